@@ -13,6 +13,7 @@ import (
 
 	"github.com/aeolun/superchat/cmd/client-gui/ui"
 	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/commands"
 )
 
 var Version = "dev"
@@ -21,6 +22,14 @@ func main() {
 	// Parse command-line flags
 	throttle := flag.Int("throttle", 0, "Throttle bandwidth (bytes/sec, e.g. 600 for 14.4k modem)")
 	flag.Parse()
+
+	// Load configuration for key bindings (same file as the terminal client)
+	config, err := client.LoadClientConfig(client.DefaultConfigPath())
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	commands.SetKeyBindings(config.KeyBindings())
+
 	// Determine state path (same logic as terminal client)
 	xdgData := os.Getenv("XDG_DATA_HOME")
 	if xdgData == "" {
@@ -46,6 +55,7 @@ func main() {
 		log.Fatalf("Failed to create connection: %v", err)
 	}
 	defer conn.Close()
+	conn.SetCertificatePinStore(state)
 
 	// Apply throttle if specified
 	if *throttle > 0 {
@@ -56,6 +56,9 @@ type App struct {
 	throttle     int // Bandwidth throttle in bytes/sec
 	window       WindowInvalidator // Reference to window for triggering redraws
 
+	// Show message source instead of rendered markdown (saved in state)
+	showRawMarkdown bool
+
 	// View state
 	mainView     commands.ViewID
 	composeModal *ComposeModal // Active compose modal (nil if not open)
@@ -110,6 +113,7 @@ func NewApp(conn client.ConnectionInterface, state client.StateInterface, theme
 		showUserList:    false,
 		throttle:        throttle,
 		window:          window,
+		showRawMarkdown: state.GetShowRawMarkdown(),
 		mainView:        commands.ViewChannelList,
 		composeModal:    nil,
 		channelList: widget.List{
@@ -208,12 +212,80 @@ func (a *App) listenForMessages() {
 				a.window.Invalidate()
 			}
 
+		case protocol.TypeReactionsUpdated:
+			resp := &protocol.ReactionsUpdatedMessage{}
+			if err := resp.Decode(frame.Payload); err != nil {
+				log.Printf("Failed to decode reactions update: %v", err)
+				continue
+			}
+			a.applyReactions(resp.MessageID, resp.Reactions)
+			// Trigger window redraw
+			if a.window != nil {
+				a.window.Invalidate()
+			}
+
+		case protocol.TypeMessagesExpired:
+			resp := &protocol.MessagesExpiredMessage{}
+			if err := resp.Decode(frame.Payload); err != nil {
+				log.Printf("Failed to decode expired messages: %v", err)
+				continue
+			}
+			a.applyMessagesExpired(resp.MessageIDs)
+			// Trigger window redraw
+			if a.window != nil {
+				a.window.Invalidate()
+			}
+
 		default:
 			// Ignore unknown messages for now
 		}
 	}
 }
 
+// applyReactions replaces the reactions of a message wherever it is shown
+func (a *App) applyReactions(messageID uint64, reactions []protocol.ReactionSummary) {
+	if a.currentThread != nil && a.currentThread.ID == messageID {
+		a.currentThread.Reactions = reactions
+	}
+	for _, messages := range [][]protocol.Message{a.threads, a.threadReplies, a.chatMessages} {
+		for i := range messages {
+			if messages[i].ID == messageID {
+				messages[i].Reactions = reactions
+			}
+		}
+	}
+}
+
+// applyMessagesExpired removes messages the server purged by retention
+func (a *App) applyMessagesExpired(messageIDs []uint64) {
+	expired := make(map[uint64]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		expired[id] = true
+	}
+	keep := func(messages []protocol.Message) []protocol.Message {
+		kept := messages[:0]
+		for _, msg := range messages {
+			if !expired[msg.ID] {
+				kept = append(kept, msg)
+			}
+		}
+		return kept
+	}
+	a.threads = keep(a.threads)
+	a.threadReplies = keep(a.threadReplies)
+	a.chatMessages = keep(a.chatMessages)
+
+	// The open thread is gone entirely: go back to the thread list
+	if a.currentThread != nil && expired[a.currentThread.ID] {
+		if a.mainView == commands.ViewThreadView {
+			a.mainView = commands.ViewThreadList
+		}
+		a.currentThread = nil
+		a.threadReplies = nil
+		a.replyFocusIndex = 0
+	}
+}
+
 // selectChannel handles channel selection
 func (a *App) selectChannel(channel *protocol.Channel) {
 	a.selectedChannel = channel
@@ -1032,13 +1104,19 @@ func (a *App) layoutChat(gtx layout.Context) layout.Dimensions {
 					// Format message display
 					// Server already prefixes anonymous users with ~
 					author := msg.AuthorNickname
-					text := fmt.Sprintf("[%s] %s", author, msg.Content)
-					label := material.Body2(a.theme, text)
+					label := material.Body2(a.theme, fmt.Sprintf("[%s] ", author))
 
 					return layout.Inset{
 						Top:    unit.Dp(2),
 						Bottom: unit.Dp(2),
-					}.Layout(gtx, label.Layout)
+					}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+							layout.Rigid(label.Layout),
+							layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+								return a.layoutMessageContent(gtx, msg.Content, label.TextSize)
+							}),
+						)
+					})
 				})
 			})
 		})
@@ -1207,9 +1285,13 @@ func (a *App) layoutThreadView(gtx layout.Context) layout.Dimensions {
 												}),
 												// Message content
 												layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-													label := material.Body1(a.theme, msg.Content)
-													label.TextSize = unit.Sp(14)
-													return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, label.Layout)
+													return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+														return a.layoutMessageContent(gtx, msg.Content, unit.Sp(14))
+													})
+												}),
+												// Reactions
+												layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+													return a.layoutReactions(gtx, msg.Reactions)
 												}),
 											)
 										}),
@@ -1254,9 +1336,13 @@ func (a *App) layoutThreadView(gtx layout.Context) layout.Dimensions {
 									}),
 									// Message content
 									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-										label := material.Body1(a.theme, msg.Content)
-										label.TextSize = unit.Sp(14)
-										return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, label.Layout)
+										return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+											return a.layoutMessageContent(gtx, msg.Content, unit.Sp(14))
+										})
+									}),
+									// Reactions
+									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+										return a.layoutReactions(gtx, msg.Reactions)
 									}),
 								)
 							})
@@ -1268,6 +1354,21 @@ func (a *App) layoutThreadView(gtx layout.Context) layout.Dimensions {
 	})
 }
 
+// layoutReactions renders a message's reaction counts below its content
+func (a *App) layoutReactions(gtx layout.Context, reactions []protocol.ReactionSummary) layout.Dimensions {
+	if len(reactions) == 0 {
+		return layout.Dimensions{}
+	}
+	parts := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		parts = append(parts, fmt.Sprintf("%s %d", reaction.Emoji, len(reaction.UserIDs)))
+	}
+	label := material.Body2(a.theme, strings.Join(parts, "   "))
+	label.Color = color.NRGBA{R: 100, G: 100, B: 100, A: 255}
+	label.TextSize = unit.Sp(12)
+	return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, label.Layout)
+}
+
 // layoutModalOverlay renders the compose modal overlay
 func (a *App) layoutModalOverlay(gtx layout.Context) layout.Dimensions {
 	// Draw semi-transparent background
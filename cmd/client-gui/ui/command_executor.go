@@ -215,6 +215,14 @@ func (a *App) ExecuteAction(actionID string) error {
 		// TODO: Implement create channel in GUI
 		return nil
 
+	// === Display Actions ===
+	case commands.ActionToggleMarkdown:
+		a.showRawMarkdown = !a.showRawMarkdown
+		if err := a.state.SetShowRawMarkdown(a.showRawMarkdown); err != nil {
+			log.Printf("Failed to save markdown setting: %v", err)
+		}
+		return nil
+
 	default:
 		// Unknown action - ignore
 	}
@@ -0,0 +1,325 @@
+// ABOUTME: Rich text rendering of markdown message bodies for the GUI client
+// ABOUTME: Lays out styled words in a wrapping flow, with code blocks, quotes and lists
+package ui
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/aeolun/superchat/pkg/client/markdown"
+)
+
+var (
+	linkColor      = color.NRGBA{R: 25, G: 118, B: 210, A: 255}
+	mutedColor     = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+	codeBackground = color.NRGBA{R: 238, G: 238, B: 238, A: 255}
+	quoteBarColor  = color.NRGBA{R: 200, G: 200, B: 200, A: 255}
+
+	tokenColors = map[markdown.TokenKind]color.NRGBA{
+		markdown.TokenKeyword: {R: 0, G: 0, B: 180, A: 255},
+		markdown.TokenString:  {R: 0, G: 128, B: 0, A: 255},
+		markdown.TokenComment: {R: 120, G: 120, B: 120, A: 255},
+		markdown.TokenNumber:  {R: 170, G: 85, B: 0, A: 255},
+	}
+)
+
+// richPiece is a word (or code token) laid out as one label
+type richPiece struct {
+	text    string
+	style   markdown.Style
+	code    bool               // Part of a code block
+	kind    markdown.TokenKind // Syntax highlighting (code blocks only)
+	muted   bool               // Link targets
+	space   bool               // Preceded by a space
+	newline bool               // Line break (no text)
+}
+
+// layoutMessageContent renders a message body as markdown, or as its source if
+// the user turned rendering off
+func (a *App) layoutMessageContent(gtx layout.Context, content string, size unit.Sp) layout.Dimensions {
+	if a.showRawMarkdown {
+		label := material.Body1(a.theme, content)
+		label.TextSize = size
+		return label.Layout(gtx)
+	}
+	return a.layoutBlocks(gtx, markdown.Parse(content), size)
+}
+
+// layoutBlocks stacks markdown blocks vertically
+func (a *App) layoutBlocks(gtx layout.Context, blocks []markdown.Block, size unit.Sp) layout.Dimensions {
+	children := make([]layout.FlexChild, 0, len(blocks))
+	for i, block := range blocks {
+		top := unit.Dp(0)
+		if i > 0 {
+			top = unit.Dp(6)
+		}
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: top}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return a.layoutBlock(gtx, block, size)
+			})
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// layoutBlock renders one markdown block
+func (a *App) layoutBlock(gtx layout.Context, block markdown.Block, size unit.Sp) layout.Dimensions {
+	switch block.Kind {
+	case markdown.BlockCode:
+		return layout.Stack{}.Layout(gtx,
+			layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+				paint.FillShape(gtx.Ops, codeBackground, clip.Rect{Max: gtx.Constraints.Min}.Op())
+				return layout.Dimensions{Size: gtx.Constraints.Min}
+			}),
+			layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+				return layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return a.layoutFlow(gtx, codePieces(block.Code, block.Lang), size-1)
+				})
+			}),
+		)
+
+	case markdown.BlockQuote:
+		return layout.Stack{}.Layout(gtx,
+			layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+				bar := image.Pt(gtx.Dp(3), gtx.Constraints.Min.Y)
+				paint.FillShape(gtx.Ops, quoteBarColor, clip.Rect{Max: bar}.Op())
+				return layout.Dimensions{Size: bar}
+			}),
+			layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return a.layoutBlocks(gtx, block.Children, size)
+				})
+			}),
+		)
+
+	case markdown.BlockList:
+		items := make([]layout.FlexChild, 0, len(block.Items))
+		for _, item := range block.Items {
+			items = append(items, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						inset := layout.Inset{Left: unit.Dp(float32(16 * item.Level)), Right: unit.Dp(6)}
+						return inset.Layout(gtx, material.Label(a.theme, size, item.Marker).Layout)
+					}),
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						return a.layoutFlow(gtx, spanPieces(item.Spans), size)
+					}),
+				)
+			}))
+		}
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, items...)
+
+	default:
+		return a.layoutFlow(gtx, spanPieces(block.Spans), size)
+	}
+}
+
+// spanPieces splits inline spans into words, adding the target after each link
+// whose text isn't the URL itself
+func spanPieces(spans []markdown.Span) []richPiece {
+	var pieces []richPiece
+	space := false
+	var linkText strings.Builder
+
+	for i, span := range spans {
+		if span.Style&markdown.Code != 0 {
+			// Code spans keep their spaces
+			pieces = append(pieces, richPiece{text: span.Text, style: span.Style, space: space})
+			space = false
+		} else {
+			pieces = appendWords(pieces, span.Text, richPiece{style: span.Style}, &space)
+		}
+
+		if span.Style&markdown.Link == 0 {
+			continue
+		}
+		linkText.WriteString(span.Text)
+		if i+1 < len(spans) && spans[i+1].Style&markdown.Link != 0 && spans[i+1].URL == span.URL {
+			continue
+		}
+		if linkText.String() != span.URL && strings.TrimPrefix(span.URL, "mailto:") != linkText.String() {
+			pieces = append(pieces, richPiece{text: "(" + span.URL + ")", muted: true, space: true})
+		}
+		linkText.Reset()
+	}
+	return pieces
+}
+
+// appendWords appends the words of text as pieces styled like p
+func appendWords(pieces []richPiece, text string, p richPiece, space *bool) []richPiece {
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			pieces = append(pieces, richPiece{newline: true})
+			*space = false
+		}
+		for j, word := range strings.Split(line, " ") {
+			if j > 0 {
+				*space = true
+			}
+			if word == "" {
+				continue
+			}
+			piece := p
+			piece.text, piece.space = word, *space
+			pieces = append(pieces, piece)
+			*space = false
+		}
+	}
+	return pieces
+}
+
+// codePieces splits a code block into highlighted tokens and line breaks
+func codePieces(code, lang string) []richPiece {
+	var pieces []richPiece
+	for _, token := range markdown.Highlight(code, lang) {
+		for i, text := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				pieces = append(pieces, richPiece{newline: true, code: true})
+			}
+			if text != "" {
+				pieces = append(pieces, richPiece{text: text, code: true, kind: token.Kind})
+			}
+		}
+	}
+	return pieces
+}
+
+// richLabel returns the label for a piece
+func (a *App) richLabel(p richPiece, size unit.Sp) material.LabelStyle {
+	label := material.Label(a.theme, size, p.text)
+	if p.style&markdown.Bold != 0 {
+		label.Font.Weight = font.Bold
+	}
+	if p.style&markdown.Italic != 0 {
+		label.Font.Style = font.Italic
+	}
+	if p.code || p.style&markdown.Code != 0 {
+		label.Font.Typeface = "monospace"
+	}
+	if c, ok := tokenColors[p.kind]; ok && p.code {
+		label.Color = c
+	}
+	if p.style&markdown.Link != 0 {
+		label.Color = linkColor
+	}
+	if p.muted {
+		label.Color = mutedColor
+	}
+	return label
+}
+
+// layoutFlow lays out pieces left to right, wrapping between words at the maximum
+// width and aligning each line on its baseline
+func (a *App) layoutFlow(gtx layout.Context, pieces []richPiece, size unit.Sp) layout.Dimensions {
+	type item struct {
+		piece richPiece
+		call  op.CallOp
+		dims  layout.Dimensions
+		x     int
+	}
+	type line struct {
+		items           []item
+		width           int
+		ascent, descent int
+	}
+
+	maxWidth := gtx.Constraints.Max.X
+	measure := func(p richPiece) item {
+		cgtx := gtx
+		cgtx.Constraints = layout.Constraints{Max: image.Pt(maxWidth, gtx.Constraints.Max.Y)}
+		macro := op.Record(gtx.Ops)
+		dims := a.richLabel(p, size).Layout(cgtx)
+		return item{piece: p, call: macro.Stop(), dims: dims}
+	}
+
+	// Empty lines are as tall as a line of text
+	blank := measure(richPiece{text: "X"})
+	spaceWidth := gtx.Sp(size) / 4
+
+	var lines []line
+	var cur line
+	place := func(word []item, space bool) {
+		if len(word) == 0 {
+			return
+		}
+		width := 0
+		for _, it := range word {
+			width += it.dims.Size.X
+		}
+		gap := 0
+		if space && len(cur.items) > 0 {
+			gap = spaceWidth
+		}
+		if len(cur.items) > 0 && cur.width+gap+width > maxWidth {
+			lines = append(lines, cur)
+			cur, gap = line{}, 0
+		}
+		x := cur.width + gap
+		for _, it := range word {
+			it.x = x
+			x += it.dims.Size.X
+			cur.ascent = max(cur.ascent, it.dims.Size.Y-it.dims.Baseline)
+			cur.descent = max(cur.descent, it.dims.Baseline)
+			cur.items = append(cur.items, it)
+		}
+		cur.width = x
+	}
+
+	// Group pieces into words that wrap together ("bold" and "," in "**bold**,");
+	// code tokens can wrap individually
+	var word []item
+	wordSpace := false
+	for _, p := range pieces {
+		if p.newline {
+			place(word, wordSpace)
+			word = nil
+			lines = append(lines, cur)
+			cur = line{}
+			continue
+		}
+		if len(word) > 0 && (p.space || p.code) {
+			place(word, wordSpace)
+			word = nil
+		}
+		if len(word) == 0 {
+			wordSpace = p.space
+		}
+		word = append(word, measure(p))
+	}
+	place(word, wordSpace)
+	lines = append(lines, cur)
+
+	y, width := 0, 0
+	for _, l := range lines {
+		if len(l.items) == 0 {
+			l.ascent, l.descent = blank.dims.Size.Y-blank.dims.Baseline, blank.dims.Baseline
+		}
+		for _, it := range l.items {
+			top := y + l.ascent - (it.dims.Size.Y - it.dims.Baseline)
+			offset := op.Offset(image.Pt(it.x, top)).Push(gtx.Ops)
+			if it.piece.style&markdown.Code != 0 && !it.piece.code {
+				paint.FillShape(gtx.Ops, codeBackground, clip.Rect{Max: it.dims.Size}.Op())
+			}
+			it.call.Add(gtx.Ops)
+			if it.piece.style&markdown.Link != 0 {
+				baseline := it.dims.Size.Y - it.dims.Baseline + gtx.Dp(1)
+				underline := clip.Rect{Min: image.Pt(0, baseline), Max: image.Pt(it.dims.Size.X, baseline+max(gtx.Dp(1), 1))}
+				paint.FillShape(gtx.Ops, linkColor, underline.Op())
+			}
+			offset.Pop()
+		}
+		y += l.ascent + l.descent
+		width = max(width, l.width)
+	}
+
+	return layout.Dimensions{Size: gtx.Constraints.Constrain(image.Pt(width, y))}
+}
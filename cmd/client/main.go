@@ -44,9 +44,20 @@ func getDefaultConfigPath() string {
 	return filepath.Join(xdgConfig, "superchat", "config.toml")
 }
 
-func handleUpdate() {
+// handleUpdate runs "sc update [--channel stable|prerelease]". restartArgs are the
+// arguments the new version is started with afterwards.
+func handleUpdate(args, restartArgs []string) {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	channelName := flags.String("channel", string(updater.DefaultChannel(Version)), "Update to the newest \"stable\" release, or \"prerelease\" to include pre-releases")
+	flags.Parse(args)
+
+	channel, err := updater.ParseChannel(*channelName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	fmt.Printf("Current version: %s\n", Version)
-	fmt.Println("Checking for updates...")
+	fmt.Printf("Checking for %s updates...\n", channel)
 
 	// Get executable path to preserve install location
 	exePath, err := os.Executable()
@@ -55,7 +66,7 @@ func handleUpdate() {
 	}
 
 	// Run the updater
-	if err := updater.Update(Version, exePath, os.Args); err != nil {
+	if err := updater.Update(Version, exePath, restartArgs, channel); err != nil {
 		log.Fatalf("Update failed: %v", err)
 	}
 }
@@ -64,10 +75,11 @@ func main() {
 	// Command line flags
 	defaultConfig := getDefaultConfigPath()
 	configPath := flag.String("config", defaultConfig, "Path to config file")
-	server := flag.String("server", "", "Server address (host:port, sc://host:port, ssh://user@host:port, ws://host:port; default port varies by scheme)")
+	server := flag.String("server", "", "Server address (host:port, sc://host:port, scs://host:port, ssh://user@host:port, ws://host:port; default port varies by scheme)")
 	directory := flag.String("directory", "", "Directory server address (host:port) to fetch server list from")
 	profile := flag.String("profile", "", "Profile name for separate configuration (default: none)")
 	statePath := flag.String("state", "", "Path to state database (overrides config)")
+	forgetCert := flag.String("forget-cert", "", "Forget the pinned TLS certificate of a scs:// server (host:port) and exit")
 	throttle := flag.Int("throttle", 0, "Bandwidth limit in bytes/sec (e.g., 3600 for 28.8kbps dial-up, 0=unlimited)")
 	version := flag.Bool("version", false, "Show version information")
 	flag.Parse()
@@ -82,7 +94,9 @@ func main() {
 	if flag.NArg() > 0 {
 		switch flag.Arg(0) {
 		case "update":
-			handleUpdate()
+			// Restart with the flags given before the subcommand
+			restartArgs := os.Args[:len(os.Args)-flag.NArg()]
+			handleUpdate(flag.Args()[1:], restartArgs)
 			return
 		default:
 			log.Fatalf("Unknown command: %s", flag.Arg(0))
@@ -101,6 +115,20 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Apply the UI theme (LoadClientConfig already checked that it loads)
+	theme, err := config.LoadTheme()
+	if err != nil {
+		log.Fatalf("Failed to load theme: %v", err)
+	}
+	ui.ApplyTheme(theme)
+
+	// Key bindings were checked against the shared commands; check them against
+	// the terminal client's own commands as well
+	if err := ui.CheckKeyBindings(config.KeyBindings()); err != nil {
+		client.HandleConfigError(*configPath, &client.ConfigError{Path: *configPath, Message: err.Error()})
+		os.Exit(1)
+	}
+
 	// Determine state path
 	finalStatePath := ""
 	if *statePath != "" {
@@ -132,6 +160,20 @@ func main() {
 	}
 	defer state.Close()
 
+	// Handle --forget-cert flag
+	if *forgetCert != "" {
+		addr, removed, err := client.ForgetCertificatePin(state, *forgetCert)
+		if err != nil {
+			log.Fatalf("Failed to forget certificate: %v", err)
+		}
+		if removed {
+			fmt.Printf("Forgot the pinned certificate of %s; the next connection will trust the certificate it presents\n", addr)
+		} else {
+			fmt.Printf("No certificate pinned for %s\n", addr)
+		}
+		return
+	}
+
 	// Set up debug logger early (before determining connection address)
 	logger, logFile, err := setupLogger(state.GetStateDir())
 	if err != nil {
@@ -202,6 +244,7 @@ func main() {
 	}
 
 	// Configure connection using concrete type methods
+	c.SetCertificatePinStore(state)
 	if logger != nil {
 		c.SetLogger(logger)
 	}
@@ -250,6 +293,8 @@ func main() {
 
 	// Create bubbletea program (pass connection error if any)
 	model := ui.NewModel(conn, state, Version, useDirectory, *throttle, logger, dataDir, initialConnErr)
+	model.SetTimestampFormatter(config.TimestampFormatter())
+	model.SetKeyBindings(config.KeyBindings())
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
 	// Run the program
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/aeolun/superchat/pkg/client/auth"
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/server"
+)
+
+const adminUsage = `Usage: scd admin [--config path] [--db path] [--socket path | --direct] <command>
+
+Users:
+  user create <nickname> [--role admin|moderator] [--password-stdin]
+  user list
+  user promote <nickname> [--role admin|moderator]   (default: admin)
+  user demote <nickname> [--role admin|moderator]    (default: both)
+  user reset-password <nickname> [--password-stdin]
+  user disable-2fa <nickname>
+
+Bans:
+  ban <nickname> [--reason text] [--duration 7d] [--shadow]
+  unban <nickname>
+  ban-ip <ip[/cidr]> [--reason text] [--duration 24h]
+  unban-ip <ip[/cidr]>
+  bans [--all]
+
+Channels:
+  channel list
+  channel delete <name|id> [--reason text] [--yes]
+
+Audit log:
+  log [--admin nickname] [--action type] [--since 24h] [--limit 50]
+
+Commands go through the admin socket of the server running on the database
+(admin_socket, <database_path>.admin.sock by default), and use the database
+directly when no server is running.
+`
+
+// runAdmin implements "scd admin", returning the exit code
+func runAdmin(args []string) int {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, adminUsage) }
+	configPath := fs.String("config", "~/.superchat/config.toml", "Path to config file")
+	dbPath := fs.String("db", "", "Path to SQLite database (overrides config)")
+	socketPath := fs.String("socket", "", "Admin socket of the running server (overrides config)")
+	direct := fs.Bool("direct", false, "Use the database directly instead of the admin socket")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	req, err := parseAdminCommand(fs.Args())
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "scd admin: %v\n\n", err)
+			fmt.Fprint(os.Stderr, adminUsage)
+		}
+		return 2
+	}
+	if req == nil {
+		return 1 // Cancelled
+	}
+	req.Admin = "cli"
+	if u, err := user.Current(); err == nil {
+		req.Admin = "cli:" + u.Username
+	}
+
+	// The database package logs migrations and timings; only results matter here
+	log.SetOutput(io.Discard)
+
+	config, err := server.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scd admin: failed to load config: %v\n", err)
+		return 1
+	}
+
+	output, err := executeAdmin(config, *dbPath, *socketPath, *direct, *req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scd admin: %v\n", err)
+		return 1
+	}
+	fmt.Println(output)
+	return 0
+}
+
+// executeAdmin runs a request through the admin socket of the server using the database
+// if there is one, and on the database otherwise
+func executeAdmin(config server.TOMLConfig, dbPath, socketPath string, direct bool, req server.AdminRequest) (string, error) {
+	if dbPath != "" {
+		config.Server.DatabasePath = dbPath
+		if config.Server.AdminSocket != server.AdminSocketDisabled {
+			config.Server.AdminSocket = "" // The socket next to that database
+		}
+	}
+	if socketPath != "" {
+		config.Server.AdminSocket = socketPath
+	}
+	socket, err := config.GetAdminSocketPath()
+	if err != nil {
+		return "", err
+	}
+	if socket != "" && !direct {
+		output, err := server.SendAdminRequest(socket, req)
+		if !errors.Is(err, server.ErrAdminSocketUnavailable) {
+			return output, err
+		}
+		// The server isn't running, so the database can be used directly
+	}
+
+	path, err := config.GetDatabasePath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("database %s not found", path)
+	}
+
+	// A running server caches the database in memory, so changes have to go through it.
+	// Holding the lock also keeps a server from starting halfway through a change.
+	if !req.ReadOnly() {
+		lock, err := server.LockDatabase(path)
+		if errors.Is(err, server.ErrDatabaseInUse) {
+			switch {
+			case direct:
+				return "", errors.New("the server is running: leave out --direct to send the command to it, or stop the server first")
+			case socket == "":
+				return "", errors.New(`the server is running with admin_socket = "off": enable the admin socket to change the database while it runs, or stop the server first`)
+			default:
+				return "", fmt.Errorf("the server is running but not listening on %s: use --socket with the admin_socket it was started with, or stop the server first", socket)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		defer lock.Close()
+	}
+
+	// WAL mode and the busy timeout make reading safe while the server is running
+	db, err := database.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	return server.NewAdminCommands(db).Execute(req)
+}
+
+// parseAdminCommand turns command line arguments into a request. It returns nil
+// if the user cancelled.
+func parseAdminCommand(args []string) (*server.AdminRequest, error) {
+	command := args[0]
+	args = args[1:]
+	if command == "user" || command == "channel" {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s needs a subcommand", command)
+		}
+		command += " " + args[0]
+		args = args[1:]
+	}
+
+	req := &server.AdminRequest{Command: command}
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	var positional string
+	var duration, since string
+	var passwordStdin, yes bool
+	switch command {
+	case "user create":
+		fs.StringVar(&req.Role, "role", "", "")
+		fs.BoolVar(&passwordStdin, "password-stdin", false, "")
+	case "user promote", "user demote":
+		fs.StringVar(&req.Role, "role", "", "")
+	case "user reset-password":
+		fs.BoolVar(&passwordStdin, "password-stdin", false, "")
+	case "ban":
+		fs.StringVar(&req.Reason, "reason", "", "")
+		fs.StringVar(&duration, "duration", "", "")
+		fs.BoolVar(&req.Shadowban, "shadow", false, "")
+	case "ban-ip":
+		fs.StringVar(&req.Reason, "reason", "", "")
+		fs.StringVar(&duration, "duration", "", "")
+	case "bans":
+		fs.BoolVar(&req.All, "all", false, "")
+	case "channel delete":
+		fs.StringVar(&req.Reason, "reason", "", "")
+		fs.BoolVar(&yes, "yes", false, "")
+	case "log":
+		fs.StringVar(&req.FilterAdmin, "admin", "", "")
+		fs.StringVar(&req.FilterAction, "action", "", "")
+		fs.StringVar(&since, "since", "", "")
+		fs.IntVar(&req.Limit, "limit", 50, "")
+	case "user list", "user disable-2fa", "unban", "unban-ip", "channel list":
+	default:
+		return nil, fmt.Errorf("unknown command %q", command)
+	}
+
+	// Flags may come before or after the positional argument
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if positional != "" {
+			return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+		}
+		positional = fs.Arg(0)
+		args = fs.Args()[1:]
+	}
+
+	switch command {
+	case "user list", "bans", "channel list", "log":
+		if positional != "" {
+			return nil, fmt.Errorf("unexpected argument %q", positional)
+		}
+	default:
+		if positional == "" {
+			return nil, fmt.Errorf("%s needs an argument", command)
+		}
+	}
+
+	switch command {
+	case "ban-ip", "unban-ip":
+		req.IP = positional
+	case "channel delete":
+		req.Channel = positional
+	default:
+		req.Nickname = positional
+	}
+
+	if duration != "" {
+		d, err := parseAdminDuration(duration)
+		if err != nil {
+			return nil, err
+		}
+		seconds := uint64(d / time.Second)
+		req.DurationSeconds = &seconds
+	}
+	if since != "" {
+		d, err := parseAdminDuration(since)
+		if err != nil {
+			return nil, err
+		}
+		req.Since = time.Now().Add(-d).UnixMilli()
+	}
+
+	if command == "user create" || command == "user reset-password" {
+		password, err := readPassword(passwordStdin)
+		if err != nil {
+			return nil, err
+		}
+		req.Password = auth.HashPassword(password, req.Nickname)
+	}
+
+	if command == "channel delete" && !yes {
+		if !confirm(fmt.Sprintf("Delete channel %s and all of its messages?", positional)) {
+			fmt.Fprintln(os.Stderr, "Cancelled")
+			return nil, nil
+		}
+	}
+
+	return req, nil
+}
+
+// parseAdminDuration parses a Go duration, or a number of days ("7d")
+func parseAdminDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. 30m, 24h or 7d)", s)
+	}
+	return d, nil
+}
+
+// readPassword reads a new password from stdin, prompting twice on a terminal
+func readPassword(fromStdin bool) (string, error) {
+	var password string
+	if fromStdin || !term.IsTerminal(os.Stdin.Fd()) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	} else {
+		fmt.Fprint(os.Stderr, "Password: ")
+		first, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		second, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passwords don't match")
+		}
+		password = string(first)
+	}
+
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters")
+	}
+	return password, nil
+}
+
+// confirm asks a yes/no question on the terminal (no on anything else)
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
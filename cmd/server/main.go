@@ -21,6 +21,11 @@ var (
 )
 
 func main() {
+	// Admin subcommands operate on the database instead of running the server
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(os.Args[2:]))
+	}
+
 	// Configure logger with microsecond precision
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
 
@@ -142,10 +147,19 @@ func main() {
 	} else {
 		log.Printf("SSH server disabled (ssh_port=%d)", serverConfig.SSHPort)
 	}
+	if serverConfig.TLSPort > 0 {
+		log.Printf("TLS Port: %d", serverConfig.TLSPort)
+		log.Printf("TLS Certificate: %s", serverConfig.TLSCertPath)
+	} else {
+		log.Printf("TLS server disabled (tls_port=%d)", serverConfig.TLSPort)
+	}
 
 	// Display available connection methods
 	log.Printf("Available connection methods:")
 	log.Printf("  - Binary Protocol (TCP): port %d", serverConfig.TCPPort)
+	if serverConfig.TLSPort > 0 {
+		log.Printf("  - Binary Protocol (TLS): port %d (scs://server:%d)", serverConfig.TLSPort, serverConfig.TLSPort)
+	}
 	if serverConfig.SSHPort > 0 {
 		log.Printf("  - SSH: port %d", serverConfig.SSHPort)
 	}
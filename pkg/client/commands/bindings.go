@@ -0,0 +1,315 @@
+// ABOUTME: User key bindings from the [keybindings] section of config.toml
+// ABOUTME: Remaps command keys per action, optionally per view or modal, and detects conflicts
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KeyBindings holds the user's key overrides by ActionID. Overrides apply
+// everywhere, or only in one view or modal (its scope). A nil *KeyBindings
+// leaves every command on its default keys.
+type KeyBindings struct {
+	global map[string]keyOverride            // action -> override
+	scoped map[string]map[string]keyOverride // scope -> action -> override
+}
+
+// keyOverride changes the keys of one action
+type keyOverride struct {
+	replace  []string // Keys replacing the defaults (only if replaced is set; empty unbinds)
+	replaced bool
+	add      []string // Keys added on top
+}
+
+// apply returns keys with the override applied
+func (o keyOverride) apply(keys []string) []string {
+	if o.replaced {
+		keys = o.replace
+	}
+	if len(o.add) == 0 {
+		return keys
+	}
+	result := make([]string, 0, len(keys)+len(o.add))
+	result = append(result, keys...)
+	for _, key := range o.add {
+		if !containsKey(result, key) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// BindableActions lists the action IDs keys can be bound to
+var BindableActions = []string{
+	ActionHelp, ActionQuit, ActionServerList,
+	ActionNavigateUp, ActionNavigateDown, ActionSelect, ActionGoBack,
+	ActionComposeNewThread, ActionComposeReply, ActionSendMessage, ActionEditMessage, ActionDeleteMessage,
+	ActionReact, ActionMessageHistory, ActionRefresh,
+	ActionAdminPanel, ActionCreateChannel, ActionCreateSubchannel,
+	ActionStartDM, ActionChangeNickname, ActionRegister, ActionSignIn, ActionGoAnonymous, ActionSSHKeys, ActionTwoFactor,
+	ActionToggleUsers, ActionProfiles, ActionSearch, ActionMentions, ActionCommandPalette, ActionToggleMarkdown,
+}
+
+// bindingScopes lists the views and modals bindings can be scoped to
+var bindingScopes = func() []string {
+	var scopes []string
+	for v := ViewSplash; v <= ViewChatChannel; v++ {
+		scopes = append(scopes, v.String())
+	}
+	for m := ModalCompose; m <= ModalDeleteConfirm; m++ {
+		scopes = append(scopes, m.String())
+	}
+	return scopes
+}()
+
+// scopeKey normalizes a view or modal name, so "thread_view" matches "ThreadView"
+func scopeKey(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
+
+// ParseKeyBindings parses the [keybindings] config section:
+//
+//	[keybindings]
+//	compose_reply = ["R"]             # Replace an action's keys
+//	quit = []                         # Unbind an action
+//	add = { navigate_up = ["w"] }     # Add keys, keeping the defaults
+//
+//	[keybindings.thread_view]         # Only in one view or modal
+//	edit_message = ["E"]
+func ParseKeyBindings(section map[string]interface{}) (*KeyBindings, error) {
+	b := &KeyBindings{
+		global: make(map[string]keyOverride),
+		scoped: make(map[string]map[string]keyOverride),
+	}
+
+	for name, value := range section {
+		if _, isTable := value.(map[string]interface{}); isTable && name != "add" {
+			scope := scopeKey(name)
+			known := false
+			for _, s := range bindingScopes {
+				known = known || scopeKey(s) == scope
+			}
+			if !known {
+				return nil, fmt.Errorf("keybindings: unknown view or modal %q", name)
+			}
+			overrides, err := parseOverrides(name+".", value.(map[string]interface{}))
+			if err != nil {
+				return nil, err
+			}
+			b.scoped[scope] = overrides
+			continue
+		}
+		overrides, err := parseOverrides("", map[string]interface{}{name: value})
+		if err != nil {
+			return nil, err
+		}
+		for action, o := range overrides {
+			merged := b.global[action]
+			if o.replaced {
+				merged.replace, merged.replaced = o.replace, true
+			}
+			merged.add = append(merged.add, o.add...)
+			b.global[action] = merged
+		}
+	}
+
+	return b, nil
+}
+
+// parseOverrides parses "action = [keys]" entries and an "add" table of them
+func parseOverrides(prefix string, table map[string]interface{}) (map[string]keyOverride, error) {
+	overrides := make(map[string]keyOverride)
+	for name, value := range table {
+		if name == "add" {
+			added, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("keybindings: %sadd must be a table of action = [keys]", prefix)
+			}
+			for action, keys := range added {
+				parsed, err := parseKeys(prefix+"add."+action, action, keys)
+				if err != nil {
+					return nil, err
+				}
+				o := overrides[action]
+				o.add = parsed
+				overrides[action] = o
+			}
+			continue
+		}
+
+		parsed, err := parseKeys(prefix+name, name, value)
+		if err != nil {
+			return nil, err
+		}
+		o := overrides[name]
+		o.replace, o.replaced = parsed, true
+		overrides[name] = o
+	}
+	return overrides, nil
+}
+
+// parseKeys validates an action name and its list of keys
+func parseKeys(field, action string, value interface{}) ([]string, error) {
+	known := false
+	for _, a := range BindableActions {
+		known = known || a == action
+	}
+	if !known {
+		return nil, fmt.Errorf("keybindings: unknown action %q (known actions: %s)", field, strings.Join(BindableActions, ", "))
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("keybindings: %s must be a list of keys", field)
+	}
+	keys := make([]string, 0, len(list))
+	for _, item := range list {
+		key, ok := item.(string)
+		if !ok || strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("keybindings: %s: invalid key %v", field, item)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Keys returns the keys bound to an action, given its default keys and the names
+// of the current view and modal
+func (b *KeyBindings) Keys(actionID string, defaults []string, scopes ...string) []string {
+	if b == nil || actionID == "" {
+		return defaults
+	}
+	keys := defaults
+	if o, ok := b.global[actionID]; ok {
+		keys = o.apply(keys)
+	}
+	for _, scope := range scopes {
+		if o, ok := b.scoped[scopeKey(scope)][actionID]; ok {
+			keys = o.apply(keys)
+		}
+	}
+	return keys
+}
+
+// ContextScopes returns the scope names that apply in a view with a modal open
+func ContextScopes(view ViewID, modal ModalType) []string {
+	if modal == ModalNone {
+		return []string{view.String()}
+	}
+	return []string{view.String(), modal.String()}
+}
+
+// Binding describes where a command's keys are active, for conflict detection
+type Binding struct {
+	ActionID string
+	Keys     []string    // Default keys
+	Views    []ViewID    // Empty = all views
+	Modals   []ModalType // Empty = all modals
+}
+
+// SharedBindings returns the bindings of the shared commands
+func SharedBindings() []Binding {
+	bindings := make([]Binding, 0, len(SharedCommands))
+	for _, cmd := range SharedCommands {
+		b := Binding{ActionID: cmd.ActionID, Keys: cmd.Keys, Modals: cmd.ModalStates}
+		if cmd.Scope == ScopeView {
+			b.Views = cmd.ViewStates
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
+
+// CheckConflicts returns an error listing keys that would trigger more than one
+// action in the same view and modal
+func (b *KeyBindings) CheckConflicts(bindings []Binding) error {
+	seen := make(map[string]bool)
+	var conflicts []string
+
+	for view := ViewSplash; view <= ViewChatChannel; view++ {
+		for modal := ModalNone; modal <= ModalDeleteConfirm; modal++ {
+			scopes := ContextScopes(view, modal)
+			boundTo := make(map[string]string) // key -> action
+			for _, binding := range bindings {
+				if !containsView(binding.Views, view) || !containsModal(binding.Modals, modal) {
+					continue
+				}
+				for _, key := range b.Keys(binding.ActionID, binding.Keys, scopes...) {
+					other, exists := boundTo[key]
+					if !exists {
+						boundTo[key] = binding.ActionID
+						continue
+					}
+					if other == binding.ActionID {
+						continue
+					}
+					pair := []string{other, binding.ActionID}
+					sort.Strings(pair)
+					id := key + " " + strings.Join(pair, " ")
+					if seen[id] {
+						continue
+					}
+					seen[id] = true
+					where := view.String()
+					if modal != ModalNone {
+						where += ", " + modal.String() + " dialog"
+					}
+					conflicts = append(conflicts, fmt.Sprintf("key %q is bound to both %s and %s (in %s)", key, pair[0], pair[1], where))
+				}
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("Conflicting key bindings:\n  • %s", strings.Join(conflicts, "\n  • "))
+	}
+	return nil
+}
+
+// activeBindings are the user's bindings applied to SharedCommands
+var activeBindings *KeyBindings
+
+// SetKeyBindings applies user key bindings to the shared commands
+func SetKeyBindings(b *KeyBindings) {
+	activeBindings = b
+}
+
+// ActiveKeyBindings returns the key bindings set with SetKeyBindings
+func ActiveKeyBindings() *KeyBindings {
+	return activeBindings
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsView(views []ViewID, view ViewID) bool {
+	if len(views) == 0 {
+		return true
+	}
+	for _, v := range views {
+		if v == view {
+			return true
+		}
+	}
+	return false
+}
+
+func containsModal(modals []ModalType, modal ModalType) bool {
+	if len(modals) == 0 {
+		return true
+	}
+	for _, m := range modals {
+		if m == modal {
+			return true
+		}
+	}
+	return false
+}
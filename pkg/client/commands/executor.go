@@ -40,7 +40,8 @@ type CommandExecutor interface {
 	ExecuteAction(actionID string) error
 }
 
-// Standard action IDs (used by shared commands)
+// Standard action IDs (used by shared commands, and by the terminal client's own
+// commands so their keys can be rebound)
 const (
 	// Global actions
 	ActionHelp       = "help"
@@ -60,15 +61,32 @@ const (
 	ActionCancelCompose    = "cancel_compose"
 	ActionEditMessage      = "edit_message"
 	ActionDeleteMessage    = "delete_message"
+	ActionReact            = "react"
+	ActionMessageHistory   = "message_history"
+	ActionRefresh          = "refresh"
 
 	// Admin actions
 	ActionAdminPanel   = "admin_panel"
 	ActionCreateChannel = "create_channel"
 	ActionBanUser      = "ban_user"
 	ActionUnbanUser    = "unban_user"
+	ActionCreateSubchannel = "create_subchannel"
 
 	// User actions
 	ActionChangeNickname = "change_nickname"
 	ActionChangePassword = "change_password"
 	ActionRegister       = "register"
+	ActionSignIn         = "sign_in"
+	ActionGoAnonymous    = "go_anonymous"
+	ActionSSHKeys        = "ssh_keys"
+	ActionTwoFactor      = "two_factor"
+	ActionStartDM        = "start_dm"
+
+	// Other actions
+	ActionToggleUsers    = "toggle_users"
+	ActionProfiles       = "profiles"
+	ActionSearch         = "search"
+	ActionMentions       = "mentions"
+	ActionCommandPalette = "command_palette"
+	ActionToggleMarkdown = "toggle_markdown"
 )
@@ -233,19 +233,36 @@ var SharedCommands = []CommandDefinition{
 		},
 		Priority: 801,
 	},
+
+	// === Display Commands ===
+
+	{
+		Keys:        []string{"M"},
+		Name:        "Raw/Markdown",
+		HelpText:    "Toggle between rendered markdown and raw message source",
+		Scope:       ScopeView,
+		ViewStates:  []ViewID{ViewThreadView, ViewChatChannel},
+		ModalStates: []ModalType{ModalNone},
+		ActionID:    ActionToggleMarkdown,
+		Priority:    870,
+	},
 }
 
-// GetCommandsForContext returns commands available in the current context
-// Sorted by priority (lower priority = shown first)
+// GetCommandsForContext returns commands available in the current context, with
+// the user's key bindings applied. Sorted by priority (lower priority = shown first)
 func GetCommandsForContext(executor CommandExecutor) []CommandDefinition {
 	currentView := executor.GetCurrentView()
 	activeModal := executor.GetActiveModal()
+	scopes := ContextScopes(currentView, activeModal)
 
 	var available []CommandDefinition
 
 	for _, cmd := range SharedCommands {
 		if isCommandAvailable(cmd, currentView, activeModal, executor) {
-			available = append(available, cmd)
+			cmd.Keys = activeBindings.Keys(cmd.ActionID, cmd.Keys, scopes...)
+			if len(cmd.Keys) > 0 {
+				available = append(available, cmd)
+			}
 		}
 	}
 
@@ -257,22 +274,34 @@ func GetCommandsForContext(executor CommandExecutor) []CommandDefinition {
 	return available
 }
 
-// FindCommandForKey returns the first available command matching the key
-// Returns nil if no command matches
+// FindCommandForKey returns the first available command matching the key, with
+// the user's key bindings applied. Returns nil if no command matches
 func FindCommandForKey(key string, executor CommandExecutor) *CommandDefinition {
 	currentView := executor.GetCurrentView()
 	activeModal := executor.GetActiveModal()
+	scopes := ContextScopes(currentView, activeModal)
 
-	for i := range SharedCommands {
-		cmd := &SharedCommands[i]
-		if keyMatches(key, cmd.Keys) && isCommandAvailable(*cmd, currentView, activeModal, executor) {
-			return cmd
+	for _, cmd := range SharedCommands {
+		cmd.Keys = activeBindings.Keys(cmd.ActionID, cmd.Keys, scopes...)
+		if keyMatches(key, cmd.Keys) && isCommandAvailable(cmd, currentView, activeModal, executor) {
+			return &cmd
 		}
 	}
 
 	return nil
 }
 
+// BoundKeys returns the keys of the shared command for an action in the given
+// context, with the user's key bindings applied
+func BoundKeys(actionID string, view ViewID, modal ModalType) []string {
+	for _, cmd := range SharedCommands {
+		if cmd.ActionID == actionID {
+			return activeBindings.Keys(actionID, cmd.Keys, ContextScopes(view, modal)...)
+		}
+	}
+	return nil
+}
+
 // isCommandAvailable checks if a command is available in the current context
 func isCommandAvailable(cmd CommandDefinition, view ViewID, modal ModalType, executor CommandExecutor) bool {
 	// Check modal compatibility
@@ -318,15 +347,11 @@ func isCommandAvailable(cmd CommandDefinition, view ViewID, modal ModalType, exe
 	return true
 }
 
-// keyMatches checks if a key string matches any of the command's keys
+// keyMatches checks if a key string matches any of the command's keys. Matching is
+// case-sensitive so "H" and "h" can be bound separately (the GUI client reports
+// unmodified letters in lowercase, like the terminal does)
 func keyMatches(key string, commandKeys []string) bool {
-	keyLower := strings.ToLower(key)
-	for _, cmdKey := range commandKeys {
-		if strings.ToLower(cmdKey) == keyLower {
-			return true
-		}
-	}
-	return false
+	return containsKey(commandKeys, key)
 }
 
 // FormatKey converts a key string to display format
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/aeolun/superchat/pkg/client/commands"
 )
 
 // TOMLConfig represents the structure of the client config file
@@ -17,6 +19,12 @@ type TOMLConfig struct {
 	Connection ConnectionSection `toml:"connection"`
 	Local      LocalSection      `toml:"local"`
 	UI         UISection         `toml:"ui"`
+
+	// Keybindings remaps command keys by action ID (see commands.ParseKeyBindings)
+	Keybindings map[string]interface{} `toml:"keybindings,omitempty"`
+
+	dir         string                // Directory the config was loaded from (user themes live here)
+	keyBindings *commands.KeyBindings // Parsed Keybindings
 }
 
 type ConnectionSection struct {
@@ -34,8 +42,9 @@ type LocalSection struct {
 
 type UISection struct {
 	ShowTimestamps  bool   `toml:"show_timestamps"`
-	TimestampFormat string `toml:"timestamp_format"` // 'relative' or 'absolute'
-	Theme           string `toml:"theme"`
+	TimestampFormat string `toml:"timestamp_format"` // 'relative', 'absolute' or 'none'
+	AbsoluteFormat  string `toml:"absolute_format"`  // Go time layout for absolute timestamps ("" = per-view default)
+	Theme           string `toml:"theme"`            // Built-in theme or themes/<name>.toml in the config dir
 }
 
 // ConfigError represents a structured configuration error
@@ -64,6 +73,11 @@ func getXDGConfigHome() string {
 	return filepath.Join(homeDir, ".config")
 }
 
+// DefaultConfigPath returns the default client config file path
+func DefaultConfigPath() string {
+	return filepath.Join(getXDGConfigHome(), "superchat", "config.toml")
+}
+
 // getXDGDataHome returns the XDG data directory
 func getXDGDataHome() string {
 	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
@@ -117,6 +131,7 @@ func LoadClientConfig(path string) (TOMLConfig, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// File doesn't exist, create default config
 		config := DefaultTOMLConfig()
+		config.dir = filepath.Dir(path)
 		if err := writeDefaultConfig(path, config); err != nil {
 			// If we can't write, just return defaults without error
 			// (might be a permissions issue, but we can still run)
@@ -146,9 +161,50 @@ func LoadClientConfig(path string) (TOMLConfig, error) {
 		}
 	}
 
+	// Parse key bindings, and make sure they don't bind a key to two commands
+	bindings, err := commands.ParseKeyBindings(config.Keybindings)
+	if err == nil {
+		err = bindings.CheckConflicts(commands.SharedBindings())
+	}
+	if err != nil {
+		return TOMLConfig{}, &ConfigError{
+			Path:    path,
+			Message: err.Error(),
+		}
+	}
+	config.keyBindings = bindings
+
+	// Make sure the theme exists and parses, so a typo doesn't silently fall back
+	config.dir = filepath.Dir(path)
+	if _, err := config.LoadTheme(); err != nil {
+		return TOMLConfig{}, &ConfigError{
+			Path:    path,
+			Message: err.Error(),
+		}
+	}
+
 	return config, nil
 }
 
+// LoadTheme returns the configured UI theme
+func (c *TOMLConfig) LoadTheme() (Theme, error) {
+	return LoadTheme(c.UI.Theme, c.dir)
+}
+
+// KeyBindings returns the parsed [keybindings] section (nil if the config wasn't
+// loaded from a file)
+func (c *TOMLConfig) KeyBindings() *commands.KeyBindings {
+	return c.keyBindings
+}
+
+// TimestampFormatter returns the formatter for the configured timestamp settings
+func (c *TOMLConfig) TimestampFormatter() TimestampFormatter {
+	if !c.UI.ShowTimestamps {
+		return TimestampFormatter{Mode: TimestampNone}
+	}
+	return TimestampFormatter{Mode: c.UI.TimestampFormat, Layout: c.UI.AbsoluteFormat}
+}
+
 // extractLineNumber tries to extract a line number from a TOML parse error
 func extractLineNumber(errMsg string) int {
 	// TOML errors typically format like "line 12: ..." or "at line 12"
@@ -184,8 +240,15 @@ func validateConfig(config *TOMLConfig) error {
 	}
 
 	// Validate timestamp format
-	if config.UI.TimestampFormat != "" && config.UI.TimestampFormat != "relative" && config.UI.TimestampFormat != "absolute" {
-		errors = append(errors, fmt.Sprintf("Invalid timestamp format: %q (must be 'relative' or 'absolute')", config.UI.TimestampFormat))
+	switch config.UI.TimestampFormat {
+	case "", TimestampRelative, TimestampAbsolute, TimestampNone:
+	default:
+		errors = append(errors, fmt.Sprintf("Invalid timestamp format: %q (must be 'relative', 'absolute' or 'none')", config.UI.TimestampFormat))
+	}
+
+	// Validate absolute timestamp layout (a layout without any time fields formats as itself)
+	if layout := config.UI.AbsoluteFormat; layout != "" && time.Date(2001, 2, 3, 16, 5, 6, 0, time.UTC).Format(layout) == layout {
+		errors = append(errors, fmt.Sprintf("Invalid absolute timestamp format: %q (must be a Go time layout like \"2006-01-02 15:04\")", layout))
 	}
 
 	// Validate state database path is not empty
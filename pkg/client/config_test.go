@@ -0,0 +1,69 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/client/commands"
+)
+
+func TestLoadClientConfigKeyBindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	load := func(keybindings string) (TOMLConfig, error) {
+		t.Helper()
+		content := "[connection]\ndefault_port = 6465\n\n[local]\nstate_db = \"state.db\"\n\n" + keybindings
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadClientConfig(path)
+	}
+
+	config, err := load(`[keybindings]
+compose_reply = ["R"]
+quit = []
+add = { navigate_up = ["w"] }
+
+[keybindings.thread_view]
+edit_message = ["E"]
+`)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	b := config.KeyBindings()
+	for _, tc := range []struct {
+		action   string
+		defaults []string
+		scopes   []string
+		want     string
+	}{
+		{commands.ActionComposeReply, []string{"r"}, []string{"ThreadView"}, "R"},
+		{commands.ActionQuit, []string{"q"}, nil, ""},
+		{commands.ActionNavigateUp, []string{"up", "k"}, nil, "up k w"},
+		{commands.ActionEditMessage, []string{"e"}, []string{"ThreadView"}, "E"},
+		{commands.ActionEditMessage, []string{"e"}, []string{"ThreadList"}, "e"},
+		{commands.ActionHelp, []string{"h", "?"}, nil, "h ?"},
+	} {
+		if got := strings.Join(b.Keys(tc.action, tc.defaults, tc.scopes...), " "); got != tc.want {
+			t.Errorf("%s in %v: expected keys %q, got %q", tc.action, tc.scopes, tc.want, got)
+		}
+	}
+
+	// Invalid and conflicting bindings are reported as config errors
+	for keybindings, want := range map[string]string{
+		"[keybindings]\nfly = [\"f\"]\n":                  "unknown action",
+		"[keybindings]\nquit = \"q\"\n":                   "must be a list of keys",
+		"[keybindings.nowhere]\nquit = [\"q\"]\n":         "unknown view or modal",
+		"[keybindings]\ncompose_reply = [\"e\"]\n":        `key "e" is bound to both compose_reply and edit_message`,
+		"[keybindings.thread_list]\nquit = [\"n\"]\n":     `key "n" is bound to both compose_new_thread and quit`,
+		"[keybindings.compose]\nsend_message = [\"esc\"]": `key "esc" is bound to both go_back and send_message`,
+	} {
+		_, err := load(keybindings)
+		var configErr *ConfigError
+		if err == nil || !errors.As(err, &configErr) || !strings.Contains(configErr.Message, want) {
+			t.Errorf("%q: expected config error containing %q, got %v", keybindings, want, err)
+		}
+	}
+}
@@ -60,7 +60,8 @@ type Connection struct {
 	reconnecting    bool
 	securityWarning string
 	warningOnce     sync.Once
-	connectionType  string // "tcp", "ssh", or "websocket"
+	pinner          *certificatePinner // TLS certificate pinning (scs:// only)
+	connectionType  string             // "tcp", "tls", "ssh", or "websocket"
 
 	// Channels for communication
 	incoming    chan *protocol.Frame
@@ -87,6 +88,9 @@ type Connection struct {
 	// Bandwidth throttling (for testing)
 	throttleBytesPerSec int // 0 = no throttle
 
+	// Frame compression (enabled when SERVER_CONFIG says the server supports it)
+	compress bool
+
 	// Logging
 	logger *log.Logger
 
@@ -108,6 +112,7 @@ func NewConnection(addr string) (*Connection, error) {
 		rawAddr:           dialConfig.raw,
 		dial:              dialConfig.dial,
 		securityWarning:   dialConfig.warning,
+		pinner:            dialConfig.pinner,
 		incoming:          make(chan *protocol.Frame, 100),
 		outgoing:          make(chan *protocol.Frame, 100),
 		errors:            make(chan error, 10),
@@ -138,6 +143,14 @@ func (c *Connection) SetThrottle(bytesPerSec int) {
 	}
 }
 
+// SetCertificatePinStore sets where the TLS certificates of scs:// servers are pinned.
+// Without a store, a certificate is only trusted for the lifetime of the connection.
+func (c *Connection) SetCertificatePinStore(store CertificatePinStore) {
+	if c.pinner != nil {
+		c.pinner.setStore(store)
+	}
+}
+
 // DisableAutoReconnect disables automatic reconnection on connection loss
 func (c *Connection) DisableAutoReconnect() {
 	c.mu.Lock()
@@ -174,6 +187,8 @@ func (c *Connection) Connect() error {
 	connType := "tcp" // Default
 	if strings.HasPrefix(c.addr, "ssh://") {
 		connType = "ssh"
+	} else if strings.HasPrefix(c.addr, "scs://") {
+		connType = "tls"
 	} else if strings.HasPrefix(c.addr, "ws://") {
 		connType = "websocket"
 	}
@@ -181,8 +196,9 @@ func (c *Connection) Connect() error {
 	if err != nil {
 		c.logf("Primary connection failed: %v", err)
 
-		// Only try WebSocket fallback if not already trying WebSocket
-		if connType != "websocket" {
+		// Only try WebSocket fallback if not already trying WebSocket, and never
+		// for TLS, where falling back could silently drop the encryption
+		if connType != "websocket" && connType != "tls" {
 			c.logf("Attempting WebSocket fallback...")
 			wsConn, wsAddr, wsErr := c.tryWebSocketFallback()
 			if wsErr != nil {
@@ -223,8 +239,8 @@ func (c *Connection) Connect() error {
 		c.logf("Protocol validation failed: %v", err)
 		conn.Close()
 
-		// Only try WebSocket fallback if not already using it
-		if connType != "websocket" {
+		// Only try WebSocket fallback if not already using it (or TLS, see above)
+		if connType != "websocket" && connType != "tls" {
 			c.logf("Attempting WebSocket fallback after protocol failure...")
 			wsConn, wsAddr, wsErr := c.tryWebSocketFallback()
 			if wsErr != nil {
@@ -326,7 +342,7 @@ func (c *Connection) tryWebSocketFallback() (net.Conn, string, error) {
 	return nil, "", fmt.Errorf("both WSS and WS failed - %v, WS: %w", lastErr, err)
 }
 
-// GetConnectionType returns the current connection type (tcp, ssh, or websocket)
+// GetConnectionType returns the current connection type (tcp, tls, ssh, or websocket)
 func (c *Connection) GetConnectionType() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -375,6 +391,17 @@ func (c *Connection) validateProtocol() error {
 			serverConfig.ProtocolVersion, protocol.ProtocolVersion)
 	}
 
+	// Opt in to compression before anything else is sent, so the server can compress large responses
+	c.mu.Lock()
+	c.compress = serverConfig.CompressionSupported
+	c.mu.Unlock()
+	if serverConfig.CompressionSupported {
+		if err := c.enableCompression(conn); err != nil {
+			return fmt.Errorf("failed to enable compression: %w", err)
+		}
+		c.logf("Compression enabled")
+	}
+
 	// Protocol validation successful - put the SERVER_CONFIG frame into the incoming channel
 	// so it can be processed normally by the message loop
 	select {
@@ -387,6 +414,21 @@ func (c *Connection) validateProtocol() error {
 	return nil
 }
 
+// enableCompression sends SET_COMPRESSION directly, before the write loop starts
+func (c *Connection) enableCompression(conn net.Conn) error {
+	payload, err := (&protocol.SetCompressionMessage{Enabled: true}).Encode()
+	if err != nil {
+		return err
+	}
+	frame := &protocol.Frame{
+		Version: protocol.ProtocolVersion,
+		Type:    protocol.TypeSetCompression,
+		Flags:   0,
+		Payload: payload,
+	}
+	return protocol.EncodeFrame(&countingWriter{w: conn, counter: &c.bytesSent}, frame)
+}
+
 // Disconnect closes the connection
 func (c *Connection) Disconnect() {
 	c.disconnectWithReason(DisconnectUserRequested)
@@ -673,12 +715,17 @@ func (c *Connection) writeLoop() {
 			conn := c.conn
 			connected := c.connected
 			throttle := c.throttleBytesPerSec
+			compress := c.compress
 			c.mu.RUnlock()
 
 			if !connected || conn == nil {
 				continue
 			}
 
+			if compress {
+				frame = protocol.CompressFrame(frame)
+			}
+
 			// Encode to buffer first
 			var buf bytes.Buffer
 			if err := protocol.EncodeFrame(&buf, frame); err != nil {
@@ -844,11 +891,13 @@ type dialConfig struct {
 	raw     string // Raw host:port without scheme
 	dial    func() (net.Conn, error)
 	warning string
+	pinner  *certificatePinner // Set for scs:// addresses
 }
 
 const (
 	defaultTCPPort            = "6465"
 	defaultSSHPort            = "6466"
+	defaultTLSPort            = "6467"
 	defaultHTTPPort           = "8080"
 	superChatSSHVersionPrefix = "SSH-2.0-SuperChat"
 )
@@ -903,6 +952,25 @@ func parseServerAddress(raw string) (*dialConfig, error) {
 			dial:    dial,
 		}, nil
 
+	case "scs":
+		host, port, err := splitHostPortWithDefault(hostPort, defaultTLSPort)
+		if err != nil {
+			return nil, err
+		}
+
+		address := net.JoinHostPort(host, port)
+		pinner := newCertificatePinner(host, port)
+		dial := func() (net.Conn, error) {
+			return dialTLS(address, pinner)
+		}
+
+		return &dialConfig{
+			display: fmt.Sprintf("scs://%s", address),
+			raw:     address,
+			dial:    dial,
+			pinner:  pinner,
+		}, nil
+
 	case "ssh":
 		host, port, err := splitHostPortWithDefault(hostPort, defaultSSHPort)
 		if err != nil {
@@ -8,7 +8,7 @@ import (
 
 // ResolveConnectionMethod determines the best connection method for a given address
 // based on connection history. It tries multiple port variations and returns
-// the address with the appropriate scheme prefix (ssh://, ws://, wss://, or plain TCP).
+// the address with the appropriate scheme prefix (ssh://, scs://, ws://, wss://, or plain TCP).
 //
 // The function attempts to find connection history for:
 //   - The exact address as provided
@@ -84,6 +84,8 @@ func applyConnectionScheme(address string, method string) string {
 	switch method {
 	case "ssh":
 		return "ssh://" + address
+	case "tls":
+		return "scs://" + address
 	case "wss":
 		return "wss://" + address
 	case "ws", "websocket":
@@ -29,9 +29,17 @@ func (m *MockStateForHelpers) UpdateReadState(channelID uint64, subchannelID *ui
 func (m *MockStateForHelpers) GetFirstRun() bool { return false }
 func (m *MockStateForHelpers) SetFirstRunComplete() error { return nil }
 func (m *MockStateForHelpers) SaveSuccessfulConnection(serverAddress string, method string) error { return nil }
+func (m *MockStateForHelpers) GetChannelKey(serverAddress string, channelID uint64) ([]byte, error) { return nil, nil }
+func (m *MockStateForHelpers) SaveChannelKey(serverAddress string, channelID uint64, key []byte) error { return nil }
+func (m *MockStateForHelpers) GetTrustedKeys(serverAddress string, userID uint64) ([]string, error) { return nil, nil }
+func (m *MockStateForHelpers) SaveTrustedKey(serverAddress string, userID uint64, fingerprint string) error { return nil }
+func (m *MockStateForHelpers) GetCertificatePin(serverAddress string) (string, error) { return "", nil }
+func (m *MockStateForHelpers) SaveCertificatePin(serverAddress, fingerprint string) error { return nil }
 func (m *MockStateForHelpers) GetStateDir() string { return "" }
 func (m *MockStateForHelpers) GetFirstPostWarningDismissed() bool { return false }
 func (m *MockStateForHelpers) SetFirstPostWarningDismissed() error { return nil }
+func (m *MockStateForHelpers) GetShowRawMarkdown() bool { return false }
+func (m *MockStateForHelpers) SetShowRawMarkdown(raw bool) error { return nil }
 func (m *MockStateForHelpers) Close() error { return nil }
 
 func TestResolveConnectionMethod(t *testing.T) {
@@ -75,6 +83,14 @@ func TestResolveConnectionMethod(t *testing.T) {
 			},
 			expected: "ws://example.com",
 		},
+		{
+			name:    "match with TLS",
+			address: "example.com:6467",
+			connectionHistory: map[string]string{
+				"example.com:6467": "tls",
+			},
+			expected: "scs://example.com:6467",
+		},
 		{
 			name:    "match with secure WebSocket",
 			address: "example.com:8080",
@@ -122,6 +122,37 @@ func FormatRelativeTime(t time.Time) string {
 	return fmt.Sprintf("%dd ago", days)
 }
 
+// Timestamp display modes (the [ui] timestamp_format setting)
+const (
+	TimestampRelative = "relative"
+	TimestampAbsolute = "absolute"
+	TimestampNone     = "none"
+)
+
+// TimestampFormatter formats message timestamps according to the [ui] settings.
+// The zero value formats relative timestamps.
+type TimestampFormatter struct {
+	Mode   string // TimestampRelative, TimestampAbsolute or TimestampNone
+	Layout string // Go time layout for absolute timestamps ("" = the caller's default)
+}
+
+// Format formats a timestamp, using defaultLayout for absolute timestamps when no
+// layout is configured. Returns "" if timestamps are hidden.
+func (f TimestampFormatter) Format(t time.Time, defaultLayout string) string {
+	switch f.Mode {
+	case TimestampNone:
+		return ""
+	case TimestampAbsolute:
+		layout := f.Layout
+		if layout == "" {
+			layout = defaultLayout
+		}
+		return t.Local().Format(layout)
+	default:
+		return FormatRelativeTime(t)
+	}
+}
+
 // SortThreadReplies sorts messages in depth-first order based on tree structure
 // Messages are grouped by parent and sorted by timestamp within each group
 func SortThreadReplies(replies []protocol.Message, rootID uint64) []protocol.Message {
@@ -63,10 +63,26 @@ type StateInterface interface {
 	GetFirstPostWarningDismissed() bool
 	SetFirstPostWarningDismissed() error
 
+	// Message display preference: raw markdown source instead of rendered
+	GetShowRawMarkdown() bool
+	SetShowRawMarkdown(raw bool) error
+
 	// Connection history
 	GetLastSuccessfulMethod(serverAddress string) (string, error)
 	SaveSuccessfulConnection(serverAddress string, method string) error
 
+	// DM encryption keys (nil key if unknown)
+	GetChannelKey(serverAddress string, channelID uint64) ([]byte, error)
+	SaveChannelKey(serverAddress string, channelID uint64, key []byte) error
+
+	// Fingerprints of DM participants' trusted encryption keys
+	GetTrustedKeys(serverAddress string, userID uint64) ([]string, error)
+	SaveTrustedKey(serverAddress string, userID uint64, fingerprint string) error
+
+	// Pinned TLS certificates of scs:// servers ("" if none)
+	GetCertificatePin(serverAddress string) (string, error)
+	SaveCertificatePin(serverAddress, fingerprint string) error
+
 	// State directory
 	GetStateDir() string
 
@@ -0,0 +1,442 @@
+package client
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/ssh"
+)
+
+// identityKeyConfig is the state config key holding the base64 X25519 identity private key
+const identityKeyConfig = "dm_identity_key"
+
+// undecryptableContent replaces message content that could not be decrypted
+const undecryptableContent = "[unable to decrypt]"
+
+// ErrNoChannelKey is returned when encrypting for a DM whose channel key we don't have yet
+var ErrNoChannelKey = errors.New("no encryption key for this conversation yet")
+
+// Keyring holds the client's DM encryption identity and the channel keys it has unwrapped.
+// Both persist in the local state DB; channel keys are scoped to the server they belong to.
+// If the user has an unencrypted ed25519 SSH key, its X25519 form is used as the identity
+// instead, so channel keys the server wrapped for the registered SSH key can be unwrapped
+// on any device that has that key.
+type Keyring struct {
+	state       StateInterface
+	sshKeyPaths []string // ed25519 private keys to derive the identity from
+
+	mu            sync.Mutex
+	identity      *ecdh.PrivateKey
+	sshKey        *ecdh.PrivateKey // X25519 form of the SSH key (nil if there is none)
+	sshKeyLoaded  bool
+	serverAddress string
+	channelKeys   map[uint64][]byte // channelID -> AES-256 channel key (cache of state)
+}
+
+// NewKeyring creates a keyring backed by the given state for a server
+func NewKeyring(state StateInterface, serverAddress string) *Keyring {
+	return &Keyring{
+		state:         state,
+		sshKeyPaths:   defaultSSHKeyPaths(),
+		serverAddress: serverAddress,
+		channelKeys:   make(map[uint64][]byte),
+	}
+}
+
+// defaultSSHKeyPaths returns where the user's ed25519 SSH key normally lives
+func defaultSSHKeyPaths() []string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil || homeDir == "" {
+		return nil
+	}
+	return []string{filepath.Join(homeDir, ".ssh", "id_ed25519")}
+}
+
+// loadIdentity returns the identity key, generating and saving one on first use.
+// Caller must hold k.mu.
+func (k *Keyring) loadIdentity() (*ecdh.PrivateKey, error) {
+	if k.identity != nil {
+		return k.identity, nil
+	}
+
+	if encoded, err := k.state.GetConfig(identityKeyConfig); err == nil && encoded != "" {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil {
+			if key, err := ecdh.X25519().NewPrivateKey(raw); err == nil {
+				k.identity = key
+				return key, nil
+			}
+		}
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity key: %w", err)
+	}
+	if err := k.state.SetConfig(identityKeyConfig, base64.StdEncoding.EncodeToString(key.Bytes())); err != nil {
+		return nil, fmt.Errorf("failed to save identity key: %w", err)
+	}
+	k.identity = key
+	return key, nil
+}
+
+// loadSSHKey returns the X25519 form of the user's ed25519 SSH key, or nil if there is
+// none we can read (encrypted keys are only available to the SSH agent, which can't
+// hand out private keys). Caller must hold k.mu.
+func (k *Keyring) loadSSHKey() *ecdh.PrivateKey {
+	if k.sshKeyLoaded {
+		return k.sshKey
+	}
+	k.sshKeyLoaded = true
+
+	for _, path := range k.sshKeyPaths {
+		keyBytes, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		raw, err := ssh.ParseRawPrivateKey(keyBytes)
+		if err != nil {
+			continue
+		}
+		var edKey ed25519.PrivateKey
+		switch key := raw.(type) {
+		case ed25519.PrivateKey:
+			edKey = key
+		case *ed25519.PrivateKey:
+			edKey = *key
+		default:
+			continue
+		}
+		if key, err := protocol.Ed25519PrivateKeyToX25519(edKey); err == nil {
+			k.sshKey = key
+			return key
+		}
+	}
+	return nil
+}
+
+// PublicKey returns the base64 public key and key type to send in PROVIDE_PUBLIC_KEY.
+// The key derived from the SSH key is preferred, since the server also wraps channel
+// keys for the user's registered SSH keys.
+func (k *Keyring) PublicKey() (string, uint8, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if sshKey := k.loadSSHKey(); sshKey != nil {
+		return protocol.EncodeEncryptionPublicKey(sshKey.PublicKey()), protocol.KeyTypeSSHDerived, nil
+	}
+	identity, err := k.loadIdentity()
+	if err != nil {
+		return "", 0, err
+	}
+	return protocol.EncodeEncryptionPublicKey(identity.PublicKey()), protocol.KeyTypeGenerated, nil
+}
+
+// SetServer switches the keyring to the channel keys of another server
+func (k *Keyring) SetServer(serverAddress string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.serverAddress = serverAddress
+	k.channelKeys = make(map[uint64][]byte)
+}
+
+// HasChannelKey reports whether the channel key for a DM is known
+func (k *Keyring) HasChannelKey(channelID uint64) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.channelKey(channelID) != nil
+}
+
+// channelKey returns a channel key from the cache or the state DB (nil if unknown).
+// Caller must hold k.mu.
+func (k *Keyring) channelKey(channelID uint64) []byte {
+	if key, ok := k.channelKeys[channelID]; ok {
+		return key
+	}
+	key, err := k.state.GetChannelKey(k.serverAddress, channelID)
+	if err != nil || len(key) != protocol.ChannelKeySize {
+		return nil
+	}
+	k.channelKeys[channelID] = key
+	return key
+}
+
+// saveChannelKey caches a channel key and persists it. Caller must hold k.mu.
+func (k *Keyring) saveChannelKey(channelID uint64, key []byte) error {
+	k.channelKeys[channelID] = key
+	return k.state.SaveChannelKey(k.serverAddress, channelID, key)
+}
+
+// StoreWrappedKey unwraps a channel key from DM_READY or DM_LIST and remembers it.
+// The key may be wrapped for the SSH-derived key or for the generated identity.
+func (k *Keyring) StoreWrappedKey(channelID uint64, wrapped string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if sshKey := k.loadSSHKey(); sshKey != nil {
+		if key, err := protocol.UnwrapChannelKey(wrapped, channelID, sshKey); err == nil {
+			return k.saveChannelKey(channelID, key)
+		}
+	}
+
+	identity, err := k.loadIdentity()
+	if err != nil {
+		return err
+	}
+	key, err := protocol.UnwrapChannelKey(wrapped, channelID, identity)
+	if err != nil {
+		return err
+	}
+	return k.saveChannelKey(channelID, key)
+}
+
+// UntrustedKey is a new public key of a DM participant whose other keys we already trust.
+// The conversation key isn't shared with it until the user trusts it (TrustKey), since the
+// server could have added it to read the conversation.
+type UntrustedKey struct {
+	UserID      uint64
+	Nickname    string
+	PublicKey   string
+	Fingerprint string
+	Trusted     []string // Fingerprints trusted so far
+}
+
+// HandleKeyExchange answers DM_KEY_EXCHANGE by wrapping the channel key for the requested
+// public keys, generating the key first if the server asks for a new one. A participant's
+// keys are trusted on first use; later keys of the same participant are returned as
+// untrusted instead of being wrapped for.
+func (k *Keyring) HandleKeyExchange(msg *protocol.DMKeyExchangeMessage) (*protocol.ProvideChannelKeysMessage, []UntrustedKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// A repeated request for a new key reuses the one already generated, so everyone ends up with the same key
+	key := k.channelKey(msg.ChannelID)
+	if key == nil {
+		if !msg.NewKey {
+			return nil, nil, ErrNoChannelKey
+		}
+		generated, err := protocol.GenerateChannelKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := k.saveChannelKey(msg.ChannelID, generated); err != nil {
+			return nil, nil, err
+		}
+		key = generated
+	}
+
+	// Our own keys never need trusting
+	own := make(map[string]bool)
+	if sshKey := k.loadSSHKey(); sshKey != nil {
+		own[protocol.EncryptionKeyFingerprint(sshKey.PublicKey())] = true
+	}
+	if identity, err := k.loadIdentity(); err == nil {
+		own[protocol.EncryptionKeyFingerprint(identity.PublicKey())] = true
+	}
+
+	trusted := make(map[uint64][]string)
+	firstUse := make(map[uint64]bool)
+	resp := &protocol.ProvideChannelKeysMessage{ChannelID: msg.ChannelID}
+	var untrusted []UntrustedKey
+	for _, recipient := range msg.Keys {
+		pub, err := protocol.ParseEncryptionPublicKey(recipient.PublicKey)
+		if err != nil {
+			continue
+		}
+		fingerprint := protocol.EncryptionKeyFingerprint(pub)
+
+		if !own[fingerprint] {
+			pins, ok := trusted[recipient.UserID]
+			if !ok {
+				if pins, err = k.state.GetTrustedKeys(k.serverAddress, recipient.UserID); err != nil {
+					return nil, nil, fmt.Errorf("failed to read trusted keys: %w", err)
+				}
+				trusted[recipient.UserID] = pins
+				// Every key in the first exchange that mentions a participant is trusted
+				firstUse[recipient.UserID] = len(pins) == 0
+			}
+			if !containsString(pins, fingerprint) {
+				if !firstUse[recipient.UserID] {
+					untrusted = append(untrusted, UntrustedKey{
+						UserID:      recipient.UserID,
+						Nickname:    recipient.Nickname,
+						PublicKey:   recipient.PublicKey,
+						Fingerprint: fingerprint,
+						Trusted:     pins,
+					})
+					continue
+				}
+				if err := k.state.SaveTrustedKey(k.serverAddress, recipient.UserID, fingerprint); err != nil {
+					return nil, nil, fmt.Errorf("failed to trust key: %w", err)
+				}
+				trusted[recipient.UserID] = append(pins, fingerprint)
+			}
+		}
+
+		wrapped, err := protocol.WrapChannelKey(key, msg.ChannelID, pub)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp.Keys = append(resp.Keys, protocol.WrappedChannelKey{KeyID: recipient.KeyID, WrappedKey: wrapped})
+	}
+	return resp, untrusted, nil
+}
+
+// TrustKey trusts a participant's public key, after the user compared its fingerprint
+// with the participant
+func (k *Keyring) TrustKey(userID uint64, publicKey string) error {
+	pub, err := protocol.ParseEncryptionPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state.SaveTrustedKey(k.serverAddress, userID, protocol.EncryptionKeyFingerprint(pub))
+}
+
+// Fingerprint returns the fingerprint of the key we provide, for comparing with other participants
+func (k *Keyring) Fingerprint() (string, error) {
+	publicKey, _, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	pub, err := protocol.ParseEncryptionPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return protocol.EncryptionKeyFingerprint(pub), nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Encrypt encrypts message content for a DM
+func (k *Keyring) Encrypt(channelID uint64, plaintext string) (string, error) {
+	k.mu.Lock()
+	key := k.channelKey(channelID)
+	k.mu.Unlock()
+
+	if key == nil {
+		return "", ErrNoChannelKey
+	}
+	if len(plaintext) > protocol.MaxEncryptedPlaintextLength {
+		return "", fmt.Errorf("message too long for an encrypted conversation (max %d bytes)", protocol.MaxEncryptedPlaintextLength)
+	}
+	return protocol.EncryptContent(key, channelID, plaintext)
+}
+
+// DecryptFrame decrypts the content of an encrypted NEW_MESSAGE, MESSAGE_LIST, MESSAGE_EDITED or
+// MESSAGE_HISTORY frame in place and clears FlagEncrypted. Content we can't decrypt is replaced by a placeholder.
+// Frames without FlagEncrypted are left untouched.
+func (k *Keyring) DecryptFrame(frame *protocol.Frame) error {
+	if frame.Flags&protocol.FlagEncrypted == 0 {
+		return nil
+	}
+
+	var msg protocol.ProtocolMessage
+	switch frame.Type {
+	case protocol.TypeNewMessage:
+		m := &protocol.NewMessageMessage{}
+		if err := m.Decode(frame.Payload); err != nil {
+			return err
+		}
+		m.Content = k.decrypt(m.ChannelID, m.Content)
+		msg = m
+	case protocol.TypeMessageList:
+		m := &protocol.MessageListMessage{}
+		if err := m.Decode(frame.Payload); err != nil {
+			return err
+		}
+		for i := range m.Messages {
+			m.Messages[i].Content = k.decrypt(m.Messages[i].ChannelID, m.Messages[i].Content)
+		}
+		msg = m
+	case protocol.TypeMessageHistory:
+		m := &protocol.MessageHistoryMessage{}
+		if err := m.Decode(frame.Payload); err != nil {
+			return err
+		}
+		for i := range m.Revisions {
+			m.Revisions[i].Content = k.decrypt(m.ChannelID, m.Revisions[i].Content)
+		}
+		msg = m
+	case protocol.TypeMessageEdited:
+		// MESSAGE_EDITED doesn't carry the channel, so try each DM key we know
+		m := &protocol.MessageEditedMessage{}
+		if err := m.Decode(frame.Payload); err != nil {
+			return err
+		}
+		m.NewContent = k.decryptAny(m.NewContent)
+		msg = m
+	default:
+		return nil
+	}
+
+	payload, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+	frame.Payload = payload
+	frame.Flags &^= protocol.FlagEncrypted
+	return nil
+}
+
+// decrypt decrypts content for a channel, returning a placeholder on failure
+func (k *Keyring) decrypt(channelID uint64, content string) string {
+	if isDeletionTombstone(content) {
+		return content
+	}
+
+	k.mu.Lock()
+	key := k.channelKey(channelID)
+	k.mu.Unlock()
+
+	if key == nil {
+		return undecryptableContent
+	}
+	plaintext, err := protocol.DecryptContent(key, channelID, content)
+	if err != nil {
+		return undecryptableContent
+	}
+	return plaintext
+}
+
+// decryptAny tries every channel key used so far, returning a placeholder if none match
+func (k *Keyring) decryptAny(content string) string {
+	if isDeletionTombstone(content) {
+		return content
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for channelID, key := range k.channelKeys {
+		if plaintext, err := protocol.DecryptContent(key, channelID, content); err == nil {
+			return plaintext
+		}
+	}
+	return undecryptableContent
+}
+
+// isDeletionTombstone reports whether content is the plaintext marker the server
+// writes over deleted messages (the only plaintext an encrypted DM can contain)
+func isDeletionTombstone(content string) bool {
+	return strings.HasPrefix(content, "[deleted")
+}
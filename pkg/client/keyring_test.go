@@ -0,0 +1,347 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestKeyring creates a keyring that ignores the SSH keys of the user running the tests
+func newTestKeyring(state StateInterface, serverAddress string) *Keyring {
+	keyring := NewKeyring(state, serverAddress)
+	keyring.sshKeyPaths = nil
+	return keyring
+}
+
+// writeSSHKey writes an unencrypted ed25519 key in OpenSSH format, like ssh-keygen does
+func writeSSHKey(t *testing.T) (string, ed25519.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path, pub
+}
+
+func TestKeyringIdentityPersists(t *testing.T) {
+	state := NewMockState()
+
+	first, _, err := newTestKeyring(state, "chat.example.com:6465").PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	second, _, err := newTestKeyring(state, "chat.example.com:6465").PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	if first != second {
+		t.Fatal("expected identity key to be reused from state")
+	}
+}
+
+func TestKeyringChannelKeysPersist(t *testing.T) {
+	state := NewMockState()
+	keyring := newTestKeyring(state, "chat.example.com:6465")
+
+	pub, _, err := keyring.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	resp, _, err := keyring.HandleKeyExchange(&protocol.DMKeyExchangeMessage{
+		ChannelID: 5,
+		NewKey:    true,
+		Keys:      []protocol.DMPublicKey{{KeyID: 1, PublicKey: pub}},
+	})
+	if err != nil || len(resp.Keys) != 1 {
+		t.Fatalf("HandleKeyExchange failed: %v", err)
+	}
+
+	// A new keyring on the same state still has the key, but only for the same server
+	reopened := newTestKeyring(state, "chat.example.com:6465")
+	if !reopened.HasChannelKey(5) {
+		t.Fatal("expected channel key to be loaded from state")
+	}
+	reopened.SetServer("other.example.com:6465")
+	if reopened.HasChannelKey(5) {
+		t.Fatal("channel keys must not leak across servers")
+	}
+}
+
+func TestKeyringKeyExchangeAndDecrypt(t *testing.T) {
+	alice := newTestKeyring(NewMockState(), "chat.example.com:6465")
+	bob := newTestKeyring(NewMockState(), "chat.example.com:6465")
+
+	alicePub, _, err := alice.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	bobPub, _, err := bob.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+
+	const channelID = 42
+	exchange := &protocol.DMKeyExchangeMessage{
+		ChannelID: channelID,
+		NewKey:    true,
+		Keys: []protocol.DMPublicKey{
+			{KeyID: 1, PublicKey: alicePub},
+			{KeyID: 2, PublicKey: bobPub},
+		},
+	}
+
+	// Bob can't answer a request for a key he doesn't hold
+	if _, _, err := bob.HandleKeyExchange(&protocol.DMKeyExchangeMessage{ChannelID: channelID, Keys: exchange.Keys}); err != ErrNoChannelKey {
+		t.Fatalf("expected ErrNoChannelKey, got %v", err)
+	}
+
+	resp, _, err := alice.HandleKeyExchange(exchange)
+	if err != nil {
+		t.Fatalf("HandleKeyExchange failed: %v", err)
+	}
+	if len(resp.Keys) != 2 {
+		t.Fatalf("expected 2 wrapped keys, got %d", len(resp.Keys))
+	}
+
+	// A repeated request must not generate a different key
+	again, _, err := alice.HandleKeyExchange(exchange)
+	if err != nil {
+		t.Fatalf("HandleKeyExchange failed: %v", err)
+	}
+	if err := bob.StoreWrappedKey(channelID, again.Keys[1].WrappedKey); err != nil {
+		t.Fatalf("StoreWrappedKey failed: %v", err)
+	}
+
+	ciphertext, err := alice.Encrypt(channelID, "hello bob")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	msg := &protocol.NewMessageMessage{ID: 1, ChannelID: channelID, Content: ciphertext}
+	payload, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	frame := &protocol.Frame{Type: protocol.TypeNewMessage, Flags: protocol.FlagEncrypted, Payload: payload}
+	if err := bob.DecryptFrame(frame); err != nil {
+		t.Fatalf("DecryptFrame failed: %v", err)
+	}
+	if frame.Flags&protocol.FlagEncrypted != 0 {
+		t.Error("expected FlagEncrypted to be cleared")
+	}
+
+	decoded := &protocol.NewMessageMessage{}
+	if err := decoded.Decode(frame.Payload); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Content != "hello bob" {
+		t.Errorf("expected decrypted content, got %q", decoded.Content)
+	}
+}
+
+func TestKeyringSSHKey(t *testing.T) {
+	sshKeyPath, sshPub := writeSSHKey(t)
+
+	// Bob's client derives its key from his SSH key
+	bob := newTestKeyring(NewMockState(), "chat.example.com:6465")
+	bob.sshKeyPaths = []string{filepath.Join(t.TempDir(), "missing"), sshKeyPath}
+	bobPub, keyType, err := bob.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	if keyType != protocol.KeyTypeSSHDerived {
+		t.Errorf("expected an SSH-derived key, got type %d", keyType)
+	}
+
+	// The server converts Bob's registered SSH public key to the same key
+	registered, err := protocol.Ed25519PublicKeyToX25519(sshPub)
+	if err != nil {
+		t.Fatalf("Ed25519PublicKeyToX25519 failed: %v", err)
+	}
+	if protocol.EncodeEncryptionPublicKey(registered) != bobPub {
+		t.Fatal("expected the provided key to match the key derived from the registered SSH key")
+	}
+
+	// Alice wraps the channel key for the key the server derived
+	alice := newTestKeyring(NewMockState(), "chat.example.com:6465")
+	const channelID = 9
+	resp, _, err := alice.HandleKeyExchange(&protocol.DMKeyExchangeMessage{
+		ChannelID: channelID,
+		NewKey:    true,
+		Keys:      []protocol.DMPublicKey{{KeyID: 3, PublicKey: protocol.EncodeEncryptionPublicKey(registered)}},
+	})
+	if err != nil || len(resp.Keys) != 1 {
+		t.Fatalf("HandleKeyExchange failed: %v", err)
+	}
+	ciphertext, err := alice.Encrypt(channelID, "hello ssh bob")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// Bob, on a device with nothing but his SSH key, can unwrap it and read the message
+	if err := bob.StoreWrappedKey(channelID, resp.Keys[0].WrappedKey); err != nil {
+		t.Fatalf("StoreWrappedKey failed: %v", err)
+	}
+	if plaintext := bob.decrypt(channelID, ciphertext); plaintext != "hello ssh bob" {
+		t.Errorf("expected decrypted content, got %q", plaintext)
+	}
+
+	// Keys wrapped for the generated identity (before the SSH key was there) still unwrap
+	bob.mu.Lock()
+	identity, err := bob.loadIdentity()
+	bob.mu.Unlock()
+	if err != nil {
+		t.Fatalf("loadIdentity failed: %v", err)
+	}
+	wrapped, err := protocol.WrapChannelKey(alice.channelKeys[channelID], channelID+1, identity.PublicKey())
+	if err != nil {
+		t.Fatalf("WrapChannelKey failed: %v", err)
+	}
+	if err := bob.StoreWrappedKey(channelID+1, wrapped); err != nil {
+		t.Fatalf("expected a key wrapped for the identity to unwrap, got %v", err)
+	}
+}
+
+func TestKeyringTrustsKeysOnFirstUse(t *testing.T) {
+	alice := newTestKeyring(NewMockState(), "chat.example.com:6465")
+	alicePub, _, err := alice.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	bobPub, _, err := newTestKeyring(NewMockState(), "chat.example.com:6465").PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	serverPub, _, err := newTestKeyring(NewMockState(), "chat.example.com:6465").PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+
+	// The first exchange with Bob trusts his key
+	resp, untrusted, err := alice.HandleKeyExchange(&protocol.DMKeyExchangeMessage{
+		ChannelID: 1,
+		NewKey:    true,
+		Keys: []protocol.DMPublicKey{
+			{KeyID: 1, UserID: 10, Nickname: "alice", PublicKey: alicePub},
+			{KeyID: 2, UserID: 20, Nickname: "bob", PublicKey: bobPub},
+		},
+	})
+	if err != nil || len(resp.Keys) != 2 || len(untrusted) != 0 {
+		t.Fatalf("expected both keys to be wrapped, got %d wrapped, %d untrusted, %v", len(resp.Keys), len(untrusted), err)
+	}
+
+	// A key that shows up for Bob later, in any conversation, isn't wrapped for
+	for _, channelID := range []uint64{1, 2} {
+		resp, untrusted, err = alice.HandleKeyExchange(&protocol.DMKeyExchangeMessage{
+			ChannelID: channelID,
+			NewKey:    true,
+			Keys:      []protocol.DMPublicKey{{KeyID: 3, UserID: 20, Nickname: "bob", PublicKey: serverPub}},
+		})
+		if err != nil || len(resp.Keys) != 0 || len(untrusted) != 1 {
+			t.Fatalf("channel %d: expected the new key to be untrusted, got %d wrapped, %d untrusted, %v", channelID, len(resp.Keys), len(untrusted), err)
+		}
+	}
+	bobKey, _ := protocol.ParseEncryptionPublicKey(bobPub)
+	newKey, _ := protocol.ParseEncryptionPublicKey(serverPub)
+	if got := untrusted[0]; got.UserID != 20 || got.Nickname != "bob" ||
+		got.Fingerprint != protocol.EncryptionKeyFingerprint(newKey) ||
+		len(got.Trusted) != 1 || got.Trusted[0] != protocol.EncryptionKeyFingerprint(bobKey) {
+		t.Errorf("unexpected untrusted key %+v", got)
+	}
+
+	// Once the user trusts it, the channel key is shared with it
+	if err := alice.TrustKey(20, serverPub); err != nil {
+		t.Fatalf("TrustKey failed: %v", err)
+	}
+	resp, untrusted, err = alice.HandleKeyExchange(&protocol.DMKeyExchangeMessage{
+		ChannelID: 1,
+		Keys:      []protocol.DMPublicKey{{KeyID: 3, UserID: 20, Nickname: "bob", PublicKey: serverPub}},
+	})
+	if err != nil || len(resp.Keys) != 1 || len(untrusted) != 0 {
+		t.Fatalf("expected the trusted key to be wrapped, got %d wrapped, %d untrusted, %v", len(resp.Keys), len(untrusted), err)
+	}
+
+	// Our own key was never pinned, so it can't be mistaken for a participant's
+	if pins, _ := alice.state.GetTrustedKeys("chat.example.com:6465", 10); len(pins) != 0 {
+		t.Errorf("expected no pins for our own key, got %v", pins)
+	}
+
+	// Trust is per server
+	alice.SetServer("other.example.com:6465")
+	resp, untrusted, err = alice.HandleKeyExchange(&protocol.DMKeyExchangeMessage{
+		ChannelID: 1,
+		NewKey:    true,
+		Keys:      []protocol.DMPublicKey{{KeyID: 3, UserID: 20, Nickname: "bob", PublicKey: alicePub}},
+	})
+	if err != nil || len(resp.Keys) != 1 || len(untrusted) != 0 {
+		t.Fatalf("expected a first exchange on another server, got %d wrapped, %d untrusted, %v", len(resp.Keys), len(untrusted), err)
+	}
+}
+
+func TestStateTrustedKeys(t *testing.T) {
+	state, err := OpenState(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open state: %v", err)
+	}
+	defer state.Close()
+
+	if pins, err := state.GetTrustedKeys("example.com:6465", 7); err != nil || len(pins) != 0 {
+		t.Fatalf("Expected no trusted keys, got %v (err %v)", pins, err)
+	}
+	for _, fingerprint := range []string{"SHA256:abc", "SHA256:def", "SHA256:abc"} {
+		if err := state.SaveTrustedKey("example.com:6465", 7, fingerprint); err != nil {
+			t.Fatalf("SaveTrustedKey failed: %v", err)
+		}
+	}
+	if pins, _ := state.GetTrustedKeys("example.com:6465", 7); len(pins) != 2 {
+		t.Errorf("Expected two trusted keys, got %v", pins)
+	}
+	if pins, _ := state.GetTrustedKeys("other.example.com:6465", 7); len(pins) != 0 {
+		t.Errorf("Expected trusted keys to be per server, got %v", pins)
+	}
+}
+
+func TestKeyringUndecryptableContent(t *testing.T) {
+	keyring := newTestKeyring(NewMockState(), "chat.example.com:6465")
+
+	list := &protocol.MessageListMessage{
+		ChannelID: 7,
+		Messages: []protocol.Message{
+			{ID: 1, ChannelID: 7, Content: "bm90IG91ciBrZXk="},
+			{ID: 2, ChannelID: 7, Content: "[deleted by ~alice]"},
+		},
+	}
+	payload, err := list.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	frame := &protocol.Frame{Type: protocol.TypeMessageList, Flags: protocol.FlagEncrypted, Payload: payload}
+	if err := keyring.DecryptFrame(frame); err != nil {
+		t.Fatalf("DecryptFrame failed: %v", err)
+	}
+
+	decoded := &protocol.MessageListMessage{}
+	if err := decoded.Decode(frame.Payload); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Messages[0].Content != undecryptableContent {
+		t.Errorf("expected placeholder, got %q", decoded.Messages[0].Content)
+	}
+	if decoded.Messages[1].Content != "[deleted by ~alice]" {
+		t.Errorf("expected deletion marker to be kept, got %q", decoded.Messages[1].Content)
+	}
+}
@@ -0,0 +1,248 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind classifies a piece of highlighted code
+type TokenKind int
+
+const (
+	TokenPlain TokenKind = iota
+	TokenKeyword
+	TokenString
+	TokenComment
+	TokenNumber
+)
+
+// Token is a piece of highlighted code
+type Token struct {
+	Text string
+	Kind TokenKind
+}
+
+// language describes the lexical syntax of a language well enough to highlight it
+type language struct {
+	keywords     []string
+	lineComments []string
+	blockComment [2]string // Opening and closing ("" if none)
+	quotes       string    // Characters that delimit strings
+}
+
+var cLike = language{
+	keywords: []string{
+		"auto", "break", "case", "catch", "char", "class", "const", "continue", "default", "delete",
+		"do", "double", "else", "enum", "extends", "extern", "false", "final", "float", "for", "goto",
+		"if", "implements", "import", "include", "int", "interface", "long", "namespace", "new", "null",
+		"nullptr", "package", "private", "protected", "public", "return", "short", "signed", "sizeof",
+		"static", "struct", "super", "switch", "template", "this", "throw", "throws", "true", "try",
+		"typedef", "typename", "union", "unsigned", "using", "virtual", "void", "volatile", "while",
+	},
+	lineComments: []string{"//"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       `"'`,
+}
+
+var languages = map[string]language{
+	"go": {
+		keywords: []string{
+			"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+			"false", "for", "func", "go", "goto", "if", "import", "interface", "iota", "map", "nil",
+			"package", "range", "return", "select", "struct", "switch", "true", "type", "var",
+		},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"javascript": {
+		keywords: []string{
+			"async", "await", "break", "case", "catch", "class", "const", "continue", "default", "delete",
+			"do", "else", "export", "extends", "false", "finally", "for", "from", "function", "if",
+			"import", "in", "instanceof", "interface", "let", "new", "null", "of", "return", "static",
+			"super", "switch", "this", "throw", "true", "try", "type", "typeof", "undefined", "var",
+			"void", "while", "yield",
+		},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"python": {
+		keywords: []string{
+			"False", "None", "True", "and", "as", "assert", "async", "await", "break", "class",
+			"continue", "def", "del", "elif", "else", "except", "finally", "for", "from", "global",
+			"if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return",
+			"try", "while", "with", "yield",
+		},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"rust": {
+		keywords: []string{
+			"as", "async", "await", "break", "const", "continue", "crate", "dyn", "else", "enum",
+			"extern", "false", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move",
+			"mut", "pub", "ref", "return", "self", "Self", "static", "struct", "super", "trait", "true",
+			"type", "unsafe", "use", "where", "while",
+		},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	},
+	"c": cLike,
+	"shell": {
+		keywords: []string{
+			"case", "do", "done", "elif", "else", "esac", "export", "fi", "for", "function", "if", "in",
+			"local", "return", "then", "until", "while",
+		},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"sql": {
+		keywords: []string{
+			"and", "as", "asc", "by", "create", "delete", "desc", "distinct", "drop", "from", "group",
+			"having", "in", "index", "insert", "into", "is", "join", "left", "like", "limit", "not",
+			"null", "on", "or", "order", "primary", "key", "select", "set", "table", "union", "update",
+			"values", "where",
+		},
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+	},
+	"json": {
+		keywords: []string{"true", "false", "null"},
+		quotes:   `"`,
+	},
+	"yaml": {
+		keywords:     []string{"true", "false", "null", "yes", "no"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"toml": {
+		keywords:     []string{"true", "false"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+}
+
+// languageAliases maps fence info strings to languages
+var languageAliases = map[string]string{
+	"golang": "go",
+	"js":     "javascript", "jsx": "javascript", "ts": "javascript", "tsx": "javascript", "typescript": "javascript",
+	"py": "python", "python3": "python",
+	"rs":  "rust",
+	"cpp": "c", "c++": "c", "h": "c", "hpp": "c", "cs": "c", "csharp": "c", "java": "c", "kotlin": "c", "swift": "c",
+	"sh": "shell", "bash": "shell", "zsh": "shell", "console": "shell",
+	"postgres": "sql", "sqlite": "sql", "mysql": "sql",
+	"yml": "yaml",
+}
+
+// Highlight splits code into tokens for syntax highlighting. Code in languages it
+// doesn't know is returned as one plain token.
+func Highlight(code, lang string) []Token {
+	if alias, ok := languageAliases[lang]; ok {
+		lang = alias
+	}
+	syntax, ok := languages[lang]
+	if !ok {
+		return []Token{{Text: code, Kind: TokenPlain}}
+	}
+	caseInsensitive := lang == "sql"
+	keywords := make(map[string]bool, len(syntax.keywords))
+	for _, kw := range syntax.keywords {
+		keywords[kw] = true
+	}
+
+	var tokens []Token
+	emit := func(text string, kind TokenKind) {
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{Text: text, Kind: kind})
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		if comment := matchComment(rest, syntax); comment > 0 {
+			emit(rest[:comment], TokenComment)
+			i += comment
+			continue
+		}
+
+		c := code[i]
+		if strings.IndexByte(syntax.quotes, c) >= 0 {
+			end := 1
+			for end < len(rest) && rest[end] != c {
+				if rest[end] == '\n' && c != '`' {
+					break
+				}
+				if rest[end] == '\\' && c != '`' && end+1 < len(rest) {
+					end++
+				}
+				end++
+			}
+			if end < len(rest) && rest[end] == c {
+				end++ // Closing quote
+			}
+			emit(rest[:end], TokenString)
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		if unicode.IsLetter(r) || r == '_' {
+			end := size
+			for end < len(rest) {
+				r, size := utf8.DecodeRuneInString(rest[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				end += size
+			}
+			word := rest[:end]
+			if keywords[word] || caseInsensitive && keywords[strings.ToLower(word)] {
+				emit(word, TokenKeyword)
+			} else {
+				emit(word, TokenPlain)
+			}
+			i += end
+			continue
+		}
+
+		if unicode.IsDigit(r) {
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			emit(rest[:end], TokenNumber)
+			i += end
+			continue
+		}
+
+		emit(rest[:size], TokenPlain)
+		i += size
+	}
+
+	return tokens
+}
+
+// matchComment returns the length of the comment at the start of s (0 if none)
+func matchComment(s string, syntax language) int {
+	for _, prefix := range syntax.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	if open, close := syntax.blockComment[0], syntax.blockComment[1]; open != "" && strings.HasPrefix(s, open) {
+		if end := strings.Index(s[len(open):], close); end >= 0 {
+			return len(open) + end + len(close)
+		}
+		return len(s)
+	}
+	return 0
+}
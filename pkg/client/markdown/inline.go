@@ -0,0 +1,248 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	autolinkPattern = regexp.MustCompile(`^<((?:https?://|mailto:)[^>\s]+)>`)
+	linkPattern     = regexp.MustCompile(`^\[((?:[^\]\\]|\\.)+)\]\(\s*(\S+?)\s*\)`)
+	bareURLPattern  = regexp.MustCompile(`^https?://[^\s<]+`)
+)
+
+// ParseInline parses emphasis, code spans and links in a paragraph
+func ParseInline(text string) []Span {
+	return mergeSpans(parseInline(text, 0))
+}
+
+func parseInline(text string, style Style) []Span {
+	var spans []Span
+	var buf strings.Builder
+
+	flush := func() {
+		if buf.Len() > 0 {
+			spans = append(spans, Span{Text: buf.String(), Style: style})
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			buf.WriteByte(text[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			n := runLength(text, i)
+			if end := findBacktickRun(text, i+n, n); end >= 0 {
+				flush()
+				spans = append(spans, Span{Text: codeSpanText(text[i+n : end]), Style: style | Code})
+				i = end + n
+				continue
+			}
+			buf.WriteString(text[i : i+n])
+			i += n
+			continue
+
+		case c == '[' && style&Link == 0:
+			if m := linkPattern.FindStringSubmatch(rest); m != nil {
+				flush()
+				for _, span := range parseInline(m[1], style|Link) {
+					span.URL = m[2]
+					spans = append(spans, span)
+				}
+				i += len(m[0])
+				continue
+			}
+
+		case c == '<' && style&Link == 0:
+			if m := autolinkPattern.FindStringSubmatch(rest); m != nil {
+				flush()
+				spans = append(spans, Span{Text: m[1], Style: style | Link, URL: m[1]})
+				i += len(m[0])
+				continue
+			}
+
+		case c == 'h' && style&Link == 0 && (i == 0 || !isWordByte(text[i-1])):
+			if url := bareURLPattern.FindString(rest); url != "" {
+				url = trimURL(url)
+				flush()
+				spans = append(spans, Span{Text: url, Style: style | Link, URL: url})
+				i += len(url)
+				continue
+			}
+
+		case c == '*' || c == '_':
+			n := runLength(text, i)
+			if n <= 3 && canOpen(text, i, n) {
+				if end := findClosingRun(text, i+n, c, n); end > i+n {
+					flush()
+					inner := style
+					switch n {
+					case 1:
+						inner |= Italic
+					case 2:
+						inner |= Bold
+					case 3:
+						inner |= Bold | Italic
+					}
+					spans = append(spans, parseInline(text[i+n:end], inner)...)
+					i = end + n
+					continue
+				}
+			}
+			buf.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+
+		buf.WriteByte(c)
+		i++
+	}
+	flush()
+
+	return spans
+}
+
+// runLength returns the number of repeats of the byte at i
+func runLength(text string, i int) int {
+	n := 1
+	for i+n < len(text) && text[i+n] == text[i] {
+		n++
+	}
+	return n
+}
+
+// findBacktickRun finds a run of exactly n backticks at or after start
+func findBacktickRun(text string, start, n int) int {
+	for j := start; j < len(text); {
+		if text[j] != '`' {
+			j++
+			continue
+		}
+		run := runLength(text, j)
+		if run == n {
+			return j
+		}
+		j += run
+	}
+	return -1
+}
+
+// codeSpanText normalizes the contents of a code span: newlines become spaces, and
+// one space is stripped from each side if both are there
+func codeSpanText(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) > 2 && s[0] == ' ' && s[len(s)-1] == ' ' && strings.TrimSpace(s) != "" {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+// canOpen checks if a delimiter run can start emphasis: it must be followed by
+// text, and underscores can't be inside a word (snake_case stays as is)
+func canOpen(text string, i, n int) bool {
+	if i+n >= len(text) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(text[i+n:])
+	if unicode.IsSpace(next) {
+		return false
+	}
+	if text[i] == '_' && i > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		if unicode.IsLetter(prev) || unicode.IsDigit(prev) {
+			return false
+		}
+	}
+	return true
+}
+
+// findClosingRun finds a run of at least n delim bytes at or after start that can
+// close emphasis
+func findClosingRun(text string, start int, delim byte, n int) int {
+	for j := start; j < len(text); {
+		switch text[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			// Don't close inside a code span
+			run := runLength(text, j)
+			if end := findBacktickRun(text, j+run, run); end >= 0 {
+				j = end + run
+			} else {
+				j += run
+			}
+			continue
+		case delim:
+		default:
+			j++
+			continue
+		}
+
+		// A longer run closes with its last n delimiters, so "**a *b***" nests
+		run := runLength(text, j)
+		if run >= n && j > start {
+			prev, _ := utf8.DecodeLastRuneInString(text[:j])
+			closes := !unicode.IsSpace(prev)
+			if delim == '_' && j+run < len(text) {
+				next, _ := utf8.DecodeRuneInString(text[j+run:])
+				closes = closes && !unicode.IsLetter(next) && !unicode.IsDigit(next)
+			}
+			if closes {
+				return j + run - n
+			}
+		}
+		j += run
+	}
+	return -1
+}
+
+// trimURL removes trailing punctuation that's more likely part of the sentence,
+// and closing parentheses that aren't balanced inside the URL
+func trimURL(url string) string {
+	for len(url) > 0 {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'\"*_", last) >= 0:
+			url = url[:len(url)-1]
+		case last == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+			url = url[:len(url)-1]
+		default:
+			return url
+		}
+	}
+	return url
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("`*_<>[]()#+-.!|~^$=", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
+
+// mergeSpans joins adjacent spans with the same style and link
+func mergeSpans(spans []Span) []Span {
+	var merged []Span
+	for _, span := range spans {
+		if span.Text == "" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Style == span.Style && merged[n-1].URL == span.URL {
+			merged[n-1].Text += span.Text
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
@@ -0,0 +1,183 @@
+// ABOUTME: CommonMark subset parser for message bodies, shared by the terminal and GUI clients
+// ABOUTME: Supports emphasis, inline code, fenced code blocks, lists, block quotes and links
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BlockKind identifies the type of a block
+type BlockKind int
+
+const (
+	BlockParagraph BlockKind = iota // Spans
+	BlockCode                       // Lang, Code
+	BlockQuote                      // Children
+	BlockList                       // Items
+)
+
+// Block is a top-level element of a message
+type Block struct {
+	Kind     BlockKind
+	Spans    []Span     // BlockParagraph
+	Lang     string     // BlockCode: info string ("" if none)
+	Code     string     // BlockCode: contents without the fences
+	Children []Block    // BlockQuote
+	Items    []ListItem // BlockList
+}
+
+// ListItem is one entry of a list
+type ListItem struct {
+	Marker string // "•" for bullets, "1." etc. for ordered lists
+	Level  int    // Nesting level (0 = top)
+	Spans  []Span
+}
+
+// Style is a set of inline formatting flags
+type Style uint8
+
+const (
+	Bold Style = 1 << iota
+	Italic
+	Code
+	Link
+)
+
+// Span is a run of text with one style. Text can contain newlines (hard line breaks).
+type Span struct {
+	Text  string
+	Style Style
+	URL   string // Link target (Link spans only)
+}
+
+var (
+	fencePattern    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+	quotePattern    = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemPattern = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])(?: +|$)(.*)$`)
+)
+
+// Parse parses message text into blocks
+func Parse(text string) []Block {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	return parseBlocks(strings.Split(text, "\n"))
+}
+
+// parseBlocks parses lines into blocks
+func parseBlocks(lines []string) []Block {
+	var blocks []Block
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, Block{Kind: BlockParagraph, Spans: ParseInline(strings.Join(paragraph, "\n"))})
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+
+		case fencePattern.MatchString(line):
+			flush()
+			m := fencePattern.FindStringSubmatch(line)
+			fence := m[1]
+			var code []string
+			for i++; i < len(lines); i++ {
+				closing := strings.TrimSpace(lines[i])
+				if strings.HasPrefix(closing, fence[:3]) && strings.Trim(closing, fence[:1]) == "" && len(closing) >= len(fence) {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, Block{Kind: BlockCode, Lang: strings.ToLower(m[2]), Code: strings.Join(code, "\n")})
+
+		case quotePattern.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.ReplaceAllString(lines[i], ""))
+			}
+			i--
+			blocks = append(blocks, Block{Kind: BlockQuote, Children: parseBlocks(quoted)})
+
+		case isListItem(line) && (len(paragraph) == 0 || startsList(line)):
+			flush()
+			var items []ListItem
+			numbers := make(map[int]int) // level -> next number of an ordered list
+			for ; i < len(lines); i++ {
+				if m := listItemPattern.FindStringSubmatch(lines[i]); m != nil && isListItem(lines[i]) {
+					level := len(m[1]) / 2
+					marker := "•"
+					if n, err := strconv.Atoi(strings.TrimRight(m[2], ".)")); err == nil {
+						if next, ok := numbers[level]; ok {
+							n = next
+						}
+						numbers[level] = n + 1
+						marker = strconv.Itoa(n) + "."
+					}
+					for l := range numbers {
+						if l > level {
+							delete(numbers, l)
+						}
+					}
+					items = append(items, ListItem{Marker: marker, Level: level, Spans: ParseInline(m[3])})
+					continue
+				}
+				// Indented lines continue the previous item
+				if len(items) > 0 && strings.HasPrefix(lines[i], " ") && strings.TrimSpace(lines[i]) != "" {
+					last := &items[len(items)-1]
+					last.Spans = append(last.Spans, Span{Text: "\n"})
+					last.Spans = append(last.Spans, ParseInline(strings.TrimSpace(lines[i]))...)
+					continue
+				}
+				break
+			}
+			i--
+			blocks = append(blocks, Block{Kind: BlockList, Items: items})
+
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// isListItem checks if a line is a list item. A lone "-" or "*" run (a thematic
+// break like "---") isn't.
+func isListItem(line string) bool {
+	m := listItemPattern.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	return !(len(trimmed) >= 3 && strings.Trim(trimmed, "-* ") == "")
+}
+
+// startsList checks if a list item can interrupt a paragraph: like CommonMark,
+// only bullets and lists starting at 1 can, so "in 2020. we..." stays text
+func startsList(line string) bool {
+	m := listItemPattern.FindStringSubmatch(line)
+	if m == nil || strings.TrimSpace(m[3]) == "" {
+		return false
+	}
+	n, err := strconv.Atoi(strings.TrimRight(m[2], ".)"))
+	return err != nil || n == 1
+}
+
+// PlainText returns the text of spans without formatting
+func PlainText(spans []Span) string {
+	var b strings.Builder
+	for _, span := range spans {
+		b.WriteString(span.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Span
+	}{
+		{"plain text", []Span{{Text: "plain text"}}},
+		{"some **bold** text", []Span{{Text: "some "}, {Text: "bold", Style: Bold}, {Text: " text"}}},
+		{"*it* and _it_", []Span{{Text: "it", Style: Italic}, {Text: " and "}, {Text: "it", Style: Italic}}},
+		{"***both***", []Span{{Text: "both", Style: Bold | Italic}}},
+		{"**bold *and italic***", []Span{{Text: "bold ", Style: Bold}, {Text: "and italic", Style: Bold | Italic}}},
+		{"run `go test ./...` now", []Span{{Text: "run "}, {Text: "go test ./...", Style: Code}, {Text: " now"}}},
+		{"``a ` b``", []Span{{Text: "a ` b", Style: Code}}},
+		{"`**not bold**`", []Span{{Text: "**not bold**", Style: Code}}},
+		{"see [the docs](https://example.com/docs)", []Span{
+			{Text: "see "}, {Text: "the docs", Style: Link, URL: "https://example.com/docs"},
+		}},
+		{"<https://example.com>", []Span{{Text: "https://example.com", Style: Link, URL: "https://example.com"}}},
+		{"go to https://example.com/a_(b).", []Span{
+			{Text: "go to "}, {Text: "https://example.com/a_(b)", Style: Link, URL: "https://example.com/a_(b)"}, {Text: "."},
+		}},
+		{"snake_case_name stays", []Span{{Text: "snake_case_name stays"}}},
+		{"2 * 3 * 4", []Span{{Text: "2 * 3 * 4"}}},
+		{"\\*not italic\\*", []Span{{Text: "*not italic*"}}},
+		{"**unclosed", []Span{{Text: "**unclosed"}}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseInline(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseInline(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := "Intro line\nsecond line\n\n" +
+		"```go\nfunc main() {}\n```\n" +
+		"> quoted **text**\n> more\n\n" +
+		"- one\n- two\n  - nested\n  continued\n" +
+		"\n3. three\n7. four\n\n" +
+		"---"
+
+	want := []Block{
+		{Kind: BlockParagraph, Spans: []Span{{Text: "Intro line\nsecond line"}}},
+		{Kind: BlockCode, Lang: "go", Code: "func main() {}"},
+		{Kind: BlockQuote, Children: []Block{
+			{Kind: BlockParagraph, Spans: []Span{{Text: "quoted "}, {Text: "text", Style: Bold}, {Text: "\nmore"}}},
+		}},
+		{Kind: BlockList, Items: []ListItem{
+			{Marker: "•", Level: 0, Spans: []Span{{Text: "one"}}},
+			{Marker: "•", Level: 0, Spans: []Span{{Text: "two"}}},
+			{Marker: "•", Level: 1, Spans: []Span{{Text: "nested"}, {Text: "\n"}, {Text: "continued"}}},
+		}},
+		{Kind: BlockList, Items: []ListItem{
+			{Marker: "3.", Level: 0, Spans: []Span{{Text: "three"}}},
+			{Marker: "4.", Level: 0, Spans: []Span{{Text: "four"}}},
+		}},
+		{Kind: BlockParagraph, Spans: []Span{{Text: "---"}}},
+	}
+
+	if got := Parse(input); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Unclosed fences run to the end of the message
+	got := Parse("~~~\nno closing fence\n\nstill code")
+	if len(got) != 1 || got[0].Kind != BlockCode || got[0].Code != "no closing fence\n\nstill code" {
+		t.Errorf("unexpected blocks for unclosed fence: %+v", got)
+	}
+
+	// Numbers inside a paragraph don't start a list
+	got = Parse("It was in\n2020. That year")
+	if len(got) != 1 || got[0].Kind != BlockParagraph {
+		t.Errorf("expected a single paragraph, got %+v", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("x := \"a\\\"b\" // note\nreturn 42", "golang")
+	want := []Token{
+		{Text: "x := ", Kind: TokenPlain},
+		{Text: "\"a\\\"b\"", Kind: TokenString},
+		{Text: " ", Kind: TokenPlain},
+		{Text: "// note", Kind: TokenComment},
+		{Text: "\n", Kind: TokenPlain},
+		{Text: "return", Kind: TokenKeyword},
+		{Text: " ", Kind: TokenPlain},
+		{Text: "42", Kind: TokenNumber},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Highlight() = %+v, want %+v", got, want)
+	}
+
+	if got := Highlight("SELECT 1", "sql"); got[0] != (Token{Text: "SELECT", Kind: TokenKeyword}) {
+		t.Errorf("expected case-insensitive SQL keywords, got %+v", got)
+	}
+
+	code := "whatever 'this' is"
+	if got := Highlight(code, "brainfuck"); !reflect.DeepEqual(got, []Token{{Text: code, Kind: TokenPlain}}) {
+		t.Errorf("expected unknown languages to be plain, got %+v", got)
+	}
+}
@@ -0,0 +1,12 @@
+-- Migration 003: Store end-to-end encryption keys for direct messages
+-- Channel keys are unwrapped from what the server sends and cached here,
+-- scoped per server because channel IDs are only unique within a server
+
+CREATE TABLE IF NOT EXISTS ChannelKey (
+	server_address TEXT NOT NULL,
+	channel_id INTEGER NOT NULL,
+	channel_key BLOB NOT NULL,   -- AES-256 key (32 bytes)
+	added_at INTEGER NOT NULL,
+
+	PRIMARY KEY (server_address, channel_id)
+);
@@ -0,0 +1,9 @@
+-- Migration 004: Pin TLS certificates of scs:// servers
+-- Self-signed server certificates are trusted on first use; later connections
+-- must present the same public key (SHA-256 of the SubjectPublicKeyInfo)
+
+CREATE TABLE IF NOT EXISTS CertificatePin (
+	server_address TEXT PRIMARY KEY,  -- host:port
+	fingerprint TEXT NOT NULL,        -- "SHA256:" + base64 digest
+	pinned_at INTEGER NOT NULL
+);
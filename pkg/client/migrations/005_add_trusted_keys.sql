@@ -0,0 +1,12 @@
+-- Migration 005: Trust DM participants' encryption keys
+-- Keys are trusted on first use; channel keys are only wrapped for trusted keys,
+-- so a key the server adds later for someone we know needs the user's approval
+
+CREATE TABLE IF NOT EXISTS TrustedKey (
+	server_address TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	fingerprint TEXT NOT NULL,   -- "SHA256:" + base64 digest of the X25519 public key
+	trusted_at INTEGER NOT NULL,
+
+	PRIMARY KEY (server_address, user_id, fingerprint)
+);
@@ -1,6 +1,8 @@
 package client
 
 import (
+	"fmt"
+	"strconv"
 	"sync"
 )
 
@@ -9,9 +11,12 @@ type MockState struct {
 	mu sync.RWMutex
 
 	// In-memory storage
-	config    map[string]string
-	readState map[uint64]ReadStateData
-	dir       string
+	config      map[string]string
+	readState   map[uint64]ReadStateData
+	channelKeys map[string][]byte   // "server/channelID" -> key
+	certPins    map[string]string   // server address -> fingerprint
+	trustedKeys map[string][]string // "server/userID" -> fingerprints
+	dir         string
 
 	// Error injection
 	getConfigErr         error
@@ -30,9 +35,12 @@ type ReadStateData struct {
 // NewMockState creates a new mock state
 func NewMockState() *MockState {
 	return &MockState{
-		config:    make(map[string]string),
-		readState: make(map[uint64]ReadStateData),
-		dir:       "/tmp/mock-state",
+		config:      make(map[string]string),
+		readState:   make(map[uint64]ReadStateData),
+		channelKeys: make(map[string][]byte),
+		certPins:    make(map[string]string),
+		trustedKeys: make(map[string][]string),
+		dir:         "/tmp/mock-state",
 	}
 }
 
@@ -222,6 +230,57 @@ func (s *MockState) SaveSuccessfulConnection(serverAddress string, method string
 	return nil // Mock: no-op
 }
 
+// GetChannelKey retrieves a stored DM channel key (mock)
+func (s *MockState) GetChannelKey(serverAddress string, channelID uint64) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.channelKeys[fmt.Sprintf("%s/%d", serverAddress, channelID)], nil
+}
+
+// SaveChannelKey stores a DM channel key (mock)
+func (s *MockState) SaveChannelKey(serverAddress string, channelID uint64, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channelKeys[fmt.Sprintf("%s/%d", serverAddress, channelID)] = key
+	return nil
+}
+
+// GetTrustedKeys retrieves a user's trusted key fingerprints (mock)
+func (s *MockState) GetTrustedKeys(serverAddress string, userID uint64) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.trustedKeys[fmt.Sprintf("%s/%d", serverAddress, userID)]...), nil
+}
+
+// SaveTrustedKey trusts a key fingerprint of a user (mock)
+func (s *MockState) SaveTrustedKey(serverAddress string, userID uint64, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s/%d", serverAddress, userID)
+	for _, existing := range s.trustedKeys[key] {
+		if existing == fingerprint {
+			return nil
+		}
+	}
+	s.trustedKeys[key] = append(s.trustedKeys[key], fingerprint)
+	return nil
+}
+
+// GetCertificatePin retrieves a pinned TLS certificate fingerprint (mock)
+func (s *MockState) GetCertificatePin(serverAddress string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.certPins[serverAddress], nil
+}
+
+// SaveCertificatePin pins a TLS certificate fingerprint (mock)
+func (s *MockState) SaveCertificatePin(serverAddress, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.certPins[serverAddress] = fingerprint
+	return nil
+}
+
 // GetFirstPostWarningDismissed checks if the first post warning has been dismissed (mock)
 func (s *MockState) GetFirstPostWarningDismissed() bool {
 	val, _ := s.GetConfig("first_post_warning_dismissed")
@@ -233,5 +292,16 @@ func (s *MockState) SetFirstPostWarningDismissed() error {
 	return s.SetConfig("first_post_warning_dismissed", "true")
 }
 
+// GetShowRawMarkdown checks if messages should be shown as markdown source (mock)
+func (s *MockState) GetShowRawMarkdown() bool {
+	val, _ := s.GetConfig("show_raw_markdown")
+	return val == "true"
+}
+
+// SetShowRawMarkdown saves whether messages are shown as markdown source (mock)
+func (s *MockState) SetShowRawMarkdown(raw bool) error {
+	return s.SetConfig("show_raw_markdown", strconv.FormatBool(raw))
+}
+
 // Verify that MockState implements StateInterface
 var _ StateInterface = (*MockState)(nil)
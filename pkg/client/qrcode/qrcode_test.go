@@ -0,0 +1,234 @@
+package qrcode
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the thonky.com QR code tutorial
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionInfo(t *testing.T) {
+	// ISO/IEC 18004 annex C and D
+	tests := []struct {
+		level Level
+		mask  int
+		want  int
+	}{
+		{Low, 0, 0b111011111000100},
+		{Low, 7, 0b110100101110110},
+		{Medium, 0, 0b101010000010010},
+		{Medium, 5, 0b100000011001110},
+	}
+	for _, tt := range tests {
+		if got := formatInfo(tt.level, tt.mask); got != tt.want {
+			t.Errorf("formatInfo(%d, %d) = %015b, want %015b", tt.level, tt.mask, got, tt.want)
+		}
+	}
+
+	if got := versionBits(7); got != 0b000111110010010100 {
+		t.Errorf("versionBits(7) = %018b", got)
+	}
+	if got := versionBits(40); got != 0b101000110001101001 {
+		t.Errorf("versionBits(40) = %018b", got)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		version int
+		level   Level
+		want    int
+	}{
+		{1, Low, 19},
+		{1, Medium, 16},
+		{5, Low, 108},
+		{7, Medium, 124},
+		{40, Low, 2956},
+		{40, Medium, 2334},
+	}
+	for _, tt := range tests {
+		if got := dataCodewords(tt.version, tt.level); got != tt.want {
+			t.Errorf("dataCodewords(%d, %d) = %d, want %d", tt.version, tt.level, got, tt.want)
+		}
+	}
+
+	if got := alignmentPositions(7); !reflect.DeepEqual(got, []int{6, 22, 38}) {
+		t.Errorf("alignmentPositions(7) = %v", got)
+	}
+	if got := alignmentPositions(32); !reflect.DeepEqual(got, []int{6, 34, 60, 86, 112, 138}) {
+		t.Errorf("alignmentPositions(32) = %v", got)
+	}
+}
+
+// readBack extracts the data a code carries, undoing what Encode did
+func readBack(t *testing.T, c *Code, level Level) []byte {
+	t.Helper()
+
+	// Find the mask from the format bits next to the top left finder
+	bits := 0
+	for i := 0; i <= 5; i++ {
+		if c.Dark(8, i) {
+			bits |= 1 << i
+		}
+	}
+	for i, p := range [][2]int{{8, 7}, {8, 8}, {7, 8}} {
+		if c.Dark(p[0], p[1]) {
+			bits |= 1 << (6 + i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if c.Dark(14-i, 8) {
+			bits |= 1 << i
+		}
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatInfo(level, m) == bits {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("Format bits %015b match no mask", bits)
+	}
+
+	// Read the codewords in drawing order
+	q := newQR(c.Version)
+	q.drawFunctionPatterns()
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			q.modules[y][x] = c.Dark(x, y) != (!q.isFunction[y][x] && masked(mask, x, y))
+		}
+	}
+	var codewords []byte
+	var b byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if q.isFunction[y][x] {
+					continue
+				}
+				b = b<<1 | map[bool]byte{false: 0, true: 1}[q.modules[y][x]]
+				if n++; n%8 == 0 {
+					codewords = append(codewords, b)
+				}
+			}
+		}
+	}
+
+	// De-interleave the data codewords and check each block's error correction
+	numBlocks := eccBlocks[level][c.Version]
+	eccLen := eccPerBlock[level][c.Version]
+	raw := rawModules(c.Version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			if i < shortLen || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for j, block := range blocks {
+		var ecc []byte
+		for i := 0; i < eccLen; i++ {
+			ecc = append(ecc, codewords[k+i*numBlocks+j])
+		}
+		if !bytes.Equal(rsRemainder(block, rsDivisor(eccLen)), ecc) {
+			t.Fatalf("Block %d has the wrong error correction codewords", j)
+		}
+		data = append(data, block...)
+	}
+
+	// Byte mode header, then the data
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("Expected byte mode, got %x", data[0]>>4)
+	}
+	var length, offset int
+	if countBits(c.Version) == 8 {
+		length = int(data[0]&0x0f)<<4 | int(data[1]>>4)
+		offset = 1
+	} else {
+		length = int(data[0]&0x0f)<<12 | int(data[1])<<4 | int(data[2]>>4)
+		offset = 2
+	}
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = data[offset+i]<<4 | data[offset+i+1]>>4
+	}
+	return result
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"hello", Medium, 1},
+		{"otpauth://totp/SuperChat:alice?algorithm=SHA1&digits=6&issuer=SuperChat&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP", Low, 6},
+		{strings.Repeat("superchat ", 30), Medium, 13},
+	}
+	for _, tt := range tests {
+		c, err := Encode([]byte(tt.data), tt.level)
+		if err != nil {
+			t.Fatalf("Encode(%q) failed: %v", tt.data, err)
+		}
+		if c.Version != tt.version || c.Size != tt.version*4+17 {
+			t.Errorf("Encode(%q) made version %d (size %d), want %d", tt.data, c.Version, c.Size, tt.version)
+		}
+		if got := readBack(t, c, tt.level); string(got) != tt.data {
+			t.Errorf("Read back %q, want %q", got, tt.data)
+		}
+	}
+
+	if _, err := Encode(make([]byte, 3000), Low); err != ErrTooLong {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestString(t *testing.T) {
+	c, err := Encode([]byte("hello"), Medium)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	lines := strings.Split(c.String(), "\n")
+	if len(lines) != (c.Size+8+1)/2 {
+		t.Fatalf("Expected %d lines, got %d", (c.Size+8+1)/2, len(lines))
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n != c.Size+8 {
+			t.Fatalf("Expected lines of %d columns, got %d", c.Size+8, n)
+		}
+	}
+	// The top left finder's left edge is dark on rows 0-6, the separator below it light
+	if strings.TrimSpace(lines[1]) != "" {
+		t.Errorf("Expected the quiet zone to be blank, got %q", lines[1])
+	}
+	if got := []rune(lines[2])[4]; got != '█' {
+		t.Errorf("Expected rows 0-1 of the finder to be a full block, got %q", got)
+	}
+	if got := []rune(lines[5])[4]; got != '▀' {
+		t.Errorf("Expected rows 6-7 of the finder to be an upper half block, got %q", got)
+	}
+}
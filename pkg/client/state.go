@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -169,6 +170,96 @@ func (s *State) SaveSuccessfulConnection(serverAddress string, method string) er
 	return err
 }
 
+// GetChannelKey retrieves a stored DM channel key (nil if none is stored)
+func (s *State) GetChannelKey(serverAddress string, channelID uint64) ([]byte, error) {
+	var key []byte
+	err := s.db.QueryRow(`
+		SELECT channel_key
+		FROM ChannelKey
+		WHERE server_address = ? AND channel_id = ?
+	`, serverAddress, channelID).Scan(&key)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// SaveChannelKey stores a DM channel key
+func (s *State) SaveChannelKey(serverAddress string, channelID uint64, key []byte) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO ChannelKey (server_address, channel_id, channel_key, added_at)
+		VALUES (?, ?, ?, ?)
+	`, serverAddress, channelID, key, time.Now().Unix())
+	return err
+}
+
+// GetTrustedKeys retrieves the fingerprints of a user's trusted DM encryption keys on a server
+func (s *State) GetTrustedKeys(serverAddress string, userID uint64) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT fingerprint
+		FROM TrustedKey
+		WHERE server_address = ? AND user_id = ?
+	`, serverAddress, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fingerprints []string
+	for rows.Next() {
+		var fingerprint string
+		if err := rows.Scan(&fingerprint); err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, rows.Err()
+}
+
+// SaveTrustedKey trusts a DM encryption key of a user on a server
+func (s *State) SaveTrustedKey(serverAddress string, userID uint64, fingerprint string) error {
+	_, err := s.db.Exec(`
+		INSERT OR IGNORE INTO TrustedKey (server_address, user_id, fingerprint, trusted_at)
+		VALUES (?, ?, ?, ?)
+	`, serverAddress, userID, fingerprint, time.Now().Unix())
+	return err
+}
+
+// GetCertificatePin retrieves the pinned TLS certificate fingerprint for a server ("" if none)
+func (s *State) GetCertificatePin(serverAddress string) (string, error) {
+	var fingerprint string
+	err := s.db.QueryRow(`
+		SELECT fingerprint
+		FROM CertificatePin
+		WHERE server_address = ?
+	`, serverAddress).Scan(&fingerprint)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return fingerprint, err
+}
+
+// SaveCertificatePin pins a TLS certificate fingerprint for a server
+func (s *State) SaveCertificatePin(serverAddress, fingerprint string) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO CertificatePin (server_address, fingerprint, pinned_at)
+		VALUES (?, ?, ?)
+	`, serverAddress, fingerprint, time.Now().Unix())
+	return err
+}
+
+// DeleteCertificatePin forgets the pinned TLS certificate of a server
+func (s *State) DeleteCertificatePin(serverAddress string) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM CertificatePin WHERE server_address = ?", serverAddress)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
 // GetFirstRun checks if this is the first time running the client
 func (s *State) GetFirstRun() bool {
 	val, _ := s.GetConfig("first_run_complete")
@@ -195,3 +286,14 @@ func (s *State) GetFirstPostWarningDismissed() bool {
 func (s *State) SetFirstPostWarningDismissed() error {
 	return s.SetConfig("first_post_warning_dismissed", "true")
 }
+
+// GetShowRawMarkdown checks if messages should be shown as markdown source instead of rendered
+func (s *State) GetShowRawMarkdown() bool {
+	val, _ := s.GetConfig("show_raw_markdown")
+	return val == "true"
+}
+
+// SetShowRawMarkdown saves whether messages are shown as markdown source
+func (s *State) SetShowRawMarkdown(raw bool) error {
+	return s.SetConfig("show_raw_markdown", strconv.FormatBool(raw))
+}
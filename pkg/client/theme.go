@@ -0,0 +1,174 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Theme holds the colors used by the terminal UI. Colors are ANSI 256-color
+// numbers ("39") or hex RGB values ("#268bd2").
+type Theme struct {
+	Base      string `toml:"base"`      // Built-in theme to take unset colors from (user themes only)
+	Primary   string `toml:"primary"`   // Titles, shortcuts, selection
+	Secondary string `toml:"secondary"` // Author names
+	Accent    string `toml:"accent"`    // Dialog borders and titles, focused input
+	Highlight string `toml:"highlight"` // Selected items in dialogs
+	Success   string `toml:"success"`
+	Error     string `toml:"error"`
+	Warning   string `toml:"warning"`
+	Muted     string `toml:"muted"` // Timestamps, hints, blurred input
+	Border    string `toml:"border"`
+	Text      string `toml:"text"` // Message content and body text
+}
+
+// DefaultThemeName is the theme used when none is configured
+const DefaultThemeName = "default"
+
+var builtinThemes = map[string]Theme{
+	"default": {
+		Primary:   "39",
+		Secondary: "213",
+		Accent:    "205",
+		Highlight: "170",
+		Success:   "42",
+		Error:     "196",
+		Warning:   "214",
+		Muted:     "243",
+		Border:    "238",
+		Text:      "252",
+	},
+	"light": {
+		Primary:   "25",
+		Secondary: "127",
+		Accent:    "162",
+		Highlight: "91",
+		Success:   "28",
+		Error:     "160",
+		Warning:   "130",
+		Muted:     "244",
+		Border:    "250",
+		Text:      "235",
+	},
+	"high-contrast": {
+		Primary:   "14",
+		Secondary: "13",
+		Accent:    "11",
+		Highlight: "14",
+		Success:   "10",
+		Error:     "9",
+		Warning:   "11",
+		Muted:     "250",
+		Border:    "15",
+		Text:      "15",
+	},
+	"solarized": {
+		Primary:   "#268bd2",
+		Secondary: "#d33682",
+		Accent:    "#2aa198",
+		Highlight: "#6c71c4",
+		Success:   "#859900",
+		Error:     "#dc322f",
+		Warning:   "#cb4b16",
+		Muted:     "#586e75",
+		Border:    "#073642",
+		Text:      "#93a1a1",
+	},
+}
+
+var (
+	hexColorPattern  = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	themeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// DefaultTheme returns the default built-in theme
+func DefaultTheme() Theme {
+	return builtinThemes[DefaultThemeName]
+}
+
+// BuiltinThemeNames returns the names of the built-in themes, sorted
+func BuiltinThemeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ThemeDir returns the directory user themes are loaded from
+func ThemeDir(configDir string) string {
+	return filepath.Join(configDir, "themes")
+}
+
+// LoadTheme returns the named theme. Names that aren't built in are loaded from
+// <configDir>/themes/<name>.toml; colors the file doesn't set are taken from its
+// base theme (default if unset).
+func LoadTheme(name, configDir string) (Theme, error) {
+	if name == "" {
+		name = DefaultThemeName
+	}
+	if theme, ok := builtinThemes[name]; ok {
+		return theme, nil
+	}
+	if !themeNamePattern.MatchString(name) {
+		return Theme{}, fmt.Errorf("invalid theme name %q", name)
+	}
+
+	path := filepath.Join(ThemeDir(configDir), name+".toml")
+	var custom Theme
+	if _, err := toml.DecodeFile(path, &custom); err != nil {
+		if os.IsNotExist(err) {
+			return Theme{}, fmt.Errorf("unknown theme %q (built-in themes: %s; or create %s)",
+				name, strings.Join(BuiltinThemeNames(), ", "), path)
+		}
+		return Theme{}, fmt.Errorf("failed to load theme %s: %s", path, cleanErrorMessage(err.Error()))
+	}
+
+	baseName := custom.Base
+	if baseName == "" {
+		baseName = DefaultThemeName
+	}
+	theme, ok := builtinThemes[baseName]
+	if !ok {
+		return Theme{}, fmt.Errorf("theme %s: unknown base theme %q (must be one of: %s)",
+			path, baseName, strings.Join(BuiltinThemeNames(), ", "))
+	}
+
+	// Override the base colors with the ones the file sets
+	src := reflect.ValueOf(custom)
+	dst := reflect.ValueOf(&theme).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		if field.Name == "Base" {
+			continue
+		}
+		color := src.Field(i).String()
+		if color == "" {
+			continue
+		}
+		if !validColor(color) {
+			return Theme{}, fmt.Errorf("theme %s: invalid %s color %q (must be 0-255 or #rrggbb)",
+				path, field.Tag.Get("toml"), color)
+		}
+		dst.Field(i).SetString(color)
+	}
+
+	return theme, nil
+}
+
+// validColor checks if a color is an ANSI 256-color number or a hex RGB value
+func validColor(color string) bool {
+	if hexColorPattern.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTheme(t *testing.T) {
+	configDir := t.TempDir()
+
+	for _, name := range BuiltinThemeNames() {
+		theme, err := LoadTheme(name, configDir)
+		if err != nil {
+			t.Fatalf("failed to load built-in theme %s: %v", name, err)
+		}
+		if theme.Primary == "" || theme.Text == "" {
+			t.Errorf("built-in theme %s has unset colors: %+v", name, theme)
+		}
+	}
+	if theme, _ := LoadTheme("", configDir); theme != DefaultTheme() {
+		t.Errorf("expected the default theme for an empty name, got %+v", theme)
+	}
+
+	// User themes override their base theme's colors
+	if err := os.MkdirAll(ThemeDir(configDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTheme := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(ThemeDir(configDir), name+".toml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTheme("mine", "base = \"light\"\nprimary = \"#b58900\"\naccent = \"33\"\n")
+	theme, err := LoadTheme("mine", configDir)
+	if err != nil {
+		t.Fatalf("failed to load user theme: %v", err)
+	}
+	light, _ := LoadTheme("light", configDir)
+	if theme.Primary != "#b58900" || theme.Accent != "33" || theme.Text != light.Text {
+		t.Errorf("expected overrides on top of the light theme, got %+v", theme)
+	}
+
+	writeTheme("bad-color", "primary = \"blue\"\n")
+	writeTheme("bad-base", "base = \"nope\"\n")
+	for name, want := range map[string]string{
+		"bad-color": "invalid primary color",
+		"bad-base":  "unknown base theme",
+		"missing":   "unknown theme",
+		"../escape": "invalid theme name",
+	} {
+		if _, err := LoadTheme(name, configDir); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("theme %q: expected error containing %q, got %v", name, want, err)
+		}
+	}
+}
+
+func TestTimestampFormatter(t *testing.T) {
+	ts := time.Date(2024, 3, 5, 14, 7, 0, 0, time.Local)
+
+	if got := (TimestampFormatter{}).Format(time.Now(), "15:04"); got != "just now" {
+		t.Errorf("expected relative timestamps by default, got %q", got)
+	}
+	if got := (TimestampFormatter{Mode: TimestampAbsolute}).Format(ts, "15:04"); got != "14:07" {
+		t.Errorf("expected the default layout, got %q", got)
+	}
+	if got := (TimestampFormatter{Mode: TimestampAbsolute, Layout: "2006-01-02"}).Format(ts, "15:04"); got != "2024-03-05" {
+		t.Errorf("expected the configured layout, got %q", got)
+	}
+	if got := (TimestampFormatter{Mode: TimestampNone}).Format(ts, "15:04"); got != "" {
+		t.Errorf("expected no timestamp, got %q", got)
+	}
+
+	// show_timestamps = false hides timestamps whatever the format
+	config := DefaultTOMLConfig()
+	config.UI.ShowTimestamps = false
+	config.UI.TimestampFormat = TimestampAbsolute
+	if f := config.TimestampFormatter(); f.Mode != TimestampNone {
+		t.Errorf("expected hidden timestamps, got %+v", f)
+	}
+
+	config = DefaultTOMLConfig()
+	config.UI.AbsoluteFormat = "not a layout"
+	if err := validateConfig(&config); err == nil {
+		t.Error("expected a layout without time fields to be rejected")
+	}
+	config.UI.AbsoluteFormat = "Jan 2 15:04"
+	config.UI.TimestampFormat = TimestampNone
+	if err := validateConfig(&config); err != nil {
+		t.Errorf("expected valid timestamp settings, got %v", err)
+	}
+}
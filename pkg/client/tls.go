@@ -0,0 +1,146 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertificatePinStore persists the TLS certificates trusted for scs:// servers
+type CertificatePinStore interface {
+	GetCertificatePin(serverAddress string) (string, error)
+	SaveCertificatePin(serverAddress, fingerprint string) error
+}
+
+// certificatePinner verifies scs:// server certificates. Certificates signed by a
+// trusted CA for the host are accepted as usual; anything else (typically the server's
+// self-signed certificate) is trusted on first use and must stay the same afterwards.
+type certificatePinner struct {
+	host    string
+	address string // host:port, the key pins are stored under
+
+	mu     sync.Mutex
+	store  CertificatePinStore // nil = pins only last for this process
+	pinned string              // Fingerprint trusted during this process
+	roots  *x509.CertPool      // nil = system roots
+}
+
+func newCertificatePinner(host, port string) *certificatePinner {
+	return &certificatePinner{
+		host:    host,
+		address: net.JoinHostPort(host, port),
+	}
+}
+
+// setStore sets where pins are persisted
+func (p *certificatePinner) setStore(store CertificatePinStore) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store = store
+}
+
+// verify is the tls.Config VerifyPeerCertificate callback
+func (p *certificatePinner) verify(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("tls verification failed: server presented no certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("tls verification failed: invalid server certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Certificates from a trusted CA don't need pinning (and survive renewal)
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: p.host, Roots: p.roots, Intermediates: intermediates}); err == nil {
+		return nil
+	}
+
+	fingerprint := certificateFingerprint(leaf)
+
+	expected := p.pinned
+	if expected == "" && p.store != nil {
+		stored, err := p.store.GetCertificatePin(p.address)
+		if err != nil {
+			return fmt.Errorf("tls verification failed: could not read pinned certificate for %s: %w", p.address, err)
+		}
+		expected = stored
+	}
+
+	if expected == "" {
+		// Trust on first use
+		p.pinned = fingerprint
+		if p.store != nil {
+			if err := p.store.SaveCertificatePin(p.address, fingerprint); err != nil {
+				return fmt.Errorf("tls verification failed: could not pin certificate for %s: %w", p.address, err)
+			}
+		}
+		return nil
+	}
+
+	if expected != fingerprint {
+		return fmt.Errorf("tls certificate verification failed for %s: the server presented certificate %s but %s is pinned. This could indicate a man-in-the-middle attack. If the server's certificate was replaced on purpose, run `sc --forget-cert %s` and reconnect", p.address, fingerprint, expected, p.address)
+	}
+
+	p.pinned = fingerprint
+	return nil
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of a certificate's public key,
+// formatted like SSH fingerprints. Pinning the key rather than the whole certificate
+// keeps the pin valid when the server re-issues a certificate for the same key.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// dialTLS connects to a scs:// server, verifying its certificate with pinner
+func dialTLS(address string, pinner *certificatePinner) (net.Conn, error) {
+	config := &tls.Config{
+		ServerName: pinner.host,
+		MinVersion: tls.VersionTLS12,
+		// Chain and pin checks happen in VerifyPeerCertificate, since the
+		// default verification rejects self-signed certificates outright
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: pinner.verify,
+	}
+	// The timeout covers the handshake too, so it's longer than plain TCP's
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return tls.DialWithDialer(dialer, "tcp", address, config)
+}
+
+// ForgetCertificatePin removes the pinned certificate of a scs:// server, so the next
+// connection trusts whatever certificate it presents. Returns the normalized address
+// and whether a pin existed.
+func ForgetCertificatePin(state *State, address string) (string, bool, error) {
+	if !strings.Contains(address, "://") {
+		address = "scs://" + address
+	}
+	cfg, err := parseServerAddress(address)
+	if err != nil {
+		return "", false, err
+	}
+	if cfg.pinner == nil {
+		return "", false, fmt.Errorf("%s is not a scs:// address", address)
+	}
+	removed, err := state.DeleteCertificatePin(cfg.raw)
+	return cfg.raw, removed, err
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateTestCertificate creates a self-signed certificate for localhost
+func generateTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTestTLSServer accepts TLS connections with cert and completes their handshakes
+func startTestTLSServer(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestParseServerAddressTLS(t *testing.T) {
+	cfg, err := parseServerAddress("scs://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.display != "scs://example.com:6467" {
+		t.Fatalf("expected default TLS port to be appended, got %s", cfg.display)
+	}
+	if cfg.raw != "example.com:6467" {
+		t.Fatalf("expected raw address example.com:6467, got %s", cfg.raw)
+	}
+	if cfg.pinner == nil || cfg.pinner.address != "example.com:6467" {
+		t.Fatal("expected certificate pinner for scs://")
+	}
+
+	if tcp, _ := parseServerAddress("sc://example.com"); tcp.pinner != nil {
+		t.Fatal("expected no certificate pinner for sc://")
+	}
+}
+
+func TestCertificatePinning(t *testing.T) {
+	cert := generateTestCertificate(t)
+	addr := startTestTLSServer(t, cert)
+	_, port, _ := net.SplitHostPort(addr)
+
+	state := NewMockState()
+	pinner := newCertificatePinner("127.0.0.1", port)
+	pinner.setStore(state)
+
+	// First connection trusts and pins the certificate
+	conn, err := dialTLS(addr, pinner)
+	if err != nil {
+		t.Fatalf("First connection failed: %v", err)
+	}
+	conn.Close()
+
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	pinned, _ := state.GetCertificatePin(addr)
+	if pinned != certificateFingerprint(leaf) {
+		t.Fatalf("Expected pin %s, got %q", certificateFingerprint(leaf), pinned)
+	}
+
+	// A new process (fresh pinner) accepts the same certificate
+	conn, err = dialTLS(addr, &certificatePinner{host: "127.0.0.1", address: addr, store: state})
+	if err != nil {
+		t.Fatalf("Connection with pinned certificate failed: %v", err)
+	}
+	conn.Close()
+
+	// A different certificate on the same address is refused
+	other := generateTestCertificate(t)
+	otherAddr := startTestTLSServer(t, other)
+	state.SaveCertificatePin(otherAddr, pinned)
+	_, otherPort, _ := net.SplitHostPort(otherAddr)
+	otherPinner := newCertificatePinner("127.0.0.1", otherPort)
+	otherPinner.setStore(state)
+	if _, err := dialTLS(otherAddr, otherPinner); err == nil || !strings.Contains(err.Error(), "--forget-cert") {
+		t.Fatalf("Expected pin mismatch error, got %v", err)
+	}
+
+	// Certificates from a trusted CA are accepted without pinning
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	caPinner := &certificatePinner{host: "localhost", address: "localhost:" + port, store: state, roots: roots}
+	conn, err = dialTLS(addr, caPinner)
+	if err != nil {
+		t.Fatalf("Connection with CA-signed certificate failed: %v", err)
+	}
+	conn.Close()
+	if pin, _ := state.GetCertificatePin("localhost:" + port); pin != "" {
+		t.Errorf("Expected no pin for a CA-signed certificate, got %s", pin)
+	}
+}
+
+func TestStateCertificatePins(t *testing.T) {
+	state, err := OpenState(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open state: %v", err)
+	}
+	defer state.Close()
+
+	if pin, err := state.GetCertificatePin("example.com:6467"); err != nil || pin != "" {
+		t.Fatalf("Expected no pin, got %q (err %v)", pin, err)
+	}
+	if err := state.SaveCertificatePin("example.com:6467", "SHA256:abc"); err != nil {
+		t.Fatalf("SaveCertificatePin failed: %v", err)
+	}
+	if pin, _ := state.GetCertificatePin("example.com:6467"); pin != "SHA256:abc" {
+		t.Fatalf("Expected saved pin, got %q", pin)
+	}
+
+	addr, removed, err := ForgetCertificatePin(state, "example.com")
+	if err != nil || !removed || addr != "example.com:6467" {
+		t.Fatalf("ForgetCertificatePin = %q, %v, %v", addr, removed, err)
+	}
+	if pin, _ := state.GetCertificatePin("example.com:6467"); pin != "" {
+		t.Fatalf("Expected pin to be removed, got %q", pin)
+	}
+	if _, removed, _ := ForgetCertificatePin(state, "scs://example.com"); removed {
+		t.Error("Expected nothing to remove the second time")
+	}
+}
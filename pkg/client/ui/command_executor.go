@@ -19,30 +19,36 @@ func (m Model) GetCurrentView() commands.ViewID {
 }
 
 func (m Model) GetActiveModal() commands.ModalType {
-	topModal := m.modalStack.TopType()
+	sharedModal, _ := sharedModalType(m.modalStack.TopType())
+	return sharedModal
+}
 
-	// Map terminal modal types to shared modal types
-	switch topModal {
+// sharedModalType maps terminal modal types to shared modal types. Returns false
+// for modals the shared command system doesn't know (mapped to ModalNone).
+func sharedModalType(t modal.ModalType) (commands.ModalType, bool) {
+	switch t {
+	case modal.ModalNone:
+		return commands.ModalNone, true
 	case modal.ModalCompose:
-		return commands.ModalCompose
+		return commands.ModalCompose, true
 	case modal.ModalHelp:
-		return commands.ModalHelp
+		return commands.ModalHelp, true
 	case modal.ModalServerSelector:
-		return commands.ModalServerSelector
+		return commands.ModalServerSelector, true
 	case modal.ModalAdminPanel:
-		return commands.ModalAdminPanel
+		return commands.ModalAdminPanel, true
 	case modal.ModalCreateChannel:
-		return commands.ModalCreateChannel
+		return commands.ModalCreateChannel, true
 	case modal.ModalNicknameSetup:
-		return commands.ModalNicknameSetup
+		return commands.ModalNicknameSetup, true
 	case modal.ModalPasswordAuth:
-		return commands.ModalPasswordAuth
+		return commands.ModalPasswordAuth, true
 	case modal.ModalRegistration:
-		return commands.ModalRegistration
+		return commands.ModalRegistration, true
 	case modal.ModalDeleteConfirm:
-		return commands.ModalDeleteConfirm
+		return commands.ModalDeleteConfirm, true
 	default:
-		return commands.ModalNone
+		return commands.ModalNone, false
 	}
 }
 
@@ -162,6 +168,11 @@ func (m Model) ExecuteActionWithReturn(actionID string) (Model, tea.Cmd) {
 	case commands.ActionCreateChannel:
 		return m.openCreateChannel()
 
+	// === Display Actions ===
+	case commands.ActionToggleMarkdown:
+		m.toggleRawMarkdown()
+		return m, nil
+
 	default:
 		// Unknown action - just return unchanged
 		return m, nil
@@ -208,7 +219,7 @@ func (m Model) navigateUp() (Model, tea.Cmd) {
 func (m Model) navigateDown() (Model, tea.Cmd) {
 	switch m.currentView {
 	case ViewChannelList:
-		if m.channelCursor < len(m.channels)-1 {
+		if m.channelCursor < len(m.channelListRows())-1 {
 			m.channelCursor++
 		}
 	case ViewThreadList:
@@ -253,10 +264,13 @@ func (m Model) goBack() (Model, tea.Cmd) {
 	case ViewThreadList, ViewChatChannel:
 		m.currentView = ViewChannelList
 		m.currentChannel = nil
-		// Leave channel
+		// Leave channel (command captures the subchannel before it's cleared)
+		var cmd tea.Cmd
 		if m.hasActiveChannel {
-			return m, m.sendLeaveChannel(m.activeChannelID)
+			cmd = m.sendLeaveChannel(m.activeChannelID)
 		}
+		m.currentSubchannel = nil
+		return m, cmd
 	}
 	return m, nil
 }
@@ -337,17 +351,31 @@ func (m Model) openCreateChannel() (Model, tea.Cmd) {
 // === Existing helper methods that we're calling ===
 
 func (m Model) selectCurrentChannel() (Model, tea.Cmd) {
-	if m.channelCursor >= 0 && m.channelCursor < len(m.channels) {
-		ch := m.channels[m.channelCursor]
+	rows := m.channelListRows()
+	if m.channelCursor >= 0 && m.channelCursor < len(rows) {
+		row := rows[m.channelCursor]
+		ch := row.channel
+
+		// Channels with subchannels expand/collapse instead of opening
+		if row.subchannel == nil && len(m.subchannels[ch.ID]) > 0 {
+			m.expandedChannels[ch.ID] = !m.expandedChannels[ch.ID]
+			return m, nil
+		}
+
 		m.currentChannel = &ch
+		m.currentSubchannel = row.subchannel
 
 		// Join and subscribe to channel
 		var cmds []tea.Cmd
 		cmds = append(cmds, m.sendJoinChannel(ch.ID))
 		cmds = append(cmds, m.sendSubscribeChannel(ch.ID))
 
-		// Request appropriate data based on channel type
-		if ch.Type == 0 {
+		// Request appropriate data based on channel type (subchannels have their own type)
+		channelType := ch.Type
+		if row.subchannel != nil {
+			channelType = row.subchannel.Type
+		}
+		if channelType == 0 {
 			// Chat channel
 			m.currentView = ViewChatChannel
 			cmds = append(cmds, m.requestChatMessages(ch.ID))
@@ -4,35 +4,44 @@ import (
 	"sort"
 	"strings"
 
+	shared "github.com/aeolun/superchat/pkg/client/commands"
 	"github.com/aeolun/superchat/pkg/client/ui/modal"
 )
 
 // Registry manages all registered commands
 type Registry struct {
-	commands       []Command
-	keyMap         map[string][]*Command // key -> commands (for dispatch)
-	viewCommands   map[int][]*Command    // view -> commands (for rendering)
-	globalCommands []*Command            // cached global commands
+	commands       []*Command
+	viewCommands   map[int][]*Command // view -> commands (for rendering)
+	globalCommands []*Command         // cached global commands
+	bindings       *shared.KeyBindings
 }
 
 // NewRegistry creates a new command registry
 func NewRegistry() *Registry {
 	return &Registry{
-		commands:     []Command{},
-		keyMap:       make(map[string][]*Command),
+		commands:     []*Command{},
 		viewCommands: make(map[int][]*Command),
 	}
 }
 
-// Register adds a command to the registry
-func (r *Registry) Register(cmd Command) {
-	r.commands = append(r.commands, cmd)
-	cmdPtr := &r.commands[len(r.commands)-1]
+// SetBindings applies user key bindings to the registered commands
+func (r *Registry) SetBindings(bindings *shared.KeyBindings) {
+	r.bindings = bindings
+}
 
-	// Build key lookup map
-	for _, key := range cmd.Keys {
-		r.keyMap[key] = append(r.keyMap[key], cmdPtr)
+// keysFor returns a command's keys in the given context, with user bindings applied
+func (r *Registry) keysFor(cmd *Command, view int, activeModal modal.ModalType) []string {
+	scopes := []string{shared.ViewIDFromInt(view).String()}
+	if activeModal != modal.ModalNone {
+		scopes = append(scopes, activeModal.String())
 	}
+	return r.bindings.Keys(cmd.ActionID, cmd.Keys, scopes...)
+}
+
+// Register adds a command to the registry
+func (r *Registry) Register(cmd Command) {
+	cmdPtr := &cmd
+	r.commands = append(r.commands, cmdPtr)
 
 	// Build view lookup map
 	if cmd.Scope == ScopeGlobal {
@@ -47,15 +56,10 @@ func (r *Registry) Register(cmd Command) {
 // GetCommand finds the first available command for a key in the current context
 // Returns nil if no available command matches the key
 func (r *Registry) GetCommand(key string, view int, activeModal modal.ModalType, model interface{}) *Command {
-	commands, exists := r.keyMap[key]
-	if !exists {
-		return nil
-	}
-
 	// Return the first available command
 	// Commands are checked in registration order, with more specific (view) taking precedence
-	for _, cmd := range commands {
-		if r.isCommandAvailable(cmd, view, activeModal, model) {
+	for _, cmd := range r.commands {
+		if containsKey(r.keysFor(cmd, view, activeModal), key) && r.isCommandAvailable(cmd, view, activeModal, model) {
 			return cmd
 		}
 	}
@@ -63,6 +67,16 @@ func (r *Registry) GetCommand(key string, view int, activeModal modal.ModalType,
 	return nil
 }
 
+// containsKey checks if keys contains key
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 // isCommandAvailable checks if a command is available in the current context
 func (r *Registry) isCommandAvailable(cmd *Command, view int, activeModal modal.ModalType, model interface{}) bool {
 	// Check modal compatibility
@@ -111,25 +125,28 @@ func (r *Registry) isCommandAvailable(cmd *Command, view int, activeModal modal.
 	return true
 }
 
-// GetAvailableCommands returns all commands available in the current context
-// Commands are sorted by priority (lower priority value = shown first)
+// GetAvailableCommands returns all commands available in the current context, with
+// user key bindings applied. Commands are sorted by priority (lower priority value = shown first)
 func (r *Registry) GetAvailableCommands(view int, activeModal modal.ModalType, model interface{}) []*Command {
 	var available []*Command
 
+	add := func(cmd *Command) {
+		if !r.isCommandAvailable(cmd, view, activeModal, model) {
+			return
+		}
+		bound := *cmd
+		bound.Keys = r.keysFor(cmd, view, activeModal)
+		available = append(available, &bound)
+	}
+
 	// Add global commands
 	for _, cmd := range r.globalCommands {
-		if r.isCommandAvailable(cmd, view, activeModal, model) {
-			available = append(available, cmd)
-		}
+		add(cmd)
 	}
 
 	// Add view-specific commands
-	if viewCmds, exists := r.viewCommands[view]; exists {
-		for _, cmd := range viewCmds {
-			if r.isCommandAvailable(cmd, view, activeModal, model) {
-				available = append(available, cmd)
-			}
-		}
+	for _, cmd := range r.viewCommands[view] {
+		add(cmd)
 	}
 
 	// Sort by priority
@@ -190,8 +207,7 @@ func (r *Registry) GetCommandByName(name string, view int, activeModal modal.Mod
 	lowerName := strings.ToLower(name)
 
 	// Check all commands
-	for i := range r.commands {
-		cmd := &r.commands[i]
+	for _, cmd := range r.commands {
 		if !r.isCommandAvailable(cmd, view, activeModal, model) {
 			continue
 		}
@@ -239,3 +255,28 @@ func (r *Registry) GetCommandNames(view int, activeModal modal.ModalType, model
 	sort.Strings(result)
 	return result
 }
+
+// Bindings describes the registered commands for key binding conflict detection.
+// sharedModal maps modal types to the shared ones; it returns false for modals
+// the shared command system doesn't know.
+func (r *Registry) Bindings(sharedModal func(modal.ModalType) (shared.ModalType, bool)) []shared.Binding {
+	var bindings []shared.Binding
+	for _, cmd := range r.commands {
+		if cmd.ActionID == "" {
+			continue
+		}
+		b := shared.Binding{ActionID: cmd.ActionID, Keys: cmd.Keys}
+		if cmd.Scope == ScopeView {
+			for _, view := range cmd.ViewStates {
+				b.Views = append(b.Views, shared.ViewIDFromInt(view))
+			}
+		}
+		for _, m := range cmd.ModalStates {
+			if sm, ok := sharedModal(m); ok {
+				b.Modals = append(b.Modals, sm)
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
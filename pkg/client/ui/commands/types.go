@@ -44,6 +44,10 @@ type Command struct {
 
 	// Priority for display ordering (lower = higher priority in footer/help)
 	Priority int
+
+	// ActionID identifies the command's action for user key bindings
+	// (one of the shared Action* IDs; empty means the keys can't be rebound)
+	ActionID string
 }
 
 // CommandScope defines the availability scope of a command
@@ -195,6 +199,12 @@ func (b *CommandBuilder) Do(fn func(interface{}) (interface{}, tea.Cmd)) *Comman
 	return b
 }
 
+// Action sets the action ID user key bindings refer to
+func (b *CommandBuilder) Action(actionID string) *CommandBuilder {
+	b.cmd.ActionID = actionID
+	return b
+}
+
 // Priority sets the display priority (lower = shown first)
 func (b *CommandBuilder) Priority(p int) *CommandBuilder {
 	b.cmd.Priority = p
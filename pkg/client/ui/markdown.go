@@ -0,0 +1,241 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/client/markdown"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// mdFragment is a run of text with one style, ready to be laid out
+type mdFragment struct {
+	text  string
+	style lipgloss.Style
+}
+
+// renderMarkdown renders message content as styled lines no wider than width
+func renderMarkdown(content string, width int) []string {
+	lines := renderBlocks(markdown.Parse(content), width)
+	if len(lines) == 0 {
+		return []string{""}
+	}
+	return lines
+}
+
+// renderBlocks renders blocks, separated by blank lines
+func renderBlocks(blocks []markdown.Block, width int) []string {
+	var lines []string
+	for i, block := range blocks {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+
+		switch block.Kind {
+		case markdown.BlockParagraph:
+			lines = append(lines, wrapFragments(spanFragments(block.Spans), width)...)
+
+		case markdown.BlockCode:
+			gutter := MarkdownQuoteStyle.Render("│ ")
+			for _, line := range breakFragments(codeFragments(block.Code, block.Lang), width-2) {
+				lines = append(lines, gutter+line)
+			}
+
+		case markdown.BlockQuote:
+			bar := MarkdownQuoteStyle.Render("▎ ")
+			for _, line := range renderBlocks(block.Children, width-2) {
+				lines = append(lines, bar+line)
+			}
+
+		case markdown.BlockList:
+			for _, item := range block.Items {
+				marker := strings.Repeat("  ", item.Level) + MarkdownMarkerStyle.Render(item.Marker) + " "
+				markerWidth := lipgloss.Width(marker)
+				hanging := strings.Repeat(" ", markerWidth)
+				for j, line := range wrapFragments(spanFragments(item.Spans), width-markerWidth) {
+					if j == 0 {
+						lines = append(lines, marker+line)
+					} else {
+						lines = append(lines, hanging+line)
+					}
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// spanFragments styles inline spans, adding the target after each link whose text
+// isn't the URL itself
+func spanFragments(spans []markdown.Span) []mdFragment {
+	fragments := make([]mdFragment, 0, len(spans))
+	var linkText strings.Builder
+	for i, span := range spans {
+		fragments = append(fragments, mdFragment{text: span.Text, style: spanStyle(span.Style)})
+
+		if span.Style&markdown.Link == 0 {
+			continue
+		}
+		linkText.WriteString(span.Text)
+		if i+1 < len(spans) && spans[i+1].Style&markdown.Link != 0 && spans[i+1].URL == span.URL {
+			continue
+		}
+		if linkText.String() != span.URL && strings.TrimPrefix(span.URL, "mailto:") != linkText.String() {
+			fragments = append(fragments, mdFragment{text: " (" + span.URL + ")", style: MarkdownURLStyle})
+		}
+		linkText.Reset()
+	}
+	return fragments
+}
+
+// spanStyle returns the style for inline formatting
+func spanStyle(s markdown.Style) lipgloss.Style {
+	style := MessageContentStyle
+	if s&markdown.Code != 0 {
+		style = MarkdownCodeStyle
+	}
+	if s&markdown.Link != 0 {
+		style = MarkdownLinkStyle
+	}
+	if s&markdown.Bold != 0 {
+		style = style.Copy().Bold(true)
+	}
+	if s&markdown.Italic != 0 {
+		style = style.Copy().Italic(true)
+	}
+	return style
+}
+
+// codeFragments styles the contents of a code block with syntax highlighting
+func codeFragments(code, lang string) []mdFragment {
+	tokens := markdown.Highlight(code, lang)
+	fragments := make([]mdFragment, 0, len(tokens))
+	for _, token := range tokens {
+		style := MessageContentStyle
+		switch token.Kind {
+		case markdown.TokenKeyword:
+			style = MarkdownKeywordStyle
+		case markdown.TokenString:
+			style = MarkdownStringStyle
+		case markdown.TokenComment:
+			style = MarkdownCommentStyle
+		case markdown.TokenNumber:
+			style = MarkdownNumberStyle
+		}
+		fragments = append(fragments, mdFragment{text: token.Text, style: style})
+	}
+	return fragments
+}
+
+// wrapFragments word-wraps fragments to width. Runs of spaces collapse into one,
+// newlines are kept, and words longer than width are broken.
+func wrapFragments(fragments []mdFragment, width int) []string {
+	width = max(width, 1)
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	var word []mdFragment
+	wordWidth := 0
+
+	endLine := func() {
+		lines = append(lines, line.String())
+		line.Reset()
+		lineWidth = 0
+	}
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		if lineWidth > 0 && lineWidth+1+wordWidth > width {
+			endLine()
+		}
+		if wordWidth > width {
+			if lineWidth > 0 {
+				endLine()
+			}
+			chunks := breakFragments(word, width)
+			lines = append(lines, chunks[:len(chunks)-1]...)
+			line.WriteString(chunks[len(chunks)-1])
+			lineWidth = lipgloss.Width(chunks[len(chunks)-1])
+		} else {
+			if lineWidth > 0 {
+				line.WriteString(" ")
+				lineWidth++
+			}
+			for _, f := range word {
+				line.WriteString(f.style.Render(f.text))
+			}
+			lineWidth += wordWidth
+		}
+		word = word[:0]
+		wordWidth = 0
+	}
+
+	for _, fragment := range fragments {
+		start := 0
+		text := fragment.text
+		for i := 0; i <= len(text); i++ {
+			if i < len(text) && text[i] != ' ' && text[i] != '\n' {
+				continue
+			}
+			if i > start {
+				word = append(word, mdFragment{text: text[start:i], style: fragment.style})
+				wordWidth += lipgloss.Width(text[start:i])
+			}
+			if i < len(text) {
+				flushWord()
+				if text[i] == '\n' {
+					endLine()
+				}
+			}
+			start = i + 1
+		}
+	}
+	flushWord()
+	if lineWidth > 0 || len(lines) == 0 {
+		endLine()
+	}
+
+	return lines
+}
+
+// breakFragments splits fragments into lines at newlines and every width
+// columns, keeping all whitespace (for code)
+func breakFragments(fragments []mdFragment, width int) []string {
+	width = max(width, 1)
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+
+	for _, fragment := range fragments {
+		var chunk strings.Builder
+		flushChunk := func() {
+			if chunk.Len() > 0 {
+				line.WriteString(fragment.style.Render(chunk.String()))
+				chunk.Reset()
+			}
+		}
+		for _, r := range fragment.text {
+			if r == '\n' {
+				flushChunk()
+				lines = append(lines, line.String())
+				line.Reset()
+				lineWidth = 0
+				continue
+			}
+			w := lipgloss.Width(string(r))
+			if lineWidth > 0 && lineWidth+w > width {
+				flushChunk()
+				lines = append(lines, line.String())
+				line.Reset()
+				lineWidth = 0
+			}
+			chunk.WriteRune(r)
+			lineWidth += w
+		}
+		flushChunk()
+	}
+
+	return append(lines, line.String())
+}
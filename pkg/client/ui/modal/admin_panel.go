@@ -11,12 +11,14 @@ import (
 type AdminPanelModal struct {
 	selectedIndex int
 	menuItems     []adminMenuItem
+	moderator     bool // Only show what moderators can do
 }
 
 type adminMenuItem struct {
 	label       string
 	description string
 	action      func() (Modal, tea.Cmd) // Returns the modal to open and optional command
+	adminOnly   bool
 }
 
 // NewAdminPanelModal creates a new admin panel modal
@@ -36,8 +38,12 @@ func (m *AdminPanelModal) SetMenuActions(
 	viewBansAction func() (Modal, tea.Cmd),
 	deleteUserAction func() (Modal, tea.Cmd),
 	deleteChannelAction func() (Modal, tea.Cmd),
+	auditLogAction func() (Modal, tea.Cmd),
+	manageRolesAction func() (Modal, tea.Cmd),
+	loginLockoutsAction func() (Modal, tea.Cmd),
+	disableTwoFactorAction func() (Modal, tea.Cmd),
 ) {
-	m.menuItems = []adminMenuItem{
+	items := []adminMenuItem{
 		{
 			label:       "Ban User",
 			description: "Ban a user by nickname",
@@ -52,11 +58,13 @@ func (m *AdminPanelModal) SetMenuActions(
 			label:       "List Users",
 			description: "View all users with online status",
 			action:      listUsersAction,
+			adminOnly:   true,
 		},
 		{
 			label:       "Unban User/IP",
 			description: "Remove a ban from user or IP",
 			action:      unbanAction,
+			adminOnly:   true,
 		},
 		{
 			label:       "View Ban List",
@@ -67,15 +75,53 @@ func (m *AdminPanelModal) SetMenuActions(
 			label:       "Delete User",
 			description: "Permanently delete a user account",
 			action:      deleteUserAction,
+			adminOnly:   true,
 		},
 		{
 			label:       "Delete Channel",
 			description: "Permanently delete a channel",
 			action:      deleteChannelAction,
+			adminOnly:   true,
 		},
+		{
+			label:       "Audit Log",
+			description: "Review what admins and moderators did",
+			action:      auditLogAction,
+		},
+		{
+			label:       "Manage Roles",
+			description: "Make users moderators or admins",
+			action:      manageRolesAction,
+			adminOnly:   true,
+		},
+		{
+			label:       "Login Lockouts",
+			description: "Lift lockouts after failed logins",
+			action:      loginLockoutsAction,
+			adminOnly:   true,
+		},
+		{
+			label:       "Disable 2FA",
+			description: "Turn off two-factor auth for a locked-out user",
+			action:      disableTwoFactorAction,
+			adminOnly:   true,
+		},
+	}
+
+	m.menuItems = items[:0]
+	for _, item := range items {
+		if !item.adminOnly || !m.moderator {
+			m.menuItems = append(m.menuItems, item)
+		}
 	}
 }
 
+// SetModerator limits the panel to the actions moderators can take. Call it
+// before SetMenuActions.
+func (m *AdminPanelModal) SetModerator(moderator bool) {
+	m.moderator = moderator
+}
+
 // Type returns the modal type
 func (m *AdminPanelModal) Type() ModalType {
 	return ModalAdminPanel
@@ -126,7 +172,7 @@ func (m *AdminPanelModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 func (m *AdminPanelModal) Render(width, height int) string {
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("196")). // Red for admin
+		Foreground(colorError). // Red for admin
 		MarginBottom(1).
 		Align(lipgloss.Center)
 
@@ -137,26 +183,29 @@ func (m *AdminPanelModal) Render(width, height int) string {
 		Padding(0, 1)
 
 	unselectedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Padding(0, 1)
 
 	descStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true).
 		MarginLeft(3)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("196")).
+		BorderForeground(colorError).
 		Padding(1, 2).
 		Width(60)
 
 	// Build content
 	title := titleStyle.Render("⚠ ADMIN PANEL ⚠")
+	if m.moderator {
+		title = titleStyle.Render("⚠ MODERATOR PANEL ⚠")
+	}
 
 	var menuLines []string
 	for i, item := range m.menuItems {
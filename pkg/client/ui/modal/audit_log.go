@@ -0,0 +1,431 @@
+package modal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// auditLogPageSize is how many entries the audit log asks for at a time
+const auditLogPageSize = 15
+
+// Filter fields of the audit log
+const (
+	auditFieldAdmin = iota
+	auditFieldAction
+	auditFieldSince
+	auditFieldUntil
+	auditFieldCount
+)
+
+// AuditLogModal browses the admin audit log, a page at a time
+type AuditLogModal struct {
+	entries       []protocol.AdminActionEntry
+	hasMore       bool
+	selectedIndex int
+	loading       bool
+	errorMessage  string
+
+	// Filters, as typed (applied ones are in query)
+	filters     [auditFieldCount]string
+	editing     bool
+	activeField int
+	query       protocol.ListAdminActionsMessage
+
+	// BeforeID of each newer page, to page back
+	newerPages []*uint64
+
+	onQuery func(*protocol.ListAdminActionsMessage) tea.Cmd
+}
+
+// NewAuditLogModal creates the audit log viewer, waiting for its first ADMIN_ACTION_LIST
+func NewAuditLogModal(onQuery func(*protocol.ListAdminActionsMessage) tea.Cmd) *AuditLogModal {
+	return &AuditLogModal{
+		loading: true,
+		query:   protocol.ListAdminActionsMessage{Limit: auditLogPageSize},
+		onQuery: onQuery,
+	}
+}
+
+// Query returns the request for the current page
+func (m *AuditLogModal) Query() *protocol.ListAdminActionsMessage {
+	query := m.query
+	return &query
+}
+
+// SetEntries shows a page of the audit log
+func (m *AuditLogModal) SetEntries(entries []protocol.AdminActionEntry, hasMore bool) {
+	m.entries = entries
+	m.hasMore = hasMore
+	m.loading = false
+	m.selectedIndex = 0
+}
+
+// Type returns the modal type
+func (m *AuditLogModal) Type() ModalType {
+	return ModalAuditLog
+}
+
+// HandleKey processes keyboard input
+func (m *AuditLogModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	if m.editing {
+		return m.handleFilterKey(msg)
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		return true, nil, nil // Close modal, back to the admin panel
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.entries)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "n", "right", "pgdown":
+		// Older entries
+		if !m.hasMore || len(m.entries) == 0 || m.loading {
+			return true, m, nil
+		}
+		m.newerPages = append(m.newerPages, m.query.BeforeID)
+		beforeID := m.entries[len(m.entries)-1].ID
+		m.query.BeforeID = &beforeID
+		return true, m, m.reload()
+
+	case "p", "left", "pgup":
+		// Newer entries
+		if len(m.newerPages) == 0 || m.loading {
+			return true, m, nil
+		}
+		m.query.BeforeID = m.newerPages[len(m.newerPages)-1]
+		m.newerPages = m.newerPages[:len(m.newerPages)-1]
+		return true, m, m.reload()
+
+	case "f", "/":
+		m.editing = true
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "c":
+		// Clear filters
+		m.filters = [auditFieldCount]string{}
+		m.query = protocol.ListAdminActionsMessage{Limit: auditLogPageSize}
+		m.newerPages = nil
+		return true, m, m.reload()
+
+	case "r":
+		return true, m, m.reload()
+
+	default:
+		return true, m, nil
+	}
+}
+
+// handleFilterKey edits the filter fields
+func (m *AuditLogModal) handleFilterKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.editing = false
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "tab", "down":
+		m.activeField = (m.activeField + 1) % auditFieldCount
+		return true, m, nil
+
+	case "shift+tab", "up":
+		m.activeField = (m.activeField + auditFieldCount - 1) % auditFieldCount
+		return true, m, nil
+
+	case "enter":
+		return m.applyFilters()
+
+	case "backspace":
+		field := m.filters[m.activeField]
+		if len(field) > 0 {
+			m.filters[m.activeField] = field[:len(field)-1]
+		}
+		m.errorMessage = ""
+		return true, m, nil
+
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			if len(m.filters[m.activeField]) < 40 {
+				m.filters[m.activeField] += string(msg.Runes)
+			}
+			m.errorMessage = ""
+		}
+		return true, m, nil
+	}
+}
+
+// applyFilters validates the filter fields and loads the first page that matches
+func (m *AuditLogModal) applyFilters() (bool, Modal, tea.Cmd) {
+	now := time.Now()
+	since, err := parseAuditTime(m.filters[auditFieldSince], now)
+	if err != nil {
+		m.errorMessage = err.Error()
+		m.activeField = auditFieldSince
+		return true, m, nil
+	}
+	until, err := parseAuditTime(m.filters[auditFieldUntil], now)
+	if err != nil {
+		m.errorMessage = err.Error()
+		m.activeField = auditFieldUntil
+		return true, m, nil
+	}
+	if since != nil && until != nil && !since.Before(*until) {
+		m.errorMessage = "\"Since\" must be before \"until\""
+		m.activeField = auditFieldSince
+		return true, m, nil
+	}
+
+	m.query = protocol.ListAdminActionsMessage{
+		AdminNickname: optionalField(m.filters[auditFieldAdmin]),
+		ActionType:    optionalField(m.filters[auditFieldAction]),
+		Since:         since,
+		Until:         until,
+		Limit:         auditLogPageSize,
+	}
+	m.newerPages = nil
+	m.editing = false
+	m.errorMessage = ""
+	return true, m, m.reload()
+}
+
+// reload requests the current page
+func (m *AuditLogModal) reload() tea.Cmd {
+	m.loading = true
+	if m.onQuery == nil {
+		return nil
+	}
+	return m.onQuery(m.Query())
+}
+
+// optionalField returns a trimmed field, or nil if it's empty
+func optionalField(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// parseAuditTime parses a time filter: empty, a time ago ("30m", "24h", "7d"),
+// or a local date ("2006-01-02" or "2006-01-02 15:04")
+func parseAuditTime(s string, now time.Time) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			t := now.AddDate(0, 0, -n)
+			return &t, nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		t := now.Add(-d)
+		return &t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid time %q (use e.g. 24h, 7d or 2006-01-02)", s)
+}
+
+// prettyDetails formats an entry's details for display, indenting JSON
+func prettyDetails(details string) string {
+	if strings.TrimSpace(details) == "" {
+		return "(no details)"
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(details), "", "  "); err != nil {
+		return details
+	}
+	return buf.String()
+}
+
+// Render returns the modal content
+func (m *AuditLogModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorError).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("196")).
+		Bold(true).
+		Padding(0, 1)
+
+	unselectedStyle := lipgloss.NewStyle().
+		Foreground(colorText).
+		Padding(0, 1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(colorText).
+		Width(8)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	inactiveInputStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Padding(0, 1)
+
+	detailsStyle := lipgloss.NewStyle().
+		Foreground(colorText).
+		Border(lipgloss.NormalBorder(), true, false, false, false).
+		BorderForeground(colorMuted)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalWidth := min(width-4, 100)
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorError).
+		Padding(1, 2).
+		Width(modalWidth)
+	innerWidth := modalWidth - 6
+
+	title := "Audit Log"
+	if page := len(m.newerPages) + 1; page > 1 {
+		title += fmt.Sprintf(" (page %d)", page)
+	}
+
+	// Filters
+	labels := [auditFieldCount]string{"Admin:", "Action:", "Since:", "Until:"}
+	var filterLines []string
+	for i, label := range labels {
+		value := m.filters[i]
+		var field string
+		switch {
+		case m.editing && i == m.activeField:
+			field = activeInputStyle.Render(value + "█")
+		case value == "":
+			field = inactiveInputStyle.Render("any")
+		default:
+			field = inactiveInputStyle.Render(value)
+		}
+		filterLines = append(filterLines, labelStyle.Render(label)+field)
+	}
+	filters := lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(innerWidth/2).Render(filterLines[0]), filterLines[1]),
+		lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(innerWidth/2).Render(filterLines[2]), filterLines[3]),
+	)
+
+	// Entries, scrolled to keep the selection visible
+	var entryLines []string
+	var details string
+	switch {
+	case m.loading:
+		entryLines = append(entryLines, hintStyle.Render("Loading..."))
+	case len(m.entries) == 0:
+		entryLines = append(entryLines, hintStyle.Render("No matching entries"))
+	default:
+		visible := max(min(len(m.entries), height-30), 3)
+		start := max(0, min(m.selectedIndex-visible/2, len(m.entries)-visible))
+		for i := start; i < min(start+visible, len(m.entries)); i++ {
+			entry := m.entries[i]
+			line := fmt.Sprintf("%s  %-16s %-16s %s",
+				entry.PerformedAt.Format("2006-01-02 15:04"),
+				truncateLine(entry.AdminNickname, 16),
+				truncateLine(entry.ActionType, 16),
+				entry.TargetIdentifier)
+			line = truncateLine(line, innerWidth-2)
+			if i == m.selectedIndex {
+				entryLines = append(entryLines, selectedStyle.Render(line))
+			} else {
+				entryLines = append(entryLines, unselectedStyle.Render(line))
+			}
+		}
+
+		entry := m.entries[m.selectedIndex]
+		target := entry.TargetType + " " + entry.TargetIdentifier
+		if entry.TargetID != nil {
+			target += fmt.Sprintf(" (ID %d)", *entry.TargetID)
+		}
+		header := []string{
+			fmt.Sprintf("#%d  %s by %s at %s", entry.ID, entry.ActionType, entry.AdminNickname,
+				entry.PerformedAt.Format("2006-01-02 15:04:05")),
+			"Target: " + target,
+		}
+		if entry.IPAddress != nil && *entry.IPAddress != "" {
+			header = append(header, "From: "+*entry.IPAddress)
+		}
+		details = detailsStyle.Width(innerWidth).Render(
+			strings.Join(header, "\n") + "\n\n" + prettyDetails(entry.Details))
+	}
+
+	var hints string
+	if m.editing {
+		hints = "[Tab] Next field  [Enter] Apply  [Esc] Cancel   Times: 24h, 7d or 2006-01-02"
+	} else {
+		hints = "[↑/↓] Navigate  [f] Filter  [c] Clear  [r] Refresh  [Esc/q] Close"
+		var paging []string
+		if len(m.newerPages) > 0 {
+			paging = append(paging, "[p] Newer")
+		}
+		if m.hasMore {
+			paging = append(paging, "[n] Older")
+		}
+		if len(paging) > 0 {
+			hints = strings.Join(paging, "  ") + "  " + hints
+		}
+	}
+
+	parts := []string{
+		titleStyle.Render(title),
+		filters,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, entryLines...),
+	}
+	if details != "" {
+		parts = append(parts, "", details)
+	}
+	if m.errorMessage != "" {
+		parts = append(parts, "", errorStyle.Render("✗ "+m.errorMessage))
+	}
+	parts = append(parts, "", hintStyle.Render(hints))
+
+	modal := modalStyle.Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+
+	// Center the modal
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modal,
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *AuditLogModal) IsBlockingInput() bool {
+	return true
+}
@@ -152,11 +152,11 @@ func (m *BanIPModal) submit() (bool, Modal, tea.Cmd) {
 func (m *BanIPModal) Render(width, height int) string {
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		MarginBottom(1)
 
 	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Width(15)
 
 	activeInputStyle := lipgloss.NewStyle().
@@ -165,20 +165,20 @@ func (m *BanIPModal) Render(width, height int) string {
 		Padding(0, 1)
 
 	inactiveInputStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("245")).
+		Foreground(colorMuted).
 		Padding(0, 1)
 
 	errorStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		Bold(true)
 
 	hintStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("196")).
+		BorderForeground(colorError).
 		Padding(1, 2).
 		Width(70)
 
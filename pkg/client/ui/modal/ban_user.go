@@ -1,6 +1,7 @@
 package modal
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -11,21 +12,25 @@ import (
 
 // BanUserModal handles banning a user
 type BanUserModal struct {
-	activeField  int // 0=nickname, 1=reason, 2=duration, 3=shadowban
+	activeField  int // 0=nickname, 1=reason, 2=duration, 3=channel, 4=shadowban
 	nickname     string
 	reason       string
 	duration     string // Empty for permanent, or number of seconds
+	channel      string // Empty for the whole server, or a channel name
+	channelIDs   map[string]uint64
 	shadowban    bool
 	errorMessage string
 	onSubmit     func(*protocol.BanUserMessage) tea.Cmd
 }
 
-// NewBanUserModal creates a new ban user modal
-func NewBanUserModal() *BanUserModal {
+// NewBanUserModal creates a new ban user modal. channelIDs maps the names of the
+// public channels a user can be banned from to their IDs.
+func NewBanUserModal(channelIDs map[string]uint64) *BanUserModal {
 	return &BanUserModal{
 		activeField: 0,
 		reason:      "Spam", // Default reason
 		duration:    "",     // Permanent by default
+		channelIDs:  channelIDs,
 		shadowban:   false,
 	}
 }
@@ -53,21 +58,21 @@ func (m *BanUserModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 		return true, nil, nil
 
 	case "tab", "down":
-		m.activeField = (m.activeField + 1) % 4
+		m.activeField = (m.activeField + 1) % 5
 		m.errorMessage = ""
 		return true, m, nil
 
 	case "shift+tab", "up":
-		m.activeField = (m.activeField - 1 + 4) % 4
+		m.activeField = (m.activeField - 1 + 5) % 5
 		m.errorMessage = ""
 		return true, m, nil
 
 	case "enter":
-		if m.activeField == 3 { // On shadowban field, submit
+		if m.activeField == 4 { // On shadowban field, submit
 			return m.submit()
 		}
 		// Otherwise, move to next field
-		m.activeField = (m.activeField + 1) % 4
+		m.activeField = (m.activeField + 1) % 5
 		return true, m, nil
 
 	case "ctrl+enter":
@@ -75,7 +80,7 @@ func (m *BanUserModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 		return m.submit()
 
 	case " ":
-		if m.activeField == 3 { // Shadowban checkbox
+		if m.activeField == 4 { // Shadowban checkbox
 			m.shadowban = !m.shadowban
 			return true, m, nil
 		}
@@ -95,6 +100,10 @@ func (m *BanUserModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 			if len(m.duration) > 0 {
 				m.duration = m.duration[:len(m.duration)-1]
 			}
+		case 3: // channel
+			if len(m.channel) > 0 {
+				m.channel = m.channel[:len(m.channel)-1]
+			}
 		}
 		m.errorMessage = ""
 		return true, m, nil
@@ -118,6 +127,10 @@ func (m *BanUserModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 						m.duration += msg.String()
 					}
 				}
+			case 3: // channel
+				if len(m.channel) < 64 {
+					m.channel += msg.String()
+				}
 			}
 			m.errorMessage = ""
 		}
@@ -151,6 +164,23 @@ func (m *BanUserModal) submit() (bool, Modal, tea.Cmd) {
 		durationSeconds = &seconds
 	}
 
+	// Resolve channel
+	var channelID *uint64
+	if name := strings.TrimPrefix(strings.TrimSpace(m.channel), "#"); name != "" {
+		id, ok := m.channelIDs[name]
+		if !ok {
+			m.errorMessage = fmt.Sprintf("Unknown channel #%s", name)
+			m.activeField = 3
+			return true, m, nil
+		}
+		if m.shadowban {
+			m.errorMessage = "Shadowbans apply to the whole server"
+			m.activeField = 4
+			return true, m, nil
+		}
+		channelID = &id
+	}
+
 	// Create message
 	nickname := m.nickname
 	msg := &protocol.BanUserMessage{
@@ -158,6 +188,7 @@ func (m *BanUserModal) submit() (bool, Modal, tea.Cmd) {
 		Reason:          m.reason,
 		DurationSeconds: durationSeconds,
 		Shadowban:       m.shadowban,
+		ChannelID:       channelID,
 	}
 
 	// Call submit handler if set and get the command
@@ -174,11 +205,11 @@ func (m *BanUserModal) submit() (bool, Modal, tea.Cmd) {
 func (m *BanUserModal) Render(width, height int) string {
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		MarginBottom(1)
 
 	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Width(15)
 
 	activeInputStyle := lipgloss.NewStyle().
@@ -187,25 +218,25 @@ func (m *BanUserModal) Render(width, height int) string {
 		Padding(0, 1)
 
 	inactiveInputStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("245")).
+		Foreground(colorMuted).
 		Padding(0, 1)
 
 	errorStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		Bold(true)
 
 	hintStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("196")).
+		BorderForeground(colorError).
 		Padding(1, 2).
 		Width(70)
 
 	// Build form fields
-	var nicknameField, reasonField, durationField, shadowbanField string
+	var nicknameField, reasonField, durationField, channelField, shadowbanField string
 
 	if m.activeField == 0 {
 		nicknameField = activeInputStyle.Render(m.nickname + "█")
@@ -225,11 +256,17 @@ func (m *BanUserModal) Render(width, height int) string {
 		durationField = inactiveInputStyle.Render(m.duration)
 	}
 
+	if m.activeField == 3 {
+		channelField = activeInputStyle.Render(m.channel + "█")
+	} else {
+		channelField = inactiveInputStyle.Render(m.channel)
+	}
+
 	checkbox := "[ ]"
 	if m.shadowban {
 		checkbox = "[✓]"
 	}
-	if m.activeField == 3 {
+	if m.activeField == 4 {
 		shadowbanField = activeInputStyle.Render(checkbox + " Shadowban (messages hidden from others)")
 	} else {
 		shadowbanField = inactiveInputStyle.Render(checkbox + " Shadowban (messages hidden from others)")
@@ -247,6 +284,9 @@ func (m *BanUserModal) Render(width, height int) string {
 		labelStyle.Render("Duration:")+"  "+durationField,
 		hintStyle.Render("               (seconds, leave empty for permanent)"),
 		"",
+		labelStyle.Render("Channel:")+"  "+channelField,
+		hintStyle.Render("               (leave empty to ban from the whole server)"),
+		"",
 		shadowbanField,
 	)
 
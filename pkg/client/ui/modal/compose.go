@@ -2,7 +2,9 @@ package modal
 
 import (
 	"strings"
+	"time"
 
+	"github.com/aeolun/superchat/pkg/client/commands"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -16,12 +18,22 @@ const (
 	ComposeModeEdit
 )
 
+// TypingRepeatInterval is how often TYPING_START is repeated while the user keeps typing
+const TypingRepeatInterval = 2 * time.Second
+
 // ComposeModal allows users to compose messages
 type ComposeModal struct {
-	mode      ComposeMode
-	input     string
-	onSend    func(content string) tea.Cmd
-	onCancel  func() tea.Cmd
+	mode     ComposeMode
+	input    string
+	onSend   func(content string) tea.Cmd
+	onCancel func() tea.Cmd
+	notice   string   // Shown above the instructions (e.g. when the server rate limits us)
+	sendKeys []string // Keys that send the message
+
+	// Typing indicators
+	onTyping func(typing bool) tea.Cmd
+	typingAt time.Time // When onTyping(true) was last called (zero = not typing)
+	typing   string    // Who else is typing here
 }
 
 // NewComposeModal creates a new compose modal
@@ -31,18 +43,69 @@ func NewComposeModal(mode ComposeMode, initialContent string, onSend func(string
 		input:    initialContent,
 		onSend:   onSend,
 		onCancel: onCancel,
+		sendKeys: []string{"ctrl+d", "ctrl+enter"},
 	}
 }
 
+// SetSendKeys sets the keys that send the message (from the user's key bindings)
+func (m *ComposeModal) SetSendKeys(keys []string) {
+	m.sendKeys = keys
+}
+
+// SetTypingHandler sets the callback that tells others we're typing (at most every
+// TypingRepeatInterval) or stopped (input cleared or compose cancelled)
+func (m *ComposeModal) SetTypingHandler(handler func(typing bool) tea.Cmd) {
+	m.onTyping = handler
+}
+
+// SetTyping shows who else is typing here (empty = nobody)
+func (m *ComposeModal) SetTyping(text string) {
+	m.typing = text
+}
+
+// Mode returns what the modal is composing
+func (m *ComposeModal) Mode() ComposeMode {
+	return m.mode
+}
+
 // Type returns the modal type
 func (m *ComposeModal) Type() ModalType {
 	return ModalCompose
 }
 
+// inputChanged reports typing to the typing handler after the input changed
+func (m *ComposeModal) inputChanged() tea.Cmd {
+	if m.onTyping == nil {
+		return nil
+	}
+	if len(m.input) == 0 {
+		if m.typingAt.IsZero() {
+			return nil
+		}
+		m.typingAt = time.Time{}
+		return m.onTyping(false)
+	}
+	now := time.Now()
+	if now.Sub(m.typingAt) < TypingRepeatInterval {
+		return nil
+	}
+	m.typingAt = now
+	return m.onTyping(true)
+}
+
+// SetNotice shows a notice in the modal until the next key press
+func (m *ComposeModal) SetNotice(notice string) {
+	m.notice = notice
+}
+
 // HandleKey processes keyboard input
 func (m *ComposeModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
-	switch msg.String() {
-	case "ctrl+d", "ctrl+enter":
+	m.notice = ""
+
+	for _, key := range m.sendKeys {
+		if msg.String() != key {
+			continue
+		}
 		// Send message
 		if len(m.input) == 0 {
 			// Don't send empty messages, just stay in modal
@@ -54,36 +117,42 @@ func (m *ComposeModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 			cmd = m.onSend(m.input)
 		}
 		return true, nil, cmd // Close modal
+	}
 
+	switch msg.String() {
 	case "esc":
 		// Cancel compose
 		var cmd tea.Cmd
 		if m.onCancel != nil {
 			cmd = m.onCancel()
 		}
+		if m.onTyping != nil && !m.typingAt.IsZero() {
+			cmd = tea.Batch(cmd, m.onTyping(false))
+		}
 		return true, nil, cmd // Close modal
 
 	case "backspace":
 		if len(m.input) > 0 {
 			m.input = m.input[:len(m.input)-1]
+			return true, m, m.inputChanged()
 		}
 		return true, m, nil
 
 	case "enter":
 		// Add newline
 		m.input += "\n"
-		return true, m, nil
+		return true, m, m.inputChanged()
 
 	case " ":
 		// Add space
 		m.input += " "
-		return true, m, nil
+		return true, m, m.inputChanged()
 
 	default:
 		// Handle text input
 		if msg.Type == tea.KeyRunes {
 			m.input += string(msg.Runes)
-			return true, m, nil
+			return true, m, m.inputChanged()
 		}
 
 		// Consume all other keys
@@ -95,20 +164,20 @@ func (m *ComposeModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 func (m *ComposeModal) Render(width, height int) string {
 	modalTitleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("205")).
+		Foreground(colorAccent).
 		MarginBottom(1)
 
 	inputFocusedStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("170")).
+		BorderForeground(colorHighlight).
 		Padding(0, 1)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(colorMuted)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")).
+		BorderForeground(colorAccent).
 		Padding(1, 2)
 
 	// Determine title based on mode
@@ -141,7 +210,7 @@ func (m *ComposeModal) Render(width, height int) string {
 	if m.mode == ComposeModeNewThread && len(m.input) > 0 {
 		// Use a more visible color for the thread title preview
 		titlePreviewStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("39")). // Bright blue
+			Foreground(colorPrimary). // Bright blue
 			Italic(true)
 
 		// Calculate available width for title preview (input box width - prefix "  → " - ellipsis "...")
@@ -189,7 +258,25 @@ func (m *ComposeModal) Render(width, height int) string {
 		contentSections = append(contentSections, "", estimateNote, titlePreview, titleHint)
 	}
 
-	instructions := mutedTextStyle.Render("[Ctrl+D or Ctrl+Enter] Send  [Esc] Cancel")
+	if m.typing != "" {
+		contentSections = append(contentSections, "", mutedTextStyle.Italic(true).Render(m.typing))
+	}
+
+	if m.notice != "" {
+		noticeStyle := lipgloss.NewStyle().
+			Foreground(colorError).
+			Bold(true)
+		contentSections = append(contentSections, "", noticeStyle.Render(m.notice))
+	}
+
+	sendKeys := make([]string, len(m.sendKeys))
+	for i, key := range m.sendKeys {
+		sendKeys[i] = commands.FormatKey(key)
+	}
+	instructions := mutedTextStyle.Render("[Esc] Cancel")
+	if len(sendKeys) > 0 {
+		instructions = mutedTextStyle.Render("[" + strings.Join(sendKeys, " or ") + "] Send  [Esc] Cancel")
+	}
 	contentSections = append(contentSections, "", instructions)
 
 	content := lipgloss.JoinVertical(
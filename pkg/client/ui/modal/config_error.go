@@ -129,7 +129,7 @@ func (m *ConfigErrorModal) Render(width, height int) string {
 			Render("⚠️  Backup Configuration?")
 
 		message := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("252")).
+			Foreground(colorText).
 			Align(lipgloss.Center).
 			MarginBottom(1).
 			Render("Do you want to backup the current config before resetting?")
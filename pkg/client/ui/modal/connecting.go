@@ -60,11 +60,11 @@ func (m *ConnectingModal) Render(width, height int) string {
 		Align(lipgloss.Center)
 
 	methodStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Bold(true)
 
 	addressStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true).
 		MarginBottom(1)
 
@@ -80,7 +80,7 @@ func (m *ConnectingModal) Render(width, height int) string {
 
 	// Hint
 	content += lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Render("Please wait...")
 
 	// Create border style
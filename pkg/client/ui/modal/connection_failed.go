@@ -128,19 +128,19 @@ func (m *ConnectionFailedModal) Render(width, height int) string {
 		MarginBottom(1)
 
 	serverStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true).
 		MarginBottom(1)
 
 	optionStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252"))
+		Foreground(colorText)
 
 	selectedStyle := lipgloss.NewStyle().
 		Foreground(primaryColor).
 		Bold(true)
 
 	keyHintStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(colorMuted)
 
 	// Build content
 	var content string
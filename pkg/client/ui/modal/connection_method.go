@@ -109,7 +109,7 @@ func (m *ConnectionMethodModal) Render(width, height int) string {
 		MarginBottom(1)
 
 	serverStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	errorStyle := lipgloss.NewStyle().
@@ -125,7 +125,7 @@ func (m *ConnectionMethodModal) Render(width, height int) string {
 		Padding(0, 2)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	// Build content
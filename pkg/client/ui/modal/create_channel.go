@@ -163,20 +163,20 @@ func (m *CreateChannelModal) Render(width, height int) string {
 		Render("Create New Channel")
 
 	prompt := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Align(lipgloss.Center).
 		MarginBottom(1).
 		Render("Fill in the channel details below:")
 
 	inputFocusedStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("170")).
+		BorderForeground(colorHighlight).
 		Padding(0, 1).
 		Width(50)
 
 	inputBlurredStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240")).
+		BorderForeground(colorMuted).
 		Padding(0, 1).
 		Width(50)
 
@@ -55,7 +55,7 @@ func (m *DeleteConfirmModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 func (m *DeleteConfirmModal) Render(width, height int) string {
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")).
+		BorderForeground(colorAccent).
 		Padding(1, 2)
 
 	content := "Delete this message?\n\n[y] Confirm    [n] Cancel"
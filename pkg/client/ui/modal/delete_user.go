@@ -96,15 +96,15 @@ func (m *DeleteUserModal) submit() (bool, Modal, tea.Cmd) {
 func (m *DeleteUserModal) Render(width, height int) string {
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		MarginBottom(1)
 
 	warningStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("208")).
+		Foreground(colorWarning).
 		Bold(true)
 
 	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252"))
+		Foreground(colorText)
 
 	activeInputStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("15")).
@@ -112,16 +112,16 @@ func (m *DeleteUserModal) Render(width, height int) string {
 		Padding(0, 1)
 
 	errorStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		Bold(true)
 
 	hintStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("196")).
+		BorderForeground(colorError).
 		Padding(1, 2).
 		Width(70)
 
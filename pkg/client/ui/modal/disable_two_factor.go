@@ -0,0 +1,129 @@
+package modal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DisableTwoFactorModal lets admins turn off two-factor authentication for users
+// who lost their authenticator and recovery codes
+type DisableTwoFactorModal struct {
+	nickname     string
+	errorMessage string
+	onSubmit     func(nickname string) tea.Cmd
+}
+
+// NewDisableTwoFactorModal creates a new disable 2FA modal
+func NewDisableTwoFactorModal() *DisableTwoFactorModal {
+	return &DisableTwoFactorModal{}
+}
+
+// SetSubmitHandler sets the callback for when the form is submitted
+func (m *DisableTwoFactorModal) SetSubmitHandler(handler func(nickname string) tea.Cmd) {
+	m.onSubmit = handler
+}
+
+// Type returns the modal type
+func (m *DisableTwoFactorModal) Type() ModalType {
+	return ModalDisableTwoFactor
+}
+
+// HandleKey processes keyboard input
+func (m *DisableTwoFactorModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "enter":
+		nickname := strings.TrimSpace(m.nickname)
+		if nickname == "" {
+			m.errorMessage = "Nickname is required"
+			return true, m, nil
+		}
+		var cmd tea.Cmd
+		if m.onSubmit != nil {
+			cmd = m.onSubmit(nickname)
+		}
+		return true, nil, cmd
+
+	case "backspace":
+		if len(m.nickname) > 0 {
+			m.nickname = m.nickname[:len(m.nickname)-1]
+		}
+		m.errorMessage = ""
+		return true, m, nil
+
+	default:
+		if len(msg.String()) == 1 && len(m.nickname) < 20 {
+			m.nickname += msg.String()
+			m.errorMessage = ""
+		}
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *DisableTwoFactorModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorError).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(colorText)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorError).
+		Padding(1, 2).
+		Width(70)
+
+	var errorLine string
+	if m.errorMessage != "" {
+		errorLine = "\n" + errorStyle.Render("✗ "+m.errorMessage)
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render("Disable Two-Factor Authentication"),
+		labelStyle.Render("The user can log in with just their password afterwards.\nCheck who you're talking to first."),
+		"",
+		labelStyle.Render("Nickname:"),
+		activeInputStyle.Render(m.nickname+"█"),
+		errorLine,
+		"",
+		hintStyle.Render("[Enter] Disable  [Esc] Cancel"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modal,
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *DisableTwoFactorModal) IsBlockingInput() bool {
+	return true
+}
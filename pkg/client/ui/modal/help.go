@@ -42,24 +42,24 @@ func (m *HelpModal) Render(width, height int) string {
 	// Styles
 	helpTitleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("205")).
+		Foreground(colorAccent).
 		MarginBottom(1).
 		Align(lipgloss.Center)
 
 	helpKeyStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("170")).
+		Foreground(colorHighlight).
 		Width(20)
 
 	helpDescStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252"))
+		Foreground(colorText)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")).
+		BorderForeground(colorAccent).
 		Padding(1, 2)
 
 	// Build content
@@ -141,18 +141,18 @@ func (m *ListUsersModal) Render(width, height int) string {
 		MarginBottom(1)
 
 	hintStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	onlineStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("46")).
+		Foreground(colorSuccess).
 		Bold(true)
 
 	offlineStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(colorMuted)
 
 	anonStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("208")).
+		Foreground(colorWarning).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
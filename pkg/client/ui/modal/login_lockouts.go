@@ -0,0 +1,169 @@
+package modal
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// LoginLockoutsModal lists the accounts and IP addresses locked out after failed
+// logins, and lets admins lift them
+type LoginLockoutsModal struct {
+	lockouts      []protocol.LoginLockout
+	selectedIndex int
+	loading       bool
+	onRefresh     func() tea.Cmd
+	onClear       func(*protocol.ClearLoginLockoutMessage) tea.Cmd
+}
+
+// NewLoginLockoutsModal creates the lockout list, waiting for its first LOGIN_LOCKOUT_LIST
+func NewLoginLockoutsModal(onRefresh func() tea.Cmd, onClear func(*protocol.ClearLoginLockoutMessage) tea.Cmd) *LoginLockoutsModal {
+	return &LoginLockoutsModal{
+		loading:   true,
+		onRefresh: onRefresh,
+		onClear:   onClear,
+	}
+}
+
+// SetLockouts sets the lockout list
+func (m *LoginLockoutsModal) SetLockouts(lockouts []protocol.LoginLockout) {
+	m.lockouts = lockouts
+	m.loading = false
+	if m.selectedIndex >= len(lockouts) {
+		m.selectedIndex = max(len(lockouts)-1, 0)
+	}
+}
+
+// Refresh reloads the list, e.g. after a lockout was cleared
+func (m *LoginLockoutsModal) Refresh() tea.Cmd {
+	m.loading = true
+	if m.onRefresh == nil {
+		return nil
+	}
+	return m.onRefresh()
+}
+
+// Type returns the modal type
+func (m *LoginLockoutsModal) Type() ModalType {
+	return ModalLoginLockouts
+}
+
+// HandleKey processes keyboard input
+func (m *LoginLockoutsModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.lockouts)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "c", "enter":
+		if m.loading || m.selectedIndex >= len(m.lockouts) || m.onClear == nil {
+			return true, m, nil
+		}
+		lockout := m.lockouts[m.selectedIndex]
+		return true, m, m.onClear(&protocol.ClearLoginLockoutMessage{
+			Kind:       lockout.Kind,
+			Identifier: lockout.Identifier,
+		})
+
+	case "r":
+		return true, m, m.Refresh()
+
+	default:
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *LoginLockoutsModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorError).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("196")).
+		Bold(true).
+		Padding(0, 1)
+
+	unselectedStyle := lipgloss.NewStyle().
+		Foreground(colorText).
+		Padding(0, 1)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorError).
+		Padding(1, 2).
+		Width(80).
+		Height(min(height-4, 30))
+
+	var lines []string
+	if m.loading {
+		lines = append(lines, hintStyle.Render("Loading..."))
+	} else if len(m.lockouts) == 0 {
+		lines = append(lines, hintStyle.Render("Nobody is locked out"))
+	} else {
+		for i, lockout := range m.lockouts {
+			target := "User: " + lockout.Identifier
+			if lockout.Kind == "ip" {
+				target = "IP: " + lockout.Identifier
+			}
+			line := fmt.Sprintf("%s | %d failures | Last %s | Until %s",
+				target,
+				lockout.Failures,
+				lockout.LastFailureAt.Format("2006-01-02 15:04"),
+				lockout.LockedUntil.Format(time.TimeOnly))
+
+			if i == m.selectedIndex {
+				lines = append(lines, selectedStyle.Render(line))
+			} else {
+				lines = append(lines, unselectedStyle.Render(line))
+			}
+		}
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render("Login Lockouts"),
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		hintStyle.Render("[c/Enter] Clear lockout  [r] Refresh  [↑/↓] Navigate  [Esc/q] Close"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modal,
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *LoginLockoutsModal) IsBlockingInput() bool {
+	return true
+}
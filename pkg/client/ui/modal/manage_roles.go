@@ -0,0 +1,268 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// roleOptions are the roles an admin can grant, in the order the selector cycles
+var roleOptions = []struct {
+	label string
+	flags protocol.UserFlags
+}{
+	{"User", 0},
+	{"Moderator", protocol.UserFlagModerator},
+	{"Admin", protocol.UserFlagAdmin},
+}
+
+// ManageRolesModal lets admins make users moderators or admins
+type ManageRolesModal struct {
+	activeField  int // 0=nickname, 1=role, 2=channels
+	nickname     string
+	role         int    // Index into roleOptions
+	channels     string // Comma-separated channel names (moderators only)
+	channelIDs   map[string]uint64
+	errorMessage string
+	onSubmit     func(*protocol.SetUserFlagsMessage) tea.Cmd
+}
+
+// NewManageRolesModal creates a new manage roles modal. channelIDs maps the names
+// of the public channels a moderator can be limited to to their IDs.
+func NewManageRolesModal(channelIDs map[string]uint64) *ManageRolesModal {
+	return &ManageRolesModal{
+		role:       1, // Moderator is the common case
+		channelIDs: channelIDs,
+	}
+}
+
+// SetSubmitHandler sets the callback for when the form is submitted
+func (m *ManageRolesModal) SetSubmitHandler(handler func(*protocol.SetUserFlagsMessage) tea.Cmd) {
+	m.onSubmit = handler
+}
+
+// Type returns the modal type
+func (m *ManageRolesModal) Type() ModalType {
+	return ModalManageRoles
+}
+
+// fieldCount is the number of fields; channels only apply to moderators
+func (m *ManageRolesModal) fieldCount() int {
+	if roleOptions[m.role].flags.IsModerator() {
+		return 3
+	}
+	return 2
+}
+
+// HandleKey processes keyboard input
+func (m *ManageRolesModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// Close modal and return to admin panel
+		return true, nil, nil
+
+	case "tab", "down":
+		m.activeField = (m.activeField + 1) % m.fieldCount()
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "shift+tab", "up":
+		m.activeField = (m.activeField - 1 + m.fieldCount()) % m.fieldCount()
+		m.errorMessage = ""
+		return true, m, nil
+
+	case "enter":
+		if m.activeField == m.fieldCount()-1 { // On the last field, submit
+			return m.submit()
+		}
+		m.activeField++
+		return true, m, nil
+
+	case "ctrl+enter":
+		// Submit from any field
+		return m.submit()
+
+	case "left", "right", " ":
+		if m.activeField == 1 { // Role selector
+			if msg.String() == "left" {
+				m.role = (m.role - 1 + len(roleOptions)) % len(roleOptions)
+			} else {
+				m.role = (m.role + 1) % len(roleOptions)
+			}
+			m.errorMessage = ""
+			return true, m, nil
+		}
+		if msg.String() == " " && m.activeField == 2 {
+			m.channels += " "
+		}
+		return true, m, nil
+
+	case "backspace":
+		switch m.activeField {
+		case 0:
+			if len(m.nickname) > 0 {
+				m.nickname = m.nickname[:len(m.nickname)-1]
+			}
+		case 2:
+			if len(m.channels) > 0 {
+				m.channels = m.channels[:len(m.channels)-1]
+			}
+		}
+		m.errorMessage = ""
+		return true, m, nil
+
+	default:
+		// Type into active field
+		if len(msg.String()) == 1 {
+			switch m.activeField {
+			case 0:
+				if len(m.nickname) < 20 {
+					m.nickname += msg.String()
+				}
+			case 2:
+				if len(m.channels) < 200 {
+					m.channels += msg.String()
+				}
+			}
+			m.errorMessage = ""
+		}
+		return true, m, nil
+	}
+}
+
+func (m *ManageRolesModal) submit() (bool, Modal, tea.Cmd) {
+	nickname := strings.TrimSpace(m.nickname)
+	if nickname == "" {
+		m.errorMessage = "Nickname is required"
+		m.activeField = 0
+		return true, m, nil
+	}
+
+	flags := roleOptions[m.role].flags
+	var channels []uint64
+	if flags.IsModerator() {
+		for _, name := range strings.Split(m.channels, ",") {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+			if name == "" {
+				continue
+			}
+			id, ok := m.channelIDs[name]
+			if !ok {
+				m.errorMessage = fmt.Sprintf("Unknown channel #%s", name)
+				m.activeField = 2
+				return true, m, nil
+			}
+			channels = append(channels, id)
+		}
+	}
+
+	msg := &protocol.SetUserFlagsMessage{
+		Nickname:          &nickname,
+		Flags:             flags,
+		ModeratorChannels: channels,
+	}
+
+	var cmd tea.Cmd
+	if m.onSubmit != nil {
+		cmd = m.onSubmit(msg)
+	}
+
+	// Close modal and return the command to be executed
+	return true, nil, cmd
+}
+
+// Render returns the modal content
+func (m *ManageRolesModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorError).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(colorText).
+		Width(15)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	inactiveInputStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorError).
+		Padding(1, 2).
+		Width(70)
+
+	field := func(index int, value string) string {
+		if m.activeField == index {
+			return activeInputStyle.Render(value + "█")
+		}
+		return inactiveInputStyle.Render(value)
+	}
+
+	roleField := "◂ " + roleOptions[m.role].label + " ▸"
+	if m.activeField == 1 {
+		roleField = activeInputStyle.Render(roleField)
+	} else {
+		roleField = inactiveInputStyle.Render(roleField)
+	}
+
+	lines := []string{
+		labelStyle.Render("Nickname:") + "  " + field(0, m.nickname),
+		"",
+		labelStyle.Render("Role:") + "  " + roleField,
+	}
+	if roleOptions[m.role].flags.IsModerator() {
+		lines = append(lines,
+			"",
+			labelStyle.Render("Channels:")+"  "+field(2, m.channels),
+			hintStyle.Render("               (comma-separated, leave empty for all channels)"),
+		)
+	}
+	form := lipgloss.JoinVertical(lipgloss.Left, lines...)
+
+	var errorLine string
+	if m.errorMessage != "" {
+		errorLine = "\n" + errorStyle.Render("✗ "+m.errorMessage) + "\n"
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render("Manage Roles"),
+		"",
+		form,
+		errorLine,
+		hintStyle.Render("[Tab] Next field  [←/→] Change role  [Ctrl+Enter] Submit  [Esc] Cancel"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modal,
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *ManageRolesModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,199 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// MentionEntry is a mention with the name of the channel it was made in
+type MentionEntry struct {
+	Mention  protocol.Mention
+	Location string
+}
+
+// MentionsModal is the inbox of messages that mention the user
+type MentionsModal struct {
+	entries       []MentionEntry
+	unreadCount   uint32
+	selectedIndex int
+	loading       bool
+	onOpen        func(protocol.Mention) tea.Cmd
+	onMarkRead    func(messageIDs []uint64) tea.Cmd // Empty = all
+}
+
+// NewMentionsModal creates a new mentions inbox, waiting for its first MENTION_LIST
+func NewMentionsModal(onOpen func(protocol.Mention) tea.Cmd, onMarkRead func([]uint64) tea.Cmd) *MentionsModal {
+	return &MentionsModal{
+		loading:    true,
+		onOpen:     onOpen,
+		onMarkRead: onMarkRead,
+	}
+}
+
+// SetMentions shows the user's mentions
+func (m *MentionsModal) SetMentions(entries []MentionEntry, unreadCount uint32) {
+	m.entries = entries
+	m.unreadCount = unreadCount
+	m.loading = false
+	if m.selectedIndex >= len(entries) {
+		m.selectedIndex = max(len(entries)-1, 0)
+	}
+}
+
+// Type returns the modal type
+func (m *MentionsModal) Type() ModalType {
+	return ModalMentions
+}
+
+// HandleKey processes keyboard input
+func (m *MentionsModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		return true, nil, nil // Close modal
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.entries)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "enter":
+		if len(m.entries) == 0 {
+			return true, m, nil
+		}
+		var cmd tea.Cmd
+		if m.onOpen != nil {
+			cmd = m.onOpen(m.entries[m.selectedIndex].Mention)
+		}
+		return true, nil, cmd // Close modal
+
+	case "r":
+		// Mark the selected mention read
+		if len(m.entries) == 0 || m.entries[m.selectedIndex].Mention.Read {
+			return true, m, nil
+		}
+		entry := &m.entries[m.selectedIndex]
+		entry.Mention.Read = true
+		if m.unreadCount > 0 {
+			m.unreadCount--
+		}
+		var cmd tea.Cmd
+		if m.onMarkRead != nil {
+			cmd = m.onMarkRead([]uint64{entry.Mention.Message.ID})
+		}
+		return true, m, cmd
+
+	case "R":
+		// Mark everything read
+		for i := range m.entries {
+			m.entries[i].Mention.Read = true
+		}
+		m.unreadCount = 0
+		var cmd tea.Cmd
+		if m.onMarkRead != nil {
+			cmd = m.onMarkRead(nil)
+		}
+		return true, m, cmd
+
+	default:
+		// Consume all other keys
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *MentionsModal) Render(width, height int) string {
+	modalTitleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorAccent).
+		MarginBottom(1)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(colorMuted)
+
+	locationStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("75"))
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(colorAccent).
+		Bold(true)
+
+	unreadStyle := lipgloss.NewStyle().
+		Foreground(colorSuccess).
+		Bold(true)
+
+	modalWidth := min(width-4, 90)
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorAccent).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	innerWidth := modalWidth - 6
+	title := modalTitleStyle.Render(fmt.Sprintf("Mentions (%d unread)", m.unreadCount))
+
+	var lines []string
+	switch {
+	case m.loading:
+		lines = append(lines, mutedTextStyle.Render("Loading..."))
+	case len(m.entries) == 0:
+		lines = append(lines, mutedTextStyle.Render("Nobody has mentioned you yet"))
+	}
+
+	// Each mention takes two lines; show a window around the selection
+	visible := max((height-14)/2, 3)
+	start := 0
+	if m.selectedIndex >= visible {
+		start = m.selectedIndex - visible + 1
+	}
+	end := min(start+visible, len(m.entries))
+	for i := start; i < end; i++ {
+		entry := m.entries[i]
+		msg := entry.Mention.Message
+
+		prefix := "  "
+		if i == m.selectedIndex {
+			prefix = selectedStyle.Render("▶ ")
+		}
+		marker := "  "
+		if !entry.Mention.Read {
+			marker = unreadStyle.Render("● ")
+		}
+		header := fmt.Sprintf("%s%s  %s  %s", marker, locationStyle.Render(entry.Location), msg.AuthorNickname, mutedTextStyle.Render(msg.CreatedAt.Format("2006-01-02 15:04")))
+		snippet := strings.Join(strings.Fields(msg.Content), " ")
+		snippetWidth := max(innerWidth-6, 10)
+		if runes := []rune(snippet); len(runes) > snippetWidth {
+			snippet = string(runes[:snippetWidth-1]) + "…"
+		}
+		lines = append(lines, prefix+header, "      "+snippet)
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		mutedTextStyle.Render("[Enter] Open  [r] Mark read  [R] Mark all read  [↑/↓] Navigate  [ESC] Close"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *MentionsModal) IsBlockingInput() bool {
+	return true
+}
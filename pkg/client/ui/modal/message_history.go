@@ -0,0 +1,256 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// MessageHistoryModal shows the revisions of an edited message, each as a diff
+// against the revision before it
+type MessageHistoryModal struct {
+	messageID     uint64
+	revisions     []protocol.MessageRevision // Oldest first
+	selectedIndex int
+	loading       bool
+	errorMessage  string
+}
+
+// NewMessageHistoryModal creates a history view, waiting for its MESSAGE_HISTORY
+func NewMessageHistoryModal(messageID uint64) *MessageHistoryModal {
+	return &MessageHistoryModal{
+		messageID: messageID,
+		loading:   true,
+	}
+}
+
+// MessageID returns the message whose history is shown
+func (m *MessageHistoryModal) MessageID() uint64 {
+	return m.messageID
+}
+
+// Loading returns true while the history hasn't arrived
+func (m *MessageHistoryModal) Loading() bool {
+	return m.loading
+}
+
+// SetRevisions shows the message's revisions, selecting the latest edit
+func (m *MessageHistoryModal) SetRevisions(revisions []protocol.MessageRevision) {
+	m.revisions = revisions
+	m.loading = false
+	m.selectedIndex = max(len(revisions)-1, 0)
+}
+
+// SetError shows an error instead of the history
+func (m *MessageHistoryModal) SetError(message string) {
+	m.errorMessage = message
+	m.loading = false
+}
+
+// Type returns the modal type
+func (m *MessageHistoryModal) Type() ModalType {
+	return ModalMessageHistory
+}
+
+// HandleKey processes keyboard input
+func (m *MessageHistoryModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		return true, nil, nil // Close modal
+
+	case "up", "k":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down", "j":
+		if m.selectedIndex < len(m.revisions)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	default:
+		// Consume all other keys
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *MessageHistoryModal) Render(width, height int) string {
+	modalTitleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorAccent).
+		MarginBottom(1)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(colorMuted)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(colorAccent).
+		Bold(true)
+
+	adminStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError)
+
+	removedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("203"))
+
+	addedStyle := lipgloss.NewStyle().
+		Foreground(colorSuccess)
+
+	modalWidth := min(width-4, 90)
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorAccent).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	innerWidth := modalWidth - 6
+	title := modalTitleStyle.Render("Edit History")
+
+	var lines []string
+	switch {
+	case m.errorMessage != "":
+		lines = append(lines, errorStyle.Render(m.errorMessage))
+	case m.loading:
+		lines = append(lines, mutedTextStyle.Render("Loading..."))
+	case len(m.revisions) == 0:
+		lines = append(lines, mutedTextStyle.Render("This message has no history"))
+	}
+
+	// Revision list, one line each
+	for i, revision := range m.revisions {
+		prefix := "  "
+		if i == m.selectedIndex {
+			prefix = selectedStyle.Render("▶ ")
+		}
+		label := "edited by"
+		if i == 0 {
+			label = "posted by"
+		}
+		line := fmt.Sprintf("%s%s %s  %s", prefix, label, revision.EditorNickname, mutedTextStyle.Render(revision.EditedAt.Format("2006-01-02 15:04:05")))
+		if revision.AdminEdit {
+			line += "  " + adminStyle.Render("[admin]")
+		}
+		lines = append(lines, line)
+	}
+
+	// Diff of the selected revision against the one before it
+	if m.selectedIndex < len(m.revisions) {
+		lines = append(lines, "")
+		current := m.revisions[m.selectedIndex].Content
+		if m.selectedIndex == 0 {
+			lines = append(lines, mutedTextStyle.Render("Original message:"))
+			for _, line := range strings.Split(current, "\n") {
+				lines = append(lines, "  "+truncateLine(line, innerWidth-2))
+			}
+		} else {
+			lines = append(lines, mutedTextStyle.Render("Changes in this edit:"))
+			previous := m.revisions[m.selectedIndex-1].Content
+			for _, d := range diffLines(previous, current) {
+				text := truncateLine(d.text, innerWidth-2)
+				switch d.op {
+				case '-':
+					lines = append(lines, removedStyle.Render("- "+text))
+				case '+':
+					lines = append(lines, addedStyle.Render("+ "+text))
+				default:
+					lines = append(lines, "  "+text)
+				}
+			}
+		}
+	}
+
+	// Keep the modal on screen for long messages
+	if maxLines := max(height-12, 5); len(lines) > maxLines {
+		lines = append(lines[:maxLines-1], mutedTextStyle.Render("…"))
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		mutedTextStyle.Render("[↑/↓] Select revision  [ESC] Close"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *MessageHistoryModal) IsBlockingInput() bool {
+	return true
+}
+
+// diffLine is one line of a line diff: '-' removed, '+' added, ' ' unchanged
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// diffLines returns a line diff from before to after, based on their longest common
+// subsequence. Messages are short, so the quadratic table is fine.
+func diffLines(before, after string) []diffLine {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	// lcs[i][j] = length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, diffLine{'-', a[i]})
+			i++
+		default:
+			diff = append(diff, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, diffLine{'+', b[j]})
+	}
+	return diff
+}
+
+// truncateLine shortens a line to width runes
+func truncateLine(line string, width int) string {
+	width = max(width, 10)
+	if runes := []rune(line); len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return line
+}
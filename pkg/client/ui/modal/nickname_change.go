@@ -80,24 +80,24 @@ func (m *NicknameChangeModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 func (m *NicknameChangeModal) Render(width, height int) string {
 	modalTitleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("205")).
+		Foreground(colorAccent).
 		MarginBottom(1)
 
 	inputFocusedStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("170")).
+		BorderForeground(colorHighlight).
 		Padding(0, 1)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(colorMuted)
 
 	errorStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		Bold(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")).
+		BorderForeground(colorAccent).
 		Padding(1, 2)
 
 	// Build content
@@ -77,24 +77,24 @@ func (m *NicknameSetupModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 func (m *NicknameSetupModal) Render(width, height int) string {
 	modalTitleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("205")).
+		Foreground(colorAccent).
 		MarginBottom(1)
 
 	inputFocusedStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("170")).
+		BorderForeground(colorHighlight).
 		Padding(0, 1)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(colorMuted)
 
 	errorStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		Bold(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")).
+		BorderForeground(colorAccent).
 		Padding(1, 2)
 
 	// Build content
@@ -107,7 +107,7 @@ func (m *NicknameSetupModal) Render(width, height int) string {
 	// Helper text with validation rules and character count
 	charCountText := fmt.Sprintf("Characters: %d/20", len(m.input))
 	helperText := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Render(lipgloss.JoinVertical(
 			lipgloss.Left,
 			"Allowed: letters, numbers, - and _",
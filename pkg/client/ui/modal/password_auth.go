@@ -125,7 +125,7 @@ func (m *PasswordAuthModal) Render(width, height int) string {
 		Render(fmt.Sprintf("🔐 Authenticate as '%s'", m.nickname))
 
 	prompt := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Align(lipgloss.Left).
 		MarginBottom(1).
 		Render("This nickname is registered. Enter password:")
@@ -133,7 +133,7 @@ func (m *PasswordAuthModal) Render(width, height int) string {
 	// Password input (hidden) - fixed width
 	inputFocusedStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("170")).
+		BorderForeground(colorHighlight).
 		Padding(0, 1).
 		Width(40)
 
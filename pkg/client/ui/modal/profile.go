@@ -0,0 +1,390 @@
+package modal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Profile field limits (in characters), the same as the server's
+const (
+	maxDisplayNameLength = 32
+	maxPronounsLength    = 20
+	maxStatusLength      = 64
+	maxBioLength         = 500
+)
+
+// profileStep is what the profile modal shows
+type profileStep int
+
+const (
+	profileList    profileStep = iota // Users from the sidebar
+	profileLoading                    // Waiting for USER_INFO
+	profileView                       // One user's profile
+	profileEdit                       // Editing your own profile
+)
+
+// ProfileUser is a user the profile modal can show
+type ProfileUser struct {
+	Nickname     string
+	IsRegistered bool
+	Away         bool
+	Self         bool
+}
+
+// ProfileModal shows the profiles of the users in the sidebar, and lets registered
+// users edit their own
+type ProfileModal struct {
+	step          profileStep
+	users         []ProfileUser
+	selectedIndex int
+	info          *protocol.UserInfoMessage
+	editAfterLoad bool // Open the editor once our own profile arrives
+
+	// Editor
+	fields       [4]string // Display name, pronouns, status, bio
+	focusIndex   int
+	saving       bool
+	errorMessage string
+
+	selfRegistered bool
+	onView         func(nickname string) tea.Cmd
+	onSave         func(profile *protocol.SetProfileMessage) tea.Cmd
+}
+
+// NewProfileModal creates the profile modal for a list of users
+func NewProfileModal(
+	users []ProfileUser,
+	selfRegistered bool,
+	onView func(nickname string) tea.Cmd,
+	onSave func(profile *protocol.SetProfileMessage) tea.Cmd,
+) *ProfileModal {
+	return &ProfileModal{
+		users:          users,
+		selfRegistered: selfRegistered,
+		onView:         onView,
+		onSave:         onSave,
+	}
+}
+
+// SetUserInfo shows a user's profile, if it's the one the modal is waiting for
+func (m *ProfileModal) SetUserInfo(info *protocol.UserInfoMessage) {
+	if m.step != profileLoading || m.selectedIndex >= len(m.users) || m.users[m.selectedIndex].Nickname != info.Nickname {
+		return
+	}
+	m.info = info
+	m.step = profileView
+	if m.editAfterLoad {
+		m.editAfterLoad = false
+		m.startEditing()
+	}
+}
+
+// SetSaved shows the result of SET_PROFILE
+func (m *ProfileModal) SetSaved(success bool, message string) {
+	if !m.saving {
+		return
+	}
+	m.saving = false
+	if !success {
+		m.errorMessage = message
+		return
+	}
+	if m.info != nil {
+		m.info.DisplayName = strings.TrimSpace(m.fields[0])
+		m.info.Pronouns = strings.TrimSpace(m.fields[1])
+		m.info.Status = strings.TrimSpace(m.fields[2])
+		m.info.Bio = strings.TrimSpace(m.fields[3])
+	}
+	m.errorMessage = ""
+	m.step = profileView
+}
+
+// Type returns the modal type
+func (m *ProfileModal) Type() ModalType {
+	return ModalProfile
+}
+
+// canEdit reports whether the profile on screen is ours to edit
+func (m *ProfileModal) canEdit() bool {
+	return m.selfRegistered && m.selectedIndex < len(m.users) && m.users[m.selectedIndex].Self
+}
+
+// load asks for the selected user's profile
+func (m *ProfileModal) load() tea.Cmd {
+	m.step = profileLoading
+	m.info = nil
+	m.errorMessage = ""
+	if m.onView == nil {
+		return nil
+	}
+	return m.onView(m.users[m.selectedIndex].Nickname)
+}
+
+func (m *ProfileModal) startEditing() {
+	m.fields = [4]string{m.info.DisplayName, m.info.Pronouns, m.info.Status, m.info.Bio}
+	m.focusIndex = 0
+	m.errorMessage = ""
+	m.step = profileEdit
+}
+
+// HandleKey processes keyboard input
+func (m *ProfileModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	key := msg.String()
+
+	switch m.step {
+	case profileList:
+		switch key {
+		case "esc", "q":
+			return true, nil, nil
+		case "up", "k":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+			}
+		case "down", "j":
+			if m.selectedIndex < len(m.users)-1 {
+				m.selectedIndex++
+			}
+		case "enter":
+			if len(m.users) > 0 {
+				return true, m, m.load()
+			}
+		case "e":
+			// Jump to our own profile and edit it
+			for i, user := range m.users {
+				if user.Self && m.selfRegistered {
+					m.selectedIndex = i
+					m.editAfterLoad = true
+					return true, m, m.load()
+				}
+			}
+		}
+		return true, m, nil
+
+	case profileLoading:
+		if key == "esc" {
+			m.step = profileList
+			m.editAfterLoad = false
+		}
+		return true, m, nil
+
+	case profileView:
+		switch key {
+		case "esc", "q", "backspace":
+			m.step = profileList
+		case "e":
+			if m.canEdit() {
+				m.startEditing()
+			}
+		}
+		return true, m, nil
+
+	case profileEdit:
+		if m.saving {
+			return true, m, nil
+		}
+		switch key {
+		case "esc":
+			m.errorMessage = ""
+			m.step = profileView
+		case "tab", "down":
+			m.focusIndex = (m.focusIndex + 1) % len(m.fields)
+		case "shift+tab", "up":
+			m.focusIndex = (m.focusIndex + len(m.fields) - 1) % len(m.fields)
+		case "enter":
+			m.saving = true
+			m.errorMessage = ""
+			if m.onSave == nil {
+				return true, m, nil
+			}
+			return true, m, m.onSave(&protocol.SetProfileMessage{
+				DisplayName: strings.TrimSpace(m.fields[0]),
+				Pronouns:    strings.TrimSpace(m.fields[1]),
+				Status:      strings.TrimSpace(m.fields[2]),
+				Bio:         strings.TrimSpace(m.fields[3]),
+			})
+		case "backspace":
+			if runes := []rune(m.fields[m.focusIndex]); len(runes) > 0 {
+				m.fields[m.focusIndex] = string(runes[:len(runes)-1])
+			}
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				limits := [4]int{maxDisplayNameLength, maxPronounsLength, maxStatusLength, maxBioLength}
+				value := m.fields[m.focusIndex] + string(msg.Runes)
+				if len([]rune(value)) <= limits[m.focusIndex] {
+					m.fields[m.focusIndex] = value
+				}
+			}
+		}
+		return true, m, nil
+	}
+
+	return true, m, nil
+}
+
+// Render returns the modal content
+func (m *ProfileModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorPrimary).
+		MarginBottom(1)
+
+	textStyle := lipgloss.NewStyle().
+		Foreground(colorText)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Width(14)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Bold(true)
+
+	inputStyle := lipgloss.NewStyle().
+		Foreground(colorText).
+		Background(lipgloss.Color("236")).
+		Padding(0, 1)
+
+	activeInputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorPrimary).
+		Padding(1, 2).
+		Width(70)
+
+	title := "Profiles"
+	var lines []string
+	var hint string
+
+	switch m.step {
+	case profileList:
+		if len(m.users) == 0 {
+			lines = append(lines, hintStyle.Render("No users"))
+		}
+		// Keep the selection on screen
+		visible := max(height-12, 5)
+		start := max(0, min(m.selectedIndex-visible/2, len(m.users)-visible))
+		end := min(len(m.users), start+visible)
+		for i := start; i < end; i++ {
+			user := m.users[i]
+			name := user.Nickname
+			if !user.IsRegistered {
+				name = "~" + name
+			}
+			if user.Self {
+				name += " (you)"
+			}
+			if user.Away {
+				name += " (away)"
+			}
+			if i == m.selectedIndex {
+				lines = append(lines, selectedStyle.Render("▶ "+name))
+			} else {
+				lines = append(lines, textStyle.Render("  "+name))
+			}
+		}
+		hint = "[↑/↓] Select  [Enter] View"
+		if m.selfRegistered {
+			hint += "  [E] Edit yours"
+		}
+		hint += "  [Esc] Close"
+
+	case profileLoading:
+		lines = append(lines, hintStyle.Render("Loading profile..."))
+		hint = "[Esc] Back"
+
+	case profileView:
+		info := m.info
+		title = info.Nickname
+		if info.DisplayName != "" {
+			title = info.DisplayName + " (" + info.Nickname + ")"
+		}
+		state := "Offline"
+		switch {
+		case info.Online && info.Away:
+			state = "Away"
+		case info.Online:
+			state = "Online"
+		}
+		if !info.IsRegistered {
+			state += ", anonymous"
+		}
+		lines = append(lines, labelStyle.Render("Status")+textStyle.Render(state))
+		if info.Status != "" {
+			lines = append(lines, labelStyle.Render("")+textStyle.Render(info.Status))
+		}
+		if info.Pronouns != "" {
+			lines = append(lines, labelStyle.Render("Pronouns")+textStyle.Render(info.Pronouns))
+		}
+		if info.Bio != "" {
+			lines = append(lines, "", textStyle.Width(64).Render(info.Bio))
+		}
+		if info.IsRegistered && info.DisplayName == "" && info.Pronouns == "" && info.Bio == "" && info.Status == "" {
+			lines = append(lines, "", hintStyle.Render("No profile set"))
+		}
+		hint = "[Esc] Back"
+		if m.canEdit() {
+			hint = "[E] Edit  " + hint
+		}
+
+	case profileEdit:
+		title = "Edit Profile"
+		labels := [4]string{"Display name", "Pronouns", "Status", "Bio"}
+		for i, label := range labels {
+			value := m.fields[i]
+			style := inputStyle
+			if i == m.focusIndex {
+				style = activeInputStyle
+				if !m.saving {
+					value += "█"
+				}
+			}
+			lines = append(lines, labelStyle.Render(label)+style.Width(50).Render(value))
+		}
+		hint = "[Tab] Next field  [Enter] Save  [Esc] Cancel"
+		if m.saving {
+			hint = "Saving..."
+		}
+	}
+
+	if m.errorMessage != "" {
+		lines = append(lines, "", errorStyle.Render("✗ "+m.errorMessage))
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render(title),
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		hintStyle.Render(hint),
+	)
+
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(content),
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *ProfileModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,135 @@
+package modal
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// QuickReactions are the emoji offered by the reaction picker
+var QuickReactions = []string{"👍", "👎", "❤️", "😂", "🎉", "😮", "😢", "👀"}
+
+// ReactionPickerModal lets the user toggle a reaction on a message
+type ReactionPickerModal struct {
+	messageID     uint64
+	reacted       map[string]bool // Emoji the user already reacted with
+	selectedIndex int
+	onToggle      func(messageID uint64, emoji string, remove bool) tea.Cmd
+}
+
+// NewReactionPickerModal creates a new reaction picker for a message
+func NewReactionPickerModal(messageID uint64, reacted map[string]bool, onToggle func(uint64, string, bool) tea.Cmd) *ReactionPickerModal {
+	return &ReactionPickerModal{
+		messageID: messageID,
+		reacted:   reacted,
+		onToggle:  onToggle,
+	}
+}
+
+// Type returns the modal type
+func (m *ReactionPickerModal) Type() ModalType {
+	return ModalReactionPicker
+}
+
+// HandleKey processes keyboard input
+func (m *ReactionPickerModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return true, nil, nil // Close modal
+
+	case "left", "h":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "right", "l":
+		if m.selectedIndex < len(QuickReactions)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case "enter", " ":
+		return true, nil, m.toggle(QuickReactions[m.selectedIndex])
+
+	default:
+		// Number keys pick an emoji directly
+		if len(msg.Runes) == 1 && msg.Runes[0] >= '1' && int(msg.Runes[0]-'1') < len(QuickReactions) {
+			return true, nil, m.toggle(QuickReactions[msg.Runes[0]-'1'])
+		}
+		// Consume all other keys
+		return true, m, nil
+	}
+}
+
+// toggle adds the reaction, or removes it if the user already reacted with it
+func (m *ReactionPickerModal) toggle(emoji string) tea.Cmd {
+	if m.onToggle == nil {
+		return nil
+	}
+	return m.onToggle(m.messageID, emoji, m.reacted[emoji])
+}
+
+// Render returns the modal content
+func (m *ReactionPickerModal) Render(width, height int) string {
+	modalTitleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorAccent).
+		MarginBottom(1)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(colorMuted)
+
+	selectedStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorAccent).
+		Padding(0, 1)
+
+	reactedStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("75")).
+		Padding(0, 1)
+
+	plainStyle := lipgloss.NewStyle().
+		Border(lipgloss.HiddenBorder()).
+		Padding(0, 1)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorAccent).
+		Padding(1, 2)
+
+	cells := make([]string, len(QuickReactions))
+	for i, emoji := range QuickReactions {
+		cell := fmt.Sprintf("%s\n%s", emoji, mutedTextStyle.Render(fmt.Sprintf("%d", i+1)))
+		switch {
+		case i == m.selectedIndex:
+			cells[i] = selectedStyle.Render(cell)
+		case m.reacted[emoji]:
+			cells[i] = reactedStyle.Render(cell)
+		default:
+			cells[i] = plainStyle.Render(cell)
+		}
+	}
+
+	hint := "Reactions you already added are highlighted; picking one removes it"
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		modalTitleStyle.Render("React"),
+		lipgloss.JoinHorizontal(lipgloss.Top, cells...),
+		mutedTextStyle.Render(hint),
+		"",
+		mutedTextStyle.Render("[←/→] Navigate  [Enter/1-8] Toggle  [ESC] Close"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *ReactionPickerModal) IsBlockingInput() bool {
+	return true
+}
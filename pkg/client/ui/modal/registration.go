@@ -141,7 +141,7 @@ func (m *RegistrationModal) Render(width, height int) string {
 		Render(fmt.Sprintf("📝 Register '%s'", m.nickname))
 
 	prompt := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Align(lipgloss.Center).
 		Render("Choose a password:")
 
@@ -154,12 +154,12 @@ func (m *RegistrationModal) Render(width, height int) string {
 
 	inputFocusedStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("170")).
+		BorderForeground(colorHighlight).
 		Padding(0, 1)
 
 	inputBlurredStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240")).
+		BorderForeground(colorMuted).
 		Padding(0, 1)
 
 	// Password input (hidden)
@@ -114,19 +114,19 @@ func (m *RegistrationWarningModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.C
 func (m *RegistrationWarningModal) Render(width, height int) string {
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")).
+		BorderForeground(colorAccent).
 		Padding(1, 2)
 
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("205"))
+		Foreground(colorAccent)
 
 	selectedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
+		Foreground(colorAccent).
 		Bold(true)
 
 	unselectedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240"))
+		Foreground(colorMuted)
 
 	title := titleStyle.Render("Post Anonymously?")
 
@@ -152,7 +152,7 @@ func (m *RegistrationWarningModal) Render(width, height int) string {
 	}
 
 	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Render("\n[↑/↓] Navigate  [Enter] Select  [1-4] Quick select  [Esc] Cancel")
 
 	content := title + "\n\n" + message + "\n\n" +
@@ -0,0 +1,229 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// maxSearchInputLength matches the server's limit on SEARCH_MESSAGES queries
+const maxSearchInputLength = 256
+
+// SearchResultEntry is a search result with the name of the channel it was found in
+type SearchResultEntry struct {
+	Result   protocol.SearchResult
+	Location string
+}
+
+// SearchModal searches messages and lists the results
+type SearchModal struct {
+	input         string
+	lastQuery     string // Input the current results are for
+	results       []SearchResultEntry
+	selectedIndex int
+	searching     bool
+	errorMessage  string
+	onSearch      func(input string) tea.Cmd
+	onSelect      func(protocol.SearchResult) tea.Cmd
+}
+
+// NewSearchModal creates a new search modal
+func NewSearchModal(initialInput string, onSearch func(string) tea.Cmd, onSelect func(protocol.SearchResult) tea.Cmd) *SearchModal {
+	return &SearchModal{
+		input:    initialInput,
+		onSearch: onSearch,
+		onSelect: onSelect,
+	}
+}
+
+// SetResults shows the results of the last search
+func (m *SearchModal) SetResults(results []SearchResultEntry) {
+	m.results = results
+	m.selectedIndex = 0
+	m.searching = false
+	m.errorMessage = ""
+}
+
+// SetError shows an error for the last search
+func (m *SearchModal) SetError(message string) {
+	m.searching = false
+	m.errorMessage = message
+}
+
+// Searching reports whether the modal is waiting for results
+func (m *SearchModal) Searching() bool {
+	return m.searching
+}
+
+// Type returns the modal type
+func (m *SearchModal) Type() ModalType {
+	return ModalSearch
+}
+
+// HandleKey processes keyboard input
+func (m *SearchModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return true, nil, nil // Close modal
+
+	case "enter":
+		// A changed query searches again; otherwise open the selected result
+		if strings.TrimSpace(m.input) == "" {
+			m.errorMessage = "Type something to search for"
+			return true, m, nil
+		}
+		if m.input != m.lastQuery || len(m.results) == 0 {
+			if m.searching {
+				return true, m, nil
+			}
+			m.lastQuery = m.input
+			m.searching = true
+			m.errorMessage = ""
+			var cmd tea.Cmd
+			if m.onSearch != nil {
+				cmd = m.onSearch(m.input)
+			}
+			return true, m, cmd
+		}
+		var cmd tea.Cmd
+		if m.onSelect != nil {
+			cmd = m.onSelect(m.results[m.selectedIndex].Result)
+		}
+		return true, nil, cmd // Close modal
+
+	case "up":
+		if m.selectedIndex > 0 {
+			m.selectedIndex--
+		}
+		return true, m, nil
+
+	case "down":
+		if m.selectedIndex < len(m.results)-1 {
+			m.selectedIndex++
+		}
+		return true, m, nil
+
+	case " ":
+		if len(m.input) < maxSearchInputLength {
+			m.input += " "
+		}
+		return true, m, nil
+
+	case "backspace":
+		if len(m.input) > 0 {
+			runes := []rune(m.input)
+			m.input = string(runes[:len(runes)-1])
+		}
+		return true, m, nil
+
+	default:
+		// Handle text input
+		if msg.Type == tea.KeyRunes && len(m.input) < maxSearchInputLength {
+			m.input += string(msg.Runes)
+			return true, m, nil
+		}
+		// Consume all other keys
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *SearchModal) Render(width, height int) string {
+	modalTitleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorAccent).
+		MarginBottom(1)
+
+	inputFocusedStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorHighlight).
+		Padding(0, 1)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(colorMuted)
+
+	locationStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("75"))
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(colorAccent).
+		Bold(true)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	modalWidth := min(width-4, 90)
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorAccent).
+		Padding(1, 2).
+		Width(modalWidth)
+
+	innerWidth := modalWidth - 6
+	title := modalTitleStyle.Render("Search Messages")
+	searchField := inputFocusedStyle.Width(innerWidth - 2).Render(m.input + "█")
+	filterHint := mutedTextStyle.Render("Filters: from:nick  in:#channel  in:thread  after:2006-01-02  before:2006-01-02  word*")
+
+	var lines []string
+	switch {
+	case m.searching:
+		lines = append(lines, mutedTextStyle.Render("Searching..."))
+	case m.errorMessage != "":
+		lines = append(lines, errorStyle.Render("⚠ "+m.errorMessage))
+	case m.lastQuery != "" && len(m.results) == 0:
+		lines = append(lines, mutedTextStyle.Render("No messages found"))
+	}
+
+	// Each result takes two lines; show a window around the selection
+	visible := max((height-18)/2, 3)
+	start := 0
+	if m.selectedIndex >= visible {
+		start = m.selectedIndex - visible + 1
+	}
+	end := min(start+visible, len(m.results))
+	for i := start; i < end; i++ {
+		entry := m.results[i]
+		msg := entry.Result.Message
+
+		prefix := "  "
+		header := fmt.Sprintf("%s  %s  %s", locationStyle.Render(entry.Location), msg.AuthorNickname, mutedTextStyle.Render(msg.CreatedAt.Format("2006-01-02 15:04")))
+		if i == m.selectedIndex {
+			prefix = selectedStyle.Render("▶ ")
+		}
+		snippet := strings.Join(strings.Fields(msg.Content), " ")
+		snippetWidth := max(innerWidth-4, 10)
+		if runes := []rune(snippet); len(runes) > snippetWidth {
+			snippet = string(runes[:snippetWidth-1]) + "…"
+		}
+		lines = append(lines, prefix+header, "    "+snippet)
+	}
+	if len(m.results) > 0 {
+		lines = append(lines, mutedTextStyle.Render(fmt.Sprintf("%d of %d", m.selectedIndex+1, len(m.results))))
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		searchField,
+		filterHint,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		mutedTextStyle.Render("[Enter] Search / Open  [↑/↓] Navigate  [ESC] Close"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *SearchModal) IsBlockingInput() bool {
+	return true
+}
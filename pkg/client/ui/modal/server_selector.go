@@ -248,10 +248,10 @@ func (m *ServerSelectorModal) Render(width, height int) string {
 		Bold(true)
 
 	serverDescStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252"))
+		Foreground(colorText)
 
 	serverStatsStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	errorStyle := lipgloss.NewStyle().
@@ -259,7 +259,7 @@ func (m *ServerSelectorModal) Render(width, height int) string {
 		Bold(true)
 
 	mutedTextStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	// Build content
@@ -307,6 +307,8 @@ func (m *ServerSelectorModal) Render(width, height int) string {
 			switch m.connectionType {
 			case "tcp":
 				connTypeDisplay = "TCP"
+			case "tls":
+				connTypeDisplay = "TLS"
 			case "ssh":
 				connTypeDisplay = "SSH"
 			case "websocket":
@@ -17,7 +17,7 @@ import (
 // Styles for SSH key manager
 var (
 	primaryColor   = lipgloss.Color("#00D0D0")
-	mutedTextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	mutedTextStyle lipgloss.Style // Set by SetTheme
 	boldStyle      = lipgloss.NewStyle().Bold(true)
 	highlightStyle = lipgloss.NewStyle().Background(lipgloss.Color("236"))
 	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
@@ -283,7 +283,7 @@ func (m *SSHKeyManagerModal) renderAddKey() string {
 	keyInputView := m.addKeyInput.View()
 	keyInputStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240"))
+		BorderForeground(colorMuted)
 	if m.addFocusIndex == 0 {
 		keyInputStyle = keyInputStyle.BorderForeground(primaryColor)
 	}
@@ -308,7 +308,7 @@ func (m *SSHKeyManagerModal) renderAddKey() string {
 	labelInputView := m.addLabelInput.View()
 	labelInputStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240"))
+		BorderForeground(colorMuted)
 	if m.addFocusIndex == 1 {
 		labelInputStyle = labelInputStyle.BorderForeground(primaryColor)
 	}
@@ -0,0 +1,131 @@
+package modal
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StartDMModal asks for the nickname of the user to start a direct message with
+type StartDMModal struct {
+	ownNickname  string
+	input        string
+	errorMessage string
+	onConfirm    func(nickname string) tea.Cmd
+	onCancel     func() tea.Cmd
+}
+
+// NewStartDMModal creates a new start DM modal
+func NewStartDMModal(ownNickname string, onConfirm func(string) tea.Cmd, onCancel func() tea.Cmd) *StartDMModal {
+	return &StartDMModal{
+		ownNickname:  ownNickname,
+		input:        "",
+		errorMessage: "",
+		onConfirm:    onConfirm,
+		onCancel:     onCancel,
+	}
+}
+
+// Type returns the modal type
+func (m *StartDMModal) Type() ModalType {
+	return ModalStartDM
+}
+
+// HandleKey processes keyboard input
+func (m *StartDMModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if m.input == "" {
+			m.errorMessage = "Nickname cannot be empty"
+			return true, m, nil
+		}
+		if m.input == m.ownNickname {
+			m.errorMessage = "You can't message yourself"
+			return true, m, nil
+		}
+
+		var cmd tea.Cmd
+		if m.onConfirm != nil {
+			cmd = m.onConfirm(m.input)
+		}
+		return true, nil, cmd // Close modal
+
+	case "esc":
+		var cmd tea.Cmd
+		if m.onCancel != nil {
+			cmd = m.onCancel()
+		}
+		return true, nil, cmd // Close modal
+
+	case "backspace":
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+		return true, m, nil
+
+	default:
+		// Handle text input
+		if msg.Type == tea.KeyRunes && len(m.input) < 20 {
+			m.input += string(msg.Runes)
+			return true, m, nil
+		}
+		// Consume all other keys
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *StartDMModal) Render(width, height int) string {
+	modalTitleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorAccent).
+		MarginBottom(1)
+
+	inputFocusedStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorHighlight).
+		Padding(0, 1)
+
+	mutedTextStyle := lipgloss.NewStyle().
+		Foreground(colorMuted)
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorAccent).
+		Padding(1, 2)
+
+	title := modalTitleStyle.Render("Direct Message")
+	prompt := "Nickname of the registered user to message:"
+
+	nicknameField := inputFocusedStyle.Width(52).Render(m.input + "█")
+
+	var errorMsg string
+	if m.errorMessage != "" {
+		errorMsg = "\n" + errorStyle.Render("⚠ "+m.errorMessage)
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		"",
+		prompt,
+		"",
+		nicknameField,
+		errorMsg,
+		"",
+		mutedTextStyle.Render("[Enter] Start  [ESC] Cancel"),
+	)
+
+	modal := modalStyle.Render(content)
+
+	// Center the modal
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *StartDMModal) IsBlockingInput() bool {
+	return true
+}
@@ -0,0 +1,56 @@
+package modal
+
+import (
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Palette holds the colors dialogs are rendered with
+type Palette struct {
+	Primary   lipgloss.Color
+	Accent    lipgloss.Color // Dialog borders and titles
+	Highlight lipgloss.Color // Selected items
+	Success   lipgloss.Color
+	Error     lipgloss.Color
+	Warning   lipgloss.Color
+	Muted     lipgloss.Color
+	Text      lipgloss.Color
+}
+
+// Dialog colors, set by SetPalette
+var (
+	colorPrimary   lipgloss.Color
+	colorAccent    lipgloss.Color
+	colorHighlight lipgloss.Color
+	colorSuccess   lipgloss.Color
+	colorError     lipgloss.Color
+	colorWarning   lipgloss.Color
+	colorMuted     lipgloss.Color
+	colorText      lipgloss.Color
+)
+
+func init() {
+	SetPalette(Palette{
+		Primary:   lipgloss.Color("39"),
+		Accent:    lipgloss.Color("205"),
+		Highlight: lipgloss.Color("170"),
+		Success:   lipgloss.Color("42"),
+		Error:     lipgloss.Color("196"),
+		Warning:   lipgloss.Color("214"),
+		Muted:     lipgloss.Color("240"),
+		Text:      lipgloss.Color("252"),
+	})
+}
+
+// SetPalette sets the colors dialogs are rendered with
+func SetPalette(p Palette) {
+	colorPrimary = p.Primary
+	colorAccent = p.Accent
+	colorHighlight = p.Highlight
+	colorSuccess = p.Success
+	colorError = p.Error
+	colorWarning = p.Warning
+	colorMuted = p.Muted
+	colorText = p.Text
+
+	mutedTextStyle = lipgloss.NewStyle().Foreground(colorMuted)
+}
@@ -0,0 +1,117 @@
+package modal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TrustKeyModal asks whether to share an encrypted conversation with a new key of a
+// participant whose earlier keys we trust
+type TrustKeyModal struct {
+	nickname       string
+	fingerprint    string
+	trusted        []string
+	ownFingerprint string
+	onTrust        func() tea.Cmd
+}
+
+// NewTrustKeyModal creates a trust key modal. trusted are the participant's fingerprints
+// trusted so far; ownFingerprint is ours, for the participant to compare.
+func NewTrustKeyModal(nickname, fingerprint string, trusted []string, ownFingerprint string, onTrust func() tea.Cmd) *TrustKeyModal {
+	return &TrustKeyModal{
+		nickname:       nickname,
+		fingerprint:    fingerprint,
+		trusted:        trusted,
+		ownFingerprint: ownFingerprint,
+		onTrust:        onTrust,
+	}
+}
+
+// Type returns the modal type
+func (m *TrustKeyModal) Type() ModalType {
+	return ModalTrustKey
+}
+
+// HandleKey processes keyboard input
+func (m *TrustKeyModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "y":
+		var cmd tea.Cmd
+		if m.onTrust != nil {
+			cmd = m.onTrust()
+		}
+		return true, nil, cmd
+
+	case "n", "esc":
+		// The key stays untrusted; the server asks again the next time it comes up
+		return true, nil, nil
+
+	default:
+		// Consume all other keys (don't let them fall through)
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *TrustKeyModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorError).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(colorText)
+
+	fingerprintStyle := lipgloss.NewStyle().
+		Foreground(colorAccent).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorError).
+		Padding(1, 2).
+		Width(76)
+
+	lines := []string{
+		titleStyle.Render(fmt.Sprintf("New Encryption Key for %s", m.nickname)),
+		labelStyle.Render(fmt.Sprintf("%s has a key you haven't seen before. That happens when they use a\nnew device, but could also mean someone is trying to read your messages.", m.nickname)),
+		"",
+		labelStyle.Render("New key:"),
+		fingerprintStyle.Render(m.fingerprint),
+	}
+	if len(m.trusted) > 0 {
+		lines = append(lines, "", labelStyle.Render("Keys you trusted before:"), fingerprintStyle.Render(strings.Join(m.trusted, "\n")))
+	}
+	if m.ownFingerprint != "" {
+		lines = append(lines, "", labelStyle.Render("Your key:"), fingerprintStyle.Render(m.ownFingerprint))
+	}
+	lines = append(lines,
+		"",
+		labelStyle.Render(fmt.Sprintf("Only share the conversation if %s confirms the new key (in person or\nanother channel you trust).", m.nickname)),
+		"",
+		hintStyle.Render("[y] Trust and share  [n] Don't share"),
+	)
+
+	modal := modalStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	// Center the modal
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modal,
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *TrustKeyModal) IsBlockingInput() bool {
+	return true
+}
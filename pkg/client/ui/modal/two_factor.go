@@ -0,0 +1,265 @@
+package modal
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/aeolun/superchat/pkg/client/qrcode"
+)
+
+// twoFactorStep is where the user is in the two-factor settings
+type twoFactorStep int
+
+const (
+	twoFactorChoose   twoFactorStep = iota // Enable or disable
+	twoFactorLoading                       // Waiting for TWO_FACTOR_SETUP
+	twoFactorSetup                         // Scan the QR code and enter a code
+	twoFactorRecovery                      // Write down the recovery codes
+	twoFactorDisable                       // Enter a code to turn 2FA off
+)
+
+// TwoFactorModal turns two-factor authentication on (scanning a QR code into an
+// authenticator app) or off
+type TwoFactorModal struct {
+	step          twoFactorStep
+	secret        string
+	uri           string
+	recoveryCodes []string
+	code          string
+	waiting       bool // Code sent, waiting for the server
+	errorMessage  string
+	onEnable      func() tea.Cmd
+	onConfirm     func(code string) tea.Cmd
+	onDisable     func(code string) tea.Cmd
+}
+
+// NewTwoFactorModal creates the two-factor settings modal
+func NewTwoFactorModal(onEnable func() tea.Cmd, onConfirm, onDisable func(code string) tea.Cmd) *TwoFactorModal {
+	return &TwoFactorModal{
+		onEnable:  onEnable,
+		onConfirm: onConfirm,
+		onDisable: onDisable,
+	}
+}
+
+// SetSetup shows the secret to add to an authenticator app
+func (m *TwoFactorModal) SetSetup(secret, uri string) {
+	m.step = twoFactorSetup
+	m.secret = secret
+	m.uri = uri
+	m.code = ""
+	m.errorMessage = ""
+}
+
+// SetRecoveryCodes shows the recovery codes once 2FA is on
+func (m *TwoFactorModal) SetRecoveryCodes(codes []string) {
+	m.step = twoFactorRecovery
+	m.recoveryCodes = codes
+	m.waiting = false
+	m.errorMessage = ""
+}
+
+// SetError shows why the server refused the last step, letting the user retry it
+func (m *TwoFactorModal) SetError(message string) {
+	if m.step == twoFactorLoading {
+		m.step = twoFactorChoose
+	}
+	m.code = ""
+	m.waiting = false
+	m.errorMessage = message
+}
+
+// Type returns the modal type
+func (m *TwoFactorModal) Type() ModalType {
+	return ModalTwoFactor
+}
+
+// HandleKey processes keyboard input
+func (m *TwoFactorModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	key := msg.String()
+	if key == "esc" {
+		return true, nil, nil
+	}
+
+	switch m.step {
+	case twoFactorChoose:
+		switch key {
+		case "e":
+			m.step = twoFactorLoading
+			m.errorMessage = ""
+			if m.onEnable == nil {
+				return true, m, nil
+			}
+			return true, m, m.onEnable()
+		case "d":
+			m.step = twoFactorDisable
+			m.code = ""
+			m.errorMessage = ""
+		}
+		return true, m, nil
+
+	case twoFactorSetup, twoFactorDisable:
+		if m.waiting {
+			return true, m, nil
+		}
+		switch key {
+		case "enter":
+			if m.code == "" {
+				m.errorMessage = "Code cannot be empty"
+				return true, m, nil
+			}
+			m.waiting = true
+			m.errorMessage = ""
+			submit := m.onConfirm
+			if m.step == twoFactorDisable {
+				submit = m.onDisable
+			}
+			if submit == nil {
+				return true, m, nil
+			}
+			return true, m, submit(m.code)
+		case "backspace":
+			if len(m.code) > 0 {
+				m.code = m.code[:len(m.code)-1]
+			}
+		default:
+			if msg.Type == tea.KeyRunes && len(m.code) < 20 {
+				m.code += string(msg.Runes)
+				m.errorMessage = ""
+			}
+		}
+		return true, m, nil
+
+	case twoFactorRecovery:
+		if key == "enter" {
+			return true, nil, nil
+		}
+		return true, m, nil
+
+	default:
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *TwoFactorModal) Render(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(colorPrimary).
+		MarginBottom(1)
+
+	textStyle := lipgloss.NewStyle().
+		Foreground(colorText)
+
+	inputStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("238")).
+		Padding(0, 1)
+
+	// Dark modules on a light background, whatever the terminal theme
+	qrStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("0")).
+		Background(lipgloss.Color("15"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(colorError).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorPrimary).
+		Padding(1, 2).
+		Width(70)
+
+	codeField := m.code
+	if !m.waiting {
+		codeField += "█"
+	}
+
+	var lines []string
+	var hint string
+	switch m.step {
+	case twoFactorChoose:
+		lines = append(lines, textStyle.Render(
+			"Two-factor authentication asks for a code from an authenticator\n"+
+				"app after your password. SSH key logins don't need it."))
+		hint = "[E] Enable  [D] Disable  [Esc] Close"
+
+	case twoFactorLoading:
+		lines = append(lines, hintStyle.Render("Generating secret..."))
+		hint = "[Esc] Cancel"
+
+	case twoFactorSetup:
+		lines = append(lines, textStyle.Render("Scan this code with your authenticator app:"), "")
+		if code, err := qrcode.Encode([]byte(m.uri), qrcode.Low); err == nil {
+			var rows []string
+			for _, row := range strings.Split(code.String(), "\n") {
+				rows = append(rows, qrStyle.Render(row))
+			}
+			lines = append(lines, strings.Join(rows, "\n"), "")
+		}
+		lines = append(lines,
+			textStyle.Render("Or enter this secret: ")+inputStyle.Render(m.secret),
+			"",
+			textStyle.Render("Code from the app:")+"  "+inputStyle.Render(codeField),
+		)
+		hint = "[Enter] Turn on  [Esc] Cancel"
+
+	case twoFactorRecovery:
+		lines = append(lines,
+			textStyle.Render("Two-factor authentication is on. Save these recovery codes;"),
+			textStyle.Render("each logs you in once if you lose your authenticator:"),
+			"",
+		)
+		for i := 0; i < len(m.recoveryCodes); i += 2 {
+			row := "  " + m.recoveryCodes[i]
+			if i+1 < len(m.recoveryCodes) {
+				row += "    " + m.recoveryCodes[i+1]
+			}
+			lines = append(lines, inputStyle.Render(row))
+		}
+		hint = "[Enter] Done"
+
+	case twoFactorDisable:
+		lines = append(lines,
+			textStyle.Render("Enter a code from your authenticator app or a recovery code:"),
+			"",
+			inputStyle.Render(codeField),
+		)
+		hint = "[Enter] Turn off  [Esc] Cancel"
+	}
+
+	if m.waiting {
+		hint = "Verifying..."
+	}
+	if m.errorMessage != "" {
+		lines = append(lines, "", errorStyle.Render("✗ "+m.errorMessage))
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		titleStyle.Render("Two-Factor Authentication"),
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+		"",
+		hintStyle.Render(hint),
+	)
+
+	return lipgloss.Place(
+		width,
+		height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(content),
+	)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *TwoFactorModal) IsBlockingInput() bool {
+	return true
+}
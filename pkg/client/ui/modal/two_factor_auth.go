@@ -0,0 +1,150 @@
+package modal
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TwoFactorAuthModal asks for the two-factor code after the password was accepted
+type TwoFactorAuthModal struct {
+	nickname         string
+	code             string
+	errorMessage     string
+	isAuthenticating bool
+	onConfirm        func(code string) tea.Cmd
+	onCancel         func() tea.Cmd
+}
+
+// NewTwoFactorAuthModal creates a new two-factor code prompt
+func NewTwoFactorAuthModal(
+	nickname string,
+	errorMessage string,
+	onConfirm func(code string) tea.Cmd,
+	onCancel func() tea.Cmd,
+) *TwoFactorAuthModal {
+	return &TwoFactorAuthModal{
+		nickname:     nickname,
+		errorMessage: errorMessage,
+		onConfirm:    onConfirm,
+		onCancel:     onCancel,
+	}
+}
+
+// Type returns the modal type
+func (m *TwoFactorAuthModal) Type() ModalType {
+	return ModalTwoFactorAuth
+}
+
+// HandleKey processes keyboard input
+func (m *TwoFactorAuthModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if m.isAuthenticating {
+			return true, m, nil
+		}
+		if m.code == "" {
+			m.errorMessage = "Code cannot be empty"
+			return true, m, nil
+		}
+
+		var cmd tea.Cmd
+		if m.onConfirm != nil {
+			cmd = m.onConfirm(m.code)
+		}
+		// Parent replaces or closes the modal when the response arrives
+		m.isAuthenticating = true
+		return true, m, cmd
+
+	case "esc":
+		var cmd tea.Cmd
+		if m.onCancel != nil {
+			cmd = m.onCancel()
+		}
+		return true, nil, cmd
+
+	case "backspace":
+		if len(m.code) > 0 {
+			m.code = m.code[:len(m.code)-1]
+		}
+		return true, m, nil
+
+	default:
+		if m.isAuthenticating {
+			return true, m, nil
+		}
+		// Authenticator codes are digits, recovery codes letters, digits and dashes
+		if msg.Type == tea.KeyRunes && len(m.code) < 20 {
+			m.code += string(msg.Runes)
+			m.errorMessage = ""
+		}
+		return true, m, nil
+	}
+}
+
+// Render returns the modal content
+func (m *TwoFactorAuthModal) Render(width, height int) string {
+	primaryColor := lipgloss.Color("205")
+	mutedColor := lipgloss.Color("240")
+	errorColor := lipgloss.Color("196")
+
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(primaryColor).
+		MarginBottom(1).
+		Render(fmt.Sprintf("🔐 Two-factor authentication for '%s'", m.nickname))
+
+	prompt := lipgloss.NewStyle().
+		Foreground(colorText).
+		MarginBottom(1).
+		Render("Enter the code from your authenticator app,\nor one of your recovery codes:")
+
+	inputStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorHighlight).
+		Padding(0, 1).
+		Width(40)
+
+	codeDisplay := m.code
+	if !m.isAuthenticating {
+		codeDisplay += "█" // Cursor
+	}
+
+	var errorMsg string
+	if m.errorMessage != "" {
+		errorMsg = "\n" + lipgloss.NewStyle().
+			Foreground(errorColor).
+			Render(m.errorMessage)
+	}
+
+	status := "[Enter] Verify  [ESC] Browse anonymously"
+	if m.isAuthenticating {
+		status = "Verifying..."
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		"",
+		title,
+		prompt,
+		inputStyle.Render(codeDisplay),
+		errorMsg,
+		lipgloss.NewStyle().Foreground(mutedColor).MarginTop(1).Render(status),
+		"",
+	)
+
+	modal := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(primaryColor).
+		Padding(1, 3).
+		Width(60).
+		Render(content)
+
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, modal)
+}
+
+// IsBlockingInput returns true (this modal blocks all input)
+func (m *TwoFactorAuthModal) IsBlockingInput() bool {
+	return true
+}
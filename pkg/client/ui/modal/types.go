@@ -34,6 +34,20 @@ const (
 	ModalDeleteUser
 	ModalDeleteChannel
 	ModalListUsers
+	ModalCreateSubchannel
+	ModalStartDM
+	ModalSearch
+	ModalReactionPicker
+	ModalMentions
+	ModalMessageHistory
+	ModalAuditLog
+	ModalManageRoles
+	ModalLoginLockouts
+	ModalTwoFactorAuth
+	ModalTwoFactor
+	ModalDisableTwoFactor
+	ModalProfile
+	ModalTrustKey
 )
 
 // String returns the string representation of the modal type
@@ -91,6 +105,34 @@ func (m ModalType) String() string {
 		return "DeleteChannel"
 	case ModalListUsers:
 		return "ListUsers"
+	case ModalCreateSubchannel:
+		return "CreateSubchannel"
+	case ModalStartDM:
+		return "StartDM"
+	case ModalSearch:
+		return "Search"
+	case ModalReactionPicker:
+		return "ReactionPicker"
+	case ModalMentions:
+		return "Mentions"
+	case ModalMessageHistory:
+		return "MessageHistory"
+	case ModalAuditLog:
+		return "AuditLog"
+	case ModalManageRoles:
+		return "ManageRoles"
+	case ModalLoginLockouts:
+		return "LoginLockouts"
+	case ModalTwoFactorAuth:
+		return "TwoFactorAuth"
+	case ModalTwoFactor:
+		return "TwoFactor"
+	case ModalDisableTwoFactor:
+		return "DisableTwoFactor"
+	case ModalProfile:
+		return "Profile"
+	case ModalTrustKey:
+		return "TrustKey"
 	default:
 		return "Unknown"
 	}
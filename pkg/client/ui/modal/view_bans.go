@@ -28,6 +28,7 @@ type BanEntry struct {
 	BannedUntil   *int64
 	BannedBy      string
 	IsShadowban   bool
+	Channel       string // Channel a user ban is limited to ("" = the whole server)
 }
 
 // NewViewBansModal creates a new view bans modal
@@ -113,7 +114,7 @@ func (m *ViewBansModal) HandleKey(msg tea.KeyMsg) (bool, Modal, tea.Cmd) {
 func (m *ViewBansModal) Render(width, height int) string {
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("196")).
+		Foreground(colorError).
 		MarginBottom(1)
 
 	selectedStyle := lipgloss.NewStyle().
@@ -123,20 +124,20 @@ func (m *ViewBansModal) Render(width, height int) string {
 		Padding(0, 1)
 
 	unselectedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(colorText).
 		Padding(0, 1)
 
 	hintStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
+		Foreground(colorMuted).
 		Italic(true)
 
 	shadowbanStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("208")).
+		Foreground(colorWarning).
 		Italic(true)
 
 	modalStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("196")).
+		BorderForeground(colorError).
 		Padding(1, 2).
 		Width(80).
 		Height(min(height-4, 30))
@@ -170,6 +171,9 @@ func (m *ViewBansModal) Render(width, height int) string {
 			} else {
 				target = "Unknown"
 			}
+			if ban.Channel != "" {
+				target += fmt.Sprintf(" in #%s", ban.Channel)
+			}
 
 			// Format duration
 			var duration string
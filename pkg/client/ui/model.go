@@ -9,6 +9,7 @@ import (
 
 	"github.com/aeolun/superchat/pkg/client"
 	"github.com/aeolun/superchat/pkg/client/assets"
+	shared "github.com/aeolun/superchat/pkg/client/commands"
 	"github.com/aeolun/superchat/pkg/client/ui/commands"
 	"github.com/aeolun/superchat/pkg/client/ui/modal"
 	"github.com/aeolun/superchat/pkg/protocol"
@@ -60,8 +61,42 @@ type presenceEntry struct {
 	IsRegistered bool
 	UserID       *uint64
 	UserFlags    protocol.UserFlags
+
+	// From SERVER_PRESENCE only; channel rosters look them up in the server roster
+	DisplayName string
+	Status      string
+	Away        bool
+}
+
+// sentContent remembers a post or edit so it can be restored when the server
+// rejects it for exceeding the message rate limit
+type sentContent struct {
+	chat      bool // Sent from the chat input rather than the compose modal
+	mode      modal.ComposeMode
+	content   string
+	parentID  *uint64
+	messageID *uint64
+}
+
+// typingTarget is where someone is typing: a chat channel (or subchannel), a forum
+// channel for a new thread, or a thread for a reply. Zero IDs mean "none".
+type typingTarget struct {
+	channelID    uint64
+	subchannelID uint64
+	threadID     uint64
 }
 
+// typingUser is someone the server says is typing, until expiresAt
+type typingUser struct {
+	nickname  string
+	target    typingTarget
+	expiresAt time.Time
+}
+
+// typingExpiry is how long a USER_TYPING is shown unless repeated (the server normally
+// says when someone stopped well before that)
+const typingExpiry = 8 * time.Second
+
 // Model represents the application state
 type Model struct {
 	// Connection and state
@@ -79,6 +114,12 @@ type Model struct {
 	awaitingServerList bool                  // True when we've requested LIST_SERVERS
 	availableServers   []protocol.ServerInfo // Servers from directory
 
+	// Display settings from config.toml
+	timestamps client.TimestampFormatter
+
+	// Show message source instead of rendered markdown (saved in state)
+	showRawMarkdown bool
+
 	// Current view and modals
 	mainView    MainView
 	modalStack  modal.ModalStack
@@ -101,6 +142,15 @@ type Model struct {
 	showUserSidebar  bool
 	unreadCounts     map[uint64]uint32 // channelID -> unread count
 
+	// Subchannel state
+	subchannels       map[uint64][]protocol.Subchannel // channelID -> subchannels
+	expandedChannels  map[uint64]bool                  // channelID -> subchannels shown in channel list
+	currentSubchannel *protocol.Subchannel             // Open subchannel (nil = channel root)
+
+	// Direct message state (registered users only)
+	dms     []protocol.DMChannel
+	keyring *client.Keyring // End-to-end encryption keys for DMs
+
 	// Loading states
 	loadingChannels      bool // True if fetching channel list
 	loadingThreadList    bool // True if fetching initial thread list
@@ -142,7 +192,8 @@ type Model struct {
 	userFlags            protocol.UserFlags
 	composeInput         string // Temporary storage for compose state
 	composeParentID      *uint64
-	composeMessageID     *uint64 // Message ID when editing
+	composeMessageID     *uint64      // Message ID when editing
+	lastSent             *sentContent // Last post/edit, restored if the server rate limits it
 
 	// Auth state (V2)
 	authState         AuthState
@@ -175,6 +226,10 @@ type Model struct {
 	lastPingSent time.Time
 	pingInterval time.Duration
 
+	// Typing indicators
+	typingUsers  map[uint64]typingUser // sessionID -> who is typing where
+	chatTypingAt time.Time             // When we last sent TYPING_START from the chat input (zero = not typing)
+
 	// Notifications
 	lastInteractionTime  time.Time
 	notificationIconPath string
@@ -185,6 +240,14 @@ type Model struct {
 	// Bandwidth optimization
 	threadRepliesCache     map[uint64][]protocol.Message // Cached thread replies
 	threadHighestMessageID map[uint64]uint64             // Highest message ID seen per thread
+
+	// Message search
+	searchThreads map[uint64]protocol.Message // Thread roots of the latest search results
+	pendingJumpID *uint64                     // Reply to select once the thread it was found in loads
+
+	// Mentions inbox
+	unreadMentions uint32
+	mentionThreads map[uint64]protocol.Message // Thread roots of the loaded mentions
 }
 
 // NewModel creates a new application model
@@ -220,11 +283,11 @@ func NewModel(conn client.ConnectionInterface, state client.StateInterface, curr
 	// Style the textarea with a border
 	ta.FocusedStyle.Base = lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("205")). // Primary color
+		BorderForeground(AccentColor).
 		Padding(0, 1)
 	ta.BlurredStyle.Base = lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240")). // Muted color
+		BorderForeground(MutedColor).
 		Padding(0, 1)
 
 	m := Model{
@@ -258,7 +321,12 @@ func NewModel(conn client.ConnectionInterface, state client.StateInterface, curr
 		lastInteractionTime:    time.Now(), // Initialize to now (active on startup)
 		channelRoster:          make(map[uint64]map[uint64]presenceEntry),
 		serverRoster:           make(map[uint64]presenceEntry),
+		typingUsers:            make(map[uint64]typingUser),
 		unreadCounts:           make(map[uint64]uint32),
+		keyring:                client.NewKeyring(state, conn.GetAddress()),
+		subchannels:            make(map[uint64][]protocol.Subchannel),
+		expandedChannels:       make(map[uint64]bool),
+		showRawMarkdown:        state.GetShowRawMarkdown(),
 	}
 
 	// Initialize notification icon (write to data directory if needed)
@@ -295,6 +363,41 @@ func NewModel(conn client.ConnectionInterface, state client.StateInterface, curr
 	return m
 }
 
+// SetTimestampFormatter sets how message timestamps are displayed
+func (m *Model) SetTimestampFormatter(f client.TimestampFormatter) {
+	m.timestamps = f
+}
+
+// SetKeyBindings applies the user's key bindings to all commands
+func (m *Model) SetKeyBindings(bindings *shared.KeyBindings) {
+	shared.SetKeyBindings(bindings)
+	m.commands.SetBindings(bindings)
+}
+
+// toggleRawMarkdown switches messages between rendered markdown and their source,
+// and remembers the choice
+func (m *Model) toggleRawMarkdown() {
+	m.showRawMarkdown = !m.showRawMarkdown
+	if err := m.state.SetShowRawMarkdown(m.showRawMarkdown); err != nil && m.logger != nil {
+		m.logger.Printf("Failed to save markdown setting: %v", err)
+	}
+	if m.showRawMarkdown {
+		m.statusMessage = "Showing raw message source"
+	} else {
+		m.statusMessage = "Rendering markdown"
+	}
+	m.threadViewport.SetContent(m.buildThreadContent())
+	m.chatViewport.SetContent(m.buildChatMessages())
+}
+
+// CheckKeyBindings reports keys the user's bindings would bind to more than one
+// action in the same view
+func CheckKeyBindings(bindings *shared.KeyBindings) error {
+	m := Model{commands: commands.NewRegistry()}
+	m.registerCommands()
+	return bindings.CheckConflicts(append(shared.SharedBindings(), m.commands.Bindings(sharedModalType)...))
+}
+
 // registerCommands sets up all keyboard commands
 func (m *Model) registerCommands() {
 	// === Global Commands ===
@@ -303,6 +406,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("q").
 		Name("Quit").
+		Action(shared.ActionQuit).
 		Aliases("Exit").
 		Help("Quit the application").
 		Global().
@@ -317,6 +421,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("h", "?").
 		Name("Help").
+		Action(shared.ActionHelp).
 		Help("Toggle help screen").
 		Global().
 		InModals(modal.ModalNone). // Only available when no modal is open
@@ -335,6 +440,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("ctrl+l").
 		Name("Server List").
+		Action(shared.ActionServerList).
 		Help("List available servers").
 		Global().
 		InModals(modal.ModalNone). // Only available when no modal is open
@@ -359,6 +465,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("up", "k").
 		Name("Navigate").
+		Action(shared.ActionNavigateUp).
 		Help("Move selection up").
 		InViews(int(ViewThreadView)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -378,6 +485,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("down", "j").
 		Name("Navigate").
+		Action(shared.ActionNavigateDown).
 		Help("Move selection down").
 		InViews(int(ViewThreadView)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -406,6 +514,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("r").
 		Name("Reply").
+		Action(shared.ActionComposeReply).
 		Help("Reply to the selected message").
 		InViews(int(ViewThreadView)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -438,6 +547,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("e").
 		Name("Edit").
+		Action(shared.ActionEditMessage).
 		Help("Edit your own message").
 		InViews(int(ViewThreadView)).
 		When(func(i interface{}) bool {
@@ -455,6 +565,11 @@ func (m *Model) registerCommands() {
 				return false
 			}
 
+			// Staff can edit other users' messages (the server checks moderator channels)
+			if model.isStaff() {
+				return true
+			}
+
 			// Check if we're authenticated and own this message
 			if model.userID == nil {
 				return false
@@ -479,6 +594,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("d").
 		Name("Delete").
+		Action(shared.ActionDeleteMessage).
 		Help("Delete your own message").
 		InViews(int(ViewThreadView)).
 		When(func(i interface{}) bool {
@@ -491,6 +607,11 @@ func (m *Model) registerCommands() {
 				return false
 			}
 
+			// Staff can delete any message (the server checks moderator channels)
+			if model.isStaff() {
+				return true
+			}
+
 			// Only registered users can delete messages (anonymous messages cannot be deleted)
 			if msg.AuthorUserID == nil {
 				return false
@@ -528,10 +649,56 @@ func (m *Model) registerCommands() {
 		Priority(40).
 		Build())
 
+	// React to message
+	m.commands.Register(commands.NewCommand().
+		Keys("+").
+		Name("React").
+		Action(shared.ActionReact).
+		Help("Add or remove a reaction").
+		InViews(int(ViewThreadView)).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			msg, ok := model.selectedMessage()
+			if !ok || isDeletedMessageContent(msg.Content) {
+				return false
+			}
+			// Only registered users can react
+			return model.userID != nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			msg, _ := model.selectedMessage()
+			model.showReactionPicker(msg)
+			return model, nil
+		}).
+		Priority(45).
+		Build())
+
+	// Edit history of message
+	m.commands.Register(commands.NewCommand().
+		Keys("H").
+		Name("History").
+		Action(shared.ActionMessageHistory).
+		Help("Show the edit history of the message").
+		InViews(int(ViewThreadView)).
+		When(func(i interface{}) bool {
+			msg, ok := i.(*Model).selectedMessage()
+			return ok && msg.EditedAt != nil && !isDeletedMessageContent(msg.Content)
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			msg, _ := model.selectedMessage()
+			model.modalStack.Push(modal.NewMessageHistoryModal(msg.ID))
+			return model, model.sendGetMessageHistory(msg.ID)
+		}).
+		Priority(46).
+		Build())
+
 	// Back to thread list
 	m.commands.Register(commands.NewCommand().
 		Keys("esc").
 		Name("Back").
+		Action(shared.ActionGoBack).
 		Help("Return to thread list").
 		InViews(int(ViewThreadView)).
 		InModals(modal.ModalNone). // Only available when no modal is open
@@ -563,6 +730,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("up", "k").
 		Name("Navigate").
+		Action(shared.ActionNavigateUp).
 		Help("Move selection up").
 		InViews(int(ViewThreadList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -581,6 +749,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("down", "j").
 		Name("Navigate").
+		Action(shared.ActionNavigateDown).
 		Help("Move selection down").
 		InViews(int(ViewThreadList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -608,6 +777,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("enter").
 		Name("Open").
+		Action(shared.ActionSelect).
 		Help("Open the selected thread").
 		InViews(int(ViewThreadList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -655,6 +825,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("n").
 		Name("New Thread").
+		Action(shared.ActionComposeNewThread).
 		Help("Create a new thread").
 		InViews(int(ViewThreadList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -679,6 +850,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("r").
 		Name("Refresh").
+		Action(shared.ActionRefresh).
 		Help("Refresh the thread list").
 		InViews(int(ViewThreadList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -698,6 +870,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("esc").
 		Name("Back").
+		Action(shared.ActionGoBack).
 		Help("Return to channel list").
 		InViews(int(ViewThreadList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -713,6 +886,7 @@ func (m *Model) registerCommands() {
 				model.clearActiveChannel()
 			}
 			model.currentChannel = nil
+			model.currentSubchannel = nil
 			model.threads = []protocol.Message{}
 			model.threadCursor = 0
 			model.loadingMore = false
@@ -726,6 +900,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("esc").
 		Name("Back").
+		Action(shared.ActionGoBack).
 		Help("Return to channel list").
 		InViews(int(ViewChatChannel)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -740,6 +915,7 @@ func (m *Model) registerCommands() {
 				model.clearActiveChannel()
 			}
 			model.currentChannel = nil
+			model.currentSubchannel = nil
 			model.chatMessages = []protocol.Message{}
 			model.chatTextarea.Blur() // Unfocus textarea
 			model.chatTextarea.Reset()
@@ -754,6 +930,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("up", "k").
 		Name("Navigate").
+		Action(shared.ActionNavigateUp).
 		Help("Move selection up").
 		InViews(int(ViewChannelList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -770,11 +947,12 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("down", "j").
 		Name("Navigate").
+		Action(shared.ActionNavigateDown).
 		Help("Move selection down").
 		InViews(int(ViewChannelList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
 			model := i.(*Model)
-			if model.channelCursor < len(model.channels)-1 {
+			if model.channelCursor < len(model.channelListRows())-1 {
 				model.channelCursor++
 			}
 			return model, nil
@@ -786,16 +964,31 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("enter").
 		Name("Select").
+		Action(shared.ActionSelect).
 		Help("Select the channel").
 		InViews(int(ViewChannelList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
 			model := i.(*Model)
-			if model.channelCursor < len(model.channels) {
-				selectedChannel := model.channels[model.channelCursor]
+			rows := model.channelListRows()
+			if model.channelCursor < len(rows) {
+				row := rows[model.channelCursor]
+				selectedChannel := row.channel
+
+				// Channels with subchannels expand/collapse instead of opening
+				if row.subchannel == nil && len(model.subchannels[selectedChannel.ID]) > 0 {
+					model.expandedChannels[selectedChannel.ID] = !model.expandedChannels[selectedChannel.ID]
+					return model, nil
+				}
+
 				model.currentChannel = &selectedChannel
+				model.currentSubchannel = row.subchannel
 
-				// Check channel type and route to appropriate view
-				if selectedChannel.Type == 0 {
+				// Check channel type and route to appropriate view (subchannels have their own type)
+				channelType := selectedChannel.Type
+				if row.subchannel != nil {
+					channelType = row.subchannel.Type
+				}
+				if channelType == 0 {
 					// Chat channel (type 0) - go to chat view
 					model.currentView = ViewChatChannel
 					model.loadingChat = true
@@ -833,6 +1026,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("r").
 		Name("Refresh").
+		Action(shared.ActionRefresh).
 		Help("Refresh the channel list").
 		InViews(int(ViewChannelList)).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
@@ -847,6 +1041,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("c").
 		Name("Create Channel").
+		Action(shared.ActionCreateChannel).
 		Help("Create a new channel (registered users only)").
 		InViews(int(ViewChannelList)).
 		When(func(i interface{}) bool {
@@ -862,10 +1057,54 @@ func (m *Model) registerCommands() {
 		Priority(80).
 		Build())
 
+	// Create subchannel in the channel under the cursor
+	m.commands.Register(commands.NewCommand().
+		Keys("s").
+		Name("Create Subchannel").
+		Action(shared.ActionCreateSubchannel).
+		Help("Create a subchannel in the selected channel (registered users only)").
+		InViews(int(ViewChannelList)).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			if model.authState != AuthStateAuthenticated || model.userID == nil {
+				return false
+			}
+			rows := model.channelListRows()
+			return model.channelCursor >= 0 && model.channelCursor < len(rows) && rows[model.channelCursor].dm == nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			rows := model.channelListRows()
+			model.showCreateSubchannelModal(rows[model.channelCursor].channel)
+			return model, nil
+		}).
+		Priority(81).
+		Build())
+
+	// Start a direct message
+	m.commands.Register(commands.NewCommand().
+		Keys("m").
+		Name("Direct Message").
+		Action(shared.ActionStartDM).
+		Help("Start a direct message with a user (registered users only)").
+		InViews(int(ViewChannelList)).
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return model.authState == AuthStateAuthenticated && model.userID != nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showStartDMModal()
+			return model, nil
+		}).
+		Priority(82).
+		Build())
+
 	// Ctrl+R to open registration modal
 	m.commands.Register(commands.NewCommand().
 		Keys("ctrl+r").
 		Name("Register").
+		Action(shared.ActionRegister).
 		Help("Register this nickname").
 		Global().
 		When(func(i interface{}) bool {
@@ -887,6 +1126,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("ctrl+s").
 		Name("Sign In").
+		Action(shared.ActionSignIn).
 		Help("Sign in with password").
 		Global().
 		When(func(i interface{}) bool {
@@ -908,6 +1148,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("ctrl+a").
 		Name("Go Anonymous").
+		Action(shared.ActionGoAnonymous).
 		Help("Post anonymously").
 		Global().
 		When(func(i interface{}) bool {
@@ -927,6 +1168,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("ctrl+n").
 		Name("Change Nick").
+		Action(shared.ActionChangeNickname).
 		Help("Change nickname").
 		Global().
 		When(func(i interface{}) bool {
@@ -946,6 +1188,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys("ctrl+k").
 		Name("SSH Keys").
+		Action(shared.ActionSSHKeys).
 		Help("Manage SSH keys").
 		Global().
 		When(func(i interface{}) bool {
@@ -966,10 +1209,31 @@ func (m *Model) registerCommands() {
 		Priority(10).
 		Build())
 
+	// Ctrl+T to set up two-factor authentication
+	m.commands.Register(commands.NewCommand().
+		Keys("ctrl+t").
+		Name("Two-Factor Auth").
+		Action(shared.ActionTwoFactor).
+		Help("Turn two-factor authentication on or off").
+		Global().
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			// Only available when authenticated
+			return model.authState == AuthStateAuthenticated
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showTwoFactorModal()
+			return model, nil
+		}).
+		Priority(10).
+		Build())
+
 	// Toggle user sidebar with U key
 	m.commands.Register(commands.NewCommand().
 		Keys("u").
 		Name("Users Sidebar").
+		Action(shared.ActionToggleUsers).
 		Help("Toggle user sidebar").
 		Global().
 		InModals(modal.ModalNone).
@@ -993,16 +1257,36 @@ func (m *Model) registerCommands() {
 		Priority(60).
 		Build())
 
+	// Profiles of the users in the sidebar with P key
+	m.commands.Register(commands.NewCommand().
+		Keys("p").
+		Name("Profiles").
+		Action(shared.ActionProfiles).
+		Help("View user profiles and edit yours").
+		Global().
+		InModals(modal.ModalNone).
+		When(func(i interface{}) bool {
+			return i.(*Model).showUserSidebar
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showProfileModal()
+			return model, nil
+		}).
+		Priority(60).
+		Build())
+
 	// Admin panel with A key
 	m.commands.Register(commands.NewCommand().
 		Keys("A").
 		Name("Admin Panel").
+		Action(shared.ActionAdminPanel).
 		Help("Open admin panel").
 		Global().
 		InModals(modal.ModalNone). // Only available when no modal is open
 		When(func(i interface{}) bool {
 			model := i.(*Model)
-			return model.isAdmin()
+			return model.isStaff()
 		}).
 		Do(func(i interface{}) (interface{}, tea.Cmd) {
 			model := i.(*Model)
@@ -1012,10 +1296,67 @@ func (m *Model) registerCommands() {
 		Priority(910).
 		Build())
 
+	// Search messages with Ctrl+F
+	m.commands.Register(commands.NewCommand().
+		Keys("ctrl+f").
+		Name("Search").
+		Action(shared.ActionSearch).
+		Help("Search messages").
+		Global().
+		InModals(modal.ModalNone). // Only available when no modal is open
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			return !model.directoryMode
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.showSearchModal()
+			return model, nil
+		}).
+		Priority(85).
+		Build())
+
+	// Mentions inbox with Ctrl+O
+	m.commands.Register(commands.NewCommand().
+		Keys("ctrl+o").
+		Name("Mentions").
+		Action(shared.ActionMentions).
+		Help("Open your mentions inbox").
+		Global().
+		InModals(modal.ModalNone). // Only available when no modal is open
+		When(func(i interface{}) bool {
+			model := i.(*Model)
+			// Only registered users can be mentioned
+			return !model.directoryMode && model.userID != nil
+		}).
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			return model, model.showMentionsModal()
+		}).
+		Priority(86).
+		Build())
+
+	// Toggle between rendered markdown and raw message source with M
+	m.commands.Register(commands.NewCommand().
+		Keys("M").
+		Name("Raw/Markdown").
+		Action(shared.ActionToggleMarkdown).
+		Help("Toggle between rendered markdown and raw message source").
+		InViews(int(ViewThreadView), int(ViewChatChannel)).
+		InModals(modal.ModalNone). // Only available when no modal is open
+		Do(func(i interface{}) (interface{}, tea.Cmd) {
+			model := i.(*Model)
+			model.toggleRawMarkdown()
+			return model, nil
+		}).
+		Priority(870).
+		Build())
+
 	// Command palette with / (IRC-style)
 	m.commands.Register(commands.NewCommand().
 		Keys("/").
 		Name("Command").
+		Action(shared.ActionCommandPalette).
 		Help("Open command palette (IRC-style)").
 		Global().
 		InModals(modal.ModalNone). // Only available when no modal is open
@@ -1031,6 +1372,7 @@ func (m *Model) registerCommands() {
 	m.commands.Register(commands.NewCommand().
 		Keys(":").
 		Name("Command").
+		Action(shared.ActionCommandPalette).
 		Help("Open command palette (vim-style)").
 		Global().
 		InModals(modal.ModalNone). // Only available when no modal is open
@@ -1102,6 +1444,58 @@ func (m *Model) showPasswordModal() {
 	m.modalStack.Push(passwordModal)
 }
 
+// showTwoFactorAuthModal asks for the two-factor code after the password was accepted
+func (m *Model) showTwoFactorAuthModal(errorMessage string) {
+	targetNickname := m.authTargetNickname
+	m.modalStack.Push(modal.NewTwoFactorAuthModal(
+		targetNickname,
+		errorMessage,
+		func(code string) tea.Cmd {
+			m.authState = AuthStateAuthenticating
+			return m.sendAuthTwoFactor(code)
+		},
+		func() tea.Cmd {
+			return func() tea.Msg {
+				return GoAnonymousMsg{TargetNickname: targetNickname}
+			}
+		},
+	))
+}
+
+// showTwoFactorModal displays the two-factor authentication settings
+// showProfileModal lists the users in the sidebar to view their profiles
+func (m *Model) showProfileModal() {
+	var users []modal.ProfileUser
+	seen := make(map[string]bool)
+	for _, entry := range m.sidebarEntries() {
+		if seen[entry.Nickname] {
+			continue
+		}
+		seen[entry.Nickname] = true
+		entry = m.withServerPresence(entry)
+		users = append(users, modal.ProfileUser{
+			Nickname:     entry.Nickname,
+			IsRegistered: entry.IsRegistered,
+			Away:         entry.Away,
+			Self:         m.isSelfSession(entry.SessionID),
+		})
+	}
+	m.modalStack.Push(modal.NewProfileModal(
+		users,
+		m.authState == AuthStateAuthenticated,
+		func(nickname string) tea.Cmd { return m.sendGetUserInfo(nickname) },
+		func(profile *protocol.SetProfileMessage) tea.Cmd { return m.sendSetProfile(profile) },
+	))
+}
+
+func (m *Model) showTwoFactorModal() {
+	m.modalStack.Push(modal.NewTwoFactorModal(
+		func() tea.Cmd { return m.sendEnable2FA() },
+		func(code string) tea.Cmd { return m.sendConfirm2FA(code) },
+		func(code string) tea.Cmd { return m.sendDisable2FA(code) },
+	))
+}
+
 // showRegistrationModal displays the registration modal
 func (m *Model) showRegistrationModal() {
 	registrationModal := modal.NewRegistrationModal(
@@ -1152,6 +1546,7 @@ func (m *Model) showGoAnonymousModal() {
 			m.userFlags = 0
 			m.authState = AuthStateAnonymous
 			m.state.SetUserID(nil)
+			m.dms = nil
 			m.state.SetLastNickname(newNickname)
 
 			// Send LOGOUT first, then SET_NICKNAME
@@ -1178,6 +1573,12 @@ func (m *Model) showComposeModal(mode modal.ComposeMode, initialContent string)
 			// Determine what to do based on mode
 			var cmd tea.Cmd
 			m.sendingMessage = true
+			m.lastSent = &sentContent{
+				mode:      mode,
+				content:   content,
+				parentID:  m.composeParentID,
+				messageID: m.composeMessageID,
+			}
 			if mode == modal.ComposeModeEdit {
 				if m.composeMessageID != nil {
 					cmd = m.sendEditMessage(*m.composeMessageID, content)
@@ -1202,6 +1603,13 @@ func (m *Model) showComposeModal(mode modal.ComposeMode, initialContent string)
 			return nil
 		},
 	)
+	composeModal.SetSendKeys(shared.BoundKeys(shared.ActionSendMessage, m.GetCurrentView(), shared.ModalCompose))
+	if target, ok := m.composeTypingTarget(mode); ok {
+		composeModal.SetTypingHandler(func(typing bool) tea.Cmd {
+			return m.sendTyping(target, typing)
+		})
+		composeModal.SetTyping(m.typingText(target))
+	}
 	m.modalStack.Push(composeModal)
 }
 
@@ -1275,6 +1683,154 @@ func (m *Model) showCreateChannelModal() {
 	m.modalStack.Push(createChannelModal)
 }
 
+// showCreateSubchannelModal displays the subchannel creation modal for a channel
+func (m *Model) showCreateSubchannelModal(channel protocol.Channel) {
+	createSubchannelModal := modal.NewCreateSubchannelModal(
+		channel.Name,
+		func(name, displayName, description string, subchannelType uint8) tea.Cmd {
+			m.statusMessage = "Creating subchannel..."
+			return tea.Batch(
+				listenForServerFrames(m.conn, m.connGeneration),
+				m.sendCreateSubchannel(channel.ID, name, displayName, description, subchannelType),
+			)
+		},
+		func() tea.Cmd {
+			// Canceled subchannel creation
+			return nil
+		},
+	)
+	m.modalStack.Push(createSubchannelModal)
+}
+
+// showStartDMModal displays the modal for starting a direct message
+func (m *Model) showStartDMModal() {
+	startDMModal := modal.NewStartDMModal(
+		m.nickname,
+		func(nickname string) tea.Cmd {
+			m.statusMessage = fmt.Sprintf("Starting direct message with %s...", nickname)
+			return tea.Batch(
+				listenForServerFrames(m.conn, m.connGeneration),
+				m.sendStartDM(nickname),
+			)
+		},
+		func() tea.Cmd {
+			return nil
+		},
+	)
+	m.modalStack.Push(startDMModal)
+}
+
+// showMentionsModal displays the mentions inbox and loads it
+func (m *Model) showMentionsModal() tea.Cmd {
+	m.modalStack.Push(modal.NewMentionsModal(
+		func(mention protocol.Mention) tea.Cmd {
+			return func() tea.Msg { return MentionJumpMsg{Mention: mention} }
+		},
+		func(messageIDs []uint64) tea.Cmd {
+			return tea.Batch(
+				m.sendMarkMentionsRead(messageIDs),
+				func() tea.Msg { return MentionsReadMsg{MessageIDs: messageIDs} },
+			)
+		},
+	))
+	return m.sendListMentions(false, 0)
+}
+
+// showReactionPicker displays the reaction picker for a message
+func (m *Model) showReactionPicker(msg *protocol.Message) {
+	reacted := make(map[string]bool)
+	for _, reaction := range msg.Reactions {
+		for _, userID := range reaction.UserIDs {
+			if userID == *m.userID {
+				reacted[reaction.Emoji] = true
+			}
+		}
+	}
+	m.modalStack.Push(modal.NewReactionPickerModal(msg.ID, reacted, m.sendReaction))
+}
+
+// showSearchModal displays the message search modal
+func (m *Model) showSearchModal() {
+	var searchModal *modal.SearchModal
+	searchModal = modal.NewSearchModal(
+		"",
+		func(input string) tea.Cmd {
+			msg, err := m.buildSearchRequest(input)
+			if err != nil {
+				searchModal.SetError(err.Error())
+				return nil
+			}
+			return m.sendSearchMessages(msg)
+		},
+		func(result protocol.SearchResult) tea.Cmd {
+			return func() tea.Msg { return SearchJumpMsg{Result: result} }
+		},
+	)
+	m.modalStack.Push(searchModal)
+}
+
+// buildSearchRequest turns search input into a SEARCH_MESSAGES request. Besides search
+// terms the input may contain filters: from:nick, in:#channel, in:thread (the open
+// thread), after:YYYY-MM-DD and before:YYYY-MM-DD.
+func (m *Model) buildSearchRequest(input string) (*protocol.SearchMessagesMessage, error) {
+	msg := &protocol.SearchMessagesMessage{}
+	var terms []string
+
+	for _, field := range strings.Fields(input) {
+		key, value, hasFilter := strings.Cut(field, ":")
+		if !hasFilter || value == "" {
+			terms = append(terms, field)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "from":
+			author := value
+			msg.Author = &author
+		case "in":
+			if strings.EqualFold(value, "thread") {
+				if m.currentThread == nil {
+					return nil, fmt.Errorf("in:thread needs an open thread")
+				}
+				threadID := m.currentThread.ID
+				msg.ThreadID = &threadID
+				continue
+			}
+			name := strings.TrimPrefix(value, "#")
+			found := false
+			for _, ch := range m.channels {
+				if strings.EqualFold(ch.Name, name) {
+					channelID := ch.ID
+					msg.ChannelID = &channelID
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unknown channel #%s", name)
+			}
+		case "after", "before":
+			day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("%s: expects a date like 2006-01-02", key)
+			}
+			if strings.EqualFold(key, "after") {
+				msg.After = &day
+			} else {
+				msg.Before = &day
+			}
+		default:
+			terms = append(terms, field)
+		}
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("type something to search for")
+	}
+	msg.Query = strings.Join(terms, " ")
+	return msg, nil
+}
+
 // showRegistrationWarningModal displays the first post warning modal
 func (m *Model) showRegistrationWarningModal(onProceed func() tea.Cmd) {
 	registrationWarningModal := modal.NewRegistrationWarningModal(
@@ -1334,9 +1890,14 @@ func (m *Model) isAdmin() bool {
 	return m.authState == AuthStateAuthenticated && m.userFlags.IsAdmin()
 }
 
-// showAdminPanel displays the admin panel modal
+// isStaff checks if the user is an admin or moderator
+func (m *Model) isStaff() bool {
+	return m.authState == AuthStateAuthenticated && m.userFlags.IsSystem()
+}
+
+// showAdminPanel displays the admin panel modal (limited for moderators)
 func (m *Model) showAdminPanel() {
-	if !m.isAdmin() {
+	if !m.isStaff() {
 		return
 	}
 	m.modalStack.Push(m.createConfiguredAdminPanel())
@@ -1346,6 +1907,7 @@ func (m *Model) showAdminPanel() {
 // This is used both when initially opening the panel and when returning from sub-modals
 func (m *Model) createConfiguredAdminPanel() modal.Modal {
 	adminPanel := modal.NewAdminPanelModal()
+	adminPanel.SetModerator(!m.isAdmin())
 
 	// Wire up menu item actions to create modals with handlers
 	adminPanel.SetMenuActions(
@@ -1356,6 +1918,10 @@ func (m *Model) createConfiguredAdminPanel() modal.Modal {
 		func() (modal.Modal, tea.Cmd) { return m.createViewBansModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createDeleteUserModal() },
 		func() (modal.Modal, tea.Cmd) { return m.createDeleteChannelModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createAuditLogModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createManageRolesModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createLoginLockoutsModal() },
+		func() (modal.Modal, tea.Cmd) { return m.createDisableTwoFactorModal() },
 	)
 
 	return adminPanel
@@ -1363,7 +1929,11 @@ func (m *Model) createConfiguredAdminPanel() modal.Modal {
 
 // createBanUserModal creates a ban user modal with submit handler
 func (m *Model) createBanUserModal() (modal.Modal, tea.Cmd) {
-	banUserModal := modal.NewBanUserModal()
+	channelIDs := make(map[string]uint64, len(m.channels))
+	for _, ch := range m.channels {
+		channelIDs[ch.Name] = ch.ID
+	}
+	banUserModal := modal.NewBanUserModal(channelIDs)
 	banUserModal.SetSubmitHandler(func(msg *protocol.BanUserMessage) tea.Cmd {
 		m.statusMessage = "Banning user..."
 		return m.sendBanUser(msg)
@@ -1407,6 +1977,50 @@ func (m *Model) createViewBansModal() (modal.Modal, tea.Cmd) {
 	return viewBansModal, m.sendListBans(false)
 }
 
+// createAuditLogModal creates the audit log viewer and requests its first page
+func (m *Model) createAuditLogModal() (modal.Modal, tea.Cmd) {
+	auditLogModal := modal.NewAuditLogModal(func(msg *protocol.ListAdminActionsMessage) tea.Cmd {
+		return m.sendListAdminActions(msg)
+	})
+	return auditLogModal, m.sendListAdminActions(auditLogModal.Query())
+}
+
+// createManageRolesModal creates a manage roles modal with submit handler
+func (m *Model) createManageRolesModal() (modal.Modal, tea.Cmd) {
+	channelIDs := make(map[string]uint64, len(m.channels))
+	for _, ch := range m.channels {
+		channelIDs[ch.Name] = ch.ID
+	}
+	manageRolesModal := modal.NewManageRolesModal(channelIDs)
+	manageRolesModal.SetSubmitHandler(func(msg *protocol.SetUserFlagsMessage) tea.Cmd {
+		m.statusMessage = "Updating roles..."
+		return m.sendSetUserFlags(msg)
+	})
+	return manageRolesModal, nil
+}
+
+// createLoginLockoutsModal creates the login lockout list and requests its contents
+func (m *Model) createLoginLockoutsModal() (modal.Modal, tea.Cmd) {
+	loginLockoutsModal := modal.NewLoginLockoutsModal(
+		func() tea.Cmd { return m.sendListLoginLockouts() },
+		func(msg *protocol.ClearLoginLockoutMessage) tea.Cmd {
+			m.statusMessage = "Clearing lockout..."
+			return m.sendClearLoginLockout(msg)
+		},
+	)
+	return loginLockoutsModal, m.sendListLoginLockouts()
+}
+
+// createDisableTwoFactorModal creates the admin 2FA override with submit handler
+func (m *Model) createDisableTwoFactorModal() (modal.Modal, tea.Cmd) {
+	disableTwoFactorModal := modal.NewDisableTwoFactorModal()
+	disableTwoFactorModal.SetSubmitHandler(func(nickname string) tea.Cmd {
+		m.statusMessage = "Disabling two-factor authentication..."
+		return m.sendDisable2FAFor(nickname)
+	})
+	return disableTwoFactorModal, nil
+}
+
 // createListUsersModal creates a list users modal with handlers
 func (m *Model) createListUsersModal() (modal.Modal, tea.Cmd) {
 	listUsersModal := modal.NewListUsersModal()
@@ -1584,16 +2198,30 @@ func (m *Model) isSelfSession(sessionID uint64) bool {
 	return m.selfSessionID != nil && *m.selfSessionID == sessionID
 }
 
-func (m *Model) buildUserSidebarContent() string {
-	var entries []presenceEntry
-	var title string
+// sidebarEntries returns the users the sidebar shows: the current channel's, or
+// everyone online
+func (m *Model) sidebarEntries() []presenceEntry {
+	if m.currentChannel != nil && m.hasActiveChannel {
+		return m.sortedChannelPresence(m.currentChannel.ID)
+	}
+	return m.sortedServerPresence()
+}
 
+// withServerPresence fills in the profile of a channel roster entry
+func (m *Model) withServerPresence(entry presenceEntry) presenceEntry {
+	if server, ok := m.serverRoster[entry.SessionID]; ok {
+		entry.DisplayName = server.DisplayName
+		entry.Status = server.Status
+		entry.Away = server.Away
+	}
+	return entry
+}
+
+func (m *Model) buildUserSidebarContent() string {
+	entries := m.sidebarEntries()
+	title := fmt.Sprintf("Online Users (%d)", len(entries))
 	if m.currentChannel != nil && m.hasActiveChannel {
-		entries = m.sortedChannelPresence(m.currentChannel.ID)
 		title = fmt.Sprintf("Channel Users (%d)", len(entries))
-	} else {
-		entries = m.sortedServerPresence()
-		title = fmt.Sprintf("Online Users (%d)", len(entries))
 	}
 
 	b := strings.Builder{}
@@ -1616,17 +2244,30 @@ func (m *Model) buildUserSidebarContent() string {
 }
 
 func (m *Model) formatPresenceEntry(entry presenceEntry) string {
+	entry = m.withServerPresence(entry)
 	prefix := entry.UserFlags.DisplayPrefix()
 	displayName := entry.Nickname
 	if !entry.IsRegistered {
 		displayName = "~" + displayName
 	}
 	display := prefix + displayName
-	if m.isSelfSession(entry.SessionID) {
-		display += " (you)"
-		return PresenceSelfStyle.Render(display)
+	if entry.DisplayName != "" && entry.DisplayName != entry.Nickname {
+		display += " (" + entry.DisplayName + ")"
+	}
+
+	var line string
+	switch {
+	case m.isSelfSession(entry.SessionID):
+		line = PresenceSelfStyle.Render(display + " (you)")
+	case entry.Away:
+		line = MutedTextStyle.Render(display + " (away)")
+	default:
+		line = PresenceItemStyle.Render(display)
 	}
-	return PresenceItemStyle.Render(display)
+	if entry.Status != "" {
+		line += "\n" + MutedTextStyle.Render("    "+entry.Status)
+	}
+	return line
 }
 
 func (m *Model) adjustChannelUserCount(channelID uint64, delta int) {
@@ -1667,6 +2308,139 @@ func (m *Model) clearActiveChannel() {
 	m.activeChannelID = 0
 }
 
+// channelListRow is one row of the channel list: a channel, one of its subchannels when expanded,
+// or a direct message conversation
+type channelListRow struct {
+	channel    protocol.Channel
+	subchannel *protocol.Subchannel // nil for the channel row itself
+	dm         *protocol.DMChannel  // non-nil for direct message rows
+}
+
+// channelListRows flattens channels, their expanded subchannels and DMs in display order
+func (m Model) channelListRows() []channelListRow {
+	rows := make([]channelListRow, 0, len(m.channels)+len(m.dms))
+	for _, ch := range m.channels {
+		rows = append(rows, channelListRow{channel: ch})
+		if !m.expandedChannels[ch.ID] {
+			continue
+		}
+		subs := m.subchannels[ch.ID]
+		for i := range subs {
+			rows = append(rows, channelListRow{channel: ch, subchannel: &subs[i]})
+		}
+	}
+	for i := range m.dms {
+		// DMs behave like chat channels once opened
+		dm := &m.dms[i]
+		rows = append(rows, channelListRow{
+			channel: protocol.Channel{ID: dm.ChannelID, Name: dmDisplayName(*dm), Type: 0},
+			dm:      dm,
+		})
+	}
+	return rows
+}
+
+// dmDisplayName joins the other participants' nicknames for display
+func dmDisplayName(dm protocol.DMChannel) string {
+	if len(dm.Participants) == 0 {
+		return "(empty)"
+	}
+	names := make([]string, len(dm.Participants))
+	for i, p := range dm.Participants {
+		names[i] = p.Nickname
+	}
+	return strings.Join(names, ", ")
+}
+
+// currentSubchannelID returns the ID of the open subchannel, or nil at channel root
+func (m Model) currentSubchannelID() *uint64 {
+	if m.currentSubchannel == nil {
+		return nil
+	}
+	id := m.currentSubchannel.ID
+	return &id
+}
+
+// typingTargetFor returns where we're typing in the current channel or subchannel,
+// replying in the given thread (0 = chat or a new thread)
+func (m Model) typingTargetFor(threadID uint64) typingTarget {
+	target := typingTarget{threadID: threadID}
+	if m.currentChannel != nil {
+		target.channelID = m.currentChannel.ID
+	}
+	if m.currentSubchannel != nil {
+		target.subchannelID = m.currentSubchannel.ID
+	}
+	return target
+}
+
+// composeTypingTarget returns where the compose modal types, if it's a new thread or a
+// reply (editing isn't announced)
+func (m Model) composeTypingTarget(mode modal.ComposeMode) (typingTarget, bool) {
+	if m.currentChannel == nil {
+		return typingTarget{}, false
+	}
+	switch mode {
+	case modal.ComposeModeNewThread:
+		return m.typingTargetFor(0), true
+	case modal.ComposeModeReply:
+		if m.currentThread == nil {
+			return typingTarget{}, false
+		}
+		return m.typingTargetFor(m.currentThread.ID), true
+	}
+	return typingTarget{}, false
+}
+
+// typingText describes who is typing at target, e.g. "alice and bob are typing…"
+func (m Model) typingText(target typingTarget) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, user := range m.typingUsers {
+		if user.target == target && !seen[user.nickname] {
+			seen[user.nickname] = true
+			names = append(names, user.nickname)
+		}
+	}
+	sort.Strings(names)
+
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0] + " is typing…"
+	case 2:
+		return names[0] + " and " + names[1] + " are typing…"
+	case 3:
+		return names[0] + ", " + names[1] + " and " + names[2] + " are typing…"
+	default:
+		return fmt.Sprintf("%d people are typing…", len(names))
+	}
+}
+
+// isCurrentLocation reports whether the given channel/subchannel is the one currently open
+func (m Model) isCurrentLocation(channelID uint64, subchannelID *uint64) bool {
+	if m.currentChannel == nil || m.currentChannel.ID != channelID {
+		return false
+	}
+	if m.currentSubchannel == nil {
+		return subchannelID == nil
+	}
+	return subchannelID != nil && *subchannelID == m.currentSubchannel.ID
+}
+
+// currentLocationName returns a breadcrumb for the open channel (e.g. "#general > /support")
+func (m Model) currentLocationName() string {
+	if m.currentChannel == nil {
+		return ""
+	}
+	name := "#" + m.currentChannel.Name
+	if m.currentSubchannel != nil {
+		name += " > /" + m.currentSubchannel.Name
+	}
+	return name
+}
+
 // showComposeWithWarning shows the compose modal, potentially with registration warning first
 func (m *Model) showComposeWithWarning(mode modal.ComposeMode, initialContent string) {
 	if m.shouldShowRegistrationWarning() {
@@ -1795,7 +2569,7 @@ func tickCmd() tea.Cmd {
 func checkForUpdates(currentVersion string) tea.Cmd {
 	return func() tea.Msg {
 		// Check for updates in background (non-blocking)
-		latestVersion, err := updater.CheckLatestVersion()
+		latestVersion, err := updater.CheckLatestVersion(currentVersion)
 		if err != nil {
 			// Silently fail - don't bother user with update check failures
 			return nil
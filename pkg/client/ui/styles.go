@@ -1,205 +1,309 @@
 package ui
 
 import (
+	"github.com/aeolun/superchat/pkg/client"
+	"github.com/aeolun/superchat/pkg/client/ui/modal"
 	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	// Color scheme (exported for view package)
-	PrimaryColor   = lipgloss.Color("39")  // Blue
-	SecondaryColor = lipgloss.Color("213") // Pink
-	SuccessColor   = lipgloss.Color("42")  // Green
-	ErrorColor     = lipgloss.Color("196") // Red
-	WarningColor   = lipgloss.Color("214") // Orange
-	MutedColor     = lipgloss.Color("243") // Gray
-	BorderColor    = lipgloss.Color("238") // Dark gray
+	// Color scheme (exported for view package), set by ApplyTheme
+	PrimaryColor   lipgloss.Color // Titles, shortcuts, selection
+	SecondaryColor lipgloss.Color // Author names
+	AccentColor    lipgloss.Color // Dialog borders, focused input
+	HighlightColor lipgloss.Color // Selected items in dialogs
+	SuccessColor   lipgloss.Color
+	ErrorColor     lipgloss.Color
+	WarningColor   lipgloss.Color
+	MutedColor     lipgloss.Color
+	BorderColor    lipgloss.Color
+	TextColor      lipgloss.Color // Message content and body text
+
+	// Styles (exported for view package), built from the colors by ApplyTheme
+	BaseStyle lipgloss.Style
+
+	HeaderStyle, StatusStyle, FooterStyle, ShortcutKeyStyle, ShortcutDescStyle lipgloss.Style
+
+	SelectedItemStyle, UnselectedItemStyle lipgloss.Style
+
+	ChannelPaneStyle, ChannelTitleStyle, ChannelItemStyle lipgloss.Style
+
+	UserSidebarStyle, UserSidebarTitleStyle, PresenceItemStyle, PresenceSelfStyle lipgloss.Style
+
+	ThreadPaneStyle, ActualThreadStyle, ThreadTitleStyle lipgloss.Style
+
+	MessageAuthorStyle, MessageAnonymousStyle, MessageOwnAuthorStyle, MessageTimeStyle,
+	MessageContentStyle, MessageDepthStyle, MessageReactionStyle, MessageOwnReactionStyle lipgloss.Style
+
+	MarkdownCodeStyle, MarkdownLinkStyle, MarkdownURLStyle, MarkdownQuoteStyle, MarkdownMarkerStyle,
+	MarkdownKeywordStyle, MarkdownStringStyle, MarkdownCommentStyle, MarkdownNumberStyle lipgloss.Style
+
+	ModalStyle, ModalTitleStyle lipgloss.Style
+
+	InputStyle, InputFocusedStyle, InputBlurredStyle lipgloss.Style
+
+	ErrorStyle, SuccessStyle, WarningStyle lipgloss.Style
+
+	HelpTitleStyle, HelpKeyStyle, HelpDescStyle lipgloss.Style
+
+	SplashTitleStyle, SplashBodyStyle, SplashPromptStyle lipgloss.Style
+
+	MutedTextStyle, SpinnerStyle lipgloss.Style
+)
+
+// Styles holds all UI styles including spinner
+var Styles struct {
+	Spinner lipgloss.Style
+}
+
+func init() {
+	ApplyTheme(client.DefaultTheme())
+}
+
+// ApplyTheme sets the UI colors and rebuilds the styles from them. Call it before
+// creating the model; views read the styles when they render.
+func ApplyTheme(theme client.Theme) {
+	PrimaryColor = lipgloss.Color(theme.Primary)
+	SecondaryColor = lipgloss.Color(theme.Secondary)
+	AccentColor = lipgloss.Color(theme.Accent)
+	HighlightColor = lipgloss.Color(theme.Highlight)
+	SuccessColor = lipgloss.Color(theme.Success)
+	ErrorColor = lipgloss.Color(theme.Error)
+	WarningColor = lipgloss.Color(theme.Warning)
+	MutedColor = lipgloss.Color(theme.Muted)
+	BorderColor = lipgloss.Color(theme.Border)
+	TextColor = lipgloss.Color(theme.Text)
 
 	// Base styles
 	BaseStyle = lipgloss.NewStyle()
 
 	// Header styles (exported for view package)
 	HeaderStyle = BaseStyle.Copy().
-			Bold(true).
-			Foreground(PrimaryColor).
-			Padding(0, 1)
+		Bold(true).
+		Foreground(PrimaryColor).
+		Padding(0, 1)
 
 	StatusStyle = BaseStyle.Copy().
-			Foreground(MutedColor).
-			Padding(0, 1)
+		Foreground(MutedColor).
+		Padding(0, 1)
 
 	// Footer styles (exported for view package)
 	FooterStyle = BaseStyle.Copy().
-			Foreground(MutedColor).
-			Padding(0, 1)
+		Foreground(MutedColor).
+		Padding(0, 1)
 
 	ShortcutKeyStyle = BaseStyle.Copy().
-				Foreground(PrimaryColor).
-				Bold(true)
+		Foreground(PrimaryColor).
+		Bold(true)
 
 	ShortcutDescStyle = BaseStyle.Copy().
-				Foreground(lipgloss.Color("252"))
+		Foreground(TextColor)
 
 	// List styles (exported for view package)
 	SelectedItemStyle = BaseStyle.Copy().
-				Foreground(PrimaryColor).
-				Bold(true)
+		Foreground(PrimaryColor).
+		Bold(true)
 
 	UnselectedItemStyle = BaseStyle.Copy().
-				Foreground(lipgloss.Color("252"))
+		Foreground(TextColor)
 
 	// Channel list styles (exported for view package)
 	ChannelPaneStyle = BaseStyle.Copy().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(BorderColor).
-				Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor).
+		Padding(0, 1)
 
 	ChannelTitleStyle = BaseStyle.Copy().
-				Bold(true).
-				Foreground(PrimaryColor)
+		Bold(true).
+		Foreground(PrimaryColor)
 
 	ChannelItemStyle = BaseStyle.Copy()
 
 	UserSidebarStyle = BaseStyle.Copy().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(BorderColor).
-				Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor).
+		Padding(0, 1)
 
 	UserSidebarTitleStyle = BaseStyle.Copy().
-				Bold(true).
-				Foreground(PrimaryColor)
+		Bold(true).
+		Foreground(PrimaryColor)
 
 	PresenceItemStyle = BaseStyle.Copy().
-				Foreground(lipgloss.Color("252"))
+		Foreground(TextColor)
 
 	PresenceSelfStyle = PresenceItemStyle.Copy().
-				Foreground(SuccessColor).
-				Bold(true)
+		Foreground(SuccessColor).
+		Bold(true)
 
 	// Thread list styles (exported for view package)
 	ThreadPaneStyle = BaseStyle.Copy().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(BorderColor)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor)
 
 	ActualThreadStyle = BaseStyle.Copy().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(BorderColor).
-				Padding(0, 1) // Top/bottom padding only, no left/right padding
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor).
+		Padding(0, 1) // Top/bottom padding only, no left/right padding
 
 	ThreadTitleStyle = BaseStyle.Copy().
-				Bold(true).
-				Foreground(PrimaryColor).
-				MarginBottom(1)
+		Bold(true).
+		Foreground(PrimaryColor).
+		MarginBottom(1)
 
 	// Message styles (exported for view package)
 	MessageAuthorStyle = BaseStyle.Copy().
-				Foreground(SecondaryColor)
+		Foreground(SecondaryColor)
 
 	MessageAnonymousStyle = BaseStyle.Copy().
-				Foreground(SecondaryColor)
+		Foreground(SecondaryColor)
 
 	MessageOwnAuthorStyle = BaseStyle.Copy().
-				Foreground(SuccessColor).
-				Bold(true)
+		Foreground(SuccessColor).
+		Bold(true)
 
 	MessageTimeStyle = BaseStyle.Copy().
-				Foreground(MutedColor).
-				Italic(true)
+		Foreground(MutedColor).
+		Italic(true)
 
 	MessageContentStyle = BaseStyle.Copy().
-				Foreground(lipgloss.Color("252"))
+		Foreground(TextColor)
 
 	MessageDepthStyle = BaseStyle.Copy().
-				Foreground(MutedColor)
+		Foreground(MutedColor)
+
+	MessageReactionStyle = BaseStyle.Copy().
+		Foreground(MutedColor)
+
+	MessageOwnReactionStyle = BaseStyle.Copy().
+		Foreground(PrimaryColor).
+		Bold(true)
+
+	// Markdown styles for message content
+	MarkdownCodeStyle = BaseStyle.Copy().
+		Foreground(WarningColor)
+
+	MarkdownLinkStyle = BaseStyle.Copy().
+		Foreground(PrimaryColor).
+		Underline(true)
+
+	MarkdownURLStyle = BaseStyle.Copy().
+		Foreground(MutedColor)
+
+	MarkdownQuoteStyle = BaseStyle.Copy().
+		Foreground(MutedColor)
+
+	MarkdownMarkerStyle = BaseStyle.Copy().
+		Foreground(SecondaryColor)
+
+	MarkdownKeywordStyle = BaseStyle.Copy().
+		Foreground(PrimaryColor).
+		Bold(true)
+
+	MarkdownStringStyle = BaseStyle.Copy().
+		Foreground(SuccessColor)
+
+	MarkdownCommentStyle = BaseStyle.Copy().
+		Foreground(MutedColor).
+		Italic(true)
+
+	MarkdownNumberStyle = BaseStyle.Copy().
+		Foreground(WarningColor)
 
 	// Modal styles (exported for view package)
 	// Note: Width sets content width, border (2 chars) is added on top
 	ModalStyle = BaseStyle.Copy().
-			Border(lipgloss.DoubleBorder()).
-			BorderForeground(PrimaryColor).
-			Padding(1, 2).
-			Width(58) // 58 + 2 (border) = 60 total
+		Border(lipgloss.DoubleBorder()).
+		BorderForeground(PrimaryColor).
+		Padding(1, 2).
+		Width(58) // 58 + 2 (border) = 60 total
 
 	ModalTitleStyle = BaseStyle.Copy().
-			Bold(true).
-			Foreground(PrimaryColor).
-			MarginBottom(1)
+		Bold(true).
+		Foreground(PrimaryColor).
+		MarginBottom(1)
 
 	// Input styles (exported for view package)
 	InputStyle = BaseStyle.Copy().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(BorderColor).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor).
+		Padding(0, 1)
 
 	InputFocusedStyle = BaseStyle.Copy().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(PrimaryColor).
-				Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(PrimaryColor).
+		Padding(0, 1)
 
 	InputBlurredStyle = BaseStyle.Copy().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(BorderColor).
-				Foreground(MutedColor).
-				Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor).
+		Foreground(MutedColor).
+		Padding(0, 1)
 
 	// Error/success styles (exported for view package)
 	ErrorStyle = BaseStyle.Copy().
-			Foreground(ErrorColor).
-			Bold(true)
+		Foreground(ErrorColor).
+		Bold(true)
 
 	SuccessStyle = BaseStyle.Copy().
-			Foreground(SuccessColor).
-			Bold(true)
+		Foreground(SuccessColor).
+		Bold(true)
 
 	WarningStyle = BaseStyle.Copy().
-			Foreground(WarningColor).
-			Bold(true)
+		Foreground(WarningColor).
+		Bold(true)
 
 	// Help styles (exported for view package)
 	HelpTitleStyle = BaseStyle.Copy().
-			Bold(true).
-			Foreground(PrimaryColor).
-			MarginBottom(1)
+		Bold(true).
+		Foreground(PrimaryColor).
+		MarginBottom(1)
 
 	HelpKeyStyle = BaseStyle.Copy().
-			Foreground(PrimaryColor).
-			Bold(true).
-			Width(12)
+		Foreground(PrimaryColor).
+		Bold(true).
+		Width(12)
 
 	HelpDescStyle = BaseStyle.Copy().
-			Foreground(lipgloss.Color("252"))
+		Foreground(TextColor)
 
 	// Splash screen styles (exported for view package)
 	SplashTitleStyle = BaseStyle.Copy().
-				Bold(true).
-				Foreground(PrimaryColor).
-				Align(lipgloss.Center).
-				MarginBottom(2)
+		Bold(true).
+		Foreground(PrimaryColor).
+		Align(lipgloss.Center).
+		MarginBottom(2)
 
 	SplashBodyStyle = BaseStyle.Copy().
-			Foreground(lipgloss.Color("252")).
-			Align(lipgloss.Left).
-			MarginBottom(1)
+		Foreground(TextColor).
+		Align(lipgloss.Left).
+		MarginBottom(1)
 
 	SplashPromptStyle = BaseStyle.Copy().
-				Foreground(MutedColor).
-				Italic(true).
-				Align(lipgloss.Center).
-				MarginTop(2)
+		Foreground(MutedColor).
+		Italic(true).
+		Align(lipgloss.Center).
+		MarginTop(2)
 
 	// Muted text style (exported for view package)
 	MutedTextStyle = BaseStyle.Copy().
-			Foreground(MutedColor)
+		Foreground(MutedColor)
 
 	// Spinner style (exported for view package)
 	SpinnerStyle = BaseStyle.Copy().
-			Foreground(PrimaryColor)
-)
-
-// Styles holds all UI styles including spinner
-var Styles = struct {
-	Spinner lipgloss.Style
-}{
-	Spinner: SpinnerStyle,
+		Foreground(PrimaryColor)
+
+	Styles.Spinner = SpinnerStyle
+
+	modal.SetPalette(modal.Palette{
+		Primary:   PrimaryColor,
+		Accent:    AccentColor,
+		Highlight: HighlightColor,
+		Success:   SuccessColor,
+		Error:     ErrorColor,
+		Warning:   WarningColor,
+		Muted:     MutedColor,
+		Text:      TextColor,
+	})
 }
 
 // RenderShortcut renders a keyboard shortcut
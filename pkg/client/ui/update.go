@@ -13,6 +13,7 @@ import (
 	"github.com/aeolun/superchat/pkg/client/commands"
 	"github.com/aeolun/superchat/pkg/client/ui/modal"
 	"github.com/aeolun/superchat/pkg/protocol"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/gen2brain/beeep"
@@ -51,7 +52,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		// Initialize or resize chat viewport (message area only, input is separate)
-		chatHeight := msg.Height - 6 - 3 // Reserve 3 lines for input field
+		chatHeight := msg.Height - 6 - 3 - 1 // Reserve 3 lines for input field and 1 for who is typing
 		if chatHeight < 5 {
 			chatHeight = 5
 		}
@@ -167,6 +168,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case TickMsg:
+		// Forget typing indicators the server didn't repeat or stop
+		expired := false
+		for sessionID, user := range m.typingUsers {
+			if time.Time(msg).After(user.expiresAt) {
+				delete(m.typingUsers, sessionID)
+				expired = true
+			}
+		}
+		if expired {
+			m.refreshComposeTyping()
+		}
+
 		// Check if we need to send a ping (only if connected)
 		if m.connectionState == StateConnected {
 			now := time.Time(msg)
@@ -220,6 +233,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.pendingNickname = msg.Nickname
 		return m, nil
 
+	case SearchJumpMsg:
+		return m.jumpToSearchResult(msg.Result)
+
+	case MentionJumpMsg:
+		return m.jumpToMention(msg.Mention)
+
+	case MentionsReadMsg:
+		m.markMentionsReadLocally(len(msg.MessageIDs))
+		return m, nil
+
+	case KeyTrustedMsg:
+		m.answerKeyExchange(msg.Exchange)
+		return m, nil
+
 	case GoAnonymousMsg:
 		// User chose to browse anonymously instead of authenticating
 		// The nickname is already set on the server - we just reset auth state
@@ -234,6 +261,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.userID = nil
 		m.userFlags = 0
 		m.state.SetUserID(nil)
+		m.dms = nil
 
 		// If we have a pending nickname (server accepted it but we haven't processed NICKNAME_RESPONSE yet),
 		// update to it now so the UI shows the correct nickname
@@ -698,6 +726,10 @@ func (m Model) handleChatChannelKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.sendLeaveChannel(channelID),
 				m.sendUnsubscribeChannel(channelID),
 			)
+			if !m.chatTypingAt.IsZero() {
+				cmd = tea.Batch(cmd, m.sendTyping(m.typingTargetFor(0), false))
+				m.chatTypingAt = time.Time{}
+			}
 			m.clearActiveChannel()
 		}
 		m.currentChannel = nil
@@ -710,6 +742,9 @@ func (m Model) handleChatChannelKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Send message if input is not empty
 		content := strings.TrimSpace(m.chatTextarea.Value())
 		if content != "" {
+			// The server stops our typing indicator when the message arrives
+			m.chatTypingAt = time.Time{}
+
 			// Check if we should show registration warning
 			if m.shouldShowRegistrationWarning() {
 				// Store content and show warning
@@ -735,7 +770,11 @@ func (m Model) handleChatChannelKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	default:
 		// Pass all other keys to the textarea
+		before := m.chatTextarea.Value()
 		m.chatTextarea, cmd = m.chatTextarea.Update(msg)
+		if m.chatTextarea.Value() != before {
+			cmd = tea.Batch(cmd, m.updateChatTyping())
+		}
 		return m, cmd
 	}
 }
@@ -751,15 +790,20 @@ func (m Model) sendChatMessageWithContent(content string) (Model, tea.Cmd) {
 	}
 
 	// Send POST_MESSAGE
-	msg := &protocol.PostMessageMessage{
-		ChannelID:    m.currentChannel.ID,
-		SubchannelID: nil,
-		ParentID:     nil, // Chat channels have no threading
-		Content:      content,
-	}
+	channelID := m.currentChannel.ID
+	subchannelID := m.currentSubchannelID()
+	m.lastSent = &sentContent{chat: true, content: content}
 
 	return m, func() tea.Msg {
-		if err := m.conn.SendMessage(protocol.TypePostMessage, msg); err != nil {
+		err := m.sendContent(protocol.TypePostMessage, channelID, content, func(content string) protocol.ProtocolMessage {
+			return &protocol.PostMessageMessage{
+				ChannelID:    channelID,
+				SubchannelID: subchannelID,
+				ParentID:     nil, // Chat channels have no threading
+				Content:      content,
+			}
+		})
+		if err != nil {
 			return ErrorMsg{Err: err}
 		}
 		return nil
@@ -805,6 +849,11 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m, listenForServerFrames(m.conn, m.connGeneration)
 	}
 
+	// Encrypted DM content is decrypted before any handler sees it
+	if err := m.keyring.DecryptFrame(frame); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decrypt message: %v", err)
+	}
+
 	switch frame.Type {
 	case protocol.TypeServerConfig:
 		return m.handleServerConfig(frame)
@@ -822,6 +871,10 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleServerList(frame)
 	case protocol.TypeChannelCreated:
 		return m.handleChannelCreated(frame)
+	case protocol.TypeSubchannelList:
+		return m.handleSubchannelList(frame)
+	case protocol.TypeSubchannelCreated:
+		return m.handleSubchannelCreated(frame)
 	case protocol.TypeChannelDeleted:
 		return m.handleChannelDeleted(frame)
 	case protocol.TypeJoinResponse:
@@ -838,6 +891,8 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleMessageEdited(frame)
 	case protocol.TypeMessageDeleted:
 		return m.handleMessageDeleted(frame)
+	case protocol.TypeMessagesExpired:
+		return m.handleMessagesExpired(frame)
 	case protocol.TypeSubscribeOk:
 		return m.handleSubscribeOk(frame)
 	case protocol.TypeError:
@@ -860,6 +915,24 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleIPUnbanned(frame)
 	case protocol.TypeBanList:
 		return m.handleBanList(frame)
+	case protocol.TypeAdminActionList:
+		return m.handleAdminActionList(frame)
+	case protocol.TypeUserFlagsUpdated:
+		return m.handleUserFlagsUpdated(frame)
+	case protocol.TypeLoginLockoutList:
+		return m.handleLoginLockoutList(frame)
+	case protocol.TypeLoginLockoutCleared:
+		return m.handleLoginLockoutCleared(frame)
+	case protocol.TypeTwoFactorSetup:
+		return m.handleTwoFactorSetup(frame)
+	case protocol.TypeTwoFactorEnabled:
+		return m.handleTwoFactorEnabled(frame)
+	case protocol.TypeTwoFactorDisabled:
+		return m.handleTwoFactorDisabled(frame)
+	case protocol.TypeProfileUpdated:
+		return m.handleProfileUpdated(frame)
+	case protocol.TypeUserTyping:
+		return m.handleUserTyping(frame)
 	case protocol.TypeUserList:
 		return m.handleUserList(frame)
 	case protocol.TypeUserDeleted:
@@ -872,6 +945,28 @@ func (m Model) handleServerFrame(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m.handleChannelPresence(frame)
 	case protocol.TypeServerPresence:
 		return m.handleServerPresence(frame)
+	case protocol.TypeDMList:
+		return m.handleDMList(frame)
+	case protocol.TypeDMReady:
+		return m.handleDMReady(frame)
+	case protocol.TypeDMRequest:
+		return m.handleDMRequest(frame)
+	case protocol.TypeDMPending:
+		return m.handleDMPending(frame)
+	case protocol.TypeKeyRequired:
+		return m.handleKeyRequired(frame)
+	case protocol.TypeDMKeyExchange:
+		return m.handleDMKeyExchange(frame)
+	case protocol.TypeSearchResults:
+		return m.handleSearchResults(frame)
+	case protocol.TypeReactionsUpdated:
+		return m.handleReactionsUpdated(frame)
+	case protocol.TypeMentionList:
+		return m.handleMentionList(frame)
+	case protocol.TypeMentionNotification:
+		return m.handleMentionNotification(frame)
+	case protocol.TypeMessageHistory:
+		return m.handleMessageHistory(frame)
 	case protocol.TypeUnreadCounts:
 		return m.handleUnreadCounts(frame)
 	}
@@ -981,6 +1076,10 @@ func (m Model) handleUserInfo(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		m.logger.Printf("[DEBUG] Current nickname=%s, pending=%s", m.nickname, m.pendingNickname)
 	}
 
+	if profileModal, ok := m.modalStack.Top().(*modal.ProfileModal); ok {
+		profileModal.SetUserInfo(msg)
+	}
+
 	// Update our tracking of whether this nickname is registered
 	// Only update if this is info about our current or pending nickname
 	if msg.Nickname == m.nickname || msg.Nickname == m.pendingNickname {
@@ -1021,6 +1120,21 @@ type GoAnonymousMsg struct {
 	TargetNickname string // The registered nickname they were trying to use
 }
 
+// SearchJumpMsg is sent when the user opens a search result
+type SearchJumpMsg struct {
+	Result protocol.SearchResult
+}
+
+// MentionJumpMsg is sent when the user opens a mention from the inbox
+type MentionJumpMsg struct {
+	Mention protocol.Mention
+}
+
+// MentionsReadMsg is sent after mentions were marked read (all of them if MessageIDs is empty)
+type MentionsReadMsg struct {
+	MessageIDs []uint64
+}
+
 // handleAuthResponse processes AUTH_RESPONSE
 func (m Model) handleAuthResponse(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.AuthResponseMessage{}
@@ -1072,10 +1186,26 @@ func (m Model) handleAuthResponse(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 
 		// Close password modal if it's open
 		m.modalStack.RemoveByType(modal.ModalPasswordAuth)
+		m.modalStack.RemoveByType(modal.ModalTwoFactorAuth)
+
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setupDMs(), m.sendListMentions(true, 1))
+	} else if msg.TwoFactorRequired {
+		// Password accepted, the server wants the two-factor code. The server locks
+		// out guessing, so codes don't count toward the client's backoff.
+		m.authState = AuthStatePrompting
+		m.authErrorMessage = ""
+		retry := m.modalStack.TopType() == modal.ModalTwoFactorAuth
+		errorMessage := ""
+		if retry {
+			errorMessage = msg.Message
+		}
+		m.modalStack.RemoveByType(modal.ModalPasswordAuth)
+		m.showTwoFactorAuthModal(errorMessage)
 	} else {
 		m.userFlags = 0
 		// Authentication failed
 		m.authState = AuthStatePrompting
+		m.modalStack.RemoveByType(modal.ModalTwoFactorAuth)
 		m.authAttempts++
 		m.authErrorMessage = msg.Message
 
@@ -1119,6 +1249,8 @@ func (m Model) handleRegisterResponse(frame *protocol.Frame) (tea.Model, tea.Cmd
 
 		// Close registration modal if it's open
 		m.modalStack.RemoveByType(modal.ModalRegistration)
+
+		return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.setupDMs(), m.sendListMentions(true, 1))
 	} else {
 		m.userFlags = 0
 		// Registration failed - close modal and show error
@@ -1142,6 +1274,14 @@ func (m Model) handleChannelList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	m.channels = msg.Channels
 	m.statusMessage = fmt.Sprintf("Loaded %d channels", len(m.channels))
 
+	// Request subchannels for each channel (responses carry channel_id, so order doesn't matter)
+	for _, channel := range m.channels {
+		if err := m.conn.SendMessage(protocol.TypeGetSubchannels, &protocol.GetSubchannelsMessage{ChannelID: channel.ID}); err != nil {
+			m.errorMessage = fmt.Sprintf("Failed to request subchannels: %v", err)
+			break
+		}
+	}
+
 	// Request unread counts for all channels (server will use stored state for registered users)
 	if len(m.channels) > 0 {
 		targets := make([]protocol.UnreadTarget, len(m.channels))
@@ -1234,6 +1374,474 @@ func (m Model) handleChannelCreated(frame *protocol.Frame) (tea.Model, tea.Cmd)
 	return m, listenForServerFrames(m.conn, m.connGeneration)
 }
 
+// handleSubchannelList processes SUBCHANNEL_LIST
+func (m Model) handleSubchannelList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.SubchannelListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode subchannel list: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if len(msg.Subchannels) == 0 {
+		delete(m.subchannels, msg.ChannelID)
+		delete(m.expandedChannels, msg.ChannelID)
+	} else {
+		m.subchannels[msg.ChannelID] = msg.Subchannels
+	}
+
+	// Keep the cursor on a visible row if the list shrank
+	if rows := len(m.channelListRows()); m.channelCursor >= rows && rows > 0 {
+		m.channelCursor = rows - 1
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleSubchannelCreated processes SUBCHANNEL_CREATED (response + broadcast)
+func (m Model) handleSubchannelCreated(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.SubchannelCreatedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode subchannel created: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if msg.Success {
+		// Close the create subchannel modal if it's open
+		m.modalStack.RemoveByType(modal.ModalCreateSubchannel)
+
+		newSubchannel := protocol.Subchannel{
+			ID:             msg.SubchannelID,
+			Name:           msg.Name,
+			Description:    msg.Description,
+			Type:           msg.Type,
+			RetentionHours: msg.RetentionHours,
+		}
+		subs := append(m.subchannels[msg.ChannelID], newSubchannel)
+		sort.Slice(subs, func(i, j int) bool {
+			return subs[i].Name < subs[j].Name
+		})
+		m.subchannels[msg.ChannelID] = subs
+
+		m.statusMessage = msg.Message
+	} else {
+		// Keep modal open but show error
+		m.errorMessage = msg.Message
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleDMList processes DM_LIST
+func (m Model) handleDMList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.DMListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode DM list: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.dms = msg.DMs
+	for _, dm := range m.dms {
+		if dm.ChannelKey != nil {
+			if err := m.keyring.StoreWrappedKey(dm.ChannelID, *dm.ChannelKey); err != nil {
+				m.errorMessage = fmt.Sprintf("Failed to unlock encrypted conversation: %v", err)
+			}
+		}
+	}
+
+	// Keep the cursor on a visible row if the list shrank
+	if rows := len(m.channelListRows()); m.channelCursor >= rows && rows > 0 {
+		m.channelCursor = rows - 1
+	}
+
+	// Request unread counts for DMs (they're not part of CHANNEL_LIST)
+	if len(m.dms) > 0 {
+		targets := make([]protocol.UnreadTarget, len(m.dms))
+		for i, dm := range m.dms {
+			targets[i] = protocol.UnreadTarget{ChannelID: dm.ChannelID}
+		}
+		unreadMsg := &protocol.GetUnreadCountsMessage{Targets: targets}
+		if err := m.conn.SendMessage(protocol.TypeGetUnreadCounts, unreadMsg); err != nil {
+			m.errorMessage = fmt.Sprintf("Failed to request unread counts: %v", err)
+		}
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleDMReady processes DM_READY (a DM we're part of can now be used)
+func (m Model) handleDMReady(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.DMReadyMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode DM_READY: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if msg.ChannelKey != nil {
+		if err := m.keyring.StoreWrappedKey(msg.ChannelID, *msg.ChannelKey); err != nil {
+			m.errorMessage = fmt.Sprintf("Failed to unlock encrypted conversation: %v", err)
+		}
+	}
+
+	m.modalStack.RemoveByType(modal.ModalStartDM)
+	m.statusMessage = fmt.Sprintf("Direct message with %s ready", msg.OtherNickname)
+	if msg.IsEncrypted {
+		m.statusMessage = fmt.Sprintf("Encrypted direct message with %s ready", msg.OtherNickname)
+	}
+
+	// Refresh the DM list so the conversation shows up with its participants
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.requestDMList())
+}
+
+// handleDMRequest processes DM_REQUEST (another user started a DM with us)
+func (m Model) handleDMRequest(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.DMRequestMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode DM_REQUEST: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.statusMessage = fmt.Sprintf("%s started a direct message with you", msg.FromNickname)
+
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.requestDMList())
+}
+
+// handleDMPending processes DM_PENDING (our DM is waiting for the other side's key)
+func (m Model) handleDMPending(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.DMPendingMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode DM_PENDING: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.modalStack.RemoveByType(modal.ModalStartDM)
+	m.statusMessage = fmt.Sprintf("Waiting for %s to set up encryption", msg.WaitingForNickname)
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleKeyRequired processes KEY_REQUIRED (the server has no encryption key for us)
+func (m Model) handleKeyRequired(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.KeyRequiredMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode KEY_REQUIRED: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	// Our key is normally uploaded right after login, so just (re)send it
+	return m, tea.Batch(listenForServerFrames(m.conn, m.connGeneration), m.sendPublicKey())
+}
+
+// handleDMKeyExchange processes DM_KEY_EXCHANGE (wrap the channel key for new participant keys)
+func (m Model) handleDMKeyExchange(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.DMKeyExchangeMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode DM_KEY_EXCHANGE: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.answerKeyExchange(msg)
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// KeyTrustedMsg is sent when the user trusted a participant's new key, to answer the
+// key exchange that asked for it again
+type KeyTrustedMsg struct {
+	Exchange *protocol.DMKeyExchangeMessage
+}
+
+// answerKeyExchange shares the channel key with the trusted keys of a DM_KEY_EXCHANGE
+// and asks the user about new keys of participants whose keys they already trust
+func (m *Model) answerKeyExchange(msg *protocol.DMKeyExchangeMessage) {
+	resp, untrusted, err := m.keyring.HandleKeyExchange(msg)
+	if err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to share conversation key: %v", err)
+		return
+	}
+	if len(resp.Keys) > 0 {
+		if err := m.conn.SendMessage(protocol.TypeProvideChannelKeys, resp); err != nil {
+			m.errorMessage = fmt.Sprintf("Failed to share conversation key: %v", err)
+		}
+	}
+	if len(untrusted) == 0 {
+		return
+	}
+
+	// One key at a time; trusting it answers the exchange again, which brings up the next
+	key := untrusted[0]
+	ownFingerprint, _ := m.keyring.Fingerprint()
+	keyring := m.keyring
+	m.modalStack.RemoveByType(modal.ModalTrustKey)
+	m.modalStack.Push(modal.NewTrustKeyModal(key.Nickname, key.Fingerprint, key.Trusted, ownFingerprint, func() tea.Cmd {
+		return func() tea.Msg {
+			if err := keyring.TrustKey(key.UserID, key.PublicKey); err != nil {
+				return ErrorMsg{Err: fmt.Errorf("failed to trust key: %w", err)}
+			}
+			return KeyTrustedMsg{Exchange: msg}
+		}
+	}))
+	m.statusMessage = fmt.Sprintf("%s has a new encryption key (%s)", key.Nickname, key.Fingerprint)
+}
+
+// searchJumpReplyLimit is how many replies are loaded when jumping to a search result
+const searchJumpReplyLimit = 500
+
+// handleSearchResults processes SEARCH_RESULTS
+func (m Model) handleSearchResults(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.SearchResultsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode SEARCH_RESULTS: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.searchThreads = make(map[uint64]protocol.Message, len(msg.Threads))
+	for _, thread := range msg.Threads {
+		m.searchThreads[thread.ID] = thread
+	}
+
+	if searchModal, ok := m.modalStack.Top().(*modal.SearchModal); ok {
+		entries := make([]modal.SearchResultEntry, len(msg.Results))
+		for i, result := range msg.Results {
+			entries[i] = modal.SearchResultEntry{
+				Result:   result,
+				Location: m.searchResultLocation(result.Message),
+			}
+		}
+		searchModal.SetResults(entries)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleMentionList processes MENTION_LIST
+func (m Model) handleMentionList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MentionListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode MENTION_LIST: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.unreadMentions = msg.UnreadCount
+	m.mentionThreads = make(map[uint64]protocol.Message, len(msg.Threads))
+	for _, thread := range msg.Threads {
+		m.mentionThreads[thread.ID] = thread
+	}
+
+	if mentionsModal, ok := m.modalStack.Top().(*modal.MentionsModal); ok {
+		entries := make([]modal.MentionEntry, len(msg.Mentions))
+		for i, mention := range msg.Mentions {
+			entries[i] = modal.MentionEntry{
+				Mention:  mention,
+				Location: m.searchResultLocation(mention.Message),
+			}
+		}
+		mentionsModal.SetMentions(entries, msg.UnreadCount)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleMentionNotification processes MENTION_NOTIFICATION
+func (m Model) handleMentionNotification(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MentionNotificationMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode MENTION_NOTIFICATION: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	mentioned := msg.Mention.Message
+	m.unreadMentions = msg.UnreadCount
+	m.statusMessage = fmt.Sprintf("%s mentioned you in %s (Ctrl+O to view)", mentioned.AuthorNickname, m.searchResultLocation(mentioned))
+	if m.shouldNotifyForMessage(mentioned) {
+		m.sendDesktopNotification(mentioned)
+	}
+
+	cmds := []tea.Cmd{listenForServerFrames(m.conn, m.connGeneration)}
+	// Reload an open inbox so the new mention comes with its thread
+	if _, ok := m.modalStack.Top().(*modal.MentionsModal); ok {
+		cmds = append(cmds, m.sendListMentions(false, 0))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// handleMessageHistory processes MESSAGE_HISTORY
+func (m Model) handleMessageHistory(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MessageHistoryMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode MESSAGE_HISTORY: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if historyModal, ok := m.modalStack.Top().(*modal.MessageHistoryModal); ok && historyModal.MessageID() == msg.MessageID {
+		historyModal.SetRevisions(msg.Revisions)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// jumpToMention marks a mention read and opens the message
+func (m Model) jumpToMention(mention protocol.Mention) (tea.Model, tea.Cmd) {
+	var markRead tea.Cmd
+	if !mention.Read {
+		m.markMentionsReadLocally(1)
+		markRead = m.sendMarkMentionsRead([]uint64{mention.Message.ID})
+	}
+	model, cmd := m.jumpToMessage(mention.Message, mention.ThreadRootID, m.mentionThreads)
+	return model, tea.Batch(markRead, cmd)
+}
+
+// markMentionsReadLocally updates the unread mention count after count mentions were
+// marked read (0 = all of them)
+func (m *Model) markMentionsReadLocally(count int) {
+	if count == 0 || uint32(count) >= m.unreadMentions {
+		m.unreadMentions = 0
+		return
+	}
+	m.unreadMentions -= uint32(count)
+}
+
+// searchResultLocation names the channel a search result was found in
+func (m Model) searchResultLocation(msg protocol.Message) string {
+	for _, ch := range m.channels {
+		if ch.ID != msg.ChannelID {
+			continue
+		}
+		name := "#" + ch.Name
+		if msg.SubchannelID != nil {
+			for _, sub := range m.subchannels[ch.ID] {
+				if sub.ID == *msg.SubchannelID {
+					name += " > /" + sub.Name
+				}
+			}
+		}
+		return name
+	}
+	for _, dm := range m.dms {
+		if dm.ChannelID == msg.ChannelID {
+			return "@" + dmDisplayName(dm)
+		}
+	}
+	return fmt.Sprintf("#%d", msg.ChannelID)
+}
+
+// jumpToSearchResult opens the channel of a search result and, in forum channels,
+// the thread it belongs to with the matching reply selected
+func (m Model) jumpToSearchResult(result protocol.SearchResult) (tea.Model, tea.Cmd) {
+	return m.jumpToMessage(result.Message, result.ThreadRootID, m.searchThreads)
+}
+
+// jumpToMessage opens the channel of a message and, in forum channels, the thread it
+// belongs to with the message selected. threads holds the roots of reply threads.
+func (m Model) jumpToMessage(target protocol.Message, threadRootID uint64, threads map[uint64]protocol.Message) (tea.Model, tea.Cmd) {
+
+	var channel *protocol.Channel
+	for _, row := range m.channelListRows() {
+		if row.subchannel == nil && row.channel.ID == target.ChannelID {
+			ch := row.channel
+			channel = &ch
+			break
+		}
+	}
+	if channel == nil {
+		m.errorMessage = "That channel is no longer available"
+		return m, nil
+	}
+
+	var subchannel *protocol.Subchannel
+	if target.SubchannelID != nil {
+		for _, sub := range m.subchannels[channel.ID] {
+			if sub.ID == *target.SubchannelID {
+				s := sub
+				subchannel = &s
+				break
+			}
+		}
+	}
+
+	var cmds []tea.Cmd
+	if m.currentThread != nil && m.currentView == ViewThreadView {
+		cmds = append(cmds, m.sendUnsubscribeThread(m.currentThread.ID))
+	}
+
+	sameLocation := m.isCurrentLocation(target.ChannelID, target.SubchannelID)
+	if m.currentChannel != nil && m.currentChannel.ID != channel.ID {
+		cmds = append(cmds,
+			m.sendLeaveChannel(m.currentChannel.ID),
+			m.sendUnsubscribeChannel(m.currentChannel.ID),
+		)
+		m.clearActiveChannel()
+	}
+	joinChannel := m.currentChannel == nil || m.currentChannel.ID != channel.ID
+	m.currentChannel = channel
+	m.currentSubchannel = subchannel
+	if joinChannel {
+		cmds = append(cmds,
+			m.sendJoinChannel(channel.ID),
+			m.sendSubscribeChannel(channel.ID),
+		)
+	}
+
+	channelType := channel.Type
+	if subchannel != nil {
+		channelType = subchannel.Type
+	}
+	m.statusMessage = "Jumped to " + m.currentLocationName()
+
+	if channelType == 0 {
+		// Chat channels have no threads - open the conversation itself
+		m.currentView = ViewChatChannel
+		m.loadingChat = true
+		m.chatMessages = []protocol.Message{}
+		m.chatTextarea.Reset()
+		m.chatTextarea.Focus()
+		m.chatViewport.SetContent(m.buildChatMessages())
+		cmds = append(cmds, m.requestChatMessages(channel.ID), textarea.Blink)
+		return m, tea.Batch(cmds...)
+	}
+
+	root := target
+	if threadRootID != target.ID {
+		thread, ok := threads[threadRootID]
+		if !ok {
+			m.errorMessage = "The thread of that message is no longer available"
+			return m, tea.Batch(cmds...)
+		}
+		root = thread
+		jumpID := target.ID
+		m.pendingJumpID = &jumpID
+	} else {
+		m.pendingJumpID = nil
+	}
+
+	// Load the thread list behind the thread so Esc goes back to it
+	if !sameLocation || len(m.threads) == 0 {
+		m.loadingMore = false
+		m.allThreadsLoaded = false
+		m.loadingThreadList = true
+		m.threads = []protocol.Message{}
+		m.threadCursor = 0
+		cmds = append(cmds, m.requestThreadList(channel.ID))
+	}
+
+	// Always load the thread fresh so the matching reply is included
+	delete(m.threadRepliesCache, root.ID)
+	m.currentThread = &root
+	m.currentView = ViewThreadView
+	m.threadReplies = []protocol.Message{}
+	m.replyCursor = 0
+	m.newMessageIDs = make(map[uint64]bool)
+	m.confirmingDelete = false
+	m.allRepliesLoaded = false
+	m.loadingMoreReplies = false
+	m.loadingThreadReplies = true
+	m.threadViewport.SetContent(m.buildThreadContent())
+	m.threadViewport.GotoTop()
+	cmds = append(cmds,
+		m.requestThreadRepliesForJump(root.ID),
+		m.sendSubscribeThread(root.ID),
+	)
+	return m, tea.Batch(cmds...)
+}
+
 // handleChannelDeleted processes CHANNEL_DELETED (response + broadcast)
 func (m Model) handleChannelDeleted(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.ChannelDeletedMessage{}
@@ -1250,11 +1858,14 @@ func (m Model) handleChannelDeleted(frame *protocol.Frame) (tea.Model, tea.Cmd)
 		for i, ch := range m.channels {
 			if ch.ID == msg.ChannelID {
 				m.channels = append(m.channels[:i], m.channels[i+1:]...)
+				delete(m.subchannels, msg.ChannelID)
+				delete(m.expandedChannels, msg.ChannelID)
 
 				// If we were in the deleted channel, navigate to channel list
 				if m.currentChannel != nil && m.currentChannel.ID == msg.ChannelID {
 					m.clearActiveChannel()
 					m.currentChannel = nil
+					m.currentSubchannel = nil
 					m.threads = nil
 					m.currentThread = nil
 					m.threadReplies = nil
@@ -1426,6 +2037,19 @@ func (m Model) handleMessageList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 
 		// Update viewport to show loaded replies
 		m.threadViewport.SetContent(m.buildThreadContent())
+
+		// Select the reply a search result jumped to
+		if m.pendingJumpID != nil && m.currentThread != nil && *msg.ParentID == m.currentThread.ID {
+			for i, reply := range m.threadReplies {
+				if reply.ID == *m.pendingJumpID {
+					m.replyCursor = i + 1 // +1 because 0 is root
+					break
+				}
+			}
+			m.pendingJumpID = nil
+			m.threadViewport.SetContent(m.buildThreadContent())
+			m.scrollToKeepCursorVisible()
+		}
 	}
 
 	return m, listenForServerFrames(m.conn, m.connGeneration)
@@ -1466,7 +2090,7 @@ func (m Model) handleNewMessage(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	newMsg := protocol.Message(*msg)
 
 	// Add to appropriate list
-	if m.currentChannel != nil && newMsg.ChannelID == m.currentChannel.ID {
+	if m.isCurrentLocation(newMsg.ChannelID, newMsg.SubchannelID) {
 		if newMsg.ParentID == nil {
 			// New root message - could be chat or thread depending on view
 			if m.currentView == ViewChatChannel {
@@ -1595,6 +2219,19 @@ func (m Model) handleMessageDeleted(frame *protocol.Frame) (tea.Model, tea.Cmd)
 	return m, listenForServerFrames(m.conn, m.connGeneration)
 }
 
+// handleMessagesExpired processes MESSAGES_EXPIRED broadcasts for messages removed by retention
+func (m Model) handleMessagesExpired(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.MessagesExpiredMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode expired messages: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.applyMessagesExpired(msg.MessageIDs)
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
 // handleMessageEdited processes MESSAGE_EDITED confirmations and broadcasts.
 func (m Model) handleMessageEdited(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	m.sendingMessage = false
@@ -1615,6 +2252,19 @@ func (m Model) handleMessageEdited(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	return m, listenForServerFrames(m.conn, m.connGeneration)
 }
 
+// handleReactionsUpdated processes REACTIONS_UPDATED
+func (m Model) handleReactionsUpdated(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ReactionsUpdatedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode reactions: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	m.applyReactions(msg.MessageID, msg.Reactions)
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
 // handleSubscribeOk processes SUBSCRIBE_OK confirmations
 func (m Model) handleSubscribeOk(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.SubscribeOkMessage{}
@@ -1638,11 +2288,60 @@ func (m Model) handleError(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 		return m, listenForServerFrames(m.conn, m.connGeneration)
 	}
 
+	if msg.ErrorCode == protocol.ErrCodeMessageRateLimit {
+		return m.handleRateLimited(msg)
+	}
+
+	// Errors while a search is running belong to the search
+	if searchModal, ok := m.modalStack.Top().(*modal.SearchModal); ok && searchModal.Searching() {
+		searchModal.SetError(msg.Message)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	// Errors while history is loading belong to the history view
+	if historyModal, ok := m.modalStack.Top().(*modal.MessageHistoryModal); ok && historyModal.Loading() {
+		historyModal.SetError(msg.Message)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
 	m.errorMessage = fmt.Sprintf("Error %d: %s", msg.ErrorCode, msg.Message)
 
 	return m, listenForServerFrames(m.conn, m.connGeneration)
 }
 
+// handleRateLimited tells the user to slow down and gives back the content the server rejected
+func (m Model) handleRateLimited(msg *protocol.ErrorMessage) (tea.Model, tea.Cmd) {
+	m.sendingMessage = false
+	m.statusMessage = ""
+
+	notice := "Slow down! You're sending messages too quickly."
+	if msg.RetryAfterMs != nil {
+		seconds := (*msg.RetryAfterMs + 999) / 1000
+		notice = fmt.Sprintf("Slow down! You can send again in %ds.", seconds)
+	}
+
+	sent := m.lastSent
+	m.lastSent = nil
+	switch {
+	case sent == nil:
+		m.errorMessage = notice
+	case sent.chat:
+		// Put the message back in the chat input so it isn't lost
+		m.chatTextarea.SetValue(sent.content)
+		m.errorMessage = notice
+	default:
+		// Reopen the compose modal with the rejected content
+		m.composeParentID = sent.parentID
+		m.composeMessageID = sent.messageID
+		m.showComposeModal(sent.mode, sent.content)
+		if compose, ok := m.modalStack.Top().(*modal.ComposeModal); ok {
+			compose.SetNotice(notice)
+		}
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
 // handleDisconnect processes DISCONNECT messages from the server
 func (m Model) handleDisconnect(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.DisconnectMessage{}
@@ -1704,6 +2403,66 @@ func (m *Model) applyMessageDeletion(messageID uint64, replacement string) {
 	}
 }
 
+// applyMessagesExpired removes messages the server purged from local state
+func (m *Model) applyMessagesExpired(messageIDs []uint64) {
+	expired := make(map[uint64]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		expired[id] = true
+		delete(m.newMessageIDs, id)
+		delete(m.threadRepliesCache, id)
+	}
+	if expired[m.pendingDeleteID] {
+		m.pendingDeleteID = 0
+		m.confirmingDelete = false
+	}
+
+	keep := func(messages []protocol.Message) []protocol.Message {
+		kept := messages[:0]
+		for _, msg := range messages {
+			if !expired[msg.ID] {
+				kept = append(kept, msg)
+			}
+		}
+		return kept
+	}
+
+	threadCount, chatCount := len(m.threads), len(m.chatMessages)
+	m.threads = keep(m.threads)
+	m.chatMessages = keep(m.chatMessages)
+	m.threadReplies = keep(m.threadReplies)
+	for rootID, replies := range m.threadRepliesCache {
+		m.threadRepliesCache[rootID] = keep(replies)
+	}
+
+	if m.threadCursor >= len(m.threads) {
+		m.threadCursor = max(len(m.threads)-1, 0)
+	}
+	if m.replyCursor > len(m.threadReplies) {
+		m.replyCursor = len(m.threadReplies)
+	}
+
+	// The open thread is gone entirely: go back to the thread list
+	if m.currentThread != nil && expired[m.currentThread.ID] {
+		m.currentThread = nil
+		m.threadReplies = nil
+		m.replyCursor = 0
+		if m.currentView == ViewThreadView {
+			m.currentView = ViewThreadList
+			m.statusMessage = "This thread expired"
+		}
+	}
+
+	if len(m.threads) != threadCount {
+		m.threadListViewport.SetContent(m.buildThreadListContent())
+	}
+	if len(m.chatMessages) != chatCount {
+		m.chatViewport.SetContent(m.buildChatMessages())
+	}
+	if m.currentView == ViewThreadView {
+		m.threadViewport.SetContent(m.buildThreadContent())
+	}
+}
+
 // applyMessageEdit updates local state to reflect an edited message.
 func (m *Model) applyMessageEdit(messageID uint64, newContent string, editedAt time.Time) {
 	updatedThreadList := false
@@ -1735,6 +2494,34 @@ func (m *Model) applyMessageEdit(messageID uint64, newContent string, editedAt t
 	}
 }
 
+// applyReactions replaces the reactions of a message wherever it is cached
+func (m *Model) applyReactions(messageID uint64, reactions []protocol.ReactionSummary) {
+	updatedThreadList := false
+	for i := range m.threads {
+		if m.threads[i].ID == messageID {
+			m.threads[i].Reactions = reactions
+			updatedThreadList = true
+		}
+	}
+
+	if m.currentThread != nil && m.currentThread.ID == messageID {
+		m.currentThread.Reactions = reactions
+	}
+
+	for i := range m.threadReplies {
+		if m.threadReplies[i].ID == messageID {
+			m.threadReplies[i].Reactions = reactions
+		}
+	}
+
+	if updatedThreadList {
+		m.threadListViewport.SetContent(m.buildThreadListContent())
+	}
+	if m.currentView == ViewThreadView {
+		m.threadViewport.SetContent(m.buildThreadContent())
+	}
+}
+
 func (m Model) sendSetNickname() tea.Cmd {
 	return m.sendSetNicknameWith(m.nickname)
 }
@@ -1775,6 +2562,42 @@ func (m Model) sendLogout() tea.Cmd {
 	}
 }
 
+func (m Model) sendAuthTwoFactor(code string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeAuthTwoFactor, &protocol.AuthTwoFactorMessage{Code: code}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendEnable2FA() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeEnable2FA, &protocol.Enable2FAMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendConfirm2FA(code string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeConfirm2FA, &protocol.Confirm2FAMessage{Code: code}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendDisable2FA(code string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeDisable2FA, &protocol.Disable2FAMessage{Code: code}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 func (m Model) sendAuthRequest(password []byte) tea.Cmd {
 	return func() tea.Msg {
 		targetNickname := m.authTargetNickname
@@ -1834,6 +2657,68 @@ func (m Model) sendGetUserInfo(nickname string) tea.Cmd {
 	}
 }
 
+// sendTyping sends TYPING_START or TYPING_STOP for target
+func (m Model) sendTyping(target typingTarget, typing bool) tea.Cmd {
+	var subchannelID, threadID *uint64
+	if target.subchannelID != 0 {
+		subchannelID = &target.subchannelID
+	}
+	if target.threadID != 0 {
+		threadID = &target.threadID
+	}
+	return func() tea.Msg {
+		var err error
+		if typing {
+			err = m.conn.SendMessage(protocol.TypeTypingStart, &protocol.TypingStartMessage{
+				ChannelID:    target.channelID,
+				SubchannelID: subchannelID,
+				ThreadID:     threadID,
+			})
+		} else {
+			err = m.conn.SendMessage(protocol.TypeTypingStop, &protocol.TypingStopMessage{
+				ChannelID:    target.channelID,
+				SubchannelID: subchannelID,
+				ThreadID:     threadID,
+			})
+		}
+		if err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// updateChatTyping tells the server we're typing in the chat input (at most every
+// modal.TypingRepeatInterval), or that we stopped once the input is empty
+func (m *Model) updateChatTyping() tea.Cmd {
+	if m.currentChannel == nil {
+		return nil
+	}
+	target := m.typingTargetFor(0)
+	if strings.TrimSpace(m.chatTextarea.Value()) == "" {
+		if m.chatTypingAt.IsZero() {
+			return nil
+		}
+		m.chatTypingAt = time.Time{}
+		return m.sendTyping(target, false)
+	}
+	now := time.Now()
+	if now.Sub(m.chatTypingAt) < modal.TypingRepeatInterval {
+		return nil
+	}
+	m.chatTypingAt = now
+	return m.sendTyping(target, true)
+}
+
+func (m Model) sendSetProfile(profile *protocol.SetProfileMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeSetProfile, profile); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 func (m Model) sendChangePassword(oldPassword, newPassword []byte) tea.Cmd {
 	return func() tea.Msg {
 		// Hash passwords client-side before sending
@@ -1870,6 +2755,157 @@ func (m Model) requestChannelList() tea.Cmd {
 	}
 }
 
+func (m Model) requestDMList() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeListDMs, &protocol.ListDMsMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// sendPublicKey uploads our DM encryption public key
+func (m Model) sendPublicKey() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.provideKey(); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// setupDMs uploads our encryption key and then fetches the DM list, in that order,
+// so the list already contains the channel keys wrapped for this key
+func (m Model) setupDMs() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.provideKey(); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		if err := m.conn.SendMessage(protocol.TypeListDMs, &protocol.ListDMsMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) provideKey() error {
+	publicKey, keyType, err := m.keyring.PublicKey()
+	if err != nil {
+		return err
+	}
+	msg := &protocol.ProvidePublicKeyMessage{
+		KeyType:   keyType,
+		PublicKey: publicKey,
+		Label:     "superchat client",
+	}
+	return m.conn.SendMessage(protocol.TypeProvidePublicKey, msg)
+}
+
+// isEncryptedDM reports whether a channel is an end-to-end encrypted DM
+func (m Model) isEncryptedDM(channelID uint64) bool {
+	for _, dm := range m.dms {
+		if dm.ChannelID == channelID {
+			return dm.IsEncrypted
+		}
+	}
+	return false
+}
+
+// sendContent sends a POST_MESSAGE or EDIT_MESSAGE, encrypting its content first when
+// channelID is an encrypted DM. build returns the message for the content to send.
+func (m Model) sendContent(msgType uint8, channelID uint64, content string, build func(content string) protocol.ProtocolMessage) error {
+	if !m.isEncryptedDM(channelID) {
+		return m.conn.SendMessage(msgType, build(content))
+	}
+
+	ciphertext, err := m.keyring.Encrypt(channelID, content)
+	if err != nil {
+		return err
+	}
+	payload, err := build(ciphertext).Encode()
+	if err != nil {
+		return err
+	}
+	return m.conn.Send(&protocol.Frame{
+		Version: protocol.ProtocolVersion,
+		Type:    msgType,
+		Flags:   protocol.FlagEncrypted,
+		Payload: payload,
+	})
+}
+
+func (m Model) sendSearchMessages(msg *protocol.SearchMessagesMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeSearchMessages, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// sendListMentions requests the mentions inbox (limit 0 = server default)
+func (m Model) sendListMentions(unreadOnly bool, limit uint16) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.ListMentionsMessage{UnreadOnly: unreadOnly, Limit: limit}
+		if err := m.conn.SendMessage(protocol.TypeListMentions, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// sendGetMessageHistory requests the edit history of a message
+func (m Model) sendGetMessageHistory(messageID uint64) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.GetMessageHistoryMessage{MessageID: messageID}
+		if err := m.conn.SendMessage(protocol.TypeGetMessageHistory, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// sendMarkMentionsRead marks mentions read (all of them if messageIDs is empty)
+func (m Model) sendMarkMentionsRead(messageIDs []uint64) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.MarkMentionsReadMessage{MessageIDs: messageIDs}
+		if err := m.conn.SendMessage(protocol.TypeMarkMentionsRead, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// sendReaction adds a reaction to a message, or removes it
+func (m Model) sendReaction(messageID uint64, emoji string, remove bool) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if remove {
+			err = m.conn.SendMessage(protocol.TypeRemoveReaction, &protocol.RemoveReactionMessage{MessageID: messageID, Emoji: emoji})
+		} else {
+			err = m.conn.SendMessage(protocol.TypeAddReaction, &protocol.AddReactionMessage{MessageID: messageID, Emoji: emoji})
+		}
+		if err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendStartDM(nickname string) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.StartDMMessage{
+			TargetType:       protocol.DMTargetNickname,
+			TargetNickname:   nickname,
+			AllowUnencrypted: true, // Fall back to plaintext if the other side has no key and agrees
+		}
+		if err := m.conn.SendMessage(protocol.TypeStartDM, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 // ServerListTimeoutMsg is sent when server list request times out
 type ServerListTimeoutMsg struct{}
 
@@ -1898,7 +2934,7 @@ func (m Model) sendJoinChannel(channelID uint64) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.JoinChannelMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 		}
 		if err := m.conn.SendMessage(protocol.TypeJoinChannel, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -1919,7 +2955,7 @@ func (m Model) sendLeaveChannel(channelID uint64) tea.Cmd {
 
 		updateMsg := &protocol.UpdateReadStateMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Timestamp:    now,
 		}
 		if err := m.conn.SendMessage(protocol.TypeUpdateReadState, updateMsg); err != nil {
@@ -1930,7 +2966,7 @@ func (m Model) sendLeaveChannel(channelID uint64) tea.Cmd {
 		}
 
 		// Also update local state
-		if err := m.state.UpdateReadState(channelID, nil, nil, now); err != nil {
+		if err := m.state.UpdateReadState(channelID, m.currentSubchannelID(), nil, now); err != nil {
 			if m.logger != nil {
 				m.logger.Printf("Failed to update local read state: %v", err)
 			}
@@ -1938,7 +2974,7 @@ func (m Model) sendLeaveChannel(channelID uint64) tea.Cmd {
 
 		msg := &protocol.LeaveChannelMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 		}
 		if err := m.conn.SendMessage(protocol.TypeLeaveChannel, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -1951,7 +2987,7 @@ func (m Model) sendListChannelUsers(channelID uint64) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.ListChannelUsersMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 		}
 		if err := m.conn.SendMessage(protocol.TypeListChannelUsers, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -1981,6 +3017,28 @@ func (m Model) sendCreateChannel(name, displayName, description string, channelT
 	}
 }
 
+func (m Model) sendCreateSubchannel(channelID uint64, name, displayName, description string, subchannelType uint8) tea.Cmd {
+	return func() tea.Msg {
+		var desc *string
+		if description != "" {
+			desc = &description
+		}
+
+		msg := &protocol.CreateSubchannelMessage{
+			ChannelID:      channelID,
+			Name:           name,
+			DisplayName:    displayName,
+			Description:    desc,
+			SubchannelType: subchannelType, // 0=chat, 1=forum
+			RetentionHours: 168,            // 7 days default
+		}
+		if err := m.conn.SendMessage(protocol.TypeCreateSubchannel, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 // SSH Key Management Functions
 
 func (m Model) sendListSSHKeys() tea.Cmd {
@@ -2048,7 +3106,7 @@ func (m Model) requestThreadList(channelID uint64) tea.Cmd {
 		}
 		msg := &protocol.ListMessagesMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Limit:        limit,
 			BeforeID:     nil,
 			ParentID:     nil,
@@ -2066,7 +3124,7 @@ func (m Model) requestChatMessages(channelID uint64) tea.Cmd {
 		limit := uint16(100) // Load last 100 messages initially
 		msg := &protocol.ListMessagesMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Limit:        limit,
 			BeforeID:     nil,
 			ParentID:     nil, // No parent ID = root messages only (chat has no threading)
@@ -2094,7 +3152,7 @@ func (m Model) loadMoreThreads() tea.Cmd {
 		}
 		msg := &protocol.ListMessagesMessage{
 			ChannelID:    m.currentChannel.ID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Limit:        limit,
 			BeforeID:     &oldestThreadID,
 			ParentID:     nil,
@@ -2116,7 +3174,7 @@ func (m Model) requestThreadReplies(threadID uint64) tea.Cmd {
 
 		msg := &protocol.ListMessagesMessage{
 			ChannelID:    m.currentChannel.ID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Limit:        limit,
 			BeforeID:     nil,
 			ParentID:     &threadID,
@@ -2129,6 +3187,23 @@ func (m Model) requestThreadReplies(threadID uint64) tea.Cmd {
 	}
 }
 
+// requestThreadRepliesForJump loads a thread's replies when jumping to a search result,
+// with a limit large enough that the matching reply is almost always included
+func (m Model) requestThreadRepliesForJump(threadID uint64) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.ListMessagesMessage{
+			ChannelID:    m.currentChannel.ID,
+			SubchannelID: m.currentSubchannelID(),
+			Limit:        searchJumpReplyLimit,
+			ParentID:     &threadID,
+		}
+		if err := m.conn.SendMessage(protocol.TypeListMessages, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 // loadMoreReplies loads more replies in the current thread (pagination)
 func (m Model) loadMoreReplies() tea.Cmd {
 	return func() tea.Msg {
@@ -2142,7 +3217,7 @@ func (m Model) loadMoreReplies() tea.Cmd {
 		limit := uint16(10)
 		msg := &protocol.ListMessagesMessage{
 			ChannelID:    m.currentChannel.ID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Limit:        limit,
 			BeforeID:     &oldestReplyID,
 			ParentID:     &m.currentThread.ID,
@@ -2161,7 +3236,7 @@ func (m Model) requestThreadRepliesAfter(threadID uint64, afterID uint64) tea.Cm
 		// Only fetch new messages, not all 200
 		msg := &protocol.ListMessagesMessage{
 			ChannelID:    m.currentChannel.ID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 			Limit:        50, // Reasonable limit for new messages
 			BeforeID:     nil,
 			ParentID:     &threadID,
@@ -2176,13 +3251,15 @@ func (m Model) requestThreadRepliesAfter(threadID uint64, afterID uint64) tea.Cm
 
 func (m Model) sendPostMessage(channelID uint64, parentID *uint64, content string) tea.Cmd {
 	return func() tea.Msg {
-		msg := &protocol.PostMessageMessage{
-			ChannelID:    channelID,
-			SubchannelID: nil,
-			ParentID:     parentID,
-			Content:      content,
-		}
-		if err := m.conn.SendMessage(protocol.TypePostMessage, msg); err != nil {
+		err := m.sendContent(protocol.TypePostMessage, channelID, content, func(content string) protocol.ProtocolMessage {
+			return &protocol.PostMessageMessage{
+				ChannelID:    channelID,
+				SubchannelID: m.currentSubchannelID(),
+				ParentID:     parentID,
+				Content:      content,
+			}
+		})
+		if err != nil {
 			return ErrorMsg{Err: err}
 		}
 		return nil
@@ -2202,12 +3279,19 @@ func (m Model) sendDeleteMessage(messageID uint64) tea.Cmd {
 }
 
 func (m Model) sendEditMessage(messageID uint64, newContent string) tea.Cmd {
+	// Edits happen in the open channel, which decides whether the new content is encrypted
+	var channelID uint64
+	if m.currentChannel != nil {
+		channelID = m.currentChannel.ID
+	}
 	return func() tea.Msg {
-		msg := &protocol.EditMessageMessage{
-			MessageID:  messageID,
-			NewContent: newContent,
-		}
-		if err := m.conn.SendMessage(protocol.TypeEditMessage, msg); err != nil {
+		err := m.sendContent(protocol.TypeEditMessage, channelID, newContent, func(content string) protocol.ProtocolMessage {
+			return &protocol.EditMessageMessage{
+				MessageID:  messageID,
+				NewContent: content,
+			}
+		})
+		if err != nil {
 			return ErrorMsg{Err: err}
 		}
 		return nil
@@ -2254,7 +3338,7 @@ func (m Model) sendSubscribeChannel(channelID uint64) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.SubscribeChannelMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 		}
 		if err := m.conn.SendMessage(protocol.TypeSubscribeChannel, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -2267,7 +3351,7 @@ func (m Model) sendUnsubscribeChannel(channelID uint64) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.UnsubscribeChannelMessage{
 			ChannelID:    channelID,
-			SubchannelID: nil,
+			SubchannelID: m.currentSubchannelID(),
 		}
 		if err := m.conn.SendMessage(protocol.TypeUnsubscribeChannel, msg); err != nil {
 			return ErrorMsg{Err: err}
@@ -2421,6 +3505,7 @@ func (m Model) handleServerSelected(server protocol.ServerInfo) (tea.Model, tea.
 		m.errorMessage = fmt.Sprintf("Failed to create connection: %v", err)
 		return m, nil
 	}
+	conn.SetCertificatePinStore(m.state)
 
 	// Set logger if we have one (using concrete type methods)
 	if m.logger != nil {
@@ -2444,6 +3529,7 @@ func (m Model) handleServerSelected(server protocol.ServerInfo) (tea.Model, tea.
 
 	// Update model state
 	m.conn = conn
+	m.keyring.SetServer(conn.GetAddress())
 	m.connectionState = StateConnected
 	m.directoryMode = false
 	m.statusMessage = fmt.Sprintf("Connected to %s", server.Name)
@@ -2676,6 +3762,7 @@ func (m Model) handleConnectionMethodSelected(msg modal.ConnectionMethodSelected
 		m.modalStack.Push(modal.NewConnectionFailedModal(rawAddr, fmt.Sprintf("Invalid address for %s: %v", method, err)))
 		return m, nil
 	}
+	conn.SetCertificatePinStore(m.state)
 
 	// Set logger on the new connection
 	if m.logger != nil {
@@ -2699,6 +3786,7 @@ func (m Model) handleConnectionMethodSelected(msg modal.ConnectionMethodSelected
 
 	// Replace our connection
 	m.conn = conn
+	m.keyring.SetServer(conn.GetAddress())
 
 	// Attempt connection asynchronously and start spinner
 	return m, tea.Batch(
@@ -2917,6 +4005,51 @@ func (m Model) sendListBans(includeExpired bool) tea.Cmd {
 	}
 }
 
+func (m Model) sendListAdminActions(msg *protocol.ListAdminActionsMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeListAdminActions, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendSetUserFlags(msg *protocol.SetUserFlagsMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeSetUserFlags, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendListLoginLockouts() tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeListLoginLockouts, &protocol.ListLoginLockoutsMessage{}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendClearLoginLockout(msg *protocol.ClearLoginLockoutMessage) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeClearLoginLockout, msg); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+func (m Model) sendDisable2FAFor(nickname string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.conn.SendMessage(protocol.TypeDisable2FA, &protocol.Disable2FAMessage{Nickname: &nickname}); err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 func (m Model) sendListUsers(includeOffline bool) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.ListUsersMessage{
@@ -3018,6 +4151,16 @@ func (m Model) handleBanList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 			// Convert protocol bans to modal.BanEntry
 			banEntries := make([]modal.BanEntry, len(msg.Bans))
 			for i, ban := range msg.Bans {
+				var channel string
+				if ban.ChannelID != nil {
+					channel = fmt.Sprintf("%d", *ban.ChannelID)
+					for _, ch := range m.channels {
+						if ch.ID == *ban.ChannelID {
+							channel = ch.Name
+							break
+						}
+					}
+				}
 				banEntries[i] = modal.BanEntry{
 					BanType:     ban.Type,
 					TargetID:    ban.UserID,
@@ -3028,6 +4171,7 @@ func (m Model) handleBanList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 					BannedUntil: ban.BannedUntil,
 					BannedBy:    ban.BannedBy,
 					IsShadowban: ban.Shadowban,
+					Channel:     channel,
 				}
 			}
 			viewBansModal.SetBans(banEntries)
@@ -3037,6 +4181,208 @@ func (m Model) handleBanList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	return m, listenForServerFrames(m.conn, m.connGeneration)
 }
 
+func (m Model) handleAdminActionList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.AdminActionListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode ADMIN_ACTION_LIST: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if auditLogModal, ok := m.modalStack.Top().(*modal.AuditLogModal); ok {
+		auditLogModal.SetEntries(msg.Entries, msg.HasMore)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+func (m Model) handleUserFlagsUpdated(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.UserFlagsUpdatedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode USER_FLAGS_UPDATED: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if !msg.Success {
+		m.errorMessage = msg.Message
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if m.userID != nil && *m.userID == msg.UserID {
+		// An admin changed our roles; the admin panel shows what the old role allowed
+		m.userFlags = msg.Flags
+		m.modalStack.RemoveByType(modal.ModalAdminPanel)
+		m.statusMessage = "An admin changed your role: " + msg.Message
+	} else {
+		m.statusMessage = msg.Message
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+func (m Model) handleLoginLockoutList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.LoginLockoutListMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode LOGIN_LOCKOUT_LIST: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if loginLockoutsModal, ok := m.modalStack.Top().(*modal.LoginLockoutsModal); ok {
+		loginLockoutsModal.SetLockouts(msg.Lockouts)
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+func (m Model) handleLoginLockoutCleared(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.LoginLockoutClearedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode LOGIN_LOCKOUT_CLEARED: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if !msg.Success {
+		m.errorMessage = msg.Message
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+	m.statusMessage = msg.Message
+
+	// Show the list without the cleared lockout
+	cmds := []tea.Cmd{listenForServerFrames(m.conn, m.connGeneration)}
+	if loginLockoutsModal, ok := m.modalStack.Top().(*modal.LoginLockoutsModal); ok {
+		cmds = append(cmds, loginLockoutsModal.Refresh())
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// handleTwoFactorSetup shows the new secret in the two-factor settings
+func (m Model) handleTwoFactorSetup(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.TwoFactorSetupMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode TWO_FACTOR_SETUP: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if twoFactorModal, ok := m.modalStack.Top().(*modal.TwoFactorModal); ok {
+		if msg.Success {
+			twoFactorModal.SetSetup(msg.Secret, msg.URI)
+		} else {
+			twoFactorModal.SetError(msg.Message)
+		}
+	} else if !msg.Success {
+		m.errorMessage = msg.Message
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleProfileUpdated shows whether SET_PROFILE worked
+func (m Model) handleProfileUpdated(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.ProfileUpdatedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode PROFILE_UPDATED: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if profileModal, ok := m.modalStack.Top().(*modal.ProfileModal); ok {
+		profileModal.SetSaved(msg.Success, msg.Message)
+	} else if msg.Success {
+		m.statusMessage = msg.Message
+	} else {
+		m.errorMessage = msg.Message
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleUserTyping shows or hides someone typing
+func (m Model) handleUserTyping(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.UserTypingMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode USER_TYPING: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	if m.typingUsers == nil {
+		m.typingUsers = make(map[uint64]typingUser)
+	}
+	if msg.Typing {
+		target := typingTarget{channelID: msg.ChannelID}
+		if msg.SubchannelID != nil {
+			target.subchannelID = *msg.SubchannelID
+		}
+		if msg.ThreadID != nil {
+			target.threadID = *msg.ThreadID
+		}
+		m.typingUsers[msg.SessionID] = typingUser{
+			nickname:  msg.Nickname,
+			target:    target,
+			expiresAt: time.Now().Add(typingExpiry),
+		}
+	} else {
+		delete(m.typingUsers, msg.SessionID)
+	}
+	m.refreshComposeTyping()
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// refreshComposeTyping updates who the open compose modal shows typing
+func (m *Model) refreshComposeTyping() {
+	composeModal, ok := m.modalStack.Top().(*modal.ComposeModal)
+	if !ok {
+		return
+	}
+	if target, ok := m.composeTypingTarget(composeModal.Mode()); ok {
+		composeModal.SetTyping(m.typingText(target))
+	}
+}
+
+// handleTwoFactorEnabled shows the recovery codes, or why the code was refused
+func (m Model) handleTwoFactorEnabled(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.TwoFactorEnabledMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode TWO_FACTOR_ENABLED: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	twoFactorModal, ok := m.modalStack.Top().(*modal.TwoFactorModal)
+	switch {
+	case msg.Success && ok:
+		twoFactorModal.SetRecoveryCodes(msg.RecoveryCodes)
+		m.statusMessage = "Two-factor authentication enabled"
+	case msg.Success:
+		m.statusMessage = "Two-factor authentication enabled"
+	case ok:
+		twoFactorModal.SetError(msg.Message)
+	default:
+		m.errorMessage = msg.Message
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
+// handleTwoFactorDisabled handles turning off 2FA, for ourselves or (admins) others
+func (m Model) handleTwoFactorDisabled(frame *protocol.Frame) (tea.Model, tea.Cmd) {
+	msg := &protocol.TwoFactorDisabledMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		m.errorMessage = fmt.Sprintf("Failed to decode TWO_FACTOR_DISABLED: %v", err)
+		return m, listenForServerFrames(m.conn, m.connGeneration)
+	}
+
+	twoFactorModal, ok := m.modalStack.Top().(*modal.TwoFactorModal)
+	switch {
+	case msg.Success:
+		m.modalStack.RemoveByType(modal.ModalTwoFactor)
+		m.statusMessage = msg.Message
+	case ok:
+		twoFactorModal.SetError(msg.Message)
+	default:
+		m.errorMessage = msg.Message
+	}
+
+	return m, listenForServerFrames(m.conn, m.connGeneration)
+}
+
 func (m Model) handleUserList(frame *protocol.Frame) (tea.Model, tea.Cmd) {
 	msg := &protocol.UserListMessage{}
 	if err := msg.Decode(frame.Payload); err != nil {
@@ -3178,6 +4524,9 @@ func (m Model) handleServerPresence(frame *protocol.Frame) (tea.Model, tea.Cmd)
 		IsRegistered: msg.IsRegistered,
 		UserID:       cloneUint64Ptr(msg.UserID),
 		UserFlags:    msg.UserFlags,
+		DisplayName:  msg.DisplayName,
+		Status:       msg.Status,
+		Away:         msg.Away,
 	}
 
 	if msg.Online {
@@ -3254,7 +4603,7 @@ func (m Model) sendDesktopNotification(msg protocol.Message) {
 	// Build notification title and message
 	title := "SuperChat"
 	if m.currentChannel != nil {
-		title = fmt.Sprintf("SuperChat - %s", m.currentChannel.Name)
+		title = fmt.Sprintf("SuperChat - %s", m.currentLocationName())
 	}
 
 	// Truncate message content to 100 chars for notification
@@ -79,7 +79,7 @@ func (m Model) renderModalOverlay(baseView string, activeModal modal.Modal) stri
 // buildSplashContent builds the scrollable content for the splash screen
 func (m Model) buildSplashContent() string {
 	subtitle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(TextColor).
 		Align(lipgloss.Left).
 		Render("A terminal-based threaded chat application")
 
@@ -475,6 +475,9 @@ func (m Model) renderHeader() string {
 		if m.onlineUsers > 0 {
 			status += fmt.Sprintf("  %d users", m.onlineUsers)
 		}
+		if m.unreadMentions > 0 {
+			status += "  " + SuccessStyle.Render(fmt.Sprintf("@%d", m.unreadMentions))
+		}
 
 		// Add traffic counter
 		sent := client.FormatBytes(m.conn.GetBytesSent())
@@ -652,15 +655,49 @@ func (m Model) buildChannelPaneContentString(availableWidth int) string {
 		// Plus 1 extra for safety/border rendering
 		contentWidth := availableWidth - 3
 
-		for i, channel := range m.channels {
-			// Use '>' prefix for chat channels (type 0), '#' for forum channels (type 1)
-			var prefix string
-			if channel.Type == 0 {
-				prefix = ">"
+		dmHeaderShown := false
+		for i, row := range m.channelListRows() {
+			channel := row.channel
+
+			// DMs follow the channels under their own heading
+			if row.dm != nil && !dmHeaderShown {
+				items = append(items, "", MutedTextStyle.Render("  Direct Messages"))
+				dmHeaderShown = true
+			}
+
+			var base string
+			if row.dm != nil {
+				base = "@" + channel.Name
+				// Encrypted DMs are marked once we hold their key
+				if row.dm.IsEncrypted && m.keyring.HasChannelKey(row.dm.ChannelID) {
+					base += " 🔒"
+				}
+			} else if row.subchannel != nil {
+				// Subchannels are indented under their parent; '>' marks chat, '/' marks forum
+				prefix := "/"
+				if row.subchannel.Type == 0 {
+					prefix = ">"
+				}
+				base = "  " + prefix + row.subchannel.Name
 			} else {
-				prefix = "#"
+				// Use '>' prefix for chat channels (type 0), '#' for forum channels (type 1)
+				var prefix string
+				if channel.Type == 0 {
+					prefix = ">"
+				} else {
+					prefix = "#"
+				}
+				base = prefix + channel.Name
+
+				// Show expand/collapse marker for channels with subchannels
+				if len(m.subchannels[channel.ID]) > 0 {
+					if m.expandedChannels[channel.ID] {
+						base += " ▾"
+					} else {
+						base += " ▸"
+					}
+				}
 			}
-			base := prefix + channel.Name
 
 			var label string
 			if i == m.channelCursor {
@@ -669,8 +706,11 @@ func (m Model) buildChannelPaneContentString(availableWidth int) string {
 				label = UnselectedItemStyle.Render("  " + base)
 			}
 
-			// Get unread count for this channel
-			unreadCount := m.unreadCounts[channel.ID]
+			// Unread counts are tracked per channel, so only show them on channel rows
+			var unreadCount uint32
+			if row.subchannel == nil {
+				unreadCount = m.unreadCounts[channel.ID]
+			}
 
 			// Only show count if there are unread messages
 			var item string
@@ -867,10 +907,13 @@ func (m Model) formatThreadItem(thread protocol.Message) string {
 	}
 
 	// Get base formatting from shared function
-	timeStr := client.FormatRelativeTime(thread.CreatedAt)
+	timeStr := m.timestamps.Format(thread.CreatedAt, "Jan 2 15:04")
 	replyCount := ""
 	if thread.ReplyCount > 0 {
 		replyCount = fmt.Sprintf(" (%d)", thread.ReplyCount)
+		if timeStr == "" {
+			replyCount = replyCount[1:]
+		}
 	}
 
 	// Apply terminal-specific styling
@@ -933,13 +976,19 @@ func (m Model) formatMessage(msg protocol.Message, depth int, selected bool) str
 	}
 	author = authorStyle.Render(author)
 
-	timeStr := client.FormatRelativeTime(msg.CreatedAt)
-	timestamp := MessageTimeStyle.Render(timeStr)
+	timestamp := ""
+	if timeStr := m.timestamps.Format(msg.CreatedAt, "Jan 2 15:04"); timeStr != "" {
+		timestamp = "  " + MessageTimeStyle.Render(timeStr)
+	}
 
 	// Add edited indicator if message was edited
 	editedIndicator := ""
 	if msg.EditedAt != nil {
-		editedIndicator = "  " + MessageTimeStyle.Render("(edited)")
+		edited := "(edited)"
+		if selected {
+			edited = "(edited, H for history)"
+		}
+		editedIndicator = "  " + MessageTimeStyle.Render(edited)
 	}
 
 	// Add NEW indicator if message is unread
@@ -954,7 +1003,7 @@ func (m Model) formatMessage(msg protocol.Message, depth int, selected bool) str
 		depthIndicator = "  " + MessageDepthStyle.Render(fmt.Sprintf("[%d]", depth))
 	}
 
-	header := author + "  " + timestamp + editedIndicator + newIndicator + depthIndicator
+	header := author + timestamp + editedIndicator + newIndicator + depthIndicator
 
 	// Calculate available width for content (viewport width minus borders, padding, indent, and indicator)
 	// Viewport width = m.width - 2 (border)
@@ -964,16 +1013,26 @@ func (m Model) formatMessage(msg protocol.Message, depth int, selected bool) str
 		availableWidth = 20 // Minimum width
 	}
 
-	// Wrap content to available width
-	contentLines := strings.Split(msg.Content, "\n")
+	// Render markdown (or wrap the raw source) to available width
+	var contentLines []string
+	if m.showRawMarkdown {
+		for _, line := range strings.Split(msg.Content, "\n") {
+			// Wrap each line to fit available width
+			wrapped := lipgloss.NewStyle().Width(availableWidth).Render(line)
+			for _, wl := range strings.Split(wrapped, "\n") {
+				contentLines = append(contentLines, MessageContentStyle.Render(wl))
+			}
+		}
+	} else {
+		contentLines = renderMarkdown(msg.Content, availableWidth)
+	}
 	var indentedContent []string
 	for _, line := range contentLines {
-		// Wrap each line to fit available width
-		wrapped := lipgloss.NewStyle().Width(availableWidth).Render(line)
-		wrappedLines := strings.Split(wrapped, "\n")
-		for _, wl := range wrappedLines {
-			indentedContent = append(indentedContent, indent+MessageContentStyle.Render(wl))
-		}
+		indentedContent = append(indentedContent, indent+line)
+	}
+
+	if len(msg.Reactions) > 0 {
+		indentedContent = append(indentedContent, indent+m.formatReactions(msg.Reactions))
 	}
 
 	content := strings.Join(indentedContent, "\n")
@@ -987,6 +1046,24 @@ func (m Model) formatMessage(msg protocol.Message, depth int, selected bool) str
 	return UnselectedItemStyle.Render("" + indent + full)
 }
 
+// formatReactions renders reaction counts, highlighting the ones the current user added
+func (m Model) formatReactions(reactions []protocol.ReactionSummary) string {
+	parts := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		style := MessageReactionStyle
+		if m.userID != nil {
+			for _, userID := range reaction.UserIDs {
+				if userID == *m.userID {
+					style = MessageOwnReactionStyle
+					break
+				}
+			}
+		}
+		parts = append(parts, style.Render(fmt.Sprintf("%s %d", reaction.Emoji, len(reaction.UserIDs))))
+	}
+	return strings.Join(parts, "  ")
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -1005,7 +1082,7 @@ func min(a, b int) int {
 func (m Model) buildThreadListContent() string {
 	var title string
 	if m.currentChannel != nil {
-		title = ThreadTitleStyle.Render("#" + m.currentChannel.Name + " - Threads")
+		title = ThreadTitleStyle.Render(m.currentLocationName() + " - Threads")
 	} else {
 		title = ThreadTitleStyle.Render("Threads")
 	}
@@ -1103,21 +1180,28 @@ func (m Model) renderChatContent() string {
 	inputContent := m.buildChatInputField()
 
 	// Row 1: Message area (flexible - takes remaining space after input)
-	// The input field has fixed height of 5 lines (3 content + 2 border)
-	// So message area gets: contentHeight - 5
+	// The input field has fixed height of 5 lines (3 content + 2 border),
+	// with a line for who is typing above it
+	// So message area gets: contentHeight - 6
 	messageRow := layout.NewRow().AddCells(
-		flexbox.NewCell(1, contentHeight-5).
+		flexbox.NewCell(1, contentHeight-6).
 			SetStyle(ThreadPaneStyle).
 			SetContent(messageContent),
 	)
 
-	// Row 2: Input field (fixed height = 5 lines)
+	// Row 2: Who is typing (fixed height = 1 line, blank when nobody is)
+	typingRow := layout.NewRow().AddCells(
+		flexbox.NewCell(1, 1).
+			SetContent(m.buildChatTypingLine()),
+	)
+
+	// Row 3: Input field (fixed height = 5 lines)
 	inputRow := layout.NewRow().AddCells(
 		flexbox.NewCell(1, 5).
 			SetContent(inputContent),
 	)
 
-	layout.AddRows([]*flexbox.Row{messageRow, inputRow})
+	layout.AddRows([]*flexbox.Row{messageRow, typingRow, inputRow})
 
 	return layout.Render()
 }
@@ -1168,8 +1252,8 @@ func (m Model) buildChatMessages() string {
 
 // formatChatMessage formats a single chat message as: [time] nickname message
 func (m Model) formatChatMessage(msg protocol.Message) string {
-	// Format timestamp (HH:MM)
-	timestamp := msg.CreatedAt.Format("15:04")
+	// Format timestamp (HH:MM unless configured otherwise)
+	timestamp := m.timestamps.Format(msg.CreatedAt, "15:04")
 	timeStyle := lipgloss.NewStyle().Foreground(MutedColor)
 
 	// Format nickname with same styling as threaded view
@@ -1188,9 +1272,10 @@ func (m Model) formatChatMessage(msg protocol.Message) string {
 	}
 
 	// Build first line with timestamp and nickname
-	timestampRendered := timeStyle.Render("[" + timestamp + "]")
-	nicknameRendered := nicknameStyle.Render(nickname)
-	firstLinePrefix := timestampRendered + " " + nicknameRendered + " "
+	firstLinePrefix := nicknameStyle.Render(nickname) + " "
+	if timestamp != "" {
+		firstLinePrefix = timeStyle.Render("["+timestamp+"]") + " " + firstLinePrefix
+	}
 
 	// Calculate available width for message content
 	// Account for viewport width minus some padding
@@ -1203,22 +1288,27 @@ func (m Model) formatChatMessage(msg protocol.Message) string {
 	prefixWidth := lipgloss.Width(firstLinePrefix)
 	contentWidth := availableWidth - prefixWidth
 
-	// Wrap the message content
-	wrappedLines := wrapText(msg.Content, contentWidth)
-
-	// Format content with proper indentation for continuation lines
-	contentStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
-	if len(wrappedLines) == 0 {
+	// Render markdown (or wrap the raw source)
+	var contentLines []string
+	if m.showRawMarkdown {
+		contentStyle := lipgloss.NewStyle().Foreground(TextColor)
+		for _, line := range wrapText(msg.Content, contentWidth) {
+			contentLines = append(contentLines, contentStyle.Render(line))
+		}
+	} else {
+		contentLines = renderMarkdown(msg.Content, contentWidth)
+	}
+	if len(contentLines) == 0 {
 		return firstLinePrefix
 	}
 
 	// First line includes timestamp and nickname
-	result := firstLinePrefix + contentStyle.Render(wrappedLines[0])
+	result := firstLinePrefix + contentLines[0]
 
 	// Continuation lines are indented to align with first line content
 	indent := strings.Repeat(" ", prefixWidth)
-	for i := 1; i < len(wrappedLines); i++ {
-		result += "\n" + indent + contentStyle.Render(wrappedLines[i])
+	for i := 1; i < len(contentLines); i++ {
+		result += "\n" + indent + contentLines[i]
 	}
 
 	return result
@@ -1280,6 +1370,15 @@ func (m Model) buildChatInputField() string {
 	return m.chatTextarea.View()
 }
 
+// buildChatTypingLine shows who else is typing in the chat
+func (m Model) buildChatTypingLine() string {
+	text := m.typingText(m.typingTargetFor(0))
+	if text == "" {
+		return ""
+	}
+	return MutedTextStyle.Italic(true).Render("  " + text)
+}
+
 // calculateCursorLinePosition returns the line number where the cursor is positioned
 func (m Model) calculateCursorLinePosition() int {
 	if m.currentThread == nil {
@@ -1399,7 +1498,7 @@ func (m Model) renderDisconnectedOverlay() string {
 		Render("⚠  CONNECTION LOST  ⚠")
 
 	message := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(TextColor).
 		Align(lipgloss.Center).
 		MarginBottom(1).
 		Render("The connection to the server has been lost.")
@@ -1412,6 +1511,8 @@ func (m Model) renderDisconnectedOverlay() string {
 		switch connType {
 		case "tcp":
 			methodName = "TCP (binary protocol)"
+		case "tls":
+			methodName = "TLS (binary protocol)"
 		case "ssh":
 			methodName = "SSH"
 		case "websocket":
@@ -1468,7 +1569,7 @@ func (m Model) renderReconnectingOverlay() string {
 
 	attemptMsg := fmt.Sprintf("Attempt %d", m.reconnectAttempt)
 	message := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(TextColor).
 		Align(lipgloss.Center).
 		MarginBottom(1).
 		Render(attemptMsg)
@@ -1481,6 +1582,8 @@ func (m Model) renderReconnectingOverlay() string {
 		switch connType {
 		case "tcp":
 			methodName = "TCP (binary protocol)"
+		case "tls":
+			methodName = "TLS (binary protocol)"
 		case "ssh":
 			methodName = "SSH"
 		case "websocket":
@@ -44,6 +44,8 @@ func RenderHeader(width int, currentVersion string, conn client.ConnectionInterf
 			switch connType {
 			case "tcp":
 				typeDisplay = "TCP"
+			case "tls":
+				typeDisplay = "TLS"
 			case "ssh":
 				typeDisplay = "SSH"
 			case "websocket":
@@ -121,7 +123,7 @@ func RenderDisconnectedOverlay(width, height int) string {
 		Render("⚠  CONNECTION LOST  ⚠")
 
 	message := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(ui.TextColor).
 		Align(lipgloss.Center).
 		MarginBottom(1).
 		Render("The connection to the server has been lost.")
@@ -160,7 +162,7 @@ func RenderReconnectingOverlay(width, height int, reconnectAttempt int) string {
 
 	attemptMsg := fmt.Sprintf("Attempt %d", reconnectAttempt)
 	message := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(ui.TextColor).
 		Align(lipgloss.Center).
 		MarginBottom(1).
 		Render(attemptMsg)
@@ -14,7 +14,7 @@ func RenderSplash(width, height int, currentVersion string) string {
 
 	title := ui.SplashTitleStyle.Render(fmt.Sprintf("SuperChat %s", currentVersion))
 	subtitle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("252")).
+		Foreground(ui.TextColor).
 		Align(lipgloss.Center).
 		MarginBottom(1).
 		Render("A terminal-based threaded chat application")
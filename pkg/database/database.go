@@ -19,8 +19,13 @@ var (
 	ErrMessageNotOwned = errors.New("cannot delete message not authored by this nickname")
 	// ErrMessageAlreadyDeleted indicates the message has already been soft-deleted.
 	ErrMessageAlreadyDeleted = errors.New("message already deleted")
+	// ErrTooManyReactions indicates the message already has the maximum number of different reactions.
+	ErrTooManyReactions = errors.New("too many different reactions")
 )
 
+// DMRetentionHours is the message retention applied to DM channels
+const DMRetentionHours = 720 // 30 days
+
 // DB wraps the SQLite database connection
 type DB struct {
 	conn        *sql.DB // Read connection pool (25 connections)
@@ -225,6 +230,20 @@ type Channel struct {
 	CreatedBy             *int64
 	CreatedAt             int64 // Unix timestamp in milliseconds
 	IsPrivate             bool
+	IsEncrypted           bool // End-to-end encrypted DM (content is ciphertext)
+}
+
+// Subchannel represents a subchannel record (second level of the channel hierarchy)
+type Subchannel struct {
+	ID                    int64
+	ChannelID             int64
+	Name                  string
+	DisplayName           string
+	Description           *string
+	SubchannelType        uint8 // 0=chat, 1=forum
+	MessageRetentionHours uint32
+	CreatedBy             *int64
+	CreatedAt             int64 // Unix timestamp in milliseconds
 }
 
 // Session represents an active connection
@@ -232,7 +251,7 @@ type Session struct {
 	ID             int64
 	UserID         *int64
 	Nickname       string
-	ConnectionType string // "tcp" or "ssh"
+	ConnectionType string // "tcp", "tls", "ssh" or "websocket"
 	ConnectedAt    int64  // Unix timestamp in milliseconds
 	LastActivity   int64  // Unix timestamp in milliseconds
 }
@@ -259,6 +278,16 @@ type SSHKey struct {
 	LastUsedAt  *int64  // Unix timestamp in milliseconds of last successful auth
 }
 
+// EncryptionKey represents an X25519 public key that DM channel keys can be wrapped for (V3 feature)
+type EncryptionKey struct {
+	ID        int64
+	UserID    int64
+	KeyType   uint8   // 0=derived from SSH key, 1=generated, 2=ephemeral
+	PublicKey string  // Base64-encoded X25519 public key
+	Label     *string // Optional user-friendly name
+	AddedAt   int64   // Unix timestamp in milliseconds
+}
+
 // Message represents a message record
 type Message struct {
 	ID             int64
@@ -275,6 +304,30 @@ type Message struct {
 	ReplyCount     atomic.Uint32 // Cached reply count (in-memory only, not persisted to SQLite)
 }
 
+// Reaction is one user's emoji reaction to a message
+type Reaction struct {
+	MessageID int64
+	UserID    int64
+	Emoji     string
+	CreatedAt int64 // Unix timestamp in milliseconds
+}
+
+// ReactionSummary aggregates the reactions to a message that use the same emoji
+type ReactionSummary struct {
+	Emoji   string
+	UserIDs []int64 // In the order they reacted
+}
+
+// Mention is a registered user mentioned in a message
+type Mention struct {
+	MessageID    int64
+	UserID       int64 // Mentioned user
+	ChannelID    int64
+	SubchannelID *int64
+	CreatedAt    int64 // Message created_at (Unix milliseconds)
+	Read         bool  // Marked read, or the channel was read past it
+}
+
 // MessageVersion represents a version history entry
 type MessageVersion struct {
 	ID             int64
@@ -283,6 +336,8 @@ type MessageVersion struct {
 	AuthorNickname string
 	CreatedAt      int64  // Unix timestamp in milliseconds
 	VersionType    string // "created", "edited", "deleted"
+	EditorNickname string // Who made the change (empty for versions recorded before edit history)
+	AdminEdit      bool   // An admin edited someone else's message
 }
 
 // nowMillis returns current time as Unix timestamp in milliseconds
@@ -354,7 +409,7 @@ func (db *DB) CreateChannel(name, displayName string, description *string, chann
 // ListChannels returns all public channels
 func (db *DB) ListChannels() ([]*Channel, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, is_encrypted
 		FROM Channel
 		WHERE is_private = 0
 		ORDER BY name ASC
@@ -364,6 +419,27 @@ func (db *DB) ListChannels() ([]*Channel, error) {
 	}
 	defer rows.Close()
 
+	return scanChannels(rows)
+}
+
+// ListPrivateChannels returns all private channels (DM conversations)
+func (db *DB) ListPrivateChannels() ([]*Channel, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, is_encrypted
+		FROM Channel
+		WHERE is_private = 1
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanChannels(rows)
+}
+
+// scanChannels converts SQL rows into Channel structs
+func scanChannels(rows *sql.Rows) ([]*Channel, error) {
 	var channels []*Channel
 	for rows.Next() {
 		ch := &Channel{}
@@ -380,6 +456,7 @@ func (db *DB) ListChannels() ([]*Channel, error) {
 			&createdBy,
 			&ch.CreatedAt,
 			&ch.IsPrivate,
+			&ch.IsEncrypted,
 		)
 		if err != nil {
 			return nil, err
@@ -405,7 +482,7 @@ func (db *DB) GetChannel(id int64) (*Channel, error) {
 	var createdBy sql.NullInt64
 
 	err := db.conn.QueryRow(`
-		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private
+		SELECT id, name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, is_encrypted
 		FROM Channel
 		WHERE id = ?
 	`, id).Scan(
@@ -418,6 +495,7 @@ func (db *DB) GetChannel(id int64) (*Channel, error) {
 		&createdBy,
 		&ch.CreatedAt,
 		&ch.IsPrivate,
+		&ch.IsEncrypted,
 	)
 
 	if err != nil {
@@ -434,6 +512,333 @@ func (db *DB) GetChannel(id int64) (*Channel, error) {
 	return ch, nil
 }
 
+// ===== Subchannel Methods (V3 Channel Hierarchy) =====
+
+// CreateSubchannel creates a new subchannel under an existing channel
+// Returns an error if a subchannel with the same name already exists in the channel
+func (db *DB) CreateSubchannel(channelID int64, name, displayName string, description *string, subchannelType uint8, retentionHours uint32, createdBy *int64) (int64, error) {
+	start := time.Now()
+	descStr := sql.NullString{}
+	if description != nil {
+		descStr.Valid = true
+		descStr.String = *description
+	}
+
+	createdByVal := sql.NullInt64{}
+	if createdBy != nil {
+		createdByVal.Valid = true
+		createdByVal.Int64 = *createdBy
+	}
+
+	result, err := db.writeConn.Exec(`
+		INSERT INTO Subchannel (channel_id, name, display_name, description, subchannel_type, message_retention_hours, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, channelID, name, displayName, descStr, subchannelType, retentionHours, createdByVal, nowMillis())
+
+	if err != nil {
+		return 0, err
+	}
+
+	subchannelID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	elapsed := time.Since(start)
+	log.Printf("DB: CreateSubchannel took %v", elapsed)
+
+	return subchannelID, nil
+}
+
+// ListSubchannels returns all subchannels of a channel, ordered by name
+func (db *DB) ListSubchannels(channelID int64) ([]*Subchannel, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, channel_id, name, display_name, description, subchannel_type, message_retention_hours, created_by, created_at
+		FROM Subchannel
+		WHERE channel_id = ?
+		ORDER BY name ASC
+	`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubchannels(rows)
+}
+
+// ListAllSubchannels returns every subchannel across all channels
+func (db *DB) ListAllSubchannels() ([]*Subchannel, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, channel_id, name, display_name, description, subchannel_type, message_retention_hours, created_by, created_at
+		FROM Subchannel
+		ORDER BY channel_id ASC, name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubchannels(rows)
+}
+
+// GetSubchannel returns a subchannel by ID
+func (db *DB) GetSubchannel(id int64) (*Subchannel, error) {
+	sub := &Subchannel{}
+	var desc sql.NullString
+	var createdBy sql.NullInt64
+
+	err := db.conn.QueryRow(`
+		SELECT id, channel_id, name, display_name, description, subchannel_type, message_retention_hours, created_by, created_at
+		FROM Subchannel
+		WHERE id = ?
+	`, id).Scan(
+		&sub.ID,
+		&sub.ChannelID,
+		&sub.Name,
+		&sub.DisplayName,
+		&desc,
+		&sub.SubchannelType,
+		&sub.MessageRetentionHours,
+		&createdBy,
+		&sub.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if desc.Valid {
+		sub.Description = &desc.String
+	}
+	if createdBy.Valid {
+		sub.CreatedBy = &createdBy.Int64
+	}
+
+	return sub, nil
+}
+
+// scanSubchannels converts SQL rows into Subchannel structs
+func scanSubchannels(rows *sql.Rows) ([]*Subchannel, error) {
+	var subchannels []*Subchannel
+	for rows.Next() {
+		sub := &Subchannel{}
+		var desc sql.NullString
+		var createdBy sql.NullInt64
+
+		err := rows.Scan(
+			&sub.ID,
+			&sub.ChannelID,
+			&sub.Name,
+			&sub.DisplayName,
+			&desc,
+			&sub.SubchannelType,
+			&sub.MessageRetentionHours,
+			&createdBy,
+			&sub.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if desc.Valid {
+			sub.Description = &desc.String
+		}
+		if createdBy.Valid {
+			sub.CreatedBy = &createdBy.Int64
+		}
+
+		subchannels = append(subchannels, sub)
+	}
+
+	return subchannels, rows.Err()
+}
+
+// ===== Direct Message Methods (V3) =====
+
+// CreateDMChannel creates a private channel for a DM conversation and grants access to all participants
+func (db *DB) CreateDMChannel(createdBy int64, participantIDs []int64, encrypted bool) (int64, error) {
+	start := time.Now()
+	now := nowMillis()
+
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// DM channels are chat-type and never listed publicly, so the name only needs to be unique
+	name := fmt.Sprintf("dm-%d-%d", createdBy, time.Now().UnixNano())
+	result, err := tx.Exec(`
+		INSERT INTO Channel (name, display_name, description, channel_type, message_retention_hours, created_by, created_at, is_private, is_encrypted)
+		VALUES (?, ?, NULL, 0, ?, ?, ?, 1, ?)
+	`, name, "Direct message", DMRetentionHours, createdBy, now, encrypted)
+	if err != nil {
+		return 0, err
+	}
+
+	channelID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+
+	for _, userID := range participantIDs {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO ChannelAccess (channel_id, user_id, added_at)
+			VALUES (?, ?, ?)
+		`, channelID, userID, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	log.Printf("DB: CreateDMChannel took %v", time.Since(start))
+	return channelID, nil
+}
+
+// AddChannelAccess grants a user access to a private channel (no-op if already granted)
+func (db *DB) AddChannelAccess(channelID, userID int64) error {
+	_, err := db.writeConn.Exec(`
+		INSERT OR IGNORE INTO ChannelAccess (channel_id, user_id, added_at)
+		VALUES (?, ?, ?)
+	`, channelID, userID, nowMillis())
+	return err
+}
+
+// ListAllChannelAccess returns the participants of every private channel (channelID -> userIDs)
+func (db *DB) ListAllChannelAccess() (map[int64][]int64, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, user_id
+		FROM ChannelAccess
+		ORDER BY channel_id ASC, added_at ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	access := make(map[int64][]int64)
+	for rows.Next() {
+		var channelID, userID int64
+		if err := rows.Scan(&channelID, &userID); err != nil {
+			return nil, err
+		}
+		access[channelID] = append(access[channelID], userID)
+	}
+
+	return access, rows.Err()
+}
+
+// SetChannelEncrypted changes whether a DM channel is end-to-end encrypted
+func (db *DB) SetChannelEncrypted(channelID int64, encrypted bool) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE Channel SET is_encrypted = ? WHERE id = ?
+	`, encrypted, channelID)
+	return err
+}
+
+// GetAllowUnencryptedDMs returns whether a user accepts unencrypted DMs without being asked
+func (db *DB) GetAllowUnencryptedDMs(userID int64) (bool, error) {
+	var allow bool
+	err := db.conn.QueryRow(`
+		SELECT allow_unencrypted_dms FROM User WHERE id = ?
+	`, userID).Scan(&allow)
+	return allow, err
+}
+
+// SetAllowUnencryptedDMs stores a user's permanent unencrypted DM preference
+func (db *DB) SetAllowUnencryptedDMs(userID int64, allow bool) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE User SET allow_unencrypted_dms = ? WHERE id = ?
+	`, allow, userID)
+	return err
+}
+
+// AddEncryptionKey stores a user's X25519 public key and returns its ID (existing keys return their ID)
+func (db *DB) AddEncryptionKey(userID int64, keyType uint8, publicKey string, label *string) (int64, error) {
+	var labelVal sql.NullString
+	if label != nil {
+		labelVal.Valid = true
+		labelVal.String = *label
+	}
+
+	if _, err := db.writeConn.Exec(`
+		INSERT OR IGNORE INTO EncryptionKey (user_id, key_type, public_key, label, added_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, keyType, publicKey, labelVal, nowMillis()); err != nil {
+		return 0, err
+	}
+
+	var keyID int64
+	err := db.writeConn.QueryRow(`
+		SELECT id FROM EncryptionKey WHERE user_id = ? AND public_key = ?
+	`, userID, publicKey).Scan(&keyID)
+	return keyID, err
+}
+
+// ListEncryptionKeys returns all encryption public keys for a user
+func (db *DB) ListEncryptionKeys(userID int64) ([]EncryptionKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, user_id, key_type, public_key, label, added_at
+		FROM EncryptionKey
+		WHERE user_id = ?
+		ORDER BY id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []EncryptionKey
+	for rows.Next() {
+		var key EncryptionKey
+		var label sql.NullString
+		if err := rows.Scan(&key.ID, &key.UserID, &key.KeyType, &key.PublicKey, &label, &key.AddedAt); err != nil {
+			return nil, err
+		}
+		if label.Valid {
+			key.Label = &label.String
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// SetChannelKey stores a channel key wrapped for one encryption public key
+func (db *DB) SetChannelKey(channelID, keyID int64, wrappedKey string) error {
+	_, err := db.writeConn.Exec(`
+		INSERT OR REPLACE INTO ChannelKey (channel_id, key_id, wrapped_key, created_at)
+		VALUES (?, ?, ?, ?)
+	`, channelID, keyID, wrappedKey, nowMillis())
+	return err
+}
+
+// ListChannelKeys returns the wrapped keys of a channel (keyID -> wrapped key)
+func (db *DB) ListChannelKeys(channelID int64) (map[int64]string, error) {
+	rows, err := db.conn.Query(`
+		SELECT key_id, wrapped_key FROM ChannelKey WHERE channel_id = ?
+	`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[int64]string)
+	for rows.Next() {
+		var keyID int64
+		var wrappedKey string
+		if err := rows.Scan(&keyID, &wrappedKey); err != nil {
+			return nil, err
+		}
+		keys[keyID] = wrappedKey
+	}
+
+	return keys, rows.Err()
+}
+
 // CreateSession creates a new session record
 func (db *DB) CreateSession(userID *int64, nickname, connType string) (int64, error) {
 	start := time.Now()
@@ -669,42 +1074,162 @@ func (db *DB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64,
 	return scanMessages(rows)
 }
 
-// GetMessage returns a single message by ID
-func (db *DB) GetMessage(messageID uint64) (*Message, error) {
-	msg := &Message{}
-	var subchannelID, parentID, threadRootID, authorUserID, editedAt, deletedAt sql.NullInt64
+// MessageSearchFilter narrows a full-text message search. Nil fields don't filter.
+type MessageSearchFilter struct {
+	ChannelID      *int64
+	AuthorUserID   *int64  // Messages by a registered user
+	AuthorNickname *string // Messages by an anonymous user with this nickname
+	After          *int64  // Unix timestamp (milliseconds), inclusive
+	Before         *int64  // Unix timestamp (milliseconds), exclusive
+	ThreadRootID   *int64  // The thread root and all of its replies
+	ViewerUserID   *int64  // Private channels are only searched if the viewer has access
+}
 
-	err := db.conn.QueryRow(`
-		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
-		       content, created_at, edited_at, deleted_at
-		FROM Message
-		WHERE id = ?
-	`, messageID).Scan(
-		&msg.ID,
-		&msg.ChannelID,
-		&subchannelID,
-		&parentID,
-		&threadRootID,
-		&authorUserID,
-		&msg.AuthorNickname,
-		&msg.Content,
-		&msg.CreatedAt,
-		&editedAt,
-		&deletedAt,
-	)
+// SearchMessages returns non-deleted messages matching an FTS5 query, newest first.
+// Messages in encrypted channels are never indexed, so they never match.
+func (db *DB) SearchMessages(ftsQuery string, filter MessageSearchFilter, limit int) ([]*Message, error) {
+	query := `
+		SELECT m.id, m.channel_id, m.subchannel_id, m.parent_id, m.thread_root_id, m.author_user_id, m.author_nickname,
+		       m.content, m.created_at, m.edited_at, m.deleted_at
+		FROM MessageSearch s
+		JOIN Message m ON m.id = s.rowid
+		JOIN Channel c ON c.id = m.channel_id
+		WHERE MessageSearch MATCH ?
+		  AND m.deleted_at IS NULL
+		  AND c.is_encrypted = 0
+	`
+	args := []interface{}{ftsQuery}
 
-	if err != nil {
-		return nil, err
+	if filter.ViewerUserID != nil {
+		query += ` AND (c.is_private = 0 OR EXISTS (SELECT 1 FROM ChannelAccess a WHERE a.channel_id = c.id AND a.user_id = ?))`
+		args = append(args, *filter.ViewerUserID)
+	} else {
+		query += ` AND c.is_private = 0`
 	}
-
-	if subchannelID.Valid {
-		msg.SubchannelID = &subchannelID.Int64
+	if filter.ChannelID != nil {
+		query += ` AND m.channel_id = ?`
+		args = append(args, *filter.ChannelID)
 	}
-	if parentID.Valid {
-		msg.ParentID = &parentID.Int64
+	if filter.AuthorUserID != nil {
+		query += ` AND m.author_user_id = ?`
+		args = append(args, *filter.AuthorUserID)
 	}
-	if threadRootID.Valid {
-		msg.ThreadRootID = &threadRootID.Int64
+	if filter.AuthorNickname != nil {
+		query += ` AND m.author_user_id IS NULL AND m.author_nickname = ?`
+		args = append(args, *filter.AuthorNickname)
+	}
+	if filter.After != nil {
+		query += ` AND m.created_at >= ?`
+		args = append(args, *filter.After)
+	}
+	if filter.Before != nil {
+		query += ` AND m.created_at < ?`
+		args = append(args, *filter.Before)
+	}
+	if filter.ThreadRootID != nil {
+		query += ` AND (m.id = ? OR m.thread_root_id = ?)`
+		args = append(args, *filter.ThreadRootID, *filter.ThreadRootID)
+	}
+
+	query += ` ORDER BY m.created_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// ListAllReactions returns every stored reaction, oldest first
+func (db *DB) ListAllReactions() ([]Reaction, error) {
+	rows, err := db.conn.Query(`
+		SELECT message_id, user_id, emoji, created_at
+		FROM Reaction
+		ORDER BY created_at ASC, rowid ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactions []Reaction
+	for rows.Next() {
+		var r Reaction
+		if err := rows.Scan(&r.MessageID, &r.UserID, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+
+	return reactions, rows.Err()
+}
+
+// ReplaceReactions replaces the stored reactions of each message in byMessage
+// (messageID -> all of its current reactions) in a single transaction
+func (db *DB) ReplaceReactions(byMessage map[int64][]Reaction) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for messageID, reactions := range byMessage {
+		if _, err := tx.Exec(`DELETE FROM Reaction WHERE message_id = ?`, messageID); err != nil {
+			return fmt.Errorf("failed to clear reactions: %w", err)
+		}
+		for _, r := range reactions {
+			// Skip reactions of users or messages deleted since the reaction was cached
+			if _, err := tx.Exec(`
+				INSERT OR IGNORE INTO Reaction (message_id, user_id, emoji, created_at)
+				SELECT ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM User WHERE id = ?) AND EXISTS (SELECT 1 FROM Message WHERE id = ?)
+			`, r.MessageID, r.UserID, r.Emoji, r.CreatedAt, r.UserID, r.MessageID); err != nil {
+				return fmt.Errorf("failed to insert reaction: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetMessage returns a single message by ID
+func (db *DB) GetMessage(messageID uint64) (*Message, error) {
+	msg := &Message{}
+	var subchannelID, parentID, threadRootID, authorUserID, editedAt, deletedAt sql.NullInt64
+
+	err := db.conn.QueryRow(`
+		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
+		       content, created_at, edited_at, deleted_at
+		FROM Message
+		WHERE id = ?
+	`, messageID).Scan(
+		&msg.ID,
+		&msg.ChannelID,
+		&subchannelID,
+		&parentID,
+		&threadRootID,
+		&authorUserID,
+		&msg.AuthorNickname,
+		&msg.Content,
+		&msg.CreatedAt,
+		&editedAt,
+		&deletedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subchannelID.Valid {
+		msg.SubchannelID = &subchannelID.Int64
+	}
+	if parentID.Valid {
+		msg.ParentID = &parentID.Int64
+	}
+	if threadRootID.Valid {
+		msg.ThreadRootID = &threadRootID.Int64
 	}
 	if authorUserID.Valid {
 		msg.AuthorUserID = &authorUserID.Int64
@@ -901,7 +1426,7 @@ func (db *DB) AdminSoftDeleteMessage(messageID uint64, adminNickname string) (*M
 
 // UpdateMessage updates a message's content (for registered users only)
 // Returns the updated message with edited_at timestamp set
-func (db *DB) UpdateMessage(messageID uint64, userID uint64, newContent string) (*Message, error) {
+func (db *DB) UpdateMessage(messageID uint64, userID uint64, newContent, editorNickname string) (*Message, error) {
 	tx, err := db.conn.Begin()
 	if err != nil {
 		return nil, err
@@ -968,9 +1493,9 @@ func (db *DB) UpdateMessage(messageID uint64, userID uint64, newContent string)
 
 	// Record edit version with original content
 	if _, err := tx.Exec(`
-		INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type)
-		VALUES (?, ?, ?, ?, 'edited')
-	`, messageID, msg.Content, msg.AuthorNickname, editedAtMillis); err != nil {
+		INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type, editor_nickname, admin_edit)
+		VALUES (?, ?, ?, ?, 'edited', ?, 0)
+	`, messageID, msg.Content, msg.AuthorNickname, editedAtMillis, editorNickname); err != nil {
 		return nil, err
 	}
 
@@ -995,7 +1520,7 @@ func (db *DB) UpdateMessage(messageID uint64, userID uint64, newContent string)
 
 // AdminUpdateMessage updates a message's content (admin override - bypasses ownership check)
 // Returns the updated message with edited_at timestamp set
-func (db *DB) AdminUpdateMessage(messageID uint64, userID uint64, newContent string) (*Message, error) {
+func (db *DB) AdminUpdateMessage(messageID uint64, userID uint64, newContent, editorNickname string) (*Message, error) {
 	tx, err := db.conn.Begin()
 	if err != nil {
 		return nil, err
@@ -1059,10 +1584,11 @@ func (db *DB) AdminUpdateMessage(messageID uint64, userID uint64, newContent str
 	editedAtMillis := nowMillis()
 
 	// Record edit version with original content
+	adminEdit := *msg.AuthorUserID != int64(userID)
 	if _, err := tx.Exec(`
-		INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type)
-		VALUES (?, ?, ?, ?, 'edited')
-	`, messageID, msg.Content, msg.AuthorNickname, editedAtMillis); err != nil {
+		INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type, editor_nickname, admin_edit)
+		VALUES (?, ?, ?, ?, 'edited', ?, ?)
+	`, messageID, msg.Content, msg.AuthorNickname, editedAtMillis, editorNickname, adminEdit); err != nil {
 		return nil, err
 	}
 
@@ -1085,6 +1611,51 @@ func (db *DB) AdminUpdateMessage(messageID uint64, userID uint64, newContent str
 	return msg, nil
 }
 
+// InsertMessageVersions writes version history entries in a single transaction.
+// Entries of messages that no longer exist are skipped.
+func (db *DB) InsertMessageVersions(versions []MessageVersion) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, v := range versions {
+		if _, err := tx.Exec(`
+			INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type, editor_nickname, admin_edit)
+			SELECT ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM Message WHERE id = ?)
+		`, v.MessageID, v.Content, v.AuthorNickname, v.CreatedAt, v.VersionType, v.EditorNickname, v.AdminEdit, v.MessageID); err != nil {
+			return fmt.Errorf("failed to insert message version: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListMessageVersions returns the version history of a message, oldest first
+func (db *DB) ListMessageVersions(messageID int64) ([]MessageVersion, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, message_id, content, author_nickname, created_at, version_type, COALESCE(editor_nickname, ''), admin_edit
+		FROM MessageVersion
+		WHERE message_id = ?
+		ORDER BY created_at, id
+	`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []MessageVersion
+	for rows.Next() {
+		var v MessageVersion
+		if err := rows.Scan(&v.ID, &v.MessageID, &v.Content, &v.AuthorNickname, &v.CreatedAt, &v.VersionType, &v.EditorNickname, &v.AdminEdit); err != nil {
+			return nil, fmt.Errorf("failed to scan message version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
 // CountReplies returns the total number of descendants for a message
 func (db *DB) CountReplies(messageID int64) (uint32, error) {
 	var count uint32
@@ -1104,19 +1675,44 @@ func (db *DB) CountReplies(messageID int64) (uint32, error) {
 }
 
 // CleanupExpiredMessages deletes messages older than their channel's retention policy
-// Returns the number of messages deleted
+// Messages in a subchannel use the subchannel's retention instead of the channel's
+// Returns the number of root and soft-deleted messages deleted (not counting cascaded replies)
 func (db *DB) CleanupExpiredMessages() (int64, error) {
 	start := time.Now()
 	// Delete root messages (and their descendants via CASCADE) that are older than retention
-	// For each channel, calculate the cutoff time based on message_retention_hours
+	// For each channel/subchannel, calculate the cutoff time based on message_retention_hours
 	result, err := db.writeConn.Exec(`
 		DELETE FROM Message
 		WHERE id IN (
 			SELECT m.id
 			FROM Message m
 			INNER JOIN Channel c ON m.channel_id = c.id
+			LEFT JOIN Subchannel s ON m.subchannel_id = s.id
 			WHERE m.parent_id IS NULL
-			  AND m.created_at < (? - (c.message_retention_hours * 3600000))
+			  AND m.created_at < (? - (COALESCE(s.message_retention_hours, c.message_retention_hours) * 3600000))
+		)
+	`, nowMillis())
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired messages: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Delete messages soft-deleted longer than the retention ago, unless live replies
+	// still hang off them (those go when their thread expires)
+	result, err = db.writeConn.Exec(`
+		DELETE FROM Message
+		WHERE id IN (
+			SELECT m.id
+			FROM Message m
+			INNER JOIN Channel c ON m.channel_id = c.id
+			LEFT JOIN Subchannel s ON m.subchannel_id = s.id
+			WHERE m.deleted_at IS NOT NULL
+			  AND m.deleted_at < (? - (COALESCE(s.message_retention_hours, c.message_retention_hours) * 3600000))
+			  AND NOT EXISTS (SELECT 1 FROM Message r WHERE r.parent_id = m.id)
 		)
 	`, nowMillis())
 
@@ -1124,10 +1720,35 @@ func (db *DB) CleanupExpiredMessages() (int64, error) {
 	log.Printf("DB: CleanupExpiredMessages took %v", elapsed)
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to cleanup expired messages: %w", err)
+		return 0, fmt.Errorf("failed to cleanup deleted messages: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
 	}
 
-	return result.RowsAffected()
+	return count + deleted, nil
+}
+
+// DeleteMessages hard-deletes messages by ID. Replies of deleted messages go via CASCADE.
+func (db *DB) DeleteMessages(messageIDs []int64) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, messageID := range messageIDs {
+		if _, err := tx.Exec(`DELETE FROM Message WHERE id = ?`, messageID); err != nil {
+			return fmt.Errorf("failed to delete message: %w", err)
+		}
+	}
+
+	return tx.Commit()
 }
 
 // CleanupIdleSessions deletes sessions that have been idle for more than the timeout period
@@ -1223,6 +1844,130 @@ func (db *DB) MessageExists(messageID int64) (bool, error) {
 	return exists, err
 }
 
+// messageRowExists checks if a message row exists, including soft-deleted messages
+func (db *DB) messageRowExists(messageID int64) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM Message WHERE id = ?)`, messageID).Scan(&exists)
+	return exists, err
+}
+
+// threadRootOf returns the root of the thread a non-deleted message belongs to
+func (db *DB) threadRootOf(messageID int64) (rootID int64, found bool, err error) {
+	err = db.conn.QueryRow(`
+		SELECT COALESCE(thread_root_id, id) FROM Message WHERE id = ? AND deleted_at IS NULL
+	`, messageID).Scan(&rootID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return rootID, err == nil, err
+}
+
+// listMessagesAndReactions returns the non-deleted messages matching filter (a condition
+// on Message columns) oldest first, and their reactions oldest first
+func (db *DB) listMessagesAndReactions(filter string, args ...interface{}) ([]*Message, []Reaction, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id, author_nickname,
+		       content, created_at, edited_at, deleted_at
+		FROM Message
+		WHERE deleted_at IS NULL AND `+filter+`
+		ORDER BY created_at ASC
+	`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages, err := scanMessages(rows)
+	rows.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = db.conn.Query(`
+		SELECT message_id, user_id, emoji, created_at
+		FROM Reaction
+		WHERE message_id IN (SELECT id FROM Message WHERE deleted_at IS NULL AND `+filter+`)
+		ORDER BY created_at ASC, rowid ASC
+	`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var reactions []Reaction
+	for rows.Next() {
+		var r Reaction
+		if err := rows.Scan(&r.MessageID, &r.UserID, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+		reactions = append(reactions, r)
+	}
+
+	return messages, reactions, rows.Err()
+}
+
+// hasMessagesBefore checks if a channel has messages created before a time (Unix ms)
+func (db *DB) hasMessagesBefore(channelID int64, before int64) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM Message WHERE channel_id = ? AND created_at < ? AND deleted_at IS NULL)
+	`, channelID, before).Scan(&exists)
+	return exists, err
+}
+
+// countDirectReplies counts the non-deleted direct replies to a message
+func (db *DB) countDirectReplies(messageID int64) (uint32, error) {
+	var count uint32
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM Message WHERE parent_id = ? AND deleted_at IS NULL
+	`, messageID).Scan(&count)
+	return count, err
+}
+
+// listReactions returns the reactions to a message, oldest first
+func (db *DB) listReactions(messageID int64) ([]Reaction, error) {
+	rows, err := db.conn.Query(`
+		SELECT message_id, user_id, emoji, created_at
+		FROM Reaction
+		WHERE message_id = ?
+		ORDER BY created_at ASC, rowid ASC
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactions []Reaction
+	for rows.Next() {
+		var r Reaction
+		if err := rows.Scan(&r.MessageID, &r.UserID, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+
+	return reactions, rows.Err()
+}
+
+// countMessagesBetween counts the non-deleted messages in a channel (or one of its
+// subchannels, nil = channel root) created after since and before until (Unix ms)
+func (db *DB) countMessagesBetween(channelID int64, subchannelID *int64, since, until int64) (uint32, error) {
+	var subchannelIDVal sql.NullInt64
+	if subchannelID != nil {
+		subchannelIDVal.Valid = true
+		subchannelIDVal.Int64 = *subchannelID
+	}
+
+	var count uint32
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM Message
+		WHERE channel_id = ?
+		  AND (subchannel_id IS ? OR (subchannel_id IS NULL AND ? IS NULL))
+		  AND created_at > ? AND created_at < ?
+		  AND deleted_at IS NULL
+	`, channelID, subchannelIDVal, subchannelIDVal, since, until).Scan(&count)
+	return count, err
+}
+
 // CreateUser inserts a new registered user and returns the user ID
 func (db *DB) CreateUser(nickname, passwordHash string, userFlags uint8) (int64, error) {
 	now := nowMillis()
@@ -1272,89 +2017,334 @@ func (db *DB) GetUserByID(userID int64) (*User, error) {
 		return nil, err // sql.ErrNoRows if not found
 	}
 
-	return &user, nil
+	return &user, nil
+}
+
+// ListAllUsers retrieves all registered users, sorted by nickname
+// Used by admins with LIST_USERS include_offline flag
+func (db *DB) ListAllUsers(limit int) ([]*User, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, nickname, user_flags, password_hash, created_at, last_seen
+		FROM User
+		ORDER BY nickname ASC
+		LIMIT ?
+	`, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Nickname, &user.UserFlags, &user.PasswordHash, &user.CreatedAt, &user.LastSeen); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UpdateUserNickname updates a user's nickname
+// Returns error if nickname is already taken by another user
+func (db *DB) UpdateUserNickname(userID int64, newNickname string) error {
+	// Check if nickname is already taken by another user
+	existingUser, err := db.GetUserByNickname(newNickname)
+	if err == nil && existingUser.ID != userID {
+		// Nickname exists and belongs to a different user
+		return fmt.Errorf("nickname already in use")
+	}
+	if err != nil && err != sql.ErrNoRows {
+		// Database error (not just "not found")
+		return fmt.Errorf("failed to check nickname availability: %w", err)
+	}
+
+	// Update the user's nickname
+	_, err = db.conn.Exec(`
+		UPDATE User
+		SET nickname = ?
+		WHERE id = ?
+	`, newNickname, userID)
+
+	if err != nil {
+		return fmt.Errorf("failed to update nickname: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateUserLastSeen updates the last_seen timestamp for a user
+func (db *DB) UpdateUserLastSeen(userID int64) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE User SET last_seen = ? WHERE id = ?
+	`, nowMillis(), userID)
+	return err
+}
+
+// UpdateSessionUserID links a session to a registered user
+func (db *DB) UpdateSessionUserID(sessionID, userID int64) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE Session SET user_id = ? WHERE id = ?
+	`, userID, sessionID)
+	return err
+}
+
+// UpdateUserPassword updates a user's password hash
+func (db *DB) UpdateUserPassword(userID int64, newPasswordHash string) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE User SET password_hash = ? WHERE id = ?
+	`, newPasswordHash, userID)
+	return err
+}
+
+// UpdateUserFlags replaces a user's flags (admin, moderator). Taking away the
+// moderator flag also removes the user's channel scope.
+func (db *DB) UpdateUserFlags(userID int64, flags uint8) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE User SET user_flags = ? WHERE id = ?
+	`, flags, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if flags&0x02 == 0 { // Not a moderator
+		if _, err := tx.Exec(`DELETE FROM ModeratorChannel WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetModeratorChannels returns the channels a moderator is limited to. None means
+// the moderator moderates every channel.
+func (db *DB) GetModeratorChannels(userID int64) ([]int64, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id FROM ModeratorChannel WHERE user_id = ? ORDER BY channel_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channelIDs []int64
+	for rows.Next() {
+		var channelID int64
+		if err := rows.Scan(&channelID); err != nil {
+			return nil, err
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	return channelIDs, rows.Err()
+}
+
+// SetModeratorChannels replaces the channels a moderator is limited to (none = all)
+func (db *DB) SetModeratorChannels(userID int64, channelIDs []int64) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM ModeratorChannel WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, channelID := range channelIDs {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO ModeratorChannel (user_id, channel_id) VALUES (?, ?)
+		`, userID, channelID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ===== Two-Factor Authentication Methods =====
+
+// TwoFactor is a user's TOTP secret
+type TwoFactor struct {
+	UserID       int64
+	Secret       string // Base32
+	LastUsedStep int64  // Time step of the last accepted code
+	EnabledAt    int64  // Unix timestamp in milliseconds
+}
+
+// GetTwoFactor returns a user's TOTP secret, or nil if they don't use 2FA
+func (db *DB) GetTwoFactor(userID int64) (*TwoFactor, error) {
+	tf := &TwoFactor{UserID: userID}
+	err := db.conn.QueryRow(`
+		SELECT secret, last_used_step, enabled_at
+		FROM TwoFactor
+		WHERE user_id = ?
+	`, userID).Scan(&tf.Secret, &tf.LastUsedStep, &tf.EnabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tf, nil
+}
+
+// EnableTwoFactor stores a user's TOTP secret and recovery codes (as hashes),
+// replacing any they had
+func (db *DB) EnableTwoFactor(userID int64, secret string, recoveryCodeHashes []string) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO TwoFactor (user_id, secret, last_used_step, enabled_at)
+		VALUES (?, ?, 0, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			secret = excluded.secret,
+			last_used_step = 0,
+			enabled_at = excluded.enabled_at
+	`, userID, secret, nowMillis()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM TwoFactorRecoveryCode WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO TwoFactorRecoveryCode (user_id, code_hash) VALUES (?, ?)
+		`, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-// ListAllUsers retrieves all registered users, sorted by nickname
-// Used by admins with LIST_USERS include_offline flag
-func (db *DB) ListAllUsers(limit int) ([]*User, error) {
-	rows, err := db.conn.Query(`
-		SELECT id, nickname, user_flags, password_hash, created_at, last_seen
-		FROM User
-		ORDER BY nickname ASC
-		LIMIT ?
-	`, limit)
-
+// DisableTwoFactor removes a user's TOTP secret and recovery codes. Returns
+// sql.ErrNoRows if they didn't use 2FA.
+func (db *DB) DisableTwoFactor(userID int64) error {
+	tx, err := db.writeConn.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var users []*User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.ID, &user.Nickname, &user.UserFlags, &user.PasswordHash, &user.CreatedAt, &user.LastSeen); err != nil {
-			return nil, err
-		}
-		users = append(users, &user)
+	result, err := tx.Exec(`DELETE FROM TwoFactor WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM TwoFactorRecoveryCode WHERE user_id = ?`, userID); err != nil {
+		return err
 	}
 
-	return users, nil
+	return tx.Commit()
 }
 
-// UpdateUserNickname updates a user's nickname
-// Returns error if nickname is already taken by another user
-func (db *DB) UpdateUserNickname(userID int64, newNickname string) error {
-	// Check if nickname is already taken by another user
-	existingUser, err := db.GetUserByNickname(newNickname)
-	if err == nil && existingUser.ID != userID {
-		// Nickname exists and belongs to a different user
-		return fmt.Errorf("nickname already in use")
+// UseTwoFactorStep records that a code for a time step was accepted. It returns
+// false if a code for that step or a later one was already used (a replay).
+func (db *DB) UseTwoFactorStep(userID, step int64) (bool, error) {
+	result, err := db.writeConn.Exec(`
+		UPDATE TwoFactor SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
 	}
-	if err != nil && err != sql.ErrNoRows {
-		// Database error (not just "not found")
-		return fmt.Errorf("failed to check nickname availability: %w", err)
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
 	}
+	return rows > 0, nil
+}
 
-	// Update the user's nickname
-	_, err = db.conn.Exec(`
-		UPDATE User
-		SET nickname = ?
-		WHERE id = ?
-	`, newNickname, userID)
-
+// UseRecoveryCode deletes a user's recovery code, returning false if they don't
+// have it (any more)
+func (db *DB) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM TwoFactorRecoveryCode WHERE user_id = ? AND code_hash = ?
+	`, userID, codeHash)
 	if err != nil {
-		return fmt.Errorf("failed to update nickname: %w", err)
+		return false, err
 	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
 
-	return nil
+// CountRecoveryCodes returns how many unused recovery codes a user has
+func (db *DB) CountRecoveryCodes(userID int64) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM TwoFactorRecoveryCode WHERE user_id = ?
+	`, userID).Scan(&count)
+	return count, err
 }
 
-// UpdateUserLastSeen updates the last_seen timestamp for a user
-func (db *DB) UpdateUserLastSeen(userID int64) error {
-	_, err := db.writeConn.Exec(`
-		UPDATE User SET last_seen = ? WHERE id = ?
-	`, nowMillis(), userID)
-	return err
+// ===== User Profile Methods =====
+
+// UserProfile is what a registered user tells others about themselves
+type UserProfile struct {
+	UserID      int64
+	DisplayName string
+	Pronouns    string
+	Bio         string
+	Status      string // Free-form, e.g. "in a meeting"
+	UpdatedAt   int64  // Unix timestamp in milliseconds
 }
 
-// UpdateSessionUserID links a session to a registered user
-func (db *DB) UpdateSessionUserID(sessionID, userID int64) error {
-	_, err := db.writeConn.Exec(`
-		UPDATE Session SET user_id = ? WHERE id = ?
-	`, userID, sessionID)
-	return err
+// GetUserProfile returns a user's profile, or nil if they never set one
+func (db *DB) GetUserProfile(userID int64) (*UserProfile, error) {
+	p := &UserProfile{UserID: userID}
+	err := db.conn.QueryRow(`
+		SELECT display_name, pronouns, bio, status_text, updated_at
+		FROM UserProfile
+		WHERE user_id = ?
+	`, userID).Scan(&p.DisplayName, &p.Pronouns, &p.Bio, &p.Status, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-// UpdateUserPassword updates a user's password hash
-func (db *DB) UpdateUserPassword(userID int64, newPasswordHash string) error {
+// SaveUserProfile creates or replaces a user's profile, setting UpdatedAt
+func (db *DB) SaveUserProfile(p *UserProfile) error {
+	p.UpdatedAt = nowMillis()
 	_, err := db.writeConn.Exec(`
-		UPDATE User SET password_hash = ? WHERE id = ?
-	`, newPasswordHash, userID)
+		INSERT INTO UserProfile (user_id, display_name, pronouns, bio, status_text, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			display_name = excluded.display_name,
+			pronouns = excluded.pronouns,
+			bio = excluded.bio,
+			status_text = excluded.status_text,
+			updated_at = excluded.updated_at
+	`, p.UserID, p.DisplayName, p.Pronouns, p.Bio, p.Status, p.UpdatedAt)
 	return err
 }
 
@@ -1693,11 +2683,22 @@ type Ban struct {
 	BannedAt    int64  // Unix timestamp in milliseconds
 	BannedUntil *int64 // NULL = permanent, Unix timestamp in milliseconds for timed bans
 	BannedBy    string // Admin nickname who created the ban
+	ChannelID   *int64 // Channel a user ban is limited to (NULL = the whole server)
 }
 
 // CreateUserBan creates a new user ban and logs the admin action
 // Returns the ban ID and error
 func (db *DB) CreateUserBan(userID *int64, nickname *string, reason string, shadowban bool, durationSeconds *uint64, adminNickname, adminIP string) (int64, error) {
+	return db.createUserBan(userID, nickname, nil, reason, shadowban, durationSeconds, adminNickname, adminIP)
+}
+
+// CreateChannelBan creates a user ban that only keeps the user from posting in one
+// channel, and logs the admin action. Returns the ban ID and error.
+func (db *DB) CreateChannelBan(userID *int64, nickname *string, channelID int64, reason string, durationSeconds *uint64, adminNickname, adminIP string) (int64, error) {
+	return db.createUserBan(userID, nickname, &channelID, reason, false, durationSeconds, adminNickname, adminIP)
+}
+
+func (db *DB) createUserBan(userID *int64, nickname *string, channelID *int64, reason string, shadowban bool, durationSeconds *uint64, adminNickname, adminIP string) (int64, error) {
 	tx, err := db.writeConn.Begin()
 	if err != nil {
 		return 0, err
@@ -1712,9 +2713,9 @@ func (db *DB) CreateUserBan(userID *int64, nickname *string, reason string, shad
 	}
 
 	result, err := tx.Exec(`
-		INSERT INTO Ban (ban_type, user_id, nickname, reason, shadowban, banned_at, banned_until, banned_by)
-		VALUES ('user', ?, ?, ?, ?, ?, ?, ?)
-	`, userID, nickname, reason, shadowban, now, bannedUntil, adminNickname)
+		INSERT INTO Ban (ban_type, user_id, nickname, reason, shadowban, banned_at, banned_until, banned_by, channel_id)
+		VALUES ('user', ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, nickname, reason, shadowban, now, bannedUntil, adminNickname, channelID)
 
 	if err != nil {
 		return 0, err
@@ -1730,10 +2731,14 @@ func (db *DB) CreateUserBan(userID *int64, nickname *string, reason string, shad
 	if nickname != nil {
 		targetIdentifier = *nickname
 	}
+	details := reason
+	if channelID != nil {
+		details = fmt.Sprintf("%s (channel %d)", reason, *channelID)
+	}
 	_, err = tx.Exec(`
 		INSERT INTO AdminAction (admin_nickname, action_type, target_type, target_id, target_identifier, details, performed_at, ip_address)
 		VALUES (?, 'ban_user', 'user', ?, ?, ?, ?, ?)
-	`, adminNickname, banID, targetIdentifier, reason, now, adminIP)
+	`, adminNickname, banID, targetIdentifier, details, now, adminIP)
 
 	if err != nil {
 		return 0, err
@@ -1880,7 +2885,7 @@ func (db *DB) DeleteIPBan(ipCIDR string, adminNickname, adminIP string) (int64,
 	return rowsAffected, nil
 }
 
-// GetActiveBanForUser checks if a user is currently banned (non-expired)
+// GetActiveBanForUser checks if a user is currently banned from the server (non-expired)
 // Returns the ban record if active, nil if no active ban, or error
 func (db *DB) GetActiveBanForUser(userID *int64, nickname *string) (*Ban, error) {
 	now := nowMillis()
@@ -1895,6 +2900,7 @@ func (db *DB) GetActiveBanForUser(userID *int64, nickname *string) (*Ban, error)
 			FROM Ban
 			WHERE ban_type = 'user'
 			  AND user_id = ?
+			  AND channel_id IS NULL
 			  AND (banned_until IS NULL OR banned_until > ?)
 			LIMIT 1
 		`, *userID, now).Scan(
@@ -1907,6 +2913,7 @@ func (db *DB) GetActiveBanForUser(userID *int64, nickname *string) (*Ban, error)
 			FROM Ban
 			WHERE ban_type = 'user'
 			  AND nickname = ?
+			  AND channel_id IS NULL
 			  AND (banned_until IS NULL OR banned_until > ?)
 			LIMIT 1
 		`, *nickname, now).Scan(
@@ -1941,6 +2948,44 @@ func (db *DB) GetActiveBanForUser(userID *int64, nickname *string) (*Ban, error)
 	return ban, nil
 }
 
+// GetActiveChannelBan returns the active ban keeping a user (by ID if registered, and
+// by nickname) from posting in a channel, or nil if there is none
+func (db *DB) GetActiveChannelBan(userID *int64, nickname string, channelID int64) (*Ban, error) {
+	ban := &Ban{ChannelID: &channelID}
+	var userIDVal, bannedUntilVal sql.NullInt64
+	var nicknameVal sql.NullString
+	err := db.conn.QueryRow(`
+		SELECT id, ban_type, user_id, nickname, reason, banned_at, banned_until, banned_by
+		FROM Ban
+		WHERE ban_type = 'user'
+		  AND channel_id = ?
+		  AND (user_id = ? OR nickname = ?)
+		  AND (banned_until IS NULL OR banned_until > ?)
+		ORDER BY banned_until IS NULL DESC, banned_until DESC
+		LIMIT 1
+	`, channelID, userID, nickname, nowMillis()).Scan(
+		&ban.ID, &ban.BanType, &userIDVal, &nicknameVal,
+		&ban.Reason, &ban.BannedAt, &bannedUntilVal, &ban.BannedBy,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if userIDVal.Valid {
+		ban.UserID = &userIDVal.Int64
+	}
+	if nicknameVal.Valid {
+		ban.Nickname = &nicknameVal.String
+	}
+	if bannedUntilVal.Valid {
+		ban.BannedUntil = &bannedUntilVal.Int64
+	}
+	return ban, nil
+}
+
 // GetActiveBanForIP checks if an IP address is currently banned
 // Checks for exact match and CIDR range matches
 // Returns the ban record if active, nil if no active ban, or error
@@ -1992,7 +3037,7 @@ func (db *DB) GetActiveBanForIP(ipAddress string) (*Ban, error) {
 // ListBans returns all bans, optionally including expired bans
 func (db *DB) ListBans(includeExpired bool) ([]*Ban, error) {
 	query := `
-		SELECT id, ban_type, user_id, nickname, ip_cidr, reason, shadowban, banned_at, banned_until, banned_by
+		SELECT id, ban_type, user_id, nickname, ip_cidr, reason, shadowban, banned_at, banned_until, banned_by, channel_id
 		FROM Ban
 	`
 
@@ -2014,12 +3059,12 @@ func (db *DB) ListBans(includeExpired bool) ([]*Ban, error) {
 	var bans []*Ban
 	for rows.Next() {
 		ban := &Ban{}
-		var userIDVal, bannedUntilVal sql.NullInt64
+		var userIDVal, bannedUntilVal, channelIDVal sql.NullInt64
 		var nicknameVal, ipCIDRVal sql.NullString
 
 		err := rows.Scan(
 			&ban.ID, &ban.BanType, &userIDVal, &nicknameVal, &ipCIDRVal,
-			&ban.Reason, &ban.Shadowban, &ban.BannedAt, &bannedUntilVal, &ban.BannedBy,
+			&ban.Reason, &ban.Shadowban, &ban.BannedAt, &bannedUntilVal, &ban.BannedBy, &channelIDVal,
 		)
 		if err != nil {
 			return nil, err
@@ -2038,6 +3083,9 @@ func (db *DB) ListBans(includeExpired bool) ([]*Ban, error) {
 		if bannedUntilVal.Valid {
 			ban.BannedUntil = &bannedUntilVal.Int64
 		}
+		if channelIDVal.Valid {
+			ban.ChannelID = &channelIDVal.Int64
+		}
 
 		bans = append(bans, ban)
 	}
@@ -2045,6 +3093,126 @@ func (db *DB) ListBans(includeExpired bool) ([]*Ban, error) {
 	return bans, rows.Err()
 }
 
+// ===== Login Failure Methods (Brute-Force Protection) =====
+
+// LoginFailure counts failed logins for an account or IP address
+type LoginFailure struct {
+	Kind          string // "user" or "ip"
+	Identifier    string // Nickname or IP address
+	Failures      int    // Failures since the last successful login
+	LastFailureAt int64  // Unix timestamp in milliseconds
+	LockedUntil   *int64 // NULL if not locked, Unix timestamp in milliseconds
+}
+
+// GetLoginFailure returns the failed logins of an account or IP address (nil if none)
+func (db *DB) GetLoginFailure(kind, identifier string) (*LoginFailure, error) {
+	f := &LoginFailure{Kind: kind, Identifier: identifier}
+	var lockedUntil sql.NullInt64
+	err := db.conn.QueryRow(`
+		SELECT failures, last_failure_at, locked_until
+		FROM LoginFailure
+		WHERE kind = ? AND identifier = ?
+	`, kind, identifier).Scan(&f.Failures, &f.LastFailureAt, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		f.LockedUntil = &lockedUntil.Int64
+	}
+	return f, nil
+}
+
+// AddLoginFailure counts a failed login of an account or IP address at now and returns
+// the new count. Counting starts over if the last failure was before resetBefore and no
+// lockout is running. The count is incremented in SQL, so concurrent failures all count.
+func (db *DB) AddLoginFailure(kind, identifier string, now, resetBefore int64) (int, error) {
+	var failures int
+	err := db.writeConn.QueryRow(`
+		INSERT INTO LoginFailure (kind, identifier, failures, last_failure_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT(kind, identifier) DO UPDATE SET
+			failures = CASE WHEN LoginFailure.last_failure_at < ? AND COALESCE(LoginFailure.locked_until, 0) <= excluded.last_failure_at
+				THEN 1 ELSE LoginFailure.failures + 1 END,
+			locked_until = CASE WHEN LoginFailure.last_failure_at < ? AND COALESCE(LoginFailure.locked_until, 0) <= excluded.last_failure_at
+				THEN NULL ELSE LoginFailure.locked_until END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING failures
+	`, kind, identifier, now, resetBefore, resetBefore).Scan(&failures)
+	return failures, err
+}
+
+// ExtendLoginLockout locks an account or IP address out until lockedUntil, unless it's
+// locked out for longer already
+func (db *DB) ExtendLoginLockout(kind, identifier string, lockedUntil int64) error {
+	_, err := db.writeConn.Exec(`
+		UPDATE LoginFailure SET locked_until = MAX(COALESCE(locked_until, 0), ?)
+		WHERE kind = ? AND identifier = ?
+	`, lockedUntil, kind, identifier)
+	return err
+}
+
+// ClearLoginFailure forgets the failed logins of an account or IP address, lifting any
+// lockout. Returns sql.ErrNoRows if there were none.
+func (db *DB) ClearLoginFailure(kind, identifier string) error {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM LoginFailure WHERE kind = ? AND identifier = ?
+	`, kind, identifier)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListLoginLockouts returns the accounts and IP addresses locked out at now,
+// longest lockout first
+func (db *DB) ListLoginLockouts(now int64) ([]*LoginFailure, error) {
+	rows, err := db.conn.Query(`
+		SELECT kind, identifier, failures, last_failure_at, locked_until
+		FROM LoginFailure
+		WHERE locked_until > ?
+		ORDER BY locked_until DESC
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lockouts []*LoginFailure
+	for rows.Next() {
+		f := &LoginFailure{}
+		var lockedUntil int64
+		if err := rows.Scan(&f.Kind, &f.Identifier, &f.Failures, &f.LastFailureAt, &lockedUntil); err != nil {
+			return nil, err
+		}
+		f.LockedUntil = &lockedUntil
+		lockouts = append(lockouts, f)
+	}
+	return lockouts, rows.Err()
+}
+
+// CleanupLoginFailures deletes records without failures since before and that aren't
+// locked out any more. Returns the number deleted.
+func (db *DB) CleanupLoginFailures(before int64) (int64, error) {
+	result, err := db.writeConn.Exec(`
+		DELETE FROM LoginFailure
+		WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)
+	`, before, nowMillis())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // ===== Admin Action Logging =====
 
 // AdminAction represents an admin action audit log entry
@@ -2060,16 +3228,101 @@ type AdminAction struct {
 	IPAddress        *string // Admin's IP address (for audit trail)
 }
 
-// LogAdminAction logs an admin action to the AdminAction table
-func (db *DB) LogAdminAction(adminUserID uint64, adminNickname, actionType, details string) error {
-	now := nowMillis()
+// AdminActionFilter narrows down ListAdminActions. Zero values don't filter.
+type AdminActionFilter struct {
+	AdminNickname string
+	ActionType    string
+	Since         int64 // Unix milliseconds, inclusive
+	Until         int64 // Unix milliseconds, exclusive
+	BeforeID      int64 // Only entries older than this ID (for paging)
+	Limit         int   // Defaults to 100
+}
+
+// LogAdminAction logs an admin action to the AdminAction table.
+// PerformedAt defaults to now.
+func (db *DB) LogAdminAction(action AdminAction) error {
+	if action.PerformedAt == 0 {
+		action.PerformedAt = nowMillis()
+	}
+	ipAddress := ""
+	if action.IPAddress != nil {
+		ipAddress = *action.IPAddress
+	}
 	_, err := db.writeConn.Exec(`
-		INSERT INTO AdminAction (admin_user_id, admin_nickname, action_type, details, performed_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, adminUserID, adminNickname, actionType, details, now)
+		INSERT INTO AdminAction (admin_nickname, action_type, target_type, target_id, target_identifier, details, performed_at, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, action.AdminNickname, action.ActionType, action.TargetType, action.TargetID, action.TargetIdentifier,
+		action.Details, action.PerformedAt, ipAddress)
 	return err
 }
 
+// ListAdminActions returns audit log entries matching filter, newest first
+func (db *DB) ListAdminActions(filter AdminActionFilter) ([]*AdminAction, error) {
+	query := `
+		SELECT id, admin_nickname, action_type, target_type, target_id, target_identifier, details, performed_at, ip_address
+		FROM AdminAction
+		WHERE 1 = 1
+	`
+	args := []interface{}{}
+	if filter.AdminNickname != "" {
+		query += ` AND admin_nickname = ?`
+		args = append(args, filter.AdminNickname)
+	}
+	if filter.ActionType != "" {
+		query += ` AND action_type = ?`
+		args = append(args, filter.ActionType)
+	}
+	if filter.Since > 0 {
+		query += ` AND performed_at >= ?`
+		args = append(args, filter.Since)
+	}
+	if filter.Until > 0 {
+		query += ` AND performed_at < ?`
+		args = append(args, filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		query += ` AND id < ?`
+		args = append(args, filter.BeforeID)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []*AdminAction
+	for rows.Next() {
+		action := &AdminAction{}
+		var targetID sql.NullInt64
+		var targetIdentifier, details, ipAddress sql.NullString
+		if err := rows.Scan(
+			&action.ID, &action.AdminNickname, &action.ActionType, &action.TargetType, &targetID,
+			&targetIdentifier, &details, &action.PerformedAt, &ipAddress,
+		); err != nil {
+			return nil, err
+		}
+		if targetID.Valid {
+			action.TargetID = &targetID.Int64
+		}
+		action.TargetIdentifier = targetIdentifier.String
+		action.Details = details.String
+		if ipAddress.Valid && ipAddress.String != "" {
+			action.IPAddress = &ipAddress.String
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, rows.Err()
+}
+
 // DeleteChannel deletes a channel and all associated data (cascades to messages, subchannels, subscriptions)
 func (db *DB) DeleteChannel(channelID uint64) error {
 	_, err := db.writeConn.Exec(`DELETE FROM Channel WHERE id = ?`, channelID)
@@ -2152,6 +3405,112 @@ func (db *DB) GetUserChannelState(userID uint64, channelID uint64, subchannelID
 	return lastReadAt, nil
 }
 
+// AddMentions stores mentions of a newly posted message
+func (db *DB) AddMentions(mentions []Mention) error {
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, mention := range mentions {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO Mention (message_id, user_id, channel_id, subchannel_id, created_at)
+			VALUES (?, ?, ?, COALESCE(?, 0), ?)
+		`, mention.MessageID, mention.UserID, mention.ChannelID, mention.SubchannelID, mention.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert mention: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// mentionReadExpr is true for mentions marked read, or older than the user's read position in their channel
+const mentionReadExpr = `(m.read_at IS NOT NULL OR m.created_at <= COALESCE(s.last_read_at, 0))`
+
+// ListMentions returns a user's mentions, newest first. beforeID pages through older mentions.
+func (db *DB) ListMentions(userID int64, unreadOnly bool, beforeID *int64, limit int) ([]Mention, error) {
+	query := `
+		SELECT m.message_id, m.channel_id, m.subchannel_id, m.created_at, ` + mentionReadExpr + `
+		FROM Mention m
+		LEFT JOIN UserChannelState s
+		  ON s.user_id = m.user_id AND s.channel_id = m.channel_id AND s.subchannel_id = m.subchannel_id
+		WHERE m.user_id = ?
+	`
+	args := []interface{}{userID}
+	if unreadOnly {
+		query += ` AND NOT ` + mentionReadExpr
+	}
+	if beforeID != nil {
+		query += ` AND m.message_id < ?`
+		args = append(args, *beforeID)
+	}
+	query += ` ORDER BY m.message_id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mentions: %w", err)
+	}
+	defer rows.Close()
+
+	var mentions []Mention
+	for rows.Next() {
+		mention := Mention{UserID: userID}
+		var subchannelID int64
+		if err := rows.Scan(&mention.MessageID, &mention.ChannelID, &subchannelID, &mention.CreatedAt, &mention.Read); err != nil {
+			return nil, err
+		}
+		if subchannelID != 0 {
+			mention.SubchannelID = &subchannelID
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+// CountUnreadMentions counts a user's unread mentions
+func (db *DB) CountUnreadMentions(userID int64) (uint32, error) {
+	var count uint32
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM Mention m
+		LEFT JOIN UserChannelState s
+		  ON s.user_id = m.user_id AND s.channel_id = m.channel_id AND s.subchannel_id = m.subchannel_id
+		WHERE m.user_id = ? AND NOT `+mentionReadExpr, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread mentions: %w", err)
+	}
+	return count, nil
+}
+
+// MarkMentionsRead marks a user's mentions in the given messages as read, or all of them if messageIDs is empty
+func (db *DB) MarkMentionsRead(userID int64, messageIDs []int64, readAt int64) error {
+	if len(messageIDs) == 0 {
+		if _, err := db.writeConn.Exec(`UPDATE Mention SET read_at = ? WHERE user_id = ? AND read_at IS NULL`, readAt, userID); err != nil {
+			return fmt.Errorf("failed to mark mentions read: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := db.writeConn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, messageID := range messageIDs {
+		if _, err := tx.Exec(`
+			UPDATE Mention SET read_at = ? WHERE user_id = ? AND message_id = ? AND read_at IS NULL
+		`, readAt, userID, messageID); err != nil {
+			return fmt.Errorf("failed to mark mention read: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // GetUnreadCountForChannel counts unread messages in a channel after the given timestamp
 func (db *DB) GetUnreadCountForChannel(channelID uint64, subchannelID *uint64, sinceTimestamp int64) (uint32, error) {
 	query := `
@@ -1,9 +1,12 @@
 package database
 
 import (
+	"database/sql"
 	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func newTestDB(t *testing.T) *DB {
@@ -248,6 +251,60 @@ func TestCleanupExpiredMessagesWithReplies(t *testing.T) {
 	}
 }
 
+func TestCleanupExpiredDeletedMessages(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	// Create a channel with 1-hour retention
+	channelID, err := db.CreateChannel("shortretention", "#shortretention", nil, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	// A recent root with two replies deleted 2 hours ago, one of which still has a live reply
+	now := nowMillis()
+	twoHoursAgo := now - (2 * 3600 * 1000)
+	insert := func(parentID interface{}, deletedAt interface{}) int64 {
+		t.Helper()
+		result, err := db.conn.Exec(`
+			INSERT INTO Message (channel_id, parent_id, author_nickname, content, created_at, deleted_at)
+			VALUES (?, ?, 'alice', 'message', ?, ?)
+		`, channelID, parentID, now, deletedAt)
+		if err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+	rootID := insert(nil, nil)
+	leafID := insert(rootID, twoHoursAgo)
+	parentID := insert(rootID, twoHoursAgo)
+	insert(parentID, nil)
+
+	count, err := db.CleanupExpiredMessages()
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+
+	// Only the deleted leaf goes; the deleted parent is kept for its reply
+	if count != 1 {
+		t.Fatalf("expected 1 message deleted, got %d", count)
+	}
+	var remaining int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM Message WHERE id = ?`, leafID).Scan(&remaining); err != nil {
+		t.Fatalf("failed to count messages: %v", err)
+	}
+	if remaining != 0 {
+		t.Fatal("expected the deleted leaf to be removed")
+	}
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM Message WHERE channel_id = ?`, channelID).Scan(&remaining); err != nil {
+		t.Fatalf("failed to count messages: %v", err)
+	}
+	if remaining != 3 {
+		t.Fatalf("expected 3 messages left, got %d", remaining)
+	}
+}
+
 func TestCleanupIdleSessions(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
@@ -293,3 +350,527 @@ func TestCleanupIdleSessions(t *testing.T) {
 		t.Fatalf("expected 1 recent session, got %d", recentCount)
 	}
 }
+
+func TestSubchannelCRUD(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	channelID := mustChannelID(t, db)
+
+	desc := "Support threads"
+	supportID, err := db.CreateSubchannel(channelID, "support", "/support", &desc, 1, 720, nil)
+	if err != nil {
+		t.Fatalf("failed to create subchannel: %v", err)
+	}
+	if _, err := db.CreateSubchannel(channelID, "lounge", "/lounge", nil, 0, 24, nil); err != nil {
+		t.Fatalf("failed to create subchannel: %v", err)
+	}
+
+	// Names are unique per channel
+	if _, err := db.CreateSubchannel(channelID, "support", "/support", nil, 1, 168, nil); err == nil {
+		t.Fatal("expected duplicate subchannel name to fail")
+	}
+
+	// Parent channel must exist
+	if _, err := db.CreateSubchannel(channelID+1000, "orphan", "/orphan", nil, 1, 168, nil); err == nil {
+		t.Fatal("expected subchannel with missing parent channel to fail")
+	}
+
+	subs, err := db.ListSubchannels(channelID)
+	if err != nil {
+		t.Fatalf("failed to list subchannels: %v", err)
+	}
+	if len(subs) != 2 || subs[0].Name != "lounge" || subs[1].Name != "support" {
+		t.Fatalf("unexpected subchannels: %+v", subs)
+	}
+
+	sub, err := db.GetSubchannel(supportID)
+	if err != nil {
+		t.Fatalf("failed to get subchannel: %v", err)
+	}
+	if sub.ChannelID != channelID || sub.SubchannelType != 1 || sub.MessageRetentionHours != 720 {
+		t.Fatalf("unexpected subchannel: %+v", sub)
+	}
+	if sub.Description == nil || *sub.Description != desc {
+		t.Fatalf("expected description %q, got %v", desc, sub.Description)
+	}
+
+	exists, err := db.SubchannelExists(supportID)
+	if err != nil || !exists {
+		t.Fatalf("expected subchannel to exist, got %v (err=%v)", exists, err)
+	}
+
+	// Deleting the channel cascades to its subchannels
+	if err := db.DeleteChannel(uint64(channelID)); err != nil {
+		t.Fatalf("failed to delete channel: %v", err)
+	}
+	exists, err = db.SubchannelExists(supportID)
+	if err != nil || exists {
+		t.Fatalf("expected subchannel to be deleted with channel, got %v (err=%v)", exists, err)
+	}
+}
+
+func TestCleanupExpiredMessagesUsesSubchannelRetention(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	// Channel keeps messages for a week, subchannel only for an hour
+	channelID := mustChannelID(t, db)
+	subID, err := db.CreateSubchannel(channelID, "ephemeral", "/ephemeral", nil, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create subchannel: %v", err)
+	}
+
+	twoHoursAgo := nowMillis() - (2 * 3600 * 1000)
+	if _, err := db.conn.Exec(`
+		INSERT INTO Message (channel_id, subchannel_id, parent_id, author_nickname, content, created_at)
+		VALUES (?, ?, NULL, 'alice', 'old subchannel message', ?), (?, NULL, NULL, 'alice', 'old root message', ?)
+	`, channelID, subID, twoHoursAgo, channelID, twoHoursAgo); err != nil {
+		t.Fatalf("failed to create old messages: %v", err)
+	}
+
+	count, err := db.CleanupExpiredMessages()
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 message deleted, got %d", count)
+	}
+
+	var remaining string
+	if err := db.conn.QueryRow(`SELECT content FROM Message`).Scan(&remaining); err != nil {
+		t.Fatalf("failed to read remaining message: %v", err)
+	}
+	if remaining != "old root message" {
+		t.Fatalf("expected root message to survive, got %q", remaining)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	channelID := mustChannelID(t, db)
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	if err := db.AddMentions([]Mention{
+		{MessageID: 100, UserID: aliceID, ChannelID: channelID, CreatedAt: 1000},
+		{MessageID: 200, UserID: aliceID, ChannelID: channelID, CreatedAt: 2000},
+		{MessageID: 300, UserID: aliceID, ChannelID: channelID, CreatedAt: 3000},
+	}); err != nil {
+		t.Fatalf("failed to add mentions: %v", err)
+	}
+	// Adding the same mention again is ignored
+	if err := db.AddMentions([]Mention{{MessageID: 300, UserID: aliceID, ChannelID: channelID, CreatedAt: 3000}}); err != nil {
+		t.Fatalf("failed to add duplicate mention: %v", err)
+	}
+
+	mentions, err := db.ListMentions(aliceID, false, nil, 10)
+	if err != nil {
+		t.Fatalf("failed to list mentions: %v", err)
+	}
+	if len(mentions) != 3 || mentions[0].MessageID != 300 || mentions[2].MessageID != 100 {
+		t.Fatalf("expected 3 mentions newest first, got %+v", mentions)
+	}
+
+	// Paging
+	beforeID := int64(300)
+	mentions, err = db.ListMentions(aliceID, false, &beforeID, 1)
+	if err != nil || len(mentions) != 1 || mentions[0].MessageID != 200 {
+		t.Fatalf("expected mention 200 before 300, got %+v (err=%v)", mentions, err)
+	}
+
+	// Reading the channel past a mention marks it read
+	if err := db.UpdateUserChannelState(uint64(aliceID), uint64(channelID), nil, 1500); err != nil {
+		t.Fatalf("failed to update read state: %v", err)
+	}
+	// Mentions can also be marked read individually
+	if err := db.MarkMentionsRead(aliceID, []int64{300}, 5000); err != nil {
+		t.Fatalf("failed to mark mention read: %v", err)
+	}
+
+	count, err := db.CountUnreadMentions(aliceID)
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 unread mention, got %d (err=%v)", count, err)
+	}
+	mentions, err = db.ListMentions(aliceID, true, nil, 10)
+	if err != nil || len(mentions) != 1 || mentions[0].MessageID != 200 || mentions[0].Read {
+		t.Fatalf("expected only mention 200 unread, got %+v (err=%v)", mentions, err)
+	}
+
+	if err := db.MarkMentionsRead(aliceID, nil, 6000); err != nil {
+		t.Fatalf("failed to mark all mentions read: %v", err)
+	}
+	if count, err := db.CountUnreadMentions(aliceID); err != nil || count != 0 {
+		t.Fatalf("expected no unread mentions, got %d (err=%v)", count, err)
+	}
+	mentions, err = db.ListMentions(aliceID, false, nil, 10)
+	if err != nil || len(mentions) != 3 || !mentions[0].Read || !mentions[1].Read || !mentions[2].Read {
+		t.Fatalf("expected all mentions read, got %+v (err=%v)", mentions, err)
+	}
+
+	// Deleting the channel removes its mentions
+	if err := db.DeleteChannel(uint64(channelID)); err != nil {
+		t.Fatalf("failed to delete channel: %v", err)
+	}
+	if mentions, err := db.ListMentions(aliceID, false, nil, 10); err != nil || len(mentions) != 0 {
+		t.Fatalf("expected mentions to be deleted with channel, got %+v (err=%v)", mentions, err)
+	}
+}
+
+func TestListAdminActions(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	ip := "10.0.0.1"
+	entries := []AdminAction{
+		{AdminNickname: "alice", ActionType: "delete_user", TargetType: "user", TargetIdentifier: "mallory", PerformedAt: 1000, IPAddress: &ip},
+		{AdminNickname: "bob", ActionType: "delete_channel", TargetType: "channel", TargetIdentifier: "spam", PerformedAt: 2000},
+		{AdminNickname: "alice", ActionType: "delete_channel", TargetType: "channel", TargetIdentifier: "old", PerformedAt: 3000},
+	}
+	for _, entry := range entries {
+		if err := db.LogAdminAction(entry); err != nil {
+			t.Fatalf("failed to log admin action: %v", err)
+		}
+	}
+
+	all, err := db.ListAdminActions(AdminActionFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 entries, got %d (err=%v)", len(all), err)
+	}
+	if all[0].TargetIdentifier != "old" || all[2].IPAddress == nil || *all[2].IPAddress != ip || all[1].IPAddress != nil {
+		t.Errorf("unexpected entries: %+v, %+v, %+v", all[0], all[1], all[2])
+	}
+
+	tests := []struct {
+		filter AdminActionFilter
+		want   []string
+	}{
+		{AdminActionFilter{AdminNickname: "alice"}, []string{"old", "mallory"}},
+		{AdminActionFilter{ActionType: "delete_channel"}, []string{"old", "spam"}},
+		{AdminActionFilter{Since: 2000, Until: 3000}, []string{"spam"}},
+		{AdminActionFilter{BeforeID: all[0].ID, Limit: 1}, []string{"spam"}},
+	}
+	for _, tt := range tests {
+		got, err := db.ListAdminActions(tt.filter)
+		if err != nil {
+			t.Fatalf("ListAdminActions(%+v) failed: %v", tt.filter, err)
+		}
+		var targets []string
+		for _, action := range got {
+			targets = append(targets, action.TargetIdentifier)
+		}
+		if strings.Join(targets, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ListAdminActions(%+v) = %v, want %v", tt.filter, targets, tt.want)
+		}
+	}
+}
+
+func TestModeratorChannels(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	userID, err := db.CreateUser("mod", "hash", 0x02)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	desc := "test"
+	general, err := db.CreateChannel("general", "#general", &desc, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	random, err := db.CreateChannel("random", "#random", &desc, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	channels, err := db.GetModeratorChannels(userID)
+	if err != nil || len(channels) != 0 {
+		t.Fatalf("expected no scope for a new moderator, got %v (err=%v)", channels, err)
+	}
+
+	if err := db.SetModeratorChannels(userID, []int64{random, general, general}); err != nil {
+		t.Fatalf("SetModeratorChannels failed: %v", err)
+	}
+	channels, err = db.GetModeratorChannels(userID)
+	if err != nil || len(channels) != 2 || channels[0] != general || channels[1] != random {
+		t.Fatalf("expected [%d %d], got %v (err=%v)", general, random, channels, err)
+	}
+
+	// Changing flags keeps the scope while the user stays a moderator
+	if err := db.UpdateUserFlags(userID, 0x03); err != nil {
+		t.Fatalf("UpdateUserFlags failed: %v", err)
+	}
+	if channels, _ = db.GetModeratorChannels(userID); len(channels) != 2 {
+		t.Errorf("expected scope to survive a flag change, got %v", channels)
+	}
+
+	// Deleting a channel removes it from the scope
+	if err := db.DeleteChannel(uint64(random)); err != nil {
+		t.Fatalf("DeleteChannel failed: %v", err)
+	}
+	if channels, _ = db.GetModeratorChannels(userID); len(channels) != 1 || channels[0] != general {
+		t.Errorf("expected [%d] after deleting a channel, got %v", general, channels)
+	}
+
+	// Demoting removes the scope
+	if err := db.UpdateUserFlags(userID, 0); err != nil {
+		t.Fatalf("UpdateUserFlags failed: %v", err)
+	}
+	if channels, _ = db.GetModeratorChannels(userID); len(channels) != 0 {
+		t.Errorf("expected no scope after demotion, got %v", channels)
+	}
+
+	if err := db.UpdateUserFlags(userID+100, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for an unknown user, got %v", err)
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	userID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	tf, err := db.GetTwoFactor(userID)
+	if err != nil || tf != nil {
+		t.Fatalf("expected no 2FA for a new user, got %+v (err=%v)", tf, err)
+	}
+	if err := db.DisableTwoFactor(userID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for disabling 2FA that isn't enabled, got %v", err)
+	}
+
+	if err := db.EnableTwoFactor(userID, "OLDSECRET", []string{"old"}); err != nil {
+		t.Fatalf("EnableTwoFactor failed: %v", err)
+	}
+	if ok, err := db.UseTwoFactorStep(userID, 100); err != nil || !ok {
+		t.Fatalf("expected first use of step 100 to succeed (err=%v)", err)
+	}
+
+	// Enabling again replaces the secret, the codes and the last step
+	if err := db.EnableTwoFactor(userID, "JBSWY3DPEHPK3PXP", []string{"a", "b", "c"}); err != nil {
+		t.Fatalf("EnableTwoFactor failed: %v", err)
+	}
+	tf, err = db.GetTwoFactor(userID)
+	if err != nil || tf == nil || tf.Secret != "JBSWY3DPEHPK3PXP" || tf.LastUsedStep != 0 {
+		t.Fatalf("expected the new secret, got %+v (err=%v)", tf, err)
+	}
+	if count, err := db.CountRecoveryCodes(userID); err != nil || count != 3 {
+		t.Errorf("expected 3 recovery codes, got %d (err=%v)", count, err)
+	}
+
+	// Codes can't be replayed
+	if ok, _ := db.UseTwoFactorStep(userID, 100); !ok {
+		t.Error("expected step 100 to be accepted")
+	}
+	if ok, _ := db.UseTwoFactorStep(userID, 100); ok {
+		t.Error("expected step 100 to be rejected the second time")
+	}
+	if ok, _ := db.UseTwoFactorStep(userID, 99); ok {
+		t.Error("expected an older step to be rejected")
+	}
+
+	// Recovery codes work once
+	if ok, err := db.UseRecoveryCode(userID, "b"); err != nil || !ok {
+		t.Fatalf("expected recovery code to be accepted (err=%v)", err)
+	}
+	if ok, _ := db.UseRecoveryCode(userID, "b"); ok {
+		t.Error("expected used recovery code to be rejected")
+	}
+	if ok, _ := db.UseRecoveryCode(userID, "old"); ok {
+		t.Error("expected replaced recovery code to be rejected")
+	}
+
+	if err := db.DisableTwoFactor(userID); err != nil {
+		t.Fatalf("DisableTwoFactor failed: %v", err)
+	}
+	if tf, _ = db.GetTwoFactor(userID); tf != nil {
+		t.Errorf("expected 2FA to be disabled, got %+v", tf)
+	}
+	if count, _ := db.CountRecoveryCodes(userID); count != 0 {
+		t.Errorf("expected recovery codes to be deleted, got %d", count)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	userID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	p, err := db.GetUserProfile(userID)
+	if err != nil || p != nil {
+		t.Fatalf("expected no profile for a new user, got %+v (err=%v)", p, err)
+	}
+
+	if err := db.SaveUserProfile(&UserProfile{UserID: userID, DisplayName: "Alice", Pronouns: "she/her", Bio: "Hi\nthere"}); err != nil {
+		t.Fatalf("SaveUserProfile failed: %v", err)
+	}
+	// Saving again replaces every field
+	if err := db.SaveUserProfile(&UserProfile{UserID: userID, DisplayName: "Alice A.", Status: "in a meeting"}); err != nil {
+		t.Fatalf("SaveUserProfile failed: %v", err)
+	}
+	p, err = db.GetUserProfile(userID)
+	if err != nil || p == nil {
+		t.Fatalf("GetUserProfile failed: %+v (err=%v)", p, err)
+	}
+	if p.DisplayName != "Alice A." || p.Pronouns != "" || p.Bio != "" || p.Status != "in a meeting" {
+		t.Errorf("unexpected profile %+v", p)
+	}
+	if p.UpdatedAt == 0 {
+		t.Error("expected UpdatedAt to be set")
+	}
+}
+
+func TestLoginFailures(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	f, err := db.GetLoginFailure("user", "alice")
+	if err != nil || f != nil {
+		t.Fatalf("expected no failures for a new account, got %+v (err=%v)", f, err)
+	}
+
+	now := time.Now().UnixMilli()
+	for i := 0; i < 5; i++ {
+		if _, err := db.AddLoginFailure("user", "alice", now-int64(4-i)*500, now-60000); err != nil {
+			t.Fatalf("AddLoginFailure failed: %v", err)
+		}
+	}
+	lockedUntil := now + 60000
+	if err := db.ExtendLoginLockout("user", "alice", lockedUntil); err != nil {
+		t.Fatalf("ExtendLoginLockout failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := db.AddLoginFailure("ip", "192.0.2.1", now, now-60000); err != nil {
+			t.Fatalf("AddLoginFailure failed: %v", err)
+		}
+	}
+
+	f, err = db.GetLoginFailure("user", "alice")
+	if err != nil || f == nil || f.Failures != 5 || f.LastFailureAt != now || f.LockedUntil == nil || *f.LockedUntil != lockedUntil {
+		t.Fatalf("expected 5 failures and a lockout, got %+v (err=%v)", f, err)
+	}
+
+	lockouts, err := db.ListLoginLockouts(now)
+	if err != nil || len(lockouts) != 1 || lockouts[0].Identifier != "alice" {
+		t.Fatalf("expected alice to be locked out, got %+v (err=%v)", lockouts, err)
+	}
+	if lockouts, _ = db.ListLoginLockouts(lockedUntil); len(lockouts) != 0 {
+		t.Errorf("expected lockout to expire, got %+v", lockouts)
+	}
+
+	// Stale records go, lockouts stay until they expire
+	deleted, err := db.CleanupLoginFailures(now + 1)
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected the IP record to be cleaned up, deleted %d (err=%v)", deleted, err)
+	}
+
+	if err := db.ClearLoginFailure("user", "alice"); err != nil {
+		t.Fatalf("ClearLoginFailure failed: %v", err)
+	}
+	if f, _ = db.GetLoginFailure("user", "alice"); f != nil {
+		t.Errorf("expected failures to be cleared, got %+v", f)
+	}
+	if err := db.ClearLoginFailure("user", "alice"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for nothing to clear, got %v", err)
+	}
+}
+
+func TestAddLoginFailure(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	now := time.Now().UnixMilli()
+	for i := 1; i <= 3; i++ {
+		failures, err := db.AddLoginFailure("user", "alice", now, now-1000)
+		if err != nil || failures != i {
+			t.Fatalf("expected failure %d, got %d (err=%v)", i, failures, err)
+		}
+	}
+
+	// Lockouts only ever get longer
+	if err := db.ExtendLoginLockout("user", "alice", now+60000); err != nil {
+		t.Fatalf("ExtendLoginLockout failed: %v", err)
+	}
+	if err := db.ExtendLoginLockout("user", "alice", now+1000); err != nil {
+		t.Fatalf("ExtendLoginLockout failed: %v", err)
+	}
+	if f, _ := db.GetLoginFailure("user", "alice"); f == nil || f.LockedUntil == nil || *f.LockedUntil != now+60000 {
+		t.Fatalf("expected the longer lockout to stay, got %+v", f)
+	}
+
+	// A running lockout keeps counting, even past the window
+	later := now + 30000
+	if failures, _ := db.AddLoginFailure("user", "alice", later, later-1000); failures != 4 {
+		t.Errorf("expected counting to go on during a lockout, got %d", failures)
+	}
+
+	// After the lockout and the window, counting starts over
+	later = now + 120000
+	failures, err := db.AddLoginFailure("user", "alice", later, later-1000)
+	if err != nil || failures != 1 {
+		t.Fatalf("expected counting to start over, got %d (err=%v)", failures, err)
+	}
+	if f, _ := db.GetLoginFailure("user", "alice"); f == nil || f.LockedUntil != nil || f.LastFailureAt != later {
+		t.Errorf("expected the old lockout to be cleared, got %+v", f)
+	}
+}
+
+func TestChannelBans(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	channelID := mustChannelID(t, db)
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	alice := "alice"
+
+	hour := uint64(3600)
+	if _, err := db.CreateChannelBan(&aliceID, &alice, channelID, "spam", &hour, "mod", "127.0.0.1"); err != nil {
+		t.Fatalf("CreateChannelBan failed: %v", err)
+	}
+
+	// Channel bans don't keep anyone off the server
+	if ban, err := db.GetActiveBanForUser(&aliceID, &alice); err != nil || ban != nil {
+		t.Errorf("expected no server-wide ban, got %+v (err=%v)", ban, err)
+	}
+
+	ban, err := db.GetActiveChannelBan(&aliceID, "alice", channelID)
+	if err != nil || ban == nil || ban.ChannelID == nil || *ban.ChannelID != channelID || ban.BannedUntil == nil {
+		t.Fatalf("expected a timed ban from the channel, got %+v (err=%v)", ban, err)
+	}
+	if ban, _ := db.GetActiveChannelBan(nil, "alice", channelID); ban == nil {
+		t.Error("expected the ban to match the nickname too")
+	}
+	if ban, _ := db.GetActiveChannelBan(nil, "bob", channelID); ban != nil {
+		t.Errorf("expected no ban for bob, got %+v", ban)
+	}
+	if ban, _ := db.GetActiveChannelBan(&aliceID, "alice", channelID+1); ban != nil {
+		t.Errorf("expected no ban in another channel, got %+v", ban)
+	}
+
+	bans, err := db.ListBans(false)
+	if err != nil || len(bans) != 1 || bans[0].ChannelID == nil || *bans[0].ChannelID != channelID {
+		t.Errorf("expected the channel ban in the list, got %+v (err=%v)", bans, err)
+	}
+
+	// Deleting the channel deletes its bans
+	if err := db.DeleteChannel(uint64(channelID)); err != nil {
+		t.Fatalf("DeleteChannel failed: %v", err)
+	}
+	if bans, _ := db.ListBans(true); len(bans) != 0 {
+		t.Errorf("expected the ban to go with the channel, got %d", len(bans))
+	}
+}
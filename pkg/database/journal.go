@@ -0,0 +1,467 @@
+package database
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalSyncPolicy controls when journal writes are fsynced to disk
+type JournalSyncPolicy int
+
+const (
+	// JournalSyncAlways fsyncs every write before the operation returns
+	JournalSyncAlways JournalSyncPolicy = iota
+	// JournalSyncBatch lets concurrent writes share one fsync. Each operation still
+	// waits until its write is on disk, so nothing acknowledged is lost.
+	JournalSyncBatch
+	// JournalSyncInterval fsyncs in the background every SyncInterval. Operations
+	// don't wait, so a crash can lose up to one interval of writes.
+	JournalSyncInterval
+)
+
+// defaultJournalSyncInterval is used for JournalSyncInterval when no interval is set
+const defaultJournalSyncInterval = time.Second
+
+// ParseJournalSyncPolicy parses a sync policy name: "always", "batch" or "interval"
+func ParseJournalSyncPolicy(name string) (JournalSyncPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "always":
+		return JournalSyncAlways, nil
+	case "batch", "":
+		return JournalSyncBatch, nil
+	case "interval":
+		return JournalSyncInterval, nil
+	default:
+		return 0, fmt.Errorf("unknown journal sync policy %q (expected always, batch or interval)", name)
+	}
+}
+
+// String returns the policy name
+func (p JournalSyncPolicy) String() string {
+	switch p {
+	case JournalSyncAlways:
+		return "always"
+	case JournalSyncBatch:
+		return "batch"
+	case JournalSyncInterval:
+		return "interval"
+	default:
+		return "unknown"
+	}
+}
+
+// JournalConfig configures the MemDB write-ahead journal
+type JournalConfig struct {
+	Dir          string // Directory for journal segments ("" = no journal)
+	SyncPolicy   JournalSyncPolicy
+	SyncInterval time.Duration // For JournalSyncInterval (0 = 1 second)
+}
+
+// Journal entry operations
+const (
+	journalOpCreate = "create"
+	journalOpEdit   = "edit"
+	journalOpDelete = "delete"
+)
+
+// journalEntry is one message operation. Timestamp is created_at, edited_at or
+// deleted_at depending on the operation.
+type journalEntry struct {
+	Op             string `json:"op"`
+	MessageID      int64  `json:"id"`
+	ChannelID      int64  `json:"channel_id,omitempty"`
+	SubchannelID   *int64 `json:"subchannel_id,omitempty"`
+	ParentID       *int64 `json:"parent_id,omitempty"`
+	ThreadRootID   *int64 `json:"thread_root_id,omitempty"`
+	AuthorUserID   *int64 `json:"author_user_id,omitempty"`
+	AuthorNickname string `json:"author_nickname,omitempty"`
+	Content        string `json:"content,omitempty"`
+	Timestamp      int64  `json:"ts"`
+	EditorNickname string `json:"editor_nickname,omitempty"`
+	AdminEdit      bool   `json:"admin_edit,omitempty"`
+}
+
+// journal is an append-only log of message operations that haven't been snapshotted
+// yet. It is split into numbered segment files; each snapshot starts a new segment and
+// removes the older ones once the snapshot is written.
+//
+// Every record is framed as [length u32][crc32 u32][JSON], so a record torn by a crash
+// is detected and ignored on replay. All methods are safe to call on a nil journal.
+type journal struct {
+	dir          string
+	policy       JournalSyncPolicy
+	syncInterval time.Duration
+
+	mu       sync.Mutex
+	cond     *sync.Cond // Signalled when a sync finishes
+	file     *os.File
+	segment  uint64 // Number of the segment being written
+	written  uint64 // Sequence number of the last record written
+	synced   uint64 // Sequence number of the last record known to be on disk
+	syncing  bool   // An fsync is running without mu held
+	size     int64  // Size of the current segment
+	segDirty bool   // Records were written to the current segment
+
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// openJournal opens the journal in config.Dir and starts a new segment after any
+// existing ones (which are left for replay). Returns nil if config.Dir is empty.
+func openJournal(config JournalConfig) (*journal, error) {
+	if config.Dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	segments, err := listJournalSegments(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var next uint64 = 1
+	if len(segments) > 0 {
+		next = segments[len(segments)-1] + 1
+	}
+
+	j := &journal{
+		dir:          config.Dir,
+		policy:       config.SyncPolicy,
+		syncInterval: config.SyncInterval,
+		shutdown:     make(chan struct{}),
+	}
+	j.cond = sync.NewCond(&j.mu)
+	if j.syncInterval <= 0 {
+		j.syncInterval = defaultJournalSyncInterval
+	}
+	if err := j.openSegmentLocked(next); err != nil {
+		return nil, err
+	}
+
+	if j.policy == JournalSyncInterval {
+		j.wg.Add(1)
+		go j.syncLoop()
+	}
+
+	return j, nil
+}
+
+// segmentPath returns the file name of a segment
+func (j *journal) segmentPath(segment uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%016d.journal", segment))
+}
+
+// listJournalSegments returns the segment numbers in dir, oldest first
+func listJournalSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+	var segments []uint64
+	for _, entry := range entries {
+		name, isSegment := strings.CutSuffix(entry.Name(), ".journal")
+		if !isSegment || entry.IsDir() {
+			continue
+		}
+		if segment, err := strconv.ParseUint(name, 10, 64); err == nil {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Slice(segments, func(i, k int) bool { return segments[i] < segments[k] })
+	return segments, nil
+}
+
+// openSegmentLocked creates segment and makes it the current one (assumes lock held)
+func (j *journal) openSegmentLocked(segment uint64) error {
+	file, err := os.OpenFile(j.segmentPath(segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal segment: %w", err)
+	}
+	// Make the new file itself durable, so records synced into it can't vanish with it
+	if dir, err := os.Open(j.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	j.file = file
+	j.segment = segment
+	j.size = 0
+	j.segDirty = false
+	return nil
+}
+
+// append writes an entry and returns its sequence number, to be passed to wait.
+// With JournalSyncAlways the entry is on disk when append returns; otherwise it may not be.
+func (j *journal) append(entry journalEntry) (uint64, error) {
+	if j == nil {
+		return 0, nil
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+	record := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[8:], payload)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return 0, errors.New("journal is closed")
+	}
+	if _, err := j.file.Write(record); err != nil {
+		// Cut off a partial record, or replay would stop there and skip later ones
+		j.file.Truncate(j.size)
+		return 0, fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	j.size += int64(len(record))
+	j.written++
+	j.segDirty = true
+	seq := j.written
+
+	if j.policy == JournalSyncAlways {
+		for j.syncing {
+			j.cond.Wait()
+		}
+		if j.synced < seq {
+			if err := j.syncLocked(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return seq, nil
+}
+
+// wait blocks until the record with sequence number seq is on disk (except for
+// JournalSyncInterval). Concurrent waiters share fsyncs: whoever finds no sync running
+// starts one covering everything written so far.
+func (j *journal) wait(seq uint64) error {
+	if j == nil || j.policy == JournalSyncInterval {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for j.synced < seq {
+		if j.syncing {
+			j.cond.Wait()
+			continue
+		}
+		if err := j.syncLocked(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncLocked fsyncs the current segment with mu released during the fsync, so
+// appends can continue meanwhile (assumes lock held)
+func (j *journal) syncLocked() error {
+	if j.file == nil {
+		return errors.New("journal is closed")
+	}
+	file := j.file
+	target := j.written
+
+	j.syncing = true
+	j.mu.Unlock()
+	err := file.Sync()
+	j.mu.Lock()
+	j.syncing = false
+
+	if err == nil && target > j.synced {
+		j.synced = target
+	}
+	j.cond.Broadcast()
+	if err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+// syncLoop fsyncs periodically for JournalSyncInterval
+func (j *journal) syncLoop() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.synced < j.written && !j.syncing {
+				if err := j.syncLocked(); err != nil {
+					log.Printf("MemDB: %v", err)
+				}
+			}
+			j.mu.Unlock()
+		case <-j.shutdown:
+			return
+		}
+	}
+}
+
+// rotate starts a new segment if the current one has records, and returns the
+// current segment number. Once a snapshot taken at this point is written, the
+// segments before it can be removed with removeBefore.
+func (j *journal) rotate() (uint64, error) {
+	if j == nil {
+		return 0, nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return 0, errors.New("journal is closed")
+	}
+	if !j.segDirty {
+		return j.segment, nil
+	}
+
+	// Waiters for records in the old segment must be able to finish
+	for j.syncing {
+		j.cond.Wait()
+	}
+	if j.synced < j.written {
+		if err := j.syncLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	old := j.file
+	if err := j.openSegmentLocked(j.segment + 1); err != nil {
+		return 0, err
+	}
+	old.Close()
+	return j.segment, nil
+}
+
+// removeBefore deletes the segments older than segment
+func (j *journal) removeBefore(segment uint64) error {
+	if j == nil {
+		return nil
+	}
+
+	segments, err := listJournalSegments(j.dir)
+	if err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if s >= segment {
+			break
+		}
+		if err := os.Remove(j.segmentPath(s)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove journal segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// replay calls apply for every entry in the segments before the current one, oldest
+// first. A torn or corrupt record ends its segment, since nothing after it can be trusted.
+func (j *journal) replay(apply func(journalEntry)) (int, error) {
+	if j == nil {
+		return 0, nil
+	}
+
+	segments, err := listJournalSegments(j.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, segment := range segments {
+		if segment >= j.segment {
+			break
+		}
+		n, err := replaySegment(j.segmentPath(segment), apply)
+		replayed += n
+		if err != nil {
+			log.Printf("MemDB: journal segment %d ends with an unreadable record after %d entries (ignored): %v", segment, n, err)
+		}
+	}
+	return replayed, nil
+}
+
+// replaySegment applies the entries of one segment file
+func replaySegment(path string, apply func(journalEntry)) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, 8)
+	count := 0
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return count, nil
+			}
+			return count, err
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		if length > 64*1024*1024 {
+			return count, fmt.Errorf("record length %d too large", length)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return count, err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			return count, errors.New("checksum mismatch")
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			return count, err
+		}
+		apply(entry)
+		count++
+	}
+}
+
+// close syncs and closes the current segment
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+
+	close(j.shutdown)
+	j.wg.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for j.syncing {
+		j.cond.Wait()
+	}
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Sync()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	j.file = nil
+	return err
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemDBJournalReplay(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	channelID, err := db.CreateChannel("general", "#general", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	journalConfig := JournalConfig{Dir: filepath.Join(tmpDir, "test.db.journal"), SyncPolicy: JournalSyncBatch}
+	crashed, err := NewMemDBWithOptions(db, time.Hour, MemDBOptions{Journal: journalConfig})
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	rootID, _, err := crashed.PostMessage(channelID, nil, nil, &aliceID, "alice", "first")
+	if err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	replyID, _, err := crashed.PostMessage(channelID, nil, &rootID, nil, "~guest", "reply")
+	if err != nil {
+		t.Fatalf("failed to post reply: %v", err)
+	}
+	if _, err := crashed.UpdateMessage(uint64(rootID), uint64(aliceID), "first (edited)", "alice"); err != nil {
+		t.Fatalf("failed to edit message: %v", err)
+	}
+	if _, err := crashed.SoftDeleteMessage(uint64(replyID), "~guest"); err != nil {
+		t.Fatalf("failed to delete reply: %v", err)
+	}
+
+	// Simulate a crash: nothing was snapshotted, the old MemDB is abandoned
+	if exists, _ := db.MessageExists(rootID); exists {
+		t.Fatal("expected message to be only in memory before the snapshot")
+	}
+	crashed.journal.close()
+
+	recovered, err := NewMemDBWithOptions(db, time.Hour, MemDBOptions{Journal: journalConfig})
+	if err != nil {
+		t.Fatalf("failed to recover MemDB: %v", err)
+	}
+	defer recovered.Close()
+
+	root, err := recovered.GetMessage(rootID)
+	if err != nil {
+		t.Fatalf("expected message to be replayed: %v", err)
+	}
+	if root.Content != "first (edited)" || root.EditedAt == nil {
+		t.Errorf("expected edited content to be replayed, got %q (edited_at %v)", root.Content, root.EditedAt)
+	}
+	if count, _ := recovered.CountReplies(rootID); count != 0 {
+		t.Errorf("expected deleted reply not to be counted, got %d", count)
+	}
+	recovered.mu.RLock()
+	reply := recovered.messages[replyID]
+	recovered.mu.RUnlock()
+	if reply == nil || reply.DeletedAt == nil {
+		t.Errorf("expected reply to be replayed as deleted, got %+v", reply)
+	}
+
+	// Replayed operations are in SQLite, including the edit history
+	if exists, _ := db.MessageExists(rootID); !exists {
+		t.Error("expected replayed message to be snapshotted")
+	}
+	versions, err := db.ListMessageVersions(rootID)
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Content != "first" || versions[0].EditorNickname != "alice" {
+		t.Errorf("expected one version with the original content, got %+v", versions)
+	}
+
+	// ...and the old segments are gone, so a second restart replays nothing
+	segments, err := listJournalSegments(journalConfig.Dir)
+	if err != nil {
+		t.Fatalf("failed to list segments: %v", err)
+	}
+	if len(segments) != 1 || segments[0] != recovered.journal.segment {
+		t.Errorf("expected only the current segment, got %v", segments)
+	}
+}
+
+func TestJournalTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, policy := range []JournalSyncPolicy{JournalSyncAlways, JournalSyncBatch, JournalSyncInterval} {
+		j, err := openJournal(JournalConfig{Dir: dir, SyncPolicy: policy, SyncInterval: 10 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("failed to open journal: %v", err)
+		}
+		seq, err := j.append(journalEntry{Op: journalOpDelete, MessageID: 1, Timestamp: 1})
+		if err != nil {
+			t.Fatalf("append (%s) failed: %v", policy, err)
+		}
+		if err := j.wait(seq); err != nil {
+			t.Fatalf("wait (%s) failed: %v", policy, err)
+		}
+		if err := j.close(); err != nil {
+			t.Fatalf("close (%s) failed: %v", policy, err)
+		}
+	}
+
+	// A crash mid-write leaves half a record at the end of the last segment
+	segments, _ := listJournalSegments(dir)
+	if len(segments) != 3 {
+		t.Fatalf("expected 3 segments, got %v", segments)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "0000000000000003.journal"), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	f.Write([]byte{0, 0, 0, 50, 1, 2, 3, 4, '{'})
+	f.Close()
+
+	j, err := openJournal(JournalConfig{Dir: dir, SyncPolicy: JournalSyncBatch})
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	defer j.close()
+
+	var replayed []journalEntry
+	count, err := j.replay(func(entry journalEntry) { replayed = append(replayed, entry) })
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if count != 3 || len(replayed) != 3 {
+		t.Fatalf("expected the 3 complete entries, got %d", count)
+	}
+
+	if err := j.removeBefore(j.segment); err != nil {
+		t.Fatalf("removeBefore failed: %v", err)
+	}
+	if segments, _ := listJournalSegments(dir); len(segments) != 1 || segments[0] != 4 {
+		t.Errorf("expected only segment 4 to remain, got %v", segments)
+	}
+}
@@ -1,24 +1,38 @@
 package database
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultRetentionHours applies to messages whose channel isn't cached (the schema default)
+const defaultRetentionHours = 168
+
 // MemDB is an in-memory database with periodic SQLite snapshots
 type MemDB struct {
 	mu sync.RWMutex
 
+	// snapshotMu serializes snapshots and retention purges, so a snapshot can't write
+	// back a message that a purge is deleting from SQLite
+	snapshotMu sync.Mutex
+
 	// Core data
-	channels map[int64]*Channel
-	sessions map[int64]*Session
-	messages map[int64]*Message
+	channels    map[int64]*Channel
+	subchannels map[int64]*Subchannel
+	sessions    map[int64]*Session
+	messages    map[int64]*Message
+
+	// Private channel (DM) participants: channelID -> set of userIDs
+	channelAccess map[int64]map[int64]bool
+
+	// Reactions: messageID -> reactions grouped by emoji, in order of first use
+	reactions map[int64][]*reactionGroup
 
 	// Indexes for fast lookups
 	messagesByChannel map[int64][]int64        // channelID -> sorted messageIDs (by timestamp)
@@ -27,7 +41,22 @@ type MemDB struct {
 	sessionsByUserID  map[int64]map[int64]bool // userID -> set of sessionIDs
 
 	// Dirty tracking for incremental snapshots
-	dirtyMessages map[int64]bool // Messages modified since last snapshot
+	dirtyMessages  map[int64]bool // Messages modified since last snapshot
+	dirtyReactions map[int64]bool // Messages whose reactions changed since last snapshot
+
+	// Edit history recorded since last snapshot, in order
+	pendingVersions []MessageVersion
+
+	// Write-ahead journal of message operations since the last snapshot (nil = disabled)
+	journal *journal
+
+	// Message cache bounds and accounting (see memdb_cache.go)
+	cache       CacheConfig
+	threads     map[int64]*threadState // threadRootID -> activity of cached threads
+	coldUntil   map[int64]int64        // channelID -> messages created before this (Unix ms) may only be in SQLite
+	cacheBytes  int64
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
 
 	// Underlying SQLite DB for snapshots
 	sqliteDB         *DB
@@ -36,10 +65,33 @@ type MemDB struct {
 	wg               sync.WaitGroup
 }
 
-// NewMemDB creates a new in-memory database and loads initial state from SQLite
+// MemDBOptions configures the optional parts of MemDB
+type MemDBOptions struct {
+	Journal JournalConfig // Write-ahead journal (no Dir = disabled)
+	Cache   CacheConfig   // Message cache bounds (zero = keep everything in memory)
+}
+
+// NewMemDB creates a new in-memory database and loads initial state from SQLite.
+// Without a journal, messages since the last snapshot are lost if the process dies.
 func NewMemDB(sqliteDB *DB, snapshotInterval time.Duration) (*MemDB, error) {
+	return NewMemDBWithOptions(sqliteDB, snapshotInterval, MemDBOptions{})
+}
+
+// NewMemDBWithOptions creates a new in-memory database. With a journal, message
+// operations journaled before a crash are replayed on top of the state loaded from
+// SQLite. With cache bounds, only recent threads are loaded and older history is
+// read from SQLite when it's needed.
+func NewMemDBWithOptions(sqliteDB *DB, snapshotInterval time.Duration, options MemDBOptions) (*MemDB, error) {
+	journal, err := openJournal(options.Journal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
 	m := &MemDB{
 		channels:          make(map[int64]*Channel),
+		subchannels:       make(map[int64]*Subchannel),
+		channelAccess:     make(map[int64]map[int64]bool),
+		reactions:         make(map[int64][]*reactionGroup),
 		sessions:          make(map[int64]*Session),
 		messages:          make(map[int64]*Message),
 		messagesByChannel: make(map[int64][]int64),
@@ -47,16 +99,33 @@ func NewMemDB(sqliteDB *DB, snapshotInterval time.Duration) (*MemDB, error) {
 		messagesByThread:  make(map[int64][]int64),
 		sessionsByUserID:  make(map[int64]map[int64]bool),
 		dirtyMessages:     make(map[int64]bool),
+		dirtyReactions:    make(map[int64]bool),
 		sqliteDB:          sqliteDB,
 		snapshotInterval:  snapshotInterval,
+		journal:           journal,
+		cache:             options.Cache,
+		threads:           make(map[int64]*threadState),
+		coldUntil:         make(map[int64]int64),
 		shutdown:          make(chan struct{}),
 	}
 
 	// Load initial state from SQLite
 	if err := m.loadFromSQLite(); err != nil {
+		journal.close()
 		return nil, fmt.Errorf("failed to load from SQLite: %w", err)
 	}
 
+	// Persist replayed operations right away, which also clears the old journal segments
+	if len(m.dirtyMessages) > 0 || len(m.pendingVersions) > 0 {
+		if err := m.snapshot(); err != nil {
+			journal.close()
+			return nil, fmt.Errorf("failed to snapshot replayed journal: %w", err)
+		}
+	}
+
+	// The hot window can hold more than the memory budget allows
+	m.evictColdThreads()
+
 	// Start background snapshot goroutine
 	m.wg.Add(1)
 	go m.snapshotLoop()
@@ -82,81 +151,78 @@ func (m *MemDB) loadFromSQLite() error {
 	}
 	log.Printf("MemDB: loaded %d channels in %v", len(channels), time.Since(startChannels))
 
-	// Load ALL messages in one query instead of per-channel recursive queries
-	startMessages := time.Now()
-
-	// Query all messages directly from SQLite
-	rows, err := m.sqliteDB.conn.Query(`
-		SELECT id, channel_id, subchannel_id, parent_id, thread_root_id, author_user_id,
-		       author_nickname, content, created_at, edited_at, deleted_at
-		FROM Message
-		WHERE deleted_at IS NULL
-		ORDER BY created_at ASC
-	`)
+	// Load private channels (DMs) and their participants
+	startDMs := time.Now()
+	privateChannels, err := m.sqliteDB.ListPrivateChannels()
 	if err != nil {
-		return fmt.Errorf("failed to load messages: %w", err)
+		return fmt.Errorf("failed to load private channels: %w", err)
 	}
-	defer rows.Close()
-
-	totalRootMessages := 0
-	totalReplies := 0
+	for _, ch := range privateChannels {
+		m.channels[ch.ID] = ch
+	}
+	access, err := m.sqliteDB.ListAllChannelAccess()
+	if err != nil {
+		return fmt.Errorf("failed to load channel access: %w", err)
+	}
+	for channelID, userIDs := range access {
+		participants := make(map[int64]bool, len(userIDs))
+		for _, userID := range userIDs {
+			participants[userID] = true
+		}
+		m.channelAccess[channelID] = participants
+	}
+	log.Printf("MemDB: loaded %d private channels in %v", len(privateChannels), time.Since(startDMs))
 
-	for rows.Next() {
-		var msg Message
-		var subchannelID, parentID, threadRootID, authorUserID, editedAt, deletedAt sql.NullInt64
+	// Load subchannels
+	startSubchannels := time.Now()
+	subchannels, err := m.sqliteDB.ListAllSubchannels()
+	if err != nil {
+		return fmt.Errorf("failed to load subchannels: %w", err)
+	}
+	for _, sub := range subchannels {
+		m.subchannels[sub.ID] = sub
+	}
+	log.Printf("MemDB: loaded %d subchannels in %v", len(subchannels), time.Since(startSubchannels))
 
-		err := rows.Scan(
-			&msg.ID, &msg.ChannelID, &subchannelID, &parentID, &threadRootID, &authorUserID,
-			&msg.AuthorNickname, &msg.Content, &msg.CreatedAt, &editedAt, &deletedAt,
-		)
-		if err != nil {
-			log.Printf("MemDB: failed to scan message: %v", err)
-			continue
+	// Load the messages of threads active within the hot window (all messages without
+	// one) and their reactions, in one query each instead of per-channel queries
+	startMessages := time.Now()
+	filter, args := "1 = 1", []interface{}{}
+	if m.cache.HotWindow > 0 {
+		cutoff := nowMillis() - m.cache.HotWindow.Milliseconds()
+		filter = `COALESCE(thread_root_id, id) IN (SELECT COALESCE(thread_root_id, id) FROM Message WHERE created_at >= ?)`
+		args = append(args, cutoff)
+		if err := m.markColdChannels(cutoff); err != nil {
+			return fmt.Errorf("failed to check for old messages: %w", err)
 		}
+	}
+	messages, reactions, err := m.sqliteDB.listMessagesAndReactions(filter, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load messages: %w", err)
+	}
 
-		// Convert nullable fields
-		if subchannelID.Valid {
-			msg.SubchannelID = &subchannelID.Int64
-		}
-		if parentID.Valid {
-			msg.ParentID = &parentID.Int64
-			totalReplies++
-		} else {
+	totalRootMessages := 0
+	for _, msg := range messages {
+		if msg.ParentID == nil {
 			totalRootMessages++
 		}
-		if threadRootID.Valid {
-			msg.ThreadRootID = &threadRootID.Int64
-		}
-		if authorUserID.Valid {
-			msg.AuthorUserID = &authorUserID.Int64
-		}
-		if editedAt.Valid {
-			msg.EditedAt = &editedAt.Int64
-		}
-		if deletedAt.Valid {
-			msg.DeletedAt = &deletedAt.Int64
-		}
+	}
+	m.cacheMessagesLocked(messages, reactions, 0)
 
-		// Store message
-		m.messages[msg.ID] = &msg
+	log.Printf("MemDB: loaded %d root messages, %d replies and %d reactions in %v (%d channels with older history in SQLite)",
+		totalRootMessages, len(messages)-totalRootMessages, len(reactions), time.Since(startMessages), len(m.coldUntil))
 
-		// Build indexes
-		m.messagesByChannel[msg.ChannelID] = append(m.messagesByChannel[msg.ChannelID], msg.ID)
-		if msg.ParentID != nil {
-			m.messagesByParent[*msg.ParentID] = append(m.messagesByParent[*msg.ParentID], msg.ID)
-		}
-		if msg.ThreadRootID != nil {
-			m.messagesByThread[*msg.ThreadRootID] = append(m.messagesByThread[*msg.ThreadRootID], msg.ID)
-		}
+	// Replay operations that didn't make it into a snapshot before the last shutdown
+	startReplay := time.Now()
+	replayed, err := m.replayJournal()
+	if err != nil {
+		return fmt.Errorf("failed to replay journal: %w", err)
 	}
-
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating messages: %w", err)
+	if replayed > 0 {
+		log.Printf("MemDB: replayed %d journal entries in %v", replayed, time.Since(startReplay))
 	}
 
-	log.Printf("MemDB: loaded %d root messages and %d replies in %v", totalRootMessages, totalReplies, time.Since(startMessages))
-
-	// Sort all message indexes by timestamp
+	// Sort all message indexes by timestamp (replayed messages were appended)
 	startSort := time.Now()
 	for channelID := range m.messagesByChannel {
 		m.sortMessagesByTimestamp(m.messagesByChannel[channelID])
@@ -209,11 +275,8 @@ func (m *MemDB) snapshotLoop() {
 				log.Printf("MemDB: snapshot failed: %v", err)
 			} else {
 				log.Printf("MemDB: snapshot completed successfully")
-				// Hard delete old messages after successful snapshot
-				deleted := m.hardDeleteOldMessages()
-				if deleted > 0 {
-					log.Printf("MemDB: hard deleted %d old messages from memory", deleted)
-				}
+				// Threads are only evicted once their changes are in SQLite
+				m.evictColdThreads()
 			}
 		case <-m.shutdown:
 			// Final snapshot on shutdown
@@ -221,11 +284,6 @@ func (m *MemDB) snapshotLoop() {
 				log.Printf("MemDB: final snapshot failed: %v", err)
 			} else {
 				log.Printf("MemDB: final snapshot completed")
-				// Hard delete old messages after final snapshot
-				deleted := m.hardDeleteOldMessages()
-				if deleted > 0 {
-					log.Printf("MemDB: hard deleted %d old messages from memory", deleted)
-				}
 			}
 			return
 		}
@@ -234,6 +292,9 @@ func (m *MemDB) snapshotLoop() {
 
 // snapshot writes current in-memory state to SQLite
 func (m *MemDB) snapshot() error {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
 	start := time.Now()
 
 	// Note: We don't snapshot channels (admin-managed, rarely change)
@@ -242,12 +303,21 @@ func (m *MemDB) snapshot() error {
 	// Collect dirty message IDs and sort by ID (ascending order)
 	// Since Snowflake IDs are monotonically increasing, parent.ID < child.ID always
 	// This ensures we write parents before children without recursion
-	retentionCutoff := time.Now().UnixMilli() - (7 * 24 * 3600 * 1000)
+	now := nowMillis()
 	messagesWritten := 0
 	messagesSkipped := 0
 
 	// Collect dirty IDs and message data under read lock
 	m.mu.RLock()
+
+	// Journal entries are written under the write lock, so everything in the segments
+	// before this point is part of this snapshot
+	journalSegment, err := m.journal.rotate()
+	if err != nil {
+		m.mu.RUnlock()
+		return fmt.Errorf("failed to rotate journal: %w", err)
+	}
+
 	dirtyIDs := make([]int64, 0, len(m.dirtyMessages))
 	for id := range m.dirtyMessages {
 		dirtyIDs = append(dirtyIDs, id)
@@ -258,16 +328,40 @@ func (m *MemDB) snapshot() error {
 	for _, id := range dirtyIDs {
 		msg := m.messages[id]
 
-		// Skip old deleted messages (will be hard-deleted later)
-		if msg.DeletedAt != nil && *msg.DeletedAt < retentionCutoff {
+		// Skip deleted messages past their channel's retention (will be hard-deleted later),
+		// unless replies need them as parent
+		if msg.DeletedAt != nil && *msg.DeletedAt < m.retentionCutoffLocked(msg.ChannelID, msg.SubchannelID, now) &&
+			len(m.messagesByParent[id]) == 0 {
 			messagesSkipped++
 			continue
 		}
 
 		messagesToWrite = append(messagesToWrite, msg)
 	}
+
+	// Versions are append-only, so the ones written are dropped from the front afterwards
+	versionsToWrite := append([]MessageVersion(nil), m.pendingVersions...)
+
+	// Copy the current reactions of messages whose reactions changed
+	dirtyReactionIDs := make([]int64, 0, len(m.dirtyReactions))
+	reactionsToWrite := make(map[int64][]Reaction, len(m.dirtyReactions))
+	for id := range m.dirtyReactions {
+		dirtyReactionIDs = append(dirtyReactionIDs, id)
+		var reactions []Reaction
+		for _, group := range m.reactions[id] {
+			reactions = append(reactions, group.reactions...)
+		}
+		reactionsToWrite[id] = reactions
+	}
 	m.mu.RUnlock()
 
+	// Channels deleted from SQLite behind our back (by another process) would fail the
+	// whole batch on the foreign key, so their messages are dropped instead
+	messagesToWrite, err = m.dropDeletedChannels(messagesToWrite, reactionsToWrite)
+	if err != nil {
+		return err
+	}
+
 	// Sort by ID (ascending) - O(n log n) but much faster than recursion for large n
 	sort.Slice(messagesToWrite, func(i, j int) bool {
 		return messagesToWrite[i].ID < messagesToWrite[j].ID
@@ -283,18 +377,83 @@ func (m *MemDB) snapshot() error {
 		messagesWritten = len(messagesToWrite)
 	}
 
+	// Reactions are written after messages so the messages they belong to exist
+	if len(reactionsToWrite) > 0 {
+		if err := m.sqliteDB.ReplaceReactions(reactionsToWrite); err != nil {
+			log.Printf("MemDB: snapshot failed to write reactions: %v", err)
+			return err
+		}
+	}
+
+	// Versions are written after messages too, since entries of unknown messages are skipped
+	if len(versionsToWrite) > 0 {
+		if err := m.sqliteDB.InsertMessageVersions(versionsToWrite); err != nil {
+			log.Printf("MemDB: snapshot failed to write message versions: %v", err)
+			return err
+		}
+	}
+
 	// Clear dirty flags after successful write (requires write lock)
 	m.mu.Lock()
+	m.pendingVersions = m.pendingVersions[len(versionsToWrite):]
 	for _, id := range dirtyIDs {
 		delete(m.dirtyMessages, id)
 	}
+	for _, id := range dirtyReactionIDs {
+		delete(m.dirtyReactions, id)
+	}
 	m.mu.Unlock()
 
-	log.Printf("MemDB: snapshot completed - %d messages written, %d old messages skipped (will be deleted) in %v",
-		messagesWritten, messagesSkipped, time.Since(start))
+	// The snapshot covers the old journal segments now
+	if err := m.journal.removeBefore(journalSegment); err != nil {
+		log.Printf("MemDB: failed to truncate journal: %v", err)
+	}
+
+	log.Printf("MemDB: snapshot completed - %d messages written, %d old messages skipped (will be deleted), reactions of %d messages and %d message versions written in %v",
+		messagesWritten, messagesSkipped, len(reactionsToWrite), len(versionsToWrite), time.Since(start))
 	return nil
 }
 
+// dropDeletedChannels removes channels that no longer exist in SQLite from the cache, and
+// their messages and reactions from a snapshot's writes. Returns the messages to write.
+func (m *MemDB) dropDeletedChannels(messages []*Message, reactions map[int64][]Reaction) ([]*Message, error) {
+	deleted := make(map[int64]bool)
+	for _, msg := range messages {
+		if _, checked := deleted[msg.ChannelID]; checked {
+			continue
+		}
+		exists, err := m.sqliteDB.ChannelExists(msg.ChannelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check channel %d: %w", msg.ChannelID, err)
+		}
+		deleted[msg.ChannelID] = !exists
+	}
+
+	kept := messages[:0]
+	for _, msg := range messages {
+		if !deleted[msg.ChannelID] {
+			kept = append(kept, msg)
+		}
+	}
+	if len(kept) == len(messages) {
+		return messages, nil
+	}
+
+	m.mu.Lock()
+	for channelID, gone := range deleted {
+		if !gone {
+			continue
+		}
+		removed := m.removeChannelLocked(channelID)
+		for _, msgID := range removed {
+			delete(reactions, msgID)
+		}
+		log.Printf("MemDB: channel %d was deleted from the database, dropped %d cached messages", channelID, len(removed))
+	}
+	m.mu.Unlock()
+	return kept, nil
+}
+
 // batchInsertMessages performs a batched INSERT OR REPLACE for messages
 // SQLite 3.32.0+ has a parameter limit of 32766, but optimal batch size is smaller
 // due to query building and parsing overhead (string concatenation + SQL parse)
@@ -355,75 +514,132 @@ func (m *MemDB) batchInsertMessages(messages []*Message) error {
 	return nil
 }
 
-// hardDeleteOldMessages removes messages from memory that have been soft-deleted for >7 days
-// Must be called after snapshot() to ensure deleted messages are persisted first
-func (m *MemDB) hardDeleteOldMessages() int {
+// hardDeleteOldMessages removes messages past their channel's retention window from memory:
+// threads whose root is older than the retention (replies go with their root, like the
+// SQLite cleanup) and messages soft-deleted longer than the retention ago that have no
+// replies left. Returns the removed messages in ID order; they still have to be deleted
+// from SQLite, which CleanupExpiredMessages does.
+func (m *MemDB) hardDeleteOldMessages() []*Message {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	retentionCutoff := time.Now().UnixMilli() - (7 * 24 * 3600 * 1000)
-	deletedCount := 0
+	now := nowMillis()
+	expired := make(map[int64]bool)
 
-	// Collect message IDs to delete (can't modify map while iterating)
-	toDelete := make([]int64, 0)
+	// Expired threads, including all of their replies
+	var softDeleted []int64
 	for msgID, msg := range m.messages {
-		if msg.DeletedAt != nil && *msg.DeletedAt < retentionCutoff {
-			toDelete = append(toDelete, msgID)
+		if msg.DeletedAt != nil {
+			softDeleted = append(softDeleted, msgID)
+		}
+		if msg.ParentID != nil || msg.CreatedAt >= m.retentionCutoffLocked(msg.ChannelID, msg.SubchannelID, now) {
+			continue
+		}
+		expired[msgID] = true
+		for _, replyID := range m.messagesByThread[msgID] {
+			expired[replyID] = true
 		}
 	}
 
-	// Delete messages and clean up indices
-	for _, msgID := range toDelete {
+	// Old soft-deleted messages, unless they still hold up live replies. Going from the
+	// highest ID down visits replies before their parents (Snowflake IDs increase), so a
+	// chain of deleted messages is removed in one pass.
+	sort.Slice(softDeleted, func(i, j int) bool { return softDeleted[i] > softDeleted[j] })
+	for _, msgID := range softDeleted {
 		msg := m.messages[msgID]
-		if msg == nil {
+		if expired[msgID] || *msg.DeletedAt >= m.retentionCutoffLocked(msg.ChannelID, msg.SubchannelID, now) {
 			continue
 		}
-
-		// Remove from main map
-		delete(m.messages, msgID)
-
-		// Remove from channel index
-		channelMsgs := m.messagesByChannel[msg.ChannelID]
-		for i, id := range channelMsgs {
-			if id == msgID {
-				m.messagesByChannel[msg.ChannelID] = append(channelMsgs[:i], channelMsgs[i+1:]...)
+		hasReplies := false
+		for _, replyID := range m.messagesByParent[msgID] {
+			if !expired[replyID] {
+				hasReplies = true
 				break
 			}
 		}
+		if !hasReplies {
+			expired[msgID] = true
+		}
+	}
 
-		// Remove from parent index (if reply)
-		if msg.ParentID != nil {
-			parentReplies := m.messagesByParent[*msg.ParentID]
-			for i, id := range parentReplies {
-				if id == msgID {
-					m.messagesByParent[*msg.ParentID] = append(parentReplies[:i], parentReplies[i+1:]...)
-					break
-				}
-			}
+	removed := make([]*Message, 0, len(expired))
+	for msgID := range expired {
+		if msg := m.messages[msgID]; msg != nil {
+			removed = append(removed, msg)
 		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].ID < removed[j].ID })
 
-		// Remove from thread index
-		if msg.ThreadRootID != nil {
-			threadMsgs := m.messagesByThread[*msg.ThreadRootID]
-			for i, id := range threadMsgs {
-				if id == msgID {
-					m.messagesByThread[*msg.ThreadRootID] = append(threadMsgs[:i], threadMsgs[i+1:]...)
-					break
-				}
-			}
+	for _, msg := range removed {
+		m.removeMessageLocked(msg)
+	}
+
+	return removed
+}
+
+// retentionCutoffLocked returns the time (Unix ms) before which messages in a channel or
+// subchannel have expired. A subchannel's retention overrides its channel's. Caller must hold m.mu.
+func (m *MemDB) retentionCutoffLocked(channelID int64, subchannelID *int64, now int64) int64 {
+	hours := uint32(defaultRetentionHours)
+	if ch, exists := m.channels[channelID]; exists {
+		hours = ch.MessageRetentionHours
+	}
+	if subchannelID != nil {
+		if sub, exists := m.subchannels[*subchannelID]; exists {
+			hours = sub.MessageRetentionHours
+		}
+	}
+	return now - int64(hours)*3600*1000
+}
+
+// removeMessageLocked removes a message from memory and all indexes (assumes lock held).
+// Pending snapshot writes are dropped too, so the message isn't written back to SQLite.
+func (m *MemDB) removeMessageLocked(msg *Message) {
+	msgID := msg.ID
+
+	m.untrackMessageLocked(msg)
+	delete(m.messages, msgID)
+	delete(m.dirtyMessages, msgID)
+	delete(m.reactions, msgID)
+	delete(m.dirtyReactions, msgID)
+	delete(m.messagesByParent, msgID)
+	if msg.ThreadRootID != nil && *msg.ThreadRootID == msgID {
+		delete(m.messagesByThread, msgID)
+	}
+
+	// Remove from channel index
+	m.messagesByChannel[msg.ChannelID] = removeID(m.messagesByChannel[msg.ChannelID], msgID)
+
+	// Remove from parent index (if reply)
+	if msg.ParentID != nil {
+		if replies, exists := m.messagesByParent[*msg.ParentID]; exists {
+			m.messagesByParent[*msg.ParentID] = removeID(replies, msgID)
 		}
+	}
 
-		deletedCount++
+	// Remove from thread index
+	if msg.ThreadRootID != nil {
+		if threadMsgs, exists := m.messagesByThread[*msg.ThreadRootID]; exists {
+			m.messagesByThread[*msg.ThreadRootID] = removeID(threadMsgs, msgID)
+		}
 	}
+}
 
-	return deletedCount
+// removeID removes the first occurrence of id from ids, in place
+func removeID(ids []int64, id int64) []int64 {
+	for i, existing := range ids {
+		if existing == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
 }
 
-// Close shuts down the background snapshot goroutine
+// Close shuts down the background snapshot goroutine and closes the journal
 func (m *MemDB) Close() error {
 	close(m.shutdown)
 	m.wg.Wait()
-	return nil
+	return m.journal.close()
 }
 
 // Snowflake returns the snowflake ID generator
@@ -545,13 +761,16 @@ func (m *MemDB) GetActiveSessions(withinSeconds int64) ([]Session, error) {
 
 // === Channel Operations ===
 
-// ListChannels returns all channels
+// ListChannels returns all public channels (private DM channels are excluded)
 func (m *MemDB) ListChannels() ([]*Channel, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	channels := make([]*Channel, 0, len(m.channels))
 	for _, ch := range m.channels {
+		if ch.IsPrivate {
+			continue
+		}
 		// Return copies to prevent external mutation
 		chCopy := *ch
 		channels = append(channels, &chCopy)
@@ -565,11 +784,18 @@ func (m *MemDB) ListChannels() ([]*Channel, error) {
 	return channels, nil
 }
 
-// CountChannels returns the number of channels
+// CountChannels returns the number of public channels
 func (m *MemDB) CountChannels() uint32 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return uint32(len(m.channels))
+
+	count := uint32(0)
+	for _, ch := range m.channels {
+		if !ch.IsPrivate {
+			count++
+		}
+	}
+	return count
 }
 
 // GetChannel retrieves a channel by ID
@@ -595,135 +821,405 @@ func (m *MemDB) ChannelExists(channelID int64) (bool, error) {
 	return exists, nil
 }
 
-// === Message Operations ===
-
-// PostMessage creates a new message in memory and returns both ID and the message
-func (m *MemDB) PostMessage(channelID int64, subchannelID, parentID, authorUserID *int64, authorNickname, content string) (int64, *Message, error) {
-	messageID := m.sqliteDB.snowflake.NextID()
-	now := nowMillis()
+// === Subchannel Operations ===
 
-	// Determine thread_root_id
-	var threadRootID *int64
-	if parentID != nil {
-		// This is a reply - inherit parent's thread_root_id
-		m.mu.RLock()
-		parent, exists := m.messages[*parentID]
-		m.mu.RUnlock()
+// ListSubchannels returns all subchannels of a channel
+func (m *MemDB) ListSubchannels(channelID int64) ([]*Subchannel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-		if !exists {
-			return 0, nil, fmt.Errorf("parent message not found")
+	subchannels := make([]*Subchannel, 0)
+	for _, sub := range m.subchannels {
+		if sub.ChannelID != channelID {
+			continue
 		}
-
-		threadRootID = parent.ThreadRootID
-	} else {
-		// Top-level message - it's its own thread root
-		threadRootID = &messageID
-	}
-
-	message := &Message{
-		ID:             messageID,
-		ChannelID:      channelID,
-		SubchannelID:   subchannelID,
-		ParentID:       parentID,
-		ThreadRootID:   threadRootID,
-		AuthorUserID:   authorUserID,
-		AuthorNickname: authorNickname,
-		Content:        content,
-		CreatedAt:      now,
-		EditedAt:       nil,
-		DeletedAt:      nil,
+		// Return copies to prevent external mutation
+		subCopy := *sub
+		subchannels = append(subchannels, &subCopy)
 	}
 
-	m.mu.Lock()
-	m.messages[messageID] = message
-	m.dirtyMessages[messageID] = true // Mark as dirty for next snapshot
-
-	// Update indexes
-	m.messagesByChannel[channelID] = append(m.messagesByChannel[channelID], messageID)
-	if parentID != nil {
-		m.messagesByParent[*parentID] = append(m.messagesByParent[*parentID], messageID)
-		// Increment parent's reply count (atomic)
-		if parent := m.messages[*parentID]; parent != nil {
-			parent.ReplyCount.Add(1)
-		}
-	}
-	if threadRootID != nil {
-		m.messagesByThread[*threadRootID] = append(m.messagesByThread[*threadRootID], messageID)
-	}
-	m.mu.Unlock()
+	// Sort subchannels alphabetically by name
+	sort.Slice(subchannels, func(i, j int) bool {
+		return subchannels[i].Name < subchannels[j].Name
+	})
 
-	return messageID, message, nil
+	return subchannels, nil
 }
 
-// GetMessage retrieves a single message by ID
-func (m *MemDB) GetMessage(messageID int64) (*Message, error) {
+// GetSubchannel retrieves a subchannel by ID
+func (m *MemDB) GetSubchannel(subchannelID int64) (*Subchannel, error) {
 	m.mu.RLock()
-	message, exists := m.messages[messageID]
+	sub, exists := m.subchannels[subchannelID]
 	m.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("message not found")
+		return nil, fmt.Errorf("subchannel not found")
 	}
 
-	messageCopy := *message
-	return &messageCopy, nil
+	subCopy := *sub
+	return &subCopy, nil
 }
 
-// GetRootMessages retrieves top-level messages in a channel (no parent)
-func (m *MemDB) GetRootMessages(channelID int64, fromMessageID int64, limit int) ([]Message, error) {
+// SubchannelExists checks if a subchannel exists
+func (m *MemDB) SubchannelExists(subchannelID int64) (bool, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	allMessageIDs, exists := m.messagesByChannel[channelID]
-	if !exists {
-		return []Message{}, nil
-	}
+	_, exists := m.subchannels[subchannelID]
+	m.mu.RUnlock()
 
-	messages := make([]Message, 0, limit)
-	for _, msgID := range allMessageIDs {
-		if fromMessageID > 0 && msgID <= fromMessageID {
-			continue
-		}
+	return exists, nil
+}
 
-		msg := m.messages[msgID]
-		if msg == nil || msg.DeletedAt != nil || msg.ParentID != nil {
-			continue // Skip deleted or replies
-		}
+// CreateSubchannel creates a new subchannel (wrapper for sqliteDB.CreateSubchannel)
+func (m *MemDB) CreateSubchannel(channelID int64, name, displayName string, description *string, subchannelType uint8, retentionHours uint32, createdBy *int64) (int64, error) {
+	// Write to SQLite and get the new ID
+	subchannelID, err := m.sqliteDB.CreateSubchannel(channelID, name, displayName, description, subchannelType, retentionHours, createdBy)
+	if err != nil {
+		return 0, err
+	}
 
-		messages = append(messages, *msg)
-		if len(messages) >= limit {
-			break
-		}
+	sub := &Subchannel{
+		ID:                    subchannelID,
+		ChannelID:             channelID,
+		Name:                  name,
+		DisplayName:           displayName,
+		Description:           description,
+		SubchannelType:        subchannelType,
+		MessageRetentionHours: retentionHours,
+		CreatedBy:             createdBy,
+		CreatedAt:             time.Now().UnixMilli(),
 	}
 
-	return messages, nil
+	m.mu.Lock()
+	m.subchannels[subchannelID] = sub
+	m.mu.Unlock()
+
+	log.Printf("MemDB: added new subchannel to cache: id=%d, channel=%d, name=%s", subchannelID, channelID, name)
+	return subchannelID, nil
 }
 
-// GetReplies retrieves all direct replies to a message
-func (m *MemDB) GetReplies(parentID int64) ([]Message, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// === Direct Message Operations ===
 
-	replyIDs, exists := m.messagesByParent[parentID]
-	if !exists {
-		return []Message{}, nil
+// CreateDMChannel creates a private DM channel with the given participants (wrapper for sqliteDB.CreateDMChannel)
+func (m *MemDB) CreateDMChannel(createdBy int64, participantIDs []int64, encrypted bool) (*Channel, error) {
+	channelID, err := m.sqliteDB.CreateDMChannel(createdBy, participantIDs, encrypted)
+	if err != nil {
+		return nil, err
 	}
 
-	messages := make([]Message, 0, len(replyIDs))
-	for _, msgID := range replyIDs {
-		msg := m.messages[msgID]
-		if msg != nil && msg.DeletedAt == nil {
-			messages = append(messages, *msg)
-		}
+	// Reload from SQLite so the cached row matches the generated name exactly
+	ch, err := m.sqliteDB.GetChannel(channelID)
+	if err != nil {
+		return nil, err
 	}
 
-	return messages, nil
-}
+	participants := make(map[int64]bool, len(participantIDs))
+	for _, userID := range participantIDs {
+		participants[userID] = true
+	}
 
-// GetThreadMessages retrieves all messages in a thread
-func (m *MemDB) GetThreadMessages(threadRootID int64) ([]Message, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	m.channels[channelID] = ch
+	m.channelAccess[channelID] = participants
+	m.mu.Unlock()
+
+	log.Printf("MemDB: added new DM channel to cache: id=%d, participants=%d", channelID, len(participantIDs))
+
+	chCopy := *ch
+	return &chCopy, nil
+}
+
+// AddChannelParticipant grants a user access to a private channel
+func (m *MemDB) AddChannelParticipant(channelID, userID int64) error {
+	if err := m.sqliteDB.AddChannelAccess(channelID, userID); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.channelAccess[channelID] == nil {
+		m.channelAccess[channelID] = make(map[int64]bool)
+	}
+	m.channelAccess[channelID][userID] = true
+	m.mu.Unlock()
+
+	return nil
+}
+
+// IsChannelParticipant reports whether a user has access to a private channel
+func (m *MemDB) IsChannelParticipant(channelID, userID int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.channelAccess[channelID][userID]
+}
+
+// ListChannelParticipants returns the user IDs with access to a private channel, sorted ascending
+func (m *MemDB) ListChannelParticipants(channelID int64) []int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	userIDs := make([]int64, 0, len(m.channelAccess[channelID]))
+	for userID := range m.channelAccess[channelID] {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	return userIDs
+}
+
+// ListUserDMChannels returns the private channels a user participates in, sorted by ID
+func (m *MemDB) ListUserDMChannels(userID int64) []*Channel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	channels := make([]*Channel, 0)
+	for channelID, participants := range m.channelAccess {
+		if !participants[userID] {
+			continue
+		}
+		ch, exists := m.channels[channelID]
+		if !exists {
+			continue
+		}
+		chCopy := *ch
+		channels = append(channels, &chCopy)
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].ID < channels[j].ID
+	})
+	return channels
+}
+
+// FindDMChannel returns the existing DM channel whose participants are exactly participantIDs
+func (m *MemDB) FindDMChannel(participantIDs []int64) (*Channel, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for channelID, participants := range m.channelAccess {
+		if len(participants) != len(participantIDs) {
+			continue
+		}
+		match := true
+		for _, userID := range participantIDs {
+			if !participants[userID] {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		if ch, exists := m.channels[channelID]; exists {
+			chCopy := *ch
+			return &chCopy, true
+		}
+	}
+	return nil, false
+}
+
+// SetChannelEncrypted changes whether a DM channel is end-to-end encrypted
+func (m *MemDB) SetChannelEncrypted(channelID int64, encrypted bool) error {
+	if err := m.sqliteDB.SetChannelEncrypted(channelID, encrypted); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if ch, exists := m.channels[channelID]; exists {
+		ch.IsEncrypted = encrypted
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemDB) GetAllowUnencryptedDMs(userID int64) (bool, error) {
+	return m.sqliteDB.GetAllowUnencryptedDMs(userID)
+}
+
+func (m *MemDB) SetAllowUnencryptedDMs(userID int64, allow bool) error {
+	return m.sqliteDB.SetAllowUnencryptedDMs(userID, allow)
+}
+
+func (m *MemDB) AddEncryptionKey(userID int64, keyType uint8, publicKey string, label *string) (int64, error) {
+	return m.sqliteDB.AddEncryptionKey(userID, keyType, publicKey, label)
+}
+
+func (m *MemDB) ListEncryptionKeys(userID int64) ([]EncryptionKey, error) {
+	return m.sqliteDB.ListEncryptionKeys(userID)
+}
+
+func (m *MemDB) SetChannelKey(channelID, keyID int64, wrappedKey string) error {
+	return m.sqliteDB.SetChannelKey(channelID, keyID, wrappedKey)
+}
+
+func (m *MemDB) ListChannelKeys(channelID int64) (map[int64]string, error) {
+	return m.sqliteDB.ListChannelKeys(channelID)
+}
+
+// === Message Operations ===
+
+// PostMessage creates a new message in memory and returns both ID and the message
+func (m *MemDB) PostMessage(channelID int64, subchannelID, parentID, authorUserID *int64, authorNickname, content string) (int64, *Message, error) {
+	messageID := m.sqliteDB.snowflake.NextID()
+	now := nowMillis()
+
+	// Replies go into the parent's thread, which has to be cached
+	if parentID != nil {
+		if _, err := m.loadThread(*parentID); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	m.mu.Lock()
+
+	// Determine thread_root_id
+	var threadRootID *int64
+	if parentID != nil {
+		// This is a reply - inherit parent's thread_root_id
+		parent, exists := m.messages[*parentID]
+		if !exists {
+			m.mu.Unlock()
+			return 0, nil, fmt.Errorf("parent message not found")
+		}
+
+		threadRootID = parent.ThreadRootID
+	} else {
+		// Top-level message - it's its own thread root
+		threadRootID = &messageID
+	}
+
+	message := &Message{
+		ID:             messageID,
+		ChannelID:      channelID,
+		SubchannelID:   subchannelID,
+		ParentID:       parentID,
+		ThreadRootID:   threadRootID,
+		AuthorUserID:   authorUserID,
+		AuthorNickname: authorNickname,
+		Content:        content,
+		CreatedAt:      now,
+		EditedAt:       nil,
+		DeletedAt:      nil,
+	}
+
+	seq, err := m.journal.append(journalEntry{
+		Op:             journalOpCreate,
+		MessageID:      messageID,
+		ChannelID:      channelID,
+		SubchannelID:   subchannelID,
+		ParentID:       parentID,
+		ThreadRootID:   threadRootID,
+		AuthorUserID:   authorUserID,
+		AuthorNickname: authorNickname,
+		Content:        content,
+		Timestamp:      now,
+	})
+	if err != nil {
+		m.mu.Unlock()
+		return 0, nil, err
+	}
+	m.messages[messageID] = message
+	m.trackMessageLocked(message, now)
+	m.dirtyMessages[messageID] = true // Mark as dirty for next snapshot
+
+	// Update indexes
+	m.messagesByChannel[channelID] = append(m.messagesByChannel[channelID], messageID)
+	if parentID != nil {
+		m.messagesByParent[*parentID] = append(m.messagesByParent[*parentID], messageID)
+		// Increment parent's reply count (atomic)
+		if parent := m.messages[*parentID]; parent != nil {
+			parent.ReplyCount.Add(1)
+		}
+	}
+	if threadRootID != nil {
+		m.messagesByThread[*threadRootID] = append(m.messagesByThread[*threadRootID], messageID)
+	}
+	m.mu.Unlock()
+
+	// The caller acknowledges the message, so it has to be on disk first
+	m.waitForJournal(seq)
+
+	return messageID, message, nil
+}
+
+// GetMessage retrieves a single message by ID, caching its thread if it isn't
+func (m *MemDB) GetMessage(messageID int64) (*Message, error) {
+	if _, err := m.loadThread(messageID); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	message, exists := m.messages[messageID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("message not found")
+	}
+
+	messageCopy := *message
+	return &messageCopy, nil
+}
+
+// GetRootMessages retrieves top-level messages in a channel (no parent)
+func (m *MemDB) GetRootMessages(channelID int64, fromMessageID int64, limit int) ([]Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	allMessageIDs, exists := m.messagesByChannel[channelID]
+	if !exists {
+		return []Message{}, nil
+	}
+
+	messages := make([]Message, 0, limit)
+	for _, msgID := range allMessageIDs {
+		if fromMessageID > 0 && msgID <= fromMessageID {
+			continue
+		}
+
+		msg := m.messages[msgID]
+		if msg == nil || msg.DeletedAt != nil || msg.ParentID != nil {
+			continue // Skip deleted or replies
+		}
+
+		messages = append(messages, *msg)
+		if len(messages) >= limit {
+			break
+		}
+	}
+
+	return messages, nil
+}
+
+// GetReplies retrieves all direct replies to a message
+func (m *MemDB) GetReplies(parentID int64) ([]Message, error) {
+	if _, err := m.loadThread(parentID); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	replyIDs, exists := m.messagesByParent[parentID]
+	if !exists {
+		return []Message{}, nil
+	}
+
+	messages := make([]Message, 0, len(replyIDs))
+	for _, msgID := range replyIDs {
+		msg := m.messages[msgID]
+		if msg != nil && msg.DeletedAt == nil {
+			messages = append(messages, *msg)
+		}
+	}
+
+	return messages, nil
+}
+
+// GetThreadMessages retrieves all messages in a thread
+func (m *MemDB) GetThreadMessages(threadRootID int64) ([]Message, error) {
+	if _, err := m.loadThread(threadRootID); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
 	messageIDs, exists := m.messagesByThread[threadRootID]
 	if !exists {
@@ -747,26 +1243,115 @@ func (m *MemDB) MessageExists(messageID int64) (bool, error) {
 	msg, exists := m.messages[messageID]
 	m.mu.RUnlock()
 
-	return exists && msg.DeletedAt == nil, nil
+	if !exists {
+		return m.sqliteDB.MessageExists(messageID)
+	}
+	return msg.DeletedAt == nil, nil
 }
 
 // ListRootMessages retrieves top-level messages (compatible with SQLite DB interface)
+// Sorting: newest first by default or with beforeID, oldest first with afterID (beforeID
+// takes precedence). Pages reaching past what's cached are merged with older history
+// from SQLite.
 func (m *MemDB) ListRootMessages(channelID int64, subchannelID *int64, limit uint16, beforeID *uint64, afterID *uint64) ([]*Message, error) {
+	if limit == 0 {
+		return []*Message{}, nil
+	}
+	ascending := beforeID == nil && afterID != nil
+
+	m.mu.RLock()
+	messages := m.cachedRootMessagesLocked(channelID, subchannelID, limit, beforeID, afterID)
+	complete := true
+	if coldUntil, cold := m.coldUntil[channelID]; cold {
+		if ascending {
+			// Everything after a cached message from the hot part of the channel is cached
+			cursor := m.messages[int64(*afterID)]
+			complete = cursor != nil && cursor.CreatedAt >= coldUntil
+		} else {
+			complete = len(messages) == int(limit) && messages[len(messages)-1].CreatedAt >= coldUntil
+		}
+	}
+	m.mu.RUnlock()
+
+	if complete {
+		m.cacheHits.Add(1)
+		return messages, nil
+	}
+	m.cacheMisses.Add(1)
+
+	// Page through SQLite with the same cursor. Deleted messages don't count towards the
+	// limit, so keep going while they were filtered out.
+	var stored []*Message
+	live := 0
+	pageBefore, pageAfter := beforeID, afterID
+	for {
+		rows, err := m.sqliteDB.ListRootMessages(channelID, subchannelID, limit, pageBefore, pageAfter)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, rows...)
+		for _, msg := range rows {
+			if msg.DeletedAt == nil {
+				live++
+			}
+		}
+		if len(rows) < int(limit) || live >= int(limit) {
+			break
+		}
+		cursor := uint64(rows[len(rows)-1].ID)
+		if ascending {
+			pageAfter = &cursor
+		} else {
+			pageBefore = &cursor
+		}
+	}
+
+	// Merge with what's cached now; cached messages may have changes SQLite doesn't have yet
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	allMessageIDs, exists := m.messagesByChannel[channelID]
-	if !exists {
-		return []*Message{}, nil
+	messages = m.cachedRootMessagesLocked(channelID, subchannelID, limit, beforeID, afterID)
+	for _, msg := range stored {
+		if _, cached := m.messages[msg.ID]; cached || msg.DeletedAt != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if ascending {
+			return messages[i].CreatedAt < messages[j].CreatedAt
+		}
+		return messages[i].CreatedAt > messages[j].CreatedAt
+	})
+	if len(messages) > int(limit) {
+		messages = messages[:limit]
 	}
 
+	return messages, nil
+}
+
+// cachedRootMessagesLocked returns up to limit cached top-level messages, in the order
+// of ListRootMessages (assumes lock held)
+func (m *MemDB) cachedRootMessagesLocked(channelID int64, subchannelID *int64, limit uint16, beforeID *uint64, afterID *uint64) []*Message {
+	allMessageIDs := m.messagesByChannel[channelID]
+	ascending := beforeID == nil && afterID != nil
+
 	messages := make([]*Message, 0, limit)
-	for _, msgID := range allMessageIDs {
+	for i := range allMessageIDs {
+		if len(messages) >= int(limit) {
+			break
+		}
+
+		msgID := allMessageIDs[len(allMessageIDs)-1-i]
+		if ascending {
+			msgID = allMessageIDs[i]
+		}
+
 		// beforeID takes precedence over afterID
 		if beforeID != nil && uint64(msgID) >= *beforeID {
 			continue
 		}
-		if afterID != nil && beforeID == nil && uint64(msgID) <= *afterID {
+		if ascending && uint64(msgID) <= *afterID {
 			continue
 		}
 
@@ -775,71 +1360,332 @@ func (m *MemDB) ListRootMessages(channelID int64, subchannelID *int64, limit uin
 			continue // Skip deleted or replies
 		}
 
-		// Filter by subchannel if specified
-		if subchannelID != nil {
-			if msg.SubchannelID == nil || *msg.SubchannelID != *subchannelID {
-				continue
-			}
+		// Filter by subchannel (nil = channel root only)
+		if subchannelID == nil && msg.SubchannelID != nil {
+			continue
+		}
+		if subchannelID != nil && (msg.SubchannelID == nil || *msg.SubchannelID != *subchannelID) {
+			continue
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+// ListThreadReplies retrieves all replies to a message recursively (compatible with SQLite DB interface)
+// Supports pagination via limit, beforeID, and afterID parameters
+func (m *MemDB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64, afterID *uint64) ([]*Message, error) {
+	if _, err := m.loadThread(int64(parentID)); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Recursively collect all descendant messages in depth-first order
+	var messages []*Message
+	m.collectThreadReplies(int64(parentID), &messages, beforeID, afterID, limit)
+
+	return messages, nil
+}
+
+// collectThreadReplies recursively collects all descendants in depth-first order (assumes lock held)
+func (m *MemDB) collectThreadReplies(parentID int64, messages *[]*Message, beforeID *uint64, afterID *uint64, limit uint16) {
+	// Stop if we've reached the limit
+	if limit > 0 && len(*messages) >= int(limit) {
+		return
+	}
+
+	replyIDs, exists := m.messagesByParent[parentID]
+	if !exists {
+		return
+	}
+
+	// Process direct children in order
+	for _, msgID := range replyIDs {
+		// Stop if we've reached the limit
+		if limit > 0 && len(*messages) >= int(limit) {
+			return
+		}
+
+		// Filter by beforeID if specified
+		if beforeID != nil && uint64(msgID) >= *beforeID {
+			continue
+		}
+		// Filter by afterID if specified
+		if afterID != nil && uint64(msgID) <= *afterID {
+			continue
+		}
+
+		msg := m.messages[msgID]
+		if msg != nil && msg.DeletedAt == nil {
+			*messages = append(*messages, msg)
+
+			// Recursively collect this message's children
+			m.collectThreadReplies(msgID, messages, beforeID, afterID, limit)
+		}
+	}
+}
+
+// SearchMessages returns non-deleted messages matching a search query, newest first.
+// Snapshotted messages come from the SQLite FTS index; messages changed since the
+// last snapshot aren't indexed yet and are matched in memory instead.
+func (m *MemDB) SearchMessages(query string, filter MessageSearchFilter, limit int) ([]*Message, error) {
+	terms := parseSearchQuery(query)
+	if len(terms) == 0 {
+		return []*Message{}, nil
+	}
+
+	// Hold the read lock across the SQLite query so a snapshot can't clear
+	// dirty flags between the query and the in-memory merge
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Dirty hits are dropped below, so over-fetch by the number of dirty messages
+	indexed, err := m.sqliteDB.SearchMessages(ftsQuery(terms), filter, limit+len(m.dirtyMessages))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Message, 0, limit)
+	for _, hit := range indexed {
+		if m.dirtyMessages[hit.ID] {
+			continue
+		}
+		// Prefer the cached message (current reply count); fall back to the row
+		msg := hit
+		if cached, exists := m.messages[hit.ID]; exists {
+			msg = cached
+		}
+		if m.matchesSearchFilter(msg, filter) {
+			results = append(results, msg)
+		}
+	}
+
+	for id := range m.dirtyMessages {
+		msg := m.messages[id]
+		if msg != nil && matchesSearch(msg.Content, terms) && m.matchesSearchFilter(msg, filter) {
+			results = append(results, msg)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt > results[j].CreatedAt
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// matchesSearchFilter reports whether a message is visible to a search (assumes lock held)
+func (m *MemDB) matchesSearchFilter(msg *Message, filter MessageSearchFilter) bool {
+	if msg.DeletedAt != nil {
+		return false
+	}
+	ch, exists := m.channels[msg.ChannelID]
+	if !exists || ch.IsEncrypted {
+		return false
+	}
+	if ch.IsPrivate && (filter.ViewerUserID == nil || !m.channelAccess[ch.ID][*filter.ViewerUserID]) {
+		return false
+	}
+	if filter.ChannelID != nil && msg.ChannelID != *filter.ChannelID {
+		return false
+	}
+	if filter.AuthorUserID != nil && (msg.AuthorUserID == nil || *msg.AuthorUserID != *filter.AuthorUserID) {
+		return false
+	}
+	if filter.AuthorNickname != nil && (msg.AuthorUserID != nil || msg.AuthorNickname != *filter.AuthorNickname) {
+		return false
+	}
+	if filter.After != nil && msg.CreatedAt < *filter.After {
+		return false
+	}
+	if filter.Before != nil && msg.CreatedAt >= *filter.Before {
+		return false
+	}
+	if filter.ThreadRootID != nil && msg.ID != *filter.ThreadRootID &&
+		(msg.ThreadRootID == nil || *msg.ThreadRootID != *filter.ThreadRootID) {
+		return false
+	}
+	return true
+}
+
+// === Reaction Operations ===
+
+// maxReactionEmojis limits how many different emoji a single message can be reacted with
+const maxReactionEmojis = 20
+
+// reactionGroup is the in-memory aggregate of a message's reactions with one emoji
+type reactionGroup struct {
+	emoji     string
+	reactions []Reaction // In the order they were added
+}
+
+// AddReaction adds a user's reaction to a message and returns the message's reactions.
+// changed is false if the user had already reacted with this emoji.
+func (m *MemDB) AddReaction(messageID, userID int64, emoji string) (summaries []ReactionSummary, changed bool, err error) {
+	if _, err := m.loadThread(messageID); err != nil {
+		return nil, false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, exists := m.messages[messageID]
+	if !exists || msg.DeletedAt != nil {
+		return nil, false, ErrMessageNotFound
+	}
+
+	group := m.reactionGroup(messageID, emoji)
+	if group == nil {
+		if len(m.reactions[messageID]) >= maxReactionEmojis {
+			return nil, false, ErrTooManyReactions
 		}
-
-		messages = append(messages, msg)
-		if len(messages) >= int(limit) {
-			break
+		group = &reactionGroup{emoji: emoji}
+		m.reactions[messageID] = append(m.reactions[messageID], group)
+	}
+	for _, r := range group.reactions {
+		if r.UserID == userID {
+			return m.reactionSummaries(messageID), false, nil
 		}
 	}
 
-	return messages, nil
+	group.reactions = append(group.reactions, Reaction{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		CreatedAt: nowMillis(),
+	})
+	m.dirtyReactions[messageID] = true // Mark as dirty for next snapshot
+
+	return m.reactionSummaries(messageID), true, nil
 }
 
-// ListThreadReplies retrieves all replies to a message recursively (compatible with SQLite DB interface)
-// Supports pagination via limit, beforeID, and afterID parameters
-func (m *MemDB) ListThreadReplies(parentID uint64, limit uint16, beforeID *uint64, afterID *uint64) ([]*Message, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// RemoveReaction removes a user's reaction from a message and returns the message's reactions.
+// changed is false if the user hadn't reacted with this emoji.
+func (m *MemDB) RemoveReaction(messageID, userID int64, emoji string) (summaries []ReactionSummary, changed bool, err error) {
+	if _, err := m.loadThread(messageID); err != nil {
+		return nil, false, err
+	}
 
-	// Recursively collect all descendant messages in depth-first order
-	var messages []*Message
-	m.collectThreadReplies(int64(parentID), &messages, beforeID, afterID, limit)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	return messages, nil
-}
+	msg, exists := m.messages[messageID]
+	if !exists || msg.DeletedAt != nil {
+		return nil, false, ErrMessageNotFound
+	}
 
-// collectThreadReplies recursively collects all descendants in depth-first order (assumes lock held)
-func (m *MemDB) collectThreadReplies(parentID int64, messages *[]*Message, beforeID *uint64, afterID *uint64, limit uint16) {
-	// Stop if we've reached the limit
-	if limit > 0 && len(*messages) >= int(limit) {
-		return
+	group := m.reactionGroup(messageID, emoji)
+	if group != nil {
+		for i, r := range group.reactions {
+			if r.UserID == userID {
+				group.reactions = append(group.reactions[:i], group.reactions[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+	if changed {
+		m.pruneReactionGroups(messageID)
+		m.dirtyReactions[messageID] = true // Mark as dirty for next snapshot
 	}
 
-	replyIDs, exists := m.messagesByParent[parentID]
-	if !exists {
-		return
+	return m.reactionSummaries(messageID), changed, nil
+}
+
+// GetReactions returns the reactions to a message, grouped by emoji in order of first use.
+// Reactions to messages that aren't cached are read from SQLite.
+func (m *MemDB) GetReactions(messageID int64) []ReactionSummary {
+	m.mu.RLock()
+	_, cached := m.messages[messageID]
+	summaries := m.reactionSummaries(messageID)
+	m.mu.RUnlock()
+
+	if cached {
+		return summaries
 	}
 
-	// Process direct children in order
-	for _, msgID := range replyIDs {
-		// Stop if we've reached the limit
-		if limit > 0 && len(*messages) >= int(limit) {
-			return
+	reactions, err := m.sqliteDB.listReactions(messageID)
+	if err != nil {
+		log.Printf("MemDB: failed to load reactions of message %d: %v", messageID, err)
+		return nil
+	}
+	for _, r := range reactions {
+		found := false
+		for i := range summaries {
+			if summaries[i].Emoji == r.Emoji {
+				summaries[i].UserIDs = append(summaries[i].UserIDs, r.UserID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			summaries = append(summaries, ReactionSummary{Emoji: r.Emoji, UserIDs: []int64{r.UserID}})
 		}
+	}
+	return summaries
+}
 
-		// Filter by beforeID if specified
-		if beforeID != nil && uint64(msgID) >= *beforeID {
-			continue
+// reactionGroup returns the group of a message's reactions with an emoji (caller must hold lock)
+func (m *MemDB) reactionGroup(messageID int64, emoji string) *reactionGroup {
+	for _, group := range m.reactions[messageID] {
+		if group.emoji == emoji {
+			return group
 		}
-		// Filter by afterID if specified
-		if afterID != nil && uint64(msgID) <= *afterID {
-			continue
+	}
+	return nil
+}
+
+// reactionSummaries copies a message's reaction aggregates (caller must hold lock)
+func (m *MemDB) reactionSummaries(messageID int64) []ReactionSummary {
+	groups := m.reactions[messageID]
+	if len(groups) == 0 {
+		return nil
+	}
+	summaries := make([]ReactionSummary, 0, len(groups))
+	for _, group := range groups {
+		userIDs := make([]int64, len(group.reactions))
+		for i, r := range group.reactions {
+			userIDs[i] = r.UserID
 		}
+		summaries = append(summaries, ReactionSummary{Emoji: group.emoji, UserIDs: userIDs})
+	}
+	return summaries
+}
 
-		msg := m.messages[msgID]
-		if msg != nil && msg.DeletedAt == nil {
-			*messages = append(*messages, msg)
+// removeUserReactions drops all of a user's reactions to a message (caller must hold write lock)
+func (m *MemDB) removeUserReactions(messageID, userID int64) {
+	for _, group := range m.reactions[messageID] {
+		kept := group.reactions[:0]
+		for _, r := range group.reactions {
+			if r.UserID != userID {
+				kept = append(kept, r)
+			}
+		}
+		group.reactions = kept
+	}
+	m.pruneReactionGroups(messageID)
+}
 
-			// Recursively collect this message's children
-			m.collectThreadReplies(msgID, messages, beforeID, afterID, limit)
+// pruneReactionGroups drops emoji nobody reacts with anymore (caller must hold write lock)
+func (m *MemDB) pruneReactionGroups(messageID int64) {
+	groups := m.reactions[messageID]
+	kept := groups[:0]
+	for _, group := range groups {
+		if len(group.reactions) > 0 {
+			kept = append(kept, group)
 		}
 	}
+	if len(kept) == 0 {
+		delete(m.reactions, messageID)
+		return
+	}
+	m.reactions[messageID] = kept
 }
 
 // recomputeReplyCount recalculates the reply count for a message (assumes lock held)
@@ -860,41 +1706,51 @@ func (m *MemDB) recomputeReplyCount(messageID int64) {
 	msg.ReplyCount.Store(count)
 }
 
-// CountReplies returns the cached reply count for a message (O(1) lookup)
+// CountReplies returns the cached reply count for a message (O(1) lookup). Replies to
+// messages that aren't cached are counted in SQLite.
 func (m *MemDB) CountReplies(messageID int64) (uint32, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	msg := m.messages[messageID]
+	m.mu.RUnlock()
+
 	if msg == nil {
-		return 0, nil
+		return m.sqliteDB.countDirectReplies(messageID)
 	}
 
 	return msg.ReplyCount.Load(), nil
 }
 
-// SubchannelExists checks if a subchannel exists (V2 feature - not implemented yet)
-func (m *MemDB) SubchannelExists(subchannelID int64) (bool, error) {
-	// V2 feature - always return false for V1
-	return false, nil
-}
-
 // SoftDeleteMessage marks a message as deleted (sets deleted_at timestamp)
 func (m *MemDB) SoftDeleteMessage(messageID uint64, nickname string) (*Message, error) {
+	if _, err := m.loadThread(int64(messageID)); err != nil {
+		return nil, err
+	}
+
+	var seq uint64
+	defer func() { m.waitForJournal(seq) }() // Runs after the unlock below
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	msg, exists := m.messages[int64(messageID)]
 	if !exists {
-		return nil, fmt.Errorf("message not found")
+		return nil, ErrMessageNotFound
 	}
 
+	if msg.AuthorNickname != nickname {
+		return nil, ErrMessageNotOwned
+	}
 	if msg.DeletedAt != nil {
-		return nil, fmt.Errorf("message already deleted")
+		return nil, ErrMessageAlreadyDeleted
 	}
 
 	// Mark as deleted
 	now := nowMillis()
+	var err error
+	seq, err = m.journal.append(journalEntry{Op: journalOpDelete, MessageID: msg.ID, Timestamp: now})
+	if err != nil {
+		return nil, err
+	}
 	msg.DeletedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
 
@@ -911,6 +1767,13 @@ func (m *MemDB) SoftDeleteMessage(messageID uint64, nickname string) (*Message,
 // AdminSoftDeleteMessage marks a message as deleted (admin override - bypasses ownership check in DB layer)
 // In MemDB, this behaves identically to SoftDeleteMessage since ownership validation happens in the DB layer
 func (m *MemDB) AdminSoftDeleteMessage(messageID uint64, adminNickname string) (*Message, error) {
+	if _, err := m.loadThread(int64(messageID)); err != nil {
+		return nil, err
+	}
+
+	var seq uint64
+	defer func() { m.waitForJournal(seq) }() // Runs after the unlock below
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -925,6 +1788,11 @@ func (m *MemDB) AdminSoftDeleteMessage(messageID uint64, adminNickname string) (
 
 	// Mark as deleted
 	now := nowMillis()
+	var err error
+	seq, err = m.journal.append(journalEntry{Op: journalOpDelete, MessageID: msg.ID, Timestamp: now})
+	if err != nil {
+		return nil, err
+	}
 	msg.DeletedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
 
@@ -939,7 +1807,14 @@ func (m *MemDB) AdminSoftDeleteMessage(messageID uint64, adminNickname string) (
 }
 
 // UpdateMessage updates a message's content (for registered users only)
-func (m *MemDB) UpdateMessage(messageID uint64, userID uint64, newContent string) (*Message, error) {
+func (m *MemDB) UpdateMessage(messageID uint64, userID uint64, newContent, editorNickname string) (*Message, error) {
+	if _, err := m.loadThread(int64(messageID)); err != nil {
+		return nil, err
+	}
+
+	var seq uint64
+	defer func() { m.waitForJournal(seq) }() // Runs after the unlock below
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -961,6 +1836,21 @@ func (m *MemDB) UpdateMessage(messageID uint64, userID uint64, newContent string
 
 	// Update content and edited_at timestamp
 	now := nowMillis()
+	adminEdit := false
+	var err error
+	seq, err = m.journal.append(journalEntry{
+		Op:             journalOpEdit,
+		MessageID:      msg.ID,
+		Content:        newContent,
+		Timestamp:      now,
+		EditorNickname: editorNickname,
+		AdminEdit:      adminEdit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.recordEditLocked(msg, editorNickname, adminEdit, now)
+	m.cacheBytes += int64(len(newContent) - len(msg.Content))
 	msg.Content = newContent
 	msg.EditedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
@@ -969,7 +1859,14 @@ func (m *MemDB) UpdateMessage(messageID uint64, userID uint64, newContent string
 }
 
 // AdminUpdateMessage updates a message's content (admin override - bypasses ownership check)
-func (m *MemDB) AdminUpdateMessage(messageID uint64, userID uint64, newContent string) (*Message, error) {
+func (m *MemDB) AdminUpdateMessage(messageID uint64, userID uint64, newContent, editorNickname string) (*Message, error) {
+	if _, err := m.loadThread(int64(messageID)); err != nil {
+		return nil, err
+	}
+
+	var seq uint64
+	defer func() { m.waitForJournal(seq) }() // Runs after the unlock below
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -989,6 +1886,21 @@ func (m *MemDB) AdminUpdateMessage(messageID uint64, userID uint64, newContent s
 
 	// Update content and edited_at timestamp
 	now := nowMillis()
+	adminEdit := *msg.AuthorUserID != int64(userID)
+	var err error
+	seq, err = m.journal.append(journalEntry{
+		Op:             journalOpEdit,
+		MessageID:      msg.ID,
+		Content:        newContent,
+		Timestamp:      now,
+		EditorNickname: editorNickname,
+		AdminEdit:      adminEdit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.recordEditLocked(msg, editorNickname, adminEdit, now)
+	m.cacheBytes += int64(len(newContent) - len(msg.Content))
 	msg.Content = newContent
 	msg.EditedAt = &now
 	m.dirtyMessages[int64(messageID)] = true // Mark as dirty for next snapshot
@@ -996,12 +1908,186 @@ func (m *MemDB) AdminUpdateMessage(messageID uint64, userID uint64, newContent s
 	return msg, nil
 }
 
-// CleanupExpiredMessages removes messages older than retention period (no-op for V1 - handled by snapshot)
-func (m *MemDB) CleanupExpiredMessages() (int64, error) {
-	// In MemDB, we don't need to actively clean up - the snapshot process
-	// only writes recent messages, and we reload from SQLite on startup
-	// SQLite's cleanup will handle the actual deletion
-	return 0, nil
+// recordEditLocked queues a version entry with the content a message had before an edit,
+// to be written on the next snapshot (assumes lock held)
+func (m *MemDB) recordEditLocked(msg *Message, editorNickname string, adminEdit bool, editedAt int64) {
+	m.pendingVersions = append(m.pendingVersions, MessageVersion{
+		MessageID:      msg.ID,
+		Content:        msg.Content,
+		AuthorNickname: msg.AuthorNickname,
+		CreatedAt:      editedAt,
+		VersionType:    "edited",
+		EditorNickname: editorNickname,
+		AdminEdit:      adminEdit,
+	})
+}
+
+// waitForJournal waits until the journal entry seq is on disk. A failed sync is only
+// logged: the operation already happened in memory and the next snapshot persists it.
+func (m *MemDB) waitForJournal(seq uint64) {
+	if err := m.journal.wait(seq); err != nil {
+		log.Printf("MemDB: %v", err)
+	}
+}
+
+// replayJournal applies journaled operations to the state loaded from SQLite. Entries
+// already in SQLite (a snapshot that finished just before a crash) are skipped, as are
+// entries whose channel, parent or author has since been deleted.
+func (m *MemDB) replayJournal() (int, error) {
+	userExists := make(map[int64]bool)
+	var rowErr error
+
+	// Entries can be about threads outside the hot window, which weren't loaded
+	loadThread := func(messageID int64) {
+		if _, exists := m.messages[messageID]; exists {
+			return
+		}
+		messages, reactions, err := m.fetchThread(messageID)
+		if err != nil && rowErr == nil {
+			rowErr = err
+		}
+		m.cacheMessagesLocked(messages, reactions, 0)
+	}
+
+	replayed, err := m.journal.replay(func(entry journalEntry) {
+		loadThread(entry.MessageID)
+		if entry.Op == journalOpCreate && entry.ParentID != nil {
+			loadThread(*entry.ParentID)
+		}
+
+		switch entry.Op {
+		case journalOpCreate:
+			if _, exists := m.messages[entry.MessageID]; exists {
+				return
+			}
+			if _, exists := m.channels[entry.ChannelID]; !exists {
+				return
+			}
+			if entry.SubchannelID != nil {
+				if _, exists := m.subchannels[*entry.SubchannelID]; !exists {
+					return
+				}
+			}
+			if entry.ParentID != nil {
+				if _, exists := m.messages[*entry.ParentID]; !exists {
+					// Soft-deleted parents aren't loaded but are still in SQLite
+					exists, err := m.sqliteDB.messageRowExists(*entry.ParentID)
+					if err != nil && rowErr == nil {
+						rowErr = err
+					}
+					if !exists {
+						return
+					}
+				}
+			}
+			authorUserID := entry.AuthorUserID
+			if authorUserID != nil {
+				exists, checked := userExists[*authorUserID]
+				if !checked {
+					_, err := m.sqliteDB.GetUserByID(*authorUserID)
+					exists = err == nil
+					userExists[*authorUserID] = exists
+				}
+				if !exists {
+					authorUserID = nil // Anonymized, like the user's other messages
+				}
+			}
+
+			msg := &Message{
+				ID:             entry.MessageID,
+				ChannelID:      entry.ChannelID,
+				SubchannelID:   entry.SubchannelID,
+				ParentID:       entry.ParentID,
+				ThreadRootID:   entry.ThreadRootID,
+				AuthorUserID:   authorUserID,
+				AuthorNickname: entry.AuthorNickname,
+				Content:        entry.Content,
+				CreatedAt:      entry.Timestamp,
+			}
+			m.messages[msg.ID] = msg
+			m.trackMessageLocked(msg, 0)
+			m.dirtyMessages[msg.ID] = true
+			m.messagesByChannel[msg.ChannelID] = append(m.messagesByChannel[msg.ChannelID], msg.ID)
+			if msg.ParentID != nil {
+				m.messagesByParent[*msg.ParentID] = append(m.messagesByParent[*msg.ParentID], msg.ID)
+			}
+			if msg.ThreadRootID != nil {
+				m.messagesByThread[*msg.ThreadRootID] = append(m.messagesByThread[*msg.ThreadRootID], msg.ID)
+			}
+
+		case journalOpEdit:
+			msg, exists := m.messages[entry.MessageID]
+			if !exists || msg.DeletedAt != nil || (msg.EditedAt != nil && *msg.EditedAt >= entry.Timestamp) {
+				return
+			}
+			m.recordEditLocked(msg, entry.EditorNickname, entry.AdminEdit, entry.Timestamp)
+			m.cacheBytes += int64(len(entry.Content) - len(msg.Content))
+			msg.Content = entry.Content
+			editedAt := entry.Timestamp
+			msg.EditedAt = &editedAt
+			m.dirtyMessages[msg.ID] = true
+
+		case journalOpDelete:
+			msg, exists := m.messages[entry.MessageID]
+			if !exists || msg.DeletedAt != nil {
+				return
+			}
+			deletedAt := entry.Timestamp
+			msg.DeletedAt = &deletedAt
+			m.dirtyMessages[msg.ID] = true
+		}
+	})
+	if err != nil {
+		return replayed, err
+	}
+	return replayed, rowErr
+}
+
+// ListMessageVersions returns the version history of a message, oldest first: the
+// versions in SQLite followed by those not yet snapshotted
+func (m *MemDB) ListMessageVersions(messageID int64) ([]MessageVersion, error) {
+	// Hold off snapshots so no version is in both places (or neither) while reading
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	versions, err := m.sqliteDB.ListMessageVersions(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	for _, v := range m.pendingVersions {
+		if v.MessageID == messageID {
+			versions = append(versions, v)
+		}
+	}
+	m.mu.RUnlock()
+
+	return versions, nil
+}
+
+// CleanupExpiredMessages purges messages past their channel's (or subchannel's) retention
+// from memory and SQLite, and returns the purged messages so clients can be told to drop them
+func (m *MemDB) CleanupExpiredMessages() ([]*Message, error) {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	expired := m.hardDeleteOldMessages()
+
+	ids := make([]int64, len(expired))
+	for i, msg := range expired {
+		ids[i] = msg.ID
+	}
+	if err := m.sqliteDB.DeleteMessages(ids); err != nil {
+		return expired, err
+	}
+
+	// Rows that aren't in memory (soft-deleted messages aren't loaded on startup)
+	if _, err := m.sqliteDB.CleanupExpiredMessages(); err != nil {
+		return expired, err
+	}
+
+	return expired, nil
 }
 
 // CleanupIdleSessions removes sessions inactive for longer than timeout (no-op for V1 - handled by session manager)
@@ -1115,6 +2201,57 @@ func (m *MemDB) UpdateUserPassword(userID int64, newPasswordHash string) error {
 	return m.sqliteDB.UpdateUserPassword(userID, newPasswordHash)
 }
 
+// UpdateUserFlags replaces a user's flags (admin, moderator)
+func (m *MemDB) UpdateUserFlags(userID int64, flags uint8) error {
+	return m.sqliteDB.UpdateUserFlags(userID, flags)
+}
+
+// GetModeratorChannels returns the channels a moderator is limited to (passthrough to SQLite)
+func (m *MemDB) GetModeratorChannels(userID int64) ([]int64, error) {
+	return m.sqliteDB.GetModeratorChannels(userID)
+}
+
+// SetModeratorChannels replaces the channels a moderator is limited to (passthrough to SQLite)
+func (m *MemDB) SetModeratorChannels(userID int64, channelIDs []int64) error {
+	return m.sqliteDB.SetModeratorChannels(userID, channelIDs)
+}
+
+// ===== Two-Factor Authentication Methods (passthrough to SQLite) =====
+
+func (m *MemDB) GetTwoFactor(userID int64) (*TwoFactor, error) {
+	return m.sqliteDB.GetTwoFactor(userID)
+}
+
+func (m *MemDB) EnableTwoFactor(userID int64, secret string, recoveryCodeHashes []string) error {
+	return m.sqliteDB.EnableTwoFactor(userID, secret, recoveryCodeHashes)
+}
+
+func (m *MemDB) DisableTwoFactor(userID int64) error {
+	return m.sqliteDB.DisableTwoFactor(userID)
+}
+
+func (m *MemDB) UseTwoFactorStep(userID, step int64) (bool, error) {
+	return m.sqliteDB.UseTwoFactorStep(userID, step)
+}
+
+func (m *MemDB) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	return m.sqliteDB.UseRecoveryCode(userID, codeHash)
+}
+
+func (m *MemDB) CountRecoveryCodes(userID int64) (int, error) {
+	return m.sqliteDB.CountRecoveryCodes(userID)
+}
+
+// ===== User Profile Methods =====
+
+func (m *MemDB) GetUserProfile(userID int64) (*UserProfile, error) {
+	return m.sqliteDB.GetUserProfile(userID)
+}
+
+func (m *MemDB) SaveUserProfile(p *UserProfile) error {
+	return m.sqliteDB.SaveUserProfile(p)
+}
+
 // ===== SSH Key Methods (V2 feature) =====
 
 func (m *MemDB) CreateSSHKey(key *SSHKey) error {
@@ -1147,6 +2284,14 @@ func (m *MemDB) CreateUserBan(userID *int64, nickname *string, reason string, sh
 	return m.sqliteDB.CreateUserBan(userID, nickname, reason, shadowban, durationSeconds, adminNickname, adminIP)
 }
 
+func (m *MemDB) CreateChannelBan(userID *int64, nickname *string, channelID int64, reason string, durationSeconds *uint64, adminNickname, adminIP string) (int64, error) {
+	return m.sqliteDB.CreateChannelBan(userID, nickname, channelID, reason, durationSeconds, adminNickname, adminIP)
+}
+
+func (m *MemDB) GetActiveChannelBan(userID *int64, nickname string, channelID int64) (*Ban, error) {
+	return m.sqliteDB.GetActiveChannelBan(userID, nickname, channelID)
+}
+
 func (m *MemDB) CreateIPBan(ipCIDR string, reason string, durationSeconds *uint64, adminNickname, adminIP string) (int64, error) {
 	return m.sqliteDB.CreateIPBan(ipCIDR, reason, durationSeconds, adminNickname, adminIP)
 }
@@ -1171,10 +2316,40 @@ func (m *MemDB) ListBans(includeExpired bool) ([]*Ban, error) {
 	return m.sqliteDB.ListBans(includeExpired)
 }
 
+// ===== Login Failure Methods (passthrough to SQLite) =====
+
+func (m *MemDB) GetLoginFailure(kind, identifier string) (*LoginFailure, error) {
+	return m.sqliteDB.GetLoginFailure(kind, identifier)
+}
+
+func (m *MemDB) AddLoginFailure(kind, identifier string, now, resetBefore int64) (int, error) {
+	return m.sqliteDB.AddLoginFailure(kind, identifier, now, resetBefore)
+}
+
+func (m *MemDB) ExtendLoginLockout(kind, identifier string, lockedUntil int64) error {
+	return m.sqliteDB.ExtendLoginLockout(kind, identifier, lockedUntil)
+}
+
+func (m *MemDB) ClearLoginFailure(kind, identifier string) error {
+	return m.sqliteDB.ClearLoginFailure(kind, identifier)
+}
+
+func (m *MemDB) ListLoginLockouts(now int64) ([]*LoginFailure, error) {
+	return m.sqliteDB.ListLoginLockouts(now)
+}
+
+func (m *MemDB) CleanupLoginFailures(before int64) (int64, error) {
+	return m.sqliteDB.CleanupLoginFailures(before)
+}
+
 // ===== Admin Action Logging =====
 
-func (m *MemDB) LogAdminAction(adminUserID uint64, adminNickname, actionType, details string) error {
-	return m.sqliteDB.LogAdminAction(adminUserID, adminNickname, actionType, details)
+func (m *MemDB) LogAdminAction(action AdminAction) error {
+	return m.sqliteDB.LogAdminAction(action)
+}
+
+func (m *MemDB) ListAdminActions(filter AdminActionFilter) ([]*AdminAction, error) {
+	return m.sqliteDB.ListAdminActions(filter)
 }
 
 // ===== Channel Deletion =====
@@ -1188,22 +2363,45 @@ func (m *MemDB) DeleteChannel(channelID uint64) error {
 
 	// Remove from in-memory cache
 	m.mu.Lock()
-	delete(m.channels, int64(channelID))
-
-	// Clean up message indexes for this channel
-	if messageIDs, exists := m.messagesByChannel[int64(channelID)]; exists {
-		// Mark all messages as dirty so they get deleted from SQLite
-		for _, msgID := range messageIDs {
-			delete(m.messages, msgID)
-		}
-		delete(m.messagesByChannel, int64(channelID))
-	}
+	m.removeChannelLocked(int64(channelID))
 	m.mu.Unlock()
 
 	log.Printf("MemDB: removed channel from cache: id=%d", channelID)
 	return nil
 }
 
+// removeChannelLocked drops a channel that's gone from SQLite from the cache, along with
+// its subchannels and messages, and returns the IDs of the removed messages (assumes
+// write lock held)
+func (m *MemDB) removeChannelLocked(channelID int64) []int64 {
+	delete(m.channels, channelID)
+
+	delete(m.channelAccess, channelID)
+
+	// Subchannels were removed from SQLite via ON DELETE CASCADE
+	for subID, sub := range m.subchannels {
+		if sub.ChannelID == channelID {
+			delete(m.subchannels, subID)
+		}
+	}
+
+	// Clean up message indexes for this channel
+	messageIDs := m.messagesByChannel[channelID]
+	// SQLite already cascaded the delete, so drop pending snapshot writes too
+	for _, msgID := range messageIDs {
+		if msg := m.messages[msgID]; msg != nil {
+			m.untrackMessageLocked(msg)
+		}
+		delete(m.messages, msgID)
+		delete(m.dirtyMessages, msgID)
+		delete(m.reactions, msgID)
+		delete(m.dirtyReactions, msgID)
+	}
+	delete(m.messagesByChannel, channelID)
+	delete(m.coldUntil, channelID)
+	return messageIDs
+}
+
 // DeleteUser deletes a user account and anonymizes their messages
 // Also removes all in-memory sessions for this user
 // Returns the nickname of the deleted user
@@ -1224,10 +2422,21 @@ func (m *MemDB) DeleteUser(userID uint64) (string, error) {
 		log.Printf("MemDB: removed %d sessions for deleted user: id=%d, nickname=%s", len(sessionsSet), userID, nickname)
 	}
 
+	// ChannelAccess rows were removed from SQLite via ON DELETE CASCADE
+	for _, participants := range m.channelAccess {
+		delete(participants, int64(userID))
+	}
+
+	// Reaction rows were removed from SQLite via ON DELETE CASCADE
+	for messageID := range m.reactions {
+		m.removeUserReactions(messageID, int64(userID))
+	}
+
 	// Update all messages in memory: set author_user_id=NULL for this user's messages
 	for _, msg := range m.messages {
 		if msg.AuthorUserID != nil && uint64(*msg.AuthorUserID) == userID {
 			msg.AuthorUserID = nil
+			m.cacheBytes += int64(len(nickname) - len(msg.AuthorNickname))
 			msg.AuthorNickname = nickname // Preserve nickname for anonymized messages
 			m.dirtyMessages[msg.ID] = true
 		}
@@ -1250,15 +2459,55 @@ func (m *MemDB) GetUserChannelState(userID uint64, channelID uint64, subchannelI
 	return m.sqliteDB.GetUserChannelState(userID, channelID, subchannelID)
 }
 
+// AddMentions stores mentions of a newly posted message (passthrough to SQLite)
+func (m *MemDB) AddMentions(mentions []Mention) error {
+	return m.sqliteDB.AddMentions(mentions)
+}
+
+// ListMentions returns a user's mentions, newest first (passthrough to SQLite)
+func (m *MemDB) ListMentions(userID int64, unreadOnly bool, beforeID *int64, limit int) ([]Mention, error) {
+	return m.sqliteDB.ListMentions(userID, unreadOnly, beforeID, limit)
+}
+
+// CountUnreadMentions counts a user's unread mentions (passthrough to SQLite)
+func (m *MemDB) CountUnreadMentions(userID int64) (uint32, error) {
+	return m.sqliteDB.CountUnreadMentions(userID)
+}
+
+// MarkMentionsRead marks a user's mentions read (passthrough to SQLite)
+func (m *MemDB) MarkMentionsRead(userID int64, messageIDs []int64, readAt int64) error {
+	return m.sqliteDB.MarkMentionsRead(userID, messageIDs, readAt)
+}
+
 // GetUnreadCountForChannel counts unread messages in a channel after the given timestamp
-// Uses in-memory data for fast counting
+// Uses in-memory data for fast counting, and SQLite for messages older than what's cached
 func (m *MemDB) GetUnreadCountForChannel(channelID uint64, subchannelID *uint64, sinceTimestamp int64) (uint32, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	coldUntil, cold := m.coldUntil[int64(channelID)]
+	count := m.countUnreadCachedLocked(channelID, subchannelID, max(sinceTimestamp, coldUntil-1))
+	m.mu.RUnlock()
+
+	if !cold || sinceTimestamp >= coldUntil-1 {
+		return count, nil
+	}
+
+	var sub *int64
+	if subchannelID != nil {
+		id := int64(*subchannelID)
+		sub = &id
+	}
+	stored, err := m.sqliteDB.countMessagesBetween(int64(channelID), sub, sinceTimestamp, coldUntil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+	return count + stored, nil
+}
 
+// countUnreadCachedLocked counts cached messages in a channel after the given timestamp (assumes lock held)
+func (m *MemDB) countUnreadCachedLocked(channelID uint64, subchannelID *uint64, sinceTimestamp int64) uint32 {
 	messageIDs, exists := m.messagesByChannel[int64(channelID)]
 	if !exists {
-		return 0, nil
+		return 0
 	}
 
 	var count uint32
@@ -1282,12 +2531,16 @@ func (m *MemDB) GetUnreadCountForChannel(channelID uint64, subchannelID *uint64,
 		}
 	}
 
-	return count, nil
+	return count
 }
 
 // GetUnreadCountForThread counts unread messages in a specific thread after the given timestamp
 // Uses in-memory data for fast counting
 func (m *MemDB) GetUnreadCountForThread(threadID uint64, sinceTimestamp int64) (uint32, error) {
+	if _, err := m.loadThread(int64(threadID)); err != nil {
+		return 0, err
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -0,0 +1,336 @@
+package database
+
+import (
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// CacheConfig bounds the message history MemDB keeps in memory. Threads are cached
+// whole; the ones that don't fit are read from SQLite again when they're needed.
+type CacheConfig struct {
+	MemoryBudget int64         // Approximate bytes of cached messages (0 = unlimited)
+	HotWindow    time.Duration // Threads unused for this long are evicted, and not loaded at startup (0 = keep all)
+}
+
+// CacheStats describes the MemDB message cache
+type CacheStats struct {
+	Messages int    // Cached messages
+	Threads  int    // Cached threads
+	Bytes    int64  // Approximate memory used by cached messages
+	Budget   int64  // Configured memory budget (0 = unlimited)
+	Hits     uint64 // Lookups served from memory
+	Misses   uint64 // Lookups that had to read SQLite
+}
+
+// messageCacheOverhead approximates the memory a cached message takes besides its
+// strings: the struct, its map entries and its slots in the indexes
+const messageCacheOverhead = 320
+
+// messageCacheSize approximates the memory a cached message takes
+func messageCacheSize(msg *Message) int64 {
+	return messageCacheOverhead + int64(len(msg.Content)+len(msg.AuthorNickname))
+}
+
+// threadState tracks the activity of a cached thread for eviction
+type threadState struct {
+	channelID     int64
+	lastMessageAt int64        // Newest message in the thread (Unix ms)
+	lastUsed      atomic.Int64 // Last read or write (Unix ms), updated under the read lock
+}
+
+// threadRootOf returns the ID of the thread a message belongs to
+func threadRootOf(msg *Message) int64 {
+	if msg.ThreadRootID != nil {
+		return *msg.ThreadRootID
+	}
+	return msg.ID
+}
+
+// trackMessageLocked accounts for a message added to the cache. usedAt is when its
+// thread was last used (0 = when the message was posted). Caller must hold write lock.
+func (m *MemDB) trackMessageLocked(msg *Message, usedAt int64) {
+	rootID := threadRootOf(msg)
+	state := m.threads[rootID]
+	if state == nil {
+		state = &threadState{channelID: msg.ChannelID}
+		m.threads[rootID] = state
+	}
+	state.lastMessageAt = max(state.lastMessageAt, msg.CreatedAt)
+	if used := max(usedAt, msg.CreatedAt); used > state.lastUsed.Load() {
+		state.lastUsed.Store(used)
+	}
+	m.cacheBytes += messageCacheSize(msg)
+}
+
+// untrackMessageLocked accounts for a message removed from the cache (caller must hold write lock)
+func (m *MemDB) untrackMessageLocked(msg *Message) {
+	m.cacheBytes -= messageCacheSize(msg)
+	if threadRootOf(msg) == msg.ID {
+		delete(m.threads, msg.ID)
+	}
+}
+
+// touchThreadLocked marks a message's thread as used (caller must hold lock)
+func (m *MemDB) touchThreadLocked(msg *Message) {
+	if state := m.threads[threadRootOf(msg)]; state != nil {
+		state.lastUsed.Store(nowMillis())
+	}
+}
+
+// cacheMessagesLocked adds messages read from SQLite (oldest first) and their reactions
+// to the cache. Threads that are already cached are skipped, since the cached copy can
+// have changes that aren't in SQLite yet. Returns the number of messages added.
+// Caller must hold write lock.
+func (m *MemDB) cacheMessagesLocked(messages []*Message, reactions []Reaction, usedAt int64) int {
+	skipThread := make(map[int64]bool)
+	added := make(map[int64]bool, len(messages))
+	byChannel := make(map[int64][]int64)
+	byParent := make(map[int64][]int64)
+	byThread := make(map[int64][]int64)
+
+	for _, msg := range messages {
+		rootID := threadRootOf(msg)
+		skip, checked := skipThread[rootID]
+		if !checked {
+			_, skip = m.threads[rootID]
+			skipThread[rootID] = skip
+		}
+		if _, exists := m.messages[msg.ID]; skip || exists {
+			continue
+		}
+
+		m.messages[msg.ID] = msg
+		m.trackMessageLocked(msg, usedAt)
+		added[msg.ID] = true
+
+		byChannel[msg.ChannelID] = append(byChannel[msg.ChannelID], msg.ID)
+		if msg.ParentID != nil {
+			byParent[*msg.ParentID] = append(byParent[*msg.ParentID], msg.ID)
+		}
+		if msg.ThreadRootID != nil {
+			byThread[*msg.ThreadRootID] = append(byThread[*msg.ThreadRootID], msg.ID)
+		}
+	}
+
+	for channelID, ids := range byChannel {
+		m.messagesByChannel[channelID] = m.mergeByTimestamp(m.messagesByChannel[channelID], ids)
+	}
+	for parentID, ids := range byParent {
+		m.messagesByParent[parentID] = m.mergeByTimestamp(m.messagesByParent[parentID], ids)
+	}
+	for rootID, ids := range byThread {
+		m.messagesByThread[rootID] = m.mergeByTimestamp(m.messagesByThread[rootID], ids)
+	}
+
+	// Replies are in the same thread as their parent, so they were loaded together
+	for msgID := range added {
+		m.recomputeReplyCount(msgID)
+	}
+
+	for _, r := range reactions {
+		if !added[r.MessageID] {
+			continue
+		}
+		group := m.reactionGroup(r.MessageID, r.Emoji)
+		if group == nil {
+			group = &reactionGroup{emoji: r.Emoji}
+			m.reactions[r.MessageID] = append(m.reactions[r.MessageID], group)
+		}
+		group.reactions = append(group.reactions, r)
+	}
+
+	return len(added)
+}
+
+// mergeByTimestamp merges two lists of message IDs sorted by timestamp (caller must hold lock)
+func (m *MemDB) mergeByTimestamp(ids, added []int64) []int64 {
+	if len(ids) == 0 {
+		return added
+	}
+
+	createdAt := func(id int64) int64 {
+		if msg := m.messages[id]; msg != nil {
+			return msg.CreatedAt
+		}
+		return 0
+	}
+
+	merged := make([]int64, 0, len(ids)+len(added))
+	i, j := 0, 0
+	for i < len(ids) && j < len(added) {
+		if createdAt(added[j]) < createdAt(ids[i]) {
+			merged = append(merged, added[j])
+			j++
+		} else {
+			merged = append(merged, ids[i])
+			i++
+		}
+	}
+	merged = append(merged, ids[i:]...)
+	return append(merged, added[j:]...)
+}
+
+// fetchThread reads the thread a message belongs to from SQLite. Returns no messages
+// if the message doesn't exist or is deleted.
+func (m *MemDB) fetchThread(messageID int64) ([]*Message, []Reaction, error) {
+	rootID, found, err := m.sqliteDB.threadRootOf(messageID)
+	if err != nil || !found {
+		return nil, nil, err
+	}
+	return m.sqliteDB.listMessagesAndReactions(`(thread_root_id = ? OR id = ?)`, rootID, rootID)
+}
+
+// loadThread makes sure the thread a message belongs to is cached, reading it from
+// SQLite if it isn't. Returns false if the message doesn't exist.
+func (m *MemDB) loadThread(messageID int64) (bool, error) {
+	m.mu.RLock()
+	msg, exists := m.messages[messageID]
+	if exists {
+		m.touchThreadLocked(msg)
+	}
+	m.mu.RUnlock()
+
+	if exists {
+		m.cacheHits.Add(1)
+		return true, nil
+	}
+	m.cacheMisses.Add(1)
+
+	messages, reactions, err := m.fetchThread(messageID)
+	if err != nil {
+		return false, err
+	}
+	if len(messages) == 0 {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	m.cacheMessagesLocked(messages, reactions, nowMillis())
+	_, exists = m.messages[messageID]
+	m.mu.Unlock()
+
+	return exists, nil
+}
+
+// markColdChannels records which channels have messages in SQLite older than the
+// hot window, which weren't loaded
+func (m *MemDB) markColdChannels(cutoff int64) error {
+	for channelID := range m.channels {
+		cold, err := m.sqliteDB.hasMessagesBefore(channelID, cutoff)
+		if err != nil {
+			return err
+		}
+		if cold {
+			m.coldUntil[channelID] = cutoff
+		}
+	}
+	return nil
+}
+
+// evictColdThreads drops threads from the cache, least recently used first, while the
+// cache is over its memory budget and while threads have been unused for longer than
+// the hot window. Threads with changes that aren't snapshotted yet stay.
+func (m *MemDB) evictColdThreads() {
+	if m.cache.MemoryBudget <= 0 && m.cache.HotWindow <= 0 {
+		return
+	}
+
+	start := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var idleBefore int64
+	if m.cache.HotWindow > 0 {
+		idleBefore = nowMillis() - m.cache.HotWindow.Milliseconds()
+	}
+
+	type candidate struct {
+		rootID   int64
+		lastUsed int64
+	}
+	candidates := make([]candidate, 0, len(m.threads))
+	for rootID, state := range m.threads {
+		candidates = append(candidates, candidate{rootID, state.lastUsed.Load()})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].lastUsed < candidates[j].lastUsed })
+
+	threadsEvicted, messagesEvicted := 0, 0
+	for _, c := range candidates {
+		overBudget := m.cache.MemoryBudget > 0 && m.cacheBytes > m.cache.MemoryBudget
+		if !overBudget && c.lastUsed >= idleBefore {
+			break // Candidates are sorted, so the rest are more recent
+		}
+		if evicted := m.evictThreadLocked(c.rootID); evicted > 0 {
+			threadsEvicted++
+			messagesEvicted += evicted
+		}
+	}
+
+	if threadsEvicted > 0 {
+		log.Printf("MemDB: evicted %d threads (%d messages) in %v, %d messages cached (~%d KB)",
+			threadsEvicted, messagesEvicted, time.Since(start), len(m.messages), m.cacheBytes/1024)
+	}
+}
+
+// evictThreadLocked drops a thread from the cache unless it has changes that aren't
+// snapshotted yet. Returns the number of messages dropped. Caller must hold write lock.
+func (m *MemDB) evictThreadLocked(rootID int64) int {
+	state := m.threads[rootID]
+	if state == nil {
+		return 0
+	}
+
+	ids := append([]int64(nil), m.messagesByThread[rootID]...)
+	if _, cached := m.messages[rootID]; cached && !containsID(ids, rootID) {
+		ids = append(ids, rootID)
+	}
+	for _, id := range ids {
+		if m.dirtyMessages[id] || m.dirtyReactions[id] {
+			return 0
+		}
+	}
+
+	evicted := 0
+	for _, id := range ids {
+		if msg := m.messages[id]; msg != nil {
+			m.removeMessageLocked(msg)
+			evicted++
+		}
+	}
+	delete(m.messagesByThread, rootID)
+	delete(m.threads, rootID)
+
+	// Everything in the channel from after this thread's last message is still cached
+	if state.lastMessageAt >= m.coldUntil[state.channelID] {
+		m.coldUntil[state.channelID] = state.lastMessageAt + 1
+	}
+
+	return evicted
+}
+
+// containsID checks if ids contains id
+func containsID(ids []int64, id int64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheStats returns the current size and hit counts of the message cache
+func (m *MemDB) CacheStats() CacheStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return CacheStats{
+		Messages: len(m.messages),
+		Threads:  len(m.threads),
+		Bytes:    m.cacheBytes,
+		Budget:   m.cache.MemoryBudget,
+		Hits:     m.cacheHits.Load(),
+		Misses:   m.cacheMisses.Load(),
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemDBHotWindow(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	channelID, err := db.CreateChannel("general", "#general", nil, 1, 24*30, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	// History from before the hot window, and one recent message
+	day := int64(24 * 3600 * 1000)
+	now := nowMillis()
+	newMessage := func(parent *Message, content string, createdAt int64) *Message {
+		msg := &Message{
+			ID:             db.snowflake.NextID(),
+			ChannelID:      channelID,
+			AuthorUserID:   &aliceID,
+			AuthorNickname: "alice",
+			Content:        content,
+			CreatedAt:      createdAt,
+		}
+		msg.ThreadRootID = &msg.ID
+		if parent != nil {
+			msg.ParentID = &parent.ID
+			msg.ThreadRootID = parent.ThreadRootID
+		}
+		return msg
+	}
+	a := newMessage(nil, "a", now-10*day)
+	a1 := newMessage(a, "a1", now-10*day+1)
+	b := newMessage(nil, "b", now-9*day)
+	c := newMessage(nil, "c", now-8*day)
+	r := newMessage(nil, "r", now-3600*1000)
+
+	setup, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	if err := setup.batchInsertMessages([]*Message{a, a1, b, c, r}); err != nil {
+		t.Fatalf("failed to insert messages: %v", err)
+	}
+	setup.Close()
+
+	memDB, err := NewMemDBWithOptions(db, time.Hour, MemDBOptions{Cache: CacheConfig{HotWindow: 72 * time.Hour}})
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	if stats := memDB.CacheStats(); stats.Messages != 1 || stats.Threads != 1 {
+		t.Fatalf("expected only the recent thread to be loaded, got %+v", stats)
+	}
+
+	listIDs := func(limit uint16, beforeID, afterID *Message) []int64 {
+		t.Helper()
+		var before, after *uint64
+		if beforeID != nil {
+			id := uint64(beforeID.ID)
+			before = &id
+		}
+		if afterID != nil {
+			id := uint64(afterID.ID)
+			after = &id
+		}
+		messages, err := memDB.ListRootMessages(channelID, nil, limit, before, after)
+		if err != nil {
+			t.Fatalf("failed to list messages: %v", err)
+		}
+		ids := make([]int64, len(messages))
+		for i, msg := range messages {
+			ids[i] = msg.ID
+		}
+		return ids
+	}
+	expectIDs := func(name string, got []int64, want ...*Message) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d messages, got %v", name, len(want), got)
+		}
+		for i := range want {
+			if got[i] != want[i].ID {
+				t.Fatalf("%s: expected %q at %d, got %v", name, want[i].Content, i, got)
+			}
+		}
+	}
+
+	// Pages past the hot window come from SQLite, newest first
+	expectIDs("first page", listIDs(2, nil, nil), r, c)
+	expectIDs("older page", listIDs(10, c, nil), b, a)
+	expectIDs("newer page", listIDs(10, nil, a), b, c, r)
+	if stats := memDB.CacheStats(); stats.Messages != 1 || stats.Misses == 0 {
+		t.Fatalf("expected listing not to load threads, got %+v", stats)
+	}
+
+	// Reply counts and reactions of history that isn't cached come from SQLite
+	if count, _ := memDB.CountReplies(a.ID); count != 1 {
+		t.Errorf("expected 1 reply to a cold message, got %d", count)
+	}
+
+	// Opening or editing a cold thread loads it whole
+	replies, err := memDB.ListThreadReplies(uint64(a.ID), 0, nil, nil)
+	if err != nil || len(replies) != 1 || replies[0].ID != a1.ID {
+		t.Fatalf("expected the cold reply, got %v (err %v)", replies, err)
+	}
+	if _, err := memDB.UpdateMessage(uint64(b.ID), uint64(aliceID), "b (edited)", "alice"); err != nil {
+		t.Fatalf("failed to edit cold message: %v", err)
+	}
+	if stats := memDB.CacheStats(); stats.Messages != 4 || stats.Threads != 3 {
+		t.Fatalf("expected threads a and b to be loaded, got %+v", stats)
+	}
+	if _, _, err := memDB.AddReaction(c.ID, aliceID, "👍"); err != nil {
+		t.Fatalf("failed to react to cold message: %v", err)
+	}
+
+	// Over the budget, only threads whose changes are in SQLite are evicted
+	memDB.cache.MemoryBudget = 1
+	memDB.evictColdThreads()
+	if stats := memDB.CacheStats(); stats.Messages != 2 || stats.Threads != 2 {
+		t.Fatalf("expected the edited and reacted threads to stay, got %+v", stats)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	memDB.evictColdThreads()
+	if stats := memDB.CacheStats(); stats.Messages != 0 || stats.Bytes != 0 {
+		t.Fatalf("expected everything to be evicted, got %+v", stats)
+	}
+
+	// ...and read back from SQLite when needed
+	expectIDs("after eviction", listIDs(10, nil, nil), r, c, b, a)
+	if reactions := memDB.GetReactions(c.ID); len(reactions) != 1 || reactions[0].Emoji != "👍" {
+		t.Errorf("expected the snapshotted reaction, got %+v", reactions)
+	}
+	edited, err := memDB.GetMessage(b.ID)
+	if err != nil || edited.Content != "b (edited)" {
+		t.Fatalf("expected the edited message, got %+v (err %v)", edited, err)
+	}
+	if count, _ := memDB.GetUnreadCountForChannel(uint64(channelID), nil, now-20*day); count != 5 {
+		t.Errorf("expected 5 unread messages across memory and SQLite, got %d", count)
+	}
+	if _, err := memDB.GetMessage(b.ID); err != nil {
+		t.Fatalf("failed to get cached message: %v", err)
+	}
+	if stats := memDB.CacheStats(); stats.Messages != 1 || stats.Hits != 1 {
+		t.Errorf("expected thread b to be loaded again and served from memory, got %+v", stats)
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -193,8 +195,8 @@ func TestSnapshotDeletesOldMessages(t *testing.T) {
 
 	// Hard delete old messages
 	deleted := memDB.hardDeleteOldMessages()
-	if deleted != 1 {
-		t.Errorf("expected 1 message to be hard deleted, got %d", deleted)
+	if len(deleted) != 1 {
+		t.Errorf("expected 1 message to be hard deleted, got %d", len(deleted))
 	}
 
 	// Verify message was removed from memory
@@ -219,6 +221,129 @@ func TestSnapshotDeletesOldMessages(t *testing.T) {
 	}
 }
 
+// TestMemDBRetention tests that messages are purged per channel/subchannel retention,
+// from memory and SQLite
+func TestMemDBRetention(t *testing.T) {
+	db, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	channelID, err := memDB.CreateChannel("short", "Short", nil, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	subID, err := memDB.CreateSubchannel(channelID, "long", "/long", nil, 1, 48, nil)
+	if err != nil {
+		t.Fatalf("failed to create subchannel: %v", err)
+	}
+
+	post := func(subchannelID, parentID *int64, content string) int64 {
+		t.Helper()
+		id, _, err := memDB.PostMessage(channelID, subchannelID, parentID, nil, "user1", content)
+		if err != nil {
+			t.Fatalf("failed to post message: %v", err)
+		}
+		return id
+	}
+	twoHoursAgo := nowMillis() - 2*3600*1000
+
+	// Expired thread: the root is older than the channel's 1h retention
+	oldRoot := post(nil, nil, "old root")
+	oldReply := post(nil, &oldRoot, "recent reply in old thread")
+
+	// Live thread with an old soft-deleted leaf and an old soft-deleted parent of a live reply
+	liveRoot := post(nil, nil, "live root")
+	deletedLeaf := post(nil, &liveRoot, "deleted leaf")
+	deletedParent := post(nil, &liveRoot, "deleted parent")
+	liveReply := post(nil, &deletedParent, "live reply")
+	for _, id := range []int64{deletedLeaf, deletedParent} {
+		if _, err := memDB.SoftDeleteMessage(uint64(id), "user1"); err != nil {
+			t.Fatalf("failed to delete message: %v", err)
+		}
+	}
+
+	// The subchannel's 48h retention overrides the channel's
+	subRoot := post(&subID, nil, "subchannel root")
+
+	memDB.mu.Lock()
+	memDB.messages[oldRoot].CreatedAt = twoHoursAgo
+	memDB.messages[subRoot].CreatedAt = twoHoursAgo
+	*memDB.messages[deletedLeaf].DeletedAt = twoHoursAgo
+	*memDB.messages[deletedParent].DeletedAt = twoHoursAgo
+	memDB.mu.Unlock()
+
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	// Everything but the old soft-deleted leaf was written
+	var count int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM Message`).Scan(&count); err != nil {
+		t.Fatalf("failed to count messages: %v", err)
+	}
+	if count != 6 {
+		t.Fatalf("expected 6 messages in SQLite after snapshot, got %d", count)
+	}
+
+	expired, err := memDB.CleanupExpiredMessages()
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	expiredIDs := make([]int64, len(expired))
+	for i, msg := range expired {
+		expiredIDs[i] = msg.ID
+	}
+	if fmt.Sprint(expiredIDs) != fmt.Sprint([]int64{oldRoot, oldReply, deletedLeaf}) {
+		t.Fatalf("expected messages %v to expire, got %v", []int64{oldRoot, oldReply, deletedLeaf}, expiredIDs)
+	}
+
+	memDB.mu.RLock()
+	for _, id := range expiredIDs {
+		if _, exists := memDB.messages[id]; exists {
+			t.Errorf("expected message %d to be removed from memory", id)
+		}
+	}
+	for _, id := range []int64{liveRoot, deletedParent, liveReply, subRoot} {
+		if _, exists := memDB.messages[id]; !exists {
+			t.Errorf("expected message %d to be kept", id)
+		}
+	}
+
+	// Indexes no longer reference purged messages
+	if ids := memDB.messagesByChannel[channelID]; len(ids) != 4 {
+		t.Errorf("expected 4 messages in the channel index, got %d", len(ids))
+	}
+	if ids := memDB.messagesByParent[liveRoot]; len(ids) != 1 || ids[0] != deletedParent {
+		t.Errorf("expected only the deleted parent to remain as a reply, got %v", ids)
+	}
+	if ids := memDB.messagesByThread[liveRoot]; len(ids) != 3 {
+		t.Errorf("expected 3 messages in the live thread, got %v", ids)
+	}
+	if _, exists := memDB.messagesByThread[oldRoot]; exists {
+		t.Error("expected the expired thread's index to be removed")
+	}
+	memDB.mu.RUnlock()
+
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM Message`).Scan(&count); err != nil {
+		t.Fatalf("failed to count messages: %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 messages left in SQLite, got %d", count)
+	}
+
+	// Nothing else expires on a second run
+	if expired, err := memDB.CleanupExpiredMessages(); err != nil || len(expired) != 0 {
+		t.Fatalf("expected nothing to expire, got %d (err %v)", len(expired), err)
+	}
+}
+
 // TestReplyCountPersistence tests that reply counts are recomputed on load
 func TestReplyCountPersistence(t *testing.T) {
 	dbPath := t.TempDir() + "/test.db"
@@ -493,3 +618,662 @@ func TestLargeBatchSnapshot(t *testing.T) {
 func strPtr(s string) *string {
 	return &s
 }
+
+// TestMemDBSubchannels tests subchannel caching and per-subchannel message filtering
+func TestMemDBSubchannels(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	channelID, err := db.CreateChannel("test-channel", "Test Channel", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	// Subchannels created before MemDB starts are loaded from SQLite
+	preexistingID, err := db.CreateSubchannel(channelID, "archive", "/archive", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create subchannel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	if exists, _ := memDB.SubchannelExists(preexistingID); !exists {
+		t.Fatal("expected preexisting subchannel to be loaded")
+	}
+
+	subID, err := memDB.CreateSubchannel(channelID, "chat", "/chat", strPtr("Quick chat"), 0, 24, nil)
+	if err != nil {
+		t.Fatalf("failed to create subchannel: %v", err)
+	}
+
+	subs, err := memDB.ListSubchannels(channelID)
+	if err != nil {
+		t.Fatalf("failed to list subchannels: %v", err)
+	}
+	if len(subs) != 2 || subs[0].Name != "archive" || subs[1].Name != "chat" {
+		t.Fatalf("unexpected subchannels: %+v", subs)
+	}
+
+	sub, err := memDB.GetSubchannel(subID)
+	if err != nil {
+		t.Fatalf("failed to get subchannel: %v", err)
+	}
+	if sub.ChannelID != channelID || sub.SubchannelType != 0 || sub.MessageRetentionHours != 24 {
+		t.Fatalf("unexpected subchannel: %+v", sub)
+	}
+
+	// Root listing and subchannel listing don't mix
+	if _, _, err := memDB.PostMessage(channelID, nil, nil, nil, "user1", "root message"); err != nil {
+		t.Fatalf("failed to post root message: %v", err)
+	}
+	if _, _, err := memDB.PostMessage(channelID, &subID, nil, nil, "user1", "subchannel message"); err != nil {
+		t.Fatalf("failed to post subchannel message: %v", err)
+	}
+
+	rootMsgs, err := memDB.ListRootMessages(channelID, nil, 50, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to list root messages: %v", err)
+	}
+	if len(rootMsgs) != 1 || rootMsgs[0].Content != "root message" {
+		t.Fatalf("expected only the root message, got %d messages", len(rootMsgs))
+	}
+
+	subMsgs, err := memDB.ListRootMessages(channelID, &subID, 50, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to list subchannel messages: %v", err)
+	}
+	if len(subMsgs) != 1 || subMsgs[0].Content != "subchannel message" {
+		t.Fatalf("expected only the subchannel message, got %d messages", len(subMsgs))
+	}
+
+	// Deleting the channel drops its subchannels from the cache
+	if err := memDB.DeleteChannel(uint64(channelID)); err != nil {
+		t.Fatalf("failed to delete channel: %v", err)
+	}
+	if exists, _ := memDB.SubchannelExists(subID); exists {
+		t.Fatal("expected subchannel to be removed with its channel")
+	}
+}
+
+func TestMemDBDirectMessages(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	carolID, err := db.CreateUser("carol", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	if _, err := db.CreateChannel("general", "#general", nil, 1, 168, nil); err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	// DMs created before MemDB starts are loaded from SQLite along with their participants
+	preexistingID, err := db.CreateDMChannel(aliceID, []int64{aliceID, carolID}, false)
+	if err != nil {
+		t.Fatalf("failed to create DM channel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	if !memDB.IsChannelParticipant(preexistingID, carolID) {
+		t.Fatal("expected preexisting DM participants to be loaded")
+	}
+
+	dm, err := memDB.CreateDMChannel(aliceID, []int64{aliceID, bobID}, false)
+	if err != nil {
+		t.Fatalf("failed to create DM channel: %v", err)
+	}
+	if !dm.IsPrivate {
+		t.Fatal("expected DM channel to be private")
+	}
+
+	// DM channels never show up in the public channel list
+	channels, err := memDB.ListChannels()
+	if err != nil {
+		t.Fatalf("failed to list channels: %v", err)
+	}
+	if len(channels) != 1 || channels[0].Name != "general" {
+		t.Fatalf("expected only the public channel, got %+v", channels)
+	}
+	if memDB.CountChannels() != 1 {
+		t.Fatalf("expected 1 public channel, got %d", memDB.CountChannels())
+	}
+
+	if memDB.IsChannelParticipant(dm.ID, carolID) {
+		t.Fatal("carol should not have access to the alice/bob DM")
+	}
+
+	found, ok := memDB.FindDMChannel([]int64{bobID, aliceID})
+	if !ok || found.ID != dm.ID {
+		t.Fatalf("expected to find existing DM %d, got %+v", dm.ID, found)
+	}
+
+	// Adding a participant turns it into a group DM, so the 1:1 lookup no longer matches
+	if err := memDB.AddChannelParticipant(dm.ID, carolID); err != nil {
+		t.Fatalf("failed to add participant: %v", err)
+	}
+	if _, ok := memDB.FindDMChannel([]int64{aliceID, bobID}); ok {
+		t.Fatal("group DM should not match a 1:1 lookup")
+	}
+	if got := memDB.ListChannelParticipants(dm.ID); len(got) != 3 {
+		t.Fatalf("expected 3 participants, got %v", got)
+	}
+
+	if got := memDB.ListUserDMChannels(carolID); len(got) != 2 {
+		t.Fatalf("expected carol to be in 2 DMs, got %d", len(got))
+	}
+	if got := memDB.ListUserDMChannels(bobID); len(got) != 1 || got[0].ID != dm.ID {
+		t.Fatalf("expected bob to be in 1 DM, got %+v", got)
+	}
+
+	// Deleting the channel drops its access list from the cache
+	if err := memDB.DeleteChannel(uint64(dm.ID)); err != nil {
+		t.Fatalf("failed to delete channel: %v", err)
+	}
+	if memDB.IsChannelParticipant(dm.ID, aliceID) {
+		t.Fatal("expected access list to be removed with its channel")
+	}
+}
+
+func TestMemDBDMEncryption(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	dm, err := memDB.CreateDMChannel(aliceID, []int64{aliceID, bobID}, true)
+	if err != nil {
+		t.Fatalf("failed to create DM channel: %v", err)
+	}
+	if !dm.IsEncrypted {
+		t.Fatal("expected DM channel to be encrypted")
+	}
+
+	// Adding the same public key twice returns the existing key
+	keyID, err := memDB.AddEncryptionKey(aliceID, 1, "alice-key", nil)
+	if err != nil {
+		t.Fatalf("failed to add encryption key: %v", err)
+	}
+	again, err := memDB.AddEncryptionKey(aliceID, 1, "alice-key", nil)
+	if err != nil {
+		t.Fatalf("failed to re-add encryption key: %v", err)
+	}
+	if again != keyID {
+		t.Fatalf("expected duplicate key to return id %d, got %d", keyID, again)
+	}
+	keys, err := memDB.ListEncryptionKeys(aliceID)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("expected 1 encryption key, got %v (%v)", keys, err)
+	}
+
+	if err := memDB.SetChannelKey(dm.ID, keyID, "wrapped"); err != nil {
+		t.Fatalf("failed to set channel key: %v", err)
+	}
+	wrapped, err := memDB.ListChannelKeys(dm.ID)
+	if err != nil || wrapped[keyID] != "wrapped" {
+		t.Fatalf("expected wrapped key for key %d, got %v (%v)", keyID, wrapped, err)
+	}
+
+	// Falling back to plaintext updates the cached channel
+	if err := memDB.SetChannelEncrypted(dm.ID, false); err != nil {
+		t.Fatalf("failed to update channel: %v", err)
+	}
+	ch, err := memDB.GetChannel(dm.ID)
+	if err != nil || ch.IsEncrypted {
+		t.Fatalf("expected cached channel to be unencrypted, got %+v (%v)", ch, err)
+	}
+
+	if err := memDB.SetAllowUnencryptedDMs(bobID, true); err != nil {
+		t.Fatalf("failed to set DM preference: %v", err)
+	}
+	if allow, err := memDB.GetAllowUnencryptedDMs(bobID); err != nil || !allow {
+		t.Fatalf("expected bob to allow unencrypted DMs, got %v (%v)", allow, err)
+	}
+}
+
+func TestMemDBSearchMessages(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	channelID, err := db.CreateChannel("general", "#general", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	dm, err := memDB.CreateDMChannel(aliceID, []int64{aliceID, bobID}, false)
+	if err != nil {
+		t.Fatalf("failed to create DM channel: %v", err)
+	}
+	encryptedDM, err := memDB.CreateDMChannel(aliceID, []int64{aliceID}, true)
+	if err != nil {
+		t.Fatalf("failed to create encrypted DM channel: %v", err)
+	}
+
+	post := func(channelID int64, parentID, authorUserID *int64, nickname, content string) int64 {
+		t.Helper()
+		id, _, err := memDB.PostMessage(channelID, nil, parentID, authorUserID, nickname, content)
+		if err != nil {
+			t.Fatalf("failed to post message: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // Distinct created_at for ordering
+		return id
+	}
+	rootID := post(channelID, nil, &aliceID, "alice", "Release notes for version two")
+	replyID := post(channelID, &rootID, nil, "guest", "The release went fine")
+	dmMsgID := post(dm.ID, nil, &aliceID, "alice", "Secret release plan")
+	post(encryptedDM.ID, nil, &aliceID, "alice", "release ciphertext")
+	post(channelID, nil, &bobID, "bob", "Unrelated chatter")
+
+	search := func(query string, filter MessageSearchFilter) []int64 {
+		t.Helper()
+		results, err := memDB.SearchMessages(query, filter, 10)
+		if err != nil {
+			t.Fatalf("search %q failed: %v", query, err)
+		}
+		ids := make([]int64, len(results))
+		for i, msg := range results {
+			ids[i] = msg.ID
+		}
+		return ids
+	}
+	expect := func(query string, filter MessageSearchFilter, want ...int64) {
+		t.Helper()
+		got := search(query, filter)
+		if len(got) != len(want) {
+			t.Fatalf("search %q: expected %v, got %v", query, want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("search %q: expected %v, got %v", query, want, got)
+			}
+		}
+	}
+
+	runSearches := func() {
+		t.Helper()
+		// Newest first; private channels need access, encrypted channels never match
+		expect("release", MessageSearchFilter{}, replyID, rootID)
+		expect("RELEASE", MessageSearchFilter{ViewerUserID: &bobID}, dmMsgID, replyID, rootID)
+		expect("rel*", MessageSearchFilter{}, replyID, rootID)
+		expect("notes version", MessageSearchFilter{}, rootID)
+		expect("release", MessageSearchFilter{ThreadRootID: &rootID}, replyID, rootID)
+		expect("release", MessageSearchFilter{AuthorUserID: &aliceID}, rootID)
+		expect("release", MessageSearchFilter{AuthorNickname: strPtr("guest")}, replyID)
+		expect("release", MessageSearchFilter{ViewerUserID: &bobID, ChannelID: &dm.ID}, dmMsgID)
+		expect("nothing-matches", MessageSearchFilter{})
+		expect("***", MessageSearchFilter{})
+	}
+
+	// Before the snapshot everything is matched in memory
+	runSearches()
+
+	// After the snapshot the same results come from the FTS index
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	indexed, err := db.SearchMessages(`"release"`, MessageSearchFilter{}, 10)
+	if err != nil {
+		t.Fatalf("FTS search failed: %v", err)
+	}
+	if len(indexed) != 2 {
+		t.Fatalf("expected 2 indexed public matches, got %d", len(indexed))
+	}
+	runSearches()
+
+	// Edits and deletes take effect immediately, and in the index after the next snapshot
+	if _, err := memDB.UpdateMessage(uint64(rootID), uint64(aliceID), "Changelog for version two", "alice"); err != nil {
+		t.Fatalf("failed to edit message: %v", err)
+	}
+	if _, err := memDB.SoftDeleteMessage(uint64(replyID), "guest"); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+	expect("release", MessageSearchFilter{})
+	expect("changelog", MessageSearchFilter{}, rootID)
+
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	if indexed, _ := db.SearchMessages(`"release"`, MessageSearchFilter{}, 10); len(indexed) != 0 {
+		t.Fatalf("expected edited and deleted messages to leave the index, got %d", len(indexed))
+	}
+	expect("changelog", MessageSearchFilter{}, rootID)
+}
+
+func TestMemDBReactions(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	channelID, err := db.CreateChannel("general", "#general", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	msgID, _, err := memDB.PostMessage(channelID, nil, nil, &aliceID, "alice", "Ship it?")
+	if err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+
+	if _, changed, err := memDB.AddReaction(msgID, aliceID, "👍"); err != nil || !changed {
+		t.Fatalf("AddReaction failed: changed=%v err=%v", changed, err)
+	}
+	if _, changed, err := memDB.AddReaction(msgID, bobID, "🎉"); err != nil || !changed {
+		t.Fatalf("AddReaction failed: changed=%v err=%v", changed, err)
+	}
+	summaries, changed, err := memDB.AddReaction(msgID, bobID, "👍")
+	if err != nil || !changed {
+		t.Fatalf("AddReaction failed: changed=%v err=%v", changed, err)
+	}
+	// Grouped by emoji in order of first use, users in the order they reacted
+	if len(summaries) != 2 || summaries[0].Emoji != "👍" || len(summaries[0].UserIDs) != 2 ||
+		summaries[0].UserIDs[0] != aliceID || summaries[0].UserIDs[1] != bobID || summaries[1].Emoji != "🎉" {
+		t.Fatalf("unexpected summaries: %+v", summaries)
+	}
+
+	// Reacting twice with the same emoji is a no-op
+	if _, changed, _ := memDB.AddReaction(msgID, aliceID, "👍"); changed {
+		t.Error("expected duplicate reaction not to change anything")
+	}
+	if _, changed, _ := memDB.RemoveReaction(msgID, aliceID, "🎉"); changed {
+		t.Error("expected removing a missing reaction not to change anything")
+	}
+	if _, _, err := memDB.AddReaction(999999, aliceID, "👍"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("expected ErrMessageNotFound, got %v", err)
+	}
+
+	// Removing the last reaction with an emoji drops it
+	summaries, changed, err = memDB.RemoveReaction(msgID, bobID, "🎉")
+	if err != nil || !changed || len(summaries) != 1 {
+		t.Fatalf("RemoveReaction failed: changed=%v err=%v summaries=%+v", changed, err, summaries)
+	}
+
+	// The number of different emoji per message is limited
+	otherID, _, err := memDB.PostMessage(channelID, nil, nil, &aliceID, "alice", "React to me")
+	if err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	for i := 0; i < maxReactionEmojis; i++ {
+		if _, _, err := memDB.AddReaction(otherID, aliceID, fmt.Sprintf("e%d", i)); err != nil {
+			t.Fatalf("AddReaction %d failed: %v", i, err)
+		}
+	}
+	if _, _, err := memDB.AddReaction(otherID, bobID, "one-too-many"); !errors.Is(err, ErrTooManyReactions) {
+		t.Errorf("expected ErrTooManyReactions, got %v", err)
+	}
+	if _, _, err := memDB.AddReaction(otherID, bobID, "e0"); err != nil {
+		t.Errorf("expected existing emoji to still be allowed, got %v", err)
+	}
+
+	// Reactions survive a snapshot and reload
+	if err := memDB.Close(); err != nil {
+		t.Fatalf("failed to close MemDB: %v", err)
+	}
+	memDB, err = NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to reload MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	summaries = memDB.GetReactions(msgID)
+	if len(summaries) != 1 || summaries[0].Emoji != "👍" || len(summaries[0].UserIDs) != 2 || summaries[0].UserIDs[0] != aliceID {
+		t.Fatalf("unexpected reactions after reload: %+v", summaries)
+	}
+	if len(memDB.GetReactions(otherID)) != maxReactionEmojis {
+		t.Errorf("expected %d reactions after reload, got %d", maxReactionEmojis, len(memDB.GetReactions(otherID)))
+	}
+
+	// Editing a message (INSERT OR REPLACE on snapshot) keeps its reactions
+	if _, err := memDB.UpdateMessage(uint64(msgID), uint64(aliceID), "Ship it!", "alice"); err != nil {
+		t.Fatalf("failed to edit message: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	var stored int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM Reaction WHERE message_id = ?", msgID).Scan(&stored); err != nil {
+		t.Fatalf("failed to count reactions: %v", err)
+	}
+	if stored != 2 {
+		t.Errorf("expected 2 stored reactions after edit, got %d", stored)
+	}
+
+	// Deleting a user removes their reactions
+	if _, err := memDB.DeleteUser(uint64(bobID)); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	summaries = memDB.GetReactions(msgID)
+	if len(summaries) != 1 || len(summaries[0].UserIDs) != 1 || summaries[0].UserIDs[0] != aliceID {
+		t.Errorf("expected only alice's reaction after deleting bob, got %+v", summaries)
+	}
+}
+
+func TestMemDBMessageVersions(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	adminID, err := db.CreateUser("admin", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	channelID, err := db.CreateChannel("general", "#general", nil, 1, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+
+	msgID, _, err := memDB.PostMessage(channelID, nil, nil, &aliceID, "alice", "v1")
+	if err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	if _, err := memDB.UpdateMessage(uint64(msgID), uint64(aliceID), "v2", "alice"); err != nil {
+		t.Fatalf("failed to edit message: %v", err)
+	}
+
+	// Versions are listed before the snapshot, and only once after it
+	checkVersions := func(want ...MessageVersion) {
+		t.Helper()
+		versions, err := memDB.ListMessageVersions(msgID)
+		if err != nil {
+			t.Fatalf("failed to list versions: %v", err)
+		}
+		if len(versions) != len(want) {
+			t.Fatalf("expected %d versions, got %d", len(want), len(versions))
+		}
+		for i, v := range versions {
+			if v.Content != want[i].Content || v.EditorNickname != want[i].EditorNickname || v.AdminEdit != want[i].AdminEdit || v.VersionType != "edited" {
+				t.Errorf("version %d: expected %+v, got %+v", i, want[i], v)
+			}
+		}
+	}
+	userEdit := MessageVersion{Content: "v1", EditorNickname: "alice"}
+	adminEdit := MessageVersion{Content: "v2", EditorNickname: "admin", AdminEdit: true}
+
+	checkVersions(userEdit)
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	checkVersions(userEdit)
+
+	// Admin edits of someone else's message are flagged
+	if _, err := memDB.AdminUpdateMessage(uint64(msgID), uint64(adminID), "v3", "admin"); err != nil {
+		t.Fatalf("failed to admin-edit message: %v", err)
+	}
+	checkVersions(userEdit, adminEdit)
+
+	// History survives the snapshot rewriting the message (INSERT OR REPLACE) and a restart
+	memDB.Close()
+	memDB, err = NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to reload MemDB: %v", err)
+	}
+	defer memDB.Close()
+	checkVersions(userEdit, adminEdit)
+
+	// Hard deleting the message removes its history
+	if err := db.DeleteMessages([]int64{msgID}); err != nil {
+		t.Fatalf("failed to delete message: %v", err)
+	}
+	if versions, _ := db.ListMessageVersions(msgID); len(versions) != 0 {
+		t.Errorf("expected history to be deleted with the message, got %d versions", len(versions))
+	}
+}
+
+// TestSnapshotSkipsDeletedChannel covers a channel deleted from SQLite by another process
+// (scd admin) while the MemDB still caches it
+func TestSnapshotSkipsDeletedChannel(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	memDB, err := NewMemDB(db, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create MemDB: %v", err)
+	}
+	defer memDB.Close()
+
+	goneID, err := memDB.CreateChannel("gone", "Gone", nil, 0, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	keptID, err := memDB.CreateChannel("kept", "Kept", nil, 0, 168, nil)
+	if err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	oldID, _, err := memDB.PostMessage(goneID, nil, nil, &aliceID, "alice", "Before")
+	if err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+
+	other, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open second handle: %v", err)
+	}
+	if err := other.DeleteChannel(uint64(goneID)); err != nil {
+		t.Fatalf("failed to delete channel: %v", err)
+	}
+	other.Close()
+
+	// Posts to the deleted channel and reactions to its old messages used to fail the
+	// whole snapshot on the foreign key
+	if _, _, err := memDB.PostMessage(goneID, nil, nil, &aliceID, "alice", "After"); err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	if _, _, err := memDB.AddReaction(oldID, aliceID, "👍"); err != nil {
+		t.Fatalf("failed to add reaction: %v", err)
+	}
+	keptMsgID, _, err := memDB.PostMessage(keptID, nil, nil, &aliceID, "alice", "Still here")
+	if err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	if err := memDB.snapshot(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+
+	if _, err := db.GetMessage(uint64(keptMsgID)); err != nil {
+		t.Errorf("expected the other channel's message to be written: %v", err)
+	}
+	if _, err := memDB.GetChannel(goneID); err == nil {
+		t.Error("expected the deleted channel to be dropped from the cache")
+	}
+	if _, err := memDB.GetMessage(oldID); err == nil {
+		t.Error("expected the deleted channel's messages to be dropped from the cache")
+	}
+}
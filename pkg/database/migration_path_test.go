@@ -326,26 +326,873 @@ func TestMigrationPath(t *testing.T) {
 			},
 		},
 
-		// WHEN ADDING MIGRATION 006:
-		/*
 		{
-			name:        "v2 → v3: Add subchannels",
-			fromVersion: 2,
-			toVersion:   3,
+			name:        "v9 → v10: Add subchannels",
+			fromVersion: 9,
+			toVersion:   10,
 			setupData: func(db *sql.DB) error {
-				// Create data that should survive subchannel addition
-				// ...
+				// Create a channel with an existing root message that should survive subchannel addition
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0)
+				`, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Message (id, channel_id, subchannel_id, parent_id, author_nickname, content, created_at)
+					VALUES (1, 1, NULL, NULL, 'alice', 'Hello before subchannels', ?)
+				`, now)
+				return err
 			},
 			validateData: func(db *sql.DB, t *testing.T) {
-				// Verify existing messages have NULL subchannel_id
-				// ...
+				// Verify existing messages still have NULL subchannel_id
+				var subchannelID sql.NullInt64
+				var content string
+				err := db.QueryRow("SELECT subchannel_id, content FROM Message WHERE id = 1").Scan(&subchannelID, &content)
+				if err != nil {
+					t.Fatalf("Failed to query message: %v", err)
+				}
+				if subchannelID.Valid {
+					t.Errorf("Expected NULL subchannel_id, got %d", subchannelID.Int64)
+				}
+				if content != "Hello before subchannels" {
+					t.Errorf("Message content changed: got %q", content)
+				}
 			},
 			validateSchema: func(db *sql.DB, t *testing.T) {
 				// Verify Subchannel table exists
-				// ...
+				var count int
+				err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='Subchannel'").Scan(&count)
+				if err != nil {
+					t.Fatalf("Failed to check Subchannel table: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Subchannel table not found after migration to v10")
+				}
+
+				// Verify columns exist
+				columns := []string{"id", "channel_id", "name", "display_name", "description", "subchannel_type", "message_retention_hours", "created_by", "created_at"}
+				for _, col := range columns {
+					var colCount int
+					err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('Subchannel') WHERE name=?", col).Scan(&colCount)
+					if err != nil {
+						t.Fatalf("Failed to check column %s: %v", col, err)
+					}
+					if colCount != 1 {
+						t.Errorf("Column %s not found in Subchannel table", col)
+					}
+				}
+
+				// Verify index exists
+				var idxCount int
+				err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='index' AND name='idx_subchannel_channel'").Scan(&idxCount)
+				if err != nil {
+					t.Fatalf("Failed to check index: %v", err)
+				}
+				if idxCount != 1 {
+					t.Errorf("Index idx_subchannel_channel not found after migration to v10")
+				}
+
+				now := time.Now().UnixMilli()
+
+				// Verify foreign key constraint (channel_id → Channel.id)
+				_, err = db.Exec(`
+					INSERT INTO Subchannel (channel_id, name, display_name, created_at)
+					VALUES (999999, 'orphan', '/orphan', ?)
+				`, now)
+				if err == nil {
+					t.Error("Expected foreign key constraint violation for invalid channel_id, got none")
+				}
+
+				// Verify (channel_id, name) uniqueness constraint
+				_, err = db.Exec(`
+					INSERT INTO Subchannel (channel_id, name, display_name, created_at)
+					VALUES (1, 'support', '/support', ?)
+				`, now)
+				if err != nil {
+					t.Fatalf("Failed to insert first subchannel: %v", err)
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Subchannel (channel_id, name, display_name, created_at)
+					VALUES (1, 'support', '/support', ?)
+				`, now)
+				if err == nil {
+					t.Error("Expected unique constraint violation for duplicate subchannel name, got none")
+				}
+
+				// Verify CASCADE delete behavior (separate channel so the seeded message survives)
+				_, err = db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private)
+					VALUES (2, 'scratch', '#scratch', 1, 168, ?, 0)
+				`, now)
+				if err != nil {
+					t.Fatalf("Failed to insert channel: %v", err)
+				}
+				_, err = db.Exec(`
+					INSERT INTO Subchannel (channel_id, name, display_name, created_at)
+					VALUES (2, 'temp', '/temp', ?)
+				`, now)
+				if err != nil {
+					t.Fatalf("Failed to insert subchannel: %v", err)
+				}
+				_, err = db.Exec(`DELETE FROM Channel WHERE id = 2`)
+				if err != nil {
+					t.Fatalf("Failed to delete channel: %v", err)
+				}
+
+				var subCount int
+				err = db.QueryRow("SELECT COUNT(*) FROM Subchannel WHERE channel_id = 2").Scan(&subCount)
+				if err != nil {
+					t.Fatalf("Failed to count subchannels: %v", err)
+				}
+				if subCount != 0 {
+					t.Errorf("Expected 0 subchannels after channel deletion (CASCADE), got %d", subCount)
+				}
+			},
+		},
+
+		{
+			name:        "v10 → v11: Add channel access for direct messages",
+			fromVersion: 10,
+			toVersion:   11,
+			setupData: func(db *sql.DB) error {
+				// Existing users and a public channel that must stay public
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO User (id, nickname, user_flags, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 0, 'hash', ?, ?), (2, 'bob', 0, 'hash', ?, ?)
+				`, now, now, now, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0)
+				`, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var isPrivate bool
+				err := db.QueryRow("SELECT is_private FROM Channel WHERE id = 1").Scan(&isPrivate)
+				if err != nil {
+					t.Fatalf("Failed to query channel: %v", err)
+				}
+				if isPrivate {
+					t.Error("Existing public channel became private after migration")
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				var count int
+				err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='ChannelAccess'").Scan(&count)
+				if err != nil {
+					t.Fatalf("Failed to check ChannelAccess table: %v", err)
+				}
+				if count != 1 {
+					t.Fatalf("ChannelAccess table not found after migration to v11")
+				}
+
+				var idxCount int
+				err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='index' AND name='idx_channel_access_user'").Scan(&idxCount)
+				if err != nil {
+					t.Fatalf("Failed to check index: %v", err)
+				}
+				if idxCount != 1 {
+					t.Errorf("Index idx_channel_access_user not found after migration to v11")
+				}
+
+				now := time.Now().UnixMilli()
+				_, err = db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private)
+					VALUES (2, 'dm-test', 'Direct message', 0, 720, ?, 1)
+				`, now)
+				if err != nil {
+					t.Fatalf("Failed to insert private channel: %v", err)
+				}
+				_, err = db.Exec(`
+					INSERT INTO ChannelAccess (channel_id, user_id, added_at)
+					VALUES (2, 1, ?), (2, 2, ?)
+				`, now, now)
+				if err != nil {
+					t.Fatalf("Failed to insert channel access: %v", err)
+				}
+
+				// Verify foreign key constraint (user_id → User.id)
+				_, err = db.Exec(`INSERT INTO ChannelAccess (channel_id, user_id, added_at) VALUES (2, 999999, ?)`, now)
+				if err == nil {
+					t.Error("Expected foreign key constraint violation for invalid user_id, got none")
+				}
+
+				// Verify CASCADE on user deletion
+				if _, err := db.Exec(`DELETE FROM User WHERE id = 2`); err != nil {
+					t.Fatalf("Failed to delete user: %v", err)
+				}
+				var accessCount int
+				if err := db.QueryRow("SELECT COUNT(*) FROM ChannelAccess WHERE channel_id = 2").Scan(&accessCount); err != nil {
+					t.Fatalf("Failed to count access rows: %v", err)
+				}
+				if accessCount != 1 {
+					t.Errorf("Expected 1 access row after user deletion (CASCADE), got %d", accessCount)
+				}
+
+				// Verify CASCADE on channel deletion
+				if _, err := db.Exec(`DELETE FROM Channel WHERE id = 2`); err != nil {
+					t.Fatalf("Failed to delete channel: %v", err)
+				}
+				if err := db.QueryRow("SELECT COUNT(*) FROM ChannelAccess WHERE channel_id = 2").Scan(&accessCount); err != nil {
+					t.Fatalf("Failed to count access rows: %v", err)
+				}
+				if accessCount != 0 {
+					t.Errorf("Expected 0 access rows after channel deletion (CASCADE), got %d", accessCount)
+				}
+			},
+		},
+
+		{
+			name:        "v11 → v12: Add DM encryption keys",
+			fromVersion: 11,
+			toVersion:   12,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO User (id, nickname, user_flags, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 0, 'hash', ?, ?), (2, 'bob', 0, 'hash', ?, ?)
+				`, now, now, now, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private)
+					VALUES (1, 'dm-1', 'Direct message', 0, 720, ?, 1)
+				`, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				// Existing DMs and users default to unencrypted / no fallback preference
+				var isEncrypted bool
+				if err := db.QueryRow("SELECT is_encrypted FROM Channel WHERE id = 1").Scan(&isEncrypted); err != nil {
+					t.Fatalf("Failed to query channel: %v", err)
+				}
+				if isEncrypted {
+					t.Error("Existing DM became encrypted after migration")
+				}
+
+				var allowUnencrypted bool
+				if err := db.QueryRow("SELECT allow_unencrypted_dms FROM User WHERE id = 1").Scan(&allowUnencrypted); err != nil {
+					t.Fatalf("Failed to query user: %v", err)
+				}
+				if allowUnencrypted {
+					t.Error("Expected allow_unencrypted_dms to default to false")
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				for _, table := range []string{"EncryptionKey", "ChannelKey"} {
+					var count int
+					err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&count)
+					if err != nil {
+						t.Fatalf("Failed to check %s table: %v", table, err)
+					}
+					if count != 1 {
+						t.Fatalf("%s table not found after migration to v12", table)
+					}
+				}
+
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO EncryptionKey (id, user_id, key_type, public_key, added_at)
+					VALUES (1, 2, 1, 'key-a', ?)
+				`, now)
+				if err != nil {
+					t.Fatalf("Failed to insert encryption key: %v", err)
+				}
+
+				// The same public key can only be registered once per user
+				_, err = db.Exec(`INSERT INTO EncryptionKey (user_id, key_type, public_key, added_at) VALUES (2, 1, 'key-a', ?)`, now)
+				if err == nil {
+					t.Error("Expected unique constraint violation for duplicate public key, got none")
+				}
+
+				_, err = db.Exec(`INSERT INTO ChannelKey (channel_id, key_id, wrapped_key, created_at) VALUES (1, 1, 'wrapped', ?)`, now)
+				if err != nil {
+					t.Fatalf("Failed to insert channel key: %v", err)
+				}
+
+				// Verify CASCADE on user deletion (EncryptionKey → ChannelKey)
+				if _, err := db.Exec(`DELETE FROM User WHERE id = 2`); err != nil {
+					t.Fatalf("Failed to delete user: %v", err)
+				}
+				var keyCount int
+				if err := db.QueryRow("SELECT COUNT(*) FROM ChannelKey WHERE channel_id = 1").Scan(&keyCount); err != nil {
+					t.Fatalf("Failed to count channel keys: %v", err)
+				}
+				if keyCount != 0 {
+					t.Errorf("Expected 0 channel keys after user deletion (CASCADE), got %d", keyCount)
+				}
+			},
+		},
+		{
+			name:        "v12 → v13: Add full-text message search",
+			fromVersion: 12,
+			toVersion:   13,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private, is_encrypted)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0, 0), (2, 'dm-2', 'Direct message', 0, 720, ?, 1, 1)
+				`, now, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Message (id, channel_id, author_nickname, content, created_at, deleted_at)
+					VALUES (1, 1, 'alice', 'searchable history', ?, NULL),
+					       (2, 1, 'alice', 'deleted history', ?, ?),
+					       (3, 2, 'alice', 'encrypted history', ?, NULL)
+				`, now, now, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				// Only the live message in the unencrypted channel is backfilled
+				rows, err := db.Query("SELECT rowid FROM MessageSearch WHERE MessageSearch MATCH 'history'")
+				if err != nil {
+					t.Fatalf("Failed to query search index: %v", err)
+				}
+				defer rows.Close()
+				var ids []int64
+				for rows.Next() {
+					var id int64
+					if err := rows.Scan(&id); err != nil {
+						t.Fatalf("Failed to scan rowid: %v", err)
+					}
+					ids = append(ids, id)
+				}
+				if len(ids) != 1 || ids[0] != 1 {
+					t.Errorf("Expected only message 1 to be indexed, got %v", ids)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				var count int
+				err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='trigger' AND name LIKE 'message_search_%'").Scan(&count)
+				if err != nil {
+					t.Fatalf("Failed to check triggers: %v", err)
+				}
+				if count != 3 {
+					t.Fatalf("Expected 3 search triggers after migration to v13, got %d", count)
+				}
+
+				// Triggers keep the index in sync with inserts, replaces and edits
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`INSERT INTO Message (id, channel_id, author_nickname, content, created_at) VALUES (10, 1, 'bob', 'fresh words', ?)`, now); err != nil {
+					t.Fatalf("Failed to insert message: %v", err)
+				}
+				if _, err := db.Exec(`INSERT OR REPLACE INTO Message (id, channel_id, author_nickname, content, created_at) VALUES (10, 1, 'bob', 'replaced words', ?)`, now); err != nil {
+					t.Fatalf("Failed to replace message: %v", err)
+				}
+				if _, err := db.Exec(`INSERT INTO Message (id, channel_id, author_nickname, content, created_at) VALUES (11, 1, 'bob', 'draft words', ?)`, now); err != nil {
+					t.Fatalf("Failed to insert message: %v", err)
+				}
+				if _, err := db.Exec(`UPDATE Message SET content = 'edited words' WHERE id = 11`); err != nil {
+					t.Fatalf("Failed to edit message: %v", err)
+				}
+
+				for query, want := range map[string]int{"fresh": 0, "replaced": 1, "draft": 0, "edited": 1, "words": 2} {
+					var matches int
+					if err := db.QueryRow("SELECT COUNT(*) FROM MessageSearch WHERE MessageSearch MATCH ?", query).Scan(&matches); err != nil {
+						t.Fatalf("Failed to query search index: %v", err)
+					}
+					if matches != want {
+						t.Errorf("Expected %d matches for %q, got %d", want, query, matches)
+					}
+				}
+			},
+		},
+		{
+			name:        "v13 → v14: Add reactions",
+			fromVersion: 13,
+			toVersion:   14,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private, is_encrypted)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0, 0)
+				`, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO User (id, nickname, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 'hash', ?, ?)
+				`, now, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Message (id, channel_id, author_nickname, content, created_at)
+					VALUES (1, 1, 'alice', 'react to me', ?), (2, 1, 'alice', 'delete me', ?)
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				// validateSchema runs first and deletes message 2; message 1 must still be there
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM Message WHERE id = 1").Scan(&count); err != nil {
+					t.Fatalf("Failed to count messages: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Expected message 1 to survive migration to v14, got %d", count)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				var count int
+				err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='Reaction'").Scan(&count)
+				if err != nil {
+					t.Fatalf("Failed to check Reaction table: %v", err)
+				}
+				if count != 1 {
+					t.Fatalf("Reaction table not found after migration to v14")
+				}
+
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`
+					INSERT INTO Reaction (message_id, user_id, emoji, created_at)
+					VALUES (1, 1, '👍', ?), (2, 1, '👍', ?)
+				`, now, now); err != nil {
+					t.Fatalf("Failed to insert reactions: %v", err)
+				}
+
+				// Replacing a message (MemDB snapshot) keeps its reactions; deleting it removes them
+				if _, err := db.Exec(`INSERT OR REPLACE INTO Message (id, channel_id, author_nickname, content, created_at) VALUES (1, 1, 'alice', 'edited', ?)`, now); err != nil {
+					t.Fatalf("Failed to replace message: %v", err)
+				}
+				if _, err := db.Exec(`DELETE FROM Message WHERE id = 2`); err != nil {
+					t.Fatalf("Failed to delete message: %v", err)
+				}
+
+				if err := db.QueryRow("SELECT COUNT(*) FROM Reaction WHERE message_id = 1").Scan(&count); err != nil {
+					t.Fatalf("Failed to count reactions: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Expected reaction to survive message replace, got %d", count)
+				}
+				if err := db.QueryRow("SELECT COUNT(*) FROM Reaction WHERE message_id = 2").Scan(&count); err != nil {
+					t.Fatalf("Failed to count reactions: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected reactions of deleted message to be removed, got %d", count)
+				}
+
+				// Deleting a user cascades to their reactions
+				if _, err := db.Exec(`DELETE FROM User WHERE id = 1`); err != nil {
+					t.Fatalf("Failed to delete user: %v", err)
+				}
+				if err := db.QueryRow("SELECT COUNT(*) FROM Reaction").Scan(&count); err != nil {
+					t.Fatalf("Failed to count reactions: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected reactions of deleted user to be removed, got %d", count)
+				}
+			},
+		},
+		{
+			name:        "v14 → v15: Add mentions",
+			fromVersion: 14,
+			toVersion:   15,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private, is_encrypted)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0, 0)
+				`, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO User (id, nickname, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 'hash', ?, ?)
+				`, now, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Message (id, channel_id, author_nickname, content, created_at)
+					VALUES (1, 1, 'bob', 'hi @alice', ?), (2, 1, 'bob', '@alice delete me', ?)
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM Message WHERE id = 1").Scan(&count); err != nil {
+					t.Fatalf("Failed to count messages: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Expected message 1 to survive migration to v15, got %d", count)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				var count int
+				err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='Mention'").Scan(&count)
+				if err != nil {
+					t.Fatalf("Failed to check Mention table: %v", err)
+				}
+				if count != 1 {
+					t.Fatalf("Mention table not found after migration to v15")
+				}
+
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`
+					INSERT INTO Mention (message_id, user_id, channel_id, created_at)
+					VALUES (1, 1, 1, ?), (2, 1, 1, ?)
+				`, now, now); err != nil {
+					t.Fatalf("Failed to insert mentions: %v", err)
+				}
+
+				// Replacing a message (MemDB snapshot) keeps its mentions; deleting it removes them
+				if _, err := db.Exec(`INSERT OR REPLACE INTO Message (id, channel_id, author_nickname, content, created_at) VALUES (1, 1, 'bob', 'hi @alice!', ?)`, now); err != nil {
+					t.Fatalf("Failed to replace message: %v", err)
+				}
+				if _, err := db.Exec(`DELETE FROM Message WHERE id = 2`); err != nil {
+					t.Fatalf("Failed to delete message: %v", err)
+				}
+
+				if err := db.QueryRow("SELECT COUNT(*) FROM Mention WHERE message_id = 1").Scan(&count); err != nil {
+					t.Fatalf("Failed to count mentions: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Expected mention to survive message replace, got %d", count)
+				}
+				if err := db.QueryRow("SELECT COUNT(*) FROM Mention WHERE message_id = 2").Scan(&count); err != nil {
+					t.Fatalf("Failed to count mentions: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected mentions of deleted message to be removed, got %d", count)
+				}
+
+				// Deleting the mentioned user cascades to their mentions
+				if _, err := db.Exec(`DELETE FROM User WHERE id = 1`); err != nil {
+					t.Fatalf("Failed to delete user: %v", err)
+				}
+				if err := db.QueryRow("SELECT COUNT(*) FROM Mention").Scan(&count); err != nil {
+					t.Fatalf("Failed to count mentions: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected mentions of deleted user to be removed, got %d", count)
+				}
+			},
+		},
+		{
+			name:        "v15 → v16: Message edit history",
+			fromVersion: 15,
+			toVersion:   16,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private, is_encrypted)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0, 0)
+				`, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO Message (id, channel_id, author_nickname, content, created_at, edited_at)
+					VALUES (1, 1, 'alice', 'hello', ?, ?), (2, 1, 'alice', 'bye', ?, ?)
+				`, now, now, now, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO MessageVersion (message_id, content, author_nickname, created_at, version_type)
+					VALUES (1, 'helo', 'alice', ?, 'edited'), (2, 'by', 'alice', ?, 'edited')
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var content, editor string
+				var adminEdit bool
+				err := db.QueryRow("SELECT content, COALESCE(editor_nickname, ''), admin_edit FROM MessageVersion WHERE message_id = 1").Scan(&content, &editor, &adminEdit)
+				if err != nil {
+					t.Fatalf("Failed to read message version: %v", err)
+				}
+				if content != "helo" || editor != "" || adminEdit {
+					t.Errorf("Unexpected migrated version: content=%q editor=%q admin=%v", content, editor, adminEdit)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				now := time.Now().UnixMilli()
+
+				// Replacing a message (MemDB snapshot) keeps its history; deleting it removes it
+				if _, err := db.Exec(`INSERT OR REPLACE INTO Message (id, channel_id, author_nickname, content, created_at) VALUES (1, 1, 'alice', 'hello!', ?)`, now); err != nil {
+					t.Fatalf("Failed to replace message: %v", err)
+				}
+				if _, err := db.Exec(`DELETE FROM Message WHERE id = 2`); err != nil {
+					t.Fatalf("Failed to delete message: %v", err)
+				}
+
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM MessageVersion WHERE message_id = 1").Scan(&count); err != nil {
+					t.Fatalf("Failed to count versions: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Expected history to survive message replace, got %d versions", count)
+				}
+				if err := db.QueryRow("SELECT COUNT(*) FROM MessageVersion WHERE message_id = 2").Scan(&count); err != nil {
+					t.Fatalf("Failed to count versions: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected history of deleted message to be removed, got %d versions", count)
+				}
+			},
+		},
+		{
+			name:        "v16 → v17: Channel-scoped moderators",
+			fromVersion: 16,
+			toVersion:   17,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at, is_private, is_encrypted)
+					VALUES (1, 'general', '#general', 1, 168, ?, 0, 0)
+				`, now)
+				if err != nil {
+					return err
+				}
+
+				_, err = db.Exec(`
+					INSERT INTO User (id, nickname, user_flags, password_hash, created_at, last_seen)
+					VALUES (1, 'mod', 2, 'hash', ?, ?)
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var flags int
+				if err := db.QueryRow("SELECT user_flags FROM User WHERE id = 1").Scan(&flags); err != nil {
+					t.Fatalf("Failed to read user: %v", err)
+				}
+				if flags != 2 {
+					t.Errorf("Expected user flags to survive migration to v17, got %d", flags)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				if _, err := db.Exec(`INSERT INTO ModeratorChannel (user_id, channel_id) VALUES (1, 1)`); err != nil {
+					t.Fatalf("Failed to insert moderator channel: %v", err)
+				}
+
+				// Deleting the channel cascades to the scope
+				if _, err := db.Exec(`DELETE FROM Channel WHERE id = 1`); err != nil {
+					t.Fatalf("Failed to delete channel: %v", err)
+				}
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM ModeratorChannel").Scan(&count); err != nil {
+					t.Fatalf("Failed to count moderator channels: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected scope of deleted channel to be removed, got %d", count)
+				}
+			},
+		},
+		{
+			name:        "v17 → v18: Login failures",
+			fromVersion: 17,
+			toVersion:   18,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO User (id, nickname, user_flags, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 0, 'hash', ?, ?)
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var nickname string
+				if err := db.QueryRow("SELECT nickname FROM User WHERE id = 1").Scan(&nickname); err != nil {
+					t.Fatalf("Failed to read user: %v", err)
+				}
+				if nickname != "alice" {
+					t.Errorf("Expected user to survive migration to v18, got %q", nickname)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`
+					INSERT INTO LoginFailure (kind, identifier, failures, last_failure_at, locked_until)
+					VALUES ('user', 'alice', 5, ?, ?), ('ip', '192.0.2.1', 1, ?, NULL)
+				`, now, now+60000, now); err != nil {
+					t.Fatalf("Failed to insert login failures: %v", err)
+				}
+
+				// Only users and IPs are tracked
+				if _, err := db.Exec(`
+					INSERT INTO LoginFailure (kind, identifier, failures, last_failure_at)
+					VALUES ('session', '1', 1, ?)
+				`, now); err == nil {
+					t.Error("Expected unknown kind to be rejected")
+				}
+			},
+		},
+		{
+			name:        "v18 → v19: Two-factor authentication",
+			fromVersion: 18,
+			toVersion:   19,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO User (id, nickname, user_flags, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 0, 'hash', ?, ?)
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM TwoFactor").Scan(&count); err != nil {
+					t.Fatalf("Failed to count TwoFactor rows: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected nobody to have 2FA after migrating, got %d", count)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`
+					INSERT INTO TwoFactor (user_id, secret, enabled_at) VALUES (1, 'JBSWY3DPEHPK3PXP', ?)
+				`, now); err != nil {
+					t.Fatalf("Failed to insert TwoFactor: %v", err)
+				}
+				if _, err := db.Exec(`
+					INSERT INTO TwoFactorRecoveryCode (user_id, code_hash) VALUES (1, 'a'), (1, 'b')
+				`); err != nil {
+					t.Fatalf("Failed to insert recovery codes: %v", err)
+				}
+
+				// Deleting the user deletes their secret and codes
+				if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+					t.Fatalf("Failed to enable foreign keys: %v", err)
+				}
+				if _, err := db.Exec("DELETE FROM User WHERE id = 1"); err != nil {
+					t.Fatalf("Failed to delete user: %v", err)
+				}
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM TwoFactorRecoveryCode").Scan(&count); err != nil {
+					t.Fatalf("Failed to count recovery codes: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected recovery codes to be deleted with the user, got %d", count)
+				}
+			},
+		},
+		{
+			name:        "v19 → v20: User profiles",
+			fromVersion: 19,
+			toVersion:   20,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				_, err := db.Exec(`
+					INSERT INTO User (id, nickname, user_flags, password_hash, created_at, last_seen)
+					VALUES (1, 'alice', 0, 'hash', ?, ?)
+				`, now, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM UserProfile").Scan(&count); err != nil {
+					t.Fatalf("Failed to count UserProfile rows: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected nobody to have a profile after migrating, got %d", count)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`
+					INSERT INTO UserProfile (user_id, display_name, updated_at) VALUES (1, 'Alice', ?)
+				`, now); err != nil {
+					t.Fatalf("Failed to insert UserProfile: %v", err)
+				}
+				var bio, status string
+				if err := db.QueryRow("SELECT bio, status_text FROM UserProfile WHERE user_id = 1").Scan(&bio, &status); err != nil {
+					t.Fatalf("Failed to read profile: %v", err)
+				}
+				if bio != "" || status != "" {
+					t.Errorf("Expected empty defaults, got bio=%q status=%q", bio, status)
+				}
+
+				// Deleting the user deletes their profile
+				if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+					t.Fatalf("Failed to enable foreign keys: %v", err)
+				}
+				if _, err := db.Exec("DELETE FROM User WHERE id = 1"); err != nil {
+					t.Fatalf("Failed to delete user: %v", err)
+				}
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM UserProfile").Scan(&count); err != nil {
+					t.Fatalf("Failed to count profiles: %v", err)
+				}
+				if count != 0 {
+					t.Errorf("Expected profile to be deleted with the user, got %d", count)
+				}
+			},
+		},
+		{
+			name:        "v20 → v21: Channel bans",
+			fromVersion: 20,
+			toVersion:   21,
+			setupData: func(db *sql.DB) error {
+				now := time.Now().UnixMilli()
+				if _, err := db.Exec(`
+					INSERT INTO Channel (id, name, display_name, channel_type, message_retention_hours, created_at)
+					VALUES (1, 'general', 'General', 0, 168, ?)
+				`, now); err != nil {
+					return err
+				}
+				_, err := db.Exec(`
+					INSERT INTO Ban (ban_type, nickname, reason, banned_at, banned_by)
+					VALUES ('user', 'mallory', 'spam', ?, 'admin')
+				`, now)
+				return err
+			},
+			validateData: func(db *sql.DB, t *testing.T) {
+				var channelID sql.NullInt64
+				if err := db.QueryRow("SELECT channel_id FROM Ban WHERE nickname = 'mallory'").Scan(&channelID); err != nil {
+					t.Fatalf("Failed to read ban: %v", err)
+				}
+				if channelID.Valid {
+					t.Errorf("Expected existing bans to stay server-wide, got channel %d", channelID.Int64)
+				}
+			},
+			validateSchema: func(db *sql.DB, t *testing.T) {
+				if _, err := db.Exec(`
+					INSERT INTO Ban (ban_type, nickname, reason, banned_at, banned_by, channel_id)
+					VALUES ('user', 'eve', 'spam', ?, 'mod', 1)
+				`, time.Now().UnixMilli()); err != nil {
+					t.Fatalf("Failed to insert channel ban: %v", err)
+				}
+
+				// Deleting the channel deletes its bans
+				if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+					t.Fatalf("Failed to enable foreign keys: %v", err)
+				}
+				if _, err := db.Exec("DELETE FROM Channel WHERE id = 1"); err != nil {
+					t.Fatalf("Failed to delete channel: %v", err)
+				}
+				var count int
+				if err := db.QueryRow("SELECT COUNT(*) FROM Ban").Scan(&count); err != nil {
+					t.Fatalf("Failed to count bans: %v", err)
+				}
+				if count != 1 {
+					t.Errorf("Expected only the server-wide ban to be left, got %d bans", count)
+				}
 			},
 		},
-		*/
 	}
 
 	for _, tt := range migrationTests {
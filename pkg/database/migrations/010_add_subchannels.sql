@@ -0,0 +1,21 @@
+-- Migration 010: Add Subchannel table
+-- Channels can optionally be split into subchannels (two-level hierarchy, see docs/versions/V3.md).
+-- Each subchannel carries its own type and retention, which take precedence over the parent channel's.
+-- Message.subchannel_id and UserChannelState.subchannel_id already exist and reference Subchannel.id.
+
+CREATE TABLE IF NOT EXISTS Subchannel (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	channel_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	display_name TEXT NOT NULL,
+	description TEXT,
+	subchannel_type INTEGER NOT NULL DEFAULT 1,  -- 0=chat, 1=forum
+	message_retention_hours INTEGER NOT NULL DEFAULT 168,
+	created_by INTEGER,                          -- NULL if creator account was deleted
+	created_at INTEGER NOT NULL,                 -- Unix timestamp (milliseconds)
+	FOREIGN KEY (channel_id) REFERENCES Channel(id) ON DELETE CASCADE,
+	FOREIGN KEY (created_by) REFERENCES User(id) ON DELETE SET NULL,
+	UNIQUE(channel_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subchannel_channel ON Subchannel(channel_id);
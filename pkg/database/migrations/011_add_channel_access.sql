@@ -0,0 +1,16 @@
+-- Migration 011: Add ChannelAccess table for direct messages
+-- DM conversations are stored as private channels (Channel.is_private = 1) and never
+-- appear in the public channel list. Only users listed in ChannelAccess may read or
+-- post in a private channel.
+
+CREATE TABLE IF NOT EXISTS ChannelAccess (
+	channel_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	added_at INTEGER NOT NULL,                   -- Unix timestamp (milliseconds)
+	PRIMARY KEY (channel_id, user_id),
+	FOREIGN KEY (channel_id) REFERENCES Channel(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+);
+
+-- Index for listing a user's DM conversations
+CREATE INDEX IF NOT EXISTS idx_channel_access_user ON ChannelAccess(user_id);
@@ -0,0 +1,35 @@
+-- Migration 012: Add end-to-end encryption for direct messages
+-- Each encrypted DM has an AES-256 channel key generated by a participant's client.
+-- The server never sees that key: it only stores copies wrapped for each participant
+-- public key (X25519), and message content in encrypted DMs is stored as ciphertext.
+
+-- Channel.is_encrypted marks DMs whose messages are end-to-end encrypted
+ALTER TABLE Channel ADD COLUMN is_encrypted INTEGER NOT NULL DEFAULT 0;
+
+-- User preference to accept unencrypted DMs without being asked (ALLOW_UNENCRYPTED permanent)
+ALTER TABLE User ADD COLUMN allow_unencrypted_dms INTEGER NOT NULL DEFAULT 0;
+
+-- Public keys that channel keys can be wrapped for (uploaded or derived from ed25519 SSH keys)
+CREATE TABLE IF NOT EXISTS EncryptionKey (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	key_type INTEGER NOT NULL,                   -- 0=derived from SSH key, 1=generated, 2=ephemeral
+	public_key TEXT NOT NULL,                    -- Base64-encoded X25519 public key
+	label TEXT,
+	added_at INTEGER NOT NULL,                   -- Unix timestamp (milliseconds)
+	UNIQUE(user_id, public_key),
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_encryption_key_user ON EncryptionKey(user_id);
+
+-- Channel key wrapped for one participant public key
+CREATE TABLE IF NOT EXISTS ChannelKey (
+	channel_id INTEGER NOT NULL,
+	key_id INTEGER NOT NULL,
+	wrapped_key TEXT NOT NULL,                   -- Base64: ephemeral pubkey + nonce + ciphertext
+	created_at INTEGER NOT NULL,                 -- Unix timestamp (milliseconds)
+	PRIMARY KEY (channel_id, key_id),
+	FOREIGN KEY (channel_id) REFERENCES Channel(id) ON DELETE CASCADE,
+	FOREIGN KEY (key_id) REFERENCES EncryptionKey(id) ON DELETE CASCADE
+);
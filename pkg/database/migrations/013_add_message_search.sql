@@ -0,0 +1,41 @@
+-- Migration 013: Add full-text message search
+-- MessageSearch is an FTS5 index over message content, keyed by Message.id (rowid).
+-- Triggers keep it in sync with every write to Message, so inserts from the WriteBuffer
+-- and the MemDB snapshot (INSERT OR REPLACE) are indexed without extra bookkeeping.
+-- Deleted messages and messages in end-to-end encrypted channels are never indexed.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS MessageSearch USING fts5(
+	content,
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- Index existing messages
+INSERT INTO MessageSearch (rowid, content)
+SELECT m.id, m.content
+FROM Message m
+JOIN Channel c ON c.id = m.channel_id
+WHERE m.deleted_at IS NULL AND c.is_encrypted = 0;
+
+-- INSERT OR REPLACE doesn't fire delete triggers, so inserts clear any stale entry first
+CREATE TRIGGER IF NOT EXISTS message_search_insert AFTER INSERT ON Message
+BEGIN
+	DELETE FROM MessageSearch WHERE rowid = new.id;
+	INSERT INTO MessageSearch (rowid, content)
+	SELECT new.id, new.content
+	WHERE new.deleted_at IS NULL
+	  AND NOT EXISTS (SELECT 1 FROM Channel WHERE id = new.channel_id AND is_encrypted = 1);
+END;
+
+CREATE TRIGGER IF NOT EXISTS message_search_update AFTER UPDATE OF content, deleted_at ON Message
+BEGIN
+	DELETE FROM MessageSearch WHERE rowid = old.id;
+	INSERT INTO MessageSearch (rowid, content)
+	SELECT new.id, new.content
+	WHERE new.deleted_at IS NULL
+	  AND NOT EXISTS (SELECT 1 FROM Channel WHERE id = new.channel_id AND is_encrypted = 1);
+END;
+
+CREATE TRIGGER IF NOT EXISTS message_search_delete AFTER DELETE ON Message
+BEGIN
+	DELETE FROM MessageSearch WHERE rowid = old.id;
+END;
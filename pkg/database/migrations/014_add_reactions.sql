@@ -0,0 +1,25 @@
+-- Migration 014: Add emoji reactions on messages
+-- Each row is one user's reaction to a message with one emoji. The MemDB keeps reactions
+-- aggregated per message and rewrites a message's rows on snapshot.
+-- message_id deliberately has no foreign key: the MemDB snapshot writes messages with
+-- INSERT OR REPLACE, which would cascade and wipe the reactions of every edited message.
+-- The delete trigger below removes reactions of messages that are really deleted.
+
+CREATE TABLE IF NOT EXISTS Reaction (
+	message_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	emoji TEXT NOT NULL,
+	created_at INTEGER NOT NULL,                 -- Unix timestamp (milliseconds)
+	PRIMARY KEY (message_id, user_id, emoji),
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+);
+
+-- Index for removing a deleted user's reactions
+CREATE INDEX IF NOT EXISTS idx_reaction_user ON Reaction(user_id);
+
+-- Delete triggers don't fire for INSERT OR REPLACE (recursive_triggers is off), only for
+-- real deletes such as hard deletes and channel deletion cascades
+CREATE TRIGGER IF NOT EXISTS reaction_message_delete AFTER DELETE ON Message
+BEGIN
+	DELETE FROM Reaction WHERE message_id = old.id;
+END;
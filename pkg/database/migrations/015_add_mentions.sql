@@ -0,0 +1,26 @@
+-- Migration 015: Add @mentions
+-- Each row is one registered user mentioned in one message. Mentions are unread until the
+-- user marks them read (read_at) or reads the channel past them (UserChannelState.last_read_at).
+-- message_id has no foreign key for the same reason as Reaction: mentions are written when the
+-- message is posted, before the MemDB snapshot has written the message itself.
+
+CREATE TABLE IF NOT EXISTS Mention (
+	message_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,                    -- Mentioned user
+	channel_id INTEGER NOT NULL,
+	subchannel_id INTEGER NOT NULL DEFAULT 0,    -- 0 for main channel, matches UserChannelState
+	created_at INTEGER NOT NULL,                 -- Message created_at (Unix milliseconds)
+	read_at INTEGER,                             -- NULL until explicitly marked read
+	PRIMARY KEY (message_id, user_id),
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE,
+	FOREIGN KEY (channel_id) REFERENCES Channel(id) ON DELETE CASCADE
+);
+
+-- Index for listing a user's mentions, newest first
+CREATE INDEX IF NOT EXISTS idx_mention_user ON Mention(user_id, message_id DESC);
+
+-- Remove mentions of hard-deleted messages (see 014_add_reactions.sql)
+CREATE TRIGGER IF NOT EXISTS mention_message_delete AFTER DELETE ON Message
+BEGIN
+	DELETE FROM Mention WHERE message_id = old.id;
+END;
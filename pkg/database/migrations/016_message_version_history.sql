@@ -0,0 +1,37 @@
+-- @foreign_keys=off
+-- Migration 016: Message edit history
+-- MessageVersion rows are now written by the MemDB snapshot, which writes messages with
+-- INSERT OR REPLACE. The message_id foreign key would cascade and wipe the history of every
+-- edited message, so it is replaced by a delete trigger (like Reaction and Mention).
+-- Edits also record who made them: an edit row's content is the content before the edit,
+-- editor_nickname who edited it and admin_edit whether an admin edited someone else's message.
+
+-- 1. Create new table without the message foreign key
+CREATE TABLE IF NOT EXISTS MessageVersion_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	author_nickname TEXT NOT NULL,
+	created_at INTEGER NOT NULL,                 -- Unix timestamp (milliseconds)
+	version_type TEXT NOT NULL,                  -- 'created', 'edited' or 'deleted'
+	editor_nickname TEXT,                        -- NULL for rows from before this migration
+	admin_edit INTEGER NOT NULL DEFAULT 0
+);
+
+-- 2. Copy existing history
+INSERT INTO MessageVersion_new (id, message_id, content, author_nickname, created_at, version_type)
+SELECT id, message_id, content, author_nickname, created_at, version_type FROM MessageVersion;
+
+-- 3. Swap tables
+DROP TABLE MessageVersion;
+ALTER TABLE MessageVersion_new RENAME TO MessageVersion;
+
+-- Index for loading a message's history
+CREATE INDEX IF NOT EXISTS idx_message_version_message ON MessageVersion(message_id, created_at);
+
+-- Delete triggers don't fire for INSERT OR REPLACE (recursive_triggers is off), only for
+-- real deletes such as hard deletes and channel deletion cascades
+CREATE TRIGGER IF NOT EXISTS message_version_message_delete AFTER DELETE ON Message
+BEGIN
+	DELETE FROM MessageVersion WHERE message_id = old.id;
+END;
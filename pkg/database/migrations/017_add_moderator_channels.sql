@@ -0,0 +1,11 @@
+-- Migration 017: Channel-scoped moderators
+-- A moderator (user_flags bit 0x02) with rows here can only moderate messages in those
+-- channels. A moderator without any rows moderates every channel.
+
+CREATE TABLE IF NOT EXISTS ModeratorChannel (
+	user_id INTEGER NOT NULL,
+	channel_id INTEGER NOT NULL,
+	PRIMARY KEY (user_id, channel_id),
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE,
+	FOREIGN KEY (channel_id) REFERENCES Channel(id) ON DELETE CASCADE
+);
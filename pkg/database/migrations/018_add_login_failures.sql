@@ -0,0 +1,14 @@
+-- Migration 018: Login brute-force protection
+-- Failed logins are counted per account and per IP address. Once either reaches its
+-- limit it is locked out for a while, longer after every further failure.
+
+CREATE TABLE IF NOT EXISTS LoginFailure (
+	kind TEXT NOT NULL CHECK(kind IN ('user', 'ip')),
+	identifier TEXT NOT NULL,        -- Nickname or IP address
+	failures INTEGER NOT NULL,       -- Failures since the last success (or quiet period)
+	last_failure_at INTEGER NOT NULL, -- Unix timestamp (milliseconds)
+	locked_until INTEGER,            -- NULL if not locked, Unix timestamp (milliseconds)
+	PRIMARY KEY (kind, identifier)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failure_locked ON LoginFailure(locked_until) WHERE locked_until IS NOT NULL;
@@ -0,0 +1,19 @@
+-- Migration 019: TOTP two-factor authentication for password logins
+-- A user with a row here must enter a code from their authenticator app (or one of
+-- their recovery codes) after the password. SSH key logins don't ask for a code.
+
+CREATE TABLE IF NOT EXISTS TwoFactor (
+	user_id INTEGER PRIMARY KEY,
+	secret TEXT NOT NULL,            -- Base32 TOTP secret (RFC 6238, SHA-1, 6 digits, 30 seconds)
+	last_used_step INTEGER NOT NULL DEFAULT 0, -- Time step of the last accepted code, so codes can't be replayed
+	enabled_at INTEGER NOT NULL,     -- Unix timestamp (milliseconds)
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+);
+
+-- One-time codes for logging in without the authenticator, deleted when used
+CREATE TABLE IF NOT EXISTS TwoFactorRecoveryCode (
+	user_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL,         -- Hex SHA-256 of the code
+	PRIMARY KEY (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Migration 020: Profiles for registered users
+-- Shown in the user info and presence messages. Away state isn't stored; the server
+-- derives it from session activity.
+
+CREATE TABLE IF NOT EXISTS UserProfile (
+	user_id INTEGER PRIMARY KEY,
+	display_name TEXT NOT NULL DEFAULT '',
+	pronouns TEXT NOT NULL DEFAULT '',
+	bio TEXT NOT NULL DEFAULT '',
+	status_text TEXT NOT NULL DEFAULT '', -- Free-form status, e.g. "in a meeting"
+	updated_at INTEGER NOT NULL,          -- Unix timestamp (milliseconds)
+	FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- Migration 021: Channel bans
+-- A user ban with a channel_id only keeps the user from posting in that channel (what a
+-- moderator limited to some channels can issue). NULL keeps applying to the whole server.
+
+ALTER TABLE Ban ADD COLUMN channel_id INTEGER REFERENCES Channel(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_ban_channel ON Ban(channel_id) WHERE channel_id IS NOT NULL;
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchTerm is one whitespace-separated word (or punctuated phrase) of a search query
+type searchTerm struct {
+	tokens []string
+	prefix bool // Trailing * - the last token matches as a prefix
+}
+
+// tokenize splits text into lowercase words the same way the FTS5 unicode61 tokenizer does
+// (diacritics aside): anything that isn't a letter or a digit separates words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// parseSearchQuery parses a user search query. Every term must match; a term ending
+// in * matches as a prefix. Terms without any letters or digits are ignored.
+func parseSearchQuery(query string) []searchTerm {
+	var terms []searchTerm
+	for _, field := range strings.Fields(query) {
+		prefix := strings.HasSuffix(field, "*")
+		tokens := tokenize(strings.TrimRight(field, "*"))
+		if len(tokens) == 0 {
+			continue
+		}
+		terms = append(terms, searchTerm{tokens: tokens, prefix: prefix})
+	}
+	return terms
+}
+
+// ftsQuery builds an FTS5 MATCH expression from parsed terms. Tokens only contain
+// letters and digits, so quoting them as phrases can't inject FTS5 syntax.
+func ftsQuery(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		part := `"` + strings.Join(term.tokens, " ") + `"`
+		if term.prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// matchesSearch reports whether content matches every term, for messages that
+// haven't reached the FTS index yet
+func matchesSearch(content string, terms []searchTerm) bool {
+	words := tokenize(content)
+	for _, term := range terms {
+		if !containsPhrase(words, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPhrase reports whether the term's tokens appear consecutively in words
+func containsPhrase(words []string, term searchTerm) bool {
+	last := len(term.tokens) - 1
+	for start := 0; start+last < len(words); start++ {
+		match := true
+		for i, token := range term.tokens {
+			word := words[start+i]
+			if i == last && term.prefix {
+				match = strings.HasPrefix(word, token)
+			} else {
+				match = word == token
+			}
+			if !match {
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Frame payload compression
+//
+// FlagCompressed means the payload travels LZ4-compressed on the wire:
+//   [Uncompressed Size (u32)][LZ4 block]
+//
+// Frame.Payload always holds the uncompressed payload. EncodeFrame compresses it when
+// the flag is set and DecodeFrame decompresses it again, leaving the flag in place.
+// Compression is opt-in per connection: the server advertises support in SERVER_CONFIG
+// and a client turns it on with SET_COMPRESSION.
+
+const (
+	// CompressionThreshold is the payload size from which frames are compressed
+	CompressionThreshold = 512
+
+	lz4MinMatch     = 4
+	lz4HashLog      = 12
+	lz4LastLiterals = 5  // the last 5 bytes of a block are always literals
+	lz4MFLimit      = 12 // a match can't start within the last 12 bytes
+	lz4MaxOffset    = 65535
+)
+
+var ErrDecompressionFailed = errors.New("failed to decompress payload")
+
+// CompressFrame marks a frame for compression if its payload reaches CompressionThreshold.
+// The frame is copied rather than modified, since callers may share it between connections.
+func CompressFrame(f *Frame) *Frame {
+	if len(f.Payload) < CompressionThreshold || f.Flags&FlagCompressed != 0 {
+		return f
+	}
+	compressed := *f
+	compressed.Flags |= FlagCompressed
+	return &compressed
+}
+
+// CompressPayload compresses a payload into the compressed payload format
+func CompressPayload(payload []byte) []byte {
+	out := make([]byte, 4, 4+len(payload)+len(payload)/255+16)
+	binary.BigEndian.PutUint32(out, uint32(len(payload)))
+	return lz4CompressBlock(out, payload)
+}
+
+// DecompressPayload decompresses a payload in the compressed payload format
+func DecompressPayload(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrDecompressionFailed
+	}
+	size := binary.BigEndian.Uint32(data)
+	if size > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	return lz4DecompressBlock(data[4:], int(size))
+}
+
+// lz4CompressBlock appends the LZ4 block encoding of src to dst (greedy, single hash table)
+func lz4CompressBlock(dst, src []byte) []byte {
+	var table [1 << lz4HashLog]int32 // position + 1 of the last occurrence of each hash
+	anchor := 0
+
+	for i := 0; i+lz4MFLimit < len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashLog)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+
+		if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+
+		end := i + lz4MinMatch
+		for end < len(src)-lz4LastLiterals && src[end] == src[ref+end-i] {
+			end++
+		}
+
+		dst = lz4AppendSequence(dst, src[anchor:i], i-ref, end-i)
+		i = end
+		anchor = end
+	}
+
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4AppendSequence appends one sequence. A zero offset writes the final, literal-only sequence.
+func lz4AppendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	litLen := len(literals)
+	token := byte(min(litLen, 15)) << 4
+	if offset > 0 {
+		token |= byte(min(matchLen-lz4MinMatch, 15))
+	}
+
+	dst = append(dst, token)
+	if litLen >= 15 {
+		dst = lz4AppendLength(dst, litLen-15)
+	}
+	dst = append(dst, literals...)
+
+	if offset > 0 {
+		dst = append(dst, byte(offset), byte(offset>>8))
+		if matchLen-lz4MinMatch >= 15 {
+			dst = lz4AppendLength(dst, matchLen-lz4MinMatch-15)
+		}
+	}
+	return dst
+}
+
+func lz4AppendLength(dst []byte, n int) []byte {
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
+
+// lz4DecompressBlock decodes an LZ4 block that must expand to exactly size bytes
+func lz4DecompressBlock(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			n, next, err := lz4ReadLength(src, i, size)
+			if err != nil {
+				return nil, err
+			}
+			litLen += n
+			i = next
+		}
+		if litLen > len(src)-i || litLen > size-len(dst) {
+			return nil, ErrDecompressionFailed
+		}
+		dst = append(dst, src[i:i+litLen]...)
+		i += litLen
+
+		// The last sequence has no match part
+		if i == len(src) {
+			break
+		}
+
+		if len(src)-i < 2 {
+			return nil, ErrDecompressionFailed
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, ErrDecompressionFailed
+		}
+
+		matchLen := int(token&0x0F) + lz4MinMatch
+		if token&0x0F == 15 {
+			n, next, err := lz4ReadLength(src, i, size)
+			if err != nil {
+				return nil, err
+			}
+			matchLen += n
+			i = next
+		}
+		if matchLen > size-len(dst) {
+			return nil, ErrDecompressionFailed
+		}
+
+		// Byte-by-byte copy: matches may overlap the bytes they produce
+		start := len(dst) - offset
+		for j := 0; j < matchLen; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+
+	if len(dst) != size {
+		return nil, ErrDecompressionFailed
+	}
+	return dst, nil
+}
+
+// lz4ReadLength reads an extended length (a run of 255s terminated by a smaller byte)
+func lz4ReadLength(src []byte, i, limit int) (int, int, error) {
+	n := 0
+	for {
+		if i >= len(src) || n > limit {
+			return 0, 0, ErrDecompressionFailed
+		}
+		b := src[i]
+		i++
+		n += int(b)
+		if b != 255 {
+			return n, i, nil
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestCompressPayloadRoundTrip(t *testing.T) {
+	random := make([]byte, 4096)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"empty", []byte{}},
+		{"short", []byte("hi")},
+		{"below match limit", []byte("abcabcabcab")},
+		{"repetitive text", []byte(strings.Repeat("the quick brown fox ", 200))},
+		{"long run", bytes.Repeat([]byte{'a'}, 70000)},
+		{"long literals", random},
+		{"mixed", append(append([]byte{}, random[:300]...), bytes.Repeat([]byte("xyz"), 500)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := CompressPayload(tt.payload)
+			decompressed, err := DecompressPayload(compressed)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.payload), len(decompressed))
+			assert.True(t, bytes.Equal(tt.payload, decompressed))
+		})
+	}
+}
+
+func TestCompressPayloadShrinksRepetitiveData(t *testing.T) {
+	payload := []byte(strings.Repeat("message content ", 100))
+	compressed := CompressPayload(payload)
+	assert.Less(t, len(compressed), len(payload)/4)
+}
+
+func TestDecompressReferenceBlock(t *testing.T) {
+	// "abcabcabcabcabcabcabc" as produced by the reference LZ4 implementation:
+	// 3 literals, then a match at offset 3 for 18 bytes
+	block := []byte{
+		0x00, 0x00, 0x00, 0x15, // uncompressed size 21
+		0x3E, 'a', 'b', 'c', 0x03, 0x00, 0x00, // token: 3 literals, match length 14+4 = 18
+	}
+	decompressed, err := DecompressPayload(block)
+	require.NoError(t, err)
+	assert.Equal(t, "abcabcabcabcabcabcabc", string(decompressed))
+}
+
+func TestDecompressPayloadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"missing size", []byte{0x00, 0x01}, ErrDecompressionFailed},
+		{"size too large", []byte{0x00, 0x20, 0x00, 0x00, 0x00}, ErrFrameTooLarge},
+		{"truncated literals", []byte{0x00, 0x00, 0x00, 0x05, 0x50, 'a', 'b'}, ErrDecompressionFailed},
+		{"size mismatch", []byte{0x00, 0x00, 0x00, 0x05, 0x20, 'a', 'b'}, ErrDecompressionFailed},
+		{"offset before start", []byte{0x00, 0x00, 0x00, 0x10, 0x10, 'a', 0x05, 0x00}, ErrDecompressionFailed},
+		{"zero offset", []byte{0x00, 0x00, 0x00, 0x10, 0x10, 'a', 0x00, 0x00}, ErrDecompressionFailed},
+		{"match exceeds size", []byte{0x00, 0x00, 0x00, 0x03, 0x10, 'a', 0x01, 0x00}, ErrDecompressionFailed},
+		{"truncated length", []byte{0x00, 0x00, 0x00, 0x20, 0xF0, 0xFF}, ErrDecompressionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecompressPayload(tt.data)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestCompressFrame(t *testing.T) {
+	small := &Frame{Version: 1, Type: TypeMessageList, Payload: make([]byte, CompressionThreshold-1)}
+	assert.Same(t, small, CompressFrame(small))
+
+	large := &Frame{Version: 1, Type: TypeMessageList, Flags: FlagEncrypted, Payload: make([]byte, CompressionThreshold)}
+	compressed := CompressFrame(large)
+	assert.Equal(t, uint8(FlagCompressed|FlagEncrypted), compressed.Flags)
+	assert.Equal(t, uint8(FlagEncrypted), large.Flags, "original frame must not be modified")
+
+	// On the wire the payload is compressed; decoding restores it
+	buf := new(bytes.Buffer)
+	require.NoError(t, EncodeFrame(buf, compressed))
+	assert.Less(t, buf.Len(), 4+3+CompressionThreshold)
+
+	decoded, err := DecodeFrame(buf)
+	require.NoError(t, err)
+	assert.Equal(t, compressed.Flags, decoded.Flags)
+	assert.Equal(t, large.Payload, decoded.Payload)
+}
+
+func TestCompressPayloadProperty(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		// Small alphabet so matches are frequent
+		payload := rapid.SliceOfN(rapid.ByteRange('a', 'd'), 0, 2048).Draw(t, "payload")
+
+		decompressed, err := DecompressPayload(CompressPayload(payload))
+		if err != nil {
+			t.Fatalf("decompress failed: %v", err)
+		}
+		if !bytes.Equal(payload, decompressed) {
+			t.Fatalf("round trip mismatch")
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package protocol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// DM end-to-end encryption
+//
+// Each encrypted DM has a random AES-256 channel key that only participants know.
+// The key is wrapped for every participant public key (X25519) with an ephemeral
+// ECDH exchange, so the server only ever sees wrapped keys and ciphertext.
+//
+// Encrypted content (base64):  [Nonce (12 B)][AES-256-GCM ciphertext + tag]
+// Wrapped channel key (base64): [Ephemeral X25519 public key (32 B)][Nonce (12 B)][AES-256-GCM ciphertext + tag]
+//
+// Both use the channel ID as additional authenticated data, so ciphertext can't be
+// replayed into a different channel.
+
+const (
+	// ChannelKeySize is the size of a DM channel key (AES-256)
+	ChannelKeySize = 32
+
+	// EncryptionPublicKeySize is the size of an X25519 public key
+	EncryptionPublicKeySize = 32
+
+	// MaxEncryptedPlaintextLength is the largest plaintext whose encrypted form still
+	// fits in a 4096-byte content field (base64 of nonce + ciphertext + tag)
+	MaxEncryptedPlaintextLength = 3044
+
+	keyWrapInfo = "superchat dm channel key"
+)
+
+var (
+	ErrInvalidPublicKey  = errors.New("invalid encryption public key")
+	ErrInvalidChannelKey = errors.New("invalid channel key size")
+	ErrDecryptionFailed  = errors.New("decryption failed")
+)
+
+// curve25519P is the field prime 2^255 - 19 shared by ed25519 and X25519
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// GenerateChannelKey creates a random AES-256 channel key
+func GenerateChannelKey() ([]byte, error) {
+	key := make([]byte, ChannelKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeEncryptionPublicKey encodes an X25519 public key for PROVIDE_PUBLIC_KEY
+func EncodeEncryptionPublicKey(pub *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub.Bytes())
+}
+
+// ParseEncryptionPublicKey decodes a base64 X25519 public key
+func ParseEncryptionPublicKey(encoded string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != EncryptionPublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return pub, nil
+}
+
+// EncryptionKeyFingerprint returns the SHA-256 fingerprint of an X25519 public key,
+// formatted like SSH fingerprints, for users to compare out of band
+func EncryptionKeyFingerprint(pub *ecdh.PublicKey) string {
+	sum := sha256.Sum256(pub.Bytes())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// Ed25519PublicKeyToX25519 converts an ed25519 public key (e.g. from a registered SSH key)
+// to the equivalent X25519 public key using the birational map u = (1 + y) / (1 - y)
+func Ed25519PublicKeyToX25519(pub ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+
+	// Edwards y coordinate is little-endian with the sign of x in the top bit
+	le := make([]byte, len(pub))
+	copy(le, pub)
+	le[31] &= 0x7F
+	y := new(big.Int).SetBytes(reverseBytes(le))
+	if y.Cmp(curve25519P) >= 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	one := big.NewInt(1)
+	num := new(big.Int).Add(one, y)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curve25519P)
+	if den.Sign() == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	u := num.Mul(num, den.ModInverse(den, curve25519P))
+	u.Mod(u, curve25519P)
+
+	out := make([]byte, EncryptionPublicKeySize)
+	u.FillBytes(out)
+	key, err := ecdh.X25519().NewPublicKey(reverseBytes(out))
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return key, nil
+}
+
+// Ed25519PrivateKeyToX25519 converts an ed25519 private key to the X25519 private key
+// matching Ed25519PublicKeyToX25519 of its public key
+func Ed25519PrivateKeyToX25519(priv ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	h := sha512.Sum512(priv.Seed())
+	// X25519 clamps the scalar itself, so the raw hash prefix can be used directly
+	return ecdh.X25519().NewPrivateKey(h[:32])
+}
+
+// EncryptContent encrypts message content with a channel key
+func EncryptContent(channelKey []byte, channelID uint64, plaintext string) (string, error) {
+	gcm, err := newChannelGCM(channelKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), channelAAD(channelID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptContent decrypts message content produced by EncryptContent
+func DecryptContent(channelKey []byte, channelID uint64, content string) (string, error) {
+	gcm, err := newChannelGCM(channelKey)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(content)
+	if err != nil || len(raw) < gcm.NonceSize()+gcm.Overhead() {
+		return "", ErrDecryptionFailed
+	}
+	plaintext, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], channelAAD(channelID))
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	return string(plaintext), nil
+}
+
+// EncryptedContentLength returns the plaintext length of encrypted content (0 if malformed)
+func EncryptedContentLength(content string) int {
+	n := base64.StdEncoding.DecodedLen(len(content)) - strings.Count(content, "=") - 12 - 16
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// WrapChannelKey encrypts a channel key for a participant's X25519 public key
+func WrapChannelKey(channelKey []byte, channelID uint64, recipient *ecdh.PublicKey) (string, error) {
+	if len(channelKey) != ChannelKeySize {
+		return "", ErrInvalidChannelKey
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	kek, err := deriveWrapKey(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newChannelGCM(kek)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	out := append([]byte{}, ephemeral.PublicKey().Bytes()...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, channelKey, channelAAD(channelID))
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// UnwrapChannelKey decrypts a wrapped channel key with the participant's X25519 private key
+func UnwrapChannelKey(wrapped string, channelID uint64, priv *ecdh.PrivateKey) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(raw) < EncryptionPublicKeySize+12+16 {
+		return nil, ErrDecryptionFailed
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(raw[:EncryptionPublicKeySize])
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	kek, err := deriveWrapKey(priv, ephemeral, ephemeral, priv.PublicKey())
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	gcm, err := newChannelGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	rest := raw[EncryptionPublicKeySize:]
+	channelKey, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], channelAAD(channelID))
+	if err != nil || len(channelKey) != ChannelKeySize {
+		return nil, ErrDecryptionFailed
+	}
+	return channelKey, nil
+}
+
+// deriveWrapKey runs ECDH and derives the key-encryption key, binding both public keys
+func deriveWrapKey(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, ephemeral.Bytes()...), recipient.Bytes()...)
+	return hkdf.Key(sha256.New, shared, salt, keyWrapInfo, ChannelKeySize)
+}
+
+func newChannelGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != ChannelKeySize {
+		return nil, ErrInvalidChannelKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func channelAAD(channelID uint64) []byte {
+	aad := make([]byte, 8)
+	binary.BigEndian.PutUint64(aad, channelID)
+	return aad
+}
+
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentEncryptionRoundTrip(t *testing.T) {
+	key, err := GenerateChannelKey()
+	require.NoError(t, err)
+
+	encrypted, err := EncryptContent(key, 7, "hello bob")
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted, "hello")
+	assert.Equal(t, len("hello bob"), EncryptedContentLength(encrypted))
+
+	plaintext, err := DecryptContent(key, 7, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "hello bob", plaintext)
+
+	t.Run("wrong channel is rejected", func(t *testing.T) {
+		_, err := DecryptContent(key, 8, encrypted)
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("wrong key is rejected", func(t *testing.T) {
+		other, err := GenerateChannelKey()
+		require.NoError(t, err)
+		_, err = DecryptContent(other, 7, encrypted)
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("garbage is rejected", func(t *testing.T) {
+		_, err := DecryptContent(key, 7, "not base64!")
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+		_, err = DecryptContent(key, 7, "c2hvcnQ=")
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+}
+
+func TestChannelKeyWrapping(t *testing.T) {
+	channelKey, err := GenerateChannelKey()
+	require.NoError(t, err)
+	recipient, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	encodedPub := EncodeEncryptionPublicKey(recipient.PublicKey())
+	pub, err := ParseEncryptionPublicKey(encodedPub)
+	require.NoError(t, err)
+
+	wrapped, err := WrapChannelKey(channelKey, 3, pub)
+	require.NoError(t, err)
+
+	unwrapped, err := UnwrapChannelKey(wrapped, 3, recipient)
+	require.NoError(t, err)
+	assert.Equal(t, channelKey, unwrapped)
+
+	t.Run("other recipient cannot unwrap", func(t *testing.T) {
+		other, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		_, err = UnwrapChannelKey(wrapped, 3, other)
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("wrapped key is bound to its channel", func(t *testing.T) {
+		_, err := UnwrapChannelKey(wrapped, 4, recipient)
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("invalid channel key size", func(t *testing.T) {
+		_, err := WrapChannelKey([]byte("short"), 3, pub)
+		assert.ErrorIs(t, err, ErrInvalidChannelKey)
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		_, err := ParseEncryptionPublicKey("c2hvcnQ=")
+		assert.ErrorIs(t, err, ErrInvalidPublicKey)
+	})
+}
+
+func TestEd25519ToX25519(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	xPub, err := Ed25519PublicKeyToX25519(edPub)
+	require.NoError(t, err)
+	xPriv, err := Ed25519PrivateKeyToX25519(edPriv)
+	require.NoError(t, err)
+
+	// The converted private key must match the converted public key
+	assert.Equal(t, xPub.Bytes(), xPriv.PublicKey().Bytes())
+
+	// A key wrapped for the SSH-derived public key can be unwrapped with the SSH private key
+	channelKey, err := GenerateChannelKey()
+	require.NoError(t, err)
+	wrapped, err := WrapChannelKey(channelKey, 1, xPub)
+	require.NoError(t, err)
+	unwrapped, err := UnwrapChannelKey(wrapped, 1, xPriv)
+	require.NoError(t, err)
+	assert.Equal(t, channelKey, unwrapped)
+
+	_, err = Ed25519PublicKeyToX25519(edPub[:10])
+	assert.ErrorIs(t, err, ErrInvalidPublicKey)
+}
@@ -35,10 +35,15 @@ type Frame struct {
 	Payload []byte // Message payload
 }
 
-// EncodeFrame writes a frame to the writer
+// EncodeFrame writes a frame to the writer, compressing the payload if FlagCompressed is set
 func EncodeFrame(w io.Writer, f *Frame) error {
+	payload := f.Payload
+	if f.Flags&FlagCompressed != 0 {
+		payload = CompressPayload(payload)
+	}
+
 	// Calculate length: Version (1) + Type (1) + Flags (1) + Payload (N)
-	length := uint32(1 + 1 + 1 + len(f.Payload))
+	length := uint32(1 + 1 + 1 + len(payload))
 
 	// Check max frame size (excluding the 4-byte length field itself)
 	if length > MaxFrameSize {
@@ -66,8 +71,8 @@ func EncodeFrame(w io.Writer, f *Frame) error {
 	}
 
 	// Write payload
-	if len(f.Payload) > 0 {
-		if _, err := w.Write(f.Payload); err != nil {
+	if len(payload) > 0 {
+		if _, err := w.Write(payload); err != nil {
 			return err
 		}
 	}
@@ -83,7 +88,7 @@ func EncodeFrame(w io.Writer, f *Frame) error {
 	return nil
 }
 
-// DecodeFrame reads a frame from the reader
+// DecodeFrame reads a frame from the reader, decompressing the payload if FlagCompressed is set
 func DecodeFrame(r io.Reader) (*Frame, error) {
 	// Read length (4 bytes)
 	length, err := ReadUint32(r)
@@ -128,6 +133,13 @@ func DecodeFrame(r io.Reader) (*Frame, error) {
 		}
 	}
 
+	// Decompress transparently so callers always see the plain payload
+	if flags&FlagCompressed != 0 {
+		if payload, err = DecompressPayload(payload); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Frame{
 		Version: version,
 		Type:    msgType,
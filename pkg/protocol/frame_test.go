@@ -209,7 +209,7 @@ func TestFrameStructure(t *testing.T) {
 		frame := &Frame{
 			Version: 1,
 			Type:    TypePostMessage,
-			Flags:   FlagCompressed,
+			Flags:   FlagEncrypted,
 			Payload: []byte("Hello, world!"),
 		}
 
@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"bytes"
+	"encoding/binary"
 	"testing"
 )
 
@@ -37,6 +38,45 @@ func FuzzDecodeFrame(f *testing.F) {
 	})
 }
 
+// FuzzDecompressPayload fuzzes the LZ4 decoder, which sees untrusted input on every
+// compressed frame, and checks compression round-trips
+func FuzzDecompressPayload(f *testing.F) {
+	// Seed with compressed payloads
+	f.Add(CompressPayload([]byte("hello")))
+	f.Add(CompressPayload(bytes.Repeat([]byte("abcabcabc"), 100)))
+	f.Add([]byte{0x00, 0x00, 0x00, 0x15, 0x3E, 'a', 'b', 'c', 0x03, 0x00, 0x00}) // Overlapping match
+
+	// Seed with malformed blocks
+	f.Add([]byte{0x00, 0x00, 0x00, 0x05, 0x50, 'a', 'b'})        // Truncated literals
+	f.Add([]byte{0x00, 0x00, 0x00, 0x10, 0x10, 'a', 0x05, 0x00}) // Offset before the start of the output
+	f.Add([]byte{0x00, 0x00, 0x00, 0x10, 0x10, 'a', 0x00, 0x00}) // Zero offset
+	f.Add([]byte{0x00, 0x00, 0x00, 0x03, 0x10, 'a', 0x01, 0x00}) // Match past the declared size
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x00})                  // Huge declared size
+
+	// Length overflow: a run of 255s extending the literal length past the declared size
+	f.Add(append([]byte{0x00, 0x10, 0x00, 0x00, 0xF0}, bytes.Repeat([]byte{0xFF}, 64)...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Anything compresses and decompresses to itself
+		roundTrip, err := DecompressPayload(CompressPayload(data))
+		if err != nil {
+			t.Fatalf("Failed to decompress compressed payload: %v", err)
+		}
+		if !bytes.Equal(roundTrip, data) {
+			t.Fatalf("Round trip changed the payload")
+		}
+
+		// Decoding random bytes must not panic, and must produce exactly the declared size
+		decompressed, err := DecompressPayload(data)
+		if err != nil {
+			return
+		}
+		if size := int(binary.BigEndian.Uint32(data)); len(decompressed) != size {
+			t.Fatalf("Decompressed %d bytes, declared %d", len(decompressed), size)
+		}
+	})
+}
+
 // FuzzReadString fuzzes the string decoder
 func FuzzReadString(f *testing.F) {
 	// Seed with valid strings
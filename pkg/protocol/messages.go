@@ -26,6 +26,7 @@ const (
 	TypeJoinChannel        = 0x05
 	TypeLeaveChannel       = 0x06
 	TypeCreateChannel      = 0x07
+	TypeCreateSubchannel   = 0x08
 	TypeListMessages       = 0x09
 	TypePostMessage        = 0x0A
 	TypeEditMessage        = 0x0B
@@ -36,13 +37,27 @@ const (
 	TypeUpdateSSHKeyLabel  = 0x12
 	TypeDeleteSSHKey       = 0x13
 	TypeListSSHKeys        = 0x14
+	TypeGetSubchannels     = 0x15
 	TypeLogout             = 0x1C
 	TypePing               = 0x10
 	TypeDisconnect         = 0x11
 	TypeListUsers          = 0x16
 	TypeListChannelUsers   = 0x17
 	TypeGetUnreadCounts    = 0x18
+	TypeStartDM            = 0x19
+	TypeProvidePublicKey   = 0x1A
+	TypeAllowUnencrypted   = 0x1B
 	TypeUpdateReadState    = 0x1D
+	TypeAddDMParticipant   = 0x1E
+	TypeListDMs            = 0x1F
+	TypeProvideChannelKeys = 0x20
+	TypeSetCompression     = 0x21
+	TypeSearchMessages     = 0x22
+	TypeAddReaction        = 0x23
+	TypeRemoveReaction     = 0x24
+	TypeListMentions       = 0x25
+	TypeMarkMentionsRead   = 0x26
+	TypeGetMessageHistory  = 0x27
 	TypeSubscribeThread    = 0x51
 	TypeUnsubscribeThread  = 0x52
 	TypeSubscribeChannel   = 0x53
@@ -60,6 +75,29 @@ const (
 	TypeListBans      = 0x5D
 	TypeDeleteUser    = 0x5E
 	TypeDeleteChannel = 0x5F
+
+	// Audit log (Client → Server)
+	TypeListAdminActions = 0x60
+
+	// Roles (Client → Server)
+	TypeSetUserFlags = 0x61
+
+	// Login lockouts (Client → Server)
+	TypeListLoginLockouts = 0x62
+	TypeClearLoginLockout = 0x63
+
+	// Two-factor authentication (Client → Server)
+	TypeAuthTwoFactor = 0x64
+	TypeEnable2FA     = 0x65
+	TypeConfirm2FA    = 0x66
+	TypeDisable2FA    = 0x67
+
+	// Profiles (Client → Server)
+	TypeSetProfile = 0x68
+
+	// Typing indicators (Client → Server)
+	TypeTypingStart = 0x69
+	TypeTypingStop  = 0x6A
 )
 
 // Message type constants (Server → Client)
@@ -85,6 +123,7 @@ const (
 	TypeSSHKeyDeleted      = 0x93
 	TypeSSHKeyList         = 0x94
 	TypeSSHKeyAdded        = 0x95
+	TypeSubchannelList     = 0x96
 	TypeUnreadCounts       = 0x97
 	TypeServerConfig       = 0x98
 	TypeSubscribeOk        = 0x99
@@ -93,9 +132,48 @@ const (
 	TypeRegisterAck        = 0x9C
 	TypeHeartbeatAck       = 0x9D
 	TypeVerifyRegistration = 0x9E
+	TypeKeyRequired        = 0xA1
+	TypeDMReady            = 0xA2
+	TypeDMPending          = 0xA3
+	TypeDMRequest          = 0xA4
 	TypeChannelUserList    = 0xAB
 	TypeChannelPresence    = 0xAC
 	TypeServerPresence     = 0xAD
+	TypeDMList             = 0xAE
+	TypeDMKeyExchange      = 0xAF
+	TypeSearchResults      = 0xB0
+	TypeReactionsUpdated   = 0xB1
+
+	// Mentions (Server → Client)
+	TypeMentionNotification = 0xB2
+	TypeMentionList         = 0xB3
+
+	// Retention (Server → Client)
+	TypeMessagesExpired = 0xB4
+
+	// Edit history (Server → Client)
+	TypeMessageHistory = 0xB5
+
+	// Audit log (Server → Client)
+	TypeAdminActionList = 0xB6
+
+	// Roles (Server → Client)
+	TypeUserFlagsUpdated = 0xB7
+
+	// Login lockouts (Server → Client)
+	TypeLoginLockoutList    = 0xB8
+	TypeLoginLockoutCleared = 0xB9
+
+	// Two-factor authentication (Server → Client)
+	TypeTwoFactorSetup    = 0xBA
+	TypeTwoFactorEnabled  = 0xBB
+	TypeTwoFactorDisabled = 0xBC
+
+	// Profiles (Server → Client)
+	TypeProfileUpdated = 0xBD
+
+	// Typing indicators (Server → Client)
+	TypeUserTyping = 0xBE
 
 	// Admin responses (Server → Client)
 	TypeUserBanned     = 0x9F
@@ -113,12 +191,14 @@ const (
 	ErrCodeInvalidFormat      = 1000
 	ErrCodeUnsupportedVersion = 1001
 	ErrCodeInvalidFrame       = 1002
+	ErrCodeEncryptionError    = 1004
 
 	// Authentication errors (2xxx)
 	ErrCodeAuthRequired = 2000
 
 	// Authorization errors (3xxx)
 	ErrCodePermissionDenied = 3000
+	ErrCodeChannelPrivate   = 3003
 
 	// Resource errors (4xxx)
 	ErrCodeNotFound           = 4000
@@ -126,10 +206,12 @@ const (
 	ErrCodeMessageNotFound    = 4002
 	ErrCodeThreadNotFound     = 4003
 	ErrCodeSubchannelNotFound = 4004
+	ErrCodeUserNotFound       = 4005
 
 	// Rate limit errors (5xxx)
 	ErrCodeRateLimitExceeded        = 5000
 	ErrCodeMessageRateLimit         = 5001
+	ErrCodeTooManyConnections       = 5003
 	ErrCodeThreadSubscriptionLimit  = 5004
 	ErrCodeChannelSubscriptionLimit = 5005
 
@@ -149,6 +231,7 @@ var (
 	ErrNicknameTooLong  = errors.New("nickname must be at most 20 characters")
 	ErrMessageTooLong   = errors.New("message content exceeds maximum length (4096 bytes)")
 	ErrEmptyContent     = errors.New("message content cannot be empty")
+	ErrInvalidDMTarget  = errors.New("invalid DM target type")
 )
 
 // AuthRequestMessage (0x01) - Authenticate with password
@@ -195,6 +278,10 @@ type AuthResponseMessage struct {
 	Nickname  string // Only present if success=true
 	Message   string
 	UserFlags *UserFlags // Optional: present when Success=true and server includes flags
+
+	// TwoFactorRequired means the password was right and the server waits for an
+	// AUTH_TWO_FACTOR code (optional: only sent when Success=false)
+	TwoFactorRequired bool
 }
 
 func (m *AuthResponseMessage) EncodeTo(w io.Writer) error {
@@ -217,6 +304,11 @@ func (m *AuthResponseMessage) EncodeTo(w io.Writer) error {
 			return err
 		}
 	}
+	if !m.Success && m.TwoFactorRequired {
+		if err := WriteBool(w, true); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -237,6 +329,7 @@ func (m *AuthResponseMessage) Decode(payload []byte) error {
 
 	m.Success = success
 	m.UserFlags = nil
+	m.TwoFactorRequired = false
 
 	if success {
 		userID, err := ReadUint64(buf)
@@ -266,6 +359,13 @@ func (m *AuthResponseMessage) Decode(payload []byte) error {
 		f := UserFlags(flags)
 		m.UserFlags = &f
 	}
+	if !success && buf.Len() > 0 {
+		required, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		m.TwoFactorRequired = required
+	}
 
 	return nil
 }
@@ -942,36 +1042,36 @@ func (m *ChannelCreatedMessage) Decode(payload []byte) error {
 	return nil
 }
 
-// ListMessagesMessage (0x09) - Request messages
-type ListMessagesMessage struct {
-	ChannelID    uint64
-	SubchannelID *uint64
-	Limit        uint16
-	BeforeID     *uint64
-	ParentID     *uint64
-	AfterID      *uint64
+// CreateSubchannelMessage (0x08) - Create a new subchannel inside a channel (V3+, requires registered user)
+type CreateSubchannelMessage struct {
+	ChannelID      uint64 // Parent channel
+	Name           string // URL-friendly name (e.g., "announcements")
+	DisplayName    string // Human-readable name (e.g., "/announcements")
+	Description    *string
+	SubchannelType uint8  // 0=chat, 1=forum
+	RetentionHours uint32 // Message retention in hours
 }
 
-func (m *ListMessagesMessage) EncodeTo(w io.Writer) error {
+func (m *CreateSubchannelMessage) EncodeTo(w io.Writer) error {
 	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+	if err := WriteString(w, m.Name); err != nil {
 		return err
 	}
-	if err := WriteUint16(w, m.Limit); err != nil {
+	if err := WriteString(w, m.DisplayName); err != nil {
 		return err
 	}
-	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+	if err := WriteOptionalString(w, m.Description); err != nil {
 		return err
 	}
-	if err := WriteOptionalUint64(w, m.ParentID); err != nil {
+	if err := WriteUint8(w, m.SubchannelType); err != nil {
 		return err
 	}
-	return WriteOptionalUint64(w, m.AfterID)
+	return WriteUint32(w, m.RetentionHours)
 }
 
-func (m *ListMessagesMessage) Encode() ([]byte, error) {
+func (m *CreateSubchannelMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -979,115 +1079,86 @@ func (m *ListMessagesMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ListMessagesMessage) Decode(payload []byte) error {
+func (m *CreateSubchannelMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	subchannelID, err := ReadOptionalUint64(buf)
+	name, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	limit, err := ReadUint16(buf)
+	displayName, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	beforeID, err := ReadOptionalUint64(buf)
+	description, err := ReadOptionalString(buf)
 	if err != nil {
 		return err
 	}
-	parentID, err := ReadOptionalUint64(buf)
+	subchannelType, err := ReadUint8(buf)
 	if err != nil {
 		return err
 	}
-	afterID, err := ReadOptionalUint64(buf)
+	retentionHours, err := ReadUint32(buf)
 	if err != nil {
 		return err
 	}
 
 	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.Limit = limit
-	m.BeforeID = beforeID
-	m.ParentID = parentID
-	m.AfterID = afterID
+	m.Name = name
+	m.DisplayName = displayName
+	m.Description = description
+	m.SubchannelType = subchannelType
+	m.RetentionHours = retentionHours
 	return nil
 }
 
-// Message represents a single message
-type Message struct {
-	ID             uint64
-	ChannelID      uint64
-	SubchannelID   *uint64
-	ParentID       *uint64
-	AuthorUserID   *uint64
-	AuthorNickname string // Only populated for anonymous users (when AuthorUserID IS NULL)
-	Content        string
-	CreatedAt      time.Time
-	EditedAt       *time.Time
-	ReplyCount     uint32
-}
-
-// MessageListMessage (0x89) - List of messages
-type MessageListMessage struct {
-	ChannelID    uint64
-	SubchannelID *uint64
-	ParentID     *uint64
-	Messages     []Message
+// SubchannelCreatedMessage (0x88) - Response to CREATE_SUBCHANNEL + broadcast to all connected clients
+// Hybrid message: sent to creator as confirmation, also broadcast to all others if success=true
+type SubchannelCreatedMessage struct {
+	Success        bool
+	ChannelID      uint64 // Only present if Success=true
+	SubchannelID   uint64 // Only present if Success=true
+	Name           string // Only present if Success=true
+	Description    string // Only present if Success=true
+	Type           uint8  // Only present if Success=true
+	RetentionHours uint32 // Only present if Success=true
+	Message        string // Error if failed, confirmation if success
 }
 
-func (m *MessageListMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.ChannelID); err != nil {
-		return err
-	}
-	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
-		return err
-	}
-	if err := WriteOptionalUint64(w, m.ParentID); err != nil {
-		return err
-	}
-	if err := WriteUint16(w, uint16(len(m.Messages))); err != nil {
+func (m *SubchannelCreatedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
 
-	for _, msg := range m.Messages {
-		if err := WriteUint64(w, msg.ID); err != nil {
-			return err
-		}
-		if err := WriteUint64(w, msg.ChannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, msg.SubchannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, msg.ParentID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, msg.AuthorUserID); err != nil {
+	// Only write subchannel data if success=true
+	if m.Success {
+		if err := WriteUint64(w, m.ChannelID); err != nil {
 			return err
 		}
-		if err := WriteString(w, msg.AuthorNickname); err != nil {
+		if err := WriteUint64(w, m.SubchannelID); err != nil {
 			return err
 		}
-		if err := WriteString(w, msg.Content); err != nil {
+		if err := WriteString(w, m.Name); err != nil {
 			return err
 		}
-		if err := WriteTimestamp(w, msg.CreatedAt); err != nil {
+		if err := WriteString(w, m.Description); err != nil {
 			return err
 		}
-		if err := WriteOptionalTimestamp(w, msg.EditedAt); err != nil {
+		if err := WriteUint8(w, m.Type); err != nil {
 			return err
 		}
-		if err := WriteUint32(w, msg.ReplyCount); err != nil {
+		if err := WriteUint32(w, m.RetentionHours); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return WriteString(w, m.Message)
 }
 
-func (m *MessageListMessage) Encode() ([]byte, error) {
+func (m *SubchannelCreatedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1095,120 +1166,69 @@ func (m *MessageListMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *MessageListMessage) Decode(payload []byte) error {
+func (m *SubchannelCreatedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-
-	channelID, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
-	subchannelID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
-	}
-	parentID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
-	}
-	count, err := ReadUint16(buf)
+	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
 
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.ParentID = parentID
-	m.Messages = make([]Message, count)
+	m.Success = success
 
-	for i := uint16(0); i < count; i++ {
-		id, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		chID, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		subID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		parID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		authorID, err := ReadOptionalUint64(buf)
+	// Only read subchannel data if success=true
+	if success {
+		channelID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		authorNick, err := ReadString(buf)
+		subchannelID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		content, err := ReadString(buf)
+		name, err := ReadString(buf)
 		if err != nil {
 			return err
 		}
-		createdAt, err := ReadTimestamp(buf)
+		description, err := ReadString(buf)
 		if err != nil {
 			return err
 		}
-		editedAt, err := ReadOptionalTimestamp(buf)
+		subchannelType, err := ReadUint8(buf)
 		if err != nil {
 			return err
 		}
-		replyCount, err := ReadUint32(buf)
+		retentionHours, err := ReadUint32(buf)
 		if err != nil {
 			return err
 		}
 
-		m.Messages[i] = Message{
-			ID:             id,
-			ChannelID:      chID,
-			SubchannelID:   subID,
-			ParentID:       parID,
-			AuthorUserID:   authorID,
-			AuthorNickname: authorNick,
-			Content:        content,
-			CreatedAt:      createdAt,
-			EditedAt:       editedAt,
-			ReplyCount:     replyCount,
-		}
+		m.ChannelID = channelID
+		m.SubchannelID = subchannelID
+		m.Name = name
+		m.Description = description
+		m.Type = subchannelType
+		m.RetentionHours = retentionHours
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
 	}
+	m.Message = message
 
 	return nil
 }
 
-// PostMessageMessage (0x0A) - Post a new message
-type PostMessageMessage struct {
-	ChannelID    uint64
-	SubchannelID *uint64
-	ParentID     *uint64
-	Content      string
+// GetSubchannelsMessage (0x15) - Request subchannels for a channel
+type GetSubchannelsMessage struct {
+	ChannelID uint64
 }
 
-func (m *PostMessageMessage) EncodeTo(w io.Writer) error {
-	// Validate content
-	if len(m.Content) == 0 {
-		return ErrEmptyContent
-	}
-	if len(m.Content) > 4096 {
-		return ErrMessageTooLong
-	}
-
-	if err := WriteUint64(w, m.ChannelID); err != nil {
-		return err
-	}
-	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
-		return err
-	}
-	if err := WriteOptionalUint64(w, m.ParentID); err != nil {
-		return err
-	}
-	return WriteString(w, m.Content)
+func (m *GetSubchannelsMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.ChannelID)
 }
 
-func (m *PostMessageMessage) Encode() ([]byte, error) {
+func (m *GetSubchannelsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1216,58 +1236,64 @@ func (m *PostMessageMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *PostMessageMessage) Decode(payload []byte) error {
+func (m *GetSubchannelsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	subchannelID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
-	}
-	parentID, err := ReadOptionalUint64(buf)
-	if err != nil {
+	m.ChannelID = channelID
+	return nil
+}
+
+// Subchannel represents a subchannel in SUBCHANNEL_LIST
+type Subchannel struct {
+	ID             uint64
+	Name           string
+	Description    string
+	Type           uint8
+	RetentionHours uint32
+}
+
+// SubchannelListMessage (0x96) - List of subchannels for a channel
+type SubchannelListMessage struct {
+	ChannelID   uint64
+	Subchannels []Subchannel
+}
+
+func (m *SubchannelListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	content, err := ReadString(buf)
-	if err != nil {
+
+	// Write subchannel count
+	if err := WriteUint16(w, uint16(len(m.Subchannels))); err != nil {
 		return err
 	}
 
-	// Validate content
-	if len(content) == 0 {
-		return ErrEmptyContent
-	}
-	if len(content) > 4096 {
-		return ErrMessageTooLong
+	// Write each subchannel
+	for _, sub := range m.Subchannels {
+		if err := WriteUint64(w, sub.ID); err != nil {
+			return err
+		}
+		if err := WriteString(w, sub.Name); err != nil {
+			return err
+		}
+		if err := WriteString(w, sub.Description); err != nil {
+			return err
+		}
+		if err := WriteUint8(w, sub.Type); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, sub.RetentionHours); err != nil {
+			return err
+		}
 	}
 
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.ParentID = parentID
-	m.Content = content
 	return nil
 }
 
-// MessagePostedMessage (0x8A) - Confirmation of message post
-type MessagePostedMessage struct {
-	Success   bool
-	MessageID uint64
-	Message   string
-}
-
-func (m *MessagePostedMessage) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
-		return err
-	}
-	if err := WriteUint64(w, m.MessageID); err != nil {
-		return err
-	}
-	return WriteString(w, m.Message)
-}
-
-func (m *MessagePostedMessage) Encode() ([]byte, error) {
+func (m *SubchannelListMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1275,49 +1301,88 @@ func (m *MessagePostedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *MessagePostedMessage) Decode(payload []byte) error {
+func (m *SubchannelListMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	messageID, err := ReadUint64(buf)
+
+	// Read subchannel count
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	message, err := ReadString(buf)
-	if err != nil {
-		return err
+
+	// Read each subchannel
+	subchannels := make([]Subchannel, count)
+	for i := uint16(0); i < count; i++ {
+		id, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		name, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		desc, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		subType, err := ReadUint8(buf)
+		if err != nil {
+			return err
+		}
+		retention, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+
+		subchannels[i] = Subchannel{
+			ID:             id,
+			Name:           name,
+			Description:    desc,
+			Type:           subType,
+			RetentionHours: retention,
+		}
 	}
 
-	m.Success = success
-	m.MessageID = messageID
-	m.Message = message
+	m.ChannelID = channelID
+	m.Subchannels = subchannels
 	return nil
 }
 
-// EditMessageMessage (0x0B) - Edit an existing message
-type EditMessageMessage struct {
-	MessageID  uint64
-	NewContent string
+// ListMessagesMessage (0x09) - Request messages
+type ListMessagesMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	Limit        uint16
+	BeforeID     *uint64
+	ParentID     *uint64
+	AfterID      *uint64
 }
 
-func (m *EditMessageMessage) EncodeTo(w io.Writer) error {
-	// Validate content
-	if len(m.NewContent) == 0 {
-		return ErrEmptyContent
+func (m *ListMessagesMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
 	}
-	if len(m.NewContent) > 4096 {
-		return ErrMessageTooLong
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
 	}
-
-	if err := WriteUint64(w, m.MessageID); err != nil {
+	if err := WriteUint16(w, m.Limit); err != nil {
 		return err
 	}
-	return WriteString(w, m.NewContent)
+	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.ParentID); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.AfterID)
 }
 
-func (m *EditMessageMessage) Encode() ([]byte, error) {
+func (m *ListMessagesMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1325,58 +1390,125 @@ func (m *EditMessageMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *EditMessageMessage) Decode(payload []byte) error {
+func (m *ListMessagesMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	messageID, err := ReadUint64(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	content, err := ReadString(buf)
+	subchannelID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-
-	// Validate content
-	if len(content) == 0 {
-		return ErrEmptyContent
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
 	}
-	if len(content) > 4096 {
-		return ErrMessageTooLong
+	beforeID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	parentID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	afterID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
 	}
 
-	m.MessageID = messageID
-	m.NewContent = content
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.Limit = limit
+	m.BeforeID = beforeID
+	m.ParentID = parentID
+	m.AfterID = afterID
 	return nil
 }
 
-// MessageEditedMessage (0x8B) - Edit confirmation + real-time broadcast
-type MessageEditedMessage struct {
-	Success    bool
-	MessageID  uint64
-	EditedAt   time.Time
-	NewContent string
-	Message    string // Error message if failed
+// Message represents a single message
+type Message struct {
+	ID             uint64
+	ChannelID      uint64
+	SubchannelID   *uint64
+	ParentID       *uint64
+	AuthorUserID   *uint64
+	AuthorNickname string // Only populated for anonymous users (when AuthorUserID IS NULL)
+	Content        string
+	CreatedAt      time.Time
+	EditedAt       *time.Time
+	ReplyCount     uint32
+	Reactions      []ReactionSummary // Grouped by emoji, in order of first use
 }
 
-func (m *MessageEditedMessage) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+// ReactionSummary is the set of users who reacted to a message with one emoji
+type ReactionSummary struct {
+	Emoji   string
+	UserIDs []uint64 // In the order they reacted
+}
+
+// MessageListMessage (0x89) - List of messages
+type MessageListMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	ParentID     *uint64
+	Messages     []Message
+}
+
+func (m *MessageListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	if err := WriteUint64(w, m.MessageID); err != nil {
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
 		return err
 	}
-	if m.Success {
-		if err := WriteTimestamp(w, m.EditedAt); err != nil {
+	if err := WriteOptionalUint64(w, m.ParentID); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Messages))); err != nil {
+		return err
+	}
+
+	for _, msg := range m.Messages {
+		if err := WriteUint64(w, msg.ID); err != nil {
 			return err
 		}
-		if err := WriteString(w, m.NewContent); err != nil {
+		if err := WriteUint64(w, msg.ChannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, msg.SubchannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, msg.ParentID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, msg.AuthorUserID); err != nil {
+			return err
+		}
+		if err := WriteString(w, msg.AuthorNickname); err != nil {
+			return err
+		}
+		if err := WriteString(w, msg.Content); err != nil {
+			return err
+		}
+		if err := WriteTimestamp(w, msg.CreatedAt); err != nil {
+			return err
+		}
+		if err := WriteOptionalTimestamp(w, msg.EditedAt); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, msg.ReplyCount); err != nil {
+			return err
+		}
+		if err := writeReactionSummaries(w, msg.Reactions); err != nil {
 			return err
 		}
 	}
-	return WriteString(w, m.Message)
+
+	return nil
 }
 
-func (m *MessageEditedMessage) Encode() ([]byte, error) {
+func (m *MessageListMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1384,52 +1516,125 @@ func (m *MessageEditedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *MessageEditedMessage) Decode(payload []byte) error {
+func (m *MessageListMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	messageID, err := ReadUint64(buf)
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	parentID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
 
-	m.Success = success
-	m.MessageID = messageID
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ParentID = parentID
+	m.Messages = make([]Message, count)
 
-	if success {
-		editedAt, err := ReadTimestamp(buf)
+	for i := uint16(0); i < count; i++ {
+		id, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		newContent, err := ReadString(buf)
+		chID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		m.EditedAt = editedAt
-		m.NewContent = newContent
-	}
-
-	message, err := ReadString(buf)
-	if err != nil {
-		return err
+		subID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		parID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		authorID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		authorNick, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		content, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		createdAt, err := ReadTimestamp(buf)
+		if err != nil {
+			return err
+		}
+		editedAt, err := ReadOptionalTimestamp(buf)
+		if err != nil {
+			return err
+		}
+		replyCount, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+		reactions, err := readReactionSummaries(buf)
+		if err != nil {
+			return err
+		}
+
+		m.Messages[i] = Message{
+			ID:             id,
+			ChannelID:      chID,
+			SubchannelID:   subID,
+			ParentID:       parID,
+			AuthorUserID:   authorID,
+			AuthorNickname: authorNick,
+			Content:        content,
+			CreatedAt:      createdAt,
+			EditedAt:       editedAt,
+			ReplyCount:     replyCount,
+			Reactions:      reactions,
+		}
 	}
-	m.Message = message
 
 	return nil
 }
 
-// DeleteMessageMessage (0x0C) - Delete a message
-type DeleteMessageMessage struct {
-	MessageID uint64
+// PostMessageMessage (0x0A) - Post a new message
+type PostMessageMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	ParentID     *uint64
+	Content      string
 }
 
-func (m *DeleteMessageMessage) EncodeTo(w io.Writer) error {
-	return WriteUint64(w, m.MessageID)
+func (m *PostMessageMessage) EncodeTo(w io.Writer) error {
+	// Validate content
+	if len(m.Content) == 0 {
+		return ErrEmptyContent
+	}
+	if len(m.Content) > 4096 {
+		return ErrMessageTooLong
+	}
+
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.ParentID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Content)
 }
 
-func (m *DeleteMessageMessage) Encode() ([]byte, error) {
+func (m *PostMessageMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1437,41 +1642,58 @@ func (m *DeleteMessageMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *DeleteMessageMessage) Decode(payload []byte) error {
+func (m *PostMessageMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	messageID, err := ReadUint64(buf)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	parentID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	content, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
 
-	m.MessageID = messageID
+	// Validate content
+	if len(content) == 0 {
+		return ErrEmptyContent
+	}
+	if len(content) > 4096 {
+		return ErrMessageTooLong
+	}
+
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ParentID = parentID
+	m.Content = content
 	return nil
 }
 
-// MessageDeletedMessage (0x8C) - Confirmation of deletion + broadcast
-type MessageDeletedMessage struct {
+// MessagePostedMessage (0x8A) - Confirmation of message post
+type MessagePostedMessage struct {
 	Success   bool
 	MessageID uint64
-	DeletedAt time.Time
 	Message   string
 }
 
-func (m *MessageDeletedMessage) EncodeTo(w io.Writer) error {
+func (m *MessagePostedMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
 	if err := WriteUint64(w, m.MessageID); err != nil {
 		return err
 	}
-	if m.Success {
-		if err := WriteTimestamp(w, m.DeletedAt); err != nil {
-			return err
-		}
-	}
 	return WriteString(w, m.Message)
 }
 
-func (m *MessageDeletedMessage) Encode() ([]byte, error) {
+func (m *MessagePostedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1479,7 +1701,7 @@ func (m *MessageDeletedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *MessageDeletedMessage) Decode(payload []byte) error {
+func (m *MessagePostedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	success, err := ReadBool(buf)
 	if err != nil {
@@ -1489,65 +1711,39 @@ func (m *MessageDeletedMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
-
-	m.Success = success
-	m.MessageID = messageID
-
-	if success {
-		deletedAt, err := ReadTimestamp(buf)
-		if err != nil {
-			return err
-		}
-		m.DeletedAt = deletedAt
-	}
-
 	message, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	m.Message = message
 
+	m.Success = success
+	m.MessageID = messageID
+	m.Message = message
 	return nil
 }
 
-// PingMessage (0x10) - Keepalive ping
-type PingMessage struct {
-	Timestamp int64
-}
-
-func (m *PingMessage) EncodeTo(w io.Writer) error {
-	return WriteInt64(w, m.Timestamp)
+// EditMessageMessage (0x0B) - Edit an existing message
+type EditMessageMessage struct {
+	MessageID  uint64
+	NewContent string
 }
 
-func (m *PingMessage) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.EncodeTo(buf); err != nil {
-		return nil, err
+func (m *EditMessageMessage) EncodeTo(w io.Writer) error {
+	// Validate content
+	if len(m.NewContent) == 0 {
+		return ErrEmptyContent
+	}
+	if len(m.NewContent) > 4096 {
+		return ErrMessageTooLong
 	}
-	return buf.Bytes(), nil
-}
 
-func (m *PingMessage) Decode(payload []byte) error {
-	buf := bytes.NewReader(payload)
-	timestamp, err := ReadInt64(buf)
-	if err != nil {
+	if err := WriteUint64(w, m.MessageID); err != nil {
 		return err
 	}
-
-	m.Timestamp = timestamp
-	return nil
-}
-
-// PongMessage (0x90) - Ping response
-type PongMessage struct {
-	ClientTimestamp int64
-}
-
-func (m *PongMessage) EncodeTo(w io.Writer) error {
-	return WriteInt64(w, m.ClientTimestamp)
+	return WriteString(w, m.NewContent)
 }
 
-func (m *PongMessage) Encode() ([]byte, error) {
+func (m *EditMessageMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1555,31 +1751,58 @@ func (m *PongMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *PongMessage) Decode(payload []byte) error {
+func (m *EditMessageMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	timestamp, err := ReadInt64(buf)
+	messageID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	content, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
 
-	m.ClientTimestamp = timestamp
+	// Validate content
+	if len(content) == 0 {
+		return ErrEmptyContent
+	}
+	if len(content) > 4096 {
+		return ErrMessageTooLong
+	}
+
+	m.MessageID = messageID
+	m.NewContent = content
 	return nil
 }
 
-// ErrorMessage (0x91) - Generic error response
-type ErrorMessage struct {
-	ErrorCode uint16
-	Message   string
+// MessageEditedMessage (0x8B) - Edit confirmation + real-time broadcast
+type MessageEditedMessage struct {
+	Success    bool
+	MessageID  uint64
+	EditedAt   time.Time
+	NewContent string
+	Message    string // Error message if failed
 }
 
-func (m *ErrorMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint16(w, m.ErrorCode); err != nil {
+func (m *MessageEditedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.MessageID); err != nil {
 		return err
 	}
+	if m.Success {
+		if err := WriteTimestamp(w, m.EditedAt); err != nil {
+			return err
+		}
+		if err := WriteString(w, m.NewContent); err != nil {
+			return err
+		}
+	}
 	return WriteString(w, m.Message)
 }
 
-func (m *ErrorMessage) Encode() ([]byte, error) {
+func (m *MessageEditedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1587,33 +1810,251 @@ func (m *ErrorMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ErrorMessage) Decode(payload []byte) error {
+func (m *MessageEditedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	errorCode, err := ReadUint16(buf)
+	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	message, err := ReadString(buf)
+	messageID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
 
-	m.ErrorCode = errorCode
-	m.Message = message
-	return nil
-}
+	m.Success = success
+	m.MessageID = messageID
 
-// ServerConfigMessage (0x98) - Server configuration and limits
-type ServerConfigMessage struct {
-	ProtocolVersion         uint8
-	MaxMessageRate          uint16
-	MaxChannelCreates       uint16
-	InactiveCleanupDays     uint16
-	MaxConnectionsPerIP     uint8
-	MaxMessageLength        uint32
-	MaxThreadSubscriptions  uint16
+	if success {
+		editedAt, err := ReadTimestamp(buf)
+		if err != nil {
+			return err
+		}
+		newContent, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		m.EditedAt = editedAt
+		m.NewContent = newContent
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+
+	return nil
+}
+
+// DeleteMessageMessage (0x0C) - Delete a message
+type DeleteMessageMessage struct {
+	MessageID uint64
+}
+
+func (m *DeleteMessageMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.MessageID)
+}
+
+func (m *DeleteMessageMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DeleteMessageMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	messageID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.MessageID = messageID
+	return nil
+}
+
+// MessageDeletedMessage (0x8C) - Confirmation of deletion + broadcast
+type MessageDeletedMessage struct {
+	Success   bool
+	MessageID uint64
+	DeletedAt time.Time
+	Message   string
+}
+
+func (m *MessageDeletedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.MessageID); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteTimestamp(w, m.DeletedAt); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *MessageDeletedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MessageDeletedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	messageID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Success = success
+	m.MessageID = messageID
+
+	if success {
+		deletedAt, err := ReadTimestamp(buf)
+		if err != nil {
+			return err
+		}
+		m.DeletedAt = deletedAt
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+
+	return nil
+}
+
+// PingMessage (0x10) - Keepalive ping
+type PingMessage struct {
+	Timestamp int64
+}
+
+func (m *PingMessage) EncodeTo(w io.Writer) error {
+	return WriteInt64(w, m.Timestamp)
+}
+
+func (m *PingMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *PingMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	timestamp, err := ReadInt64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Timestamp = timestamp
+	return nil
+}
+
+// PongMessage (0x90) - Ping response
+type PongMessage struct {
+	ClientTimestamp int64
+}
+
+func (m *PongMessage) EncodeTo(w io.Writer) error {
+	return WriteInt64(w, m.ClientTimestamp)
+}
+
+func (m *PongMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *PongMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	timestamp, err := ReadInt64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ClientTimestamp = timestamp
+	return nil
+}
+
+// ErrorMessage (0x91) - Generic error response
+type ErrorMessage struct {
+	ErrorCode    uint16
+	Message      string
+	RetryAfterMs *uint64 // When the request may be retried (rate limit errors); absent from older servers
+}
+
+func (m *ErrorMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, m.ErrorCode); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Message); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.RetryAfterMs)
+}
+
+func (m *ErrorMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ErrorMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	errorCode, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ErrorCode = errorCode
+	m.Message = message
+	m.RetryAfterMs = nil
+
+	// Older servers end the message here
+	if buf.Len() > 0 {
+		retryAfterMs, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		m.RetryAfterMs = retryAfterMs
+	}
+	return nil
+}
+
+// ServerConfigMessage (0x98) - Server configuration and limits
+type ServerConfigMessage struct {
+	ProtocolVersion         uint8
+	MaxMessageRate          uint16
+	MaxChannelCreates       uint16
+	InactiveCleanupDays     uint16
+	MaxConnectionsPerIP     uint8
+	MaxMessageLength        uint32
+	MaxThreadSubscriptions  uint16
 	MaxChannelSubscriptions uint16
 	DirectoryEnabled        bool
+	CompressionSupported    bool // Server accepts SET_COMPRESSION (absent from older servers)
 }
 
 func (m *ServerConfigMessage) EncodeTo(w io.Writer) error {
@@ -1641,7 +2082,10 @@ func (m *ServerConfigMessage) EncodeTo(w io.Writer) error {
 	if err := WriteUint16(w, m.MaxChannelSubscriptions); err != nil {
 		return err
 	}
-	return WriteBool(w, m.DirectoryEnabled)
+	if err := WriteBool(w, m.DirectoryEnabled); err != nil {
+		return err
+	}
+	return WriteBool(w, m.CompressionSupported)
 }
 
 func (m *ServerConfigMessage) Encode() ([]byte, error) {
@@ -1702,6 +2146,42 @@ func (m *ServerConfigMessage) Decode(payload []byte) error {
 	}
 	m.DirectoryEnabled = directoryEnabled
 
+	// Older servers end the message here
+	if buf.Len() > 0 {
+		compressionSupported, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		m.CompressionSupported = compressionSupported
+	}
+
+	return nil
+}
+
+// SetCompressionMessage (0x21) - Ask the server to compress large frames sent to this client
+type SetCompressionMessage struct {
+	Enabled bool
+}
+
+func (m *SetCompressionMessage) EncodeTo(w io.Writer) error {
+	return WriteBool(w, m.Enabled)
+}
+
+func (m *SetCompressionMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SetCompressionMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	enabled, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	m.Enabled = enabled
 	return nil
 }
 
@@ -1737,7 +2217,10 @@ func (m *NewMessageMessage) EncodeTo(w io.Writer) error {
 	if err := WriteOptionalTimestamp(w, m.EditedAt); err != nil {
 		return err
 	}
-	return WriteUint32(w, m.ReplyCount)
+	if err := WriteUint32(w, m.ReplyCount); err != nil {
+		return err
+	}
+	return writeReactionSummaries(w, m.Reactions)
 }
 
 func (m *NewMessageMessage) Encode() ([]byte, error) {
@@ -1775,48 +2258,2316 @@ func (m *NewMessageMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
-	content, err := ReadString(buf)
-	if err != nil {
+	content, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	createdAt, err := ReadTimestamp(buf)
+	if err != nil {
+		return err
+	}
+	editedAt, err := ReadOptionalTimestamp(buf)
+	if err != nil {
+		return err
+	}
+	replyCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	reactions, err := readReactionSummaries(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ID = id
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ParentID = parentID
+	m.AuthorUserID = authorUserID
+	m.AuthorNickname = authorNickname
+	m.Content = content
+	m.CreatedAt = createdAt
+	m.EditedAt = editedAt
+	m.ReplyCount = replyCount
+	m.Reactions = reactions
+
+	return nil
+}
+
+// DisconnectMessage (0x11) - Graceful disconnect notification
+// Can be sent by either client or server to signal intentional disconnect
+type DisconnectMessage struct {
+	Reason *string // Optional reason for disconnect
+}
+
+func (m *DisconnectMessage) EncodeTo(w io.Writer) error {
+	return WriteOptionalString(w, m.Reason)
+}
+
+func (m *DisconnectMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DisconnectMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	reason, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+	m.Reason = reason
+	return nil
+}
+
+// SubscribeThreadMessage (0x51) - Subscribe to a thread
+type SubscribeThreadMessage struct {
+	ThreadID uint64
+}
+
+func (m *SubscribeThreadMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.ThreadID)
+}
+
+func (m *SubscribeThreadMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SubscribeThreadMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	threadID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.ThreadID = threadID
+	return nil
+}
+
+// UnsubscribeThreadMessage (0x52) - Unsubscribe from a thread
+type UnsubscribeThreadMessage struct {
+	ThreadID uint64
+}
+
+func (m *UnsubscribeThreadMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.ThreadID)
+}
+
+func (m *UnsubscribeThreadMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UnsubscribeThreadMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	threadID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.ThreadID = threadID
+	return nil
+}
+
+// SubscribeChannelMessage (0x53) - Subscribe to a channel/subchannel
+type SubscribeChannelMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+}
+
+func (m *SubscribeChannelMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.SubchannelID)
+}
+
+func (m *SubscribeChannelMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SubscribeChannelMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	return nil
+}
+
+// UnsubscribeChannelMessage (0x54) - Unsubscribe from a channel/subchannel
+type UnsubscribeChannelMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+}
+
+func (m *UnsubscribeChannelMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.SubchannelID)
+}
+
+func (m *UnsubscribeChannelMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UnsubscribeChannelMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	return nil
+}
+
+// SubscribeOkMessage (0x99) - Subscription confirmed
+type SubscribeOkMessage struct {
+	Type         uint8   // Type of subscription: 1=thread, 2=channel
+	ID           uint64  // thread_id or channel_id depending on Type
+	SubchannelID *uint64 // Present only for channel subscriptions (Type=2)
+}
+
+func (m *SubscribeOkMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint8(w, m.Type); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ID); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.SubchannelID)
+}
+
+func (m *SubscribeOkMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SubscribeOkMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	subType, err := ReadUint8(buf)
+	if err != nil {
+		return err
+	}
+	id, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.Type = subType
+	m.ID = id
+	m.SubchannelID = subchannelID
+	return nil
+}
+
+// GetUserInfoMessage (0x0F) - Request user information by nickname
+type GetUserInfoMessage struct {
+	Nickname string
+}
+
+func (m *GetUserInfoMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Nickname)
+}
+
+func (m *GetUserInfoMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *GetUserInfoMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	nickname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Nickname = nickname
+	return nil
+}
+
+// UserInfoMessage (0x8F) - User information response
+type UserInfoMessage struct {
+	Nickname     string
+	IsRegistered bool
+	UserID       *uint64 // Only present if IsRegistered = true
+	Online       bool
+
+	// Profile, empty for anonymous users and users who never set one
+	DisplayName string
+	Pronouns    string
+	Bio         string
+	Status      string
+	Away        bool // Online but idle
+}
+
+func (m *UserInfoMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.IsRegistered); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.Online); err != nil {
+		return err
+	}
+	for _, field := range []string{m.DisplayName, m.Pronouns, m.Bio, m.Status} {
+		if err := WriteString(w, field); err != nil {
+			return err
+		}
+	}
+	return WriteBool(w, m.Away)
+}
+
+func (m *UserInfoMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserInfoMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	nickname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	isRegistered, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	userID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	online, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	m.Nickname = nickname
+	m.IsRegistered = isRegistered
+	m.UserID = userID
+	m.Online = online
+	m.DisplayName, m.Pronouns, m.Bio, m.Status = "", "", "", ""
+	m.Away = false
+
+	// Older servers end the message here
+	if buf.Len() > 0 {
+		for _, field := range []*string{&m.DisplayName, &m.Pronouns, &m.Bio, &m.Status} {
+			if *field, err = ReadString(buf); err != nil {
+				return err
+			}
+		}
+		if m.Away, err = ReadBool(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListUsersMessage (0x16) - Request list of online or all users
+type ListUsersMessage struct {
+	Limit          uint16
+	IncludeOffline bool // Optional field - admin only
+}
+
+func (m *ListUsersMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, m.Limit); err != nil {
+		return err
+	}
+	// Write include_offline flag (optional, defaults to false)
+	return WriteBool(w, m.IncludeOffline)
+}
+
+func (m *ListUsersMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListUsersMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Limit = limit
+
+	// IncludeOffline is optional - if not present, defaults to false
+	includeOffline, err := ReadBool(buf)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == io.EOF {
+		// Field not present (backwards compatible with older clients)
+		m.IncludeOffline = false
+	} else {
+		m.IncludeOffline = includeOffline
+	}
+
+	return nil
+}
+
+// UserListEntry represents a single user in the user list
+type UserListEntry struct {
+	Nickname     string
+	IsRegistered bool
+	UserID       *uint64 // Only present if IsRegistered = true
+	Online       bool    // True if user has an active session
+}
+
+// UserListMessage (0x9A) - List of online users response
+type UserListMessage struct {
+	Users []UserListEntry
+}
+
+func (m *UserListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Users))); err != nil {
+		return err
+	}
+	for _, user := range m.Users {
+		if err := WriteString(w, user.Nickname); err != nil {
+			return err
+		}
+		if err := WriteBool(w, user.IsRegistered); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, user.UserID); err != nil {
+			return err
+		}
+		if err := WriteBool(w, user.Online); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *UserListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	userCount, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	users := make([]UserListEntry, userCount)
+	for i := uint16(0); i < userCount; i++ {
+		nickname, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		isRegistered, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		userID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		online, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		users[i] = UserListEntry{
+			Nickname:     nickname,
+			IsRegistered: isRegistered,
+			UserID:       userID,
+			Online:       online,
+		}
+	}
+
+	m.Users = users
+	return nil
+}
+
+// ListChannelUsersMessage (0x17) - Request list of users in a channel
+type ListChannelUsersMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+}
+
+func (m *ListChannelUsersMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.SubchannelID)
+}
+
+func (m *ListChannelUsersMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListChannelUsersMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	return nil
+}
+
+// ChannelUserEntry represents a single user currently present in a channel
+type ChannelUserEntry struct {
+	SessionID    uint64
+	Nickname     string
+	IsRegistered bool
+	UserID       *uint64
+	UserFlags    UserFlags
+}
+
+// ChannelUserListMessage (0xAB) - Snapshot of users in a channel
+type ChannelUserListMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	Users        []ChannelUserEntry
+}
+
+func (m *ChannelUserListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Users))); err != nil {
+		return err
+	}
+	for _, user := range m.Users {
+		if err := WriteUint64(w, user.SessionID); err != nil {
+			return err
+		}
+		if err := WriteString(w, user.Nickname); err != nil {
+			return err
+		}
+		if err := WriteBool(w, user.IsRegistered); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, user.UserID); err != nil {
+			return err
+		}
+		if err := WriteUint8(w, uint8(user.UserFlags)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ChannelUserListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelUserListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	userCount, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	users := make([]ChannelUserEntry, userCount)
+	for i := uint16(0); i < userCount; i++ {
+		sessionID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		nickname, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		isRegistered, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		userID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		flags, err := ReadUint8(buf)
+		if err != nil {
+			return err
+		}
+
+		users[i] = ChannelUserEntry{
+			SessionID:    sessionID,
+			Nickname:     nickname,
+			IsRegistered: isRegistered,
+			UserID:       userID,
+			UserFlags:    UserFlags(flags),
+		}
+	}
+
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.Users = users
+	return nil
+}
+
+// ChannelPresenceMessage (0xAC) - User joined or left a channel
+type ChannelPresenceMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	SessionID    uint64
+	Nickname     string
+	IsRegistered bool
+	UserID       *uint64
+	UserFlags    UserFlags
+	Joined       bool
+}
+
+func (m *ChannelPresenceMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.SessionID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.IsRegistered); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteUint8(w, uint8(m.UserFlags)); err != nil {
+		return err
+	}
+	return WriteBool(w, m.Joined)
+}
+
+func (m *ChannelPresenceMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChannelPresenceMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	sessionID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	nickname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	isRegistered, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	userID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	flags, err := ReadUint8(buf)
+	if err != nil {
+		return err
+	}
+	joined, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.SessionID = sessionID
+	m.Nickname = nickname
+	m.IsRegistered = isRegistered
+	m.UserID = userID
+	m.UserFlags = UserFlags(flags)
+	m.Joined = joined
+	return nil
+}
+
+// ServerPresenceMessage (0xAD) - User connected or disconnected from the server
+type ServerPresenceMessage struct {
+	SessionID    uint64
+	Nickname     string
+	IsRegistered bool
+	UserID       *uint64
+	UserFlags    UserFlags
+	Online       bool
+
+	// Profile, also sent when it changes or the user goes away or comes back
+	DisplayName string
+	Status      string
+	Away        bool
+}
+
+func (m *ServerPresenceMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.SessionID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.IsRegistered); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteUint8(w, uint8(m.UserFlags)); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.Online); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.DisplayName); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Status); err != nil {
+		return err
+	}
+	return WriteBool(w, m.Away)
+}
+
+func (m *ServerPresenceMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ServerPresenceMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	sessionID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	nickname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	isRegistered, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	userID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	flags, err := ReadUint8(buf)
+	if err != nil {
+		return err
+	}
+	online, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+
+	m.SessionID = sessionID
+	m.Nickname = nickname
+	m.IsRegistered = isRegistered
+	m.UserID = userID
+	m.UserFlags = UserFlags(flags)
+	m.Online = online
+	m.DisplayName, m.Status = "", ""
+	m.Away = false
+
+	// Older servers end the message here
+	if buf.Len() > 0 {
+		if m.DisplayName, err = ReadString(buf); err != nil {
+			return err
+		}
+		if m.Status, err = ReadString(buf); err != nil {
+			return err
+		}
+		if m.Away, err = ReadBool(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ===== Server Discovery Messages =====
+
+// ListServersMessage (0x55) - Request server list from directory
+type ListServersMessage struct {
+	Limit uint16
+}
+
+func (m *ListServersMessage) EncodeTo(w io.Writer) error {
+	return WriteUint16(w, m.Limit)
+}
+
+func (m *ListServersMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListServersMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.Limit = limit
+	return nil
+}
+
+// ServerInfo represents a single server in the server list
+type ServerInfo struct {
+	Hostname      string
+	Port          uint16
+	Name          string
+	Description   string
+	UserCount     uint32
+	MaxUsers      uint32
+	UptimeSeconds uint64
+	IsPublic      bool
+	ChannelCount  uint32
+}
+
+// ServerListMessage (0x9B) - List of discoverable servers
+type ServerListMessage struct {
+	Servers []ServerInfo
+}
+
+func (m *ServerListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Servers))); err != nil {
+		return err
+	}
+	for _, server := range m.Servers {
+		if err := WriteString(w, server.Hostname); err != nil {
+			return err
+		}
+		if err := WriteUint16(w, server.Port); err != nil {
+			return err
+		}
+		if err := WriteString(w, server.Name); err != nil {
+			return err
+		}
+		if err := WriteString(w, server.Description); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, server.UserCount); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, server.MaxUsers); err != nil {
+			return err
+		}
+		if err := WriteUint64(w, server.UptimeSeconds); err != nil {
+			return err
+		}
+		if err := WriteBool(w, server.IsPublic); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, server.ChannelCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ServerListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ServerListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	serverCount, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	servers := make([]ServerInfo, serverCount)
+	for i := uint16(0); i < serverCount; i++ {
+		hostname, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		port, err := ReadUint16(buf)
+		if err != nil {
+			return err
+		}
+		name, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		description, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		userCount, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+		maxUsers, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+		uptimeSeconds, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		isPublic, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		channelCount, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+
+		servers[i] = ServerInfo{
+			Hostname:      hostname,
+			Port:          port,
+			Name:          name,
+			Description:   description,
+			UserCount:     userCount,
+			MaxUsers:      maxUsers,
+			UptimeSeconds: uptimeSeconds,
+			IsPublic:      isPublic,
+			ChannelCount:  channelCount,
+		}
+	}
+
+	m.Servers = servers
+	return nil
+}
+
+// RegisterServerMessage (0x56) - Register server with directory
+type RegisterServerMessage struct {
+	Hostname     string
+	Port         uint16
+	Name         string
+	Description  string
+	MaxUsers     uint32
+	IsPublic     bool
+	ChannelCount uint32
+}
+
+func (m *RegisterServerMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Hostname); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, m.Port); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Name); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Description); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.MaxUsers); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.IsPublic); err != nil {
+		return err
+	}
+	return WriteUint32(w, m.ChannelCount)
+}
+
+func (m *RegisterServerMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *RegisterServerMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	hostname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	port, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	name, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	description, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	maxUsers, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	isPublic, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	channelCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Hostname = hostname
+	m.Port = port
+	m.Name = name
+	m.Description = description
+	m.MaxUsers = maxUsers
+	m.IsPublic = isPublic
+	m.ChannelCount = channelCount
+	return nil
+}
+
+// RegisterAckMessage (0x9C) - Server registration acknowledgment
+type RegisterAckMessage struct {
+	Success           bool
+	HeartbeatInterval uint32 // Only present if success = true
+	Message           string
+}
+
+func (m *RegisterAckMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteUint32(w, m.HeartbeatInterval); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *RegisterAckMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *RegisterAckMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+
+	if success {
+		heartbeatInterval, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+		m.HeartbeatInterval = heartbeatInterval
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+	return nil
+}
+
+// VerifyRegistrationMessage (0x9E) - Verification challenge
+type VerifyRegistrationMessage struct {
+	Challenge uint64
+}
+
+func (m *VerifyRegistrationMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.Challenge)
+}
+
+func (m *VerifyRegistrationMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *VerifyRegistrationMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	challenge, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.Challenge = challenge
+	return nil
+}
+
+// VerifyResponseMessage (0x58) - Response to verification challenge
+type VerifyResponseMessage struct {
+	Challenge uint64
+}
+
+func (m *VerifyResponseMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.Challenge)
+}
+
+func (m *VerifyResponseMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *VerifyResponseMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	challenge, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.Challenge = challenge
+	return nil
+}
+
+// HeartbeatMessage (0x57) - Periodic heartbeat to directory
+type HeartbeatMessage struct {
+	Hostname      string
+	Port          uint16
+	UserCount     uint32
+	UptimeSeconds uint64
+	ChannelCount  uint32
+}
+
+func (m *HeartbeatMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Hostname); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, m.Port); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, m.UserCount); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.UptimeSeconds); err != nil {
+		return err
+	}
+	return WriteUint32(w, m.ChannelCount)
+}
+
+func (m *HeartbeatMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *HeartbeatMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	hostname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	port, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	userCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	uptimeSeconds, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	channelCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Hostname = hostname
+	m.Port = port
+	m.UserCount = userCount
+	m.UptimeSeconds = uptimeSeconds
+	m.ChannelCount = channelCount
+	return nil
+}
+
+// HeartbeatAckMessage (0x9D) - Heartbeat acknowledgment with interval
+type HeartbeatAckMessage struct {
+	HeartbeatInterval uint32
+}
+
+func (m *HeartbeatAckMessage) EncodeTo(w io.Writer) error {
+	return WriteUint32(w, m.HeartbeatInterval)
+}
+
+func (m *HeartbeatAckMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *HeartbeatAckMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	heartbeatInterval, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	m.HeartbeatInterval = heartbeatInterval
+	return nil
+}
+
+// ===== CHANGE_PASSWORD (0x0E) - Client → Server =====
+
+// ChangePasswordRequest is sent by clients to change their password
+type ChangePasswordRequest struct {
+	OldPassword string // Empty for SSH-registered users changing password for first time
+	NewPassword string
+}
+
+func (m *ChangePasswordRequest) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.OldPassword); err != nil {
+		return err
+	}
+	return WriteString(w, m.NewPassword)
+}
+
+func (m *ChangePasswordRequest) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ChangePasswordRequest) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	oldPassword, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	newPassword, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.OldPassword = oldPassword
+	m.NewPassword = newPassword
+	return nil
+}
+
+// ===== PASSWORD_CHANGED (0x8E) - Server → Client =====
+
+// PasswordChangedResponse is sent by server after password change attempt
+type PasswordChangedResponse struct {
+	Success      bool
+	ErrorMessage string // Empty if success=true
+}
+
+func (m *PasswordChangedResponse) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	return WriteString(w, m.ErrorMessage)
+}
+
+func (m *PasswordChangedResponse) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *PasswordChangedResponse) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	errorMessage, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+	m.ErrorMessage = errorMessage
+	return nil
+}
+
+// ===== ADD_SSH_KEY (0x0D) - Client → Server =====
+
+// AddSSHKeyRequest is sent by clients to add a new SSH public key to their account
+type AddSSHKeyRequest struct {
+	PublicKey string // Full SSH public key (e.g., "ssh-rsa AAAA... user@host")
+	Label     string // Optional user-friendly label (e.g., "Work Laptop")
+}
+
+func (m *AddSSHKeyRequest) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.PublicKey); err != nil {
+		return err
+	}
+	return WriteString(w, m.Label)
+}
+
+func (m *AddSSHKeyRequest) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *AddSSHKeyRequest) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	publicKey, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	label, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.PublicKey = publicKey
+	m.Label = label
+	return nil
+}
+
+// ===== SSH_KEY_ADDED (0x95) - Server → Client =====
+
+// SSHKeyAddedResponse is sent by server after adding an SSH key
+type SSHKeyAddedResponse struct {
+	Success      bool
+	KeyID        int64  // Database ID of the added key
+	Fingerprint  string // SHA256 fingerprint
+	ErrorMessage string // Empty if success=true
+}
+
+func (m *SSHKeyAddedResponse) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteInt64(w, m.KeyID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Fingerprint); err != nil {
+		return err
+	}
+	return WriteString(w, m.ErrorMessage)
+}
+
+func (m *SSHKeyAddedResponse) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SSHKeyAddedResponse) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	keyID, err := ReadInt64(buf)
+	if err != nil {
+		return err
+	}
+	fingerprint, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	errorMessage, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+	m.KeyID = keyID
+	m.Fingerprint = fingerprint
+	m.ErrorMessage = errorMessage
+	return nil
+}
+
+// ===== LIST_SSH_KEYS (0x14) - Client → Server =====
+
+// ListSSHKeysRequest is sent by clients to retrieve their SSH keys
+type ListSSHKeysRequest struct {
+	// No fields - user is identified from session
+}
+
+func (m *ListSSHKeysRequest) EncodeTo(w io.Writer) error {
+	// No data to encode
+	return nil
+}
+
+func (m *ListSSHKeysRequest) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListSSHKeysRequest) Decode(payload []byte) error {
+	// No data to decode
+	return nil
+}
+
+// ===== SSH_KEY_LIST (0x94) - Server → Client =====
+
+// SSHKeyInfo represents a single SSH key in the list
+type SSHKeyInfo struct {
+	ID          int64
+	Fingerprint string
+	KeyType     string // ssh-rsa, ssh-ed25519, etc.
+	Label       string // May be empty
+	AddedAt     int64  // Unix milliseconds
+	LastUsedAt  int64  // Unix milliseconds (0 if never used)
+}
+
+// SSHKeyListResponse is sent by server with list of user's SSH keys
+type SSHKeyListResponse struct {
+	Keys []SSHKeyInfo
+}
+
+func (m *SSHKeyListResponse) EncodeTo(w io.Writer) error {
+	// Write number of keys
+	if err := WriteUint32(w, uint32(len(m.Keys))); err != nil {
+		return err
+	}
+
+	// Write each key
+	for _, key := range m.Keys {
+		if err := WriteInt64(w, key.ID); err != nil {
+			return err
+		}
+		if err := WriteString(w, key.Fingerprint); err != nil {
+			return err
+		}
+		if err := WriteString(w, key.KeyType); err != nil {
+			return err
+		}
+		if err := WriteString(w, key.Label); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, key.AddedAt); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, key.LastUsedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SSHKeyListResponse) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SSHKeyListResponse) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	// Read number of keys
+	count, err := ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Keys = make([]SSHKeyInfo, count)
+
+	// Read each key
+	for i := uint32(0); i < count; i++ {
+		id, err := ReadInt64(buf)
+		if err != nil {
+			return err
+		}
+		fingerprint, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		keyType, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		label, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		addedAt, err := ReadInt64(buf)
+		if err != nil {
+			return err
+		}
+		lastUsedAt, err := ReadInt64(buf)
+		if err != nil {
+			return err
+		}
+
+		m.Keys[i] = SSHKeyInfo{
+			ID:          id,
+			Fingerprint: fingerprint,
+			KeyType:     keyType,
+			Label:       label,
+			AddedAt:     addedAt,
+			LastUsedAt:  lastUsedAt,
+		}
+	}
+	return nil
+}
+
+// ===== UPDATE_SSH_KEY_LABEL (0x12) - Client → Server =====
+
+// UpdateSSHKeyLabelRequest is sent by clients to update an SSH key's label
+type UpdateSSHKeyLabelRequest struct {
+	KeyID    int64
+	NewLabel string
+}
+
+func (m *UpdateSSHKeyLabelRequest) EncodeTo(w io.Writer) error {
+	if err := WriteInt64(w, m.KeyID); err != nil {
+		return err
+	}
+	return WriteString(w, m.NewLabel)
+}
+
+func (m *UpdateSSHKeyLabelRequest) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UpdateSSHKeyLabelRequest) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	keyID, err := ReadInt64(buf)
+	if err != nil {
+		return err
+	}
+	newLabel, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.KeyID = keyID
+	m.NewLabel = newLabel
+	return nil
+}
+
+// ===== SSH_KEY_LABEL_UPDATED (0x92) - Server → Client =====
+
+// SSHKeyLabelUpdatedResponse is sent by server after updating an SSH key label
+type SSHKeyLabelUpdatedResponse struct {
+	Success      bool
+	ErrorMessage string // Empty if success=true
+}
+
+func (m *SSHKeyLabelUpdatedResponse) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	return WriteString(w, m.ErrorMessage)
+}
+
+func (m *SSHKeyLabelUpdatedResponse) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SSHKeyLabelUpdatedResponse) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	errorMessage, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+	m.ErrorMessage = errorMessage
+	return nil
+}
+
+// ===== DELETE_SSH_KEY (0x13) - Client → Server =====
+
+// DeleteSSHKeyRequest is sent by clients to delete an SSH key
+type DeleteSSHKeyRequest struct {
+	KeyID int64
+}
+
+func (m *DeleteSSHKeyRequest) EncodeTo(w io.Writer) error {
+	return WriteInt64(w, m.KeyID)
+}
+
+func (m *DeleteSSHKeyRequest) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DeleteSSHKeyRequest) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	keyID, err := ReadInt64(buf)
+	if err != nil {
+		return err
+	}
+	m.KeyID = keyID
+	return nil
+}
+
+// ===== SSH_KEY_DELETED (0x93) - Server → Client =====
+
+// SSHKeyDeletedResponse is sent by server after deleting an SSH key
+type SSHKeyDeletedResponse struct {
+	Success      bool
+	ErrorMessage string // Empty if success=true
+}
+
+func (m *SSHKeyDeletedResponse) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	return WriteString(w, m.ErrorMessage)
+}
+
+func (m *SSHKeyDeletedResponse) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *SSHKeyDeletedResponse) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	errorMessage, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+	m.ErrorMessage = errorMessage
+	return nil
+}
+
+// ===== ADMIN COMMANDS =====
+
+// BanUserMessage (0x59) - Ban a user by user ID or nickname
+type BanUserMessage struct {
+	UserID          *uint64 // Optional: user ID to ban (takes precedence if provided)
+	Nickname        *string // Optional: nickname to ban (if user_id not provided)
+	Reason          string
+	Shadowban       bool
+	DurationSeconds *uint64 // NULL = permanent ban
+	ChannelID       *uint64 // Only ban from posting in this channel (NULL = the whole server)
+}
+
+func (m *BanUserMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Reason); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.Shadowban); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.DurationSeconds); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.ChannelID)
+}
+
+func (m *BanUserMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *BanUserMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	userID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	nickname, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+	reason, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	shadowban, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	durationSeconds, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.UserID = userID
+	m.Nickname = nickname
+	m.Reason = reason
+	m.Shadowban = shadowban
+	m.DurationSeconds = durationSeconds
+	m.ChannelID = nil
+
+	// Older clients end the message here
+	if buf.Len() > 0 {
+		channelID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		m.ChannelID = channelID
+	}
+	return nil
+}
+
+// UserBannedMessage (0x9F) - Response to BAN_USER
+type UserBannedMessage struct {
+	Success bool
+	BanID   uint64 // Only present if Success=true
+	Message string
+}
+
+func (m *UserBannedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteUint64(w, m.BanID); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *UserBannedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserBannedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+
+	if success {
+		banID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		m.BanID = banID
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+	return nil
+}
+
+// BanIPMessage (0x5A) - Ban an IP address or CIDR range
+type BanIPMessage struct {
+	IPCIDR          string // IP address or CIDR range (e.g., "10.0.0.5/32", "192.168.1.0/24")
+	Reason          string
+	DurationSeconds *uint64 // NULL = permanent ban
+}
+
+func (m *BanIPMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.IPCIDR); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Reason); err != nil {
+		return err
+	}
+	return WriteOptionalUint64(w, m.DurationSeconds)
+}
+
+func (m *BanIPMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *BanIPMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	ipCIDR, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	reason, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	durationSeconds, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.IPCIDR = ipCIDR
+	m.Reason = reason
+	m.DurationSeconds = durationSeconds
+	return nil
+}
+
+// IPBannedMessage (0xA5) - Response to BAN_IP
+type IPBannedMessage struct {
+	Success bool
+	BanID   uint64 // Only present if Success=true
+	Message string
+}
+
+func (m *IPBannedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if m.Success {
+		if err := WriteUint64(w, m.BanID); err != nil {
+			return err
+		}
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *IPBannedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *IPBannedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+
+	if success {
+		banID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		m.BanID = banID
+	}
+
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+	return nil
+}
+
+// UnbanUserMessage (0x5B) - Remove user ban
+type UnbanUserMessage struct {
+	UserID   *uint64 // Optional: user ID to unban
+	Nickname *string // Optional: nickname to unban
+}
+
+func (m *UnbanUserMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+		return err
+	}
+	return WriteOptionalString(w, m.Nickname)
+}
+
+func (m *UnbanUserMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UnbanUserMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	userID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	nickname, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.UserID = userID
+	m.Nickname = nickname
+	return nil
+}
+
+// UserUnbannedMessage (0xA6) - Response to UNBAN_USER
+type UserUnbannedMessage struct {
+	Success bool
+	Message string
+}
+
+func (m *UserUnbannedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *UserUnbannedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UserUnbannedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Success = success
+	m.Message = message
+	return nil
+}
+
+// UnbanIPMessage (0x5C) - Remove IP ban
+type UnbanIPMessage struct {
+	IPCIDR string // IP address or CIDR range to unban
+}
+
+func (m *UnbanIPMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.IPCIDR)
+}
+
+func (m *UnbanIPMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *UnbanIPMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	ipCIDR, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.IPCIDR = ipCIDR
+	return nil
+}
+
+// IPUnbannedMessage (0xA7) - Response to UNBAN_IP
+type IPUnbannedMessage struct {
+	Success bool
+	Message string
+}
+
+func (m *IPUnbannedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
+}
+
+func (m *IPUnbannedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *IPUnbannedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Success = success
+	m.Message = message
+	return nil
+}
+
+// ListBansMessage (0x5D) - Request list of all active bans
+type ListBansMessage struct {
+	IncludeExpired bool
+}
+
+func (m *ListBansMessage) EncodeTo(w io.Writer) error {
+	return WriteBool(w, m.IncludeExpired)
+}
+
+func (m *ListBansMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *ListBansMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	includeExpired, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+
+	m.IncludeExpired = includeExpired
+	return nil
+}
+
+// BanEntry represents a single ban in the ban list
+type BanEntry struct {
+	ID          uint64
+	Type        string  // "user" or "ip"
+	UserID      *uint64 // NULL for IP bans
+	Nickname    *string // NULL for IP bans
+	IPCIDR      *string // NULL for user bans
+	Reason      string
+	Shadowban   bool
+	BannedAt    int64   // Unix milliseconds
+	BannedUntil *int64  // NULL = permanent, Unix milliseconds for timed bans
+	BannedBy    string  // Admin nickname
+	ChannelID   *uint64 // Channel a user ban is limited to (NULL = the whole server)
+}
+
+// BanListMessage (0xA8) - List of active bans. The channels of the bans follow the
+// list, so older clients can still read it.
+type BanListMessage struct {
+	Bans []BanEntry
+}
+
+func (m *BanListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Bans))); err != nil {
+		return err
+	}
+
+	for _, ban := range m.Bans {
+		if err := WriteUint64(w, ban.ID); err != nil {
+			return err
+		}
+		if err := WriteString(w, ban.Type); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, ban.UserID); err != nil {
+			return err
+		}
+		if err := WriteOptionalString(w, ban.Nickname); err != nil {
+			return err
+		}
+		if err := WriteOptionalString(w, ban.IPCIDR); err != nil {
+			return err
+		}
+		if err := WriteString(w, ban.Reason); err != nil {
+			return err
+		}
+		if err := WriteBool(w, ban.Shadowban); err != nil {
+			return err
+		}
+		if err := WriteInt64(w, ban.BannedAt); err != nil {
+			return err
+		}
+		if err := WriteOptionalInt64(w, ban.BannedUntil); err != nil {
+			return err
+		}
+		if err := WriteString(w, ban.BannedBy); err != nil {
+			return err
+		}
+	}
+
+	for _, ban := range m.Bans {
+		if err := WriteOptionalUint64(w, ban.ChannelID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *BanListMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *BanListMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Bans = make([]BanEntry, count)
+
+	for i := uint16(0); i < count; i++ {
+		id, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		banType, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		userID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		nickname, err := ReadOptionalString(buf)
+		if err != nil {
+			return err
+		}
+		ipCIDR, err := ReadOptionalString(buf)
+		if err != nil {
+			return err
+		}
+		reason, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+		shadowban, err := ReadBool(buf)
+		if err != nil {
+			return err
+		}
+		bannedAt, err := ReadInt64(buf)
+		if err != nil {
+			return err
+		}
+		bannedUntil, err := ReadOptionalInt64(buf)
+		if err != nil {
+			return err
+		}
+		bannedBy, err := ReadString(buf)
+		if err != nil {
+			return err
+		}
+
+		m.Bans[i] = BanEntry{
+			ID:          id,
+			Type:        banType,
+			UserID:      userID,
+			Nickname:    nickname,
+			IPCIDR:      ipCIDR,
+			Reason:      reason,
+			Shadowban:   shadowban,
+			BannedAt:    bannedAt,
+			BannedUntil: bannedUntil,
+			BannedBy:    bannedBy,
+		}
+	}
+
+	// Older servers end the message here
+	if buf.Len() > 0 {
+		for i := range m.Bans {
+			channelID, err := ReadOptionalUint64(buf)
+			if err != nil {
+				return err
+			}
+			m.Bans[i].ChannelID = channelID
+		}
+	}
+
+	return nil
+}
+
+// DeleteUserMessage (0x5E) - Permanently delete a user account
+type DeleteUserMessage struct {
+	UserID uint64
+}
+
+func (m *DeleteUserMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.UserID)
+}
+
+func (m *DeleteUserMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *DeleteUserMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	userID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+
+	m.UserID = userID
+	return nil
+}
+
+// UserDeletedMessage (0xA9) - Response to DELETE_USER
+type UserDeletedMessage struct {
+	Success bool
+	Message string
+}
+
+func (m *UserDeletedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
-	createdAt, err := ReadTimestamp(buf)
-	if err != nil {
-		return err
+	return WriteString(w, m.Message)
+}
+
+func (m *UserDeletedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
 	}
-	editedAt, err := ReadOptionalTimestamp(buf)
+	return buf.Bytes(), nil
+}
+
+func (m *UserDeletedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	replyCount, err := ReadUint32(buf)
+	message, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
 
-	m.ID = id
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.ParentID = parentID
-	m.AuthorUserID = authorUserID
-	m.AuthorNickname = authorNickname
-	m.Content = content
-	m.CreatedAt = createdAt
-	m.EditedAt = editedAt
-	m.ReplyCount = replyCount
-
+	m.Success = success
+	m.Message = message
 	return nil
 }
 
-// DisconnectMessage (0x11) - Graceful disconnect notification
-// Can be sent by either client or server to signal intentional disconnect
-type DisconnectMessage struct {
-	Reason *string // Optional reason for disconnect
+// DeleteChannelMessage (0x5F) - Delete a channel (admin only)
+type DeleteChannelMessage struct {
+	ChannelID uint64
+	Reason    string
 }
 
-func (m *DisconnectMessage) EncodeTo(w io.Writer) error {
-	return WriteOptionalString(w, m.Reason)
+func (m *DeleteChannelMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Reason)
 }
 
-func (m *DisconnectMessage) Encode() ([]byte, error) {
+func (m *DeleteChannelMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1824,26 +4575,40 @@ func (m *DisconnectMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *DisconnectMessage) Decode(payload []byte) error {
+func (m *DeleteChannelMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	reason, err := ReadOptionalString(buf)
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	reason, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
+
+	m.ChannelID = channelID
 	m.Reason = reason
 	return nil
 }
 
-// SubscribeThreadMessage (0x51) - Subscribe to a thread
-type SubscribeThreadMessage struct {
-	ThreadID uint64
+// ChannelDeletedMessage (0xAA) - Response to DELETE_CHANNEL + broadcast
+type ChannelDeletedMessage struct {
+	Success   bool
+	ChannelID uint64
+	Message   string
 }
 
-func (m *SubscribeThreadMessage) EncodeTo(w io.Writer) error {
-	return WriteUint64(w, m.ThreadID)
+func (m *ChannelDeletedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Message)
 }
 
-func (m *SubscribeThreadMessage) Encode() ([]byte, error) {
+func (m *ChannelDeletedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1851,26 +4616,62 @@ func (m *SubscribeThreadMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SubscribeThreadMessage) Decode(payload []byte) error {
+func (m *ChannelDeletedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	threadID, err := ReadUint64(buf)
+	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	m.ThreadID = threadID
+	channelID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.Success = success
+	m.ChannelID = channelID
+	m.Message = message
 	return nil
 }
 
-// UnsubscribeThreadMessage (0x52) - Unsubscribe from a thread
-type UnsubscribeThreadMessage struct {
-	ThreadID uint64
+// UnreadTarget represents a channel/subchannel/thread for unread count requests
+type UnreadTarget struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	ThreadID     *uint64 // Optional: if present, count only messages in this thread
 }
 
-func (m *UnsubscribeThreadMessage) EncodeTo(w io.Writer) error {
-	return WriteUint64(w, m.ThreadID)
+// GetUnreadCountsMessage (0x18) - Request unread counts for channels
+type GetUnreadCountsMessage struct {
+	SinceTimestamp *int64         // Optional: if null, uses server's stored last_read_at (registered users only)
+	Targets        []UnreadTarget // Channels/subchannels to get counts for
 }
 
-func (m *UnsubscribeThreadMessage) Encode() ([]byte, error) {
+func (m *GetUnreadCountsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalInt64(w, m.SinceTimestamp); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Targets))); err != nil {
+		return err
+	}
+	for _, target := range m.Targets {
+		if err := WriteUint64(w, target.ChannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, target.SubchannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, target.ThreadID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *GetUnreadCountsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1878,30 +4679,76 @@ func (m *UnsubscribeThreadMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UnsubscribeThreadMessage) Decode(payload []byte) error {
+func (m *GetUnreadCountsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	threadID, err := ReadUint64(buf)
+	sinceTimestamp, err := ReadOptionalInt64(buf)
 	if err != nil {
 		return err
 	}
-	m.ThreadID = threadID
+	targetCount, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	targets := make([]UnreadTarget, targetCount)
+	for i := uint16(0); i < targetCount; i++ {
+		channelID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		subchannelID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		threadID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		targets[i] = UnreadTarget{
+			ChannelID:    channelID,
+			SubchannelID: subchannelID,
+			ThreadID:     threadID,
+		}
+	}
+	m.SinceTimestamp = sinceTimestamp
+	m.Targets = targets
 	return nil
 }
 
-// SubscribeChannelMessage (0x53) - Subscribe to a channel/subchannel
-type SubscribeChannelMessage struct {
+// UnreadCount represents unread count for a single channel/subchannel/thread
+type UnreadCount struct {
 	ChannelID    uint64
 	SubchannelID *uint64
+	ThreadID     *uint64 // Optional: if present, this count is for a specific thread
+	UnreadCount  uint32
 }
 
-func (m *SubscribeChannelMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.ChannelID); err != nil {
+// UnreadCountsMessage (0x97) - Response with unread counts
+type UnreadCountsMessage struct {
+	Counts []UnreadCount
+}
+
+func (m *UnreadCountsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Counts))); err != nil {
 		return err
 	}
-	return WriteOptionalUint64(w, m.SubchannelID)
+	for _, count := range m.Counts {
+		if err := WriteUint64(w, count.ChannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, count.SubchannelID); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, count.ThreadID); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, count.UnreadCount); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (m *SubscribeChannelMessage) Encode() ([]byte, error) {
+func (m *UnreadCountsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1909,35 +4756,59 @@ func (m *SubscribeChannelMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SubscribeChannelMessage) Decode(payload []byte) error {
+func (m *UnreadCountsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	channelID, err := ReadUint64(buf)
+	countCount, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	subchannelID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
+	counts := make([]UnreadCount, countCount)
+	for i := uint16(0); i < countCount; i++ {
+		channelID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		subchannelID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		threadID, err := ReadOptionalUint64(buf)
+		if err != nil {
+			return err
+		}
+		unreadCount, err := ReadUint32(buf)
+		if err != nil {
+			return err
+		}
+		counts[i] = UnreadCount{
+			ChannelID:    channelID,
+			SubchannelID: subchannelID,
+			ThreadID:     threadID,
+			UnreadCount:  unreadCount,
+		}
 	}
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
+	m.Counts = counts
 	return nil
 }
 
-// UnsubscribeChannelMessage (0x54) - Unsubscribe from a channel/subchannel
-type UnsubscribeChannelMessage struct {
+// UpdateReadStateMessage (0x1D) - Update last read timestamp for a channel
+type UpdateReadStateMessage struct {
 	ChannelID    uint64
 	SubchannelID *uint64
+	Timestamp    int64 // Unix timestamp (seconds)
 }
 
-func (m *UnsubscribeChannelMessage) EncodeTo(w io.Writer) error {
+func (m *UpdateReadStateMessage) EncodeTo(w io.Writer) error {
 	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	return WriteOptionalUint64(w, m.SubchannelID)
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	return WriteInt64(w, m.Timestamp)
 }
 
-func (m *UnsubscribeChannelMessage) Encode() ([]byte, error) {
+func (m *UpdateReadStateMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1945,7 +4816,7 @@ func (m *UnsubscribeChannelMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UnsubscribeChannelMessage) Decode(payload []byte) error {
+func (m *UpdateReadStateMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	channelID, err := ReadUint64(buf)
 	if err != nil {
@@ -1955,29 +4826,74 @@ func (m *UnsubscribeChannelMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
+	timestamp, err := ReadInt64(buf)
+	if err != nil {
+		return err
+	}
 	m.ChannelID = channelID
 	m.SubchannelID = subchannelID
+	m.Timestamp = timestamp
 	return nil
 }
 
-// SubscribeOkMessage (0x99) - Subscription confirmed
-type SubscribeOkMessage struct {
-	Type         uint8   // Type of subscription: 1=thread, 2=channel
-	ID           uint64  // thread_id or channel_id depending on Type
-	SubchannelID *uint64 // Present only for channel subscriptions (Type=2)
-}
+// DM target types for START_DM and ADD_DM_PARTICIPANT
+const (
+	DMTargetUserID    = 0x00 // target is a registered user_id (u64)
+	DMTargetNickname  = 0x01 // target is a nickname (String)
+	DMTargetSessionID = 0x02 // target is a session_id (u64)
+)
 
-func (m *SubscribeOkMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint8(w, m.Type); err != nil {
+// writeDMTarget encodes a DM target (target_type followed by a type-dependent target_id)
+func writeDMTarget(w io.Writer, targetType uint8, targetID uint64, targetNickname string) error {
+	if err := WriteUint8(w, targetType); err != nil {
 		return err
 	}
-	if err := WriteUint64(w, m.ID); err != nil {
+	if targetType == DMTargetNickname {
+		return WriteString(w, targetNickname)
+	}
+	return WriteUint64(w, targetID)
+}
+
+// readDMTarget decodes a DM target written by writeDMTarget
+func readDMTarget(r io.Reader) (uint8, uint64, string, error) {
+	targetType, err := ReadUint8(r)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	switch targetType {
+	case DMTargetNickname:
+		nickname, err := ReadString(r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		return targetType, 0, nickname, nil
+	case DMTargetUserID, DMTargetSessionID:
+		targetID, err := ReadUint64(r)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		return targetType, targetID, "", nil
+	default:
+		return 0, 0, "", ErrInvalidDMTarget
+	}
+}
+
+// StartDMMessage (0x19) - Initiate a direct message conversation
+type StartDMMessage struct {
+	TargetType       uint8  // DMTargetUserID, DMTargetNickname or DMTargetSessionID
+	TargetID         uint64 // user_id or session_id (unused for nickname targets)
+	TargetNickname   string // nickname (only for DMTargetNickname)
+	AllowUnencrypted bool
+}
+
+func (m *StartDMMessage) EncodeTo(w io.Writer) error {
+	if err := writeDMTarget(w, m.TargetType, m.TargetID, m.TargetNickname); err != nil {
 		return err
 	}
-	return WriteOptionalUint64(w, m.SubchannelID)
+	return WriteBool(w, m.AllowUnencrypted)
 }
 
-func (m *SubscribeOkMessage) Encode() ([]byte, error) {
+func (m *StartDMMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -1985,36 +4901,40 @@ func (m *SubscribeOkMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SubscribeOkMessage) Decode(payload []byte) error {
+func (m *StartDMMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	subType, err := ReadUint8(buf)
+	targetType, targetID, targetNickname, err := readDMTarget(buf)
 	if err != nil {
 		return err
 	}
-	id, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
-	subchannelID, err := ReadOptionalUint64(buf)
+	allowUnencrypted, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	m.Type = subType
-	m.ID = id
-	m.SubchannelID = subchannelID
+
+	m.TargetType = targetType
+	m.TargetID = targetID
+	m.TargetNickname = targetNickname
+	m.AllowUnencrypted = allowUnencrypted
 	return nil
 }
 
-// GetUserInfoMessage (0x0F) - Request user information by nickname
-type GetUserInfoMessage struct {
-	Nickname string
+// AddDMParticipantMessage (0x1E) - Add another user to an existing DM (small-group DMs)
+type AddDMParticipantMessage struct {
+	ChannelID      uint64
+	TargetType     uint8
+	TargetID       uint64
+	TargetNickname string
 }
 
-func (m *GetUserInfoMessage) EncodeTo(w io.Writer) error {
-	return WriteString(w, m.Nickname)
+func (m *AddDMParticipantMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	return writeDMTarget(w, m.TargetType, m.TargetID, m.TargetNickname)
 }
 
-func (m *GetUserInfoMessage) Encode() ([]byte, error) {
+func (m *AddDMParticipantMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2022,38 +4942,67 @@ func (m *GetUserInfoMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *GetUserInfoMessage) Decode(payload []byte) error {
+func (m *AddDMParticipantMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	nickname, err := ReadString(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.Nickname = nickname
+	targetType, targetID, targetNickname, err := readDMTarget(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ChannelID = channelID
+	m.TargetType = targetType
+	m.TargetID = targetID
+	m.TargetNickname = targetNickname
 	return nil
 }
 
-// UserInfoMessage (0x8F) - User information response
-type UserInfoMessage struct {
-	Nickname     string
-	IsRegistered bool
-	UserID       *uint64 // Only present if IsRegistered = true
-	Online       bool
+// ListDMsMessage (0x1F) - Request the DM conversations the user participates in
+type ListDMsMessage struct{}
+
+func (m *ListDMsMessage) EncodeTo(w io.Writer) error {
+	// Empty message
+	return nil
 }
 
-func (m *UserInfoMessage) EncodeTo(w io.Writer) error {
-	if err := WriteString(w, m.Nickname); err != nil {
+func (m *ListDMsMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *ListDMsMessage) Decode(payload []byte) error {
+	// Empty message - nothing to decode
+	return nil
+}
+
+// DMReadyMessage (0xA2) - DM channel is ready to use
+type DMReadyMessage struct {
+	ChannelID     uint64
+	OtherUserID   *uint64 // nil for group DMs
+	OtherNickname string  // comma-separated for group DMs
+	IsEncrypted   bool
+	ChannelKey    *string
+}
+
+func (m *DMReadyMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	if err := WriteBool(w, m.IsRegistered); err != nil {
+	if err := WriteOptionalUint64(w, m.OtherUserID); err != nil {
 		return err
 	}
-	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+	if err := WriteString(w, m.OtherNickname); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.IsEncrypted); err != nil {
 		return err
 	}
-	return WriteBool(w, m.Online)
+	return WriteOptionalString(w, m.ChannelKey)
 }
 
-func (m *UserInfoMessage) Encode() ([]byte, error) {
+func (m *DMReadyMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2061,46 +5010,63 @@ func (m *UserInfoMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UserInfoMessage) Decode(payload []byte) error {
+func (m *DMReadyMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	nickname, err := ReadString(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	isRegistered, err := ReadBool(buf)
+	otherUserID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-	userID, err := ReadOptionalUint64(buf)
+	otherNickname, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	online, err := ReadBool(buf)
+	isEncrypted, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	m.Nickname = nickname
-	m.IsRegistered = isRegistered
-	m.UserID = userID
-	m.Online = online
+	channelKey, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.ChannelID = channelID
+	m.OtherUserID = otherUserID
+	m.OtherNickname = otherNickname
+	m.IsEncrypted = isEncrypted
+	m.ChannelKey = channelKey
 	return nil
 }
 
-// ListUsersMessage (0x16) - Request list of online or all users
-type ListUsersMessage struct {
-	Limit          uint16
-	IncludeOffline bool // Optional field - admin only
+// DMRequestMessage (0xA4) - Incoming DM request from another user
+type DMRequestMessage struct {
+	ChannelID                  uint64
+	FromUserID                 *uint64
+	FromNickname               string
+	RequiresKey                bool
+	InitiatorAllowsUnencrypted bool
 }
 
-func (m *ListUsersMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint16(w, m.Limit); err != nil {
+func (m *DMRequestMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	// Write include_offline flag (optional, defaults to false)
-	return WriteBool(w, m.IncludeOffline)
+	if err := WriteOptionalUint64(w, m.FromUserID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.FromNickname); err != nil {
+		return err
+	}
+	if err := WriteBool(w, m.RequiresKey); err != nil {
+		return err
+	}
+	return WriteBool(w, m.InitiatorAllowsUnencrypted)
 }
 
-func (m *ListUsersMessage) Encode() ([]byte, error) {
+func (m *DMRequestMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2108,64 +5074,86 @@ func (m *ListUsersMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ListUsersMessage) Decode(payload []byte) error {
+func (m *DMRequestMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	limit, err := ReadUint16(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.Limit = limit
-
-	// IncludeOffline is optional - if not present, defaults to false
-	includeOffline, err := ReadBool(buf)
-	if err != nil && err != io.EOF {
+	fromUserID, err := ReadOptionalUint64(buf)
+	if err != nil {
 		return err
 	}
-	if err == io.EOF {
-		// Field not present (backwards compatible with older clients)
-		m.IncludeOffline = false
-	} else {
-		m.IncludeOffline = includeOffline
+	fromNickname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	requiresKey, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	initiatorAllowsUnencrypted, err := ReadBool(buf)
+	if err != nil {
+		return err
 	}
 
+	m.ChannelID = channelID
+	m.FromUserID = fromUserID
+	m.FromNickname = fromNickname
+	m.RequiresKey = requiresKey
+	m.InitiatorAllowsUnencrypted = initiatorAllowsUnencrypted
 	return nil
 }
 
-// UserListEntry represents a single user in the user list
-type UserListEntry struct {
-	Nickname     string
-	IsRegistered bool
-	UserID       *uint64 // Only present if IsRegistered = true
-	Online       bool    // True if user has an active session
+// DMParticipant represents one member of a DM conversation
+type DMParticipant struct {
+	UserID   uint64
+	Nickname string
 }
 
-// UserListMessage (0x9A) - List of online users response
-type UserListMessage struct {
-	Users []UserListEntry
+// DMChannel represents a DM conversation in DM_LIST
+type DMChannel struct {
+	ChannelID    uint64
+	IsEncrypted  bool
+	ChannelKey   *string         // Channel key wrapped for the requesting session's public key (encrypted DMs only)
+	Participants []DMParticipant // Other participants (excludes the requesting user)
 }
 
-func (m *UserListMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint16(w, uint16(len(m.Users))); err != nil {
+// DMListMessage (0xAE) - DM conversations the user participates in
+type DMListMessage struct {
+	DMs []DMChannel
+}
+
+func (m *DMListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.DMs))); err != nil {
 		return err
 	}
-	for _, user := range m.Users {
-		if err := WriteString(w, user.Nickname); err != nil {
+	for _, dm := range m.DMs {
+		if err := WriteUint64(w, dm.ChannelID); err != nil {
 			return err
 		}
-		if err := WriteBool(w, user.IsRegistered); err != nil {
+		if err := WriteBool(w, dm.IsEncrypted); err != nil {
 			return err
 		}
-		if err := WriteOptionalUint64(w, user.UserID); err != nil {
+		if err := WriteOptionalString(w, dm.ChannelKey); err != nil {
 			return err
 		}
-		if err := WriteBool(w, user.Online); err != nil {
+		if err := WriteUint8(w, uint8(len(dm.Participants))); err != nil {
 			return err
 		}
+		for _, p := range dm.Participants {
+			if err := WriteUint64(w, p.UserID); err != nil {
+				return err
+			}
+			if err := WriteString(w, p.Nickname); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func (m *UserListMessage) Encode() ([]byte, error) {
+func (m *DMListMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2173,57 +5161,82 @@ func (m *UserListMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UserListMessage) Decode(payload []byte) error {
+func (m *DMListMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	userCount, err := ReadUint16(buf)
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
 
-	users := make([]UserListEntry, userCount)
-	for i := uint16(0); i < userCount; i++ {
-		nickname, err := ReadString(buf)
+	dms := make([]DMChannel, count)
+	for i := uint16(0); i < count; i++ {
+		channelID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		isRegistered, err := ReadBool(buf)
+		isEncrypted, err := ReadBool(buf)
 		if err != nil {
 			return err
 		}
-		userID, err := ReadOptionalUint64(buf)
+		channelKey, err := ReadOptionalString(buf)
 		if err != nil {
 			return err
 		}
-		online, err := ReadBool(buf)
+		participantCount, err := ReadUint8(buf)
 		if err != nil {
 			return err
 		}
-		users[i] = UserListEntry{
-			Nickname:     nickname,
-			IsRegistered: isRegistered,
-			UserID:       userID,
-			Online:       online,
+
+		participants := make([]DMParticipant, participantCount)
+		for j := uint8(0); j < participantCount; j++ {
+			userID, err := ReadUint64(buf)
+			if err != nil {
+				return err
+			}
+			nickname, err := ReadString(buf)
+			if err != nil {
+				return err
+			}
+			participants[j] = DMParticipant{UserID: userID, Nickname: nickname}
+		}
+
+		dms[i] = DMChannel{
+			ChannelID:    channelID,
+			IsEncrypted:  isEncrypted,
+			ChannelKey:   channelKey,
+			Participants: participants,
 		}
 	}
 
-	m.Users = users
+	m.DMs = dms
 	return nil
 }
 
-// ListChannelUsersMessage (0x17) - Request list of users in a channel
-type ListChannelUsersMessage struct {
-	ChannelID    uint64
-	SubchannelID *uint64
+// Encryption key types for PROVIDE_PUBLIC_KEY
+const (
+	KeyTypeSSHDerived = 0x00 // X25519 key derived from the user's ed25519 SSH key
+	KeyTypeGenerated  = 0x01 // X25519 key generated and stored by the client
+	KeyTypeEphemeral  = 0x02 // X25519 key that only lives for the current session
+)
+
+// ProvidePublicKeyMessage (0x1A) - Upload an X25519 public key for DM encryption
+type ProvidePublicKeyMessage struct {
+	KeyType   uint8
+	PublicKey string // Base64-encoded 32-byte X25519 public key
+	Label     string
 }
 
-func (m *ListChannelUsersMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.ChannelID); err != nil {
+func (m *ProvidePublicKeyMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint8(w, m.KeyType); err != nil {
 		return err
 	}
-	return WriteOptionalUint64(w, m.SubchannelID)
+	if err := WriteString(w, m.PublicKey); err != nil {
+		return err
+	}
+	return WriteString(w, m.Label)
 }
 
-func (m *ListChannelUsersMessage) Encode() ([]byte, error) {
+func (m *ProvidePublicKeyMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2231,68 +5244,95 @@ func (m *ListChannelUsersMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ListChannelUsersMessage) Decode(payload []byte) error {
+func (m *ProvidePublicKeyMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	channelID, err := ReadUint64(buf)
+	keyType, err := ReadUint8(buf)
 	if err != nil {
 		return err
 	}
-	subchannelID, err := ReadOptionalUint64(buf)
+	publicKey, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
+	label, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+
+	m.KeyType = keyType
+	m.PublicKey = publicKey
+	m.Label = label
 	return nil
 }
 
-// ChannelUserEntry represents a single user currently present in a channel
-type ChannelUserEntry struct {
-	SessionID    uint64
-	Nickname     string
-	IsRegistered bool
-	UserID       *uint64
-	UserFlags    UserFlags
+// AllowUnencryptedMessage (0x1B) - Accept an unencrypted DM instead of setting up a key
+type AllowUnencryptedMessage struct {
+	DMChannelID uint64
+	Permanent   bool // Also allow all future DMs to be unencrypted
 }
 
-// ChannelUserListMessage (0xAB) - Snapshot of users in a channel
-type ChannelUserListMessage struct {
-	ChannelID    uint64
-	SubchannelID *uint64
-	Users        []ChannelUserEntry
+func (m *AllowUnencryptedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.DMChannelID); err != nil {
+		return err
+	}
+	return WriteBool(w, m.Permanent)
 }
 
-func (m *ChannelUserListMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.ChannelID); err != nil {
+func (m *AllowUnencryptedMessage) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.EncodeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *AllowUnencryptedMessage) Decode(payload []byte) error {
+	buf := bytes.NewReader(payload)
+	dmChannelID, err := ReadUint64(buf)
+	if err != nil {
 		return err
 	}
-	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+	permanent, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+
+	m.DMChannelID = dmChannelID
+	m.Permanent = permanent
+	return nil
+}
+
+// WrappedChannelKey is a DM channel key wrapped for one participant public key
+type WrappedChannelKey struct {
+	KeyID      uint64
+	WrappedKey string // Base64 output of WrapChannelKey
+}
+
+// ProvideChannelKeysMessage (0x20) - Upload wrapped channel keys requested by DM_KEY_EXCHANGE
+type ProvideChannelKeysMessage struct {
+	ChannelID uint64
+	Keys      []WrappedChannelKey
+}
+
+func (m *ProvideChannelKeysMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	if err := WriteUint16(w, uint16(len(m.Users))); err != nil {
+	if err := WriteUint8(w, uint8(len(m.Keys))); err != nil {
 		return err
 	}
-	for _, user := range m.Users {
-		if err := WriteUint64(w, user.SessionID); err != nil {
-			return err
-		}
-		if err := WriteString(w, user.Nickname); err != nil {
-			return err
-		}
-		if err := WriteBool(w, user.IsRegistered); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, user.UserID); err != nil {
+	for _, key := range m.Keys {
+		if err := WriteUint64(w, key.KeyID); err != nil {
 			return err
 		}
-		if err := WriteUint8(w, uint8(user.UserFlags)); err != nil {
+		if err := WriteString(w, key.WrappedKey); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *ChannelUserListMessage) Encode() ([]byte, error) {
+func (m *ProvideChannelKeysMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2300,97 +5340,49 @@ func (m *ChannelUserListMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ChannelUserListMessage) Decode(payload []byte) error {
+func (m *ProvideChannelKeysMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	subchannelID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
-	}
-	userCount, err := ReadUint16(buf)
+	count, err := ReadUint8(buf)
 	if err != nil {
 		return err
 	}
 
-	users := make([]ChannelUserEntry, userCount)
-	for i := uint16(0); i < userCount; i++ {
-		sessionID, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		nickname, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		isRegistered, err := ReadBool(buf)
-		if err != nil {
-			return err
-		}
-		userID, err := ReadOptionalUint64(buf)
+	keys := make([]WrappedChannelKey, count)
+	for i := uint8(0); i < count; i++ {
+		keyID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		flags, err := ReadUint8(buf)
+		wrappedKey, err := ReadString(buf)
 		if err != nil {
 			return err
 		}
-
-		users[i] = ChannelUserEntry{
-			SessionID:    sessionID,
-			Nickname:     nickname,
-			IsRegistered: isRegistered,
-			UserID:       userID,
-			UserFlags:    UserFlags(flags),
-		}
+		keys[i] = WrappedChannelKey{KeyID: keyID, WrappedKey: wrappedKey}
 	}
 
 	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.Users = users
+	m.Keys = keys
 	return nil
 }
 
-// ChannelPresenceMessage (0xAC) - User joined or left a channel
-type ChannelPresenceMessage struct {
-	ChannelID    uint64
-	SubchannelID *uint64
-	SessionID    uint64
-	Nickname     string
-	IsRegistered bool
-	UserID       *uint64
-	UserFlags    UserFlags
-	Joined       bool
+// KeyRequiredMessage (0xA1) - Server needs an encryption key before proceeding with a DM
+type KeyRequiredMessage struct {
+	Reason      string
+	DMChannelID *uint64 // nil if a key is needed for DMs in general
 }
 
-func (m *ChannelPresenceMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.ChannelID); err != nil {
-		return err
-	}
-	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
-		return err
-	}
-	if err := WriteUint64(w, m.SessionID); err != nil {
-		return err
-	}
-	if err := WriteString(w, m.Nickname); err != nil {
-		return err
-	}
-	if err := WriteBool(w, m.IsRegistered); err != nil {
-		return err
-	}
-	if err := WriteOptionalUint64(w, m.UserID); err != nil {
-		return err
-	}
-	if err := WriteUint8(w, uint8(m.UserFlags)); err != nil {
+func (m *KeyRequiredMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Reason); err != nil {
 		return err
 	}
-	return WriteBool(w, m.Joined)
+	return WriteOptionalUint64(w, m.DMChannelID)
 }
 
-func (m *ChannelPresenceMessage) Encode() ([]byte, error) {
+func (m *KeyRequiredMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2398,82 +5390,44 @@ func (m *ChannelPresenceMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ChannelPresenceMessage) Decode(payload []byte) error {
+func (m *KeyRequiredMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	channelID, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
-	subchannelID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
-	}
-	sessionID, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
-	nickname, err := ReadString(buf)
-	if err != nil {
-		return err
-	}
-	isRegistered, err := ReadBool(buf)
-	if err != nil {
-		return err
-	}
-	userID, err := ReadOptionalUint64(buf)
-	if err != nil {
-		return err
-	}
-	flags, err := ReadUint8(buf)
+	reason, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	joined, err := ReadBool(buf)
+	dmChannelID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
 
-	m.ChannelID = channelID
-	m.SubchannelID = subchannelID
-	m.SessionID = sessionID
-	m.Nickname = nickname
-	m.IsRegistered = isRegistered
-	m.UserID = userID
-	m.UserFlags = UserFlags(flags)
-	m.Joined = joined
+	m.Reason = reason
+	m.DMChannelID = dmChannelID
 	return nil
 }
 
-// ServerPresenceMessage (0xAD) - User connected or disconnected from the server
-type ServerPresenceMessage struct {
-	SessionID    uint64
-	Nickname     string
-	IsRegistered bool
-	UserID       *uint64
-	UserFlags    UserFlags
-	Online       bool
+// DMPendingMessage (0xA3) - Waiting for the other party before the DM can be used
+type DMPendingMessage struct {
+	DMChannelID        uint64
+	WaitingForUserID   *uint64
+	WaitingForNickname string
+	Reason             string
 }
 
-func (m *ServerPresenceMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.SessionID); err != nil {
-		return err
-	}
-	if err := WriteString(w, m.Nickname); err != nil {
-		return err
-	}
-	if err := WriteBool(w, m.IsRegistered); err != nil {
+func (m *DMPendingMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.DMChannelID); err != nil {
 		return err
 	}
-	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+	if err := WriteOptionalUint64(w, m.WaitingForUserID); err != nil {
 		return err
 	}
-	if err := WriteUint8(w, uint8(m.UserFlags)); err != nil {
+	if err := WriteString(w, m.WaitingForNickname); err != nil {
 		return err
 	}
-	return WriteBool(w, m.Online)
+	return WriteString(w, m.Reason)
 }
 
-func (m *ServerPresenceMessage) Encode() ([]byte, error) {
+func (m *DMPendingMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2481,126 +5435,75 @@ func (m *ServerPresenceMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ServerPresenceMessage) Decode(payload []byte) error {
+func (m *DMPendingMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	sessionID, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
-	nickname, err := ReadString(buf)
-	if err != nil {
-		return err
-	}
-	isRegistered, err := ReadBool(buf)
+	dmChannelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	userID, err := ReadOptionalUint64(buf)
+	waitingForUserID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-	flags, err := ReadUint8(buf)
+	waitingForNickname, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	online, err := ReadBool(buf)
+	reason, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
 
-	m.SessionID = sessionID
-	m.Nickname = nickname
-	m.IsRegistered = isRegistered
-	m.UserID = userID
-	m.UserFlags = UserFlags(flags)
-	m.Online = online
+	m.DMChannelID = dmChannelID
+	m.WaitingForUserID = waitingForUserID
+	m.WaitingForNickname = waitingForNickname
+	m.Reason = reason
 	return nil
 }
 
-// ===== Server Discovery Messages =====
-
-// ListServersMessage (0x55) - Request server list from directory
-type ListServersMessage struct {
-	Limit uint16
+// DMPublicKey is a participant public key the channel key must be wrapped for
+type DMPublicKey struct {
+	KeyID     uint64
+	UserID    uint64
+	Nickname  string
+	PublicKey string // Base64-encoded X25519 public key
 }
 
-func (m *ListServersMessage) EncodeTo(w io.Writer) error {
-	return WriteUint16(w, m.Limit)
+// DMKeyExchangeMessage (0xAF) - Asks a participant to wrap the channel key for the listed public keys
+type DMKeyExchangeMessage struct {
+	ChannelID uint64
+	NewKey    bool // No participant holds a key yet; the client should generate one
+	Keys      []DMPublicKey
 }
 
-func (m *ListServersMessage) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.EncodeTo(buf); err != nil {
-		return nil, err
+func (m *DMKeyExchangeMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
-}
-
-func (m *ListServersMessage) Decode(payload []byte) error {
-	buf := bytes.NewReader(payload)
-	limit, err := ReadUint16(buf)
-	if err != nil {
+	if err := WriteBool(w, m.NewKey); err != nil {
 		return err
 	}
-	m.Limit = limit
-	return nil
-}
-
-// ServerInfo represents a single server in the server list
-type ServerInfo struct {
-	Hostname      string
-	Port          uint16
-	Name          string
-	Description   string
-	UserCount     uint32
-	MaxUsers      uint32
-	UptimeSeconds uint64
-	IsPublic      bool
-	ChannelCount  uint32
-}
-
-// ServerListMessage (0x9B) - List of discoverable servers
-type ServerListMessage struct {
-	Servers []ServerInfo
-}
-
-func (m *ServerListMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint16(w, uint16(len(m.Servers))); err != nil {
+	if err := WriteUint8(w, uint8(len(m.Keys))); err != nil {
 		return err
 	}
-	for _, server := range m.Servers {
-		if err := WriteString(w, server.Hostname); err != nil {
-			return err
-		}
-		if err := WriteUint16(w, server.Port); err != nil {
-			return err
-		}
-		if err := WriteString(w, server.Name); err != nil {
-			return err
-		}
-		if err := WriteString(w, server.Description); err != nil {
-			return err
-		}
-		if err := WriteUint32(w, server.UserCount); err != nil {
-			return err
-		}
-		if err := WriteUint32(w, server.MaxUsers); err != nil {
+	for _, key := range m.Keys {
+		if err := WriteUint64(w, key.KeyID); err != nil {
 			return err
 		}
-		if err := WriteUint64(w, server.UptimeSeconds); err != nil {
+		if err := WriteUint64(w, key.UserID); err != nil {
 			return err
 		}
-		if err := WriteBool(w, server.IsPublic); err != nil {
+		if err := WriteString(w, key.Nickname); err != nil {
 			return err
 		}
-		if err := WriteUint32(w, server.ChannelCount); err != nil {
+		if err := WriteString(w, key.PublicKey); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *ServerListMessage) Encode() ([]byte, error) {
+func (m *DMKeyExchangeMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2608,103 +5511,82 @@ func (m *ServerListMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ServerListMessage) Decode(payload []byte) error {
+func (m *DMKeyExchangeMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	serverCount, err := ReadUint16(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
-	}
-
-	servers := make([]ServerInfo, serverCount)
-	for i := uint16(0); i < serverCount; i++ {
-		hostname, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		port, err := ReadUint16(buf)
-		if err != nil {
-			return err
-		}
-		name, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		description, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		userCount, err := ReadUint32(buf)
-		if err != nil {
-			return err
-		}
-		maxUsers, err := ReadUint32(buf)
+	}
+	newKey, err := ReadBool(buf)
+	if err != nil {
+		return err
+	}
+	count, err := ReadUint8(buf)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]DMPublicKey, count)
+	for i := uint8(0); i < count; i++ {
+		keyID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		uptimeSeconds, err := ReadUint64(buf)
+		userID, err := ReadUint64(buf)
 		if err != nil {
 			return err
 		}
-		isPublic, err := ReadBool(buf)
+		nickname, err := ReadString(buf)
 		if err != nil {
 			return err
 		}
-		channelCount, err := ReadUint32(buf)
+		publicKey, err := ReadString(buf)
 		if err != nil {
 			return err
 		}
-
-		servers[i] = ServerInfo{
-			Hostname:      hostname,
-			Port:          port,
-			Name:          name,
-			Description:   description,
-			UserCount:     userCount,
-			MaxUsers:      maxUsers,
-			UptimeSeconds: uptimeSeconds,
-			IsPublic:      isPublic,
-			ChannelCount:  channelCount,
-		}
+		keys[i] = DMPublicKey{KeyID: keyID, UserID: userID, Nickname: nickname, PublicKey: publicKey}
 	}
 
-	m.Servers = servers
+	m.ChannelID = channelID
+	m.NewKey = newKey
+	m.Keys = keys
 	return nil
 }
 
-// RegisterServerMessage (0x56) - Register server with directory
-type RegisterServerMessage struct {
-	Hostname     string
-	Port         uint16
-	Name         string
-	Description  string
-	MaxUsers     uint32
-	IsPublic     bool
-	ChannelCount uint32
+// SearchMessagesMessage (0x22) - Full-text search over messages the user can read
+type SearchMessagesMessage struct {
+	Query     string
+	ChannelID *uint64    // Only this channel
+	Author    *string    // Only messages by this nickname ("~nick" for anonymous users)
+	After     *time.Time // Only messages created at or after this time
+	Before    *time.Time // Only messages created before this time
+	ThreadID  *uint64    // Only this thread (root message and all replies)
+	Limit     uint16     // 0 = server default
 }
 
-func (m *RegisterServerMessage) EncodeTo(w io.Writer) error {
-	if err := WriteString(w, m.Hostname); err != nil {
+func (m *SearchMessagesMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Query); err != nil {
 		return err
 	}
-	if err := WriteUint16(w, m.Port); err != nil {
+	if err := WriteOptionalUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	if err := WriteString(w, m.Name); err != nil {
+	if err := WriteOptionalString(w, m.Author); err != nil {
 		return err
 	}
-	if err := WriteString(w, m.Description); err != nil {
+	if err := WriteOptionalTimestamp(w, m.After); err != nil {
 		return err
 	}
-	if err := WriteUint32(w, m.MaxUsers); err != nil {
+	if err := WriteOptionalTimestamp(w, m.Before); err != nil {
 		return err
 	}
-	if err := WriteBool(w, m.IsPublic); err != nil {
+	if err := WriteOptionalUint64(w, m.ThreadID); err != nil {
 		return err
 	}
-	return WriteUint32(w, m.ChannelCount)
+	return WriteUint16(w, m.Limit)
 }
 
-func (m *RegisterServerMessage) Encode() ([]byte, error) {
+func (m *SearchMessagesMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2712,67 +5594,87 @@ func (m *RegisterServerMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *RegisterServerMessage) Decode(payload []byte) error {
+func (m *SearchMessagesMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	hostname, err := ReadString(buf)
+	query, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	port, err := ReadUint16(buf)
+	channelID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-	name, err := ReadString(buf)
+	author, err := ReadOptionalString(buf)
 	if err != nil {
 		return err
 	}
-	description, err := ReadString(buf)
+	after, err := ReadOptionalTimestamp(buf)
 	if err != nil {
 		return err
 	}
-	maxUsers, err := ReadUint32(buf)
+	before, err := ReadOptionalTimestamp(buf)
 	if err != nil {
 		return err
 	}
-	isPublic, err := ReadBool(buf)
+	threadID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-	channelCount, err := ReadUint32(buf)
+	limit, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
 
-	m.Hostname = hostname
-	m.Port = port
-	m.Name = name
-	m.Description = description
-	m.MaxUsers = maxUsers
-	m.IsPublic = isPublic
-	m.ChannelCount = channelCount
+	m.Query = query
+	m.ChannelID = channelID
+	m.Author = author
+	m.After = after
+	m.Before = before
+	m.ThreadID = threadID
+	m.Limit = limit
 	return nil
 }
 
-// RegisterAckMessage (0x9C) - Server registration acknowledgment
-type RegisterAckMessage struct {
-	Success           bool
-	HeartbeatInterval uint32 // Only present if success = true
-	Message           string
+// SearchResult is a single SEARCH_RESULTS match
+type SearchResult struct {
+	Message      Message
+	ThreadRootID uint64 // Root message of the thread containing the match (the match itself for root messages)
 }
 
-func (m *RegisterAckMessage) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+// SearchResultsMessage (0xB0) - Response to SEARCH_MESSAGES, newest first
+type SearchResultsMessage struct {
+	Query   string
+	Results []SearchResult
+	Threads []Message // Root messages of the threads that reply matches belong to
+}
+
+func (m *SearchResultsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Query); err != nil {
 		return err
 	}
-	if m.Success {
-		if err := WriteUint32(w, m.HeartbeatInterval); err != nil {
+	if err := WriteUint16(w, uint16(len(m.Results))); err != nil {
+		return err
+	}
+	for _, result := range m.Results {
+		if err := writeSearchMessage(w, &result.Message); err != nil {
+			return err
+		}
+		if err := WriteUint64(w, result.ThreadRootID); err != nil {
 			return err
 		}
 	}
-	return WriteString(w, m.Message)
+	if err := WriteUint16(w, uint16(len(m.Threads))); err != nil {
+		return err
+	}
+	for i := range m.Threads {
+		if err := writeSearchMessage(w, &m.Threads[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (m *RegisterAckMessage) Encode() ([]byte, error) {
+func (m *SearchResultsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2780,40 +5682,158 @@ func (m *RegisterAckMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *RegisterAckMessage) Decode(payload []byte) error {
+func (m *SearchResultsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+	query, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	m.Success = success
 
-	if success {
-		heartbeatInterval, err := ReadUint32(buf)
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	results := make([]SearchResult, count)
+	for i := uint16(0); i < count; i++ {
+		msg, err := readSearchMessage(buf)
 		if err != nil {
 			return err
 		}
-		m.HeartbeatInterval = heartbeatInterval
+		threadRootID, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		results[i] = SearchResult{Message: msg, ThreadRootID: threadRootID}
 	}
 
-	message, err := ReadString(buf)
+	threadCount, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	m.Message = message
+	threads := make([]Message, threadCount)
+	for i := uint16(0); i < threadCount; i++ {
+		if threads[i], err = readSearchMessage(buf); err != nil {
+			return err
+		}
+	}
+
+	m.Query = query
+	m.Results = results
+	m.Threads = threads
 	return nil
 }
 
-// VerifyRegistrationMessage (0x9E) - Verification challenge
-type VerifyRegistrationMessage struct {
-	Challenge uint64
+// writeSearchMessage writes a message in the same layout as a MESSAGE_LIST entry
+func writeSearchMessage(w io.Writer, msg *Message) error {
+	if err := WriteUint64(w, msg.ID); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, msg.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, msg.SubchannelID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, msg.ParentID); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, msg.AuthorUserID); err != nil {
+		return err
+	}
+	if err := WriteString(w, msg.AuthorNickname); err != nil {
+		return err
+	}
+	if err := WriteString(w, msg.Content); err != nil {
+		return err
+	}
+	if err := WriteTimestamp(w, msg.CreatedAt); err != nil {
+		return err
+	}
+	if err := WriteOptionalTimestamp(w, msg.EditedAt); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, msg.ReplyCount); err != nil {
+		return err
+	}
+	return writeReactionSummaries(w, msg.Reactions)
 }
 
-func (m *VerifyRegistrationMessage) EncodeTo(w io.Writer) error {
-	return WriteUint64(w, m.Challenge)
+// readSearchMessage reads a message written by writeSearchMessage
+func readSearchMessage(r io.Reader) (Message, error) {
+	id, err := ReadUint64(r)
+	if err != nil {
+		return Message{}, err
+	}
+	channelID, err := ReadUint64(r)
+	if err != nil {
+		return Message{}, err
+	}
+	subchannelID, err := ReadOptionalUint64(r)
+	if err != nil {
+		return Message{}, err
+	}
+	parentID, err := ReadOptionalUint64(r)
+	if err != nil {
+		return Message{}, err
+	}
+	authorID, err := ReadOptionalUint64(r)
+	if err != nil {
+		return Message{}, err
+	}
+	authorNick, err := ReadString(r)
+	if err != nil {
+		return Message{}, err
+	}
+	content, err := ReadString(r)
+	if err != nil {
+		return Message{}, err
+	}
+	createdAt, err := ReadTimestamp(r)
+	if err != nil {
+		return Message{}, err
+	}
+	editedAt, err := ReadOptionalTimestamp(r)
+	if err != nil {
+		return Message{}, err
+	}
+	replyCount, err := ReadUint32(r)
+	if err != nil {
+		return Message{}, err
+	}
+	reactions, err := readReactionSummaries(r)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		ID:             id,
+		ChannelID:      channelID,
+		SubchannelID:   subchannelID,
+		ParentID:       parentID,
+		AuthorUserID:   authorID,
+		AuthorNickname: authorNick,
+		Content:        content,
+		CreatedAt:      createdAt,
+		EditedAt:       editedAt,
+		ReplyCount:     replyCount,
+		Reactions:      reactions,
+	}, nil
 }
 
-func (m *VerifyRegistrationMessage) Encode() ([]byte, error) {
+// AddReactionMessage (0x23) - React to a message with an emoji
+type AddReactionMessage struct {
+	MessageID uint64
+	Emoji     string
+}
+
+func (m *AddReactionMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.MessageID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Emoji)
+}
+
+func (m *AddReactionMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2821,26 +5841,35 @@ func (m *VerifyRegistrationMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *VerifyRegistrationMessage) Decode(payload []byte) error {
+func (m *AddReactionMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	challenge, err := ReadUint64(buf)
+	messageID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.Challenge = challenge
+	emoji, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.MessageID = messageID
+	m.Emoji = emoji
 	return nil
 }
 
-// VerifyResponseMessage (0x58) - Response to verification challenge
-type VerifyResponseMessage struct {
-	Challenge uint64
+// RemoveReactionMessage (0x24) - Take back a reaction to a message
+type RemoveReactionMessage struct {
+	MessageID uint64
+	Emoji     string
 }
 
-func (m *VerifyResponseMessage) EncodeTo(w io.Writer) error {
-	return WriteUint64(w, m.Challenge)
+func (m *RemoveReactionMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.MessageID); err != nil {
+		return err
+	}
+	return WriteString(w, m.Emoji)
 }
 
-func (m *VerifyResponseMessage) Encode() ([]byte, error) {
+func (m *RemoveReactionMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2848,42 +5877,40 @@ func (m *VerifyResponseMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *VerifyResponseMessage) Decode(payload []byte) error {
+func (m *RemoveReactionMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	challenge, err := ReadUint64(buf)
+	messageID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.Challenge = challenge
+	emoji, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.MessageID = messageID
+	m.Emoji = emoji
 	return nil
 }
 
-// HeartbeatMessage (0x57) - Periodic heartbeat to directory
-type HeartbeatMessage struct {
-	Hostname      string
-	Port          uint16
-	UserCount     uint32
-	UptimeSeconds uint64
-	ChannelCount  uint32
+// ReactionsUpdatedMessage (0xB1) - The reactions to a message changed
+// Carries the complete new set of reactions, so clients can replace what they have.
+type ReactionsUpdatedMessage struct {
+	MessageID uint64
+	ChannelID uint64
+	Reactions []ReactionSummary
 }
 
-func (m *HeartbeatMessage) EncodeTo(w io.Writer) error {
-	if err := WriteString(w, m.Hostname); err != nil {
-		return err
-	}
-	if err := WriteUint16(w, m.Port); err != nil {
-		return err
-	}
-	if err := WriteUint32(w, m.UserCount); err != nil {
+func (m *ReactionsUpdatedMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.MessageID); err != nil {
 		return err
 	}
-	if err := WriteUint64(w, m.UptimeSeconds); err != nil {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	return WriteUint32(w, m.ChannelCount)
+	return writeReactionSummaries(w, m.Reactions)
 }
 
-func (m *HeartbeatMessage) Encode() ([]byte, error) {
+func (m *ReactionsUpdatedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2891,80 +5918,133 @@ func (m *HeartbeatMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *HeartbeatMessage) Decode(payload []byte) error {
+func (m *ReactionsUpdatedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	hostname, err := ReadString(buf)
+	messageID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	port, err := ReadUint16(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	userCount, err := ReadUint32(buf)
+	reactions, err := readReactionSummaries(buf)
 	if err != nil {
 		return err
 	}
-	uptimeSeconds, err := ReadUint64(buf)
-	if err != nil {
+	m.MessageID = messageID
+	m.ChannelID = channelID
+	m.Reactions = reactions
+	return nil
+}
+
+// writeReactionSummaries writes a count (u16) followed by each emoji and its user IDs
+func writeReactionSummaries(w io.Writer, reactions []ReactionSummary) error {
+	if err := WriteUint16(w, uint16(len(reactions))); err != nil {
 		return err
 	}
-	channelCount, err := ReadUint32(buf)
-	if err != nil {
-		return err
+	for _, reaction := range reactions {
+		if err := WriteString(w, reaction.Emoji); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, uint32(len(reaction.UserIDs))); err != nil {
+			return err
+		}
+		for _, userID := range reaction.UserIDs {
+			if err := WriteUint64(w, userID); err != nil {
+				return err
+			}
+		}
 	}
-
-	m.Hostname = hostname
-	m.Port = port
-	m.UserCount = userCount
-	m.UptimeSeconds = uptimeSeconds
-	m.ChannelCount = channelCount
 	return nil
 }
 
-// HeartbeatAckMessage (0x9D) - Heartbeat acknowledgment with interval
-type HeartbeatAckMessage struct {
-	HeartbeatInterval uint32
+// readReactionSummaries reads reactions written by writeReactionSummaries
+func readReactionSummaries(r io.Reader) ([]ReactionSummary, error) {
+	count, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	reactions := make([]ReactionSummary, count)
+	for i := range reactions {
+		emoji, err := ReadString(r)
+		if err != nil {
+			return nil, err
+		}
+		userCount, err := ReadUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		// Don't trust the count for the allocation size; grow as IDs are actually read
+		var userIDs []uint64
+		for j := uint32(0); j < userCount; j++ {
+			userID, err := ReadUint64(r)
+			if err != nil {
+				return nil, err
+			}
+			userIDs = append(userIDs, userID)
+		}
+		reactions[i] = ReactionSummary{Emoji: emoji, UserIDs: userIDs}
+	}
+	return reactions, nil
 }
-
-func (m *HeartbeatAckMessage) EncodeTo(w io.Writer) error {
-	return WriteUint32(w, m.HeartbeatInterval)
+
+// Mention is a message that mentions the user
+type Mention struct {
+	Message      Message
+	ThreadRootID uint64 // Root message of the thread containing the mention (the message itself for root messages)
+	Read         bool
 }
 
-func (m *HeartbeatAckMessage) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.EncodeTo(buf); err != nil {
-		return nil, err
+// writeMention writes a mention as a MESSAGE_LIST entry followed by its thread root and read flag
+func writeMention(w io.Writer, mention *Mention) error {
+	if err := writeSearchMessage(w, &mention.Message); err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
+	if err := WriteUint64(w, mention.ThreadRootID); err != nil {
+		return err
+	}
+	return WriteBool(w, mention.Read)
 }
 
-func (m *HeartbeatAckMessage) Decode(payload []byte) error {
-	buf := bytes.NewReader(payload)
-	heartbeatInterval, err := ReadUint32(buf)
+// readMention reads a mention written by writeMention
+func readMention(r io.Reader) (Mention, error) {
+	msg, err := readSearchMessage(r)
 	if err != nil {
-		return err
+		return Mention{}, err
 	}
-	m.HeartbeatInterval = heartbeatInterval
-	return nil
+	threadRootID, err := ReadUint64(r)
+	if err != nil {
+		return Mention{}, err
+	}
+	read, err := ReadBool(r)
+	if err != nil {
+		return Mention{}, err
+	}
+	return Mention{Message: msg, ThreadRootID: threadRootID, Read: read}, nil
 }
 
-// ===== CHANGE_PASSWORD (0x0E) - Client → Server =====
-
-// ChangePasswordRequest is sent by clients to change their password
-type ChangePasswordRequest struct {
-	OldPassword string // Empty for SSH-registered users changing password for first time
-	NewPassword string
+// ListMentionsMessage (0x25) - Request the user's mentions inbox, newest first
+type ListMentionsMessage struct {
+	UnreadOnly bool
+	BeforeID   *uint64 // Only mentions in messages older than this one (for paging)
+	Limit      uint16  // 0 = server default
 }
 
-func (m *ChangePasswordRequest) EncodeTo(w io.Writer) error {
-	if err := WriteString(w, m.OldPassword); err != nil {
+func (m *ListMentionsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.UnreadOnly); err != nil {
 		return err
 	}
-	return WriteString(w, m.NewPassword)
+	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+		return err
+	}
+	return WriteUint16(w, m.Limit)
 }
 
-func (m *ChangePasswordRequest) Encode() ([]byte, error) {
+func (m *ListMentionsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -2972,37 +6052,44 @@ func (m *ChangePasswordRequest) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ChangePasswordRequest) Decode(payload []byte) error {
+func (m *ListMentionsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	oldPassword, err := ReadString(buf)
+	unreadOnly, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	newPassword, err := ReadString(buf)
+	beforeID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.OldPassword = oldPassword
-	m.NewPassword = newPassword
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	m.UnreadOnly = unreadOnly
+	m.BeforeID = beforeID
+	m.Limit = limit
 	return nil
 }
 
-// ===== PASSWORD_CHANGED (0x8E) - Server → Client =====
-
-// PasswordChangedResponse is sent by server after password change attempt
-type PasswordChangedResponse struct {
-	Success      bool
-	ErrorMessage string // Empty if success=true
+// MarkMentionsReadMessage (0x26) - Mark mentions as read
+type MarkMentionsReadMessage struct {
+	MessageIDs []uint64 // Empty = all of the user's mentions
 }
 
-func (m *PasswordChangedResponse) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+func (m *MarkMentionsReadMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.MessageIDs))); err != nil {
 		return err
 	}
-	return WriteString(w, m.ErrorMessage)
+	for _, id := range m.MessageIDs {
+		if err := WriteUint64(w, id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (m *PasswordChangedResponse) Encode() ([]byte, error) {
+func (m *MarkMentionsReadMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3010,37 +6097,38 @@ func (m *PasswordChangedResponse) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *PasswordChangedResponse) Decode(payload []byte) error {
+func (m *MarkMentionsReadMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	errorMessage, err := ReadString(buf)
-	if err != nil {
-		return err
+	var ids []uint64
+	for i := uint16(0); i < count; i++ {
+		id, err := ReadUint64(buf)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
 	}
-	m.Success = success
-	m.ErrorMessage = errorMessage
+	m.MessageIDs = ids
 	return nil
 }
 
-// ===== ADD_SSH_KEY (0x0D) - Client → Server =====
-
-// AddSSHKeyRequest is sent by clients to add a new SSH public key to their account
-type AddSSHKeyRequest struct {
-	PublicKey string // Full SSH public key (e.g., "ssh-rsa AAAA... user@host")
-	Label     string // Optional user-friendly label (e.g., "Work Laptop")
+// MentionNotificationMessage (0xB2) - Pushed to every session of a user mentioned in a new message
+type MentionNotificationMessage struct {
+	Mention     Mention
+	UnreadCount uint32 // The user's unread mentions, including this one
 }
 
-func (m *AddSSHKeyRequest) EncodeTo(w io.Writer) error {
-	if err := WriteString(w, m.PublicKey); err != nil {
+func (m *MentionNotificationMessage) EncodeTo(w io.Writer) error {
+	if err := writeMention(w, &m.Mention); err != nil {
 		return err
 	}
-	return WriteString(w, m.Label)
+	return WriteUint32(w, m.UnreadCount)
 }
 
-func (m *AddSSHKeyRequest) Encode() ([]byte, error) {
+func (m *MentionNotificationMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3048,45 +6136,49 @@ func (m *AddSSHKeyRequest) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *AddSSHKeyRequest) Decode(payload []byte) error {
+func (m *MentionNotificationMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	publicKey, err := ReadString(buf)
+	mention, err := readMention(buf)
 	if err != nil {
 		return err
 	}
-	label, err := ReadString(buf)
+	unreadCount, err := ReadUint32(buf)
 	if err != nil {
 		return err
 	}
-	m.PublicKey = publicKey
-	m.Label = label
+	m.Mention = mention
+	m.UnreadCount = unreadCount
 	return nil
 }
 
-// ===== SSH_KEY_ADDED (0x95) - Server → Client =====
-
-// SSHKeyAddedResponse is sent by server after adding an SSH key
-type SSHKeyAddedResponse struct {
-	Success      bool
-	KeyID        int64  // Database ID of the added key
-	Fingerprint  string // SHA256 fingerprint
-	ErrorMessage string // Empty if success=true
+// MentionListMessage (0xB3) - Response to LIST_MENTIONS, newest first
+type MentionListMessage struct {
+	Mentions    []Mention
+	Threads     []Message // Root messages of the threads that reply mentions belong to
+	UnreadCount uint32    // The user's unread mentions in total, not just in this page
 }
 
-func (m *SSHKeyAddedResponse) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+func (m *MentionListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Mentions))); err != nil {
 		return err
 	}
-	if err := WriteInt64(w, m.KeyID); err != nil {
-		return err
+	for i := range m.Mentions {
+		if err := writeMention(w, &m.Mentions[i]); err != nil {
+			return err
+		}
 	}
-	if err := WriteString(w, m.Fingerprint); err != nil {
+	if err := WriteUint16(w, uint16(len(m.Threads))); err != nil {
 		return err
 	}
-	return WriteString(w, m.ErrorMessage)
+	for i := range m.Threads {
+		if err := writeSearchMessage(w, &m.Threads[i]); err != nil {
+			return err
+		}
+	}
+	return WriteUint32(w, m.UnreadCount)
 }
 
-func (m *SSHKeyAddedResponse) Encode() ([]byte, error) {
+func (m *MentionListMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3094,104 +6186,68 @@ func (m *SSHKeyAddedResponse) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SSHKeyAddedResponse) Decode(payload []byte) error {
+func (m *MentionListMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	keyID, err := ReadInt64(buf)
-	if err != nil {
-		return err
+	mentions := make([]Mention, count)
+	for i := range mentions {
+		if mentions[i], err = readMention(buf); err != nil {
+			return err
+		}
 	}
-	fingerprint, err := ReadString(buf)
+
+	threadCount, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	errorMessage, err := ReadString(buf)
-	if err != nil {
-		return err
+	threads := make([]Message, threadCount)
+	for i := range threads {
+		if threads[i], err = readSearchMessage(buf); err != nil {
+			return err
+		}
 	}
-	m.Success = success
-	m.KeyID = keyID
-	m.Fingerprint = fingerprint
-	m.ErrorMessage = errorMessage
-	return nil
-}
 
-// ===== LIST_SSH_KEYS (0x14) - Client → Server =====
-
-// ListSSHKeysRequest is sent by clients to retrieve their SSH keys
-type ListSSHKeysRequest struct {
-	// No fields - user is identified from session
-}
-
-func (m *ListSSHKeysRequest) EncodeTo(w io.Writer) error {
-	// No data to encode
-	return nil
-}
-
-func (m *ListSSHKeysRequest) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.EncodeTo(buf); err != nil {
-		return nil, err
+	unreadCount, err := ReadUint32(buf)
+	if err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
-}
 
-func (m *ListSSHKeysRequest) Decode(payload []byte) error {
-	// No data to decode
+	m.Mentions = mentions
+	m.Threads = threads
+	m.UnreadCount = unreadCount
 	return nil
 }
 
-// ===== SSH_KEY_LIST (0x94) - Server → Client =====
-
-// SSHKeyInfo represents a single SSH key in the list
-type SSHKeyInfo struct {
-	ID          int64
-	Fingerprint string
-	KeyType     string // ssh-rsa, ssh-ed25519, etc.
-	Label       string // May be empty
-	AddedAt     int64  // Unix milliseconds
-	LastUsedAt  int64  // Unix milliseconds (0 if never used)
-}
-
-// SSHKeyListResponse is sent by server with list of user's SSH keys
-type SSHKeyListResponse struct {
-	Keys []SSHKeyInfo
+// MessagesExpiredMessage (0xB4) - Messages removed by the channel's retention policy.
+// Large purges are split over several frames.
+type MessagesExpiredMessage struct {
+	ChannelID    uint64
+	SubchannelID *uint64
+	MessageIDs   []uint64
 }
 
-func (m *SSHKeyListResponse) EncodeTo(w io.Writer) error {
-	// Write number of keys
-	if err := WriteUint32(w, uint32(len(m.Keys))); err != nil {
+func (m *MessagesExpiredMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-
-	// Write each key
-	for _, key := range m.Keys {
-		if err := WriteInt64(w, key.ID); err != nil {
-			return err
-		}
-		if err := WriteString(w, key.Fingerprint); err != nil {
-			return err
-		}
-		if err := WriteString(w, key.KeyType); err != nil {
-			return err
-		}
-		if err := WriteString(w, key.Label); err != nil {
-			return err
-		}
-		if err := WriteInt64(w, key.AddedAt); err != nil {
-			return err
-		}
-		if err := WriteInt64(w, key.LastUsedAt); err != nil {
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.MessageIDs))); err != nil {
+		return err
+	}
+	for _, id := range m.MessageIDs {
+		if err := WriteUint64(w, id); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *SSHKeyListResponse) Encode() ([]byte, error) {
+func (m *MessagesExpiredMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3199,72 +6255,43 @@ func (m *SSHKeyListResponse) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SSHKeyListResponse) Decode(payload []byte) error {
+func (m *MessagesExpiredMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-
-	// Read number of keys
-	count, err := ReadUint32(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-
-	m.Keys = make([]SSHKeyInfo, count)
-
-	// Read each key
-	for i := uint32(0); i < count; i++ {
-		id, err := ReadInt64(buf)
-		if err != nil {
-			return err
-		}
-		fingerprint, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		keyType, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		label, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		addedAt, err := ReadInt64(buf)
-		if err != nil {
-			return err
-		}
-		lastUsedAt, err := ReadInt64(buf)
-		if err != nil {
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint64, count)
+	for i := range ids {
+		if ids[i], err = ReadUint64(buf); err != nil {
 			return err
 		}
-
-		m.Keys[i] = SSHKeyInfo{
-			ID:          id,
-			Fingerprint: fingerprint,
-			KeyType:     keyType,
-			Label:       label,
-			AddedAt:     addedAt,
-			LastUsedAt:  lastUsedAt,
-		}
 	}
-	return nil
-}
-
-// ===== UPDATE_SSH_KEY_LABEL (0x12) - Client → Server =====
 
-// UpdateSSHKeyLabelRequest is sent by clients to update an SSH key's label
-type UpdateSSHKeyLabelRequest struct {
-	KeyID    int64
-	NewLabel string
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.MessageIDs = ids
+	return nil
 }
 
-func (m *UpdateSSHKeyLabelRequest) EncodeTo(w io.Writer) error {
-	if err := WriteInt64(w, m.KeyID); err != nil {
-		return err
-	}
-	return WriteString(w, m.NewLabel)
+// GetMessageHistoryMessage (0x27) requests the edit history of a message
+type GetMessageHistoryMessage struct {
+	MessageID uint64
 }
 
-func (m *UpdateSSHKeyLabelRequest) Encode() ([]byte, error) {
+func (m *GetMessageHistoryMessage) EncodeTo(w io.Writer) error {
+	return WriteUint64(w, m.MessageID)
+}
+
+func (m *GetMessageHistoryMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3272,37 +6299,60 @@ func (m *UpdateSSHKeyLabelRequest) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UpdateSSHKeyLabelRequest) Decode(payload []byte) error {
+func (m *GetMessageHistoryMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	keyID, err := ReadInt64(buf)
-	if err != nil {
-		return err
-	}
-	newLabel, err := ReadString(buf)
+	messageID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.KeyID = keyID
-	m.NewLabel = newLabel
+	m.MessageID = messageID
 	return nil
 }
 
-// ===== SSH_KEY_LABEL_UPDATED (0x92) - Server → Client =====
+// MessageRevision is one version of a message's content and who produced it
+type MessageRevision struct {
+	Content        string
+	EditorNickname string    // Author for the original revision, otherwise who edited it
+	EditedAt       time.Time // Posting time for the original revision
+	AdminEdit      bool      // An admin edited someone else's message
+}
 
-// SSHKeyLabelUpdatedResponse is sent by server after updating an SSH key label
-type SSHKeyLabelUpdatedResponse struct {
-	Success      bool
-	ErrorMessage string // Empty if success=true
+// MessageHistoryMessage (0xB5) lists a message's revisions, oldest (the original post)
+// first and the current content last
+type MessageHistoryMessage struct {
+	MessageID uint64
+	ChannelID uint64
+	Revisions []MessageRevision
 }
 
-func (m *SSHKeyLabelUpdatedResponse) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+func (m *MessageHistoryMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.MessageID); err != nil {
 		return err
 	}
-	return WriteString(w, m.ErrorMessage)
+	if err := WriteUint64(w, m.ChannelID); err != nil {
+		return err
+	}
+	if err := WriteUint16(w, uint16(len(m.Revisions))); err != nil {
+		return err
+	}
+	for _, rev := range m.Revisions {
+		if err := WriteString(w, rev.Content); err != nil {
+			return err
+		}
+		if err := WriteString(w, rev.EditorNickname); err != nil {
+			return err
+		}
+		if err := WriteTimestamp(w, rev.EditedAt); err != nil {
+			return err
+		}
+		if err := WriteBool(w, rev.AdminEdit); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (m *SSHKeyLabelUpdatedResponse) Encode() ([]byte, error) {
+func (m *MessageHistoryMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3310,33 +6360,73 @@ func (m *SSHKeyLabelUpdatedResponse) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SSHKeyLabelUpdatedResponse) Decode(payload []byte) error {
+func (m *MessageHistoryMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+	messageID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	errorMessage, err := ReadString(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.Success = success
-	m.ErrorMessage = errorMessage
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	revisions := make([]MessageRevision, count)
+	for i := range revisions {
+		if revisions[i].Content, err = ReadString(buf); err != nil {
+			return err
+		}
+		if revisions[i].EditorNickname, err = ReadString(buf); err != nil {
+			return err
+		}
+		if revisions[i].EditedAt, err = ReadTimestamp(buf); err != nil {
+			return err
+		}
+		if revisions[i].AdminEdit, err = ReadBool(buf); err != nil {
+			return err
+		}
+	}
+
+	m.MessageID = messageID
+	m.ChannelID = channelID
+	m.Revisions = revisions
 	return nil
 }
 
-// ===== DELETE_SSH_KEY (0x13) - Client → Server =====
-
-// DeleteSSHKeyRequest is sent by clients to delete an SSH key
-type DeleteSSHKeyRequest struct {
-	KeyID int64
+// ListAdminActionsMessage (0x60) - Request the admin audit log, newest first
+// (admins and moderators only)
+type ListAdminActionsMessage struct {
+	AdminNickname *string    // Only actions by this admin
+	ActionType    *string    // Only this kind of action ("ban_user", "delete_channel", ...)
+	Since         *time.Time // Only actions at or after this time
+	Until         *time.Time // Only actions before this time
+	BeforeID      *uint64    // Only actions older than this entry (for paging)
+	Limit         uint16     // 0 = server default
 }
 
-func (m *DeleteSSHKeyRequest) EncodeTo(w io.Writer) error {
-	return WriteInt64(w, m.KeyID)
+func (m *ListAdminActionsMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalString(w, m.AdminNickname); err != nil {
+		return err
+	}
+	if err := WriteOptionalString(w, m.ActionType); err != nil {
+		return err
+	}
+	if err := WriteOptionalTimestamp(w, m.Since); err != nil {
+		return err
+	}
+	if err := WriteOptionalTimestamp(w, m.Until); err != nil {
+		return err
+	}
+	if err := WriteOptionalUint64(w, m.BeforeID); err != nil {
+		return err
+	}
+	return WriteUint16(w, m.Limit)
 }
 
-func (m *DeleteSSHKeyRequest) Encode() ([]byte, error) {
+func (m *ListAdminActionsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3344,32 +6434,98 @@ func (m *DeleteSSHKeyRequest) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *DeleteSSHKeyRequest) Decode(payload []byte) error {
+func (m *ListAdminActionsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	keyID, err := ReadInt64(buf)
+	adminNickname, err := ReadOptionalString(buf)
 	if err != nil {
 		return err
 	}
-	m.KeyID = keyID
+	actionType, err := ReadOptionalString(buf)
+	if err != nil {
+		return err
+	}
+	since, err := ReadOptionalTimestamp(buf)
+	if err != nil {
+		return err
+	}
+	until, err := ReadOptionalTimestamp(buf)
+	if err != nil {
+		return err
+	}
+	beforeID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	limit, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+
+	m.AdminNickname = adminNickname
+	m.ActionType = actionType
+	m.Since = since
+	m.Until = until
+	m.BeforeID = beforeID
+	m.Limit = limit
 	return nil
 }
 
-// ===== SSH_KEY_DELETED (0x93) - Server → Client =====
+// AdminActionEntry is one entry in the admin audit log
+type AdminActionEntry struct {
+	ID               uint64
+	AdminNickname    string
+	ActionType       string  // "ban_user", "unban_ip", "delete_channel", ...
+	TargetType       string  // "user", "ip", "message" or "channel"
+	TargetID         *uint64 // NULL for IP bans
+	TargetIdentifier string  // Nickname, IP, channel name, ...
+	Details          string  // JSON object with context such as the reason
+	PerformedAt      time.Time
+	IPAddress        *string // Where the admin connected from (only sent to admins)
+}
 
-// SSHKeyDeletedResponse is sent by server after deleting an SSH key
-type SSHKeyDeletedResponse struct {
-	Success      bool
-	ErrorMessage string // Empty if success=true
+// AdminActionListMessage (0xB6) - Response to LIST_ADMIN_ACTIONS, newest first
+type AdminActionListMessage struct {
+	Entries []AdminActionEntry
+	HasMore bool // Older entries match the filters
 }
 
-func (m *SSHKeyDeletedResponse) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+func (m *AdminActionListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Entries))); err != nil {
 		return err
 	}
-	return WriteString(w, m.ErrorMessage)
+	for _, entry := range m.Entries {
+		if err := WriteUint64(w, entry.ID); err != nil {
+			return err
+		}
+		if err := WriteString(w, entry.AdminNickname); err != nil {
+			return err
+		}
+		if err := WriteString(w, entry.ActionType); err != nil {
+			return err
+		}
+		if err := WriteString(w, entry.TargetType); err != nil {
+			return err
+		}
+		if err := WriteOptionalUint64(w, entry.TargetID); err != nil {
+			return err
+		}
+		if err := WriteString(w, entry.TargetIdentifier); err != nil {
+			return err
+		}
+		if err := WriteString(w, entry.Details); err != nil {
+			return err
+		}
+		if err := WriteTimestamp(w, entry.PerformedAt); err != nil {
+			return err
+		}
+		if err := WriteOptionalString(w, entry.IPAddress); err != nil {
+			return err
+		}
+	}
+	return WriteBool(w, m.HasMore)
 }
 
-func (m *SSHKeyDeletedResponse) Encode() ([]byte, error) {
+func (m *AdminActionListMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3377,49 +6533,75 @@ func (m *SSHKeyDeletedResponse) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *SSHKeyDeletedResponse) Decode(payload []byte) error {
+func (m *AdminActionListMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	errorMessage, err := ReadString(buf)
+	entries := make([]AdminActionEntry, count)
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ID, err = ReadUint64(buf); err != nil {
+			return err
+		}
+		if entry.AdminNickname, err = ReadString(buf); err != nil {
+			return err
+		}
+		if entry.ActionType, err = ReadString(buf); err != nil {
+			return err
+		}
+		if entry.TargetType, err = ReadString(buf); err != nil {
+			return err
+		}
+		if entry.TargetID, err = ReadOptionalUint64(buf); err != nil {
+			return err
+		}
+		if entry.TargetIdentifier, err = ReadString(buf); err != nil {
+			return err
+		}
+		if entry.Details, err = ReadString(buf); err != nil {
+			return err
+		}
+		if entry.PerformedAt, err = ReadTimestamp(buf); err != nil {
+			return err
+		}
+		if entry.IPAddress, err = ReadOptionalString(buf); err != nil {
+			return err
+		}
+	}
+	hasMore, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	m.Success = success
-	m.ErrorMessage = errorMessage
+
+	m.Entries = entries
+	m.HasMore = hasMore
 	return nil
 }
 
-// ===== ADMIN COMMANDS =====
-
-// BanUserMessage (0x59) - Ban a user by user ID or nickname
-type BanUserMessage struct {
-	UserID          *uint64 // Optional: user ID to ban (takes precedence if provided)
-	Nickname        *string // Optional: nickname to ban (if user_id not provided)
-	Reason          string
-	Shadowban       bool
-	DurationSeconds *uint64 // NULL = permanent ban
+// SetUserFlagsMessage (0x61) - Grant or revoke the admin and moderator roles (admin only)
+type SetUserFlagsMessage struct {
+	UserID            *uint64
+	Nickname          *string // Used if UserID is NULL
+	Flags             UserFlags
+	ModeratorChannels []uint64 // Channels a moderator is limited to (empty = all channels)
 }
 
-func (m *BanUserMessage) EncodeTo(w io.Writer) error {
+func (m *SetUserFlagsMessage) EncodeTo(w io.Writer) error {
 	if err := WriteOptionalUint64(w, m.UserID); err != nil {
 		return err
 	}
 	if err := WriteOptionalString(w, m.Nickname); err != nil {
 		return err
 	}
-	if err := WriteString(w, m.Reason); err != nil {
-		return err
-	}
-	if err := WriteBool(w, m.Shadowban); err != nil {
+	if err := WriteUint8(w, uint8(m.Flags)); err != nil {
 		return err
 	}
-	return WriteOptionalUint64(w, m.DurationSeconds)
+	return writeUint64List(w, m.ModeratorChannels)
 }
 
-func (m *BanUserMessage) Encode() ([]byte, error) {
+func (m *SetUserFlagsMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3427,7 +6609,7 @@ func (m *BanUserMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *BanUserMessage) Decode(payload []byte) error {
+func (m *SetUserFlagsMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	userID, err := ReadOptionalUint64(buf)
 	if err != nil {
@@ -3437,47 +6619,53 @@ func (m *BanUserMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
-	reason, err := ReadString(buf)
-	if err != nil {
-		return err
-	}
-	shadowban, err := ReadBool(buf)
+	flags, err := ReadUint8(buf)
 	if err != nil {
 		return err
 	}
-	durationSeconds, err := ReadOptionalUint64(buf)
+	channels, err := readUint64List(buf)
 	if err != nil {
 		return err
 	}
 
 	m.UserID = userID
 	m.Nickname = nickname
-	m.Reason = reason
-	m.Shadowban = shadowban
-	m.DurationSeconds = durationSeconds
+	m.Flags = UserFlags(flags)
+	m.ModeratorChannels = channels
 	return nil
 }
 
-// UserBannedMessage (0x9F) - Response to BAN_USER
-type UserBannedMessage struct {
-	Success bool
-	BanID   uint64 // Only present if Success=true
-	Message string
+// UserFlagsUpdatedMessage (0xB7) - Response to SET_USER_FLAGS. On success it is also
+// sent to the sessions of the user whose roles changed.
+type UserFlagsUpdatedMessage struct {
+	Success           bool
+	UserID            uint64
+	Nickname          string
+	Flags             UserFlags
+	ModeratorChannels []uint64
+	Message           string
 }
 
-func (m *UserBannedMessage) EncodeTo(w io.Writer) error {
+func (m *UserFlagsUpdatedMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
-	if m.Success {
-		if err := WriteUint64(w, m.BanID); err != nil {
-			return err
-		}
+	if err := WriteUint64(w, m.UserID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Nickname); err != nil {
+		return err
+	}
+	if err := WriteUint8(w, uint8(m.Flags)); err != nil {
+		return err
+	}
+	if err := writeUint64List(w, m.ModeratorChannels); err != nil {
+		return err
 	}
 	return WriteString(w, m.Message)
 }
 
-func (m *UserBannedMessage) Encode() ([]byte, error) {
+func (m *UserFlagsUpdatedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3485,96 +6673,99 @@ func (m *UserBannedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UserBannedMessage) Decode(payload []byte) error {
+func (m *UserFlagsUpdatedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	m.Success = success
-
-	if success {
-		banID, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		m.BanID = banID
-	}
-
-	message, err := ReadString(buf)
+	userID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	m.Message = message
-	return nil
-}
-
-// BanIPMessage (0x5A) - Ban an IP address or CIDR range
-type BanIPMessage struct {
-	IPCIDR          string // IP address or CIDR range (e.g., "10.0.0.5/32", "192.168.1.0/24")
-	Reason          string
-	DurationSeconds *uint64 // NULL = permanent ban
-}
-
-func (m *BanIPMessage) EncodeTo(w io.Writer) error {
-	if err := WriteString(w, m.IPCIDR); err != nil {
-		return err
-	}
-	if err := WriteString(w, m.Reason); err != nil {
-		return err
-	}
-	return WriteOptionalUint64(w, m.DurationSeconds)
-}
-
-func (m *BanIPMessage) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := m.EncodeTo(buf); err != nil {
-		return nil, err
+	nickname, err := ReadString(buf)
+	if err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
-}
-
-func (m *BanIPMessage) Decode(payload []byte) error {
-	buf := bytes.NewReader(payload)
-	ipCIDR, err := ReadString(buf)
+	flags, err := ReadUint8(buf)
 	if err != nil {
 		return err
 	}
-	reason, err := ReadString(buf)
+	channels, err := readUint64List(buf)
 	if err != nil {
 		return err
 	}
-	durationSeconds, err := ReadOptionalUint64(buf)
+	message, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
 
-	m.IPCIDR = ipCIDR
-	m.Reason = reason
-	m.DurationSeconds = durationSeconds
+	m.Success = success
+	m.UserID = userID
+	m.Nickname = nickname
+	m.Flags = UserFlags(flags)
+	m.ModeratorChannels = channels
+	m.Message = message
 	return nil
 }
 
-// IPBannedMessage (0xA5) - Response to BAN_IP
-type IPBannedMessage struct {
-	Success bool
-	BanID   uint64 // Only present if Success=true
-	Message string
+// ListLoginLockoutsMessage (0x62) - Request the accounts and IP addresses locked out
+// after failed logins (admin only)
+type ListLoginLockoutsMessage struct{}
+
+func (m *ListLoginLockoutsMessage) EncodeTo(w io.Writer) error {
+	// Empty message
+	return nil
 }
 
-func (m *IPBannedMessage) EncodeTo(w io.Writer) error {
-	if err := WriteBool(w, m.Success); err != nil {
+func (m *ListLoginLockoutsMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *ListLoginLockoutsMessage) Decode(payload []byte) error {
+	// Empty message - nothing to decode
+	return nil
+}
+
+// LoginLockout is an account or IP address locked out after failed logins
+type LoginLockout struct {
+	Kind          string // "user" or "ip"
+	Identifier    string // Nickname or IP address
+	Failures      uint32
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// LoginLockoutListMessage (0xB8) - Response to LIST_LOGIN_LOCKOUTS
+type LoginLockoutListMessage struct {
+	Lockouts []LoginLockout
+}
+
+func (m *LoginLockoutListMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint16(w, uint16(len(m.Lockouts))); err != nil {
 		return err
 	}
-	if m.Success {
-		if err := WriteUint64(w, m.BanID); err != nil {
+	for _, lockout := range m.Lockouts {
+		if err := WriteString(w, lockout.Kind); err != nil {
+			return err
+		}
+		if err := WriteString(w, lockout.Identifier); err != nil {
+			return err
+		}
+		if err := WriteUint32(w, lockout.Failures); err != nil {
+			return err
+		}
+		if err := WriteTimestamp(w, lockout.LastFailureAt); err != nil {
+			return err
+		}
+		if err := WriteTimestamp(w, lockout.LockedUntil); err != nil {
 			return err
 		}
 	}
-	return WriteString(w, m.Message)
+	return nil
 }
 
-func (m *IPBannedMessage) Encode() ([]byte, error) {
+func (m *LoginLockoutListMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3582,44 +6773,49 @@ func (m *IPBannedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *IPBannedMessage) Decode(payload []byte) error {
+func (m *LoginLockoutListMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	success, err := ReadBool(buf)
+	count, err := ReadUint16(buf)
 	if err != nil {
 		return err
 	}
-	m.Success = success
-
-	if success {
-		banID, err := ReadUint64(buf)
-		if err != nil {
+	m.Lockouts = make([]LoginLockout, count)
+	for i := range m.Lockouts {
+		lockout := &m.Lockouts[i]
+		if lockout.Kind, err = ReadString(buf); err != nil {
+			return err
+		}
+		if lockout.Identifier, err = ReadString(buf); err != nil {
+			return err
+		}
+		if lockout.Failures, err = ReadUint32(buf); err != nil {
+			return err
+		}
+		if lockout.LastFailureAt, err = ReadTimestamp(buf); err != nil {
+			return err
+		}
+		if lockout.LockedUntil, err = ReadTimestamp(buf); err != nil {
 			return err
 		}
-		m.BanID = banID
-	}
-
-	message, err := ReadString(buf)
-	if err != nil {
-		return err
 	}
-	m.Message = message
 	return nil
 }
 
-// UnbanUserMessage (0x5B) - Remove user ban
-type UnbanUserMessage struct {
-	UserID   *uint64 // Optional: user ID to unban
-	Nickname *string // Optional: nickname to unban
+// ClearLoginLockoutMessage (0x63) - Lift a login lockout and forget the failed logins
+// of an account or IP address (admin only)
+type ClearLoginLockoutMessage struct {
+	Kind       string // "user" or "ip"
+	Identifier string // Nickname or IP address
 }
 
-func (m *UnbanUserMessage) EncodeTo(w io.Writer) error {
-	if err := WriteOptionalUint64(w, m.UserID); err != nil {
+func (m *ClearLoginLockoutMessage) EncodeTo(w io.Writer) error {
+	if err := WriteString(w, m.Kind); err != nil {
 		return err
 	}
-	return WriteOptionalString(w, m.Nickname)
+	return WriteString(w, m.Identifier)
 }
 
-func (m *UnbanUserMessage) Encode() ([]byte, error) {
+func (m *ClearLoginLockoutMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3627,36 +6823,35 @@ func (m *UnbanUserMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UnbanUserMessage) Decode(payload []byte) error {
+func (m *ClearLoginLockoutMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	userID, err := ReadOptionalUint64(buf)
+	kind, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-	nickname, err := ReadOptionalString(buf)
+	identifier, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-
-	m.UserID = userID
-	m.Nickname = nickname
+	m.Kind = kind
+	m.Identifier = identifier
 	return nil
 }
 
-// UserUnbannedMessage (0xA6) - Response to UNBAN_USER
-type UserUnbannedMessage struct {
+// LoginLockoutClearedMessage (0xB9) - Response to CLEAR_LOGIN_LOCKOUT
+type LoginLockoutClearedMessage struct {
 	Success bool
 	Message string
 }
 
-func (m *UserUnbannedMessage) EncodeTo(w io.Writer) error {
+func (m *LoginLockoutClearedMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
 	return WriteString(w, m.Message)
 }
 
-func (m *UserUnbannedMessage) Encode() ([]byte, error) {
+func (m *LoginLockoutClearedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3664,7 +6859,7 @@ func (m *UserUnbannedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UserUnbannedMessage) Decode(payload []byte) error {
+func (m *LoginLockoutClearedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	success, err := ReadBool(buf)
 	if err != nil {
@@ -3674,22 +6869,22 @@ func (m *UserUnbannedMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
-
 	m.Success = success
 	m.Message = message
 	return nil
 }
 
-// UnbanIPMessage (0x5C) - Remove IP ban
-type UnbanIPMessage struct {
-	IPCIDR string // IP address or CIDR range to unban
+// AuthTwoFactorMessage (0x64) - Second login step: the authenticator or recovery code
+// asked for by an AUTH_RESPONSE with TwoFactorRequired
+type AuthTwoFactorMessage struct {
+	Code string
 }
 
-func (m *UnbanIPMessage) EncodeTo(w io.Writer) error {
-	return WriteString(w, m.IPCIDR)
+func (m *AuthTwoFactorMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Code)
 }
 
-func (m *UnbanIPMessage) Encode() ([]byte, error) {
+func (m *AuthTwoFactorMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3697,31 +6892,56 @@ func (m *UnbanIPMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UnbanIPMessage) Decode(payload []byte) error {
+func (m *AuthTwoFactorMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	ipCIDR, err := ReadString(buf)
+	code, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
+	m.Code = code
+	return nil
+}
 
-	m.IPCIDR = ipCIDR
+// Enable2FAMessage (0x65) - Start setting up two-factor authentication (registered users)
+type Enable2FAMessage struct{}
+
+func (m *Enable2FAMessage) EncodeTo(w io.Writer) error {
+	// Empty message
 	return nil
 }
 
-// IPUnbannedMessage (0xA7) - Response to UNBAN_IP
-type IPUnbannedMessage struct {
+func (m *Enable2FAMessage) Encode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *Enable2FAMessage) Decode(payload []byte) error {
+	// Empty message - nothing to decode
+	return nil
+}
+
+// TwoFactorSetupMessage (0xBA) - Response to ENABLE_2FA: the secret to add to an
+// authenticator app
+type TwoFactorSetupMessage struct {
 	Success bool
-	Message string
+	Secret  string // Base32, for typing in by hand
+	URI     string // otpauth:// URI, for scanning as a QR code
+	Message string // Error description if failed
 }
 
-func (m *IPUnbannedMessage) EncodeTo(w io.Writer) error {
+func (m *TwoFactorSetupMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
+	if err := WriteString(w, m.Secret); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.URI); err != nil {
+		return err
+	}
 	return WriteString(w, m.Message)
 }
 
-func (m *IPUnbannedMessage) Encode() ([]byte, error) {
+func (m *TwoFactorSetupMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3729,32 +6949,42 @@ func (m *IPUnbannedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *IPUnbannedMessage) Decode(payload []byte) error {
+func (m *TwoFactorSetupMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
+	secret, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	uri, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
 	message, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-
 	m.Success = success
+	m.Secret = secret
+	m.URI = uri
 	m.Message = message
 	return nil
 }
 
-// ListBansMessage (0x5D) - Request list of all active bans
-type ListBansMessage struct {
-	IncludeExpired bool
+// Confirm2FAMessage (0x66) - Finish setting up two-factor authentication with a code
+// from the authenticator app
+type Confirm2FAMessage struct {
+	Code string
 }
 
-func (m *ListBansMessage) EncodeTo(w io.Writer) error {
-	return WriteBool(w, m.IncludeExpired)
+func (m *Confirm2FAMessage) EncodeTo(w io.Writer) error {
+	return WriteString(w, m.Code)
 }
 
-func (m *ListBansMessage) Encode() ([]byte, error) {
+func (m *Confirm2FAMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3762,78 +6992,39 @@ func (m *ListBansMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ListBansMessage) Decode(payload []byte) error {
+func (m *Confirm2FAMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	includeExpired, err := ReadBool(buf)
+	code, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-
-	m.IncludeExpired = includeExpired
+	m.Code = code
 	return nil
 }
 
-// BanEntry represents a single ban in the ban list
-type BanEntry struct {
-	ID          uint64
-	Type        string  // "user" or "ip"
-	UserID      *uint64 // NULL for IP bans
-	Nickname    *string // NULL for IP bans
-	IPCIDR      *string // NULL for user bans
-	Reason      string
-	Shadowban   bool
-	BannedAt    int64  // Unix milliseconds
-	BannedUntil *int64 // NULL = permanent, Unix milliseconds for timed bans
-	BannedBy    string // Admin nickname
-}
-
-// BanListMessage (0xA8) - List of active bans
-type BanListMessage struct {
-	Bans []BanEntry
+// TwoFactorEnabledMessage (0xBB) - Response to CONFIRM_2FA
+type TwoFactorEnabledMessage struct {
+	Success       bool
+	RecoveryCodes []string // One-time codes for logging in without the authenticator
+	Message       string
 }
 
-func (m *BanListMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint16(w, uint16(len(m.Bans))); err != nil {
+func (m *TwoFactorEnabledMessage) EncodeTo(w io.Writer) error {
+	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
-
-	for _, ban := range m.Bans {
-		if err := WriteUint64(w, ban.ID); err != nil {
-			return err
-		}
-		if err := WriteString(w, ban.Type); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, ban.UserID); err != nil {
-			return err
-		}
-		if err := WriteOptionalString(w, ban.Nickname); err != nil {
-			return err
-		}
-		if err := WriteOptionalString(w, ban.IPCIDR); err != nil {
-			return err
-		}
-		if err := WriteString(w, ban.Reason); err != nil {
-			return err
-		}
-		if err := WriteBool(w, ban.Shadowban); err != nil {
-			return err
-		}
-		if err := WriteInt64(w, ban.BannedAt); err != nil {
-			return err
-		}
-		if err := WriteOptionalInt64(w, ban.BannedUntil); err != nil {
-			return err
-		}
-		if err := WriteString(w, ban.BannedBy); err != nil {
+	if err := WriteUint16(w, uint16(len(m.RecoveryCodes))); err != nil {
+		return err
+	}
+	for _, code := range m.RecoveryCodes {
+		if err := WriteString(w, code); err != nil {
 			return err
 		}
 	}
-
-	return nil
+	return WriteString(w, m.Message)
 }
 
-func (m *BanListMessage) Encode() ([]byte, error) {
+func (m *TwoFactorEnabledMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3841,85 +7032,49 @@ func (m *BanListMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *BanListMessage) Decode(payload []byte) error {
+func (m *TwoFactorEnabledMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-
-	count, err := ReadUint16(buf)
+	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-
-	m.Bans = make([]BanEntry, count)
-
-	for i := uint16(0); i < count; i++ {
-		id, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		banType, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		userID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		nickname, err := ReadOptionalString(buf)
-		if err != nil {
-			return err
-		}
-		ipCIDR, err := ReadOptionalString(buf)
-		if err != nil {
-			return err
-		}
-		reason, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-		shadowban, err := ReadBool(buf)
-		if err != nil {
-			return err
-		}
-		bannedAt, err := ReadInt64(buf)
-		if err != nil {
-			return err
-		}
-		bannedUntil, err := ReadOptionalInt64(buf)
-		if err != nil {
-			return err
-		}
-		bannedBy, err := ReadString(buf)
-		if err != nil {
-			return err
-		}
-
-		m.Bans[i] = BanEntry{
-			ID:          id,
-			Type:        banType,
-			UserID:      userID,
-			Nickname:    nickname,
-			IPCIDR:      ipCIDR,
-			Reason:      reason,
-			Shadowban:   shadowban,
-			BannedAt:    bannedAt,
-			BannedUntil: bannedUntil,
-			BannedBy:    bannedBy,
+	count, err := ReadUint16(buf)
+	if err != nil {
+		return err
+	}
+	var codes []string
+	for i := 0; i < int(count); i++ {
+		code, err := ReadString(buf)
+		if err != nil {
+			return err
 		}
+		codes = append(codes, code)
 	}
-
+	message, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Success = success
+	m.RecoveryCodes = codes
+	m.Message = message
 	return nil
 }
 
-// DeleteUserMessage (0x5E) - Permanently delete a user account
-type DeleteUserMessage struct {
-	UserID uint64
+// Disable2FAMessage (0x67) - Turn off two-factor authentication: your own with a
+// current code, or another user's (admin only)
+type Disable2FAMessage struct {
+	Nickname *string // Another user (admin only); nil for yourself
+	Code     string  // Authenticator or recovery code (ignored for other users)
 }
 
-func (m *DeleteUserMessage) EncodeTo(w io.Writer) error {
-	return WriteUint64(w, m.UserID)
+func (m *Disable2FAMessage) EncodeTo(w io.Writer) error {
+	if err := WriteOptionalString(w, m.Nickname); err != nil {
+		return err
+	}
+	return WriteString(w, m.Code)
 }
 
-func (m *DeleteUserMessage) Encode() ([]byte, error) {
+func (m *Disable2FAMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3927,31 +7082,35 @@ func (m *DeleteUserMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *DeleteUserMessage) Decode(payload []byte) error {
+func (m *Disable2FAMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	userID, err := ReadUint64(buf)
+	nickname, err := ReadOptionalString(buf)
 	if err != nil {
 		return err
 	}
-
-	m.UserID = userID
+	code, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	m.Nickname = nickname
+	m.Code = code
 	return nil
 }
 
-// UserDeletedMessage (0xA9) - Response to DELETE_USER
-type UserDeletedMessage struct {
+// TwoFactorDisabledMessage (0xBC) - Response to DISABLE_2FA
+type TwoFactorDisabledMessage struct {
 	Success bool
 	Message string
 }
 
-func (m *UserDeletedMessage) EncodeTo(w io.Writer) error {
+func (m *TwoFactorDisabledMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
 	return WriteString(w, m.Message)
 }
 
-func (m *UserDeletedMessage) Encode() ([]byte, error) {
+func (m *TwoFactorDisabledMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3959,7 +7118,7 @@ func (m *UserDeletedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UserDeletedMessage) Decode(payload []byte) error {
+func (m *TwoFactorDisabledMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	success, err := ReadBool(buf)
 	if err != nil {
@@ -3969,26 +7128,31 @@ func (m *UserDeletedMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
-
 	m.Success = success
 	m.Message = message
 	return nil
 }
 
-// DeleteChannelMessage (0x5F) - Delete a channel (admin only)
-type DeleteChannelMessage struct {
-	ChannelID uint64
-	Reason    string
+// ===== Profile Messages =====
+
+// SetProfileMessage (0x68) - Replace your own profile (registered users only)
+type SetProfileMessage struct {
+	DisplayName string
+	Pronouns    string
+	Bio         string
+	Status      string // Free-form, e.g. "in a meeting"
 }
 
-func (m *DeleteChannelMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint64(w, m.ChannelID); err != nil {
-		return err
+func (m *SetProfileMessage) EncodeTo(w io.Writer) error {
+	for _, field := range []string{m.DisplayName, m.Pronouns, m.Bio, m.Status} {
+		if err := WriteString(w, field); err != nil {
+			return err
+		}
 	}
-	return WriteString(w, m.Reason)
+	return nil
 }
 
-func (m *DeleteChannelMessage) Encode() ([]byte, error) {
+func (m *SetProfileMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -3996,40 +7160,31 @@ func (m *DeleteChannelMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *DeleteChannelMessage) Decode(payload []byte) error {
+func (m *SetProfileMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	channelID, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
-	reason, err := ReadString(buf)
-	if err != nil {
-		return err
+	var err error
+	for _, field := range []*string{&m.DisplayName, &m.Pronouns, &m.Bio, &m.Status} {
+		if *field, err = ReadString(buf); err != nil {
+			return err
+		}
 	}
-
-	m.ChannelID = channelID
-	m.Reason = reason
 	return nil
 }
 
-// ChannelDeletedMessage (0xAA) - Response to DELETE_CHANNEL + broadcast
-type ChannelDeletedMessage struct {
-	Success   bool
-	ChannelID uint64
-	Message   string
+// ProfileUpdatedMessage (0xBD) - Response to SET_PROFILE
+type ProfileUpdatedMessage struct {
+	Success bool
+	Message string
 }
 
-func (m *ChannelDeletedMessage) EncodeTo(w io.Writer) error {
+func (m *ProfileUpdatedMessage) EncodeTo(w io.Writer) error {
 	if err := WriteBool(w, m.Success); err != nil {
 		return err
 	}
-	if err := WriteUint64(w, m.ChannelID); err != nil {
-		return err
-	}
 	return WriteString(w, m.Message)
 }
 
-func (m *ChannelDeletedMessage) Encode() ([]byte, error) {
+func (m *ProfileUpdatedMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -4037,62 +7192,42 @@ func (m *ChannelDeletedMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *ChannelDeletedMessage) Decode(payload []byte) error {
+func (m *ProfileUpdatedMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	success, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
-	channelID, err := ReadUint64(buf)
-	if err != nil {
-		return err
-	}
 	message, err := ReadString(buf)
 	if err != nil {
 		return err
 	}
-
 	m.Success = success
-	m.ChannelID = channelID
 	m.Message = message
 	return nil
 }
 
-// UnreadTarget represents a channel/subchannel/thread for unread count requests
-type UnreadTarget struct {
+// ===== Typing Messages =====
+
+// TypingStartMessage (0x69) - The user is composing a message. Clients repeat it
+// every few seconds while typing; the server forgets it after a few seconds without one.
+type TypingStartMessage struct {
 	ChannelID    uint64
 	SubchannelID *uint64
-	ThreadID     *uint64 // Optional: if present, count only messages in this thread
-}
-
-// GetUnreadCountsMessage (0x18) - Request unread counts for channels
-type GetUnreadCountsMessage struct {
-	SinceTimestamp *int64         // Optional: if null, uses server's stored last_read_at (registered users only)
-	Targets        []UnreadTarget // Channels/subchannels to get counts for
+	ThreadID     *uint64 // Thread root being replied to (nil = chat or a new thread)
 }
 
-func (m *GetUnreadCountsMessage) EncodeTo(w io.Writer) error {
-	if err := WriteOptionalInt64(w, m.SinceTimestamp); err != nil {
+func (m *TypingStartMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	if err := WriteUint16(w, uint16(len(m.Targets))); err != nil {
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
 		return err
 	}
-	for _, target := range m.Targets {
-		if err := WriteUint64(w, target.ChannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, target.SubchannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, target.ThreadID); err != nil {
-			return err
-		}
-	}
-	return nil
+	return WriteOptionalUint64(w, m.ThreadID)
 }
 
-func (m *GetUnreadCountsMessage) Encode() ([]byte, error) {
+func (m *TypingStartMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -4100,76 +7235,44 @@ func (m *GetUnreadCountsMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *GetUnreadCountsMessage) Decode(payload []byte) error {
+func (m *TypingStartMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	sinceTimestamp, err := ReadOptionalInt64(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	targetCount, err := ReadUint16(buf)
+	subchannelID, err := ReadOptionalUint64(buf)
 	if err != nil {
 		return err
 	}
-	targets := make([]UnreadTarget, targetCount)
-	for i := uint16(0); i < targetCount; i++ {
-		channelID, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		subchannelID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		threadID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		targets[i] = UnreadTarget{
-			ChannelID:    channelID,
-			SubchannelID: subchannelID,
-			ThreadID:     threadID,
-		}
+	threadID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
 	}
-	m.SinceTimestamp = sinceTimestamp
-	m.Targets = targets
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ThreadID = threadID
 	return nil
 }
 
-// UnreadCount represents unread count for a single channel/subchannel/thread
-type UnreadCount struct {
+// TypingStopMessage (0x6A) - The user stopped composing (cleared the input or cancelled)
+type TypingStopMessage struct {
 	ChannelID    uint64
 	SubchannelID *uint64
-	ThreadID     *uint64 // Optional: if present, this count is for a specific thread
-	UnreadCount  uint32
-}
-
-// UnreadCountsMessage (0x97) - Response with unread counts
-type UnreadCountsMessage struct {
-	Counts []UnreadCount
+	ThreadID     *uint64
 }
 
-func (m *UnreadCountsMessage) EncodeTo(w io.Writer) error {
-	if err := WriteUint16(w, uint16(len(m.Counts))); err != nil {
+func (m *TypingStopMessage) EncodeTo(w io.Writer) error {
+	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
-	for _, count := range m.Counts {
-		if err := WriteUint64(w, count.ChannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, count.SubchannelID); err != nil {
-			return err
-		}
-		if err := WriteOptionalUint64(w, count.ThreadID); err != nil {
-			return err
-		}
-		if err := WriteUint32(w, count.UnreadCount); err != nil {
-			return err
-		}
+	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
+		return err
 	}
-	return nil
+	return WriteOptionalUint64(w, m.ThreadID)
 }
 
-func (m *UnreadCountsMessage) Encode() ([]byte, error) {
+func (m *TypingStopMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -4177,59 +7280,56 @@ func (m *UnreadCountsMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UnreadCountsMessage) Decode(payload []byte) error {
+func (m *TypingStopMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
-	countCount, err := ReadUint16(buf)
+	channelID, err := ReadUint64(buf)
 	if err != nil {
 		return err
 	}
-	counts := make([]UnreadCount, countCount)
-	for i := uint16(0); i < countCount; i++ {
-		channelID, err := ReadUint64(buf)
-		if err != nil {
-			return err
-		}
-		subchannelID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		threadID, err := ReadOptionalUint64(buf)
-		if err != nil {
-			return err
-		}
-		unreadCount, err := ReadUint32(buf)
-		if err != nil {
-			return err
-		}
-		counts[i] = UnreadCount{
-			ChannelID:    channelID,
-			SubchannelID: subchannelID,
-			ThreadID:     threadID,
-			UnreadCount:  unreadCount,
-		}
+	subchannelID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
 	}
-	m.Counts = counts
+	threadID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	m.ChannelID = channelID
+	m.SubchannelID = subchannelID
+	m.ThreadID = threadID
 	return nil
 }
 
-// UpdateReadStateMessage (0x1D) - Update last read timestamp for a channel
-type UpdateReadStateMessage struct {
+// UserTypingMessage (0xBE) - Someone started or stopped typing where you're subscribed
+type UserTypingMessage struct {
 	ChannelID    uint64
 	SubchannelID *uint64
-	Timestamp    int64 // Unix timestamp (seconds)
+	ThreadID     *uint64
+	SessionID    uint64 // Tells apart two sessions with the same nickname
+	Nickname     string
+	Typing       bool
 }
 
-func (m *UpdateReadStateMessage) EncodeTo(w io.Writer) error {
+func (m *UserTypingMessage) EncodeTo(w io.Writer) error {
 	if err := WriteUint64(w, m.ChannelID); err != nil {
 		return err
 	}
 	if err := WriteOptionalUint64(w, m.SubchannelID); err != nil {
 		return err
 	}
-	return WriteInt64(w, m.Timestamp)
+	if err := WriteOptionalUint64(w, m.ThreadID); err != nil {
+		return err
+	}
+	if err := WriteUint64(w, m.SessionID); err != nil {
+		return err
+	}
+	if err := WriteString(w, m.Nickname); err != nil {
+		return err
+	}
+	return WriteBool(w, m.Typing)
 }
 
-func (m *UpdateReadStateMessage) Encode() ([]byte, error) {
+func (m *UserTypingMessage) Encode() ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := m.EncodeTo(buf); err != nil {
 		return nil, err
@@ -4237,7 +7337,7 @@ func (m *UpdateReadStateMessage) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (m *UpdateReadStateMessage) Decode(payload []byte) error {
+func (m *UserTypingMessage) Decode(payload []byte) error {
 	buf := bytes.NewReader(payload)
 	channelID, err := ReadUint64(buf)
 	if err != nil {
@@ -4247,16 +7347,61 @@ func (m *UpdateReadStateMessage) Decode(payload []byte) error {
 	if err != nil {
 		return err
 	}
-	timestamp, err := ReadInt64(buf)
+	threadID, err := ReadOptionalUint64(buf)
+	if err != nil {
+		return err
+	}
+	sessionID, err := ReadUint64(buf)
+	if err != nil {
+		return err
+	}
+	nickname, err := ReadString(buf)
+	if err != nil {
+		return err
+	}
+	typing, err := ReadBool(buf)
 	if err != nil {
 		return err
 	}
 	m.ChannelID = channelID
 	m.SubchannelID = subchannelID
-	m.Timestamp = timestamp
+	m.ThreadID = threadID
+	m.SessionID = sessionID
+	m.Nickname = nickname
+	m.Typing = typing
+	return nil
+}
+
+// writeUint64List writes a u16 count followed by the IDs
+func writeUint64List(w io.Writer, ids []uint64) error {
+	if err := WriteUint16(w, uint16(len(ids))); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := WriteUint64(w, id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// readUint64List reads a list written by writeUint64List (nil if empty)
+func readUint64List(r io.Reader) ([]uint64, error) {
+	count, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for i := uint16(0); i < count; i++ {
+		id, err := ReadUint64(r)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // Compile-time checks to ensure all message types implement the ProtocolMessage interface
 // This will cause a compile error if any message type is missing Encode(), EncodeTo(), or Decode()
 var (
@@ -4269,6 +7414,14 @@ var (
 	_ ProtocolMessage = (*JoinChannelMessage)(nil)
 	_ ProtocolMessage = (*LeaveChannelMessage)(nil)
 	_ ProtocolMessage = (*CreateChannelMessage)(nil)
+	_ ProtocolMessage = (*CreateSubchannelMessage)(nil)
+	_ ProtocolMessage = (*GetSubchannelsMessage)(nil)
+	_ ProtocolMessage = (*StartDMMessage)(nil)
+	_ ProtocolMessage = (*AddDMParticipantMessage)(nil)
+	_ ProtocolMessage = (*ListDMsMessage)(nil)
+	_ ProtocolMessage = (*ProvidePublicKeyMessage)(nil)
+	_ ProtocolMessage = (*AllowUnencryptedMessage)(nil)
+	_ ProtocolMessage = (*ProvideChannelKeysMessage)(nil)
 	_ ProtocolMessage = (*ListMessagesMessage)(nil)
 	_ ProtocolMessage = (*PostMessageMessage)(nil)
 	_ ProtocolMessage = (*EditMessageMessage)(nil)
@@ -4297,6 +7450,23 @@ var (
 	_ ProtocolMessage = (*UnbanIPMessage)(nil)
 	_ ProtocolMessage = (*ListBansMessage)(nil)
 	_ ProtocolMessage = (*DeleteUserMessage)(nil)
+	_ ProtocolMessage = (*SearchMessagesMessage)(nil)
+	_ ProtocolMessage = (*AddReactionMessage)(nil)
+	_ ProtocolMessage = (*RemoveReactionMessage)(nil)
+	_ ProtocolMessage = (*ListMentionsMessage)(nil)
+	_ ProtocolMessage = (*MarkMentionsReadMessage)(nil)
+	_ ProtocolMessage = (*GetMessageHistoryMessage)(nil)
+	_ ProtocolMessage = (*ListAdminActionsMessage)(nil)
+	_ ProtocolMessage = (*SetUserFlagsMessage)(nil)
+	_ ProtocolMessage = (*ListLoginLockoutsMessage)(nil)
+	_ ProtocolMessage = (*ClearLoginLockoutMessage)(nil)
+	_ ProtocolMessage = (*AuthTwoFactorMessage)(nil)
+	_ ProtocolMessage = (*Enable2FAMessage)(nil)
+	_ ProtocolMessage = (*Confirm2FAMessage)(nil)
+	_ ProtocolMessage = (*Disable2FAMessage)(nil)
+	_ ProtocolMessage = (*SetProfileMessage)(nil)
+	_ ProtocolMessage = (*TypingStartMessage)(nil)
+	_ ProtocolMessage = (*TypingStopMessage)(nil)
 
 	// Server → Client messages
 	_ ProtocolMessage = (*AuthResponseMessage)(nil)
@@ -4306,6 +7476,8 @@ var (
 	_ ProtocolMessage = (*JoinResponseMessage)(nil)
 	_ ProtocolMessage = (*LeaveResponseMessage)(nil)
 	_ ProtocolMessage = (*ChannelCreatedMessage)(nil)
+	_ ProtocolMessage = (*SubchannelCreatedMessage)(nil)
+	_ ProtocolMessage = (*SubchannelListMessage)(nil)
 	_ ProtocolMessage = (*MessageListMessage)(nil)
 	_ ProtocolMessage = (*MessagePostedMessage)(nil)
 	_ ProtocolMessage = (*MessageEditedMessage)(nil)
@@ -4313,12 +7485,34 @@ var (
 	_ ProtocolMessage = (*PongMessage)(nil)
 	_ ProtocolMessage = (*ErrorMessage)(nil)
 	_ ProtocolMessage = (*ServerConfigMessage)(nil)
+	_ ProtocolMessage = (*SetCompressionMessage)(nil)
 	_ ProtocolMessage = (*SubscribeOkMessage)(nil)
 	_ ProtocolMessage = (*UserInfoMessage)(nil)
 	_ ProtocolMessage = (*UserListMessage)(nil)
 	_ ProtocolMessage = (*ChannelUserListMessage)(nil)
 	_ ProtocolMessage = (*ChannelPresenceMessage)(nil)
 	_ ProtocolMessage = (*ServerPresenceMessage)(nil)
+	_ ProtocolMessage = (*DMReadyMessage)(nil)
+	_ ProtocolMessage = (*DMRequestMessage)(nil)
+	_ ProtocolMessage = (*DMListMessage)(nil)
+	_ ProtocolMessage = (*KeyRequiredMessage)(nil)
+	_ ProtocolMessage = (*DMPendingMessage)(nil)
+	_ ProtocolMessage = (*DMKeyExchangeMessage)(nil)
+	_ ProtocolMessage = (*SearchResultsMessage)(nil)
+	_ ProtocolMessage = (*ReactionsUpdatedMessage)(nil)
+	_ ProtocolMessage = (*MentionNotificationMessage)(nil)
+	_ ProtocolMessage = (*MentionListMessage)(nil)
+	_ ProtocolMessage = (*MessagesExpiredMessage)(nil)
+	_ ProtocolMessage = (*MessageHistoryMessage)(nil)
+	_ ProtocolMessage = (*AdminActionListMessage)(nil)
+	_ ProtocolMessage = (*UserFlagsUpdatedMessage)(nil)
+	_ ProtocolMessage = (*LoginLockoutListMessage)(nil)
+	_ ProtocolMessage = (*LoginLockoutClearedMessage)(nil)
+	_ ProtocolMessage = (*TwoFactorSetupMessage)(nil)
+	_ ProtocolMessage = (*TwoFactorEnabledMessage)(nil)
+	_ ProtocolMessage = (*TwoFactorDisabledMessage)(nil)
+	_ ProtocolMessage = (*ProfileUpdatedMessage)(nil)
+	_ ProtocolMessage = (*UserTypingMessage)(nil)
 	_ ProtocolMessage = (*ServerListMessage)(nil)
 	_ ProtocolMessage = (*RegisterAckMessage)(nil)
 	_ ProtocolMessage = (*VerifyResponseMessage)(nil)
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -93,6 +94,14 @@ func TestAuthResponseMessage(t *testing.T) {
 				UserFlags: makeFlags(UserFlagAdmin),
 			},
 		},
+		{
+			name: "two-factor code required",
+			msg: AuthResponseMessage{
+				Success:           false,
+				Message:           "Two-factor authentication code required",
+				TwoFactorRequired: true,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -105,6 +114,7 @@ func TestAuthResponseMessage(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, tt.msg.Success, decoded.Success)
 			assert.Equal(t, tt.msg.Message, decoded.Message)
+			assert.Equal(t, tt.msg.TwoFactorRequired, decoded.TwoFactorRequired)
 			if tt.msg.Success {
 				assert.Equal(t, tt.msg.UserID, decoded.UserID)
 				assert.Equal(t, tt.msg.Nickname, decoded.Nickname)
@@ -296,6 +306,262 @@ func TestChannelCreatedMessage(t *testing.T) {
 	}
 }
 
+func TestCreateSubchannelMessage(t *testing.T) {
+	desc1 := "Release announcements"
+
+	tests := []struct {
+		name string
+		msg  CreateSubchannelMessage
+	}{
+		{
+			name: "with description",
+			msg: CreateSubchannelMessage{
+				ChannelID:      7,
+				Name:           "announcements",
+				DisplayName:    "/announcements",
+				Description:    &desc1,
+				SubchannelType: 1,
+				RetentionHours: 720,
+			},
+		},
+		{
+			name: "without description",
+			msg: CreateSubchannelMessage{
+				ChannelID:      7,
+				Name:           "lounge",
+				DisplayName:    "/lounge",
+				Description:    nil,
+				SubchannelType: 0,
+				RetentionHours: 24,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &CreateSubchannelMessage{}
+			err = decoded.Decode(payload)
+			require.NoError(t, err)
+			assert.Equal(t, tt.msg.ChannelID, decoded.ChannelID)
+			assert.Equal(t, tt.msg.Name, decoded.Name)
+			assert.Equal(t, tt.msg.DisplayName, decoded.DisplayName)
+			assert.Equal(t, tt.msg.SubchannelType, decoded.SubchannelType)
+			assert.Equal(t, tt.msg.RetentionHours, decoded.RetentionHours)
+
+			if tt.msg.Description == nil {
+				assert.Nil(t, decoded.Description)
+			} else {
+				require.NotNil(t, decoded.Description)
+				assert.Equal(t, *tt.msg.Description, *decoded.Description)
+			}
+		})
+	}
+}
+
+func TestSubchannelCreatedMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  SubchannelCreatedMessage
+	}{
+		{
+			name: "success response",
+			msg: SubchannelCreatedMessage{
+				Success:        true,
+				ChannelID:      7,
+				SubchannelID:   3,
+				Name:           "announcements",
+				Description:    "Release announcements",
+				Type:           1,
+				RetentionHours: 720,
+				Message:        "Subchannel created successfully",
+			},
+		},
+		{
+			name: "failure response",
+			msg: SubchannelCreatedMessage{
+				Success: false,
+				Message: "Channel not found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &SubchannelCreatedMessage{}
+			err = decoded.Decode(payload)
+			require.NoError(t, err)
+			assert.Equal(t, tt.msg, *decoded)
+		})
+	}
+}
+
+func TestSubchannelListMessage(t *testing.T) {
+	t.Run("get subchannels request", func(t *testing.T) {
+		msg := &GetSubchannelsMessage{ChannelID: 99}
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &GetSubchannelsMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, uint64(99), decoded.ChannelID)
+	})
+
+	t.Run("list with subchannels", func(t *testing.T) {
+		msg := &SubchannelListMessage{
+			ChannelID: 99,
+			Subchannels: []Subchannel{
+				{ID: 1, Name: "lounge", Description: "", Type: 0, RetentionHours: 24},
+				{ID: 2, Name: "support", Description: "Help threads", Type: 1, RetentionHours: 168},
+			},
+		}
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &SubchannelListMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		msg := &SubchannelListMessage{ChannelID: 5}
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &SubchannelListMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, uint64(5), decoded.ChannelID)
+		assert.Empty(t, decoded.Subchannels)
+	})
+
+	t.Run("truncated payload", func(t *testing.T) {
+		decoded := &SubchannelListMessage{}
+		assert.Error(t, decoded.Decode([]byte{0, 0, 0, 0, 0, 0, 0, 5, 0, 1}))
+	})
+}
+
+func TestStartDMMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *StartDMMessage
+	}{
+		{"by user id", &StartDMMessage{TargetType: DMTargetUserID, TargetID: 42, AllowUnencrypted: true}},
+		{"by nickname", &StartDMMessage{TargetType: DMTargetNickname, TargetNickname: "alice"}},
+		{"by session id", &StartDMMessage{TargetType: DMTargetSessionID, TargetID: 7, AllowUnencrypted: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &StartDMMessage{}
+			require.NoError(t, decoded.Decode(payload))
+			assert.Equal(t, tt.msg, decoded)
+		})
+	}
+
+	t.Run("invalid target type", func(t *testing.T) {
+		decoded := &StartDMMessage{}
+		assert.ErrorIs(t, decoded.Decode([]byte{0x09, 0, 0, 0, 0, 0, 0, 0, 1, 1}), ErrInvalidDMTarget)
+	})
+}
+
+func TestAddDMParticipantMessage(t *testing.T) {
+	msg := &AddDMParticipantMessage{ChannelID: 12, TargetType: DMTargetNickname, TargetNickname: "carol"}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &AddDMParticipantMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+}
+
+func TestDMReadyAndRequestMessages(t *testing.T) {
+	otherID := uint64(5)
+
+	t.Run("dm ready", func(t *testing.T) {
+		msg := &DMReadyMessage{ChannelID: 3, OtherUserID: &otherID, OtherNickname: "bob"}
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &DMReadyMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	})
+
+	t.Run("dm request", func(t *testing.T) {
+		msg := &DMRequestMessage{ChannelID: 3, FromUserID: &otherID, FromNickname: "bob", InitiatorAllowsUnencrypted: true}
+		payload, err := msg.Encode()
+		require.NoError(t, err)
+
+		decoded := &DMRequestMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, msg, decoded)
+	})
+}
+
+func TestDMListMessage(t *testing.T) {
+	wrapped := "wrapped"
+	msg := &DMListMessage{
+		DMs: []DMChannel{
+			{ChannelID: 1, Participants: []DMParticipant{{UserID: 2, Nickname: "bob"}}},
+			{ChannelID: 9, IsEncrypted: true, ChannelKey: &wrapped, Participants: []DMParticipant{
+				{UserID: 2, Nickname: "bob"},
+				{UserID: 3, Nickname: "carol"},
+			}},
+		},
+	}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &DMListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	t.Run("truncated payload", func(t *testing.T) {
+		decoded := &DMListMessage{}
+		assert.Error(t, decoded.Decode(payload[:len(payload)-2]))
+	})
+}
+
+func TestDMEncryptionMessages(t *testing.T) {
+	channelID := uint64(9)
+	userID := uint64(2)
+
+	tests := []struct {
+		name    string
+		msg     ProtocolMessage
+		decoded ProtocolMessage
+	}{
+		{"provide public key", &ProvidePublicKeyMessage{KeyType: KeyTypeGenerated, PublicKey: "cHVibGlj", Label: "laptop"}, &ProvidePublicKeyMessage{}},
+		{"allow unencrypted", &AllowUnencryptedMessage{DMChannelID: 9, Permanent: true}, &AllowUnencryptedMessage{}},
+		{"provide channel keys", &ProvideChannelKeysMessage{ChannelID: 9, Keys: []WrappedChannelKey{{KeyID: 1, WrappedKey: "a"}, {KeyID: 4, WrappedKey: "b"}}}, &ProvideChannelKeysMessage{}},
+		{"key required", &KeyRequiredMessage{Reason: "DM encryption requires a key", DMChannelID: &channelID}, &KeyRequiredMessage{}},
+		{"key required without channel", &KeyRequiredMessage{Reason: "DM encryption requires a key"}, &KeyRequiredMessage{}},
+		{"dm pending", &DMPendingMessage{DMChannelID: 9, WaitingForUserID: &userID, WaitingForNickname: "bob", Reason: "Waiting for bob to set up encryption"}, &DMPendingMessage{}},
+		{"dm key exchange", &DMKeyExchangeMessage{ChannelID: 9, NewKey: true, Keys: []DMPublicKey{{KeyID: 1, UserID: 2, Nickname: "bob", PublicKey: "cHVibGlj"}}}, &DMKeyExchangeMessage{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+			require.NoError(t, tt.decoded.Decode(payload))
+			assert.Equal(t, tt.msg, tt.decoded)
+
+			if len(payload) > 0 {
+				assert.Error(t, tt.decoded.Decode(payload[:len(payload)-1]))
+			}
+		})
+	}
+}
+
 func TestSetNicknameMessage(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1361,6 +1627,754 @@ func TestServerConfigMessage(t *testing.T) {
 	assert.Equal(t, msg.MaxMessageLength, decoded.MaxMessageLength)
 }
 
+func TestErrorMessageRetryAfter(t *testing.T) {
+	retryAfter := uint64(2500)
+	msg := &ErrorMessage{ErrorCode: ErrCodeMessageRateLimit, Message: "Slow down!", RetryAfterMs: &retryAfter}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &ErrorMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	// Older servers don't send the field
+	older := &ErrorMessage{}
+	require.NoError(t, older.Decode(payload[:len(payload)-9]))
+	assert.Nil(t, older.RetryAfterMs)
+	assert.Equal(t, "Slow down!", older.Message)
+}
+
+func TestBanUserMessageChannel(t *testing.T) {
+	nickname := "troll"
+	duration := uint64(3600)
+	channelID := uint64(7)
+	msg := &BanUserMessage{Nickname: &nickname, Reason: "spam", DurationSeconds: &duration, ChannelID: &channelID}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &BanUserMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	// Older clients don't send the field
+	older := &BanUserMessage{}
+	require.NoError(t, older.Decode(payload[:len(payload)-9]))
+	assert.Nil(t, older.ChannelID)
+	assert.Equal(t, "spam", older.Reason)
+}
+
+func TestBanListMessageChannel(t *testing.T) {
+	nickname := "troll"
+	channelID := uint64(7)
+	msg := &BanListMessage{Bans: []BanEntry{
+		{ID: 1, Type: "user", Nickname: &nickname, Reason: "spam", BannedAt: 1000, BannedBy: "admin", ChannelID: &channelID},
+		{ID: 2, Type: "user", Nickname: &nickname, Reason: "abuse", BannedAt: 2000, BannedBy: "admin"},
+	}}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &BanListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	// Older servers don't send the channels
+	older := &BanListMessage{}
+	require.NoError(t, older.Decode(payload[:len(payload)-10]))
+	require.Len(t, older.Bans, 2)
+	assert.Nil(t, older.Bans[0].ChannelID)
+	assert.Equal(t, "abuse", older.Bans[1].Reason)
+}
+
+func TestServerConfigCompressionSupported(t *testing.T) {
+	msg := &ServerConfigMessage{ProtocolVersion: 1, CompressionSupported: true}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &ServerConfigMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.True(t, decoded.CompressionSupported)
+
+	// Older servers don't send the field
+	older := &ServerConfigMessage{}
+	require.NoError(t, older.Decode(payload[:len(payload)-1]))
+	assert.False(t, older.CompressionSupported)
+}
+
+func TestSetCompressionMessage(t *testing.T) {
+	msg := &SetCompressionMessage{Enabled: true}
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &SetCompressionMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	assert.Error(t, decoded.Decode([]byte{}))
+}
+
+func TestSearchMessagesMessage(t *testing.T) {
+	channelID := uint64(3)
+	threadID := uint64(99)
+	author := "~guest"
+	after := time.UnixMilli(1700000000000)
+	before := time.UnixMilli(1700086400000)
+
+	tests := []struct {
+		name string
+		msg  *SearchMessagesMessage
+	}{
+		{"query only", &SearchMessagesMessage{Query: "hello"}},
+		{"all filters", &SearchMessagesMessage{
+			Query:     "release notes*",
+			ChannelID: &channelID,
+			Author:    &author,
+			After:     &after,
+			Before:    &before,
+			ThreadID:  &threadID,
+			Limit:     25,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &SearchMessagesMessage{}
+			require.NoError(t, decoded.Decode(payload))
+			assert.Equal(t, tt.msg, decoded)
+		})
+	}
+
+	assert.Error(t, (&SearchMessagesMessage{}).Decode([]byte{}))
+}
+
+func TestSearchResultsMessage(t *testing.T) {
+	parentID := uint64(10)
+	authorUserID := uint64(42)
+	editedAt := time.UnixMilli(1700000060000)
+
+	msg := &SearchResultsMessage{
+		Query: "hello",
+		Results: []SearchResult{
+			{
+				Message: Message{
+					ID:             11,
+					ChannelID:      1,
+					ParentID:       &parentID,
+					AuthorUserID:   &authorUserID,
+					AuthorNickname: "alice",
+					Content:        "hello again",
+					CreatedAt:      time.UnixMilli(1700000000000),
+					EditedAt:       &editedAt,
+				},
+				ThreadRootID: 10,
+			},
+			{
+				Message: Message{
+					ID:             10,
+					ChannelID:      1,
+					AuthorNickname: "~guest",
+					Content:        "hello",
+					CreatedAt:      time.UnixMilli(1699999000000),
+					ReplyCount:     1,
+				},
+				ThreadRootID: 10,
+			},
+		},
+		Threads: []Message{
+			{
+				ID:             10,
+				ChannelID:      1,
+				AuthorNickname: "~guest",
+				Content:        "hello",
+				CreatedAt:      time.UnixMilli(1699999000000),
+				ReplyCount:     1,
+			},
+		},
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &SearchResultsMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	// Truncated payload
+	assert.Error(t, decoded.Decode(payload[:len(payload)-4]))
+}
+
+func TestAddReactionMessage(t *testing.T) {
+	msg := &AddReactionMessage{MessageID: 42, Emoji: "👍"}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &AddReactionMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	assert.Error(t, (&AddReactionMessage{}).Decode(payload[:4]))
+}
+
+func TestRemoveReactionMessage(t *testing.T) {
+	msg := &RemoveReactionMessage{MessageID: 42, Emoji: "🎉"}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &RemoveReactionMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	assert.Error(t, (&RemoveReactionMessage{}).Decode(payload[:4]))
+}
+
+func TestReactionsUpdatedMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *ReactionsUpdatedMessage
+	}{
+		{
+			name: "with reactions",
+			msg: &ReactionsUpdatedMessage{
+				MessageID: 42,
+				ChannelID: 1,
+				Reactions: []ReactionSummary{
+					{Emoji: "👍", UserIDs: []uint64{1, 2, 3}},
+					{Emoji: "🎉", UserIDs: []uint64{2}},
+				},
+			},
+		},
+		{
+			name: "last reaction removed",
+			msg:  &ReactionsUpdatedMessage{MessageID: 42, ChannelID: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &ReactionsUpdatedMessage{}
+			require.NoError(t, decoded.Decode(payload))
+			assert.Equal(t, tt.msg, decoded)
+		})
+	}
+
+	payload, err := tests[0].msg.Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&ReactionsUpdatedMessage{}).Decode(payload[:len(payload)-4]))
+}
+
+func TestListMentionsMessage(t *testing.T) {
+	beforeID := uint64(500)
+	tests := []struct {
+		name string
+		msg  *ListMentionsMessage
+	}{
+		{"defaults", &ListMentionsMessage{}},
+		{"unread page", &ListMentionsMessage{UnreadOnly: true, BeforeID: &beforeID, Limit: 25}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &ListMentionsMessage{}
+			require.NoError(t, decoded.Decode(payload))
+			assert.Equal(t, tt.msg, decoded)
+		})
+	}
+
+	assert.Error(t, (&ListMentionsMessage{}).Decode([]byte{}))
+}
+
+func TestMarkMentionsReadMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *MarkMentionsReadMessage
+	}{
+		{"all", &MarkMentionsReadMessage{}},
+		{"some", &MarkMentionsReadMessage{MessageIDs: []uint64{10, 20}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &MarkMentionsReadMessage{}
+			require.NoError(t, decoded.Decode(payload))
+			assert.Equal(t, tt.msg, decoded)
+		})
+	}
+
+	payload, err := tests[1].msg.Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&MarkMentionsReadMessage{}).Decode(payload[:len(payload)-4]))
+}
+
+func TestMentionNotificationMessage(t *testing.T) {
+	authorUserID := uint64(7)
+	parentID := uint64(10)
+	msg := &MentionNotificationMessage{
+		Mention: Mention{
+			Message: Message{
+				ID:             11,
+				ChannelID:      1,
+				ParentID:       &parentID,
+				AuthorUserID:   &authorUserID,
+				AuthorNickname: "bob",
+				Content:        "@alice take a look",
+				CreatedAt:      time.UnixMilli(1700000000000),
+			},
+			ThreadRootID: 10,
+		},
+		UnreadCount: 3,
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &MentionNotificationMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	assert.Error(t, decoded.Decode(payload[:len(payload)-2]))
+}
+
+func TestMentionListMessage(t *testing.T) {
+	root := Message{
+		ID:             10,
+		ChannelID:      1,
+		AuthorNickname: "carol",
+		Content:        "thread root",
+		CreatedAt:      time.UnixMilli(1699999000000),
+		ReplyCount:     1,
+	}
+	msg := &MentionListMessage{
+		Mentions: []Mention{
+			{
+				Message: Message{
+					ID:             11,
+					ChannelID:      1,
+					AuthorNickname: "~guest",
+					Content:        "hey @alice",
+					CreatedAt:      time.UnixMilli(1700000000000),
+				},
+				ThreadRootID: 10,
+			},
+			{Message: root, ThreadRootID: 10, Read: true},
+		},
+		Threads:     []Message{root},
+		UnreadCount: 1,
+	}
+
+	payload, err := msg.Encode()
+	require.NoError(t, err)
+
+	decoded := &MentionListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, msg, decoded)
+
+	assert.Error(t, decoded.Decode(payload[:len(payload)-2]))
+}
+
+func TestMessagesExpiredMessage(t *testing.T) {
+	subchannelID := uint64(3)
+	tests := []struct {
+		name string
+		msg  *MessagesExpiredMessage
+	}{
+		{"channel", &MessagesExpiredMessage{ChannelID: 1, MessageIDs: []uint64{10, 11, 12}}},
+		{"subchannel", &MessagesExpiredMessage{ChannelID: 1, SubchannelID: &subchannelID, MessageIDs: []uint64{20}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.Encode()
+			require.NoError(t, err)
+
+			decoded := &MessagesExpiredMessage{}
+			require.NoError(t, decoded.Decode(payload))
+			assert.Equal(t, tt.msg, decoded)
+		})
+	}
+
+	payload, err := tests[0].msg.Encode()
+	require.NoError(t, err)
+	assert.Error(t, (&MessagesExpiredMessage{}).Decode(payload[:len(payload)-4]))
+}
+
+func TestMessageHistoryMessages(t *testing.T) {
+	request := &GetMessageHistoryMessage{MessageID: 42}
+	payload, err := request.Encode()
+	require.NoError(t, err)
+	decodedRequest := &GetMessageHistoryMessage{}
+	require.NoError(t, decodedRequest.Decode(payload))
+	assert.Equal(t, request, decodedRequest)
+
+	history := &MessageHistoryMessage{
+		MessageID: 42,
+		ChannelID: 1,
+		Revisions: []MessageRevision{
+			{Content: "helo", EditorNickname: "alice", EditedAt: time.UnixMilli(1700000000000)},
+			{Content: "hello", EditorNickname: "alice", EditedAt: time.UnixMilli(1700000060000)},
+			{Content: "[removed]", EditorNickname: "admin", EditedAt: time.UnixMilli(1700000120000), AdminEdit: true},
+		},
+	}
+	payload, err = history.Encode()
+	require.NoError(t, err)
+	decoded := &MessageHistoryMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, history, decoded)
+
+	assert.Error(t, (&MessageHistoryMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestAdminActionMessages(t *testing.T) {
+	admin := "alice"
+	action := "ban_user"
+	since := time.UnixMilli(1700000000000)
+	until := time.UnixMilli(1700086400000)
+	beforeID := uint64(120)
+	requests := []*ListAdminActionsMessage{
+		{},
+		{AdminNickname: &admin, ActionType: &action, Since: &since, Until: &until, BeforeID: &beforeID, Limit: 25},
+	}
+	for _, request := range requests {
+		payload, err := request.Encode()
+		require.NoError(t, err)
+		decoded := &ListAdminActionsMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, request, decoded)
+	}
+	assert.Error(t, (&ListAdminActionsMessage{}).Decode([]byte{}))
+
+	targetID := uint64(7)
+	ip := "192.0.2.1"
+	list := &AdminActionListMessage{
+		Entries: []AdminActionEntry{
+			{
+				ID:               119,
+				AdminNickname:    "alice",
+				ActionType:       "ban_user",
+				TargetType:       "user",
+				TargetID:         &targetID,
+				TargetIdentifier: "mallory",
+				Details:          `{"reason":"spam"}`,
+				PerformedAt:      time.UnixMilli(1700000060000),
+				IPAddress:        &ip,
+			},
+			{
+				ID:               118,
+				AdminNickname:    "bob",
+				ActionType:       "ban_ip",
+				TargetType:       "ip",
+				TargetIdentifier: "198.51.100.0/24",
+				PerformedAt:      time.UnixMilli(1700000000000),
+			},
+		},
+		HasMore: true,
+	}
+	payload, err := list.Encode()
+	require.NoError(t, err)
+	decoded := &AdminActionListMessage{}
+	require.NoError(t, decoded.Decode(payload))
+	assert.Equal(t, list, decoded)
+
+	assert.Error(t, (&AdminActionListMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestUserFlagsMessages(t *testing.T) {
+	userID := uint64(7)
+	nickname := "bob"
+	requests := []*SetUserFlagsMessage{
+		{UserID: &userID, Flags: UserFlagAdmin},
+		{Nickname: &nickname, Flags: UserFlagModerator, ModeratorChannels: []uint64{1, 4}},
+		{Nickname: &nickname},
+	}
+	for _, request := range requests {
+		payload, err := request.Encode()
+		require.NoError(t, err)
+		decoded := &SetUserFlagsMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, request, decoded)
+	}
+	assert.Error(t, (&SetUserFlagsMessage{}).Decode([]byte{}))
+
+	responses := []*UserFlagsUpdatedMessage{
+		{Success: true, UserID: 7, Nickname: "bob", Flags: UserFlagModerator, ModeratorChannels: []uint64{1, 4}, Message: "bob is now a moderator"},
+		{Message: "Permission denied: admin access required"},
+	}
+	for _, response := range responses {
+		payload, err := response.Encode()
+		require.NoError(t, err)
+		decoded := &UserFlagsUpdatedMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, response, decoded)
+
+		assert.Error(t, (&UserFlagsUpdatedMessage{}).Decode(payload[:len(payload)-1]))
+	}
+}
+
+func TestLoginLockoutMessages(t *testing.T) {
+	payload, err := (&ListLoginLockoutsMessage{}).Encode()
+	require.NoError(t, err)
+	assert.Empty(t, payload)
+	require.NoError(t, (&ListLoginLockoutsMessage{}).Decode(payload))
+
+	list := &LoginLockoutListMessage{Lockouts: []LoginLockout{
+		{Kind: "user", Identifier: "bob", Failures: 5, LastFailureAt: time.UnixMilli(1700000000000), LockedUntil: time.UnixMilli(1700000060000)},
+		{Kind: "ip", Identifier: "10.0.0.1", Failures: 21, LastFailureAt: time.UnixMilli(1700000001000), LockedUntil: time.UnixMilli(1700000121000)},
+	}}
+	payload, err = list.Encode()
+	require.NoError(t, err)
+	decodedList := &LoginLockoutListMessage{}
+	require.NoError(t, decodedList.Decode(payload))
+	require.Len(t, decodedList.Lockouts, 2)
+	for i, lockout := range list.Lockouts {
+		assert.Equal(t, lockout.Kind, decodedList.Lockouts[i].Kind)
+		assert.Equal(t, lockout.Identifier, decodedList.Lockouts[i].Identifier)
+		assert.Equal(t, lockout.Failures, decodedList.Lockouts[i].Failures)
+		assert.True(t, lockout.LastFailureAt.Equal(decodedList.Lockouts[i].LastFailureAt))
+		assert.True(t, lockout.LockedUntil.Equal(decodedList.Lockouts[i].LockedUntil))
+	}
+	assert.Error(t, (&LoginLockoutListMessage{}).Decode(payload[:len(payload)-1]))
+
+	clearMsg := &ClearLoginLockoutMessage{Kind: "ip", Identifier: "10.0.0.1"}
+	payload, err = clearMsg.Encode()
+	require.NoError(t, err)
+	decodedClear := &ClearLoginLockoutMessage{}
+	require.NoError(t, decodedClear.Decode(payload))
+	assert.Equal(t, clearMsg, decodedClear)
+	assert.Error(t, (&ClearLoginLockoutMessage{}).Decode([]byte{}))
+
+	cleared := &LoginLockoutClearedMessage{Success: true, Message: "Cleared lockout of ip 10.0.0.1"}
+	payload, err = cleared.Encode()
+	require.NoError(t, err)
+	decodedCleared := &LoginLockoutClearedMessage{}
+	require.NoError(t, decodedCleared.Decode(payload))
+	assert.Equal(t, cleared, decodedCleared)
+	assert.Error(t, (&LoginLockoutClearedMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestTwoFactorMessages(t *testing.T) {
+	auth := &AuthTwoFactorMessage{Code: "123456"}
+	payload, err := auth.Encode()
+	require.NoError(t, err)
+	decodedAuth := &AuthTwoFactorMessage{}
+	require.NoError(t, decodedAuth.Decode(payload))
+	assert.Equal(t, auth, decodedAuth)
+	assert.Error(t, (&AuthTwoFactorMessage{}).Decode([]byte{}))
+
+	payload, err = (&Enable2FAMessage{}).Encode()
+	require.NoError(t, err)
+	assert.Empty(t, payload)
+	require.NoError(t, (&Enable2FAMessage{}).Decode(payload))
+
+	setups := []*TwoFactorSetupMessage{
+		{Success: true, Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/SuperChat:alice?secret=JBSWY3DPEHPK3PXP&issuer=SuperChat"},
+		{Message: "Two-factor authentication is already enabled"},
+	}
+	for _, setup := range setups {
+		payload, err := setup.Encode()
+		require.NoError(t, err)
+		decoded := &TwoFactorSetupMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, setup, decoded)
+		assert.Error(t, (&TwoFactorSetupMessage{}).Decode(payload[:len(payload)-1]))
+	}
+
+	confirm := &Confirm2FAMessage{Code: "654321"}
+	payload, err = confirm.Encode()
+	require.NoError(t, err)
+	decodedConfirm := &Confirm2FAMessage{}
+	require.NoError(t, decodedConfirm.Decode(payload))
+	assert.Equal(t, confirm, decodedConfirm)
+	assert.Error(t, (&Confirm2FAMessage{}).Decode([]byte{}))
+
+	enabledMessages := []*TwoFactorEnabledMessage{
+		{Success: true, RecoveryCodes: []string{"abcde-fghij", "klmno-pqrst"}, Message: "Two-factor authentication enabled"},
+		{Message: "Invalid code"},
+	}
+	for _, enabled := range enabledMessages {
+		payload, err := enabled.Encode()
+		require.NoError(t, err)
+		decoded := &TwoFactorEnabledMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, enabled, decoded)
+		assert.Error(t, (&TwoFactorEnabledMessage{}).Decode(payload[:len(payload)-1]))
+	}
+
+	nickname := "bob"
+	disables := []*Disable2FAMessage{
+		{Code: "123456"},
+		{Nickname: &nickname},
+	}
+	for _, disable := range disables {
+		payload, err := disable.Encode()
+		require.NoError(t, err)
+		decoded := &Disable2FAMessage{}
+		require.NoError(t, decoded.Decode(payload))
+		assert.Equal(t, disable, decoded)
+	}
+	assert.Error(t, (&Disable2FAMessage{}).Decode([]byte{}))
+
+	disabled := &TwoFactorDisabledMessage{Success: true, Message: "Two-factor authentication disabled"}
+	payload, err = disabled.Encode()
+	require.NoError(t, err)
+	decodedDisabled := &TwoFactorDisabledMessage{}
+	require.NoError(t, decodedDisabled.Decode(payload))
+	assert.Equal(t, disabled, decodedDisabled)
+	assert.Error(t, (&TwoFactorDisabledMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestProfileMessages(t *testing.T) {
+	set := &SetProfileMessage{DisplayName: "Alice", Pronouns: "she/her", Bio: "Line one\nLine two", Status: "in a meeting"}
+	payload, err := set.Encode()
+	require.NoError(t, err)
+	decodedSet := &SetProfileMessage{}
+	require.NoError(t, decodedSet.Decode(payload))
+	assert.Equal(t, set, decodedSet)
+	assert.Error(t, (&SetProfileMessage{}).Decode(payload[:len(payload)-1]))
+
+	updated := &ProfileUpdatedMessage{Success: true, Message: "Profile updated"}
+	payload, err = updated.Encode()
+	require.NoError(t, err)
+	decodedUpdated := &ProfileUpdatedMessage{}
+	require.NoError(t, decodedUpdated.Decode(payload))
+	assert.Equal(t, updated, decodedUpdated)
+	assert.Error(t, (&ProfileUpdatedMessage{}).Decode(payload[:len(payload)-1]))
+
+	userID := uint64(7)
+	info := &UserInfoMessage{
+		Nickname:     "alice",
+		IsRegistered: true,
+		UserID:       &userID,
+		Online:       true,
+		DisplayName:  "Alice",
+		Pronouns:     "she/her",
+		Bio:          "Hello",
+		Status:       "in a meeting",
+		Away:         true,
+	}
+	payload, err = info.Encode()
+	require.NoError(t, err)
+	decodedInfo := &UserInfoMessage{}
+	require.NoError(t, decodedInfo.Decode(payload))
+	assert.Equal(t, info, decodedInfo)
+	assert.Error(t, (&UserInfoMessage{}).Decode(payload[:len(payload)-1]))
+
+	presence := &ServerPresenceMessage{
+		SessionID:    3,
+		Nickname:     "alice",
+		IsRegistered: true,
+		UserID:       &userID,
+		Online:       true,
+		DisplayName:  "Alice",
+		Status:       "in a meeting",
+		Away:         true,
+	}
+	payload, err = presence.Encode()
+	require.NoError(t, err)
+	decodedPresence := &ServerPresenceMessage{}
+	require.NoError(t, decodedPresence.Decode(payload))
+	assert.Equal(t, presence, decodedPresence)
+
+	// Messages from older servers end before the profile
+	var legacy bytes.Buffer
+	require.NoError(t, WriteString(&legacy, "bob"))
+	require.NoError(t, WriteBool(&legacy, false))
+	require.NoError(t, WriteOptionalUint64(&legacy, nil))
+	require.NoError(t, WriteBool(&legacy, true))
+	decodedInfo = &UserInfoMessage{Status: "stale", Away: true}
+	require.NoError(t, decodedInfo.Decode(legacy.Bytes()))
+	assert.Equal(t, &UserInfoMessage{Nickname: "bob", Online: true}, decodedInfo)
+
+	legacy.Reset()
+	require.NoError(t, WriteUint64(&legacy, 4))
+	require.NoError(t, WriteString(&legacy, "bob"))
+	require.NoError(t, WriteBool(&legacy, false))
+	require.NoError(t, WriteOptionalUint64(&legacy, nil))
+	require.NoError(t, WriteUint8(&legacy, 0))
+	require.NoError(t, WriteBool(&legacy, true))
+	decodedPresence = &ServerPresenceMessage{}
+	require.NoError(t, decodedPresence.Decode(legacy.Bytes()))
+	assert.Equal(t, &ServerPresenceMessage{SessionID: 4, Nickname: "bob", Online: true}, decodedPresence)
+}
+
+func TestTypingMessages(t *testing.T) {
+	subchannelID := uint64(2)
+	threadID := uint64(40)
+
+	start := &TypingStartMessage{ChannelID: 1, SubchannelID: &subchannelID, ThreadID: &threadID}
+	payload, err := start.Encode()
+	require.NoError(t, err)
+	decodedStart := &TypingStartMessage{}
+	require.NoError(t, decodedStart.Decode(payload))
+	assert.Equal(t, start, decodedStart)
+	assert.Error(t, (&TypingStartMessage{}).Decode(payload[:len(payload)-1]))
+
+	stop := &TypingStopMessage{ChannelID: 1}
+	payload, err = stop.Encode()
+	require.NoError(t, err)
+	assert.Len(t, payload, 10)
+	decodedStop := &TypingStopMessage{}
+	require.NoError(t, decodedStop.Decode(payload))
+	assert.Equal(t, stop, decodedStop)
+	assert.Error(t, (&TypingStopMessage{}).Decode(payload[:len(payload)-1]))
+
+	typing := &UserTypingMessage{ChannelID: 1, ThreadID: &threadID, SessionID: 9, Nickname: "alice", Typing: true}
+	payload, err = typing.Encode()
+	require.NoError(t, err)
+	decodedTyping := &UserTypingMessage{}
+	require.NoError(t, decodedTyping.Decode(payload))
+	assert.Equal(t, typing, decodedTyping)
+	assert.Error(t, (&UserTypingMessage{}).Decode(payload[:len(payload)-1]))
+}
+
+func TestMessageReactionsRoundTrip(t *testing.T) {
+	reactions := []ReactionSummary{
+		{Emoji: "👍", UserIDs: []uint64{7, 9}},
+		{Emoji: "❤️", UserIDs: []uint64{9}},
+	}
+	message := Message{
+		ID:             5,
+		ChannelID:      1,
+		AuthorNickname: "alice",
+		Content:        "react to me",
+		CreatedAt:      time.UnixMilli(1700000000000),
+		Reactions:      reactions,
+	}
+
+	list := &MessageListMessage{ChannelID: 1, Messages: []Message{message}}
+	payload, err := list.Encode()
+	require.NoError(t, err)
+	decodedList := &MessageListMessage{}
+	require.NoError(t, decodedList.Decode(payload))
+	require.Len(t, decodedList.Messages, 1)
+	assert.Equal(t, reactions, decodedList.Messages[0].Reactions)
+
+	newMsg := &NewMessageMessage{
+		ID:             message.ID,
+		ChannelID:      message.ChannelID,
+		AuthorNickname: message.AuthorNickname,
+		Content:        message.Content,
+		CreatedAt:      message.CreatedAt,
+		Reactions:      reactions,
+	}
+	payload, err = newMsg.Encode()
+	require.NoError(t, err)
+	decodedNew := &NewMessageMessage{}
+	require.NoError(t, decodedNew.Decode(payload))
+	assert.Equal(t, reactions, decodedNew.Reactions)
+}
+
 func TestNewMessageMessage(t *testing.T) {
 	now := time.Now()
 	editedTime := now.Add(5 * time.Minute)
@@ -1907,7 +2921,39 @@ func TestMessageTypeConstants(t *testing.T) {
 	assert.Equal(t, 0xAD, TypeServerPresence)
 	assert.Equal(t, 0x18, TypeGetUnreadCounts)
 	assert.Equal(t, 0x1D, TypeUpdateReadState)
+	assert.Equal(t, 0x22, TypeSearchMessages)
+	assert.Equal(t, 0x23, TypeAddReaction)
+	assert.Equal(t, 0x24, TypeRemoveReaction)
+	assert.Equal(t, 0x25, TypeListMentions)
+	assert.Equal(t, 0x26, TypeMarkMentionsRead)
 	assert.Equal(t, 0x97, TypeUnreadCounts)
+	assert.Equal(t, 0xB0, TypeSearchResults)
+	assert.Equal(t, 0xB1, TypeReactionsUpdated)
+	assert.Equal(t, 0xB2, TypeMentionNotification)
+	assert.Equal(t, 0xB3, TypeMentionList)
+	assert.Equal(t, 0xB4, TypeMessagesExpired)
+	assert.Equal(t, 0x27, TypeGetMessageHistory)
+	assert.Equal(t, 0xB5, TypeMessageHistory)
+	assert.Equal(t, 0x60, TypeListAdminActions)
+	assert.Equal(t, 0xB6, TypeAdminActionList)
+	assert.Equal(t, 0x61, TypeSetUserFlags)
+	assert.Equal(t, 0xB7, TypeUserFlagsUpdated)
+	assert.Equal(t, 0x62, TypeListLoginLockouts)
+	assert.Equal(t, 0x63, TypeClearLoginLockout)
+	assert.Equal(t, 0xB8, TypeLoginLockoutList)
+	assert.Equal(t, 0xB9, TypeLoginLockoutCleared)
+	assert.Equal(t, 0x64, TypeAuthTwoFactor)
+	assert.Equal(t, 0x65, TypeEnable2FA)
+	assert.Equal(t, 0x66, TypeConfirm2FA)
+	assert.Equal(t, 0x67, TypeDisable2FA)
+	assert.Equal(t, 0xBA, TypeTwoFactorSetup)
+	assert.Equal(t, 0xBB, TypeTwoFactorEnabled)
+	assert.Equal(t, 0xBC, TypeTwoFactorDisabled)
+	assert.Equal(t, 0x68, TypeSetProfile)
+	assert.Equal(t, 0xBD, TypeProfileUpdated)
+	assert.Equal(t, 0x69, TypeTypingStart)
+	assert.Equal(t, 0x6A, TypeTypingStop)
+	assert.Equal(t, 0xBE, TypeUserTyping)
 }
 
 func TestErrorCodeConstants(t *testing.T) {
@@ -0,0 +1,759 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// AdminStore is the subset of database operations used by admin commands. It's
+// implemented by *database.DB (scd admin opening the database directly) and by
+// *database.MemDB (the server answering on its admin socket).
+type AdminStore interface {
+	CreateUser(nickname, passwordHash string, userFlags uint8) (int64, error)
+	GetUserByNickname(nickname string) (*database.User, error)
+	ListAllUsers(limit int) ([]*database.User, error)
+	UpdateUserFlags(userID int64, flags uint8) error
+	UpdateUserPassword(userID int64, newPasswordHash string) error
+	DisableTwoFactor(userID int64) error
+
+	CreateUserBan(userID *int64, nickname *string, reason string, shadowban bool, durationSeconds *uint64, adminNickname, adminIP string) (int64, error)
+	CreateIPBan(ipCIDR string, reason string, durationSeconds *uint64, adminNickname, adminIP string) (int64, error)
+	DeleteUserBan(userID *int64, nickname *string, adminNickname, adminIP string) (int64, error)
+	DeleteIPBan(ipCIDR string, adminNickname, adminIP string) (int64, error)
+	ListBans(includeExpired bool) ([]*database.Ban, error)
+
+	ListChannels() ([]*database.Channel, error)
+	GetChannel(channelID int64) (*database.Channel, error)
+	DeleteChannel(channelID uint64) error
+
+	LogAdminAction(action database.AdminAction) error
+	ListAdminActions(filter database.AdminActionFilter) ([]*database.AdminAction, error)
+}
+
+// AdminRequest is one admin command, as sent over the admin socket
+type AdminRequest struct {
+	Command string `json:"command"` // e.g. "user create", "ban", "channel delete", "log"
+	Admin   string `json:"admin"`   // Recorded as the admin in the audit log
+
+	Nickname string `json:"nickname,omitempty"`
+	Password string `json:"password,omitempty"` // Client-side password hash (see auth.HashPassword)
+	Role     string `json:"role,omitempty"`     // "admin" or "moderator"
+
+	Reason          string  `json:"reason,omitempty"`
+	DurationSeconds *uint64 `json:"duration_seconds,omitempty"` // nil = permanent
+	Shadowban       bool    `json:"shadowban,omitempty"`
+	IP              string  `json:"ip,omitempty"`      // IP or CIDR for ban-ip/unban-ip
+	Channel         string  `json:"channel,omitempty"` // Channel name or ID
+	All             bool    `json:"all,omitempty"`     // Include expired bans
+
+	// Audit log filters
+	FilterAdmin  string `json:"filter_admin,omitempty"`
+	FilterAction string `json:"filter_action,omitempty"`
+	Since        int64  `json:"since,omitempty"` // Unix milliseconds
+	Limit        int    `json:"limit,omitempty"`
+}
+
+// ReadOnly reports whether the request only lists things
+func (r AdminRequest) ReadOnly() bool {
+	switch r.Command {
+	case "user list", "bans", "channel list", "log":
+		return true
+	}
+	return false
+}
+
+// AdminResponse is the reply to an AdminRequest
+type AdminResponse struct {
+	OK     bool   `json:"ok"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ErrAdminSocketUnavailable means no server is listening on the admin socket
+var ErrAdminSocketUnavailable = errors.New("admin socket unavailable")
+
+// adminLocalIP is recorded as the admin's IP for commands run on the server host
+const adminLocalIP = "local"
+
+// AdminCommands runs admin requests against a store
+type AdminCommands struct {
+	store AdminStore
+
+	// Let a running server update connected clients (nil when run directly)
+	onChannelDeleted   func(channel *database.Channel, reason string)
+	onUserFlagsChanged func(userID int64, flags uint8)
+}
+
+// NewAdminCommands creates admin commands operating on store
+func NewAdminCommands(store AdminStore) *AdminCommands {
+	return &AdminCommands{store: store}
+}
+
+// Execute runs a request and returns its output
+func (c *AdminCommands) Execute(req AdminRequest) (string, error) {
+	if req.Admin == "" {
+		req.Admin = "cli"
+	}
+
+	switch req.Command {
+	case "user create":
+		return c.createUser(req)
+	case "user list":
+		return c.listUsers(req)
+	case "user promote":
+		return c.setRole(req, true)
+	case "user demote":
+		return c.setRole(req, false)
+	case "user reset-password":
+		return c.resetPassword(req)
+	case "user disable-2fa":
+		return c.disableTwoFactor(req)
+	case "ban":
+		return c.banUser(req)
+	case "unban":
+		return c.unbanUser(req)
+	case "ban-ip":
+		return c.banIP(req)
+	case "unban-ip":
+		return c.unbanIP(req)
+	case "bans":
+		return c.listBans(req)
+	case "channel list":
+		return c.listChannels()
+	case "channel delete":
+		return c.deleteChannel(req)
+	case "log":
+		return c.listAdminActions(req)
+	default:
+		return "", fmt.Errorf("unknown admin command %q", req.Command)
+	}
+}
+
+func (c *AdminCommands) createUser(req AdminRequest) (string, error) {
+	if !nicknameRegex.MatchString(req.Nickname) {
+		return "", fmt.Errorf("invalid nickname %q: must be 3-20 characters, alphanumeric plus - and _", req.Nickname)
+	}
+	flags, err := roleFlags(req.Role)
+	if err != nil {
+		return "", err
+	}
+	hash, err := hashClientPassword(req.Password)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := c.store.CreateUser(req.Nickname, hash, flags)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return "", fmt.Errorf("nickname %q is already registered", req.Nickname)
+		}
+		return "", err
+	}
+
+	c.logAction(req, "create_user", "user", &userID, req.Nickname, map[string]string{"role": req.Role})
+	return fmt.Sprintf("Created user %s (id %d)", req.Nickname, userID), nil
+}
+
+func (c *AdminCommands) listUsers(req AdminRequest) (string, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	users, err := c.store.ListAllUsers(limit)
+	if err != nil {
+		return "", err
+	}
+
+	return formatTable([]string{"ID", "NICKNAME", "ROLE", "CREATED", "LAST SEEN"}, len(users), func(i int) []string {
+		u := users[i]
+		return []string{
+			strconv.FormatInt(u.ID, 10), u.Nickname, roleName(u.UserFlags),
+			formatAdminTime(u.CreatedAt), formatAdminTime(u.LastSeen),
+		}
+	}), nil
+}
+
+// setRole adds (promote) or removes (demote) a role. Promoting defaults to admin,
+// demoting without a role removes both.
+func (c *AdminCommands) setRole(req AdminRequest, grant bool) (string, error) {
+	user, err := c.lookupUser(req.Nickname)
+	if err != nil {
+		return "", err
+	}
+	role, err := roleFlags(req.Role)
+	if err != nil {
+		return "", err
+	}
+	if role == 0 {
+		role = uint8(protocol.UserFlagAdmin)
+		if !grant {
+			role |= uint8(protocol.UserFlagModerator)
+		}
+	}
+
+	flags := user.UserFlags | role
+	if !grant {
+		flags = user.UserFlags &^ role
+	}
+	if flags == user.UserFlags {
+		return fmt.Sprintf("%s is already %s", user.Nickname, roleName(flags)), nil
+	}
+	if err := c.store.UpdateUserFlags(user.ID, flags); err != nil {
+		return "", err
+	}
+	if c.onUserFlagsChanged != nil {
+		c.onUserFlagsChanged(user.ID, flags)
+	}
+
+	c.logAction(req, "set_user_flags", "user", &user.ID, user.Nickname, map[string]string{
+		"from": roleName(user.UserFlags),
+		"to":   roleName(flags),
+	})
+	return fmt.Sprintf("%s is now %s", user.Nickname, roleName(flags)), nil
+}
+
+func (c *AdminCommands) resetPassword(req AdminRequest) (string, error) {
+	user, err := c.lookupUser(req.Nickname)
+	if err != nil {
+		return "", err
+	}
+	hash, err := hashClientPassword(req.Password)
+	if err != nil {
+		return "", err
+	}
+	if err := c.store.UpdateUserPassword(user.ID, hash); err != nil {
+		return "", err
+	}
+
+	c.logAction(req, "reset_password", "user", &user.ID, user.Nickname, nil)
+	return fmt.Sprintf("Password reset for %s", user.Nickname), nil
+}
+
+// disableTwoFactor turns off 2FA for a user who lost their authenticator and
+// recovery codes
+func (c *AdminCommands) disableTwoFactor(req AdminRequest) (string, error) {
+	user, err := c.lookupUser(req.Nickname)
+	if err != nil {
+		return "", err
+	}
+	if err := c.store.DisableTwoFactor(user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s doesn't use two-factor authentication", user.Nickname)
+		}
+		return "", err
+	}
+
+	c.logAction(req, "disable_2fa", "user", &user.ID, user.Nickname, nil)
+	return fmt.Sprintf("Two-factor authentication disabled for %s", user.Nickname), nil
+}
+
+func (c *AdminCommands) banUser(req AdminRequest) (string, error) {
+	if req.Nickname == "" {
+		return "", errors.New("nickname is required")
+	}
+	// Registered users are banned by ID too, so the ban survives a nickname change
+	var userID *int64
+	if user, err := c.store.GetUserByNickname(req.Nickname); err == nil {
+		userID = &user.ID
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	nickname := req.Nickname
+	banID, err := c.store.CreateUserBan(userID, &nickname, req.Reason, req.Shadowban, req.DurationSeconds, req.Admin, adminLocalIP)
+	if err != nil {
+		return "", err
+	}
+	kind := "Banned"
+	if req.Shadowban {
+		kind = "Shadowbanned"
+	}
+	return fmt.Sprintf("%s %s %s (ban %d)", kind, req.Nickname, formatBanDuration(req.DurationSeconds), banID), nil
+}
+
+func (c *AdminCommands) unbanUser(req AdminRequest) (string, error) {
+	if req.Nickname == "" {
+		return "", errors.New("nickname is required")
+	}
+	var userID *int64
+	if user, err := c.store.GetUserByNickname(req.Nickname); err == nil {
+		userID = &user.ID
+	}
+
+	nickname := req.Nickname
+	removed, err := c.store.DeleteUserBan(userID, &nickname, req.Admin, adminLocalIP)
+	if err != nil {
+		return "", err
+	}
+	if removed == 0 {
+		return "", fmt.Errorf("%s is not banned", req.Nickname)
+	}
+	return fmt.Sprintf("Unbanned %s", req.Nickname), nil
+}
+
+func (c *AdminCommands) banIP(req AdminRequest) (string, error) {
+	if req.IP == "" {
+		return "", errors.New("IP address is required")
+	}
+	banID, err := c.store.CreateIPBan(req.IP, req.Reason, req.DurationSeconds, req.Admin, adminLocalIP)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Banned %s %s (ban %d)", req.IP, formatBanDuration(req.DurationSeconds), banID), nil
+}
+
+func (c *AdminCommands) unbanIP(req AdminRequest) (string, error) {
+	if req.IP == "" {
+		return "", errors.New("IP address is required")
+	}
+	removed, err := c.store.DeleteIPBan(req.IP, req.Admin, adminLocalIP)
+	if err != nil {
+		return "", err
+	}
+	if removed == 0 {
+		return "", fmt.Errorf("%s is not banned", req.IP)
+	}
+	return fmt.Sprintf("Unbanned %s", req.IP), nil
+}
+
+func (c *AdminCommands) listBans(req AdminRequest) (string, error) {
+	bans, err := c.store.ListBans(req.All)
+	if err != nil {
+		return "", err
+	}
+
+	return formatTable([]string{"ID", "TYPE", "TARGET", "REASON", "BY", "BANNED", "UNTIL"}, len(bans), func(i int) []string {
+		ban := bans[i]
+		target := ""
+		switch {
+		case ban.Nickname != nil:
+			target = *ban.Nickname
+		case ban.IPCIDR != nil:
+			target = *ban.IPCIDR
+		case ban.UserID != nil:
+			target = fmt.Sprintf("user %d", *ban.UserID)
+		}
+		banType := ban.BanType
+		if ban.Shadowban {
+			banType += " (shadow)"
+		}
+		if ban.ChannelID != nil {
+			if channel, err := c.store.GetChannel(*ban.ChannelID); err == nil {
+				banType += fmt.Sprintf(" (#%s)", channel.Name)
+			} else {
+				banType += fmt.Sprintf(" (channel %d)", *ban.ChannelID)
+			}
+		}
+		until := "never"
+		if ban.BannedUntil != nil {
+			until = formatAdminTime(*ban.BannedUntil)
+		}
+		return []string{
+			strconv.FormatInt(ban.ID, 10), banType, target, ban.Reason, ban.BannedBy,
+			formatAdminTime(ban.BannedAt), until,
+		}
+	}), nil
+}
+
+func (c *AdminCommands) listChannels() (string, error) {
+	channels, err := c.store.ListChannels()
+	if err != nil {
+		return "", err
+	}
+
+	return formatTable([]string{"ID", "NAME", "TYPE", "RETENTION", "CREATED"}, len(channels), func(i int) []string {
+		ch := channels[i]
+		channelType := "chat"
+		if ch.ChannelType == 1 {
+			channelType = "forum"
+		}
+		if ch.IsPrivate {
+			channelType += ", private"
+		}
+		return []string{
+			strconv.FormatInt(ch.ID, 10), "#" + ch.Name, channelType,
+			fmt.Sprintf("%dh", ch.MessageRetentionHours), formatAdminTime(ch.CreatedAt),
+		}
+	}), nil
+}
+
+func (c *AdminCommands) deleteChannel(req AdminRequest) (string, error) {
+	channel, err := c.lookupChannel(req.Channel)
+	if err != nil {
+		return "", err
+	}
+	if err := c.store.DeleteChannel(uint64(channel.ID)); err != nil {
+		return "", err
+	}
+	if c.onChannelDeleted != nil {
+		c.onChannelDeleted(channel, req.Reason)
+	}
+
+	c.logAction(req, "delete_channel", "channel", &channel.ID, channel.Name, map[string]string{"reason": req.Reason})
+	return fmt.Sprintf("Deleted channel #%s (id %d)", channel.Name, channel.ID), nil
+}
+
+func (c *AdminCommands) listAdminActions(req AdminRequest) (string, error) {
+	actions, err := c.store.ListAdminActions(database.AdminActionFilter{
+		AdminNickname: req.FilterAdmin,
+		ActionType:    req.FilterAction,
+		Since:         req.Since,
+		Limit:         req.Limit,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return formatTable([]string{"ID", "TIME", "ADMIN", "ACTION", "TARGET", "DETAILS"}, len(actions), func(i int) []string {
+		a := actions[i]
+		target := a.TargetIdentifier
+		if target == "" && a.TargetID != nil {
+			target = strconv.FormatInt(*a.TargetID, 10)
+		}
+		if a.TargetType != "" {
+			target = a.TargetType + " " + target
+		}
+		return []string{
+			strconv.FormatInt(a.ID, 10), formatAdminTime(a.PerformedAt), a.AdminNickname, a.ActionType,
+			target, a.Details,
+		}
+	}), nil
+}
+
+// lookupUser finds a registered user by nickname
+func (c *AdminCommands) lookupUser(nickname string) (*database.User, error) {
+	if nickname == "" {
+		return nil, errors.New("nickname is required")
+	}
+	user, err := c.store.GetUserByNickname(nickname)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no registered user %q", nickname)
+	}
+	return user, err
+}
+
+// lookupChannel finds a channel by ID or name (with or without #)
+func (c *AdminCommands) lookupChannel(ref string) (*database.Channel, error) {
+	if ref == "" {
+		return nil, errors.New("channel is required")
+	}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		channel, err := c.store.GetChannel(id)
+		if err != nil {
+			return nil, fmt.Errorf("no channel with id %d", id)
+		}
+		return channel, nil
+	}
+
+	channels, err := c.store.ListChannels()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(ref, "#")
+	for _, channel := range channels {
+		if channel.Name == name {
+			return channel, nil
+		}
+	}
+	return nil, fmt.Errorf("no channel named #%s", name)
+}
+
+// logAction records an action in the audit log. Failures are logged rather than
+// returned, since the action itself already happened.
+func (c *AdminCommands) logAction(req AdminRequest, actionType, targetType string, targetID *int64, target string, details map[string]string) {
+	ip := adminLocalIP
+	if err := c.store.LogAdminAction(database.AdminAction{
+		AdminNickname:    req.Admin,
+		ActionType:       actionType,
+		TargetType:       targetType,
+		TargetID:         targetID,
+		TargetIdentifier: target,
+		Details:          adminDetails(details),
+		IPAddress:        &ip,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
+	}
+}
+
+// adminDetails encodes the non-empty values as the JSON details of an audit entry
+func adminDetails(values map[string]string) string {
+	details := make(map[string]string, len(values))
+	for key, value := range values {
+		if value != "" {
+			details[key] = value
+		}
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// hashClientPassword bcrypts a client-side password hash for storage, like registration does
+func hashClientPassword(clientHash string) (string, error) {
+	if len(clientHash) < 40 || len(clientHash) > 50 {
+		return "", errors.New("invalid password hash format")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(clientHash), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// roleFlags returns the flag for a role name ("" is no role)
+func roleFlags(role string) (uint8, error) {
+	switch role {
+	case "":
+		return 0, nil
+	case "admin":
+		return uint8(protocol.UserFlagAdmin), nil
+	case "moderator":
+		return uint8(protocol.UserFlagModerator), nil
+	default:
+		return 0, fmt.Errorf("unknown role %q (expected admin or moderator)", role)
+	}
+}
+
+// roleName describes user flags
+func roleName(flags uint8) string {
+	f := protocol.UserFlags(flags)
+	switch {
+	case f.IsAdmin() && f.IsModerator():
+		return "admin, moderator"
+	case f.IsAdmin():
+		return "admin"
+	case f.IsModerator():
+		return "moderator"
+	default:
+		return "user"
+	}
+}
+
+func formatBanDuration(seconds *uint64) string {
+	if seconds == nil {
+		return "permanently"
+	}
+	return "for " + (time.Duration(*seconds) * time.Second).String()
+}
+
+func formatAdminTime(millis int64) string {
+	if millis == 0 {
+		return "-"
+	}
+	return time.UnixMilli(millis).Format("2006-01-02 15:04")
+}
+
+// formatTable renders rows as aligned columns
+func formatTable(header []string, rows int, row func(i int) []string) string {
+	if rows == 0 {
+		return "(none)"
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for i := 0; i < rows; i++ {
+		fmt.Fprintln(w, strings.Join(row(i), "\t"))
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// ===== Admin socket =====
+
+// startAdminSocket listens for admin commands on a Unix socket, if configured.
+// Only the user running the server can connect (the socket is mode 0600).
+func (s *Server) startAdminSocket() error {
+	if s.config.AdminSocket == "" {
+		return nil
+	}
+	path, err := expandHomePath(s.config.AdminSocket)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create admin socket directory: %w", err)
+	}
+
+	// A socket left behind by a crash would make Listen fail. Don't remove it if a
+	// server is still answering on it.
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("admin socket %s is in use by another server", path)
+	}
+	os.Remove(path)
+
+	listener, err := listenPrivateUnix(path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket %s: %w", path, err)
+	}
+	s.adminListener = listener
+
+	log.Printf("Admin socket listening on %s", path)
+
+	s.wg.Add(1)
+	go s.acceptAdminLoop(listener)
+
+	return nil
+}
+
+// listenPrivateUnix listens on a unix socket at path that only the current user can
+// connect to. The socket is created in a new directory only we can enter, restricted to
+// 0600 and then moved into place, so it's never reachable with the umask's permissions.
+func listenPrivateUnix(path string) (net.Listener, error) {
+	if runtime.GOOS == "windows" {
+		// Access is governed by the directory's ACL; file modes don't apply
+		return net.Listen("unix", path)
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".admin-sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket file moves, so Close can't remove it by the name it was created with
+	listener.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unlinkListener{Listener: listener, path: path}, nil
+}
+
+// unlinkListener removes its socket file when closed
+type unlinkListener struct {
+	net.Listener
+	path string
+}
+
+func (l *unlinkListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// acceptAdminLoop accepts admin socket connections
+func (s *Server) acceptAdminLoop(listener net.Listener) {
+	defer s.wg.Done()
+
+	commands := &AdminCommands{
+		store:              s.db,
+		onChannelDeleted:   s.notifyChannelDeleted,
+		onUserFlagsChanged: s.updateSessionFlags,
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.shutdown:
+				return
+			default:
+				log.Printf("Admin socket accept error: %v", err)
+				continue
+			}
+		}
+		go handleAdminConn(conn, commands)
+	}
+}
+
+// handleAdminConn answers one JSON request per connection
+func handleAdminConn(conn net.Conn, commands *AdminCommands) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	var req AdminRequest
+	resp := AdminResponse{}
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else if output, err := commands.Execute(req); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.OK, resp.Output = true, output
+	}
+
+	if req.Command != "" {
+		log.Printf("Admin socket: %s ran %q (ok=%v)", req.Admin, req.Command, resp.OK)
+	}
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("Admin socket: failed to send response: %v", err)
+	}
+}
+
+// SendAdminRequest runs a request on a running server through its admin socket
+func SendAdminRequest(socketPath string, req AdminRequest) (string, error) {
+	conn, err := net.DialTimeout("unix", socketPath, 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrAdminSocketUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(60 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	var resp AdminResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return "", errors.New(resp.Error)
+	}
+	return resp.Output, nil
+}
+
+// notifyChannelDeleted tells connected clients that a channel was deleted
+func (s *Server) notifyChannelDeleted(channel *database.Channel, reason string) {
+	resp := &protocol.ChannelDeletedMessage{
+		Success:   true,
+		ChannelID: uint64(channel.ID),
+		Message:   fmt.Sprintf("Channel '%s' deleted by an administrator", channel.Name),
+	}
+	if reason != "" {
+		resp.Message += ": " + reason
+	}
+	if err := s.broadcastToAll(protocol.TypeChannelDeleted, resp); err != nil {
+		log.Printf("Failed to broadcast channel deletion: %v", err)
+	}
+}
+
+// updateSessionFlags applies changed user flags to the user's connected sessions
+func (s *Server) updateSessionFlags(userID int64, flags uint8) {
+	for _, sess := range s.sessionsForUser(userID) {
+		sess.mu.Lock()
+		sess.UserFlags = flags
+		sess.mu.Unlock()
+	}
+}
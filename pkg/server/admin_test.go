@@ -0,0 +1,202 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// clientHash stands in for auth.HashPassword output (43 characters)
+const clientHash = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG"
+
+func newAdminTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.SeedDefaultChannels(); err != nil {
+		t.Fatalf("failed to seed channels: %v", err)
+	}
+	return db
+}
+
+func TestAdminCommands(t *testing.T) {
+	db := newAdminTestDB(t)
+	commands := NewAdminCommands(db)
+
+	run := func(req AdminRequest) string {
+		t.Helper()
+		req.Admin = "cli:test"
+		output, err := commands.Execute(req)
+		if err != nil {
+			t.Fatalf("%s failed: %v", req.Command, err)
+		}
+		return output
+	}
+
+	run(AdminRequest{Command: "user create", Nickname: "alice", Password: clientHash})
+	if _, err := commands.Execute(AdminRequest{Command: "user create", Nickname: "alice", Password: clientHash}); err == nil {
+		t.Error("expected creating a duplicate user to fail")
+	}
+	if _, err := commands.Execute(AdminRequest{Command: "user create", Nickname: "x", Password: clientHash}); err == nil {
+		t.Error("expected an invalid nickname to be rejected")
+	}
+
+	// Promote to moderator, then admin; demoting without a role removes both
+	run(AdminRequest{Command: "user promote", Nickname: "alice", Role: "moderator"})
+	run(AdminRequest{Command: "user promote", Nickname: "alice"})
+	user, _ := db.GetUserByNickname("alice")
+	if want := uint8(protocol.UserFlagAdmin | protocol.UserFlagModerator); user.UserFlags != want {
+		t.Errorf("expected flags %d after promotion, got %d", want, user.UserFlags)
+	}
+	if output := run(AdminRequest{Command: "user list"}); !strings.Contains(output, "admin, moderator") {
+		t.Errorf("expected user list to show roles, got:\n%s", output)
+	}
+	run(AdminRequest{Command: "user demote", Nickname: "alice"})
+	if user, _ := db.GetUserByNickname("alice"); user.UserFlags != 0 {
+		t.Errorf("expected no flags after demotion, got %d", user.UserFlags)
+	}
+
+	// Two-factor authentication can be turned off for users locked out of it
+	if err := db.EnableTwoFactor(user.ID, "JBSWY3DPEHPK3PXP", nil); err != nil {
+		t.Fatalf("EnableTwoFactor failed: %v", err)
+	}
+	run(AdminRequest{Command: "user disable-2fa", Nickname: "alice"})
+	if tf, _ := db.GetTwoFactor(user.ID); tf != nil {
+		t.Error("expected 2FA to be disabled")
+	}
+	if _, err := commands.Execute(AdminRequest{Command: "user disable-2fa", Nickname: "alice"}); err == nil {
+		t.Error("expected disabling 2FA that isn't enabled to fail")
+	}
+
+	// Bans by nickname also record the user ID
+	day := uint64(86400)
+	run(AdminRequest{Command: "ban", Nickname: "alice", Reason: "spam", DurationSeconds: &day})
+	if ban, err := db.GetActiveBanForUser(&user.ID, nil); err != nil || ban == nil {
+		t.Fatalf("expected alice to be banned by ID, got %v, %v", ban, err)
+	}
+	run(AdminRequest{Command: "unban", Nickname: "alice"})
+	if _, err := commands.Execute(AdminRequest{Command: "unban", Nickname: "alice"}); err == nil {
+		t.Error("expected unbanning a user who isn't banned to fail")
+	}
+
+	// Channels can be deleted by name
+	var deleted *database.Channel
+	commands.onChannelDeleted = func(channel *database.Channel, reason string) { deleted = channel }
+	run(AdminRequest{Command: "channel delete", Channel: "#random", Reason: "cleanup"})
+	if deleted == nil || deleted.Name != "random" {
+		t.Errorf("expected deletion of #random to be reported, got %+v", deleted)
+	}
+	if _, err := commands.Execute(AdminRequest{Command: "channel delete", Channel: "random"}); err == nil {
+		t.Error("expected deleting a missing channel to fail")
+	}
+
+	actions, err := db.ListAdminActions(database.AdminActionFilter{ActionType: "delete_channel"})
+	if err != nil || len(actions) != 1 {
+		t.Fatalf("expected one delete_channel entry, got %d (%v)", len(actions), err)
+	}
+	if a := actions[0]; a.AdminNickname != "cli:test" || a.TargetIdentifier != "random" || a.Details != `{"reason":"cleanup"}` {
+		t.Errorf("unexpected audit entry: %+v", a)
+	}
+
+	output := run(AdminRequest{Command: "log", FilterAction: "set_user_flags"})
+	if lines := strings.Split(output, "\n"); len(lines) != 4 { // Header and three changes
+		t.Errorf("expected three set_user_flags entries, got:\n%s", output)
+	}
+}
+
+func TestAdminSocket(t *testing.T) {
+	commands := NewAdminCommands(newAdminTestDB(t))
+
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	if _, err := SendAdminRequest(path, AdminRequest{Command: "user list"}); !errors.Is(err, ErrAdminSocketUnavailable) {
+		t.Fatalf("expected ErrAdminSocketUnavailable without a server, got %v", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleAdminConn(conn, commands)
+		}
+	}()
+
+	output, err := SendAdminRequest(path, AdminRequest{Command: "channel list"})
+	if err != nil || !strings.Contains(output, "#general") {
+		t.Errorf("expected channel list over the socket, got %q, %v", output, err)
+	}
+
+	_, err = SendAdminRequest(path, AdminRequest{Command: "user promote", Nickname: "nobody"})
+	if err == nil || errors.Is(err, ErrAdminSocketUnavailable) || !strings.Contains(err.Error(), "nobody") {
+		t.Errorf("expected the command's error to be returned, got %v", err)
+	}
+}
+
+func TestListenPrivateUnix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "admin.sock")
+
+	listener, err := listenPrivateUnix(path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the socket at %s: %v", path, err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the socket in %s, got %d entries", dir, len(entries))
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("failed to connect to the moved socket: %v", err)
+	}
+	conn.Close()
+
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected Close to remove the socket, got %v", err)
+	}
+}
+
+func TestLockDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	lock, err := LockDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("LockDatabase failed: %v", err)
+	}
+	if _, err := LockDatabase(dbPath); !errors.Is(err, ErrDatabaseInUse) {
+		t.Errorf("expected ErrDatabaseInUse while locked, got %v", err)
+	}
+	if _, err := NewServer(dbPath, DefaultConfig(), ""); err == nil {
+		t.Error("expected a second server on the same database to fail")
+	}
+
+	lock.Close()
+	lock, err = LockDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("expected the lock to be free after Close, got %v", err)
+	}
+	lock.Close()
+}
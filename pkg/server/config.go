@@ -26,8 +26,30 @@ type ServerSection struct {
 	SSHHostKey   string   `toml:"ssh_host_key"`
 	DatabasePath string   `toml:"database_path"`
 	AdminUsers   []string `toml:"admin_users"`
+
+	// TLS for the binary protocol (scs://); tls_port <= 0 disables it
+	TLSPort int    `toml:"tls_port"`
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+
+	// Generate a self-signed certificate when tls_cert and tls_key don't exist (nil = default true)
+	TLSGenerateCert *bool `toml:"tls_generate_cert"`
+
+	// Reverse proxies (IPs or CIDR ranges) allowed to set X-Forwarded-For on /ws
+	TrustedProxies []string `toml:"trusted_proxies"`
+
+	// When the message journal is fsynced: "always", "batch" or "interval"
+	JournalSync           string `toml:"journal_sync"`
+	JournalSyncIntervalMs int    `toml:"journal_sync_interval_ms"`
+
+	// Unix socket for `scd admin` to run commands through the running server
+	// ("" = next to the database, "off" = disabled)
+	AdminSocket string `toml:"admin_socket"`
 }
 
+// AdminSocketDisabled turns off the admin socket when used as admin_socket
+const AdminSocketDisabled = "off"
+
 type LimitsSection struct {
 	MaxConnectionsPerIP     int `toml:"max_connections_per_ip"`
 	MessageRateLimit        int `toml:"message_rate_limit"`
@@ -37,6 +59,19 @@ type LimitsSection struct {
 	SessionTimeoutSeconds   int `toml:"session_timeout_seconds"`
 	MaxThreadSubscriptions  int `toml:"max_thread_subscriptions"`
 	MaxChannelSubscriptions int `toml:"max_channel_subscriptions"`
+
+	// Message history kept in memory (-1 = no limit); the rest is read from SQLite on demand
+	MemoryBudgetMB int `toml:"memory_budget_mb"`
+	HotWindowHours int `toml:"hot_window_hours"`
+
+	// Failed logins before an account or IP address is locked out (-1 = never); the
+	// lockout doubles with every further failure
+	LoginMaxFailures      int `toml:"login_max_failures"`
+	LoginMaxFailuresPerIP int `toml:"login_max_failures_per_ip"`
+	LoginLockoutSeconds   int `toml:"login_lockout_seconds"`
+
+	// Idle time before a user is shown as away (-1 = never)
+	AwayAfterSeconds int `toml:"away_after_seconds"`
 }
 
 type RetentionSection struct {
@@ -70,6 +105,15 @@ func DefaultTOMLConfig() TOMLConfig {
 			HTTPPort:     8080,
 			SSHHostKey:   "~/.superchat/ssh_host_key",
 			DatabasePath: "~/.superchat/superchat.db",
+
+			TLSPort: 6467,
+			TLSCert: "~/.superchat/tls_cert.pem",
+			TLSKey:  "~/.superchat/tls_key.pem",
+
+			TrustedProxies: []string{"127.0.0.1", "::1"},
+
+			JournalSync:           "batch",
+			JournalSyncIntervalMs: 1000,
 		},
 		Limits: LimitsSection{
 			MaxConnectionsPerIP:     10,
@@ -80,6 +124,12 @@ func DefaultTOMLConfig() TOMLConfig {
 			SessionTimeoutSeconds:   120,
 			MaxThreadSubscriptions:  50,
 			MaxChannelSubscriptions: 10,
+			MemoryBudgetMB:          512,
+			HotWindowHours:          72,
+			LoginMaxFailures:        5,
+			LoginMaxFailuresPerIP:   20,
+			LoginLockoutSeconds:     60,
+			AwayAfterSeconds:        600,
 		},
 		Retention: RetentionSection{
 			DefaultRetentionHours:  168, // 7 days
@@ -159,6 +209,22 @@ func applyEnvOverrides(config TOMLConfig) TOMLConfig {
 	if val := os.Getenv("SUPERCHAT_SERVER_SSH_HOST_KEY"); val != "" {
 		config.Server.SSHHostKey = val
 	}
+	if val := os.Getenv("SUPERCHAT_SERVER_TLS_PORT"); val != "" {
+		if port, err := strconv.Atoi(val); err == nil {
+			config.Server.TLSPort = port
+		}
+	}
+	if val := os.Getenv("SUPERCHAT_SERVER_TLS_CERT"); val != "" {
+		config.Server.TLSCert = val
+	}
+	if val := os.Getenv("SUPERCHAT_SERVER_TLS_KEY"); val != "" {
+		config.Server.TLSKey = val
+	}
+	if val := os.Getenv("SUPERCHAT_SERVER_TLS_GENERATE_CERT"); val != "" {
+		if generate, err := strconv.ParseBool(val); err == nil {
+			config.Server.TLSGenerateCert = &generate
+		}
+	}
 	if val := os.Getenv("SUPERCHAT_SERVER_DATABASE_PATH"); val != "" {
 		config.Server.DatabasePath = val
 	}
@@ -171,6 +237,27 @@ func applyEnvOverrides(config TOMLConfig) TOMLConfig {
 		}
 		config.Server.AdminUsers = adminUsers
 	}
+	if val, ok := os.LookupEnv("SUPERCHAT_SERVER_TRUSTED_PROXIES"); ok {
+		// Comma-separated list; an empty value trusts no proxies
+		proxies := []string{}
+		for _, proxy := range strings.Split(val, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				proxies = append(proxies, proxy)
+			}
+		}
+		config.Server.TrustedProxies = proxies
+	}
+	if val, ok := os.LookupEnv("SUPERCHAT_SERVER_ADMIN_SOCKET"); ok {
+		config.Server.AdminSocket = val
+	}
+	if val := os.Getenv("SUPERCHAT_SERVER_JOURNAL_SYNC"); val != "" {
+		config.Server.JournalSync = val
+	}
+	if val := os.Getenv("SUPERCHAT_SERVER_JOURNAL_SYNC_INTERVAL_MS"); val != "" {
+		if interval, err := strconv.Atoi(val); err == nil {
+			config.Server.JournalSyncIntervalMs = interval
+		}
+	}
 
 	// Limits section
 	if val := os.Getenv("SUPERCHAT_LIMITS_MAX_CONNECTIONS_PER_IP"); val != "" {
@@ -213,6 +300,36 @@ func applyEnvOverrides(config TOMLConfig) TOMLConfig {
 			config.Limits.MaxChannelSubscriptions = limit
 		}
 	}
+	if val := os.Getenv("SUPERCHAT_LIMITS_MEMORY_BUDGET_MB"); val != "" {
+		if limit, err := strconv.Atoi(val); err == nil {
+			config.Limits.MemoryBudgetMB = limit
+		}
+	}
+	if val := os.Getenv("SUPERCHAT_LIMITS_HOT_WINDOW_HOURS"); val != "" {
+		if hours, err := strconv.Atoi(val); err == nil {
+			config.Limits.HotWindowHours = hours
+		}
+	}
+	if val := os.Getenv("SUPERCHAT_LIMITS_LOGIN_MAX_FAILURES"); val != "" {
+		if limit, err := strconv.Atoi(val); err == nil {
+			config.Limits.LoginMaxFailures = limit
+		}
+	}
+	if val := os.Getenv("SUPERCHAT_LIMITS_LOGIN_MAX_FAILURES_PER_IP"); val != "" {
+		if limit, err := strconv.Atoi(val); err == nil {
+			config.Limits.LoginMaxFailuresPerIP = limit
+		}
+	}
+	if val := os.Getenv("SUPERCHAT_LIMITS_LOGIN_LOCKOUT_SECONDS"); val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil {
+			config.Limits.LoginLockoutSeconds = seconds
+		}
+	}
+	if val := os.Getenv("SUPERCHAT_LIMITS_AWAY_AFTER_SECONDS"); val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil {
+			config.Limits.AwayAfterSeconds = seconds
+		}
+	}
 
 	// Retention section
 	if val := os.Getenv("SUPERCHAT_RETENTION_DEFAULT_RETENTION_HOURS"); val != "" {
@@ -290,21 +407,52 @@ http_port = 8080
 # Path to SSH host key file
 ssh_host_key = "~/.superchat/ssh_host_key"
 
+# Port for TLS-encrypted binary protocol connections (scs://)
+# Set to -1 to disable
+tls_port = 6467
+
+# TLS certificate and private key (PEM). If neither file exists, a self-signed
+# certificate is generated; clients pin it on first connect.
+tls_cert = "~/.superchat/tls_cert.pem"
+tls_key = "~/.superchat/tls_key.pem"
+
+# Set to false to refuse to start without an existing certificate
+# tls_generate_cert = true
+
 # Path to SQLite database file
 database_path = "~/.superchat/superchat.db"
 
 # List of admin user nicknames (admins can ban users, delete channels, etc.)
+# Users can also be promoted with "scd admin user promote <nickname>".
 # Uncomment and add nicknames to grant admin privileges:
 # admin_users = ["alice", "bob"]
 
+# Reverse proxies (IPs or CIDR ranges) trusted to set X-Forwarded-For on /ws.
+# Connections through them are counted against the client's IP instead of the proxy's.
+# Set to [] to ignore X-Forwarded-For entirely.
+trusted_proxies = ["127.0.0.1", "::1"]
+
+# When new, edited and deleted messages are fsynced to the journal next to the database
+# (replayed after a crash, cleared after each snapshot):
+#   "always"   - fsync every write before it is acknowledged (slowest)
+#   "batch"    - concurrent writes share an fsync, still before they are acknowledged
+#   "interval" - fsync every journal_sync_interval_ms; a crash can lose that much
+journal_sync = "batch"
+# journal_sync_interval_ms = 1000
+
+# Unix socket (mode 0600) on which the running server accepts "scd admin" commands,
+# so they can be used while it runs. Defaults to <database_path>.admin.sock; set to
+# "off" to disable it (scd admin then only lists while the server is running).
+# admin_socket = "/run/superchat/admin.sock"
+
 [limits]
-# Maximum concurrent connections per IP address
+# Maximum concurrent connections per IP address (TCP, SSH and WebSocket combined)
 max_connections_per_ip = 10
 
 # Maximum messages per minute per user
 message_rate_limit = 10
 
-# Maximum channels a user can create per hour
+# Maximum channels a user can create per hour (admins are exempt)
 max_channel_creates = 5
 
 # Maximum message length in bytes
@@ -325,6 +473,25 @@ session_timeout_seconds = 120
 # Uncomment to change from default (10):
 # max_channel_subscriptions = 10
 
+# Approximate memory for cached message history in MB. Least recently used threads
+# beyond this are dropped from memory and read from SQLite when needed (-1 = no limit)
+memory_budget_mb = 512
+
+# Threads without activity for this many hours are dropped from memory, and not loaded
+# at startup (-1 = keep all history in memory)
+hot_window_hours = 72
+
+# Failed logins before an account is locked out, and before an IP address is (-1 = never).
+# The first lockout lasts login_lockout_seconds and doubles with every further failure,
+# up to a day. Admins can lift lockouts from the admin panel.
+login_max_failures = 5
+login_max_failures_per_ip = 20
+login_lockout_seconds = 60
+
+# Idle time before a user is shown as away to others (-1 = never). Typing, reading and
+# switching channels count as activity; the client's keepalive pings don't.
+away_after_seconds = 600
+
 [retention]
 # Default message retention in hours (messages older than this are deleted)
 default_retention_hours = 168  # 7 days
@@ -390,6 +557,30 @@ func (c *TOMLConfig) ToServerConfig() ServerConfig {
 		cfg.SSHHostKeyPath = c.Server.SSHHostKey
 	}
 
+	if c.Server.TLSPort != 0 {
+		cfg.TLSPort = c.Server.TLSPort
+	}
+
+	if strings.TrimSpace(c.Server.TLSCert) != "" {
+		cfg.TLSCertPath = c.Server.TLSCert
+	}
+
+	if strings.TrimSpace(c.Server.TLSKey) != "" {
+		cfg.TLSKeyPath = c.Server.TLSKey
+	}
+
+	if c.Server.TLSGenerateCert != nil {
+		cfg.TLSGenerateCert = *c.Server.TLSGenerateCert
+	}
+
+	if strings.TrimSpace(c.Server.JournalSync) != "" {
+		cfg.JournalSync = c.Server.JournalSync
+	}
+
+	if c.Server.JournalSyncIntervalMs > 0 {
+		cfg.JournalSyncIntervalMs = c.Server.JournalSyncIntervalMs
+	}
+
 	if c.Limits.MaxConnectionsPerIP != 0 {
 		cfg.MaxConnectionsPerIP = uint8(c.Limits.MaxConnectionsPerIP)
 	}
@@ -418,6 +609,30 @@ func (c *TOMLConfig) ToServerConfig() ServerConfig {
 		cfg.MaxChannelSubscriptions = uint16(c.Limits.MaxChannelSubscriptions)
 	}
 
+	if c.Limits.MemoryBudgetMB != 0 {
+		cfg.MemoryBudgetMB = c.Limits.MemoryBudgetMB
+	}
+
+	if c.Limits.HotWindowHours != 0 {
+		cfg.HotWindowHours = c.Limits.HotWindowHours
+	}
+
+	if c.Limits.LoginMaxFailures != 0 {
+		cfg.LoginMaxFailures = c.Limits.LoginMaxFailures
+	}
+
+	if c.Limits.LoginMaxFailuresPerIP != 0 {
+		cfg.LoginMaxFailuresPerIP = c.Limits.LoginMaxFailuresPerIP
+	}
+
+	if c.Limits.LoginLockoutSeconds > 0 {
+		cfg.LoginLockoutSeconds = c.Limits.LoginLockoutSeconds
+	}
+
+	if c.Limits.AwayAfterSeconds != 0 {
+		cfg.AwayAfterSeconds = c.Limits.AwayAfterSeconds
+	}
+
 	// Discovery section
 	// Check if Discovery section exists in config file (vs missing in old configs)
 	// If ServerName and ServerDescription are both empty, the section is likely missing
@@ -451,9 +666,38 @@ func (c *TOMLConfig) ToServerConfig() ServerConfig {
 		cfg.AdminUsers = c.Server.AdminUsers
 	}
 
+	cfg.AdminSocket = c.adminSocket()
+
+	// A missing trusted_proxies keeps the default; an empty list trusts no proxies
+	if c.Server.TrustedProxies != nil {
+		cfg.TrustedProxies = c.Server.TrustedProxies
+	}
+
 	return cfg
 }
 
+// GetAdminSocketPath returns the admin socket path with ~ expanded ("" if disabled)
+func (c *TOMLConfig) GetAdminSocketPath() (string, error) {
+	return expandHomePath(c.adminSocket())
+}
+
+// adminSocket returns the configured admin socket, which by default lives next to the
+// database so that scd admin finds the server using the same database
+func (c *TOMLConfig) adminSocket() string {
+	socket := strings.TrimSpace(c.Server.AdminSocket)
+	switch socket {
+	case AdminSocketDisabled:
+		return ""
+	case "":
+		dbPath := c.Server.DatabasePath
+		if dbPath == "" {
+			dbPath = DefaultTOMLConfig().Server.DatabasePath
+		}
+		return dbPath + ".admin.sock"
+	}
+	return socket
+}
+
 // GetDatabasePath returns the database path with ~ expanded
 func (c *TOMLConfig) GetDatabasePath() (string, error) {
 	path := c.Server.DatabasePath
@@ -235,3 +235,141 @@ func TestAdminUsersEnvVarWithWhitespace(t *testing.T) {
 		}
 	}
 }
+
+func TestTrustedProxiesConfig(t *testing.T) {
+	t.Setenv("SUPERCHAT_SERVER_TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+
+	config := applyEnvOverrides(TOMLConfig{})
+	serverCfg := config.ToServerConfig()
+	if len(serverCfg.TrustedProxies) != 2 || serverCfg.TrustedProxies[1] != "192.168.1.1" {
+		t.Errorf("Expected trusted proxies from env, got %v", serverCfg.TrustedProxies)
+	}
+
+	// An empty value trusts no proxies rather than falling back to the default
+	t.Setenv("SUPERCHAT_SERVER_TRUSTED_PROXIES", "")
+	config = applyEnvOverrides(TOMLConfig{})
+	if serverCfg := config.ToServerConfig(); len(serverCfg.TrustedProxies) != 0 {
+		t.Errorf("Expected no trusted proxies, got %v", serverCfg.TrustedProxies)
+	}
+
+	// A config without trusted_proxies keeps the loopback default
+	if serverCfg := (&TOMLConfig{}).ToServerConfig(); len(serverCfg.TrustedProxies) != 2 {
+		t.Errorf("Expected default trusted proxies, got %v", serverCfg.TrustedProxies)
+	}
+}
+
+func TestAdminSocketConfig(t *testing.T) {
+	// The socket is on by default, next to the database, so scd admin can reach the server using it
+	config := TOMLConfig{Server: ServerSection{DatabasePath: "/srv/superchat/chat.db"}}
+	if socket := config.ToServerConfig().AdminSocket; socket != "/srv/superchat/chat.db.admin.sock" {
+		t.Errorf("Expected the socket next to the database, got %q", socket)
+	}
+	if socket := (&TOMLConfig{}).ToServerConfig().AdminSocket; socket != "~/.superchat/superchat.db.admin.sock" {
+		t.Errorf("Expected the socket next to the default database, got %q", socket)
+	}
+
+	config.Server.AdminSocket = " /run/superchat/admin.sock "
+	if path, err := config.GetAdminSocketPath(); err != nil || path != "/run/superchat/admin.sock" {
+		t.Errorf("Expected the configured socket, got %q, %v", path, err)
+	}
+
+	config.Server.AdminSocket = AdminSocketDisabled
+	if socket := config.ToServerConfig().AdminSocket; socket != "" {
+		t.Errorf("Expected no socket when disabled, got %q", socket)
+	}
+
+	t.Setenv("SUPERCHAT_SERVER_ADMIN_SOCKET", "off")
+	config = applyEnvOverrides(DefaultTOMLConfig())
+	if path, err := config.GetAdminSocketPath(); err != nil || path != "" {
+		t.Errorf("Expected the env to disable the socket, got %q, %v", path, err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	// A config without TLS settings (e.g. written by an older version) gets TLS with a generated certificate
+	serverCfg := (&TOMLConfig{}).ToServerConfig()
+	if serverCfg.TLSPort != 6467 || serverCfg.TLSCertPath == "" || serverCfg.TLSKeyPath == "" || !serverCfg.TLSGenerateCert {
+		t.Errorf("Expected TLS defaults, got port %d cert %q key %q generate %v", serverCfg.TLSPort, serverCfg.TLSCertPath, serverCfg.TLSKeyPath, serverCfg.TLSGenerateCert)
+	}
+
+	t.Setenv("SUPERCHAT_SERVER_TLS_PORT", "-1")
+	t.Setenv("SUPERCHAT_SERVER_TLS_CERT", "/etc/superchat/cert.pem")
+	t.Setenv("SUPERCHAT_SERVER_TLS_KEY", "/etc/superchat/key.pem")
+	t.Setenv("SUPERCHAT_SERVER_TLS_GENERATE_CERT", "false")
+
+	config := applyEnvOverrides(DefaultTOMLConfig())
+	serverCfg = config.ToServerConfig()
+	if serverCfg.TLSPort != -1 {
+		t.Errorf("Expected TLS port -1, got %d", serverCfg.TLSPort)
+	}
+	if serverCfg.TLSCertPath != "/etc/superchat/cert.pem" || serverCfg.TLSKeyPath != "/etc/superchat/key.pem" {
+		t.Errorf("Expected TLS paths from env, got %q and %q", serverCfg.TLSCertPath, serverCfg.TLSKeyPath)
+	}
+	if serverCfg.TLSGenerateCert {
+		t.Error("Expected certificate generation to be disabled")
+	}
+}
+
+func TestJournalConfig(t *testing.T) {
+	if serverCfg := (&TOMLConfig{}).ToServerConfig(); serverCfg.JournalSync != "batch" || serverCfg.JournalSyncIntervalMs != 1000 {
+		t.Errorf("Expected journal defaults, got %q every %dms", serverCfg.JournalSync, serverCfg.JournalSyncIntervalMs)
+	}
+
+	t.Setenv("SUPERCHAT_SERVER_JOURNAL_SYNC", "interval")
+	t.Setenv("SUPERCHAT_SERVER_JOURNAL_SYNC_INTERVAL_MS", "250")
+
+	config := applyEnvOverrides(DefaultTOMLConfig())
+	serverCfg := config.ToServerConfig()
+	if serverCfg.JournalSync != "interval" || serverCfg.JournalSyncIntervalMs != 250 {
+		t.Errorf("Expected journal settings from env, got %q every %dms", serverCfg.JournalSync, serverCfg.JournalSyncIntervalMs)
+	}
+
+	serverCfg.JournalSync = "sometimes"
+	if _, err := NewServer(t.TempDir()+"/test.db", serverCfg, ""); err == nil {
+		t.Error("Expected unknown journal sync policy to be rejected")
+	}
+}
+
+func TestCacheConfig(t *testing.T) {
+	if serverCfg := (&TOMLConfig{}).ToServerConfig(); serverCfg.MemoryBudgetMB != 512 || serverCfg.HotWindowHours != 72 {
+		t.Errorf("Expected cache defaults, got %dMB and %dh", serverCfg.MemoryBudgetMB, serverCfg.HotWindowHours)
+	}
+
+	t.Setenv("SUPERCHAT_LIMITS_MEMORY_BUDGET_MB", "-1")
+	t.Setenv("SUPERCHAT_LIMITS_HOT_WINDOW_HOURS", "24")
+
+	config := applyEnvOverrides(DefaultTOMLConfig())
+	serverCfg := config.ToServerConfig()
+	if serverCfg.MemoryBudgetMB != -1 || serverCfg.HotWindowHours != 24 {
+		t.Errorf("Expected cache settings from env, got %dMB and %dh", serverCfg.MemoryBudgetMB, serverCfg.HotWindowHours)
+	}
+}
+
+func TestLoginLockoutConfig(t *testing.T) {
+	serverCfg := (&TOMLConfig{}).ToServerConfig()
+	if serverCfg.LoginMaxFailures != 5 || serverCfg.LoginMaxFailuresPerIP != 20 || serverCfg.LoginLockoutSeconds != 60 {
+		t.Errorf("Expected lockout defaults, got %d/%d failures and %ds", serverCfg.LoginMaxFailures, serverCfg.LoginMaxFailuresPerIP, serverCfg.LoginLockoutSeconds)
+	}
+
+	t.Setenv("SUPERCHAT_LIMITS_LOGIN_MAX_FAILURES", "-1")
+	t.Setenv("SUPERCHAT_LIMITS_LOGIN_MAX_FAILURES_PER_IP", "50")
+	t.Setenv("SUPERCHAT_LIMITS_LOGIN_LOCKOUT_SECONDS", "300")
+
+	config := applyEnvOverrides(DefaultTOMLConfig())
+	serverCfg = config.ToServerConfig()
+	if serverCfg.LoginMaxFailures != -1 || serverCfg.LoginMaxFailuresPerIP != 50 || serverCfg.LoginLockoutSeconds != 300 {
+		t.Errorf("Expected lockout settings from env, got %d/%d failures and %ds", serverCfg.LoginMaxFailures, serverCfg.LoginMaxFailuresPerIP, serverCfg.LoginLockoutSeconds)
+	}
+}
+
+func TestAwayConfig(t *testing.T) {
+	if serverCfg := (&TOMLConfig{}).ToServerConfig(); serverCfg.AwayAfterSeconds != 600 {
+		t.Errorf("Expected away after 600s by default, got %d", serverCfg.AwayAfterSeconds)
+	}
+
+	t.Setenv("SUPERCHAT_LIMITS_AWAY_AFTER_SECONDS", "-1")
+	config := applyEnvOverrides(DefaultTOMLConfig())
+	if serverCfg := config.ToServerConfig(); serverCfg.AwayAfterSeconds != -1 {
+		t.Errorf("Expected away to be disabled from env, got %d", serverCfg.AwayAfterSeconds)
+	}
+}
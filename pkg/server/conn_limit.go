@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// connectionLimiter counts open connections per IP address across all transports
+type connectionLimiter struct {
+	mu     sync.Mutex
+	max    int
+	counts map[string]int
+}
+
+// newConnectionLimiter creates a limiter allowing max connections per IP (0 = unlimited)
+func newConnectionLimiter(max uint8) *connectionLimiter {
+	return &connectionLimiter{
+		max:    int(max),
+		counts: make(map[string]int),
+	}
+}
+
+// acquire takes a connection slot for an IP, returning false if it has none left.
+// A nil limiter allows everything.
+func (l *connectionLimiter) acquire(ip string) bool {
+	if l == nil || l.max == 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[ip] >= l.max {
+		return false
+	}
+	l.counts[ip]++
+	return true
+}
+
+// release gives back a slot taken with acquire
+func (l *connectionLimiter) release(ip string) {
+	if l == nil || l.max == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[ip] <= 1 {
+		delete(l.counts, ip)
+		return
+	}
+	l.counts[ip]--
+}
+
+// limitedConn releases its connection slot the first time it is closed
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close closes the connection and releases its slot
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// admitConnection takes a connection slot for the remote IP of conn. The returned
+// connection releases the slot when closed. If the IP is over its limit, the client
+// gets ERROR 5003 (where the transport speaks our protocol before any handshake) and
+// the connection is closed.
+func (s *Server) admitConnection(conn net.Conn, transport string) (net.Conn, bool) {
+	ip := remoteIP(conn.RemoteAddr())
+	if !s.connLimiter.acquire(ip) {
+		log.Printf("Rejected %s connection from %s: too many connections (max %d per IP)", transport, ip, s.config.MaxConnectionsPerIP)
+		if transport != "ssh" && transport != "tls" {
+			rejectConnection(conn, s.config.MaxConnectionsPerIP)
+		}
+		conn.Close()
+		return nil, false
+	}
+	return &limitedConn{Conn: conn, release: func() { s.connLimiter.release(ip) }}, true
+}
+
+// rejectConnection tells a client it has too many connections open
+func rejectConnection(conn net.Conn, max uint8) {
+	msg := &protocol.ErrorMessage{
+		ErrorCode: protocol.ErrCodeTooManyConnections,
+		Message:   fmt.Sprintf("Too many connections from your IP address (max %d)", max),
+	}
+	payload, err := msg.Encode()
+	if err != nil {
+		return
+	}
+	protocol.EncodeFrame(conn, &protocol.Frame{
+		Version: protocol.ProtocolVersion,
+		Type:    protocol.TypeError,
+		Payload: payload,
+	})
+}
+
+// remoteIP returns the IP part of a remote address (or the whole address if it has no port)
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// parseTrustedProxies parses IP addresses and CIDR ranges of reverse proxies
+func parseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// isTrustedProxy reports whether ip belongs to one of the trusted proxy ranges
+func isTrustedProxy(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client behind an HTTP request. X-Forwarded-For is
+// only honoured when the request comes from a trusted proxy; the client is then the
+// rightmost address in the chain that isn't a trusted proxy itself, since anything
+// further left can be forged by the client.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		ip = hops[i]
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// addrConn is a pipe connection that reports a fixed remote address
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestConnectionLimiter(t *testing.T) {
+	limiter := newConnectionLimiter(2)
+
+	if !limiter.acquire("10.0.0.1") || !limiter.acquire("10.0.0.1") {
+		t.Fatal("Expected first two connections to be allowed")
+	}
+	if limiter.acquire("10.0.0.1") {
+		t.Fatal("Expected third connection to be rejected")
+	}
+	if !limiter.acquire("10.0.0.2") {
+		t.Fatal("Expected other IPs to be unaffected")
+	}
+
+	limiter.release("10.0.0.1")
+	if !limiter.acquire("10.0.0.1") {
+		t.Fatal("Expected released slot to be reusable")
+	}
+
+	// Zero means unlimited, and a nil limiter allows everything
+	unlimited := newConnectionLimiter(0)
+	for i := 0; i < 100; i++ {
+		if !unlimited.acquire("10.0.0.1") {
+			t.Fatal("Expected unlimited limiter to allow everything")
+		}
+	}
+	var nilLimiter *connectionLimiter
+	if !nilLimiter.acquire("10.0.0.1") {
+		t.Fatal("Expected nil limiter to allow everything")
+	}
+	nilLimiter.release("10.0.0.1")
+}
+
+func TestAdmitConnection(t *testing.T) {
+	initTestLoggers(t)
+	srv := &Server{config: DefaultConfig(), connLimiter: newConnectionLimiter(1)}
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5000}
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	first, ok := srv.admitConnection(&addrConn{Conn: serverSide, addr: addr}, "tcp")
+	if !ok {
+		t.Fatal("Expected first connection to be admitted")
+	}
+
+	// The second connection from the same IP gets ERROR 5003 and is closed
+	rejectedServer, rejectedClient := net.Pipe()
+	defer rejectedClient.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, ok := srv.admitConnection(&addrConn{Conn: rejectedServer, addr: addr}, "tcp"); ok {
+			t.Error("Expected second connection to be rejected")
+		}
+	}()
+
+	frame, err := protocol.DecodeFrame(rejectedClient)
+	if err != nil {
+		t.Fatalf("Failed to read rejection: %v", err)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	if frame.Type != protocol.TypeError || errMsg.Decode(frame.Payload) != nil || errMsg.ErrorCode != protocol.ErrCodeTooManyConnections {
+		t.Fatalf("Expected ERROR %d, got frame type 0x%02X", protocol.ErrCodeTooManyConnections, frame.Type)
+	}
+	<-done
+
+	// Closing the admitted connection (even twice) frees exactly one slot
+	first.Close()
+	first.Close()
+	again, ok := srv.admitConnection(&addrConn{Conn: serverSide, addr: addr}, "tcp")
+	if !ok {
+		t.Fatal("Expected slot to be released on close")
+	}
+	again.Close()
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct connection", "198.51.100.7:1234", nil, "198.51.100.7"},
+		{"untrusted peer can't forge", "198.51.100.7:1234", []string{"203.0.113.9"}, "198.51.100.7"},
+		{"trusted proxy", "127.0.0.1:1234", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"chain of proxies", "127.0.0.1:1234", []string{"203.0.113.9, 10.1.2.3"}, "203.0.113.9"},
+		{"spoofed entries left of the client are ignored", "127.0.0.1:1234", []string{"1.1.1.1, 203.0.113.9"}, "203.0.113.9"},
+		{"multiple headers", "127.0.0.1:1234", []string{"203.0.113.9", "10.1.2.3"}, "203.0.113.9"},
+		{"trusted proxy without header", "127.0.0.1:1234", nil, "127.0.0.1"},
+		{"garbage header", "127.0.0.1:1234", []string{"not-an-ip"}, "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := clientIP(r, trusted); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := parseTrustedProxies([]string{"::1", " 192.168.0.0/16 ", ""})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies failed: %v", err)
+	}
+	if len(nets) != 2 {
+		t.Fatalf("Expected 2 ranges, got %d", len(nets))
+	}
+	if !isTrustedProxy("::1", nets) || !isTrustedProxy("192.168.4.2", nets) || isTrustedProxy("192.169.0.1", nets) {
+		t.Error("Unexpected trusted proxy matching")
+	}
+
+	if _, err := parseTrustedProxies([]string{"example.com"}); err == nil {
+		t.Error("Expected error for invalid entry")
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrDatabaseInUse means a running server holds the database lock
+var ErrDatabaseInUse = errors.New("database is in use by a running server")
+
+// DatabaseLock is held by the server for as long as it has a database open, so scd admin
+// doesn't change the database underneath the server's in-memory cache
+type DatabaseLock struct {
+	file *os.File
+}
+
+// LockDatabase takes the lock of the database at dbPath, returning ErrDatabaseInUse if
+// another process holds it. The operating system releases the lock when the process
+// exits, so a crashed server doesn't leave it behind.
+func LockDatabase(dbPath string) (*DatabaseLock, error) {
+	file, err := os.OpenFile(dbPath+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database lock: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &DatabaseLock{file: file}, nil
+}
+
+// Close releases the lock
+func (l *DatabaseLock) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
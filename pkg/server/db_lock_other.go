@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package server
+
+import "os"
+
+// lockFile is a no-op on systems without flock; scd admin can't tell whether a server runs
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -2,15 +2,19 @@ package server
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/ssh"
@@ -19,8 +23,28 @@ import (
 	"github.com/aeolun/superchat/pkg/protocol"
 )
 
+// channelCreateWindow is the period max_channel_creates applies to
+const channelCreateWindow = time.Hour
+
+// SEARCH_MESSAGES limits
+const (
+	maxSearchQueryLength = 256
+	defaultSearchLimit   = 50
+	maxSearchLimit       = 100
+)
+
+// maxReactionLength is the maximum size of a reaction in bytes (room for multi-codepoint emoji)
+const maxReactionLength = 32
+
+const (
+	maxMentionsPerMessage = 10
+	defaultMentionLimit   = 50
+	maxMentionLimit       = 100
+)
+
 var (
 	nicknameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,20}$`)
+	mentionRegex  = regexp.MustCompile(`@([a-zA-Z0-9_-]{3,20})`)
 
 	// ErrClientDisconnecting is returned when client sends graceful disconnect
 	ErrClientDisconnecting = errors.New("client disconnecting")
@@ -45,6 +69,9 @@ func (s *Server) buildServerPresenceMessage(sess *Session, online bool) *protoco
 	nickname := sess.Nickname
 	userID := sess.UserID
 	userFlags := sess.UserFlags
+	displayName := sess.displayName
+	status := sess.status
+	away := sess.away
 	sess.mu.RUnlock()
 
 	if nickname == "" {
@@ -57,6 +84,9 @@ func (s *Server) buildServerPresenceMessage(sess *Session, online bool) *protoco
 		IsRegistered: userID != nil,
 		UserFlags:    protocol.UserFlags(userFlags),
 		Online:       online,
+		DisplayName:  displayName,
+		Status:       status,
+		Away:         away,
 	}
 
 	if userID != nil {
@@ -153,18 +183,33 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 
 	// Get user from database
 	user, err := s.db.GetUserByNickname(msg.Nickname)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("Session %d: AUTH_REQUEST failed - nickname %s not registered", sess.ID, msg.Nickname)
-			resp := &protocol.AuthResponseMessage{
-				Success: false,
-				Message: "Invalid credentials",
-			}
-			return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
-		}
+	if err != nil && err != sql.ErrNoRows {
 		return s.dbError(sess, "GetUserByNickname", err)
 	}
 
+	// Failures are counted per nickname whether or not it is registered, so lockouts
+	// don't reveal which nicknames exist
+	ip, _, _ := net.SplitHostPort(sess.RemoteAddr)
+	now := time.Now()
+	if wait := s.loginLockedFor(msg.Nickname, ip, now); wait > 0 {
+		log.Printf("Session %d: AUTH_REQUEST rejected - %s is locked out for %s", sess.ID, msg.Nickname, wait)
+		resp := &protocol.AuthResponseMessage{
+			Success: false,
+			Message: fmt.Sprintf("Too many failed login attempts. Try again in %s.", formatLockout(wait)),
+		}
+		return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
+	}
+
+	if user == nil {
+		log.Printf("Session %d: AUTH_REQUEST failed - nickname %s not registered", sess.ID, msg.Nickname)
+		s.recordLoginFailure(msg.Nickname, ip, now)
+		resp := &protocol.AuthResponseMessage{
+			Success: false,
+			Message: "Invalid credentials",
+		}
+		return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
+	}
+
 	// Check if user has removed password (SSH-only authentication)
 	if user.PasswordHash == "" {
 		log.Printf("Session %d: AUTH_REQUEST failed - user %s requires SSH authentication", sess.ID, msg.Nickname)
@@ -182,6 +227,7 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(msg.Password))
 	if err != nil {
 		log.Printf("Session %d: AUTH_REQUEST failed - password verification failed for user %s (client_hash_len=%d)", sess.ID, msg.Nickname, len(msg.Password))
+		s.recordLoginFailure(msg.Nickname, ip, now)
 		resp := &protocol.AuthResponseMessage{
 			Success: false,
 			Message: "Invalid credentials",
@@ -189,6 +235,108 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 		return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
 	}
 
+	// Accounts with two-factor authentication need a code before they are logged in
+	tf, err := s.db.GetTwoFactor(user.ID)
+	if err != nil {
+		return s.dbError(sess, "GetTwoFactor", err)
+	}
+	if tf != nil {
+		log.Printf("Session %d: AUTH_REQUEST for user %s waiting for a two-factor code", sess.ID, user.Nickname)
+		sess.mu.Lock()
+		sess.pendingTwoFactor = &pendingTwoFactor{
+			userID:   user.ID,
+			nickname: user.Nickname,
+			expires:  now.Add(twoFactorLoginTimeout),
+		}
+		sess.mu.Unlock()
+		resp := &protocol.AuthResponseMessage{
+			Success:           false,
+			Message:           "Two-factor authentication code required",
+			TwoFactorRequired: true,
+		}
+		return s.sendMessage(sess, protocol.TypeAuthResponse, resp)
+	}
+
+	return s.completeLogin(sess, user, fmt.Sprintf("Welcome back, %s!", user.Nickname))
+}
+
+// handleAuthTwoFactor handles AUTH_TWO_FACTOR message (the code after the password)
+func (s *Server) handleAuthTwoFactor(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.AuthTwoFactorMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	fail := func(message string, retry bool) error {
+		if !retry {
+			sess.mu.Lock()
+			sess.pendingTwoFactor = nil
+			sess.mu.Unlock()
+		}
+		return s.sendMessage(sess, protocol.TypeAuthResponse, &protocol.AuthResponseMessage{
+			Success:           false,
+			Message:           message,
+			TwoFactorRequired: retry,
+		})
+	}
+
+	sess.mu.RLock()
+	pending := sess.pendingTwoFactor
+	sess.mu.RUnlock()
+	now := time.Now()
+	if pending == nil || now.After(pending.expires) {
+		return fail("No login is waiting for a two-factor code. Enter your password again.", false)
+	}
+
+	// Wrong codes count as failed logins, so guessing codes locks the account too
+	ip, _, _ := net.SplitHostPort(sess.RemoteAddr)
+	if wait := s.loginLockedFor(pending.nickname, ip, now); wait > 0 {
+		log.Printf("Session %d: AUTH_TWO_FACTOR rejected - %s is locked out for %s", sess.ID, pending.nickname, wait)
+		return fail(fmt.Sprintf("Too many failed login attempts. Try again in %s.", formatLockout(wait)), false)
+	}
+
+	user, err := s.db.GetUserByID(pending.userID)
+	if err != nil {
+		// Deleted since the password was checked
+		return fail("Invalid credentials", false)
+	}
+	tf, err := s.db.GetTwoFactor(user.ID)
+	if err != nil {
+		return s.dbError(sess, "GetTwoFactor", err)
+	}
+
+	// tf is nil if an admin turned 2FA off since the password was checked
+	welcome := fmt.Sprintf("Welcome back, %s!", user.Nickname)
+	if tf != nil {
+		ok, recovery, err := s.checkTwoFactorCode(tf, msg.Code, now)
+		if err != nil {
+			return s.dbError(sess, "checkTwoFactorCode", err)
+		}
+		if !ok {
+			log.Printf("Session %d: AUTH_TWO_FACTOR failed - wrong code for user %s", sess.ID, user.Nickname)
+			s.recordLoginFailure(user.Nickname, ip, now)
+			return fail("Invalid two-factor code", true)
+		}
+		if recovery {
+			left, err := s.db.CountRecoveryCodes(user.ID)
+			if err != nil {
+				log.Printf("Session %d: failed to count recovery codes: %v", sess.ID, err)
+			}
+			log.Printf("Session %d: user %s logged in with a recovery code (%d left)", sess.ID, user.Nickname, left)
+			welcome += fmt.Sprintf(" You used a recovery code; %d left.", left)
+		}
+	}
+
+	sess.mu.Lock()
+	sess.pendingTwoFactor = nil
+	sess.mu.Unlock()
+	return s.completeLogin(sess, user, welcome)
+}
+
+// completeLogin logs a session in as a user whose credentials were checked
+func (s *Server) completeLogin(sess *Session, user *database.User, welcome string) error {
+	s.clearLoginFailures(user.Nickname)
+
 	// Check if user is banned
 	ban, err := s.db.GetActiveBanForUser(&user.ID, &user.Nickname)
 	if err != nil {
@@ -223,6 +371,7 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 	sess.UserFlags = user.UserFlags
 	sess.Shadowbanned = ban != nil && ban.Shadowban // Mark session as shadowbanned
 	sess.mu.Unlock()
+	s.loadSessionProfile(sess, user.ID)
 
 	// Update database session
 	if err := s.db.UpdateSessionUserID(sess.DBSessionID, user.ID); err != nil {
@@ -235,13 +384,13 @@ func (s *Server) handleAuthRequest(sess *Session, frame *protocol.Frame) error {
 	}
 
 	// Send success response
-	log.Printf("Session %d: AUTH_REQUEST succeeded for user %s (id=%d)", sess.ID, user.Nickname, user.ID)
+	log.Printf("Session %d: login succeeded for user %s (id=%d)", sess.ID, user.Nickname, user.ID)
 	flags := protocol.UserFlags(user.UserFlags)
 	resp := &protocol.AuthResponseMessage{
 		Success:   true,
 		UserID:    uint64(user.ID),
 		Nickname:  user.Nickname,
-		Message:   fmt.Sprintf("Welcome back, %s!", user.Nickname),
+		Message:   welcome,
 		UserFlags: &flags,
 	}
 	if err := s.sendMessage(sess, protocol.TypeAuthResponse, resp); err != nil {
@@ -340,10 +489,15 @@ func (s *Server) handleLogout(sess *Session, frame *protocol.Frame) error {
 	sess.mu.Lock()
 	oldUserID := sess.UserID
 	sess.UserID = nil
+	sess.EncryptionKeyID = nil
+	sess.displayName = ""
+	sess.status = ""
 	sess.mu.Unlock()
 
 	if oldUserID != nil {
 		log.Printf("Session %d: Logged out (was user_id=%d), now anonymous with nickname %s", sess.ID, *oldUserID, sess.Nickname)
+		// Others shouldn't see the profile of an anonymous session
+		s.notifyServerPresence(sess, true)
 	} else {
 		log.Printf("Session %d: LOGOUT received but already anonymous", sess.ID)
 	}
@@ -506,6 +660,31 @@ func (s *Server) handleJoinChannel(sess *Session, frame *protocol.Frame) error {
 		return s.sendMessage(sess, protocol.TypeJoinResponse, resp)
 	}
 
+	// Private (DM) channels are only joinable by their participants
+	if !s.canAccessChannel(sess, int64(msg.ChannelID)) {
+		resp := &protocol.JoinResponseMessage{
+			Success:      false,
+			ChannelID:    msg.ChannelID,
+			SubchannelID: msg.SubchannelID,
+			Message:      "Channel is private",
+		}
+		return s.sendMessage(sess, protocol.TypeJoinResponse, resp)
+	}
+
+	// Subchannel (if given) must belong to this channel
+	if msg.SubchannelID != nil {
+		sub, err := s.db.GetSubchannel(int64(*msg.SubchannelID))
+		if err != nil || uint64(sub.ChannelID) != msg.ChannelID {
+			resp := &protocol.JoinResponseMessage{
+				Success:      false,
+				ChannelID:    msg.ChannelID,
+				SubchannelID: msg.SubchannelID,
+				Message:      "Subchannel not found",
+			}
+			return s.sendMessage(sess, protocol.TypeJoinResponse, resp)
+		}
+	}
+
 	sess.mu.RLock()
 	previousJoined := sess.JoinedChannel
 	sess.mu.RUnlock()
@@ -645,9 +824,25 @@ func (s *Server) handleCreateChannel(sess *Session, frame *protocol.Frame) error
 		})
 	}
 
+	// Enforce the hourly channel creation quota (admins are exempt)
+	createdAt := time.Now()
+	if !s.isAdmin(sess) {
+		allowed, retryAfter := s.reserveChannelCreate(*userID, createdAt)
+		if !allowed {
+			return s.sendMessage(sess, protocol.TypeChannelCreated, &protocol.ChannelCreatedMessage{
+				Success: false,
+				Message: fmt.Sprintf("Channel creation limit reached (%d per hour). Try again in %d min.",
+					s.config.MaxChannelCreates, int(math.Ceil(retryAfter.Minutes()))),
+			})
+		}
+	}
+
 	// Create channel in database
 	channelID, err := s.db.CreateChannel(msg.Name, msg.DisplayName, msg.Description, msg.ChannelType, msg.RetentionHours, userID)
 	if err != nil {
+		// Failed creations don't count against the quota
+		s.releaseChannelCreate(*userID, createdAt)
+
 		// Check if it's a duplicate name error
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") || strings.Contains(err.Error(), "already exists") {
 			return s.sendMessage(sess, protocol.TypeChannelCreated, &protocol.ChannelCreatedMessage{
@@ -694,6 +889,175 @@ func (s *Server) handleCreateChannel(sess *Session, frame *protocol.Frame) error
 	return nil
 }
 
+// handleGetSubchannels handles GET_SUBCHANNELS message (V3+)
+func (s *Server) handleGetSubchannels(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.GetSubchannelsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	exists, err := s.db.ChannelExists(int64(msg.ChannelID))
+	if err != nil || !exists {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel does not exist")
+	}
+
+	if !s.canAccessChannel(sess, int64(msg.ChannelID)) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
+	dbSubchannels, err := s.db.ListSubchannels(int64(msg.ChannelID))
+	if err != nil {
+		return s.dbError(sess, "ListSubchannels", err)
+	}
+
+	subchannels := make([]protocol.Subchannel, 0, len(dbSubchannels))
+	for _, dbSub := range dbSubchannels {
+		subchannels = append(subchannels, protocol.Subchannel{
+			ID:             uint64(dbSub.ID),
+			Name:           dbSub.Name,
+			Description:    safeDeref(dbSub.Description, ""),
+			Type:           dbSub.SubchannelType,
+			RetentionHours: dbSub.MessageRetentionHours,
+		})
+	}
+
+	resp := &protocol.SubchannelListMessage{
+		ChannelID:   msg.ChannelID,
+		Subchannels: subchannels,
+	}
+	return s.sendMessage(sess, protocol.TypeSubchannelList, resp)
+}
+
+// handleCreateSubchannel handles CREATE_SUBCHANNEL message (V3+)
+func (s *Server) handleCreateSubchannel(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.CreateSubchannelMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Invalid request format",
+		})
+	}
+
+	// Only registered users can create subchannels
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Only registered users can create subchannels. Please register or log in.",
+		})
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Channel not found",
+		})
+	}
+
+	// DM conversations stay flat (SUBCHANNEL_CREATED is broadcast to everyone)
+	if channel.IsPrivate {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Direct messages cannot have subchannels",
+		})
+	}
+
+	// Only the channel's creator (or an admin) can add subchannels to it
+	isOwner := channel.CreatedBy != nil && *channel.CreatedBy == *userID
+	if !isOwner && !s.isAdmin(sess) {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Only the channel creator can add subchannels",
+		})
+	}
+
+	// Validate subchannel name (must be URL-friendly)
+	if len(msg.Name) < 3 || len(msg.Name) > 50 {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Subchannel name must be 3-50 characters",
+		})
+	}
+
+	// Validate display name
+	if len(msg.DisplayName) < 1 || len(msg.DisplayName) > 100 {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Display name must be 1-100 characters",
+		})
+	}
+
+	// Validate description (optional, max 500 chars)
+	if msg.Description != nil && len(*msg.Description) > 500 {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Description must be at most 500 characters",
+		})
+	}
+
+	// Validate subchannel type (0=chat, 1=forum)
+	if msg.SubchannelType != 0 && msg.SubchannelType != 1 {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Invalid subchannel type (must be 0=chat or 1=forum)",
+		})
+	}
+
+	// Validate retention hours (1 hour to 1 year)
+	if msg.RetentionHours < 1 || msg.RetentionHours > 8760 {
+		return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+			Success: false,
+			Message: "Retention hours must be between 1 and 8760 (1 year)",
+		})
+	}
+
+	// Create subchannel in database
+	subchannelID, err := s.db.CreateSubchannel(channel.ID, msg.Name, msg.DisplayName, msg.Description, msg.SubchannelType, msg.RetentionHours, userID)
+	if err != nil {
+		// Names are unique per channel
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return s.sendMessage(sess, protocol.TypeSubchannelCreated, &protocol.SubchannelCreatedMessage{
+				Success: false,
+				Message: "Subchannel name already exists in this channel",
+			})
+		}
+		return s.dbError(sess, "CreateSubchannel", err)
+	}
+
+	// Send to creator as confirmation
+	resp := &protocol.SubchannelCreatedMessage{
+		Success:        true,
+		ChannelID:      msg.ChannelID,
+		SubchannelID:   uint64(subchannelID),
+		Name:           msg.Name,
+		Description:    safeDeref(msg.Description, ""),
+		Type:           msg.SubchannelType,
+		RetentionHours: msg.RetentionHours,
+		Message:        fmt.Sprintf("Subchannel '%s' created successfully", msg.DisplayName),
+	}
+	if err := s.sendMessage(sess, protocol.TypeSubchannelCreated, resp); err != nil {
+		return err
+	}
+
+	// Broadcast to all OTHER connected users (not the creator again)
+	s.broadcastSubchannelCreated(&database.Subchannel{
+		ID:                    subchannelID,
+		ChannelID:             channel.ID,
+		Name:                  msg.Name,
+		DisplayName:           msg.DisplayName,
+		Description:           msg.Description,
+		SubchannelType:        msg.SubchannelType,
+		MessageRetentionHours: msg.RetentionHours,
+		CreatedBy:             userID,
+	}, sess.ID)
+
+	return nil
+}
+
 // handleListMessages handles LIST_MESSAGES message
 func (s *Server) handleListMessages(sess *Session, frame *protocol.Frame) error {
 	// Decode message
@@ -702,6 +1066,17 @@ func (s *Server) handleListMessages(sess *Session, frame *protocol.Frame) error
 		return s.sendError(sess, 1000, "Invalid message format")
 	}
 
+	// Private (DM) channels are only readable by their participants
+	channelID := int64(msg.ChannelID)
+	if msg.ParentID != nil {
+		if parent, err := s.db.GetMessage(int64(*msg.ParentID)); err == nil {
+			channelID = parent.ChannelID
+		}
+	}
+	if !s.canAccessChannel(sess, channelID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
 	var messages []protocol.Message
 
 	if msg.ParentID != nil {
@@ -734,7 +1109,123 @@ func (s *Server) handleListMessages(sess *Session, frame *protocol.Frame) error
 		Messages:     messages,
 	}
 
-	return s.sendMessage(sess, protocol.TypeMessageList, resp)
+	return s.sendMessageWithFlags(sess, protocol.TypeMessageList, s.contentFlags(channelID, protocol.TypeMessageList), resp)
+}
+
+// handleSearchMessages handles SEARCH_MESSAGES message
+func (s *Server) handleSearchMessages(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.SearchMessagesMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, 1000, "Invalid message format")
+	}
+
+	query := strings.TrimSpace(msg.Query)
+	if query == "" {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Search query is required")
+	}
+	if len(query) > maxSearchQueryLength {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, fmt.Sprintf("Search query too long (max %d bytes)", maxSearchQueryLength))
+	}
+
+	limit := int(msg.Limit)
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	filter := database.MessageSearchFilter{ViewerUserID: userID}
+
+	if msg.ChannelID != nil {
+		channel, err := s.db.GetChannel(int64(*msg.ChannelID))
+		if err != nil {
+			return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel not found")
+		}
+		if !s.canAccessChannel(sess, channel.ID) {
+			return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+		}
+		if channel.IsEncrypted {
+			return s.sendError(sess, protocol.ErrCodeInvalidInput, "Encrypted conversations can't be searched")
+		}
+		filter.ChannelID = &channel.ID
+	}
+
+	if msg.ThreadID != nil {
+		root, err := s.db.GetMessage(int64(*msg.ThreadID))
+		if err != nil || !s.canAccessChannel(sess, root.ChannelID) {
+			return s.sendError(sess, protocol.ErrCodeThreadNotFound, "Thread not found")
+		}
+		threadRootID := root.ID
+		if root.ThreadRootID != nil {
+			threadRootID = *root.ThreadRootID
+		}
+		filter.ThreadRootID = &threadRootID
+	}
+
+	if msg.Author != nil {
+		// Accept the nickname as displayed: "~" marks anonymous users, "$"/"@" are role prefixes
+		author := strings.TrimLeft(*msg.Author, "$@")
+		if strings.HasPrefix(author, "~") {
+			nickname := strings.TrimPrefix(author, "~")
+			filter.AuthorNickname = &nickname
+		} else if user, err := s.db.GetUserByNickname(author); err == nil {
+			filter.AuthorUserID = &user.ID
+		} else if err == sql.ErrNoRows {
+			filter.AuthorNickname = &author
+		} else {
+			return s.dbError(sess, "GetUserByNickname", err)
+		}
+	}
+
+	if msg.After != nil {
+		after := msg.After.UnixMilli()
+		filter.After = &after
+	}
+	if msg.Before != nil {
+		before := msg.Before.UnixMilli()
+		filter.Before = &before
+	}
+
+	dbMessages, err := s.db.SearchMessages(query, filter, limit)
+	if err != nil {
+		return s.dbError(sess, "SearchMessages", err)
+	}
+
+	results := make([]protocol.SearchResult, len(dbMessages))
+	threads := []protocol.Message{}
+	seenThreads := make(map[int64]bool)
+	for i, dbMsg := range dbMessages {
+		threadRootID := dbMsg.ID
+		if dbMsg.ThreadRootID != nil {
+			threadRootID = *dbMsg.ThreadRootID
+		}
+		results[i] = protocol.SearchResult{
+			Message:      *convertDBMessageToProtocol(dbMsg, s.db),
+			ThreadRootID: uint64(threadRootID),
+		}
+
+		// Include the thread root of reply matches so the client can open the thread directly
+		if threadRootID == dbMsg.ID || seenThreads[threadRootID] {
+			continue
+		}
+		seenThreads[threadRootID] = true
+		if root, err := s.db.GetMessage(threadRootID); err == nil {
+			threads = append(threads, *convertDBMessageToProtocol(root, s.db))
+		}
+	}
+
+	debugLog.Printf("Session %d: SEARCH_MESSAGES %q returned %d results", sess.ID, query, len(results))
+
+	return s.sendMessage(sess, protocol.TypeSearchResults, &protocol.SearchResultsMessage{
+		Query:   msg.Query,
+		Results: results,
+		Threads: threads,
+	})
 }
 
 // handlePostMessage handles POST_MESSAGE message
@@ -755,8 +1246,12 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 		return s.sendError(sess, 2000, "Nickname required. Use SET_NICKNAME first.")
 	}
 
-	// Validate message length
-	if uint32(len(msg.Content)) > s.config.MaxMessageLength {
+	if ok, retryAfter := s.allowMessage(sess); !ok {
+		return s.sendRateLimitError(sess, retryAfter)
+	}
+
+	// Validate message length (of the plaintext, for encrypted content)
+	if uint32(contentLength(frame.Flags, msg.Content)) > s.config.MaxMessageLength {
 		return s.sendError(sess, 6001, fmt.Sprintf("Message too long (max %d bytes)", s.config.MaxMessageLength))
 	}
 
@@ -776,17 +1271,39 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 	if err != nil {
 		return s.dbError(sess, "GetChannel", err)
 	}
+	channelType := channel.ChannelType
 
-	if channel.ChannelType == 0 && parentID != nil {
-		return s.sendError(sess, 6000, "Chat channels do not support threaded replies")
+	// Private (DM) channels only accept posts from their participants
+	if !s.canAccessChannel(sess, channel.ID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+	if banned := s.channelBanMessage(sess, channel.ID); banned != "" {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, banned)
 	}
 
-	// Post message to in-memory database (instant)
-	messageID, dbMsg, err := s.db.PostMessage(
-		int64(msg.ChannelID),
-		subchannelID,
-		parentID,
-		sess.UserID,
+	if errMsg := s.validateContentEncryption(channel.ID, frame.Flags, msg.Content); errMsg != "" {
+		return s.sendError(sess, protocol.ErrCodeEncryptionError, errMsg)
+	}
+
+	// Subchannels carry their own type, which takes precedence over the channel's
+	if subchannelID != nil {
+		sub, err := s.db.GetSubchannel(*subchannelID)
+		if err != nil || sub.ChannelID != channel.ID {
+			return s.sendError(sess, protocol.ErrCodeSubchannelNotFound, "Subchannel does not exist")
+		}
+		channelType = sub.SubchannelType
+	}
+
+	if channelType == 0 && parentID != nil {
+		return s.sendError(sess, 6000, "Chat channels do not support threaded replies")
+	}
+
+	// Post message to in-memory database (instant)
+	messageID, dbMsg, err := s.db.PostMessage(
+		int64(msg.ChannelID),
+		subchannelID,
+		parentID,
+		sess.UserID,
 		nickname,
 		msg.Content,
 	)
@@ -806,6 +1323,9 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 		return err
 	}
 
+	// Whatever they were typing has arrived
+	s.clearTyping(sess.ID)
+
 	// Broadcast NEW_MESSAGE to subscribed sessions
 	newMsg := convertDBMessageToProtocol(dbMsg, s.db)
 	broadcastMsg := (*protocol.NewMessageMessage)(newMsg)
@@ -822,6 +1342,11 @@ func (s *Server) handlePostMessage(sess *Session, frame *protocol.Frame) error {
 		fmt.Printf("Failed to broadcast new message: %v\n", err)
 	}
 
+	// The server can't read encrypted content, so it can't find mentions in it
+	if !channel.IsEncrypted {
+		s.notifyMentions(channel, dbMsg, newMsg)
+	}
+
 	return nil
 }
 
@@ -835,6 +1360,7 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 	// Check if user is registered (anonymous users cannot edit)
 	sess.mu.RLock()
 	userID := sess.UserID
+	nickname := sess.Nickname
 	sess.mu.RUnlock()
 
 	if userID == nil {
@@ -842,24 +1368,41 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to edit messages.")
 	}
 
-	// Validate message length
-	if uint32(len(msg.NewContent)) > s.config.MaxMessageLength {
+	if ok, retryAfter := s.allowMessage(sess); !ok {
+		return s.sendRateLimitError(sess, retryAfter)
+	}
+
+	// Validate message length (of the plaintext, for encrypted content)
+	if uint32(contentLength(frame.Flags, msg.NewContent)) > s.config.MaxMessageLength {
 		return s.sendError(sess, protocol.ErrCodeMessageTooLong, fmt.Sprintf("Message too long (max %d bytes)", s.config.MaxMessageLength))
 	}
 
-	// Check if user is admin - admins can edit any message
-	isAdmin := s.isAdmin(sess)
+	// Edits must keep the encryption state of the channel the message lives in
+	existing, existingErr := s.db.GetMessage(int64(msg.MessageID))
+	if existingErr == nil {
+		if errMsg := s.validateContentEncryption(existing.ChannelID, frame.Flags, msg.NewContent); errMsg != "" {
+			return s.sendError(sess, protocol.ErrCodeEncryptionError, errMsg)
+		}
+	}
+
+	// Admins can edit any message, moderators those in the channels they moderate
+	moderate := existingErr == nil && s.canModerateChannel(sess, existing.ChannelID)
+	if existingErr == nil && !moderate {
+		if banned := s.channelBanMessage(sess, existing.ChannelID); banned != "" {
+			return s.sendError(sess, protocol.ErrCodePermissionDenied, banned)
+		}
+	}
 
 	// Update message in database
 	var dbMsg *database.Message
 	var err error
 
-	if isAdmin {
-		// Admin edit: bypass ownership check
-		dbMsg, err = s.db.AdminUpdateMessage(msg.MessageID, uint64(*userID), msg.NewContent)
+	if moderate {
+		// Moderator edit: bypass ownership check
+		dbMsg, err = s.db.AdminUpdateMessage(msg.MessageID, uint64(*userID), msg.NewContent, nickname)
 	} else {
 		// Regular edit: check ownership
-		dbMsg, err = s.db.UpdateMessage(msg.MessageID, uint64(*userID), msg.NewContent)
+		dbMsg, err = s.db.UpdateMessage(msg.MessageID, uint64(*userID), msg.NewContent, nickname)
 	}
 	if err != nil {
 		switch {
@@ -876,6 +1419,10 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 		}
 	}
 
+	if moderate && (dbMsg.AuthorUserID == nil || *dbMsg.AuthorUserID != *userID) {
+		s.logModeration(sess, "edit_message", dbMsg)
+	}
+
 	// EditedAt should always be set by UpdateMessage
 	editedAtMs := safeDeref(dbMsg.EditedAt, time.Now().UnixMilli())
 	editedAt := time.UnixMilli(editedAtMs)
@@ -889,7 +1436,7 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 		Message:    "",
 	}
 
-	if err := s.sendMessage(sess, protocol.TypeMessageEdited, resp); err != nil {
+	if err := s.sendMessageWithFlags(sess, protocol.TypeMessageEdited, s.contentFlags(dbMsg.ChannelID, protocol.TypeMessageEdited), resp); err != nil {
 		return err
 	}
 
@@ -901,6 +1448,69 @@ func (s *Server) handleEditMessage(sess *Session, frame *protocol.Frame) error {
 	return nil
 }
 
+// maxHistoryRevisions caps how many revisions MESSAGE_HISTORY returns (the most recent ones),
+// keeping the frame well below the size limit
+const maxHistoryRevisions = 100
+
+// handleGetMessageHistory handles GET_MESSAGE_HISTORY message
+func (s *Server) handleGetMessageHistory(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.GetMessageHistoryMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// The history of a deleted message would reveal its removed content
+	dbMsg, err := s.db.GetMessage(int64(msg.MessageID))
+	if err != nil || dbMsg.DeletedAt != nil || !s.canAccessChannel(sess, dbMsg.ChannelID) {
+		return s.sendError(sess, protocol.ErrCodeMessageNotFound, "Message not found")
+	}
+
+	versions, err := s.db.ListMessageVersions(dbMsg.ID)
+	if err != nil {
+		return s.dbError(sess, "load message history", err)
+	}
+
+	revisions := messageRevisions(dbMsg, versions)
+	if len(revisions) > maxHistoryRevisions {
+		revisions = revisions[len(revisions)-maxHistoryRevisions:]
+	}
+
+	resp := &protocol.MessageHistoryMessage{
+		MessageID: msg.MessageID,
+		ChannelID: uint64(dbMsg.ChannelID),
+		Revisions: revisions,
+	}
+	return s.sendMessageWithFlags(sess, protocol.TypeMessageHistory, s.contentFlags(dbMsg.ChannelID, protocol.TypeMessageHistory), resp)
+}
+
+// messageRevisions turns a message's edit versions, which hold the content from before
+// each edit, into the revisions it went through: the original post first, current content last
+func messageRevisions(msg *database.Message, versions []database.MessageVersion) []protocol.MessageRevision {
+	revisions := []protocol.MessageRevision{{
+		EditorNickname: msg.AuthorNickname,
+		EditedAt:       time.UnixMilli(msg.CreatedAt),
+	}}
+	for _, v := range versions {
+		if v.VersionType != "edited" {
+			continue
+		}
+		revisions[len(revisions)-1].Content = v.Content
+
+		// Versions recorded before edit history only know the message author
+		editor := v.EditorNickname
+		if editor == "" {
+			editor = v.AuthorNickname
+		}
+		revisions = append(revisions, protocol.MessageRevision{
+			EditorNickname: editor,
+			EditedAt:       time.UnixMilli(v.CreatedAt),
+			AdminEdit:      v.AdminEdit,
+		})
+	}
+	revisions[len(revisions)-1].Content = msg.Content
+	return revisions
+}
+
 // handleDeleteMessage handles DELETE_MESSAGE message
 func (s *Server) handleDeleteMessage(sess *Session, frame *protocol.Frame) error {
 	msg := &protocol.DeleteMessageMessage{}
@@ -916,14 +1526,14 @@ func (s *Server) handleDeleteMessage(sess *Session, frame *protocol.Frame) error
 		return s.sendError(sess, protocol.ErrCodeNicknameRequired, "Nickname required. Use SET_NICKNAME first.")
 	}
 
-	// Check if user is admin - admins can delete any message
-	isAdmin := s.isAdmin(sess)
+	// Admins can delete any message, moderators those in the channels they moderate
+	existing, err := s.db.GetMessage(int64(msg.MessageID))
+	moderate := err == nil && s.canModerateChannel(sess, existing.ChannelID)
 
 	var dbMsg *database.Message
-	var err error
 
-	if isAdmin {
-		// Admin delete: bypass ownership check
+	if moderate {
+		// Moderator delete: bypass ownership check
 		dbMsg, err = s.db.AdminSoftDeleteMessage(msg.MessageID, nickname)
 	} else {
 		// Regular delete: check ownership
@@ -943,6 +1553,13 @@ func (s *Server) handleDeleteMessage(sess *Session, frame *protocol.Frame) error
 		}
 	}
 
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+	if moderate && (dbMsg.AuthorUserID == nil || userID == nil || *dbMsg.AuthorUserID != *userID) {
+		s.logModeration(sess, "delete_message", dbMsg)
+	}
+
 	// DeletedAt should always be set by SoftDeleteMessage, but add defensive check
 	deletedAtMs := safeDeref(dbMsg.DeletedAt, time.Now().UnixMilli())
 	deletedAt := time.UnixMilli(deletedAtMs)
@@ -964,6 +1581,329 @@ func (s *Server) handleDeleteMessage(sess *Session, frame *protocol.Frame) error
 	return nil
 }
 
+// logModeration records an admin or moderator editing or deleting someone else's
+// message in the audit log
+func (s *Server) logModeration(sess *Session, actionType string, msg *database.Message) {
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+	adminIP, _, _ := net.SplitHostPort(sess.RemoteAddr)
+
+	var channelName string
+	if channel, err := s.db.GetChannel(msg.ChannelID); err == nil {
+		channelName = channel.Name
+	}
+	messageID := msg.ID
+	if err := s.db.LogAdminAction(database.AdminAction{
+		AdminNickname:    nickname,
+		ActionType:       actionType,
+		TargetType:       "message",
+		TargetID:         &messageID,
+		TargetIdentifier: msg.AuthorNickname,
+		Details:          adminDetails(map[string]string{"channel": channelName}),
+		IPAddress:        &adminIP,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
+	}
+}
+
+// handleAddReaction handles ADD_REACTION message
+func (s *Server) handleAddReaction(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.AddReactionMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+	return s.updateReaction(sess, msg.MessageID, msg.Emoji, true)
+}
+
+// handleRemoveReaction handles REMOVE_REACTION message
+func (s *Server) handleRemoveReaction(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.RemoveReactionMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+	return s.updateReaction(sess, msg.MessageID, msg.Emoji, false)
+}
+
+// updateReaction adds or removes the session user's reaction to a message, then sends
+// REACTIONS_UPDATED to the user and everyone who can see the message
+func (s *Server) updateReaction(sess *Session, messageID uint64, emoji string, add bool) error {
+	// Reactions are per account (anonymous users cannot react)
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to react to messages.")
+	}
+
+	if !validReaction(emoji) {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, fmt.Sprintf("Invalid reaction (max %d bytes, no spaces)", maxReactionLength))
+	}
+
+	if ok, retryAfter := s.allowMessage(sess); !ok {
+		return s.sendRateLimitError(sess, retryAfter)
+	}
+
+	// Messages in private channels the user can't read don't exist as far as they know
+	dbMsg, err := s.db.GetMessage(int64(messageID))
+	if err != nil || dbMsg.DeletedAt != nil || !s.canAccessChannel(sess, dbMsg.ChannelID) {
+		return s.sendError(sess, protocol.ErrCodeMessageNotFound, "Message not found")
+	}
+	if add {
+		if banned := s.channelBanMessage(sess, dbMsg.ChannelID); banned != "" {
+			return s.sendError(sess, protocol.ErrCodePermissionDenied, banned)
+		}
+	}
+
+	var summaries []database.ReactionSummary
+	var changed bool
+	if add {
+		summaries, changed, err = s.db.AddReaction(int64(messageID), *userID, emoji)
+	} else {
+		summaries, changed, err = s.db.RemoveReaction(int64(messageID), *userID, emoji)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrMessageNotFound):
+			return s.sendError(sess, protocol.ErrCodeMessageNotFound, "Message not found")
+		case errors.Is(err, database.ErrTooManyReactions):
+			return s.sendError(sess, protocol.ErrCodeInvalidInput, "This message has too many different reactions")
+		default:
+			return s.dbError(sess, "update reaction", err)
+		}
+	}
+
+	update := &protocol.ReactionsUpdatedMessage{
+		MessageID: messageID,
+		ChannelID: uint64(dbMsg.ChannelID),
+		Reactions: convertReactionsToProtocol(summaries),
+	}
+
+	if err := s.sendMessage(sess, protocol.TypeReactionsUpdated, update); err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	// Broadcast to the channel and to everyone following the thread the message is in
+	if err := s.broadcastToChannelAndThread(dbMsg.ChannelID, optionalUint64FromInt64Ptr(dbMsg.ThreadRootID), protocol.TypeReactionsUpdated, update); err != nil {
+		log.Printf("Failed to broadcast reactions: %v", err)
+	}
+
+	return nil
+}
+
+// validReaction reports whether a reaction is acceptable: a short string without
+// whitespace or control characters
+func validReaction(emoji string) bool {
+	if emoji == "" || len(emoji) > maxReactionLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMentions returns the distinct nicknames mentioned as @nickname in content,
+// ignoring e-mail addresses and words too long to be nicknames
+func parseMentions(content string) []string {
+	var nicknames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionRegex.FindAllStringSubmatchIndex(content, -1) {
+		at, start, end := match[0], match[2], match[3]
+		if at > 0 && isMentionWordByte(content[at-1]) {
+			continue
+		}
+		if end < len(content) && isMentionWordByte(content[end]) {
+			continue
+		}
+		nickname := content[start:end]
+		if !seen[nickname] {
+			seen[nickname] = true
+			nicknames = append(nicknames, nickname)
+		}
+	}
+	return nicknames
+}
+
+// isMentionWordByte reports whether b, next to a mention, makes it part of a longer word or address
+func isMentionWordByte(b byte) bool {
+	return b == '_' || b == '-' || b == '@' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// notifyMentions stores the mentions in a new message and pushes MENTION_NOTIFICATION to
+// every session of the mentioned users, whether or not they're in the channel
+func (s *Server) notifyMentions(channel *database.Channel, dbMsg *database.Message, msg *protocol.Message) {
+	nicknames := parseMentions(dbMsg.Content)
+	if len(nicknames) > maxMentionsPerMessage {
+		nicknames = nicknames[:maxMentionsPerMessage]
+	}
+
+	var mentions []database.Mention
+	for _, nickname := range nicknames {
+		user, err := s.db.GetUserByNickname(nickname)
+		if err != nil {
+			continue // Not a registered user
+		}
+		if dbMsg.AuthorUserID != nil && *dbMsg.AuthorUserID == user.ID {
+			continue // Mentioning yourself doesn't notify
+		}
+		// Users outside a private channel can't read the message, so they aren't notified of it
+		if channel.IsPrivate && !s.db.IsChannelParticipant(channel.ID, user.ID) {
+			continue
+		}
+		mentions = append(mentions, database.Mention{
+			MessageID:    dbMsg.ID,
+			UserID:       user.ID,
+			ChannelID:    dbMsg.ChannelID,
+			SubchannelID: dbMsg.SubchannelID,
+			CreatedAt:    dbMsg.CreatedAt,
+		})
+	}
+	if len(mentions) == 0 {
+		return
+	}
+
+	if err := s.db.AddMentions(mentions); err != nil {
+		errorLog.Printf("Failed to store mentions of message %d: %v", dbMsg.ID, err)
+		return
+	}
+
+	threadRootID := dbMsg.ID
+	if dbMsg.ThreadRootID != nil {
+		threadRootID = *dbMsg.ThreadRootID
+	}
+	for _, mention := range mentions {
+		sessions := s.sessionsForUser(mention.UserID)
+		if len(sessions) == 0 {
+			continue
+		}
+		unread, err := s.db.CountUnreadMentions(mention.UserID)
+		if err != nil {
+			errorLog.Printf("Failed to count unread mentions of user %d: %v", mention.UserID, err)
+			continue
+		}
+		notification := &protocol.MentionNotificationMessage{
+			Mention:     protocol.Mention{Message: *msg, ThreadRootID: uint64(threadRootID)},
+			UnreadCount: unread,
+		}
+		for _, sess := range sessions {
+			if err := s.sendMessage(sess, protocol.TypeMentionNotification, notification); err != nil {
+				debugLog.Printf("Session %d: failed to send mention notification: %v", sess.ID, err)
+			}
+		}
+	}
+}
+
+// handleListMentions handles LIST_MENTIONS
+func (s *Server) handleListMentions(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ListMentionsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// Only registered users can be mentioned
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to receive mentions.")
+	}
+
+	limit := int(msg.Limit)
+	if limit == 0 {
+		limit = defaultMentionLimit
+	}
+	if limit > maxMentionLimit {
+		limit = maxMentionLimit
+	}
+
+	var beforeID *int64
+	if msg.BeforeID != nil {
+		id := int64(*msg.BeforeID)
+		beforeID = &id
+	}
+
+	dbMentions, err := s.db.ListMentions(*userID, msg.UnreadOnly, beforeID, limit)
+	if err != nil {
+		return s.dbError(sess, "ListMentions", err)
+	}
+	unread, err := s.db.CountUnreadMentions(*userID)
+	if err != nil {
+		return s.dbError(sess, "CountUnreadMentions", err)
+	}
+
+	mentions := []protocol.Mention{}
+	threads := []protocol.Message{}
+	seenThreads := make(map[int64]bool)
+	for _, mention := range dbMentions {
+		// Skip messages that were deleted or that the user can no longer read
+		dbMsg, err := s.db.GetMessage(mention.MessageID)
+		if err != nil || dbMsg.DeletedAt != nil || !s.canAccessChannel(sess, dbMsg.ChannelID) {
+			continue
+		}
+
+		threadRootID := dbMsg.ID
+		if dbMsg.ThreadRootID != nil {
+			threadRootID = *dbMsg.ThreadRootID
+		}
+		mentions = append(mentions, protocol.Mention{
+			Message:      *convertDBMessageToProtocol(dbMsg, s.db),
+			ThreadRootID: uint64(threadRootID),
+			Read:         mention.Read,
+		})
+
+		// Include the thread root of reply mentions so the client can open the thread directly
+		if threadRootID == dbMsg.ID || seenThreads[threadRootID] {
+			continue
+		}
+		seenThreads[threadRootID] = true
+		if root, err := s.db.GetMessage(threadRootID); err == nil {
+			threads = append(threads, *convertDBMessageToProtocol(root, s.db))
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeMentionList, &protocol.MentionListMessage{
+		Mentions:    mentions,
+		Threads:     threads,
+		UnreadCount: unread,
+	})
+}
+
+// handleMarkMentionsRead handles MARK_MENTIONS_READ
+func (s *Server) handleMarkMentionsRead(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.MarkMentionsReadMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Authentication required. Register to receive mentions.")
+	}
+
+	messageIDs := make([]int64, len(msg.MessageIDs))
+	for i, id := range msg.MessageIDs {
+		messageIDs[i] = int64(id)
+	}
+	if err := s.db.MarkMentionsRead(*userID, messageIDs, time.Now().UnixMilli()); err != nil {
+		return s.dbError(sess, "MarkMentionsRead", err)
+	}
+
+	// Silent success, like UPDATE_READ_STATE
+	return nil
+}
+
 // handleChangePassword handles CHANGE_PASSWORD message (V2 feature)
 func (s *Server) handleChangePassword(sess *Session, frame *protocol.Frame) error {
 	// Must be authenticated
@@ -1058,45 +1998,310 @@ func (s *Server) sendPasswordChanged(sess *Session, success bool, errorMessage s
 	return s.sendMessage(sess, protocol.TypePasswordChanged, resp)
 }
 
-// handleAddSSHKey handles ADD_SSH_KEY message
-func (s *Server) handleAddSSHKey(sess *Session, frame *protocol.Frame) error {
-	// Must be authenticated
+// handleEnable2FA handles ENABLE_2FA: starts 2FA setup with a new secret, which
+// CONFIRM_2FA turns on once the user's authenticator produces a matching code
+func (s *Server) handleEnable2FA(sess *Session, frame *protocol.Frame) error {
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeTwoFactorSetup, &protocol.TwoFactorSetupMessage{
+			Success: false,
+			Message: message,
+		})
+	}
+
 	sess.mu.RLock()
 	userID := sess.UserID
+	nickname := sess.Nickname
 	sess.mu.RUnlock()
-
 	if userID == nil {
-		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Must be authenticated to add SSH key")
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Must be authenticated to enable two-factor authentication")
 	}
 
-	// Decode request
-	req := &protocol.AddSSHKeyRequest{}
-	if err := req.Decode(frame.Payload); err != nil {
-		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid request format")
+	msg := &protocol.Enable2FAMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
 	}
 
-	// Validate public key format
-	if req.PublicKey == "" {
-		return s.sendSSHKeyAdded(sess, false, 0, "", "Public key cannot be empty")
+	user, err := s.db.GetUserByID(*userID)
+	if err != nil {
+		return s.dbError(sess, "GetUserByID", err)
+	}
+	if user.PasswordHash == "" {
+		// SSH key logins skip 2FA, so it would protect nothing
+		return fail("Two-factor authentication protects password logins; set a password first")
+	}
+	tf, err := s.db.GetTwoFactor(*userID)
+	if err != nil {
+		return s.dbError(sess, "GetTwoFactor", err)
+	}
+	if tf != nil {
+		return fail("Two-factor authentication is already enabled")
 	}
 
-	// Parse SSH public key
-	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	secret, err := generateTOTPSecret()
 	if err != nil {
-		return s.sendSSHKeyAdded(sess, false, 0, "", fmt.Sprintf("Invalid SSH public key: %v", err))
+		log.Printf("Session %d: failed to generate TOTP secret: %v", sess.ID, err)
+		return s.sendError(sess, protocol.ErrCodeInternalError, "Failed to generate secret")
 	}
+	sess.mu.Lock()
+	sess.pendingTOTPSecret = secret
+	sess.mu.Unlock()
 
-	// Compute fingerprint
-	fingerprint := ssh.FingerprintSHA256(pubKey)
+	issuer := s.config.ServerName
+	if issuer == "" {
+		issuer = "SuperChat"
+	}
+	return s.sendMessage(sess, protocol.TypeTwoFactorSetup, &protocol.TwoFactorSetupMessage{
+		Success: true,
+		Secret:  secret,
+		URI:     totpURI(issuer, nickname, secret),
+	})
+}
 
-	// Check for duplicate
-	existing, err := s.db.GetSSHKeyByFingerprint(fingerprint)
-	if err == nil && existing != nil {
-		return s.sendSSHKeyAdded(sess, false, 0, "", "SSH key already exists")
+// handleConfirm2FA handles CONFIRM_2FA: turns 2FA on if the code matches the secret
+// from ENABLE_2FA, and hands out recovery codes
+func (s *Server) handleConfirm2FA(sess *Session, frame *protocol.Frame) error {
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeTwoFactorEnabled, &protocol.TwoFactorEnabledMessage{
+			Success: false,
+			Message: message,
+		})
 	}
 
-	// Create SSH key record
-	sshKey := &database.SSHKey{
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	secret := sess.pendingTOTPSecret
+	sess.mu.RUnlock()
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Must be authenticated to enable two-factor authentication")
+	}
+
+	msg := &protocol.Confirm2FAMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	if secret == "" {
+		return fail("Two-factor setup hasn't been started")
+	}
+	// The secret stays pending on a wrong code, so the user can try again
+	step, ok := matchTOTP(secret, strings.ReplaceAll(strings.TrimSpace(msg.Code), " ", ""), time.Now())
+	if !ok {
+		return fail("Invalid code. Check your authenticator app's clock and try again.")
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		log.Printf("Session %d: failed to generate recovery codes: %v", sess.ID, err)
+		return s.sendError(sess, protocol.ErrCodeInternalError, "Failed to generate recovery codes")
+	}
+	if err := s.db.EnableTwoFactor(*userID, secret, hashes); err != nil {
+		return s.dbError(sess, "EnableTwoFactor", err)
+	}
+	// The confirmation code can't be replayed to log in
+	if _, err := s.db.UseTwoFactorStep(*userID, step); err != nil {
+		log.Printf("Session %d: failed to record TOTP step: %v", sess.ID, err)
+	}
+
+	sess.mu.Lock()
+	sess.pendingTOTPSecret = ""
+	sess.mu.Unlock()
+
+	log.Printf("User %s (ID: %d) enabled two-factor authentication", nickname, *userID)
+	return s.sendMessage(sess, protocol.TypeTwoFactorEnabled, &protocol.TwoFactorEnabledMessage{
+		Success:       true,
+		RecoveryCodes: codes,
+	})
+}
+
+// handleDisable2FA handles DISABLE_2FA: users turn off their own 2FA with a current
+// code, admins turn it off for anyone who lost their authenticator
+func (s *Server) handleDisable2FA(sess *Session, frame *protocol.Frame) error {
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeTwoFactorDisabled, &protocol.TwoFactorDisabledMessage{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Must be authenticated to disable two-factor authentication")
+	}
+
+	msg := &protocol.Disable2FAMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// Admin override for another user
+	if msg.Nickname != nil && *msg.Nickname != nickname {
+		if !s.isAdmin(sess) {
+			return fail("Permission denied: admin access required")
+		}
+		target, err := s.db.GetUserByNickname(*msg.Nickname)
+		if err != nil {
+			return fail(fmt.Sprintf("User %s not found", *msg.Nickname))
+		}
+		if err := s.db.DisableTwoFactor(target.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fail(fmt.Sprintf("%s doesn't use two-factor authentication", target.Nickname))
+			}
+			return s.dbError(sess, "DisableTwoFactor", err)
+		}
+
+		adminIP, _, _ := net.SplitHostPort(sess.RemoteAddr)
+		if err := s.db.LogAdminAction(database.AdminAction{
+			AdminNickname:    nickname,
+			ActionType:       "disable_2fa",
+			TargetType:       "user",
+			TargetID:         &target.ID,
+			TargetIdentifier: target.Nickname,
+			IPAddress:        &adminIP,
+		}); err != nil {
+			log.Printf("Failed to log admin action: %v", err)
+		}
+
+		log.Printf("Admin %s disabled two-factor authentication for %s", nickname, target.Nickname)
+		return s.sendMessage(sess, protocol.TypeTwoFactorDisabled, &protocol.TwoFactorDisabledMessage{
+			Success: true,
+			Message: fmt.Sprintf("Two-factor authentication disabled for %s", target.Nickname),
+		})
+	}
+
+	tf, err := s.db.GetTwoFactor(*userID)
+	if err != nil {
+		return s.dbError(sess, "GetTwoFactor", err)
+	}
+	if tf == nil {
+		return fail("Two-factor authentication isn't enabled")
+	}
+
+	// Wrong codes count as failed logins, so a hijacked session can't guess them either
+	now := time.Now()
+	ip, _, _ := net.SplitHostPort(sess.RemoteAddr)
+	if wait := s.loginLockedFor(nickname, ip, now); wait > 0 {
+		log.Printf("Session %d: DISABLE_2FA rejected - %s is locked out for %s", sess.ID, nickname, wait)
+		return fail(fmt.Sprintf("Too many failed attempts. Try again in %s.", formatLockout(wait)))
+	}
+	ok, _, err := s.checkTwoFactorCode(tf, msg.Code, now)
+	if err != nil {
+		return s.dbError(sess, "checkTwoFactorCode", err)
+	}
+	if !ok {
+		log.Printf("Session %d: DISABLE_2FA failed - wrong code for user %s", sess.ID, nickname)
+		s.recordLoginFailure(nickname, ip, now)
+		return fail("Invalid two-factor code")
+	}
+	if err := s.db.DisableTwoFactor(*userID); err != nil {
+		return s.dbError(sess, "DisableTwoFactor", err)
+	}
+
+	log.Printf("User %s (ID: %d) disabled two-factor authentication", nickname, *userID)
+	return s.sendMessage(sess, protocol.TypeTwoFactorDisabled, &protocol.TwoFactorDisabledMessage{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// handleSetProfile handles SET_PROFILE message
+func (s *Server) handleSetProfile(sess *Session, frame *protocol.Frame) error {
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Must be authenticated to set a profile")
+	}
+
+	msg := &protocol.SetProfileMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+	normalizeProfile(msg)
+	if reason := validateProfile(msg); reason != "" {
+		return s.sendMessage(sess, protocol.TypeProfileUpdated, &protocol.ProfileUpdatedMessage{
+			Success: false,
+			Message: reason,
+		})
+	}
+
+	if err := s.db.SaveUserProfile(&database.UserProfile{
+		UserID:      *userID,
+		DisplayName: msg.DisplayName,
+		Pronouns:    msg.Pronouns,
+		Bio:         msg.Bio,
+		Status:      msg.Status,
+	}); err != nil {
+		return s.dbError(sess, "SaveUserProfile", err)
+	}
+	log.Printf("User %s (ID: %d) updated their profile", nickname, *userID)
+
+	if err := s.sendMessage(sess, protocol.TypeProfileUpdated, &protocol.ProfileUpdatedMessage{
+		Success: true,
+		Message: "Profile updated",
+	}); err != nil {
+		return err
+	}
+
+	// Update every session the user is logged in on, and tell everyone
+	for _, other := range s.sessions.GetAllSessions() {
+		other.mu.Lock()
+		mine := other.UserID != nil && *other.UserID == *userID
+		if mine {
+			other.displayName = msg.DisplayName
+			other.status = msg.Status
+		}
+		other.mu.Unlock()
+		if mine {
+			s.notifyServerPresence(other, true)
+		}
+	}
+	return nil
+}
+
+// handleAddSSHKey handles ADD_SSH_KEY message
+func (s *Server) handleAddSSHKey(sess *Session, frame *protocol.Frame) error {
+	// Must be authenticated
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Must be authenticated to add SSH key")
+	}
+
+	// Decode request
+	req := &protocol.AddSSHKeyRequest{}
+	if err := req.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid request format")
+	}
+
+	// Validate public key format
+	if req.PublicKey == "" {
+		return s.sendSSHKeyAdded(sess, false, 0, "", "Public key cannot be empty")
+	}
+
+	// Parse SSH public key
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		return s.sendSSHKeyAdded(sess, false, 0, "", fmt.Sprintf("Invalid SSH public key: %v", err))
+	}
+
+	// Compute fingerprint
+	fingerprint := ssh.FingerprintSHA256(pubKey)
+
+	// Check for duplicate
+	existing, err := s.db.GetSSHKeyByFingerprint(fingerprint)
+	if err == nil && existing != nil {
+		return s.sendSSHKeyAdded(sess, false, 0, "", "SSH key already exists")
+	}
+
+	// Create SSH key record
+	sshKey := &database.SSHKey{
 		UserID:      *userID,
 		Fingerprint: fingerprint,
 		PublicKey:   req.PublicKey,
@@ -1310,8 +2515,9 @@ func (s *Server) handlePing(sess *Session, frame *protocol.Frame) error {
 		return s.sendError(sess, 1000, "Invalid message format")
 	}
 
-	// Update session activity on PING (for idle detection, rate-limited based on session timeout)
-	s.sessions.UpdateSessionActivity(sess, time.Now().UnixMilli())
+	// Update session activity on PING (for idle detection, rate-limited based on session timeout).
+	// Pings are keepalives, so they don't count against going away.
+	s.sessions.UpdateSessionActivity(sess, time.Now().UnixMilli(), false)
 
 	// Send PONG
 	resp := &protocol.PongMessage{
@@ -1321,6 +2527,76 @@ func (s *Server) handlePing(sess *Session, frame *protocol.Frame) error {
 	return s.sendMessage(sess, protocol.TypePong, resp)
 }
 
+// allowMessage applies the message rate limit to a session and, if registered, its user
+// (so opening more sessions doesn't raise a user's limit)
+func (s *Server) allowMessage(sess *Session) (bool, time.Duration) {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	keys := []string{sessionRateKey(sess.ID)}
+	if userID != nil {
+		keys = append(keys, userRateKey(*userID))
+	}
+	return s.messageLimiter.allow(time.Now(), keys...)
+}
+
+// reserveChannelCreate counts a channel creation against a user's hourly quota.
+// If the quota is used up, it returns false and how long until a slot frees up.
+func (s *Server) reserveChannelCreate(userID int64, now time.Time) (bool, time.Duration) {
+	if s.config.MaxChannelCreates == 0 {
+		return true, 0
+	}
+
+	s.channelCreateMu.Lock()
+	defer s.channelCreateMu.Unlock()
+
+	if s.channelCreates == nil {
+		s.channelCreates = make(map[int64][]time.Time)
+	}
+
+	cutoff := now.Add(-channelCreateWindow)
+	recent := s.channelCreates[userID][:0]
+	for _, ts := range s.channelCreates[userID] {
+		if ts.After(cutoff) {
+			recent = append(recent, ts)
+		}
+	}
+
+	if len(recent) >= int(s.config.MaxChannelCreates) {
+		s.channelCreates[userID] = recent
+		return false, recent[0].Sub(cutoff)
+	}
+	s.channelCreates[userID] = append(recent, now)
+	return true, 0
+}
+
+// releaseChannelCreate removes a reservation made by reserveChannelCreate
+func (s *Server) releaseChannelCreate(userID int64, at time.Time) {
+	s.channelCreateMu.Lock()
+	defer s.channelCreateMu.Unlock()
+
+	creates := s.channelCreates[userID]
+	for i, ts := range creates {
+		if ts.Equal(at) {
+			s.channelCreates[userID] = append(creates[:i], creates[i+1:]...)
+			return
+		}
+	}
+}
+
+// handleSetCompression handles SET_COMPRESSION message (no response)
+func (s *Server) handleSetCompression(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.SetCompressionMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, 1000, "Invalid message format")
+	}
+
+	sess.Conn.SetCompression(msg.Enabled)
+	debugLog.Printf("Session %d: compression %v", sess.ID, msg.Enabled)
+	return nil
+}
+
 // handleDisconnect handles graceful client disconnect
 func (s *Server) handleDisconnect(sess *Session, frame *protocol.Frame) error {
 	// Client is disconnecting gracefully - remove from sessions map immediately
@@ -1333,6 +2609,11 @@ func (s *Server) handleDisconnect(sess *Session, frame *protocol.Frame) error {
 
 // sendMessage sends a protocol message to a session
 func (s *Server) sendMessage(sess *Session, msgType uint8, msg interface{}) error {
+	return s.sendMessageWithFlags(sess, msgType, 0, msg)
+}
+
+// sendMessageWithFlags sends a protocol message to a session with the given frame flags
+func (s *Server) sendMessageWithFlags(sess *Session, msgType uint8, flags uint8, msg interface{}) error {
 	// Encode message payload
 	var payload []byte
 	var err error
@@ -1352,12 +2633,12 @@ func (s *Server) sendMessage(sess *Session, msgType uint8, msg interface{}) erro
 	frame := &protocol.Frame{
 		Version: protocol.ProtocolVersion,
 		Type:    msgType,
-		Flags:   0,
+		Flags:   flags,
 		Payload: payload,
 	}
 
 	// Send frame (SafeConn automatically handles write synchronization)
-	debugLog.Printf("Session %d → SEND: Type=0x%02X Flags=0x%02X PayloadLen=%d", sess.ID, msgType, 0, len(payload))
+	debugLog.Printf("Session %d → SEND: Type=0x%02X Flags=0x%02X PayloadLen=%d", sess.ID, msgType, flags, len(payload))
 	if err := sess.Conn.EncodeFrame(frame); err != nil {
 		errorLog.Printf("Session %d: EncodeFrame failed (Type=0x%02X): %v", sess.ID, msgType, err)
 		return err
@@ -1367,6 +2648,12 @@ func (s *Server) sendMessage(sess *Session, msgType uint8, msg interface{}) erro
 
 // broadcastToChannel sends a message to all sessions in a channel
 func (s *Server) broadcastToChannel(channelID int64, msgType uint8, msg interface{}) error {
+	return s.broadcastToChannelAndThread(channelID, nil, msgType, msg)
+}
+
+// broadcastToChannelAndThread sends a message to all sessions in a channel and, if
+// threadRootID is set, to the sessions subscribed to that thread
+func (s *Server) broadcastToChannelAndThread(channelID int64, threadRootID *uint64, msgType uint8, msg interface{}) error {
 	// Encode message payload
 	var payload []byte
 	var err error
@@ -1386,7 +2673,7 @@ func (s *Server) broadcastToChannel(channelID int64, msgType uint8, msg interfac
 	frame := &protocol.Frame{
 		Version: protocol.ProtocolVersion,
 		Type:    msgType,
-		Flags:   0,
+		Flags:   s.contentFlags(channelID, msgType),
 		Payload: payload,
 	}
 
@@ -1422,12 +2709,35 @@ func (s *Server) broadcastToChannel(channelID int64, msgType uint8, msg interfac
 		targetSessionsMap[sess.ID] = sess
 	}
 
+	// 3. Get sessions subscribed to any of this channel's subchannels
+	subchannels, _ := s.db.ListSubchannels(channelID)
+	for _, sub := range subchannels {
+		subID := uint64(sub.ID)
+		subSessions := s.sessions.GetChannelSubscribers(ChannelSubscription{
+			ChannelID:    uint64(channelID),
+			SubchannelID: &subID,
+		})
+		for _, sess := range subSessions {
+			targetSessionsMap[sess.ID] = sess
+		}
+	}
+
+	// 4. Get sessions subscribed to the thread
+	if threadRootID != nil {
+		for _, sess := range s.sessions.GetThreadSubscribers(*threadRootID) {
+			targetSessionsMap[sess.ID] = sess
+		}
+	}
+
 	// Convert map to slice
 	targetSessions := make([]*Session, 0, len(targetSessionsMap))
 	for _, sess := range targetSessionsMap {
 		targetSessions = append(targetSessions, sess)
 	}
 
+	// Private (DM) channels only reach their participants
+	targetSessions = s.filterChannelAccess(channelID, targetSessions)
+
 	// Broadcast to target sessions using worker pool
 	deadSessions := s.broadcastToSessionsParallel(targetSessions, frameBytes)
 
@@ -1504,7 +2814,7 @@ func (s *Server) broadcastNewMessage(authorSess *Session, msg *protocol.NewMessa
 	frame := &protocol.Frame{
 		Version: protocol.ProtocolVersion,
 		Type:    protocol.TypeNewMessage,
-		Flags:   0,
+		Flags:   s.contentFlags(int64(msg.ChannelID), protocol.TypeNewMessage),
 		Payload: payload,
 	}
 
@@ -1554,6 +2864,9 @@ func (s *Server) broadcastNewMessage(authorSess *Session, msg *protocol.NewMessa
 		debugLog.Printf("WARNING: Reply message %d has no threadRootID - will not be broadcast!", msg.ID)
 	}
 
+	// Private (DM) channels only reach their participants (subscriptions outlive LOGOUT)
+	targetSessions = s.filterChannelAccess(int64(msg.ChannelID), targetSessions)
+
 	// Filter recipients if author is shadowbanned
 	authorSess.mu.RLock()
 	isShadowbanned := authorSess.Shadowbanned
@@ -1662,7 +2975,24 @@ func convertDBMessageToProtocol(dbMsg *database.Message, db *database.MemDB) *pr
 		CreatedAt:      time.UnixMilli(dbMsg.CreatedAt),
 		EditedAt:       editedAt,
 		ReplyCount:     replyCount,
+		Reactions:      convertReactionsToProtocol(db.GetReactions(dbMsg.ID)),
+	}
+}
+
+// convertReactionsToProtocol converts database reaction summaries to protocol ones
+func convertReactionsToProtocol(summaries []database.ReactionSummary) []protocol.ReactionSummary {
+	if len(summaries) == 0 {
+		return nil
+	}
+	reactions := make([]protocol.ReactionSummary, len(summaries))
+	for i, summary := range summaries {
+		userIDs := make([]uint64, len(summary.UserIDs))
+		for j, userID := range summary.UserIDs {
+			userIDs[j] = uint64(userID)
+		}
+		reactions[i] = protocol.ReactionSummary{Emoji: summary.Emoji, UserIDs: userIDs}
 	}
+	return reactions
 }
 
 // handleSubscribeThread handles SUBSCRIBE_THREAD message
@@ -1687,6 +3017,10 @@ func (s *Server) handleSubscribeThread(sess *Session, frame *protocol.Frame) err
 		return s.dbError(sess, "GetMessage", err)
 	}
 
+	if !s.canAccessChannel(sess, threadMsg.ChannelID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
 	var subchannelID *uint64
 	if threadMsg.SubchannelID != nil {
 		id := uint64(*threadMsg.SubchannelID)
@@ -1745,13 +3079,14 @@ func (s *Server) handleSubscribeChannel(sess *Session, frame *protocol.Frame) er
 		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel does not exist")
 	}
 
-	// Validate subchannel if provided (still uses DB - subchannels not cached yet)
+	if !s.canAccessChannel(sess, int64(msg.ChannelID)) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
+	// Validate subchannel if provided (must belong to the channel)
 	if msg.SubchannelID != nil {
-		exists, err := s.db.SubchannelExists(int64(*msg.SubchannelID))
-		if err != nil {
-			return s.dbError(sess, "SubchannelExists", err)
-		}
-		if !exists {
+		sub, err := s.db.GetSubchannel(int64(*msg.SubchannelID))
+		if err != nil || uint64(sub.ChannelID) != msg.ChannelID {
 			return s.sendError(sess, protocol.ErrCodeSubchannelNotFound, "Subchannel does not exist")
 		}
 	}
@@ -1814,6 +3149,7 @@ func (s *Server) handleGetUserInfo(sess *Session, frame *protocol.Frame) error {
 	user, err := s.db.GetUserByNickname(msg.Nickname)
 	isRegistered := false
 	var userID *uint64
+	var profile *database.UserProfile
 
 	if err == nil {
 		// User is registered
@@ -1821,6 +3157,10 @@ func (s *Server) handleGetUserInfo(sess *Session, frame *protocol.Frame) error {
 		uid := uint64(user.ID)
 		userID = &uid
 		log.Printf("Session %d: User '%s' is registered (user_id=%d)", sess.ID, msg.Nickname, uid)
+
+		if profile, err = s.db.GetUserProfile(user.ID); err != nil {
+			return s.dbError(sess, "GetUserProfile", err)
+		}
 	} else if err != sql.ErrNoRows {
 		// Database error (not just "user not found")
 		return s.dbError(sess, "GetUserByNickname", err)
@@ -1828,15 +3168,16 @@ func (s *Server) handleGetUserInfo(sess *Session, frame *protocol.Frame) error {
 		log.Printf("Session %d: User '%s' is not registered", sess.ID, msg.Nickname)
 	}
 
-	// Check if user is currently online (check all sessions for matching nickname)
+	// Check if user is currently online (check all sessions for matching nickname);
+	// they're away if every session they have is
 	online := false
+	away := true
 	allSessions := s.sessions.GetAllSessions()
 	for _, s := range allSessions {
 		s.mu.RLock()
 		if s.Nickname == msg.Nickname {
 			online = true
-			s.mu.RUnlock()
-			break
+			away = away && s.away
 		}
 		s.mu.RUnlock()
 	}
@@ -1847,6 +3188,13 @@ func (s *Server) handleGetUserInfo(sess *Session, frame *protocol.Frame) error {
 		IsRegistered: isRegistered,
 		UserID:       userID,
 		Online:       online,
+		Away:         online && away,
+	}
+	if profile != nil {
+		resp.DisplayName = profile.DisplayName
+		resp.Pronouns = profile.Pronouns
+		resp.Bio = profile.Bio
+		resp.Status = profile.Status
 	}
 	log.Printf("Session %d: Sending USER_INFO response: nickname=%s, is_registered=%v, online=%v", sess.ID, msg.Nickname, isRegistered, online)
 	return s.sendMessage(sess, protocol.TypeUserInfo, resp)
@@ -1975,6 +3323,10 @@ func (s *Server) handleListChannelUsers(sess *Session, frame *protocol.Frame) er
 		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel does not exist")
 	}
 
+	if !s.canAccessChannel(sess, channelID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
 	if msg.SubchannelID != nil {
 		subExists, err := s.db.SubchannelExists(int64(*msg.SubchannelID))
 		if err != nil {
@@ -2043,6 +3395,31 @@ func (s *Server) broadcastChannelCreated(ch *database.Channel, creatorSessionID
 	}
 }
 
+// broadcastSubchannelCreated broadcasts a SUBCHANNEL_CREATED message to all connected users (except creator)
+func (s *Server) broadcastSubchannelCreated(sub *database.Subchannel, creatorSessionID uint64) {
+	msg := &protocol.SubchannelCreatedMessage{
+		Success:        true,
+		ChannelID:      uint64(sub.ChannelID),
+		SubchannelID:   uint64(sub.ID),
+		Name:           sub.Name,
+		Description:    safeDeref(sub.Description, ""),
+		Type:           sub.SubchannelType,
+		RetentionHours: sub.MessageRetentionHours,
+		Message:        fmt.Sprintf("New subchannel '%s' created", sub.DisplayName),
+	}
+
+	// Broadcast to all connected sessions EXCEPT the creator (they already got the response)
+	allSessions := s.sessions.GetAllSessions()
+	for _, sess := range allSessions {
+		if sess.ID == creatorSessionID {
+			continue // Skip creator - they already received the response
+		}
+		if err := s.sendMessage(sess, protocol.TypeSubchannelCreated, msg); err != nil {
+			log.Printf("Failed to broadcast SUBCHANNEL_CREATED to session %d: %v", sess.ID, err)
+		}
+	}
+}
+
 // broadcastToAll broadcasts a message to all connected clients
 func (s *Server) broadcastToAll(msgType uint8, msg interface{}) error {
 	// Encode message payload
@@ -2424,22 +3801,43 @@ func (s *Server) verifyAndRegisterServer(msg *protocol.RegisterServerMessage, so
 
 // ===== Admin System Handlers =====
 
-// handleBanUser handles BAN_USER message (admin only)
+// handleBanUser handles BAN_USER message (admins, and moderators for a limited time;
+// moderators limited to some channels can only ban from those channels)
 func (s *Server) handleBanUser(sess *Session, frame *protocol.Frame) error {
-	// Check admin permissions
-	if !s.isAdmin(sess) {
-		return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
-			Success: false,
-			Message: "Permission denied: admin access required",
-		})
-	}
-
 	// Decode message
 	msg := &protocol.BanUserMessage{}
 	if err := msg.Decode(frame.Payload); err != nil {
 		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
 	}
 
+	// Check permissions
+	if msg.ChannelID != nil {
+		if _, err := s.db.GetChannel(int64(*msg.ChannelID)); err != nil {
+			return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel not found")
+		}
+		if !s.canModerateChannel(sess, int64(*msg.ChannelID)) {
+			return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
+				Success: false,
+				Message: "Permission denied: you don't moderate this channel",
+			})
+		}
+		if msg.Shadowban {
+			return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
+				Success: false,
+				Message: "Shadowbans apply to the whole server",
+			})
+		}
+	} else if !s.canBan(sess) {
+		message := "Permission denied: admin or moderator access required"
+		if s.isModerator(sess) {
+			message = "Permission denied: you can only ban users from the channels you moderate"
+		}
+		return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
+			Success: false,
+			Message: message,
+		})
+	}
+
 	// Validate: must provide either UserID or Nickname
 	if msg.UserID == nil && msg.Nickname == nil {
 		return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
@@ -2448,6 +3846,22 @@ func (s *Server) handleBanUser(sess *Session, frame *protocol.Frame) error {
 		})
 	}
 
+	// Moderators can't ban for long, or ban staff
+	if !s.isAdmin(sess) {
+		if reason := checkModeratorBanDuration(msg.DurationSeconds); reason != "" {
+			return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
+				Success: false,
+				Message: reason,
+			})
+		}
+		if s.isStaff(msg.UserID, msg.Nickname) {
+			return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
+				Success: false,
+				Message: "Moderators cannot ban admins or moderators",
+			})
+		}
+	}
+
 	// Get admin info for audit log
 	sess.mu.RLock()
 	adminNickname := sess.Nickname
@@ -2462,7 +3876,13 @@ func (s *Server) handleBanUser(sess *Session, frame *protocol.Frame) error {
 	}
 
 	// Create ban in database
-	banID, err := s.db.CreateUserBan(userID, msg.Nickname, msg.Reason, msg.Shadowban, msg.DurationSeconds, adminNickname, adminIP)
+	var banID int64
+	var err error
+	if msg.ChannelID != nil {
+		banID, err = s.db.CreateChannelBan(userID, msg.Nickname, int64(*msg.ChannelID), msg.Reason, msg.DurationSeconds, adminNickname, adminIP)
+	} else {
+		banID, err = s.db.CreateUserBan(userID, msg.Nickname, msg.Reason, msg.Shadowban, msg.DurationSeconds, adminNickname, adminIP)
+	}
 	if err != nil {
 		log.Printf("Failed to create user ban: %v", err)
 		return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
@@ -2478,6 +3898,16 @@ func (s *Server) handleBanUser(sess *Session, frame *protocol.Frame) error {
 		targetIdentifier = fmt.Sprintf("user_id:%d", *msg.UserID)
 	}
 
+	if msg.ChannelID != nil {
+		log.Printf("Admin %s banned user %s from channel %d (ban_id=%d, reason=%s)",
+			adminNickname, targetIdentifier, *msg.ChannelID, banID, msg.Reason)
+		return s.sendMessage(sess, protocol.TypeUserBanned, &protocol.UserBannedMessage{
+			Success: true,
+			BanID:   uint64(banID),
+			Message: fmt.Sprintf("User %s banned from the channel", targetIdentifier),
+		})
+	}
+
 	log.Printf("Admin %s banned user %s (ban_id=%d, reason=%s, shadowban=%v)",
 		adminNickname, targetIdentifier, banID, msg.Reason, msg.Shadowban)
 
@@ -2489,13 +3919,13 @@ func (s *Server) handleBanUser(sess *Session, frame *protocol.Frame) error {
 	})
 }
 
-// handleBanIP handles BAN_IP message (admin only)
+// handleBanIP handles BAN_IP message (admins, and moderators for a limited time)
 func (s *Server) handleBanIP(sess *Session, frame *protocol.Frame) error {
-	// Check admin permissions
-	if !s.isAdmin(sess) {
+	// Check permissions
+	if !s.canBan(sess) {
 		return s.sendMessage(sess, protocol.TypeIPBanned, &protocol.IPBannedMessage{
 			Success: false,
-			Message: "Permission denied: admin access required",
+			Message: "Permission denied: admin or moderator access required",
 		})
 	}
 
@@ -2513,7 +3943,16 @@ func (s *Server) handleBanIP(sess *Session, frame *protocol.Frame) error {
 		})
 	}
 
-	// Get admin info for audit log
+	if !s.isAdmin(sess) {
+		if reason := checkModeratorBanDuration(msg.DurationSeconds); reason != "" {
+			return s.sendMessage(sess, protocol.TypeIPBanned, &protocol.IPBannedMessage{
+				Success: false,
+				Message: reason,
+			})
+		}
+	}
+
+	// Get admin info for audit log
 	sess.mu.RLock()
 	adminNickname := sess.Nickname
 	sess.mu.RUnlock()
@@ -2665,11 +4104,11 @@ func (s *Server) handleUnbanIP(sess *Session, frame *protocol.Frame) error {
 	})
 }
 
-// handleListBans handles LIST_BANS message (admin only)
+// handleListBans handles LIST_BANS message (admins and moderators)
 func (s *Server) handleListBans(sess *Session, frame *protocol.Frame) error {
-	// Check admin permissions
-	if !s.isAdmin(sess) {
-		return s.sendError(sess, protocol.ErrCodePermissionDenied, "Permission denied: admin access required")
+	// Check permissions
+	if !s.isAdmin(sess) && !s.isModerator(sess) {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "Permission denied: admin or moderator access required")
 	}
 
 	// Decode message
@@ -2693,6 +4132,11 @@ func (s *Server) handleListBans(sess *Session, frame *protocol.Frame) error {
 			id := uint64(*ban.UserID)
 			userID = &id
 		}
+		var channelID *uint64
+		if ban.ChannelID != nil {
+			id := uint64(*ban.ChannelID)
+			channelID = &id
+		}
 
 		banEntries[i] = protocol.BanEntry{
 			ID:          uint64(ban.ID),
@@ -2705,6 +4149,7 @@ func (s *Server) handleListBans(sess *Session, frame *protocol.Frame) error {
 			BannedAt:    ban.BannedAt,
 			BannedUntil: ban.BannedUntil,
 			BannedBy:    ban.BannedBy,
+			ChannelID:   channelID,
 		}
 	}
 
@@ -2715,6 +4160,279 @@ func (s *Server) handleListBans(sess *Session, frame *protocol.Frame) error {
 	return s.sendMessage(sess, protocol.TypeBanList, resp)
 }
 
+// Audit log page sizes
+const (
+	defaultAdminActionLimit = 50
+	maxAdminActionLimit     = 200
+)
+
+// handleListAdminActions handles LIST_ADMIN_ACTIONS (admins and moderators)
+func (s *Server) handleListAdminActions(sess *Session, frame *protocol.Frame) error {
+	admin := s.isAdmin(sess)
+	if !admin && !s.isModerator(sess) {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "Permission denied: admin or moderator access required")
+	}
+
+	msg := &protocol.ListAdminActionsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	limit := int(msg.Limit)
+	if limit == 0 {
+		limit = defaultAdminActionLimit
+	}
+	limit = min(limit, maxAdminActionLimit)
+
+	filter := database.AdminActionFilter{Limit: limit + 1} // One extra to know if there are more
+	if msg.AdminNickname != nil {
+		filter.AdminNickname = *msg.AdminNickname
+	}
+	if msg.ActionType != nil {
+		filter.ActionType = *msg.ActionType
+	}
+	if msg.Since != nil {
+		filter.Since = msg.Since.UnixMilli()
+	}
+	if msg.Until != nil {
+		filter.Until = msg.Until.UnixMilli()
+	}
+	if msg.BeforeID != nil {
+		filter.BeforeID = int64(*msg.BeforeID)
+	}
+
+	actions, err := s.db.ListAdminActions(filter)
+	if err != nil {
+		return s.dbError(sess, "ListAdminActions", err)
+	}
+
+	resp := &protocol.AdminActionListMessage{}
+	if len(actions) > limit {
+		actions = actions[:limit]
+		resp.HasMore = true
+	}
+	resp.Entries = make([]protocol.AdminActionEntry, len(actions))
+	for i, action := range actions {
+		entry := protocol.AdminActionEntry{
+			ID:               uint64(action.ID),
+			AdminNickname:    action.AdminNickname,
+			ActionType:       action.ActionType,
+			TargetType:       action.TargetType,
+			TargetID:         optionalUint64FromInt64Ptr(action.TargetID),
+			TargetIdentifier: action.TargetIdentifier,
+			Details:          action.Details,
+			PerformedAt:      time.UnixMilli(action.PerformedAt),
+		}
+		// Moderators see what was done, not where admins connect from
+		if admin {
+			entry.IPAddress = action.IPAddress
+		}
+		resp.Entries[i] = entry
+	}
+
+	return s.sendMessage(sess, protocol.TypeAdminActionList, resp)
+}
+
+// handleSetUserFlags handles SET_USER_FLAGS message (admin only)
+func (s *Server) handleSetUserFlags(sess *Session, frame *protocol.Frame) error {
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeUserFlagsUpdated, &protocol.UserFlagsUpdatedMessage{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return fail("Permission denied: admin access required")
+	}
+
+	// Decode message
+	msg := &protocol.SetUserFlagsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	// Validate: must provide either UserID or Nickname
+	if msg.UserID == nil && msg.Nickname == nil {
+		return fail("Must provide either UserID or Nickname")
+	}
+	if msg.Flags&^(protocol.UserFlagAdmin|protocol.UserFlagModerator) != 0 {
+		return fail("Unknown user flags")
+	}
+	if !msg.Flags.IsModerator() && len(msg.ModeratorChannels) > 0 {
+		return fail("Only moderators can be limited to channels")
+	}
+
+	var user *database.User
+	var err error
+	if msg.UserID != nil {
+		user, err = s.db.GetUserByID(int64(*msg.UserID))
+	} else {
+		user, err = s.db.GetUserByNickname(*msg.Nickname)
+	}
+	if err != nil {
+		return fail("User not found")
+	}
+
+	// Don't allow admins to demote themselves
+	sess.mu.RLock()
+	adminUserID := sess.UserID
+	adminNickname := sess.Nickname
+	sess.mu.RUnlock()
+	if adminUserID != nil && *adminUserID == user.ID {
+		return fail("Cannot change your own roles")
+	}
+
+	// Moderators can only be limited to public channels
+	channelIDs := make([]int64, 0, len(msg.ModeratorChannels))
+	channelNames := make([]string, 0, len(msg.ModeratorChannels))
+	for _, id := range msg.ModeratorChannels {
+		channel, err := s.db.GetChannel(int64(id))
+		if err != nil || channel.IsPrivate {
+			return fail(fmt.Sprintf("Channel %d not found", id))
+		}
+		channelIDs = append(channelIDs, channel.ID)
+		channelNames = append(channelNames, channel.Name)
+	}
+
+	if err := s.db.UpdateUserFlags(user.ID, uint8(msg.Flags)); err != nil {
+		return s.dbError(sess, "UpdateUserFlags", err)
+	}
+	// Users that aren't moderators (anymore) have no channels
+	if err := s.db.SetModeratorChannels(user.ID, channelIDs); err != nil {
+		return s.dbError(sess, "SetModeratorChannels", err)
+	}
+	s.updateSessionFlags(user.ID, uint8(msg.Flags))
+
+	// Log admin action
+	adminIP, _, _ := net.SplitHostPort(sess.RemoteAddr)
+	targetID := user.ID
+	if err := s.db.LogAdminAction(database.AdminAction{
+		AdminNickname:    adminNickname,
+		ActionType:       "set_user_flags",
+		TargetType:       "user",
+		TargetID:         &targetID,
+		TargetIdentifier: user.Nickname,
+		Details: adminDetails(map[string]string{
+			"role":     roleName(uint8(msg.Flags)),
+			"channels": strings.Join(channelNames, ", "),
+		}),
+		IPAddress: &adminIP,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
+	}
+
+	description := roleName(uint8(msg.Flags))
+	if len(channelNames) > 0 {
+		description += " in #" + strings.Join(channelNames, ", #")
+	}
+	log.Printf("Admin %s set %s's role to %s", adminNickname, user.Nickname, description)
+
+	resp := &protocol.UserFlagsUpdatedMessage{
+		Success:           true,
+		UserID:            uint64(user.ID),
+		Nickname:          user.Nickname,
+		Flags:             msg.Flags,
+		ModeratorChannels: msg.ModeratorChannels,
+		Message:           fmt.Sprintf("%s is now %s", user.Nickname, description),
+	}
+
+	// Let the user know their permissions changed
+	for _, targetSess := range s.sessionsForUser(user.ID) {
+		if err := s.sendMessage(targetSess, protocol.TypeUserFlagsUpdated, resp); err != nil {
+			log.Printf("Failed to notify session %d of role change: %v", targetSess.ID, err)
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeUserFlagsUpdated, resp)
+}
+
+// handleListLoginLockouts handles LIST_LOGIN_LOCKOUTS message (admin only)
+func (s *Server) handleListLoginLockouts(sess *Session, frame *protocol.Frame) error {
+	if !s.isAdmin(sess) {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "Permission denied: admin access required")
+	}
+
+	lockouts, err := s.db.ListLoginLockouts(time.Now().UnixMilli())
+	if err != nil {
+		return s.dbError(sess, "ListLoginLockouts", err)
+	}
+
+	resp := &protocol.LoginLockoutListMessage{
+		Lockouts: make([]protocol.LoginLockout, len(lockouts)),
+	}
+	for i, lockout := range lockouts {
+		resp.Lockouts[i] = protocol.LoginLockout{
+			Kind:          lockout.Kind,
+			Identifier:    lockout.Identifier,
+			Failures:      uint32(lockout.Failures),
+			LastFailureAt: time.UnixMilli(lockout.LastFailureAt),
+			LockedUntil:   time.UnixMilli(*lockout.LockedUntil),
+		}
+	}
+
+	return s.sendMessage(sess, protocol.TypeLoginLockoutList, resp)
+}
+
+// handleClearLoginLockout handles CLEAR_LOGIN_LOCKOUT message (admin only)
+func (s *Server) handleClearLoginLockout(sess *Session, frame *protocol.Frame) error {
+	fail := func(message string) error {
+		return s.sendMessage(sess, protocol.TypeLoginLockoutCleared, &protocol.LoginLockoutClearedMessage{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	// Check admin permissions
+	if !s.isAdmin(sess) {
+		return fail("Permission denied: admin access required")
+	}
+
+	// Decode message
+	msg := &protocol.ClearLoginLockoutMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	if msg.Kind != loginFailureUser && msg.Kind != loginFailureIP {
+		return fail(fmt.Sprintf("Unknown lockout kind %q", msg.Kind))
+	}
+	identifier := strings.TrimSpace(msg.Identifier)
+	if identifier == "" {
+		return fail("Must provide a nickname or IP address")
+	}
+
+	if err := s.db.ClearLoginFailure(msg.Kind, identifier); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fail(fmt.Sprintf("No failed logins recorded for %s %s", msg.Kind, identifier))
+		}
+		return s.dbError(sess, "ClearLoginFailure", err)
+	}
+
+	// Log admin action
+	sess.mu.RLock()
+	adminNickname := sess.Nickname
+	sess.mu.RUnlock()
+	adminIP, _, _ := net.SplitHostPort(sess.RemoteAddr)
+	if err := s.db.LogAdminAction(database.AdminAction{
+		AdminNickname:    adminNickname,
+		ActionType:       "clear_login_lockout",
+		TargetType:       msg.Kind,
+		TargetIdentifier: identifier,
+		IPAddress:        &adminIP,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
+	}
+
+	log.Printf("Admin %s cleared the login lockout of %s %s", adminNickname, msg.Kind, identifier)
+
+	return s.sendMessage(sess, protocol.TypeLoginLockoutCleared, &protocol.LoginLockoutClearedMessage{
+		Success: true,
+		Message: fmt.Sprintf("Cleared login lockout of %s %s", msg.Kind, identifier),
+	})
+}
+
 // handleDeleteUser handles DELETE_USER message (admin only)
 func (s *Server) handleDeleteUser(sess *Session, frame *protocol.Frame) error {
 	// Check admin permissions
@@ -2764,11 +4482,17 @@ func (s *Server) handleDeleteUser(sess *Session, frame *protocol.Frame) error {
 	}
 
 	// Log admin action
-	if adminUserID != nil {
-		if err := s.db.LogAdminAction(uint64(*adminUserID), sess.Nickname, "DELETE_USER",
-			fmt.Sprintf("user_id=%d nickname=%s", msg.UserID, user.Nickname)); err != nil {
-			log.Printf("Failed to log admin action: %v", err)
-		}
+	adminIP, _, _ := net.SplitHostPort(sess.RemoteAddr)
+	targetID := user.ID
+	if err := s.db.LogAdminAction(database.AdminAction{
+		AdminNickname:    sess.Nickname,
+		ActionType:       "delete_user",
+		TargetType:       "user",
+		TargetID:         &targetID,
+		TargetIdentifier: user.Nickname,
+		IPAddress:        &adminIP,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
 	}
 
 	// Delete user (anonymizes messages, removes from DB)
@@ -2834,14 +4558,20 @@ func (s *Server) handleDeleteChannel(sess *Session, frame *protocol.Frame) error
 	// Log admin action
 	sess.mu.RLock()
 	adminNickname := sess.Nickname
-	adminUserID := sess.UserID
 	sess.mu.RUnlock()
+	adminIP, _, _ := net.SplitHostPort(sess.RemoteAddr)
 
-	if adminUserID != nil {
-		if err := s.db.LogAdminAction(uint64(*adminUserID), adminNickname, "DELETE_CHANNEL",
-			fmt.Sprintf("channel_id=%d name=%s reason=%s", msg.ChannelID, channel.Name, msg.Reason)); err != nil {
-			log.Printf("Failed to log admin action: %v", err)
-		}
+	targetID := channel.ID
+	if err := s.db.LogAdminAction(database.AdminAction{
+		AdminNickname:    adminNickname,
+		ActionType:       "delete_channel",
+		TargetType:       "channel",
+		TargetID:         &targetID,
+		TargetIdentifier: channel.Name,
+		Details:          adminDetails(map[string]string{"reason": msg.Reason}),
+		IPAddress:        &adminIP,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
 	}
 
 	// Delete the channel (cascades to messages, subchannels, subscriptions)
@@ -2905,6 +4635,16 @@ func (s *Server) handleGetUnreadCounts(sess *Session, frame *protocol.Frame) err
 		var count uint32
 		var err error
 
+		// Skip private (DM) channels the session has no access to
+		if !s.canAccessChannel(sess, int64(target.ChannelID)) {
+			continue
+		}
+		if target.ThreadID != nil {
+			if threadMsg, err := s.db.GetMessage(int64(*target.ThreadID)); err == nil && !s.canAccessChannel(sess, threadMsg.ChannelID) {
+				continue
+			}
+		}
+
 		// If no explicit timestamp provided, get user's last read timestamp for this target
 		timestamp := sinceTimestamp
 		if msg.SinceTimestamp == nil && userID != nil {
@@ -2970,3 +4710,858 @@ func (s *Server) handleUpdateReadState(sess *Session, frame *protocol.Frame) err
 	// Silent success (no response message defined for UPDATE_READ_STATE)
 	return nil
 }
+
+// ===== Direct Message Handlers =====
+
+// maxDMParticipants caps the size of small-group DMs
+const maxDMParticipants = 8
+
+// canAccessChannel reports whether the session may read or post in a channel.
+// Public channels are open to everyone; private (DM) channels only to their participants.
+// Unknown channels are reported as accessible so callers keep their own not-found handling.
+func (s *Server) canAccessChannel(sess *Session, channelID int64) bool {
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil || !ch.IsPrivate {
+		return true
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	return userID != nil && s.db.IsChannelParticipant(channelID, *userID)
+}
+
+// channelBanMessage returns why the session is banned from posting in a channel,
+// or "" if it isn't
+func (s *Server) channelBanMessage(sess *Session, channelID int64) string {
+	sess.mu.RLock()
+	userID := sess.UserID
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+
+	ban, err := s.db.GetActiveChannelBan(userID, nickname, channelID)
+	if err != nil {
+		log.Printf("Session %d: failed to check channel ban: %v", sess.ID, err)
+		return ""
+	}
+	if ban == nil {
+		return ""
+	}
+
+	bannedUntil := "permanently"
+	if ban.BannedUntil != nil {
+		bannedUntil = fmt.Sprintf("until %s", time.Unix(*ban.BannedUntil/1000, 0).Format(time.RFC3339))
+	}
+	return fmt.Sprintf("Banned from this channel %s. Reason: %s", bannedUntil, ban.Reason)
+}
+
+// filterChannelAccess drops sessions that may not see traffic for a private channel
+func (s *Server) filterChannelAccess(channelID int64, sessions []*Session) []*Session {
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil || !ch.IsPrivate {
+		return sessions
+	}
+
+	filtered := make([]*Session, 0, len(sessions))
+	for _, sess := range sessions {
+		sess.mu.RLock()
+		userID := sess.UserID
+		sess.mu.RUnlock()
+
+		if userID != nil && s.db.IsChannelParticipant(channelID, *userID) {
+			filtered = append(filtered, sess)
+		}
+	}
+	return filtered
+}
+
+// sessionsForUser returns all connected sessions authenticated as the given user
+func (s *Server) sessionsForUser(userID int64) []*Session {
+	var result []*Session
+	for _, sess := range s.sessions.GetAllSessions() {
+		sess.mu.RLock()
+		sessUserID := sess.UserID
+		sess.mu.RUnlock()
+
+		if sessUserID != nil && *sessUserID == userID {
+			result = append(result, sess)
+		}
+	}
+	return result
+}
+
+// resolveDMTarget looks up the registered user addressed by a START_DM/ADD_DM_PARTICIPANT target.
+// Returns a user-facing error message if the target cannot receive DMs.
+func (s *Server) resolveDMTarget(targetType uint8, targetID uint64, targetNickname string) (*database.User, string) {
+	switch targetType {
+	case protocol.DMTargetUserID:
+		user, err := s.db.GetUserByID(int64(targetID))
+		if err != nil {
+			return nil, "User not found"
+		}
+		return user, ""
+	case protocol.DMTargetNickname:
+		user, err := s.db.GetUserByNickname(targetNickname)
+		if err != nil {
+			return nil, fmt.Sprintf("No registered user named '%s'", targetNickname)
+		}
+		return user, ""
+	case protocol.DMTargetSessionID:
+		target, ok := s.sessions.GetSession(targetID)
+		if !ok {
+			return nil, "Session not found"
+		}
+		target.mu.RLock()
+		userID := target.UserID
+		target.mu.RUnlock()
+		if userID == nil {
+			return nil, "Anonymous users cannot receive direct messages"
+		}
+		user, err := s.db.GetUserByID(*userID)
+		if err != nil {
+			return nil, "User not found"
+		}
+		return user, ""
+	default:
+		return nil, "Invalid target type"
+	}
+}
+
+// dmDisplayName builds the name shown for a DM from the perspective of viewerID
+// (the other participants' nicknames, comma-separated)
+func (s *Server) dmDisplayName(channelID, viewerID int64) (string, *uint64) {
+	var names []string
+	var others []int64
+	for _, userID := range s.db.ListChannelParticipants(channelID) {
+		if userID == viewerID {
+			continue
+		}
+		user, err := s.db.GetUserByID(userID)
+		if err != nil {
+			continue
+		}
+		names = append(names, user.Nickname)
+		others = append(others, userID)
+	}
+
+	// other_user_id is only meaningful for 1:1 conversations
+	var otherUserID *uint64
+	if len(others) == 1 {
+		id := uint64(others[0])
+		otherUserID = &id
+	}
+	return strings.Join(names, ", "), otherUserID
+}
+
+// sendDMReady sends DM_READY for a channel to every online session of the given user.
+// For encrypted DMs each session gets the channel key wrapped for its own public key.
+func (s *Server) sendDMReady(channelID, userID int64) {
+	isEncrypted := false
+	if ch, err := s.db.GetChannel(channelID); err == nil {
+		isEncrypted = ch.IsEncrypted
+	}
+
+	name, otherUserID := s.dmDisplayName(channelID, userID)
+	for _, sess := range s.sessionsForUser(userID) {
+		msg := &protocol.DMReadyMessage{
+			ChannelID:     uint64(channelID),
+			OtherUserID:   otherUserID,
+			OtherNickname: name,
+			IsEncrypted:   isEncrypted,
+		}
+		if isEncrypted {
+			msg.ChannelKey = s.sessionChannelKey(sess, channelID)
+		}
+		if err := s.sendMessage(sess, protocol.TypeDMReady, msg); err != nil {
+			log.Printf("Failed to send DM_READY to session %d: %v", sess.ID, err)
+		}
+	}
+}
+
+// sendDMRequest notifies every online session of recipientID that fromSess added them to a DM
+func (s *Server) sendDMRequest(channelID, recipientID int64, fromSess *Session, requiresKey, allowUnencrypted bool) {
+	fromSess.mu.RLock()
+	fromNickname := fromSess.Nickname
+	fromUserID := fromSess.UserID
+	fromSess.mu.RUnlock()
+
+	msg := &protocol.DMRequestMessage{
+		ChannelID:                  uint64(channelID),
+		FromUserID:                 optionalUint64FromInt64Ptr(fromUserID),
+		FromNickname:               fromNickname,
+		RequiresKey:                requiresKey,
+		InitiatorAllowsUnencrypted: allowUnencrypted,
+	}
+	for _, sess := range s.sessionsForUser(recipientID) {
+		if err := s.sendMessage(sess, protocol.TypeDMRequest, msg); err != nil {
+			log.Printf("Failed to send DM_REQUEST to session %d: %v", sess.ID, err)
+		}
+	}
+}
+
+// sendKeyRequired asks every online session of a user to provide an encryption key
+func (s *Server) sendKeyRequired(userID int64, channelID *uint64, reason string) {
+	msg := &protocol.KeyRequiredMessage{
+		Reason:      reason,
+		DMChannelID: channelID,
+	}
+	for _, sess := range s.sessionsForUser(userID) {
+		if err := s.sendMessage(sess, protocol.TypeKeyRequired, msg); err != nil {
+			log.Printf("Failed to send KEY_REQUIRED to session %d: %v", sess.ID, err)
+		}
+	}
+}
+
+// ===== DM Encryption =====
+
+// userEncryptionKeys returns the encryption keys a user's DM channel keys are wrapped for.
+// Registered ed25519 SSH keys are converted to X25519 keys the first time they are seen.
+func (s *Server) userEncryptionKeys(userID int64) []database.EncryptionKey {
+	keys, err := s.db.ListEncryptionKeys(userID)
+	if err != nil {
+		log.Printf("Failed to list encryption keys for user %d: %v", userID, err)
+		return nil
+	}
+
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key.PublicKey] = true
+	}
+
+	sshKeys, err := s.db.GetSSHKeysByUserID(userID)
+	if err != nil {
+		return keys
+	}
+	added := false
+	for _, sshKey := range sshKeys {
+		publicKey, ok := sshKeyToEncryptionKey(sshKey.PublicKey)
+		if !ok || known[publicKey] {
+			continue
+		}
+		if _, err := s.db.AddEncryptionKey(userID, protocol.KeyTypeSSHDerived, publicKey, sshKey.Label); err != nil {
+			log.Printf("Failed to add SSH-derived encryption key for user %d: %v", userID, err)
+			continue
+		}
+		added = true
+	}
+
+	if added {
+		if keys, err = s.db.ListEncryptionKeys(userID); err != nil {
+			return nil
+		}
+	}
+	return keys
+}
+
+// sshKeyToEncryptionKey converts an ed25519 SSH public key (authorized_keys format)
+// to a base64 X25519 public key. Other key types cannot be used for DM encryption.
+func sshKeyToEncryptionKey(authorizedKey string) (string, bool) {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil || parsed.Type() != ssh.KeyAlgoED25519 {
+		return "", false
+	}
+	cryptoKey, ok := parsed.(ssh.CryptoPublicKey)
+	if !ok {
+		return "", false
+	}
+	edKey, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok {
+		return "", false
+	}
+	x25519Key, err := protocol.Ed25519PublicKeyToX25519(edKey)
+	if err != nil {
+		return "", false
+	}
+	return protocol.EncodeEncryptionPublicKey(x25519Key), true
+}
+
+// hasEncryptionKey reports whether a user has at least one encryption key
+func (s *Server) hasEncryptionKey(userID int64) bool {
+	return len(s.userEncryptionKeys(userID)) > 0
+}
+
+// sessionChannelKey returns the channel key wrapped for the session's public key, if any
+func (s *Server) sessionChannelKey(sess *Session, channelID int64) *string {
+	sess.mu.RLock()
+	keyID := sess.EncryptionKeyID
+	sess.mu.RUnlock()
+
+	if keyID == nil {
+		return nil
+	}
+	wrapped, err := s.db.ListChannelKeys(channelID)
+	if err != nil {
+		return nil
+	}
+	if key, ok := wrapped[*keyID]; ok {
+		return &key
+	}
+	return nil
+}
+
+// dmKeysReady reports whether every participant of an encrypted DM can read it
+// (has at least one public key the channel key is wrapped for)
+func (s *Server) dmKeysReady(channelID int64) bool {
+	wrapped, err := s.db.ListChannelKeys(channelID)
+	if err != nil {
+		return false
+	}
+	for _, userID := range s.db.ListChannelParticipants(channelID) {
+		ready := false
+		for _, key := range s.userEncryptionKeys(userID) {
+			if _, ok := wrapped[key.ID]; ok {
+				ready = true
+				break
+			}
+		}
+		if !ready {
+			return false
+		}
+	}
+	return true
+}
+
+// requestDMKeys asks an online participant that holds the channel key to wrap it for every
+// participant public key that has no wrapped key yet. For a new channel (nothing wrapped yet)
+// the creator is asked to generate the key. If nobody who can do it is online, the request
+// is repeated when they provide their public key.
+func (s *Server) requestDMKeys(channelID int64) {
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil || !ch.IsEncrypted {
+		return
+	}
+	wrapped, err := s.db.ListChannelKeys(channelID)
+	if err != nil {
+		log.Printf("Failed to list channel keys for DM %d: %v", channelID, err)
+		return
+	}
+
+	var missing []protocol.DMPublicKey
+	for _, userID := range s.db.ListChannelParticipants(channelID) {
+		user, err := s.db.GetUserByID(userID)
+		if err != nil {
+			continue
+		}
+		for _, key := range s.userEncryptionKeys(userID) {
+			if _, ok := wrapped[key.ID]; ok {
+				continue
+			}
+			missing = append(missing, protocol.DMPublicKey{
+				KeyID:     uint64(key.ID),
+				UserID:    uint64(userID),
+				Nickname:  user.Nickname,
+				PublicKey: key.PublicKey,
+			})
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	// The key count is a u8 on the wire; the rest are requested on the next exchange
+	if len(missing) > 255 {
+		missing = missing[:255]
+	}
+
+	holder := s.findDMKeyHolder(ch, wrapped)
+	if holder == nil {
+		return
+	}
+
+	msg := &protocol.DMKeyExchangeMessage{
+		ChannelID: uint64(channelID),
+		NewKey:    len(wrapped) == 0,
+		Keys:      missing,
+	}
+	if err := s.sendMessage(holder, protocol.TypeDMKeyExchange, msg); err != nil {
+		log.Printf("Failed to send DM_KEY_EXCHANGE to session %d: %v", holder.ID, err)
+	}
+}
+
+// findDMKeyHolder returns an online session that can wrap the channel key: one whose public
+// key already has a wrapped copy, or the creator's keyed session if the channel has no key yet
+func (s *Server) findDMKeyHolder(ch *database.Channel, wrapped map[int64]string) *Session {
+	for _, userID := range s.db.ListChannelParticipants(ch.ID) {
+		if len(wrapped) == 0 && (ch.CreatedBy == nil || *ch.CreatedBy != userID) {
+			continue
+		}
+		for _, sess := range s.sessionsForUser(userID) {
+			sess.mu.RLock()
+			keyID := sess.EncryptionKeyID
+			sess.mu.RUnlock()
+
+			if keyID == nil {
+				continue
+			}
+			if _, ok := wrapped[*keyID]; ok || len(wrapped) == 0 {
+				return sess
+			}
+		}
+	}
+	return nil
+}
+
+// setDMPending marks an encrypted DM as waiting for participant keys
+func (s *Server) setDMPending(channelID int64, initiatorAllowsUnencrypted bool) {
+	s.pendingDMMu.Lock()
+	defer s.pendingDMMu.Unlock()
+	s.pendingDMs[channelID] = initiatorAllowsUnencrypted
+}
+
+// dmPending reports whether a DM is waiting for keys, and whether its initiator
+// would accept falling back to an unencrypted conversation
+func (s *Server) dmPending(channelID int64) (pending, initiatorAllowsUnencrypted bool) {
+	s.pendingDMMu.Lock()
+	defer s.pendingDMMu.Unlock()
+	initiatorAllowsUnencrypted, pending = s.pendingDMs[channelID]
+	return pending, initiatorAllowsUnencrypted
+}
+
+// clearDMPending marks a DM as no longer waiting for keys
+func (s *Server) clearDMPending(channelID int64) {
+	s.pendingDMMu.Lock()
+	defer s.pendingDMMu.Unlock()
+	delete(s.pendingDMs, channelID)
+}
+
+// contentFlags returns the frame flags for a message about a channel.
+// Frames carrying message content from an encrypted DM are marked FlagEncrypted.
+func (s *Server) contentFlags(channelID int64, msgType uint8) uint8 {
+	switch msgType {
+	case protocol.TypeNewMessage, protocol.TypeMessageList, protocol.TypeMessageEdited, protocol.TypeMessageHistory:
+	default:
+		return 0
+	}
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil || !ch.IsEncrypted {
+		return 0
+	}
+	return protocol.FlagEncrypted
+}
+
+// contentLength returns the length limits apply to: the plaintext length for encrypted content
+func contentLength(flags uint8, content string) int {
+	if flags&protocol.FlagEncrypted != 0 {
+		return protocol.EncryptedContentLength(content)
+	}
+	return len(content)
+}
+
+// validateContentEncryption checks that content is encrypted exactly when the channel is an
+// encrypted DM. Returns a user-facing error message, or "" if the content is acceptable.
+func (s *Server) validateContentEncryption(channelID int64, flags uint8, content string) string {
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil {
+		return ""
+	}
+	encrypted := flags&protocol.FlagEncrypted != 0
+	switch {
+	case ch.IsEncrypted && !encrypted:
+		return "This conversation is end-to-end encrypted. Messages must be encrypted."
+	case !ch.IsEncrypted && encrypted:
+		return "This channel does not accept encrypted messages"
+	case encrypted && protocol.EncryptedContentLength(content) == 0:
+		return "Malformed encrypted message"
+	}
+	return ""
+}
+
+// handleStartDM handles START_DM message (registered users only)
+func (s *Server) handleStartDM(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.StartDMMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Direct messages require a registered account")
+	}
+
+	// An initiator without a key must either provide one or accept an unencrypted conversation
+	initiatorHasKey := s.hasEncryptionKey(*userID)
+	if !initiatorHasKey && !msg.AllowUnencrypted {
+		return s.sendMessage(sess, protocol.TypeKeyRequired, &protocol.KeyRequiredMessage{
+			Reason: "Provide an encryption key to start encrypted direct messages",
+		})
+	}
+
+	target, errMsg := s.resolveDMTarget(msg.TargetType, msg.TargetID, msg.TargetNickname)
+	if target == nil {
+		return s.sendError(sess, protocol.ErrCodeUserNotFound, errMsg)
+	}
+	if target.ID == *userID {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Cannot start a direct message with yourself")
+	}
+
+	// Reuse an existing 1:1 conversation between the two users, unless it is unencrypted
+	// and the initiator now insists on encryption
+	participants := []int64{*userID, target.ID}
+	if existing, ok := s.db.FindDMChannel(participants); ok && (existing.IsEncrypted || msg.AllowUnencrypted) {
+		if pending, _ := s.dmPending(existing.ID); pending {
+			s.sendDMPending(sess, existing.ID, target)
+		} else {
+			s.sendDMReady(existing.ID, *userID)
+		}
+		s.requestDMKeys(existing.ID)
+		return nil
+	}
+
+	targetHasKey := s.hasEncryptionKey(target.ID)
+	targetAllowsUnencrypted, err := s.db.GetAllowUnencryptedDMs(target.ID)
+	if err != nil {
+		return s.dbError(sess, "GetAllowUnencryptedDMs", err)
+	}
+
+	// Encrypt whenever both sides can, fall back to plaintext only if both sides agreed to it
+	encrypted := (initiatorHasKey && targetHasKey) || !(msg.AllowUnencrypted && targetAllowsUnencrypted)
+
+	ch, err := s.db.CreateDMChannel(*userID, participants, encrypted)
+	if err != nil {
+		return s.dbError(sess, "CreateDMChannel", err)
+	}
+
+	log.Printf("Session %d: started DM %d with user %d (%s), encrypted=%v", sess.ID, ch.ID, target.ID, target.Nickname, encrypted)
+
+	switch {
+	case !encrypted:
+		s.sendDMReady(ch.ID, *userID)
+		s.sendDMRequest(ch.ID, target.ID, sess, false, msg.AllowUnencrypted)
+
+	case initiatorHasKey && targetHasKey:
+		// DM_READY goes out once the initiator has generated and wrapped the channel key
+		s.setDMPending(ch.ID, msg.AllowUnencrypted)
+		s.sendDMRequest(ch.ID, target.ID, sess, false, msg.AllowUnencrypted)
+		s.requestDMKeys(ch.ID)
+
+	default:
+		// Someone still needs a key before the conversation can start
+		s.setDMPending(ch.ID, msg.AllowUnencrypted)
+		s.sendDMPending(sess, ch.ID, target)
+		channelID := uint64(ch.ID)
+		if !initiatorHasKey {
+			s.sendKeyRequired(*userID, &channelID, "Provide an encryption key for this encrypted conversation")
+		}
+		if !targetHasKey {
+			sess.mu.RLock()
+			nickname := sess.Nickname
+			sess.mu.RUnlock()
+			s.sendKeyRequired(target.ID, &channelID, fmt.Sprintf("%s wants to start an encrypted conversation", nickname))
+		}
+		s.sendDMRequest(ch.ID, target.ID, sess, !targetHasKey, msg.AllowUnencrypted)
+		s.requestDMKeys(ch.ID)
+	}
+	return nil
+}
+
+// sendDMPending tells the initiator that a DM is waiting for the other party
+func (s *Server) sendDMPending(sess *Session, channelID int64, target *database.User) {
+	targetID := uint64(target.ID)
+	msg := &protocol.DMPendingMessage{
+		DMChannelID:        uint64(channelID),
+		WaitingForUserID:   &targetID,
+		WaitingForNickname: target.Nickname,
+		Reason:             "Waiting for encryption keys to be set up",
+	}
+	if err := s.sendMessage(sess, protocol.TypeDMPending, msg); err != nil {
+		log.Printf("Failed to send DM_PENDING to session %d: %v", sess.ID, err)
+	}
+}
+
+// handleProvidePublicKey handles PROVIDE_PUBLIC_KEY message (registered users only)
+func (s *Server) handleProvidePublicKey(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ProvidePublicKeyMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Encryption keys require a registered account")
+	}
+
+	if msg.KeyType > protocol.KeyTypeEphemeral {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, "Invalid key type")
+	}
+	if _, err := protocol.ParseEncryptionPublicKey(msg.PublicKey); err != nil {
+		return s.sendError(sess, protocol.ErrCodeEncryptionError, "Invalid public key")
+	}
+
+	var label *string
+	if msg.Label != "" {
+		label = &msg.Label
+	}
+	keyID, err := s.db.AddEncryptionKey(*userID, msg.KeyType, msg.PublicKey, label)
+	if err != nil {
+		return s.dbError(sess, "AddEncryptionKey", err)
+	}
+
+	sess.mu.Lock()
+	sess.EncryptionKeyID = &keyID
+	sess.mu.Unlock()
+
+	log.Printf("Session %d: provided encryption key %d (type %d)", sess.ID, keyID, msg.KeyType)
+
+	// The new key may complete pending DMs, or make this session the one that can wrap keys
+	for _, ch := range s.db.ListUserDMChannels(*userID) {
+		if ch.IsEncrypted {
+			s.requestDMKeys(ch.ID)
+		}
+	}
+	return nil
+}
+
+// handleAllowUnencrypted handles ALLOW_UNENCRYPTED message
+func (s *Server) handleAllowUnencrypted(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.AllowUnencryptedMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Direct messages require a registered account")
+	}
+
+	if msg.Permanent {
+		if err := s.db.SetAllowUnencryptedDMs(*userID, true); err != nil {
+			return s.dbError(sess, "SetAllowUnencryptedDMs", err)
+		}
+	}
+
+	// dm_channel_id 0 only updates the permanent preference
+	if msg.DMChannelID == 0 {
+		return nil
+	}
+
+	channelID := int64(msg.DMChannelID)
+	if !s.db.IsChannelParticipant(channelID, *userID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "You are not a participant in this conversation")
+	}
+
+	pending, initiatorAllowsUnencrypted := s.dmPending(channelID)
+	if !pending {
+		// Already set up; an established encrypted DM is never downgraded
+		return nil
+	}
+	if !initiatorAllowsUnencrypted {
+		return s.sendError(sess, protocol.ErrCodeEncryptionError, "The other participant requires an encrypted conversation")
+	}
+
+	if err := s.db.SetChannelEncrypted(channelID, false); err != nil {
+		return s.dbError(sess, "SetChannelEncrypted", err)
+	}
+	s.clearDMPending(channelID)
+
+	log.Printf("Session %d: accepted unencrypted DM %d", sess.ID, channelID)
+
+	for _, participantID := range s.db.ListChannelParticipants(channelID) {
+		s.sendDMReady(channelID, participantID)
+	}
+	return nil
+}
+
+// handleProvideChannelKeys handles PROVIDE_CHANNEL_KEYS message (reply to DM_KEY_EXCHANGE)
+func (s *Server) handleProvideChannelKeys(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ProvideChannelKeysMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	keyID := sess.EncryptionKeyID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Direct messages require a registered account")
+	}
+
+	channelID := int64(msg.ChannelID)
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil || !ch.IsPrivate || !ch.IsEncrypted {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Encrypted direct message not found")
+	}
+	if !s.db.IsChannelParticipant(channelID, *userID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "You are not a participant in this conversation")
+	}
+
+	wrapped, err := s.db.ListChannelKeys(channelID)
+	if err != nil {
+		return s.dbError(sess, "ListChannelKeys", err)
+	}
+
+	// Only a session that holds the key may hand it out (the creator, for a new channel)
+	holder := keyID != nil
+	if holder && len(wrapped) == 0 {
+		holder = ch.CreatedBy != nil && *ch.CreatedBy == *userID
+	} else if holder {
+		_, holder = wrapped[*keyID]
+	}
+	if !holder {
+		return s.sendError(sess, protocol.ErrCodePermissionDenied, "You do not hold the key for this conversation")
+	}
+
+	// Keys may only be wrapped for participants, and existing wraps are never replaced
+	keyOwners := make(map[int64]int64)
+	for _, participantID := range s.db.ListChannelParticipants(channelID) {
+		for _, key := range s.userEncryptionKeys(participantID) {
+			keyOwners[key.ID] = participantID
+		}
+	}
+
+	recipients := make(map[int64]bool)
+	for _, key := range msg.Keys {
+		ownerID, ok := keyOwners[int64(key.KeyID)]
+		if !ok || key.WrappedKey == "" {
+			continue
+		}
+		if _, exists := wrapped[int64(key.KeyID)]; exists {
+			continue
+		}
+		if err := s.db.SetChannelKey(channelID, int64(key.KeyID), key.WrappedKey); err != nil {
+			return s.dbError(sess, "SetChannelKey", err)
+		}
+		wrapped[int64(key.KeyID)] = key.WrappedKey
+		recipients[ownerID] = true
+	}
+
+	if pending, _ := s.dmPending(channelID); pending {
+		if !s.dmKeysReady(channelID) {
+			return nil
+		}
+		// Everyone can read the conversation now
+		s.clearDMPending(channelID)
+		for _, participantID := range s.db.ListChannelParticipants(channelID) {
+			s.sendDMReady(channelID, participantID)
+		}
+		return nil
+	}
+
+	for participantID := range recipients {
+		s.sendDMReady(channelID, participantID)
+	}
+	return nil
+}
+
+// handleAddDMParticipant handles ADD_DM_PARTICIPANT message (turns a DM into a small group)
+func (s *Server) handleAddDMParticipant(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.AddDMParticipantMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return s.sendError(sess, protocol.ErrCodeAuthRequired, "Direct messages require a registered account")
+	}
+
+	channelID := int64(msg.ChannelID)
+	ch, err := s.db.GetChannel(channelID)
+	if err != nil || !ch.IsPrivate {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Direct message not found")
+	}
+	if !s.db.IsChannelParticipant(channelID, *userID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "You are not a participant in this conversation")
+	}
+
+	target, errMsg := s.resolveDMTarget(msg.TargetType, msg.TargetID, msg.TargetNickname)
+	if target == nil {
+		return s.sendError(sess, protocol.ErrCodeUserNotFound, errMsg)
+	}
+	if s.db.IsChannelParticipant(channelID, target.ID) {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, fmt.Sprintf("%s is already in this conversation", target.Nickname))
+	}
+
+	participants := s.db.ListChannelParticipants(channelID)
+	if len(participants) >= maxDMParticipants {
+		return s.sendError(sess, protocol.ErrCodeInvalidInput, fmt.Sprintf("Group DMs are limited to %d participants", maxDMParticipants))
+	}
+
+	if err := s.db.AddChannelParticipant(channelID, target.ID); err != nil {
+		return s.dbError(sess, "AddChannelParticipant", err)
+	}
+
+	log.Printf("Session %d: added user %d (%s) to DM %d", sess.ID, target.ID, target.Nickname, channelID)
+
+	if !ch.IsEncrypted {
+		s.sendDMRequest(channelID, target.ID, sess, false, true)
+
+		// Everyone's view of the conversation name changed, including the new participant's
+		for _, participantID := range append(participants, target.ID) {
+			s.sendDMReady(channelID, participantID)
+		}
+		return nil
+	}
+
+	// The new participant gets DM_READY once the channel key has been wrapped for them
+	targetHasKey := s.hasEncryptionKey(target.ID)
+	if !targetHasKey {
+		id := uint64(channelID)
+		s.sendKeyRequired(target.ID, &id, "Provide an encryption key to join this encrypted conversation")
+	}
+	s.sendDMRequest(channelID, target.ID, sess, !targetHasKey, false)
+	s.requestDMKeys(channelID)
+
+	for _, participantID := range participants {
+		s.sendDMReady(channelID, participantID)
+	}
+	return nil
+}
+
+// handleListDMs handles LIST_DMS message
+func (s *Server) handleListDMs(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.ListDMsMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	// Anonymous users have no persistent DMs
+	resp := &protocol.DMListMessage{DMs: []protocol.DMChannel{}}
+	if userID == nil {
+		return s.sendMessage(sess, protocol.TypeDMList, resp)
+	}
+
+	for _, ch := range s.db.ListUserDMChannels(*userID) {
+		dm := protocol.DMChannel{
+			ChannelID:    uint64(ch.ID),
+			IsEncrypted:  ch.IsEncrypted,
+			Participants: []protocol.DMParticipant{},
+		}
+		if ch.IsEncrypted {
+			dm.ChannelKey = s.sessionChannelKey(sess, ch.ID)
+		}
+		for _, participantID := range s.db.ListChannelParticipants(ch.ID) {
+			if participantID == *userID {
+				continue
+			}
+			user, err := s.db.GetUserByID(participantID)
+			if err != nil {
+				continue
+			}
+			dm.Participants = append(dm.Participants, protocol.DMParticipant{
+				UserID:   uint64(user.ID),
+				Nickname: user.Nickname,
+			})
+		}
+		resp.DMs = append(resp.DMs, dm)
+	}
+
+	return s.sendMessage(sess, protocol.TypeDMList, resp)
+}
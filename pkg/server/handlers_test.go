@@ -2,11 +2,15 @@ package server
 
 import (
 	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,6 +54,10 @@ func testServer(t *testing.T) (*Server, *database.DB) {
 		sessions: sessions,
 		config:   cfg,
 		metrics:  nil, // Skip metrics in tests
+
+		pendingDMs:     make(map[int64]bool),
+		messageLimiter: newMessageRateLimiter(cfg.MessageRateLimit),
+		typing:         newTypingTracker(),
 	}
 
 	return srv, db
@@ -445,6 +453,133 @@ func TestHandlePostMessage(t *testing.T) {
 	})
 }
 
+func TestMessageRateLimit(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	reloadMemDB(t, srv, db)
+	srv.messageLimiter = newMessageRateLimiter(3)
+
+	post := func(sess *Session) *protocol.ErrorMessage {
+		t.Helper()
+		frame, err := encodePostMessageMessage(&protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "spam"})
+		if err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+		if err := srv.handlePostMessage(sess, frame); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		frames := readFrames(t, sess)
+		if errFrame := findFrame(frames, protocol.TypeError); errFrame != nil {
+			errMsg := &protocol.ErrorMessage{}
+			if err := errMsg.Decode(errFrame.Payload); err != nil {
+				t.Fatalf("Failed to decode ERROR: %v", err)
+			}
+			return errMsg
+		}
+		return nil
+	}
+
+	t.Run("anonymous session is limited", func(t *testing.T) {
+		sess := testSession(srv)
+		srv.sessions.UpdateNickname(sess.ID, "flooder")
+
+		for i := 0; i < 3; i++ {
+			if errMsg := post(sess); errMsg != nil {
+				t.Fatalf("Post %d should succeed, got error %d: %s", i+1, errMsg.ErrorCode, errMsg.Message)
+			}
+		}
+
+		errMsg := post(sess)
+		if errMsg == nil || errMsg.ErrorCode != protocol.ErrCodeMessageRateLimit {
+			t.Fatalf("Expected ERROR %d, got %+v", protocol.ErrCodeMessageRateLimit, errMsg)
+		}
+		if errMsg.RetryAfterMs == nil || *errMsg.RetryAfterMs == 0 || *errMsg.RetryAfterMs > 20000 {
+			t.Errorf("Expected retry-after hint of at most 20s, got %v", errMsg.RetryAfterMs)
+		}
+	})
+
+	t.Run("registered user is limited across sessions", func(t *testing.T) {
+		userID := int64(42)
+		first := testSession(srv)
+		second := testSession(srv)
+		for _, sess := range []*Session{first, second} {
+			sess.mu.Lock()
+			sess.UserID = &userID
+			sess.Nickname = "alice"
+			sess.mu.Unlock()
+		}
+
+		for _, sess := range []*Session{first, second, first} {
+			if errMsg := post(sess); errMsg != nil {
+				t.Fatalf("Post should succeed, got error %d: %s", errMsg.ErrorCode, errMsg.Message)
+			}
+		}
+		if errMsg := post(second); errMsg == nil || errMsg.ErrorCode != protocol.ErrCodeMessageRateLimit {
+			t.Fatalf("Expected ERROR %d from the shared user bucket, got %+v", protocol.ErrCodeMessageRateLimit, errMsg)
+		}
+	})
+}
+
+func TestChannelCreateQuota(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	userID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+	srv.config.MaxChannelCreates = 2
+
+	sess := testSession(srv)
+	sess.Nickname = "alice"
+	sess.UserID = &userID
+
+	create := func(name string) *protocol.ChannelCreatedMessage {
+		t.Helper()
+		frame := dmFrame(t, protocol.TypeCreateChannel, &protocol.CreateChannelMessage{
+			Name:           name,
+			DisplayName:    "#" + name,
+			ChannelType:    1,
+			RetentionHours: 168,
+		})
+		if err := srv.handleCreateChannel(sess, frame); err != nil {
+			t.Fatalf("handleCreateChannel failed: %v", err)
+		}
+		resp := &protocol.ChannelCreatedMessage{}
+		if decodeFrame(t, readFrames(t, sess), protocol.TypeChannelCreated, resp) == nil {
+			t.Fatal("Expected CHANNEL_CREATED")
+		}
+		return resp
+	}
+
+	if resp := create("first"); !resp.Success {
+		t.Fatalf("First channel should be created: %s", resp.Message)
+	}
+	if resp := create("second"); !resp.Success {
+		t.Fatalf("Second channel should be created: %s", resp.Message)
+	}
+
+	resp := create("third")
+	if resp.Success || !strings.Contains(resp.Message, "limit") {
+		t.Fatalf("Expected channel creation limit, got %+v", resp)
+	}
+
+	// The quota frees up an hour after the oldest creation
+	allowed, _ := srv.reserveChannelCreate(userID, time.Now().Add(channelCreateWindow+time.Second))
+	if !allowed {
+		t.Error("Expected quota to reset after an hour")
+	}
+
+	// Admins are exempt
+	srv.config.AdminUsers = []string{"alice"}
+	if resp := create("fourth"); !resp.Success {
+		t.Fatalf("Admin should not be limited: %s", resp.Message)
+	}
+}
+
 func TestHandleDeleteMessage(t *testing.T) {
 	srv, db := testServer(t)
 	defer db.Close()
@@ -644,6 +779,435 @@ func TestConvertDBMessageToProtocol(t *testing.T) {
 	}
 }
 
+func TestHandleSearchMessages(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	channelID := createTestChannel(t, db, "general", "General")
+	reloadMemDB(t, srv, db)
+
+	dm, err := srv.db.CreateDMChannel(aliceID, []int64{aliceID, bobID}, false)
+	if err != nil {
+		t.Fatalf("Failed to create DM: %v", err)
+	}
+
+	post := func(channelID int64, parentID, authorUserID *int64, nickname, content string) int64 {
+		t.Helper()
+		id, _, err := srv.db.PostMessage(channelID, nil, parentID, authorUserID, nickname, content)
+		if err != nil {
+			t.Fatalf("Failed to post message: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+		return id
+	}
+	rootID := post(channelID, nil, &aliceID, "alice", "Deploy is scheduled for friday")
+	replyID := post(channelID, &rootID, nil, "guest", "Can we deploy sooner?")
+	post(dm.ID, nil, &aliceID, "alice", "Private deploy notes")
+
+	anon := testSession(srv)
+	alice := testSession(srv)
+	alice.Nickname = "alice"
+	alice.UserID = &aliceID
+
+	var lastThreads []protocol.Message
+	search := func(sess *Session, msg *protocol.SearchMessagesMessage) []protocol.SearchResult {
+		t.Helper()
+		if err := srv.handleSearchMessages(sess, dmFrame(t, protocol.TypeSearchMessages, msg)); err != nil {
+			t.Fatalf("handleSearchMessages failed: %v", err)
+		}
+		resp := &protocol.SearchResultsMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeSearchResults, resp)
+		lastThreads = resp.Threads
+		return resp.Results
+	}
+	expectError := func(sess *Session, msg *protocol.SearchMessagesMessage, code uint16) {
+		t.Helper()
+		if err := srv.handleSearchMessages(sess, dmFrame(t, protocol.TypeSearchMessages, msg)); err != nil {
+			t.Fatalf("handleSearchMessages failed: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != code {
+			t.Fatalf("Expected error %d, got %d (%s)", code, errMsg.ErrorCode, errMsg.Message)
+		}
+	}
+
+	t.Run("public results point at their thread", func(t *testing.T) {
+		results := search(anon, &protocol.SearchMessagesMessage{Query: "deploy"})
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(results))
+		}
+		if results[0].Message.ID != uint64(replyID) || results[0].ThreadRootID != uint64(rootID) {
+			t.Errorf("Expected newest reply first with its thread root, got %+v", results[0])
+		}
+		if results[1].Message.ID != uint64(rootID) || results[1].ThreadRootID != uint64(rootID) {
+			t.Errorf("Expected root message second, got %+v", results[1])
+		}
+		if len(lastThreads) != 1 || lastThreads[0].ID != uint64(rootID) {
+			t.Errorf("Expected the reply's thread root to be included, got %+v", lastThreads)
+		}
+	})
+
+	t.Run("participants also see their DMs", func(t *testing.T) {
+		if results := search(alice, &protocol.SearchMessagesMessage{Query: "deploy"}); len(results) != 3 {
+			t.Fatalf("Expected 3 results, got %d", len(results))
+		}
+		dmChannelID := uint64(dm.ID)
+		results := search(alice, &protocol.SearchMessagesMessage{Query: "deploy", ChannelID: &dmChannelID})
+		if len(results) != 1 || results[0].Message.ChannelID != dmChannelID {
+			t.Fatalf("Expected only the DM result, got %+v", results)
+		}
+		expectError(anon, &protocol.SearchMessagesMessage{Query: "deploy", ChannelID: &dmChannelID}, protocol.ErrCodeChannelPrivate)
+	})
+
+	t.Run("author filter", func(t *testing.T) {
+		for author, want := range map[string]uint64{"~guest": uint64(replyID), "alice": uint64(rootID)} {
+			author := author
+			results := search(anon, &protocol.SearchMessagesMessage{Query: "deploy", Author: &author})
+			if len(results) != 1 || results[0].Message.ID != want {
+				t.Errorf("Author %q: expected message %d, got %+v", author, want, results)
+			}
+		}
+	})
+
+	t.Run("thread and date filters", func(t *testing.T) {
+		// Searching from a reply narrows to its whole thread
+		threadID := uint64(replyID)
+		if results := search(anon, &protocol.SearchMessagesMessage{Query: "deploy", ThreadID: &threadID}); len(results) != 2 {
+			t.Fatalf("Expected 2 thread results, got %d", len(results))
+		}
+
+		future := time.Now().Add(time.Hour)
+		if results := search(anon, &protocol.SearchMessagesMessage{Query: "deploy", After: &future}); len(results) != 0 {
+			t.Fatalf("Expected no results after %v, got %d", future, len(results))
+		}
+
+		limit := uint16(1)
+		if results := search(anon, &protocol.SearchMessagesMessage{Query: "deploy", Limit: limit}); len(results) != 1 {
+			t.Fatalf("Expected limit to apply, got %d", len(results))
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		expectError(anon, &protocol.SearchMessagesMessage{Query: "   "}, protocol.ErrCodeInvalidInput)
+		expectError(anon, &protocol.SearchMessagesMessage{Query: strings.Repeat("a", maxSearchQueryLength+1)}, protocol.ErrCodeInvalidInput)
+
+		missing := uint64(999999)
+		expectError(anon, &protocol.SearchMessagesMessage{Query: "deploy", ChannelID: &missing}, protocol.ErrCodeChannelNotFound)
+		expectError(anon, &protocol.SearchMessagesMessage{Query: "deploy", ThreadID: &missing}, protocol.ErrCodeThreadNotFound)
+	})
+}
+
+func TestHandleReactions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	carolID, err := db.CreateUser("carol", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	channelID := createTestChannel(t, db, "general", "General")
+	reloadMemDB(t, srv, db)
+
+	rootID, _, err := srv.db.PostMessage(channelID, nil, nil, &aliceID, "alice", "Ship it?")
+	if err != nil {
+		t.Fatalf("Failed to post message: %v", err)
+	}
+	replyID, _, err := srv.db.PostMessage(channelID, nil, &rootID, &bobID, "bob", "+1")
+	if err != nil {
+		t.Fatalf("Failed to post reply: %v", err)
+	}
+	dm, err := srv.db.CreateDMChannel(aliceID, []int64{aliceID, bobID}, false)
+	if err != nil {
+		t.Fatalf("Failed to create DM: %v", err)
+	}
+	dmMsgID, _, err := srv.db.PostMessage(dm.ID, nil, nil, &aliceID, "alice", "Secret")
+	if err != nil {
+		t.Fatalf("Failed to post DM: %v", err)
+	}
+
+	newUserSession := func(nickname string, userID *int64) *Session {
+		sess := testSession(srv)
+		sess.Nickname = nickname
+		sess.UserID = userID
+		return sess
+	}
+	alice := newUserSession("alice", &aliceID)
+	bob := newUserSession("bob", &bobID)
+	carol := newUserSession("carol", &carolID)
+	anon := newUserSession("guest", nil)
+
+	// Bob has the channel open; carol only follows the thread
+	srv.sessions.SetJoinedChannel(bob.ID, &channelID)
+	srv.sessions.SubscribeToThread(carol, uint64(rootID), ChannelSubscription{ChannelID: uint64(channelID)})
+
+	react := func(sess *Session, messageID int64, emoji string, add bool) {
+		t.Helper()
+		var err error
+		if add {
+			err = srv.handleAddReaction(sess, dmFrame(t, protocol.TypeAddReaction, &protocol.AddReactionMessage{MessageID: uint64(messageID), Emoji: emoji}))
+		} else {
+			err = srv.handleRemoveReaction(sess, dmFrame(t, protocol.TypeRemoveReaction, &protocol.RemoveReactionMessage{MessageID: uint64(messageID), Emoji: emoji}))
+		}
+		if err != nil {
+			t.Fatalf("Reaction handler failed: %v", err)
+		}
+	}
+	expectError := func(sess *Session, messageID int64, emoji string, code uint16) {
+		t.Helper()
+		react(sess, messageID, emoji, true)
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != code {
+			t.Fatalf("Expected error %d, got %d (%s)", code, errMsg.ErrorCode, errMsg.Message)
+		}
+	}
+
+	t.Run("reactions reach the channel and thread subscribers", func(t *testing.T) {
+		react(alice, replyID, "👍", true)
+
+		for name, sess := range map[string]*Session{"alice": alice, "bob": bob, "carol": carol} {
+			update := &protocol.ReactionsUpdatedMessage{}
+			decodeFrame(t, readFrames(t, sess), protocol.TypeReactionsUpdated, update)
+			if update.MessageID != uint64(replyID) || len(update.Reactions) != 1 ||
+				update.Reactions[0].Emoji != "👍" || len(update.Reactions[0].UserIDs) != 1 || update.Reactions[0].UserIDs[0] != uint64(aliceID) {
+				t.Errorf("%s: unexpected update %+v", name, update)
+			}
+		}
+		if frames := readFrames(t, anon); len(frames) != 0 {
+			t.Errorf("Expected no frames for a session outside the channel, got %d", len(frames))
+		}
+	})
+
+	t.Run("repeating a reaction only answers the sender", func(t *testing.T) {
+		react(alice, replyID, "👍", true)
+		update := &protocol.ReactionsUpdatedMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeReactionsUpdated, update)
+		if len(update.Reactions) != 1 {
+			t.Errorf("Expected reactions to be unchanged, got %+v", update.Reactions)
+		}
+		if frames := readFrames(t, bob); len(frames) != 0 {
+			t.Errorf("Expected no broadcast for a no-op, got %d frames", len(frames))
+		}
+	})
+
+	t.Run("reactions are included in message lists", func(t *testing.T) {
+		parentID := uint64(rootID)
+		if err := srv.handleListMessages(bob, dmFrame(t, protocol.TypeListMessages, &protocol.ListMessagesMessage{
+			ChannelID: uint64(channelID),
+			ParentID:  &parentID,
+			Limit:     50,
+		})); err != nil {
+			t.Fatalf("handleListMessages failed: %v", err)
+		}
+		list := &protocol.MessageListMessage{}
+		decodeFrame(t, readFrames(t, bob), protocol.TypeMessageList, list)
+		if len(list.Messages) != 1 || len(list.Messages[0].Reactions) != 1 || list.Messages[0].Reactions[0].Emoji != "👍" {
+			t.Errorf("Expected the reply's reactions in MESSAGE_LIST, got %+v", list.Messages)
+		}
+	})
+
+	t.Run("removing a reaction", func(t *testing.T) {
+		react(alice, replyID, "👍", false)
+		update := &protocol.ReactionsUpdatedMessage{}
+		decodeFrame(t, readFrames(t, bob), protocol.TypeReactionsUpdated, update)
+		if len(update.Reactions) != 0 {
+			t.Errorf("Expected no reactions left, got %+v", update.Reactions)
+		}
+		readFrames(t, alice)
+		readFrames(t, carol)
+	})
+
+	t.Run("invalid reactions", func(t *testing.T) {
+		expectError(anon, rootID, "👍", protocol.ErrCodeAuthRequired)
+		expectError(alice, rootID, "", protocol.ErrCodeInvalidInput)
+		expectError(alice, rootID, "thumbs up", protocol.ErrCodeInvalidInput)
+		expectError(alice, rootID, strings.Repeat("👍", maxReactionLength), protocol.ErrCodeInvalidInput)
+		expectError(alice, 999999, "👍", protocol.ErrCodeMessageNotFound)
+		// DMs the user isn't part of are invisible
+		expectError(carol, dmMsgID, "👍", protocol.ErrCodeMessageNotFound)
+	})
+}
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"hey @alice, look", []string{"alice"}},
+		{"@alice @bob @alice", []string{"alice", "bob"}},
+		{"(@carol_1) and @dave-x.", []string{"carol_1", "dave-x"}},
+		{"mail me at bob@example.com", nil},
+		{"@@alice or @al or @" + strings.Repeat("a", 21), nil},
+		{"no mentions here", nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseMentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentions(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestHandleMentions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	carolID, err := db.CreateUser("carol", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	channelID := createTestChannel(t, db, "general", "General")
+	reloadMemDB(t, srv, db)
+
+	newUserSession := func(nickname string, userID *int64) *Session {
+		sess := testSession(srv)
+		sess.Nickname = nickname
+		sess.UserID = userID
+		return sess
+	}
+	alice := newUserSession("alice", &aliceID)
+	bob := newUserSession("bob", &bobID)
+	carol := newUserSession("carol", &carolID)
+	anon := newUserSession("guest", nil)
+
+	post := func(sess *Session, channelID int64, parentID *uint64, content string) uint64 {
+		t.Helper()
+		if err := srv.handlePostMessage(sess, dmFrame(t, protocol.TypePostMessage, &protocol.PostMessageMessage{
+			ChannelID: uint64(channelID),
+			ParentID:  parentID,
+			Content:   content,
+		})); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		posted := &protocol.MessagePostedMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeMessagePosted, posted)
+		return posted.MessageID
+	}
+	listMentions := func(sess *Session, unreadOnly bool) *protocol.MentionListMessage {
+		t.Helper()
+		if err := srv.handleListMentions(sess, dmFrame(t, protocol.TypeListMentions, &protocol.ListMentionsMessage{UnreadOnly: unreadOnly})); err != nil {
+			t.Fatalf("handleListMentions failed: %v", err)
+		}
+		list := &protocol.MentionListMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeMentionList, list)
+		return list
+	}
+
+	rootID := post(alice, channelID, nil, "Release notes draft")
+	readFrames(t, bob)
+
+	// Bob isn't in the channel, but still hears about the mention in a reply
+	replyID := post(carol, channelID, &rootID, "@bob can you review? cc @alice @nobody")
+
+	notification := &protocol.MentionNotificationMessage{}
+	decodeFrame(t, readFrames(t, bob), protocol.TypeMentionNotification, notification)
+	if notification.Mention.Message.ID != replyID || notification.Mention.ThreadRootID != rootID || notification.UnreadCount != 1 {
+		t.Fatalf("Unexpected notification %+v", notification)
+	}
+	if findFrame(readFrames(t, alice), protocol.TypeMentionNotification) == nil {
+		t.Error("Expected alice to be notified too")
+	}
+	if frames := readFrames(t, anon); findFrame(frames, protocol.TypeMentionNotification) != nil {
+		t.Error("Expected no mention notification for an unmentioned session")
+	}
+
+	// Mentioning yourself doesn't count
+	post(bob, channelID, &rootID, "Note to self, @bob: review")
+	readFrames(t, alice)
+	readFrames(t, carol)
+
+	list := listMentions(bob, false)
+	if len(list.Mentions) != 1 || list.Mentions[0].Message.ID != replyID || list.Mentions[0].Read || list.UnreadCount != 1 {
+		t.Fatalf("Unexpected mention list %+v", list)
+	}
+	if len(list.Threads) != 1 || list.Threads[0].ID != rootID {
+		t.Fatalf("Expected the thread root to be included, got %+v", list.Threads)
+	}
+
+	t.Run("mark read", func(t *testing.T) {
+		if err := srv.handleMarkMentionsRead(bob, dmFrame(t, protocol.TypeMarkMentionsRead, &protocol.MarkMentionsReadMessage{
+			MessageIDs: []uint64{replyID},
+		})); err != nil {
+			t.Fatalf("handleMarkMentionsRead failed: %v", err)
+		}
+		list := listMentions(bob, false)
+		if len(list.Mentions) != 1 || !list.Mentions[0].Read || list.UnreadCount != 0 {
+			t.Fatalf("Expected the mention to be read, got %+v", list)
+		}
+		if unread := listMentions(bob, true); len(unread.Mentions) != 0 {
+			t.Fatalf("Expected no unread mentions, got %+v", unread.Mentions)
+		}
+	})
+
+	t.Run("reading the channel marks mentions read", func(t *testing.T) {
+		if list := listMentions(alice, true); len(list.Mentions) != 1 {
+			t.Fatalf("Expected alice to have an unread mention, got %+v", list.Mentions)
+		}
+		if err := srv.handleUpdateReadState(alice, dmFrame(t, protocol.TypeUpdateReadState, &protocol.UpdateReadStateMessage{
+			ChannelID: uint64(channelID),
+			Timestamp: time.Now().Add(time.Minute).UnixMilli(),
+		})); err != nil {
+			t.Fatalf("handleUpdateReadState failed: %v", err)
+		}
+		if list := listMentions(alice, true); len(list.Mentions) != 0 || list.UnreadCount != 0 {
+			t.Fatalf("Expected alice's mention to be read, got %+v", list)
+		}
+	})
+
+	t.Run("private channels only notify participants", func(t *testing.T) {
+		dm, err := srv.db.CreateDMChannel(aliceID, []int64{aliceID, bobID}, false)
+		if err != nil {
+			t.Fatalf("Failed to create DM: %v", err)
+		}
+		post(alice, dm.ID, nil, "@bob @carol secret plans")
+		if findFrame(readFrames(t, bob), protocol.TypeMentionNotification) == nil {
+			t.Error("Expected bob to be notified of a DM mention")
+		}
+		if findFrame(readFrames(t, carol), protocol.TypeMentionNotification) != nil {
+			t.Error("Expected no notification for a user outside the DM")
+		}
+		if list := listMentions(carol, false); len(list.Mentions) != 0 {
+			t.Errorf("Expected carol to have no mentions, got %+v", list.Mentions)
+		}
+	})
+
+	t.Run("anonymous users have no inbox", func(t *testing.T) {
+		if err := srv.handleListMentions(anon, dmFrame(t, protocol.TypeListMentions, &protocol.ListMentionsMessage{})); err != nil {
+			t.Fatalf("handleListMentions failed: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, anon), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodeAuthRequired {
+			t.Errorf("Expected error %d, got %d", protocol.ErrCodeAuthRequired, errMsg.ErrorCode)
+		}
+	})
+}
+
 // encodeSubscribeThreadMessage helper
 func encodeSubscribeThreadMessage(msg *protocol.SubscribeThreadMessage) (*protocol.Frame, error) {
 	var buf bytes.Buffer
@@ -1122,6 +1686,67 @@ func TestHandleLeaveChannel(t *testing.T) {
 	})
 }
 
+func TestHandleSetCompression(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	sess := testSession(srv)
+	mockConn := sess.Conn.conn.(*mockConn)
+	large := &protocol.ErrorMessage{ErrorCode: 1000, Message: strings.Repeat("compress me ", 100)}
+
+	t.Run("server config advertises compression", func(t *testing.T) {
+		if err := srv.sendServerConfig(sess); err != nil {
+			t.Fatalf("sendServerConfig failed: %v", err)
+		}
+		config := &protocol.ServerConfigMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeServerConfig, config)
+		if !config.CompressionSupported {
+			t.Error("Expected CompressionSupported in SERVER_CONFIG")
+		}
+	})
+
+	t.Run("frames are uncompressed by default", func(t *testing.T) {
+		if err := srv.sendMessage(sess, protocol.TypeError, large); err != nil {
+			t.Fatalf("sendMessage failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, sess), protocol.TypeError)
+		if frame == nil || frame.Flags&protocol.FlagCompressed != 0 {
+			t.Fatal("Expected an uncompressed ERROR frame")
+		}
+	})
+
+	t.Run("large frames are compressed once enabled", func(t *testing.T) {
+		if err := srv.handleSetCompression(sess, dmFrame(t, protocol.TypeSetCompression, &protocol.SetCompressionMessage{Enabled: true})); err != nil {
+			t.Fatalf("handleSetCompression failed: %v", err)
+		}
+		if err := srv.sendMessage(sess, protocol.TypeError, large); err != nil {
+			t.Fatalf("sendMessage failed: %v", err)
+		}
+		if mockConn.writeBuf.Len() >= len(large.Message) {
+			t.Errorf("Expected compressed frame on the wire, got %d bytes", mockConn.writeBuf.Len())
+		}
+
+		decoded := &protocol.ErrorMessage{}
+		frame := decodeFrame(t, readFrames(t, sess), protocol.TypeError, decoded)
+		if frame.Flags&protocol.FlagCompressed == 0 {
+			t.Error("Expected FlagCompressed to be set")
+		}
+		if decoded.Message != large.Message {
+			t.Error("Decompressed message does not match")
+		}
+	})
+
+	t.Run("small frames stay uncompressed", func(t *testing.T) {
+		if err := srv.sendMessage(sess, protocol.TypePong, &protocol.PongMessage{ClientTimestamp: 1}); err != nil {
+			t.Fatalf("sendMessage failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, sess), protocol.TypePong)
+		if frame == nil || frame.Flags&protocol.FlagCompressed != 0 {
+			t.Fatal("Expected an uncompressed PONG frame")
+		}
+	})
+}
+
 func TestHandleDisconnect(t *testing.T) {
 	srv, db := testServer(t)
 	defer db.Close()
@@ -1516,3 +2141,1390 @@ func hashPasswordForTest(password, nickname string) string {
 func verifyPasswordHash(storedHash, clientHash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(clientHash))
 }
+
+// readSubchannelCreated decodes the last SUBCHANNEL_CREATED frame written to a test session
+func readSubchannelCreated(t *testing.T, sess *Session) *protocol.SubchannelCreatedMessage {
+	t.Helper()
+	mockConn := sess.Conn.conn.(*mockConn)
+	var resp *protocol.SubchannelCreatedMessage
+	for mockConn.writeBuf.Len() > 0 {
+		frame, err := protocol.DecodeFrame(mockConn.writeBuf)
+		if err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+		if frame.Type != protocol.TypeSubchannelCreated {
+			continue
+		}
+		resp = &protocol.SubchannelCreatedMessage{}
+		if err := resp.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode SUBCHANNEL_CREATED: %v", err)
+		}
+	}
+	if resp == nil {
+		t.Fatal("Session did not receive SUBCHANNEL_CREATED")
+	}
+	return resp
+}
+
+func TestHandleCreateSubchannel(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	ownerID, err := db.CreateUser("owner", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	otherID, err := db.CreateUser("other", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	channelID, err := db.CreateChannel("general", "#general", nil, 1, 168, &ownerID)
+	if err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	send := func(sess *Session, msg *protocol.CreateSubchannelMessage) *protocol.SubchannelCreatedMessage {
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeCreateSubchannel, Payload: payload}
+		if err := srv.handleCreateSubchannel(sess, frame); err != nil {
+			t.Fatalf("handleCreateSubchannel failed: %v", err)
+		}
+		return readSubchannelCreated(t, sess)
+	}
+
+	valid := &protocol.CreateSubchannelMessage{
+		ChannelID:      uint64(channelID),
+		Name:           "support",
+		DisplayName:    "/support",
+		SubchannelType: 0,
+		RetentionHours: 24,
+	}
+
+	t.Run("anonymous users cannot create subchannels", func(t *testing.T) {
+		sess := testSession(srv)
+		resp := send(sess, valid)
+		if resp.Success {
+			t.Error("Expected anonymous create to fail")
+		}
+	})
+
+	t.Run("only the channel creator can create subchannels", func(t *testing.T) {
+		sess := testSession(srv)
+		sess.UserID = &otherID
+		resp := send(sess, valid)
+		if resp.Success {
+			t.Error("Expected create by non-owner to fail")
+		}
+	})
+
+	t.Run("owner creates subchannel and others are notified", func(t *testing.T) {
+		owner := testSession(srv)
+		owner.UserID = &ownerID
+		observer := testSession(srv)
+
+		resp := send(owner, valid)
+		if !resp.Success {
+			t.Fatalf("Expected create to succeed, got: %s", resp.Message)
+		}
+		if resp.ChannelID != uint64(channelID) || resp.Type != 0 || resp.RetentionHours != 24 {
+			t.Errorf("Unexpected response: %+v", resp)
+		}
+
+		broadcast := readSubchannelCreated(t, observer)
+		if broadcast.SubchannelID != resp.SubchannelID {
+			t.Errorf("Expected broadcast for subchannel %d, got %d", resp.SubchannelID, broadcast.SubchannelID)
+		}
+
+		exists, _ := srv.db.SubchannelExists(int64(resp.SubchannelID))
+		if !exists {
+			t.Error("Subchannel should exist after creation")
+		}
+	})
+
+	t.Run("duplicate name is rejected", func(t *testing.T) {
+		owner := testSession(srv)
+		owner.UserID = &ownerID
+		resp := send(owner, valid)
+		if resp.Success {
+			t.Error("Expected duplicate subchannel name to fail")
+		}
+	})
+
+	t.Run("invalid type is rejected", func(t *testing.T) {
+		owner := testSession(srv)
+		owner.UserID = &ownerID
+		invalid := *valid
+		invalid.Name = "other"
+		invalid.SubchannelType = 5
+		resp := send(owner, &invalid)
+		if resp.Success {
+			t.Error("Expected invalid subchannel type to fail")
+		}
+	})
+}
+
+func TestHandleGetSubchannels(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	if _, err := db.CreateSubchannel(channelID, "lounge", "/lounge", nil, 0, 24, nil); err != nil {
+		t.Fatalf("Failed to create subchannel: %v", err)
+	}
+	if _, err := db.CreateSubchannel(channelID, "support", "/support", nil, 1, 720, nil); err != nil {
+		t.Fatalf("Failed to create subchannel: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	sess := testSession(srv)
+	payload, err := (&protocol.GetSubchannelsMessage{ChannelID: uint64(channelID)}).Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypeGetSubchannels, Payload: payload}
+	if err := srv.handleGetSubchannels(sess, frame); err != nil {
+		t.Fatalf("handleGetSubchannels failed: %v", err)
+	}
+
+	mockConn := sess.Conn.conn.(*mockConn)
+	respFrame, err := protocol.DecodeFrame(mockConn.writeBuf)
+	if err != nil {
+		t.Fatalf("Failed to decode response frame: %v", err)
+	}
+	if respFrame.Type != protocol.TypeSubchannelList {
+		t.Fatalf("Expected SUBCHANNEL_LIST, got 0x%02X", respFrame.Type)
+	}
+
+	resp := &protocol.SubchannelListMessage{}
+	if err := resp.Decode(respFrame.Payload); err != nil {
+		t.Fatalf("Failed to decode SUBCHANNEL_LIST: %v", err)
+	}
+	if resp.ChannelID != uint64(channelID) || len(resp.Subchannels) != 2 {
+		t.Fatalf("Unexpected subchannel list: %+v", resp)
+	}
+	if resp.Subchannels[0].Name != "lounge" || resp.Subchannels[1].Type != 1 || resp.Subchannels[1].RetentionHours != 720 {
+		t.Errorf("Unexpected subchannels: %+v", resp.Subchannels)
+	}
+}
+
+func TestSubchannelMessaging(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	// Forum channel with a chat subchannel
+	channelID := createTestChannel(t, db, "general", "General")
+	subID, err := db.CreateSubchannel(channelID, "lounge", "/lounge", nil, 0, 24, nil)
+	if err != nil {
+		t.Fatalf("Failed to create subchannel: %v", err)
+	}
+	rootID := postTestMessage(t, db, channelID, nil, "alice", "Root thread")
+	reloadMemDB(t, srv, db)
+
+	subscriber := testSession(srv)
+	subchannelID := uint64(subID)
+	srv.sessions.SubscribeToChannel(subscriber, ChannelSubscription{
+		ChannelID:    uint64(channelID),
+		SubchannelID: &subchannelID,
+	})
+
+	t.Run("subchannel subscription matches a fresh pointer", func(t *testing.T) {
+		sameID := uint64(subID)
+		subs := srv.sessions.GetChannelSubscribers(ChannelSubscription{
+			ChannelID:    uint64(channelID),
+			SubchannelID: &sameID,
+		})
+		if len(subs) != 1 || subs[0].ID != subscriber.ID {
+			t.Fatalf("Expected subscriber to be found, got %d sessions", len(subs))
+		}
+	})
+
+	t.Run("post to subchannel reaches subchannel subscribers", func(t *testing.T) {
+		poster := testSession(srv)
+		poster.Nickname = "bob"
+		mockConn := subscriber.Conn.conn.(*mockConn)
+		mockConn.writeBuf.Reset()
+
+		msg := &protocol.PostMessageMessage{
+			ChannelID:    uint64(channelID),
+			SubchannelID: &subchannelID,
+			Content:      "hello lounge",
+		}
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePostMessage, Payload: payload}
+		if err := srv.handlePostMessage(poster, frame); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+
+		if mockConn.writeBuf.Len() == 0 {
+			t.Error("Subchannel subscriber did not receive NEW_MESSAGE")
+		}
+
+		subMsgs, _ := srv.db.ListRootMessages(channelID, &subID, 50, nil, nil)
+		if len(subMsgs) != 1 {
+			t.Errorf("Expected 1 message in subchannel, got %d", len(subMsgs))
+		}
+		rootMsgs, _ := srv.db.ListRootMessages(channelID, nil, 50, nil, nil)
+		if len(rootMsgs) != 1 {
+			t.Errorf("Expected channel root to only contain its own message, got %d", len(rootMsgs))
+		}
+	})
+
+	t.Run("chat subchannel rejects threaded replies", func(t *testing.T) {
+		poster := testSession(srv)
+		poster.Nickname = "bob"
+
+		parentID := uint64(rootID)
+		msg := &protocol.PostMessageMessage{
+			ChannelID:    uint64(channelID),
+			SubchannelID: &subchannelID,
+			ParentID:     &parentID,
+			Content:      "reply",
+		}
+		payload, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+		frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePostMessage, Payload: payload}
+		if err := srv.handlePostMessage(poster, frame); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+
+		replies, _ := srv.db.ListThreadReplies(parentID, 50, nil, nil)
+		if len(replies) != 0 {
+			t.Errorf("Expected reply to be rejected, got %d replies", len(replies))
+		}
+	})
+}
+
+// dmFrame encodes a DM-related message into a frame of the given type
+func dmFrame(t *testing.T, msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+	t.Helper()
+	payload, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	return &protocol.Frame{Version: protocol.ProtocolVersion, Type: msgType, Payload: payload}
+}
+
+// readFrames drains and returns all frames written to a session
+func readFrames(t *testing.T, sess *Session) []*protocol.Frame {
+	t.Helper()
+	mockConn := sess.Conn.conn.(*mockConn)
+	var frames []*protocol.Frame
+	for mockConn.writeBuf.Len() > 0 {
+		frame, err := protocol.DecodeFrame(mockConn.writeBuf)
+		if err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func findFrame(frames []*protocol.Frame, msgType uint8) *protocol.Frame {
+	for _, frame := range frames {
+		if frame.Type == msgType {
+			return frame
+		}
+	}
+	return nil
+}
+
+func TestDirectMessages(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	carolID, err := db.CreateUser("carol", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	createTestChannel(t, db, "general", "General")
+
+	// Nobody has encryption keys here, so DMs fall back to plaintext
+	for _, userID := range []int64{bobID, carolID} {
+		if err := db.SetAllowUnencryptedDMs(userID, true); err != nil {
+			t.Fatalf("Failed to set DM preference: %v", err)
+		}
+	}
+	reloadMemDB(t, srv, db)
+
+	alice := testSession(srv)
+	alice.Nickname = "alice"
+	alice.UserID = &aliceID
+	bob := testSession(srv)
+	bob.Nickname = "bob"
+	bob.UserID = &bobID
+	carol := testSession(srv)
+	carol.Nickname = "carol"
+	carol.UserID = &carolID
+
+	startDM := &protocol.StartDMMessage{
+		TargetType:       protocol.DMTargetNickname,
+		TargetNickname:   "bob",
+		AllowUnencrypted: true,
+	}
+
+	t.Run("anonymous users cannot start DMs", func(t *testing.T) {
+		anon := testSession(srv)
+		anon.Nickname = "guest"
+		if err := srv.handleStartDM(anon, dmFrame(t, protocol.TypeStartDM, startDM)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, anon), protocol.TypeError)
+		if frame == nil {
+			t.Fatal("Expected ERROR response")
+		}
+		errMsg := &protocol.ErrorMessage{}
+		if err := errMsg.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode ERROR: %v", err)
+		}
+		if errMsg.ErrorCode != protocol.ErrCodeAuthRequired {
+			t.Errorf("Expected error code %d, got %d", protocol.ErrCodeAuthRequired, errMsg.ErrorCode)
+		}
+	})
+
+	t.Run("unknown target is rejected", func(t *testing.T) {
+		unknown := &protocol.StartDMMessage{
+			TargetType:       protocol.DMTargetNickname,
+			TargetNickname:   "nobody",
+			AllowUnencrypted: true,
+		}
+		if err := srv.handleStartDM(alice, dmFrame(t, protocol.TypeStartDM, unknown)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, alice), protocol.TypeError)
+		if frame == nil {
+			t.Fatal("Expected ERROR response")
+		}
+		errMsg := &protocol.ErrorMessage{}
+		if err := errMsg.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode ERROR: %v", err)
+		}
+		if errMsg.ErrorCode != protocol.ErrCodeUserNotFound {
+			t.Errorf("Expected error code %d, got %d", protocol.ErrCodeUserNotFound, errMsg.ErrorCode)
+		}
+	})
+
+	var dmID uint64
+
+	t.Run("start DM notifies both users", func(t *testing.T) {
+		if err := srv.handleStartDM(alice, dmFrame(t, protocol.TypeStartDM, startDM)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+
+		frame := findFrame(readFrames(t, alice), protocol.TypeDMReady)
+		if frame == nil {
+			t.Fatal("Initiator did not receive DM_READY")
+		}
+		ready := &protocol.DMReadyMessage{}
+		if err := ready.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode DM_READY: %v", err)
+		}
+		if ready.OtherNickname != "bob" {
+			t.Errorf("Expected other nickname 'bob', got %q", ready.OtherNickname)
+		}
+		if ready.OtherUserID == nil || *ready.OtherUserID != uint64(bobID) {
+			t.Errorf("Expected other user ID %d, got %v", bobID, ready.OtherUserID)
+		}
+		dmID = ready.ChannelID
+
+		frame = findFrame(readFrames(t, bob), protocol.TypeDMRequest)
+		if frame == nil {
+			t.Fatal("Target did not receive DM_REQUEST")
+		}
+		req := &protocol.DMRequestMessage{}
+		if err := req.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode DM_REQUEST: %v", err)
+		}
+		if req.ChannelID != dmID || req.FromNickname != "alice" {
+			t.Errorf("Unexpected DM_REQUEST: %+v", req)
+		}
+	})
+
+	t.Run("starting again reuses the existing DM", func(t *testing.T) {
+		if err := srv.handleStartDM(alice, dmFrame(t, protocol.TypeStartDM, startDM)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, alice), protocol.TypeDMReady)
+		if frame == nil {
+			t.Fatal("Initiator did not receive DM_READY")
+		}
+		ready := &protocol.DMReadyMessage{}
+		if err := ready.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode DM_READY: %v", err)
+		}
+		if ready.ChannelID != dmID {
+			t.Errorf("Expected existing DM %d, got %d", dmID, ready.ChannelID)
+		}
+		readFrames(t, bob)
+	})
+
+	t.Run("DM is hidden from channel list", func(t *testing.T) {
+		channels, err := srv.db.ListChannels()
+		if err != nil {
+			t.Fatalf("ListChannels failed: %v", err)
+		}
+		for _, ch := range channels {
+			if uint64(ch.ID) == dmID {
+				t.Fatal("DM channel appeared in public channel list")
+			}
+		}
+	})
+
+	t.Run("non-participants cannot read or post", func(t *testing.T) {
+		if err := srv.handleJoinChannel(carol, dmFrame(t, protocol.TypeJoinChannel, &protocol.JoinChannelMessage{ChannelID: dmID})); err != nil {
+			t.Fatalf("handleJoinChannel failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, carol), protocol.TypeJoinResponse)
+		if frame == nil {
+			t.Fatal("Expected JOIN_RESPONSE")
+		}
+		join := &protocol.JoinResponseMessage{}
+		if err := join.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode JOIN_RESPONSE: %v", err)
+		}
+		if join.Success {
+			t.Error("Non-participant was allowed to join DM")
+		}
+
+		list := &protocol.ListMessagesMessage{ChannelID: dmID, Limit: 50}
+		if err := srv.handleListMessages(carol, dmFrame(t, protocol.TypeListMessages, list)); err != nil {
+			t.Fatalf("handleListMessages failed: %v", err)
+		}
+		if findFrame(readFrames(t, carol), protocol.TypeMessageList) != nil {
+			t.Error("Non-participant received DM messages")
+		}
+
+		post := &protocol.PostMessageMessage{ChannelID: dmID, Content: "intrusion"}
+		if err := srv.handlePostMessage(carol, dmFrame(t, protocol.TypePostMessage, post)); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		msgs, _ := srv.db.ListRootMessages(int64(dmID), nil, 50, nil, nil)
+		if len(msgs) != 0 {
+			t.Errorf("Expected no messages in DM, got %d", len(msgs))
+		}
+	})
+
+	t.Run("participants can post", func(t *testing.T) {
+		post := &protocol.PostMessageMessage{ChannelID: dmID, Content: "hi bob"}
+		if err := srv.handlePostMessage(alice, dmFrame(t, protocol.TypePostMessage, post)); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		msgs, _ := srv.db.ListRootMessages(int64(dmID), nil, 50, nil, nil)
+		if len(msgs) != 1 {
+			t.Errorf("Expected 1 message in DM, got %d", len(msgs))
+		}
+		readFrames(t, alice)
+	})
+
+	t.Run("participant can add another user", func(t *testing.T) {
+		add := &protocol.AddDMParticipantMessage{
+			ChannelID:  dmID,
+			TargetType: protocol.DMTargetUserID,
+			TargetID:   uint64(carolID),
+		}
+		if err := srv.handleAddDMParticipant(bob, dmFrame(t, protocol.TypeAddDMParticipant, add)); err != nil {
+			t.Fatalf("handleAddDMParticipant failed: %v", err)
+		}
+		if !srv.db.IsChannelParticipant(int64(dmID), carolID) {
+			t.Fatal("Carol was not added to the DM")
+		}
+		if findFrame(readFrames(t, carol), protocol.TypeDMRequest) == nil {
+			t.Error("Added user did not receive DM_REQUEST")
+		}
+		readFrames(t, alice)
+		readFrames(t, bob)
+	})
+
+	t.Run("list DMs returns the other participants", func(t *testing.T) {
+		if err := srv.handleListDMs(alice, dmFrame(t, protocol.TypeListDMs, &protocol.ListDMsMessage{})); err != nil {
+			t.Fatalf("handleListDMs failed: %v", err)
+		}
+		frame := findFrame(readFrames(t, alice), protocol.TypeDMList)
+		if frame == nil {
+			t.Fatal("Expected DM_LIST")
+		}
+		list := &protocol.DMListMessage{}
+		if err := list.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode DM_LIST: %v", err)
+		}
+		if len(list.DMs) != 1 {
+			t.Fatalf("Expected 1 DM, got %d", len(list.DMs))
+		}
+		if list.DMs[0].ChannelID != dmID || len(list.DMs[0].Participants) != 2 {
+			t.Errorf("Unexpected DM entry: %+v", list.DMs[0])
+		}
+	})
+}
+
+// decodeFrame decodes the first frame of the given type, failing the test if there is none
+func decodeFrame(t *testing.T, frames []*protocol.Frame, msgType uint8, msg protocol.ProtocolMessage) *protocol.Frame {
+	t.Helper()
+	frame := findFrame(frames, msgType)
+	if frame == nil {
+		t.Fatalf("Expected frame type 0x%02X, got %d frames", msgType, len(frames))
+	}
+	if err := msg.Decode(frame.Payload); err != nil {
+		t.Fatalf("Failed to decode frame type 0x%02X: %v", msgType, err)
+	}
+	return frame
+}
+
+func TestEncryptedDirectMessages(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	carolID, err := db.CreateUser("carol", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	alice := testSession(srv)
+	alice.Nickname = "alice"
+	alice.UserID = &aliceID
+	bob := testSession(srv)
+	bob.Nickname = "bob"
+	bob.UserID = &bobID
+	carol := testSession(srv)
+	carol.Nickname = "carol"
+	carol.UserID = &carolID
+
+	aliceKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bobKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	startDM := &protocol.StartDMMessage{
+		TargetType:     protocol.DMTargetNickname,
+		TargetNickname: "bob",
+	}
+
+	t.Run("encrypted DM requires a key", func(t *testing.T) {
+		if err := srv.handleStartDM(alice, dmFrame(t, protocol.TypeStartDM, startDM)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+		required := &protocol.KeyRequiredMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeKeyRequired, required)
+		if required.DMChannelID != nil {
+			t.Errorf("Expected no channel in KEY_REQUIRED, got %d", *required.DMChannelID)
+		}
+	})
+
+	t.Run("invalid public keys are rejected", func(t *testing.T) {
+		provide := &protocol.ProvidePublicKeyMessage{KeyType: protocol.KeyTypeGenerated, PublicKey: "not-a-key"}
+		if err := srv.handleProvidePublicKey(alice, dmFrame(t, protocol.TypeProvidePublicKey, provide)); err != nil {
+			t.Fatalf("handleProvidePublicKey failed: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodeEncryptionError {
+			t.Errorf("Expected error code %d, got %d", protocol.ErrCodeEncryptionError, errMsg.ErrorCode)
+		}
+	})
+
+	for _, tc := range []struct {
+		sess *Session
+		key  *ecdh.PrivateKey
+	}{{alice, aliceKey}, {bob, bobKey}} {
+		provide := &protocol.ProvidePublicKeyMessage{
+			KeyType:   protocol.KeyTypeGenerated,
+			PublicKey: protocol.EncodeEncryptionPublicKey(tc.key.PublicKey()),
+		}
+		if err := srv.handleProvidePublicKey(tc.sess, dmFrame(t, protocol.TypeProvidePublicKey, provide)); err != nil {
+			t.Fatalf("handleProvidePublicKey failed: %v", err)
+		}
+		if tc.sess.EncryptionKeyID == nil {
+			t.Fatal("Expected session encryption key to be set")
+		}
+	}
+
+	var dmID uint64
+	var channelKey []byte
+
+	t.Run("initiator generates and wraps the channel key", func(t *testing.T) {
+		if err := srv.handleStartDM(alice, dmFrame(t, protocol.TypeStartDM, startDM)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+
+		exchange := &protocol.DMKeyExchangeMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeDMKeyExchange, exchange)
+		if !exchange.NewKey {
+			t.Error("Expected initiator to be asked for a new key")
+		}
+		if len(exchange.Keys) != 2 {
+			t.Fatalf("Expected 2 public keys to wrap for, got %d", len(exchange.Keys))
+		}
+		dmID = exchange.ChannelID
+
+		req := &protocol.DMRequestMessage{}
+		decodeFrame(t, readFrames(t, bob), protocol.TypeDMRequest, req)
+		if req.ChannelID != dmID || req.RequiresKey {
+			t.Errorf("Unexpected DM_REQUEST: %+v", req)
+		}
+
+		channelKey, err = protocol.GenerateChannelKey()
+		if err != nil {
+			t.Fatalf("GenerateChannelKey failed: %v", err)
+		}
+		provide := &protocol.ProvideChannelKeysMessage{ChannelID: dmID}
+		for _, key := range exchange.Keys {
+			pub, err := protocol.ParseEncryptionPublicKey(key.PublicKey)
+			if err != nil {
+				t.Fatalf("ParseEncryptionPublicKey failed: %v", err)
+			}
+			wrapped, err := protocol.WrapChannelKey(channelKey, dmID, pub)
+			if err != nil {
+				t.Fatalf("WrapChannelKey failed: %v", err)
+			}
+			provide.Keys = append(provide.Keys, protocol.WrappedChannelKey{KeyID: key.KeyID, WrappedKey: wrapped})
+		}
+		if err := srv.handleProvideChannelKeys(alice, dmFrame(t, protocol.TypeProvideChannelKeys, provide)); err != nil {
+			t.Fatalf("handleProvideChannelKeys failed: %v", err)
+		}
+
+		for _, tc := range []struct {
+			sess *Session
+			key  *ecdh.PrivateKey
+		}{{alice, aliceKey}, {bob, bobKey}} {
+			ready := &protocol.DMReadyMessage{}
+			decodeFrame(t, readFrames(t, tc.sess), protocol.TypeDMReady, ready)
+			if !ready.IsEncrypted || ready.ChannelKey == nil {
+				t.Fatalf("Expected encrypted DM_READY with a channel key, got %+v", ready)
+			}
+			unwrapped, err := protocol.UnwrapChannelKey(*ready.ChannelKey, dmID, tc.key)
+			if err != nil {
+				t.Fatalf("UnwrapChannelKey failed: %v", err)
+			}
+			if !bytes.Equal(unwrapped, channelKey) {
+				t.Error("Unwrapped channel key does not match")
+			}
+		}
+	})
+
+	t.Run("plaintext is rejected in encrypted DMs", func(t *testing.T) {
+		post := &protocol.PostMessageMessage{ChannelID: dmID, Content: "hi bob"}
+		if err := srv.handlePostMessage(alice, dmFrame(t, protocol.TypePostMessage, post)); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodeEncryptionError {
+			t.Errorf("Expected error code %d, got %d", protocol.ErrCodeEncryptionError, errMsg.ErrorCode)
+		}
+	})
+
+	t.Run("encrypted messages are stored and delivered as ciphertext", func(t *testing.T) {
+		ciphertext, err := protocol.EncryptContent(channelKey, dmID, "hi bob")
+		if err != nil {
+			t.Fatalf("EncryptContent failed: %v", err)
+		}
+		frame := dmFrame(t, protocol.TypePostMessage, &protocol.PostMessageMessage{ChannelID: dmID, Content: ciphertext})
+		frame.Flags = protocol.FlagEncrypted
+		if err := srv.handlePostMessage(alice, frame); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		posted := &protocol.MessagePostedMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeMessagePosted, posted)
+		if !posted.Success {
+			t.Fatal("Encrypted post was rejected")
+		}
+
+		list := &protocol.ListMessagesMessage{ChannelID: dmID, Limit: 50}
+		if err := srv.handleListMessages(bob, dmFrame(t, protocol.TypeListMessages, list)); err != nil {
+			t.Fatalf("handleListMessages failed: %v", err)
+		}
+		messages := &protocol.MessageListMessage{}
+		listFrame := decodeFrame(t, readFrames(t, bob), protocol.TypeMessageList, messages)
+		if listFrame.Flags&protocol.FlagEncrypted == 0 {
+			t.Error("Expected MESSAGE_LIST for encrypted DM to carry FlagEncrypted")
+		}
+		if len(messages.Messages) != 1 || messages.Messages[0].Content != ciphertext {
+			t.Fatalf("Expected stored ciphertext, got %+v", messages.Messages)
+		}
+		plaintext, err := protocol.DecryptContent(channelKey, dmID, messages.Messages[0].Content)
+		if err != nil || plaintext != "hi bob" {
+			t.Errorf("Expected to decrypt 'hi bob', got %q (%v)", plaintext, err)
+		}
+	})
+
+	t.Run("DM list includes the session's wrapped key", func(t *testing.T) {
+		if err := srv.handleListDMs(bob, dmFrame(t, protocol.TypeListDMs, &protocol.ListDMsMessage{})); err != nil {
+			t.Fatalf("handleListDMs failed: %v", err)
+		}
+		list := &protocol.DMListMessage{}
+		decodeFrame(t, readFrames(t, bob), protocol.TypeDMList, list)
+		if len(list.DMs) != 1 || !list.DMs[0].IsEncrypted || list.DMs[0].ChannelKey == nil {
+			t.Fatalf("Expected one encrypted DM with a key, got %+v", list.DMs)
+		}
+	})
+
+	t.Run("pending DM can fall back to unencrypted", func(t *testing.T) {
+		withCarol := &protocol.StartDMMessage{
+			TargetType:       protocol.DMTargetNickname,
+			TargetNickname:   "carol",
+			AllowUnencrypted: true,
+		}
+		if err := srv.handleStartDM(alice, dmFrame(t, protocol.TypeStartDM, withCarol)); err != nil {
+			t.Fatalf("handleStartDM failed: %v", err)
+		}
+		pending := &protocol.DMPendingMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeDMPending, pending)
+		if pending.WaitingForNickname != "carol" {
+			t.Errorf("Expected to wait for carol, got %q", pending.WaitingForNickname)
+		}
+
+		carolFrames := readFrames(t, carol)
+		req := &protocol.DMRequestMessage{}
+		decodeFrame(t, carolFrames, protocol.TypeDMRequest, req)
+		if !req.RequiresKey || !req.InitiatorAllowsUnencrypted {
+			t.Errorf("Unexpected DM_REQUEST: %+v", req)
+		}
+		if findFrame(carolFrames, protocol.TypeKeyRequired) == nil {
+			t.Error("Expected KEY_REQUIRED for carol")
+		}
+
+		allow := &protocol.AllowUnencryptedMessage{DMChannelID: pending.DMChannelID}
+		if err := srv.handleAllowUnencrypted(carol, dmFrame(t, protocol.TypeAllowUnencrypted, allow)); err != nil {
+			t.Fatalf("handleAllowUnencrypted failed: %v", err)
+		}
+		for _, sess := range []*Session{alice, carol} {
+			ready := &protocol.DMReadyMessage{}
+			decodeFrame(t, readFrames(t, sess), protocol.TypeDMReady, ready)
+			if ready.ChannelID != pending.DMChannelID || ready.IsEncrypted {
+				t.Errorf("Expected unencrypted DM_READY for %d, got %+v", pending.DMChannelID, ready)
+			}
+		}
+
+		post := &protocol.PostMessageMessage{ChannelID: pending.DMChannelID, Content: "hi carol"}
+		if err := srv.handlePostMessage(alice, dmFrame(t, protocol.TypePostMessage, post)); err != nil {
+			t.Fatalf("handlePostMessage failed: %v", err)
+		}
+		if findFrame(readFrames(t, alice), protocol.TypeMessagePosted) == nil {
+			t.Error("Expected plaintext post to succeed after fallback")
+		}
+	})
+}
+
+func TestCleanupExpiredMessagesBroadcast(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID, err := srv.db.CreateChannel("short", "Short", nil, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+	subchannelID, err := srv.db.CreateSubchannel(channelID, "sub", "/sub", nil, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("Failed to create subchannel: %v", err)
+	}
+
+	// More expired messages than fit in one notice, plus a recent one that stays
+	twoHoursAgo := time.Now().UnixMilli() - 2*3600*1000
+	post := func(subchannelID *int64) int64 {
+		t.Helper()
+		id, msg, err := srv.db.PostMessage(channelID, subchannelID, nil, nil, "alice", "hello")
+		if err != nil {
+			t.Fatalf("Failed to post message: %v", err)
+		}
+		msg.CreatedAt = twoHoursAgo
+		return id
+	}
+	for i := 0; i < maxExpiredIDsPerNotice+1; i++ {
+		post(nil)
+	}
+	subMsgID := post(&subchannelID)
+	if _, _, err := srv.db.PostMessage(channelID, nil, nil, nil, "alice", "recent"); err != nil {
+		t.Fatalf("Failed to post message: %v", err)
+	}
+
+	viewer := testSession(srv)
+	srv.sessions.SetJoinedChannel(viewer.ID, &channelID)
+	outsider := testSession(srv)
+
+	srv.cleanupExpiredMessages()
+
+	var rootIDs int
+	var subNotice *protocol.MessagesExpiredMessage
+	for _, frame := range readFrames(t, viewer) {
+		if frame.Type != protocol.TypeMessagesExpired {
+			continue
+		}
+		notice := &protocol.MessagesExpiredMessage{}
+		if err := notice.Decode(frame.Payload); err != nil {
+			t.Fatalf("Failed to decode MESSAGES_EXPIRED: %v", err)
+		}
+		if notice.ChannelID != uint64(channelID) {
+			t.Errorf("Expected channel %d, got %d", channelID, notice.ChannelID)
+		}
+		if len(notice.MessageIDs) > maxExpiredIDsPerNotice {
+			t.Errorf("Expected at most %d IDs per notice, got %d", maxExpiredIDsPerNotice, len(notice.MessageIDs))
+		}
+		if notice.SubchannelID != nil {
+			subNotice = notice
+		} else {
+			rootIDs += len(notice.MessageIDs)
+		}
+	}
+
+	if rootIDs != maxExpiredIDsPerNotice+1 {
+		t.Errorf("Expected %d expired root messages, got %d", maxExpiredIDsPerNotice+1, rootIDs)
+	}
+	if subNotice == nil || *subNotice.SubchannelID != uint64(subchannelID) ||
+		len(subNotice.MessageIDs) != 1 || subNotice.MessageIDs[0] != uint64(subMsgID) {
+		t.Errorf("Expected a subchannel notice for message %d, got %+v", subMsgID, subNotice)
+	}
+	if frames := readFrames(t, outsider); len(frames) != 0 {
+		t.Errorf("Expected no frames for a session outside the channel, got %d", len(frames))
+	}
+
+	roots, err := srv.db.ListRootMessages(channelID, nil, 50, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if len(roots) != 1 || roots[0].Content != "recent" {
+		t.Errorf("Expected only the recent message to remain, got %d messages", len(roots))
+	}
+}
+
+func TestGetMessageHistory(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	adminID, err := db.CreateUser("admin", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	srv.config.AdminUsers = []string{"admin"}
+	channelID := createTestChannel(t, db, "general", "General")
+	reloadMemDB(t, srv, db)
+
+	msgID, _, err := srv.db.PostMessage(channelID, nil, nil, &aliceID, "alice", "helo")
+	if err != nil {
+		t.Fatalf("Failed to post message: %v", err)
+	}
+
+	newUserSession := func(nickname string, userID *int64) *Session {
+		sess := testSession(srv)
+		sess.Nickname = nickname
+		sess.UserID = userID
+		return sess
+	}
+	alice := newUserSession("alice", &aliceID)
+	admin := newUserSession("admin", &adminID)
+	guest := newUserSession("guest", nil)
+
+	edit := func(sess *Session, content string) {
+		t.Helper()
+		frame := dmFrame(t, protocol.TypeEditMessage, &protocol.EditMessageMessage{MessageID: uint64(msgID), NewContent: content})
+		if err := srv.handleEditMessage(sess, frame); err != nil {
+			t.Fatalf("handleEditMessage failed: %v", err)
+		}
+		readFrames(t, sess)
+	}
+	getHistory := func(sess *Session) []*protocol.Frame {
+		t.Helper()
+		frame := dmFrame(t, protocol.TypeGetMessageHistory, &protocol.GetMessageHistoryMessage{MessageID: uint64(msgID)})
+		if err := srv.handleGetMessageHistory(sess, frame); err != nil {
+			t.Fatalf("handleGetMessageHistory failed: %v", err)
+		}
+		return readFrames(t, sess)
+	}
+
+	// A message that was never edited has just its original revision
+	history := &protocol.MessageHistoryMessage{}
+	decodeFrame(t, getHistory(guest), protocol.TypeMessageHistory, history)
+	if len(history.Revisions) != 1 || history.Revisions[0].Content != "helo" || history.Revisions[0].EditorNickname != "alice" {
+		t.Fatalf("Unexpected history of unedited message: %+v", history.Revisions)
+	}
+
+	edit(alice, "hello")
+	edit(alice, "hello world")
+	edit(admin, "[removed by admin]")
+
+	history = &protocol.MessageHistoryMessage{}
+	decodeFrame(t, getHistory(guest), protocol.TypeMessageHistory, history)
+	if history.MessageID != uint64(msgID) || history.ChannelID != uint64(channelID) {
+		t.Errorf("Unexpected history header: message %d, channel %d", history.MessageID, history.ChannelID)
+	}
+	expected := []struct {
+		content, editor string
+		admin           bool
+	}{
+		{"helo", "alice", false},
+		{"hello", "alice", false},
+		{"hello world", "alice", false},
+		{"[removed by admin]", "admin", true},
+	}
+	if len(history.Revisions) != len(expected) {
+		t.Fatalf("Expected %d revisions, got %d", len(expected), len(history.Revisions))
+	}
+	for i, want := range expected {
+		rev := history.Revisions[i]
+		if rev.Content != want.content || rev.EditorNickname != want.editor || rev.AdminEdit != want.admin {
+			t.Errorf("Revision %d: expected %+v, got %+v", i, want, rev)
+		}
+		if i > 0 && rev.EditedAt.Before(history.Revisions[i-1].EditedAt) {
+			t.Errorf("Revision %d is older than the one before it", i)
+		}
+	}
+
+	// Deleted messages don't expose their history
+	if _, err := srv.db.SoftDeleteMessage(uint64(msgID), "alice"); err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	decodeFrame(t, getHistory(guest), protocol.TypeError, errMsg)
+	if errMsg.ErrorCode != protocol.ErrCodeMessageNotFound {
+		t.Errorf("Expected error %d, got %d", protocol.ErrCodeMessageNotFound, errMsg.ErrorCode)
+	}
+}
+
+func TestListAdminActions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	adminID, err := db.CreateUser("admin", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	modID, err := db.CreateUser("mod", "hash", uint8(protocol.UserFlagModerator))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	srv.config.AdminUsers = []string{"admin"}
+
+	ip := "192.0.2.1"
+	base := time.Now().Add(-time.Hour).UnixMilli()
+	for i, action := range []database.AdminAction{
+		{AdminNickname: "admin", ActionType: "ban_user", TargetType: "user", TargetID: &aliceID, TargetIdentifier: "alice", Details: `{"reason":"spam"}`, IPAddress: &ip},
+		{AdminNickname: "mod", ActionType: "delete_message", TargetType: "message", TargetIdentifier: "42"},
+		{AdminNickname: "admin", ActionType: "unban_user", TargetType: "user", TargetID: &aliceID, TargetIdentifier: "alice", IPAddress: &ip},
+	} {
+		action.PerformedAt = base + int64(i)*60000
+		if err := db.LogAdminAction(action); err != nil {
+			t.Fatalf("Failed to log admin action: %v", err)
+		}
+	}
+
+	newUserSession := func(nickname string, userID *int64, flags protocol.UserFlags) *Session {
+		sess := testSession(srv)
+		sess.Nickname = nickname
+		sess.UserID = userID
+		sess.UserFlags = uint8(flags)
+		return sess
+	}
+	admin := newUserSession("admin", &adminID, 0)
+	mod := newUserSession("mod", &modID, protocol.UserFlagModerator)
+	alice := newUserSession("alice", &aliceID, 0)
+
+	list := func(sess *Session, msg *protocol.ListAdminActionsMessage) []*protocol.Frame {
+		t.Helper()
+		frame := dmFrame(t, protocol.TypeListAdminActions, msg)
+		if err := srv.handleListAdminActions(sess, frame); err != nil {
+			t.Fatalf("handleListAdminActions failed: %v", err)
+		}
+		return readFrames(t, sess)
+	}
+
+	// Regular users can't read the log
+	errMsg := &protocol.ErrorMessage{}
+	decodeFrame(t, list(alice, &protocol.ListAdminActionsMessage{}), protocol.TypeError, errMsg)
+	if errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+		t.Errorf("Expected error %d, got %d", protocol.ErrCodePermissionDenied, errMsg.ErrorCode)
+	}
+
+	// Admins get everything newest first, including where the admin connected from
+	resp := &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(admin, &protocol.ListAdminActionsMessage{}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 3 || resp.HasMore {
+		t.Fatalf("Expected 3 entries and no more, got %d (more: %v)", len(resp.Entries), resp.HasMore)
+	}
+	if resp.Entries[0].ActionType != "unban_user" || resp.Entries[2].ActionType != "ban_user" {
+		t.Errorf("Entries not newest first: %+v", resp.Entries)
+	}
+	if resp.Entries[2].Details != `{"reason":"spam"}` || resp.Entries[2].TargetID == nil || *resp.Entries[2].TargetID != uint64(aliceID) {
+		t.Errorf("Unexpected entry: %+v", resp.Entries[2])
+	}
+	if resp.Entries[2].IPAddress == nil || *resp.Entries[2].IPAddress != ip {
+		t.Errorf("Expected admin to see the IP address, got %v", resp.Entries[2].IPAddress)
+	}
+	if !resp.Entries[2].PerformedAt.Equal(time.UnixMilli(base)) {
+		t.Errorf("Expected time %d, got %d", base, resp.Entries[2].PerformedAt.UnixMilli())
+	}
+
+	// Moderators can read it too, without IP addresses
+	resp = &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(mod, &protocol.ListAdminActionsMessage{}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 3 {
+		t.Fatalf("Expected 3 entries for moderator, got %d", len(resp.Entries))
+	}
+	for _, entry := range resp.Entries {
+		if entry.IPAddress != nil {
+			t.Errorf("Moderator saw IP address of entry %d", entry.ID)
+		}
+	}
+
+	// Filters
+	nickname, actionType := "admin", "ban_user"
+	resp = &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(admin, &protocol.ListAdminActionsMessage{AdminNickname: &nickname}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 2 {
+		t.Errorf("Expected 2 entries by admin, got %d", len(resp.Entries))
+	}
+	resp = &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(admin, &protocol.ListAdminActionsMessage{ActionType: &actionType}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 1 || resp.Entries[0].ActionType != "ban_user" {
+		t.Errorf("Expected the ban_user entry, got %+v", resp.Entries)
+	}
+	since, until := time.UnixMilli(base+60000), time.UnixMilli(base+120000)
+	resp = &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(admin, &protocol.ListAdminActionsMessage{Since: &since, Until: &until}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 1 || resp.Entries[0].ActionType != "delete_message" {
+		t.Errorf("Expected the delete_message entry, got %+v", resp.Entries)
+	}
+
+	// Paging
+	resp = &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(admin, &protocol.ListAdminActionsMessage{Limit: 2}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 2 || !resp.HasMore {
+		t.Fatalf("Expected a first page of 2 with more, got %d (more: %v)", len(resp.Entries), resp.HasMore)
+	}
+	beforeID := resp.Entries[1].ID
+	resp = &protocol.AdminActionListMessage{}
+	decodeFrame(t, list(admin, &protocol.ListAdminActionsMessage{BeforeID: &beforeID, Limit: 2}), protocol.TypeAdminActionList, resp)
+	if len(resp.Entries) != 1 || resp.HasMore || resp.Entries[0].ActionType != "ban_user" {
+		t.Errorf("Expected a last page with the ban_user entry, got %+v (more: %v)", resp.Entries, resp.HasMore)
+	}
+}
+
+func TestPermissionChecksDuringRoleChange(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	userID, err := db.CreateUser("alice", "hash", uint8(protocol.UserFlagModerator))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	sess := testSession(srv)
+	sess.mu.Lock()
+	sess.Nickname = "alice"
+	sess.UserID = &userID
+	sess.UserFlags = uint8(protocol.UserFlagModerator)
+	sess.mu.Unlock()
+
+	// Role changes update live sessions while their requests are being checked (run with -race)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			srv.updateSessionFlags(userID, uint8(protocol.UserFlagAdmin)*uint8(i%2))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		srv.isAdmin(sess)
+		srv.isModerator(sess)
+		if _, err := srv.moderatorChannels(sess); err != nil {
+			t.Fatalf("moderatorChannels failed: %v", err)
+		}
+	}
+	<-done
+
+	if !srv.isAdmin(sess) {
+		t.Error("Expected the last role change to make alice an admin")
+	}
+}
+
+func TestModeratorPermissions(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	generalID := createTestChannel(t, db, "general", "General")
+	randomID := createTestChannel(t, db, "random", "Random")
+
+	createUser := func(nickname string, flags protocol.UserFlags) int64 {
+		t.Helper()
+		id, err := db.CreateUser(nickname, "hash", uint8(flags))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		return id
+	}
+	adminID := createUser("admin", 0)
+	modID := createUser("mod", protocol.UserFlagModerator)
+	globalModID := createUser("globalmod", protocol.UserFlagModerator)
+	aliceID := createUser("alice", 0)
+	srv.config.AdminUsers = []string{"admin"}
+	if err := db.SetModeratorChannels(modID, []int64{generalID}); err != nil {
+		t.Fatalf("SetModeratorChannels failed: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	newUserSession := func(nickname string, userID *int64, flags protocol.UserFlags) *Session {
+		sess := testSession(srv)
+		sess.Nickname = nickname
+		sess.UserID = userID
+		sess.UserFlags = uint8(flags)
+		return sess
+	}
+	admin := newUserSession("admin", &adminID, 0)
+	mod := newUserSession("mod", &modID, protocol.UserFlagModerator)
+	globalMod := newUserSession("globalmod", &globalModID, protocol.UserFlagModerator)
+	alice := newUserSession("alice", &aliceID, 0)
+
+	post := func(channelID int64, content string) uint64 {
+		t.Helper()
+		id, _, err := srv.db.PostMessage(channelID, nil, nil, &aliceID, "alice", content)
+		if err != nil {
+			t.Fatalf("Failed to post message: %v", err)
+		}
+		return uint64(id)
+	}
+
+	t.Run("moderators delete and edit within their channels", func(t *testing.T) {
+		inScope := post(generalID, "in scope")
+		outOfScope := post(randomID, "out of scope")
+
+		if err := srv.handleDeleteMessage(mod, dmFrame(t, protocol.TypeDeleteMessage, &protocol.DeleteMessageMessage{MessageID: outOfScope})); err != nil {
+			t.Fatalf("handleDeleteMessage failed: %v", err)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, mod), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+			t.Errorf("Expected error %d, got %d", protocol.ErrCodePermissionDenied, errMsg.ErrorCode)
+		}
+
+		if err := srv.handleDeleteMessage(mod, dmFrame(t, protocol.TypeDeleteMessage, &protocol.DeleteMessageMessage{MessageID: inScope})); err != nil {
+			t.Fatalf("handleDeleteMessage failed: %v", err)
+		}
+		deleted := &protocol.MessageDeletedMessage{}
+		decodeFrame(t, readFrames(t, mod), protocol.TypeMessageDeleted, deleted)
+		if !deleted.Success {
+			t.Errorf("Expected moderator to delete message in their channel: %s", deleted.Message)
+		}
+
+		edited := post(generalID, "before")
+		if err := srv.handleEditMessage(mod, dmFrame(t, protocol.TypeEditMessage, &protocol.EditMessageMessage{MessageID: edited, NewContent: "after"})); err != nil {
+			t.Fatalf("handleEditMessage failed: %v", err)
+		}
+		editResp := &protocol.MessageEditedMessage{}
+		decodeFrame(t, readFrames(t, mod), protocol.TypeMessageEdited, editResp)
+		if !editResp.Success || editResp.NewContent != "after" {
+			t.Errorf("Expected moderator to edit message in their channel: %+v", editResp)
+		}
+
+		// Both show up in the audit log
+		actions, err := srv.db.ListAdminActions(database.AdminActionFilter{AdminNickname: "mod"})
+		if err != nil {
+			t.Fatalf("ListAdminActions failed: %v", err)
+		}
+		if len(actions) != 2 || actions[0].ActionType != "edit_message" || actions[1].ActionType != "delete_message" {
+			t.Fatalf("Expected edit and delete to be logged, got %+v", actions)
+		}
+		if actions[1].TargetIdentifier != "alice" || actions[1].Details != `{"channel":"general"}` {
+			t.Errorf("Unexpected audit entry: %+v", actions[1])
+		}
+
+		// Moderators without channels moderate every public channel
+		if err := srv.handleDeleteMessage(globalMod, dmFrame(t, protocol.TypeDeleteMessage, &protocol.DeleteMessageMessage{MessageID: outOfScope})); err != nil {
+			t.Fatalf("handleDeleteMessage failed: %v", err)
+		}
+		deleted = &protocol.MessageDeletedMessage{}
+		decodeFrame(t, readFrames(t, globalMod), protocol.TypeMessageDeleted, deleted)
+		if !deleted.Success {
+			t.Errorf("Expected unscoped moderator to delete message: %s", deleted.Message)
+		}
+	})
+
+	t.Run("moderator bans are limited", func(t *testing.T) {
+		ban := func(sess *Session, nickname string, duration *uint64) *protocol.UserBannedMessage {
+			t.Helper()
+			frame := dmFrame(t, protocol.TypeBanUser, &protocol.BanUserMessage{Nickname: &nickname, Reason: "spam", DurationSeconds: duration})
+			if err := srv.handleBanUser(sess, frame); err != nil {
+				t.Fatalf("handleBanUser failed: %v", err)
+			}
+			resp := &protocol.UserBannedMessage{}
+			decodeFrame(t, readFrames(t, sess), protocol.TypeUserBanned, resp)
+			return resp
+		}
+		day, month := uint64(24*60*60), uint64(30*24*60*60)
+
+		if resp := ban(mod, "alice", &day); resp.Success {
+			t.Error("Expected channel moderator to be unable to ban")
+		}
+		if resp := ban(globalMod, "alice", nil); resp.Success {
+			t.Error("Expected permanent moderator ban to fail")
+		}
+		if resp := ban(globalMod, "alice", &month); resp.Success {
+			t.Error("Expected month-long moderator ban to fail")
+		}
+		if resp := ban(globalMod, "mod", &day); resp.Success {
+			t.Error("Expected moderator to be unable to ban staff")
+		}
+		if resp := ban(globalMod, "admin", &day); resp.Success {
+			t.Error("Expected moderator to be unable to ban a configured admin")
+		}
+		if resp := ban(globalMod, "alice", &day); !resp.Success {
+			t.Errorf("Expected day-long moderator ban to succeed: %s", resp.Message)
+		}
+		if resp := ban(admin, "alice", nil); !resp.Success {
+			t.Errorf("Expected admin to ban permanently: %s", resp.Message)
+		}
+	})
+
+	t.Run("channel moderators ban from their channels", func(t *testing.T) {
+		ban := func(sess *Session, msg *protocol.BanUserMessage) *protocol.UserBannedMessage {
+			t.Helper()
+			if err := srv.handleBanUser(sess, dmFrame(t, protocol.TypeBanUser, msg)); err != nil {
+				t.Fatalf("handleBanUser failed: %v", err)
+			}
+			resp := &protocol.UserBannedMessage{}
+			decodeFrame(t, readFrames(t, sess), protocol.TypeUserBanned, resp)
+			return resp
+		}
+		nickname := "alice"
+		day, month := uint64(24*60*60), uint64(30*24*60*60)
+		general, random := uint64(generalID), uint64(randomID)
+
+		if resp := ban(mod, &protocol.BanUserMessage{Nickname: &nickname, Reason: "spam", DurationSeconds: &day, ChannelID: &random}); resp.Success {
+			t.Error("Expected moderator to be unable to ban from a channel they don't moderate")
+		}
+		if resp := ban(mod, &protocol.BanUserMessage{Nickname: &nickname, Reason: "spam", DurationSeconds: &month, ChannelID: &general}); resp.Success {
+			t.Error("Expected month-long channel ban to fail")
+		}
+		if resp := ban(mod, &protocol.BanUserMessage{Nickname: &nickname, Reason: "spam", DurationSeconds: &day, Shadowban: true, ChannelID: &general}); resp.Success {
+			t.Error("Expected channel shadowban to fail")
+		}
+		if resp := ban(mod, &protocol.BanUserMessage{Nickname: &nickname, Reason: "spam", DurationSeconds: &day, ChannelID: &general}); !resp.Success {
+			t.Fatalf("Expected moderator to ban from their channel: %s", resp.Message)
+		}
+
+		postAs := func(channelID uint64) []*protocol.Frame {
+			t.Helper()
+			frame := dmFrame(t, protocol.TypePostMessage, &protocol.PostMessageMessage{ChannelID: channelID, Content: "hello"})
+			if err := srv.handlePostMessage(alice, frame); err != nil {
+				t.Fatalf("handlePostMessage failed: %v", err)
+			}
+			return readFrames(t, alice)
+		}
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, postAs(general), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+			t.Errorf("Expected error %d, got %d", protocol.ErrCodePermissionDenied, errMsg.ErrorCode)
+		}
+		if findFrame(postAs(random), protocol.TypeMessagePosted) == nil {
+			t.Error("Expected alice to still post in other channels")
+		}
+
+		// The ban shows up in the ban list with its channel
+		if err := srv.handleListBans(admin, dmFrame(t, protocol.TypeListBans, &protocol.ListBansMessage{})); err != nil {
+			t.Fatalf("handleListBans failed: %v", err)
+		}
+		list := &protocol.BanListMessage{}
+		decodeFrame(t, readFrames(t, admin), protocol.TypeBanList, list)
+		found := false
+		for _, entry := range list.Bans {
+			if entry.ChannelID != nil && *entry.ChannelID == general {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a ban in #general, got %+v", list.Bans)
+		}
+	})
+
+	t.Run("only admins set user flags", func(t *testing.T) {
+		set := func(sess *Session, msg *protocol.SetUserFlagsMessage) *protocol.UserFlagsUpdatedMessage {
+			t.Helper()
+			if err := srv.handleSetUserFlags(sess, dmFrame(t, protocol.TypeSetUserFlags, msg)); err != nil {
+				t.Fatalf("handleSetUserFlags failed: %v", err)
+			}
+			resp := &protocol.UserFlagsUpdatedMessage{}
+			decodeFrame(t, readFrames(t, sess), protocol.TypeUserFlagsUpdated, resp)
+			return resp
+		}
+		nickname := "alice"
+
+		if resp := set(globalMod, &protocol.SetUserFlagsMessage{Nickname: &nickname, Flags: protocol.UserFlagModerator}); resp.Success {
+			t.Error("Expected moderator to be unable to grant roles")
+		}
+		if resp := set(admin, &protocol.SetUserFlagsMessage{Nickname: &nickname, Flags: 0x80}); resp.Success {
+			t.Error("Expected unknown flags to be rejected")
+		}
+		self := uint64(adminID)
+		if resp := set(admin, &protocol.SetUserFlagsMessage{UserID: &self, Flags: 0}); resp.Success {
+			t.Error("Expected admin to be unable to change their own roles")
+		}
+
+		resp := set(admin, &protocol.SetUserFlagsMessage{Nickname: &nickname, Flags: protocol.UserFlagModerator, ModeratorChannels: []uint64{uint64(randomID)}})
+		if !resp.Success || resp.UserID != uint64(aliceID) || resp.Message != "alice is now moderator in #random" {
+			t.Fatalf("Unexpected response: %+v", resp)
+		}
+		if !protocol.UserFlags(alice.UserFlags).IsModerator() {
+			t.Error("Expected alice's session to become a moderator")
+		}
+		notice := &protocol.UserFlagsUpdatedMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeUserFlagsUpdated, notice)
+		if notice.Flags != protocol.UserFlagModerator || len(notice.ModeratorChannels) != 1 {
+			t.Errorf("Unexpected notification: %+v", notice)
+		}
+		if !srv.canModerateChannel(alice, randomID) || srv.canModerateChannel(alice, generalID) {
+			t.Error("Expected alice to moderate only #random")
+		}
+
+		// Taking the role away clears the channels, on every session of the user
+		alice2 := newUserSession("alice", &aliceID, protocol.UserFlagModerator)
+		resp = set(admin, &protocol.SetUserFlagsMessage{Nickname: &nickname, Flags: protocol.UserFlagAdmin})
+		if !resp.Success {
+			t.Fatalf("Failed to demote: %s", resp.Message)
+		}
+		if channels, err := srv.db.GetModeratorChannels(aliceID); err != nil || len(channels) != 0 {
+			t.Errorf("Expected no moderator channels, got %v (%v)", channels, err)
+		}
+		for _, sess := range []*Session{alice, alice2} {
+			if srv.isModerator(sess) {
+				t.Error("Expected alice to no longer be a moderator")
+			}
+			notice := &protocol.UserFlagsUpdatedMessage{}
+			decodeFrame(t, readFrames(t, sess), protocol.TypeUserFlagsUpdated, notice)
+			if notice.Flags != protocol.UserFlagAdmin {
+				t.Errorf("Unexpected notification: %+v", notice)
+			}
+		}
+	})
+}
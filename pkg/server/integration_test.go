@@ -145,6 +145,7 @@ func TestServerIntegration(t *testing.T) {
 	// Start server once for all subtests
 	config := DefaultConfig()
 	config.SessionTimeoutSeconds = 2 // Short timeout for faster tests
+	config.MaxConnectionsPerIP = 0  // All test clients connect from localhost
 	srv, addr := startTestServer(t, config)
 
 	t.Run("lifecycle/connect_and_disconnect", func(t *testing.T) {
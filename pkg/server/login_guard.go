@@ -0,0 +1,127 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+)
+
+// Kinds of login failure records
+const (
+	loginFailureUser = "user"
+	loginFailureIP   = "ip"
+)
+
+const (
+	// loginFailureWindow is how long failures count: after this long without one (and
+	// with no lockout running) counting starts over
+	loginFailureWindow = 24 * time.Hour
+
+	// maxLoginLockout caps the doubling of lockouts
+	maxLoginLockout = 24 * time.Hour
+)
+
+// loginLockoutDuration returns the lockout for a failure count at or over limit:
+// base for the failure that reaches the limit, doubling for every one after it
+func loginLockoutDuration(base time.Duration, failures, limit int) time.Duration {
+	lockout := base
+	for i := limit; i < failures && lockout < maxLoginLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, maxLoginLockout)
+}
+
+// loginLockedFor returns how long until an account (by nickname, "" for none) and IP
+// address may try to log in again, or 0 if they may now
+func (s *Server) loginLockedFor(nickname, ip string, now time.Time) time.Duration {
+	var wait time.Duration
+	for _, key := range [][2]string{{loginFailureUser, nickname}, {loginFailureIP, ip}} {
+		if key[1] == "" {
+			continue
+		}
+		f, err := s.db.GetLoginFailure(key[0], key[1])
+		if err != nil {
+			log.Printf("Failed to check login lockout of %s %s: %v", key[0], key[1], err)
+			continue
+		}
+		if f != nil && f.LockedUntil != nil {
+			wait = max(wait, time.UnixMilli(*f.LockedUntil).Sub(now))
+		}
+	}
+	return wait
+}
+
+// recordLoginFailure counts a failed login against an account (by nickname, "" if it
+// doesn't exist) and the IP address it came from, locking them out at their limits
+func (s *Server) recordLoginFailure(nickname, ip string, now time.Time) {
+	if nickname != "" {
+		s.countLoginFailure(loginFailureUser, nickname, ip, s.config.LoginMaxFailures, now)
+	}
+	if ip != "" {
+		s.countLoginFailure(loginFailureIP, ip, ip, s.config.LoginMaxFailuresPerIP, now)
+	}
+}
+
+// countLoginFailure adds a failure to one record and locks it out at limit (<= 0 = never)
+func (s *Server) countLoginFailure(kind, identifier, ip string, limit int, now time.Time) {
+	if limit <= 0 {
+		return
+	}
+
+	nowMs := now.UnixMilli()
+	failures, err := s.db.AddLoginFailure(kind, identifier, nowMs, now.Add(-loginFailureWindow).UnixMilli())
+	if err != nil {
+		log.Printf("Failed to count login failure of %s %s: %v", kind, identifier, err)
+		return
+	}
+	if failures < limit {
+		return
+	}
+
+	lockout := loginLockoutDuration(time.Duration(s.config.LoginLockoutSeconds)*time.Second, failures, limit)
+	if err := s.db.ExtendLoginLockout(kind, identifier, now.Add(lockout).UnixMilli()); err != nil {
+		log.Printf("Failed to lock out %s %s: %v", kind, identifier, err)
+		return
+	}
+
+	log.Printf("Locked out %s %s for %s after %d failed logins", kind, identifier, lockout, failures)
+	if err := s.db.LogAdminAction(database.AdminAction{
+		AdminNickname:    "server",
+		ActionType:       "login_lockout",
+		TargetType:       kind,
+		TargetIdentifier: identifier,
+		Details: adminDetails(map[string]string{
+			"failures": strconv.Itoa(failures),
+			"duration": lockout.String(),
+			"from_ip":  ip,
+		}),
+		PerformedAt: nowMs,
+	}); err != nil {
+		log.Printf("Failed to log admin action: %v", err)
+	}
+}
+
+// clearLoginFailures forgets an account's failed logins after it logged in. The IP
+// address keeps its count, so one known password doesn't reset guessing others.
+func (s *Server) clearLoginFailures(nickname string) {
+	if err := s.db.ClearLoginFailure(loginFailureUser, nickname); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Failed to clear login failures of %s: %v", nickname, err)
+	}
+}
+
+// formatLockout describes how long a lockout lasts in whole seconds, minutes or hours
+func formatLockout(d time.Duration) string {
+	switch {
+	case d > time.Hour:
+		return fmt.Sprintf("%d hours", int((d+time.Hour-1)/time.Hour))
+	case d > time.Minute:
+		return fmt.Sprintf("%d minutes", int((d+time.Minute-1)/time.Minute))
+	default:
+		return fmt.Sprintf("%d seconds", max(int((d+time.Second-1)/time.Second), 1))
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{20, maxLoginLockout},
+	}
+	for _, tt := range tests {
+		if got := loginLockoutDuration(time.Minute, tt.failures, 5); got != tt.want {
+			t.Errorf("loginLockoutDuration(%d failures) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("right"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword failed: %v", err)
+	}
+	if _, err := db.CreateUser("alice", string(hash), 0); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	login := func(nickname, password string) *protocol.AuthResponseMessage {
+		t.Helper()
+		sess := testSession(srv)
+		frame := dmFrame(t, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: nickname, Password: password})
+		if err := srv.handleAuthRequest(sess, frame); err != nil {
+			t.Fatalf("handleAuthRequest failed: %v", err)
+		}
+		resp := &protocol.AuthResponseMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeAuthResponse, resp)
+		return resp
+	}
+
+	// Logging in below the limit forgets the failures
+	for i := 0; i < srv.config.LoginMaxFailures-1; i++ {
+		if resp := login("alice", "wrong"); resp.Message != "Invalid credentials" {
+			t.Fatalf("Attempt %d: expected invalid credentials, got %q", i+1, resp.Message)
+		}
+	}
+	if resp := login("alice", "right"); !resp.Success {
+		t.Fatalf("Expected login below the limit to succeed, got %q", resp.Message)
+	}
+	if f, _ := srv.db.GetLoginFailure(loginFailureUser, "alice"); f != nil {
+		t.Errorf("Expected failures to be cleared after logging in, got %d", f.Failures)
+	}
+
+	// Reaching the limit locks the account, even with the right password
+	for i := 0; i < srv.config.LoginMaxFailures; i++ {
+		login("alice", "wrong")
+	}
+	resp := login("alice", "right")
+	if resp.Success || !strings.HasPrefix(resp.Message, "Too many failed login attempts") {
+		t.Fatalf("Expected a locked out account, got success=%v %q", resp.Success, resp.Message)
+	}
+
+	actions, err := srv.db.ListAdminActions(database.AdminActionFilter{ActionType: "login_lockout"})
+	if err != nil {
+		t.Fatalf("ListAdminActions failed: %v", err)
+	}
+	if len(actions) != 1 || actions[0].TargetType != loginFailureUser || actions[0].TargetIdentifier != "alice" {
+		t.Fatalf("Expected one logged lockout of alice, got %+v", actions)
+	}
+
+	// Unregistered nicknames lock out the same way
+	for i := 0; i < srv.config.LoginMaxFailures; i++ {
+		login("nobody", "wrong")
+	}
+	if resp := login("nobody", "wrong"); !strings.HasPrefix(resp.Message, "Too many failed login attempts") {
+		t.Errorf("Expected unregistered nickname to be locked out, got %q", resp.Message)
+	}
+
+	// The IP address locks out across nicknames
+	srv.config.LoginMaxFailuresPerIP = 3
+	if err := srv.db.ClearLoginFailure(loginFailureIP, "127.0.0.1"); err != nil {
+		t.Fatalf("ClearLoginFailure failed: %v", err)
+	}
+	for _, nickname := range []string{"bob", "carol", "dave"} {
+		login(nickname, "wrong")
+	}
+	if resp := login("erin", "wrong"); !strings.HasPrefix(resp.Message, "Too many failed login attempts") {
+		t.Errorf("Expected IP address to be locked out, got %q", resp.Message)
+	}
+}
+
+func TestLoginLockoutConcurrent(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("right"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword failed: %v", err)
+	}
+	if _, err := db.CreateUser("alice", string(hash), 0); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	// Parallel guesses can pass the lockout check before any of them is counted, so
+	// every guess that got to the password check has to count
+	attempts := srv.config.LoginMaxFailuresPerIP
+	sessions := make([]*Session, attempts)
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		sessions[i] = testSession(srv)
+		frame := dmFrame(t, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: "alice", Password: "wrong"})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = srv.handleAuthRequest(sessions[i], frame)
+		}()
+	}
+	wg.Wait()
+
+	checked := 0
+	for i, sess := range sessions {
+		if errs[i] != nil {
+			t.Fatalf("handleAuthRequest failed: %v", errs[i])
+		}
+		resp := &protocol.AuthResponseMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeAuthResponse, resp)
+		if resp.Message == "Invalid credentials" {
+			checked++
+		}
+	}
+
+	if checked < srv.config.LoginMaxFailures {
+		t.Fatalf("Expected at least %d guesses to be checked, got %d", srv.config.LoginMaxFailures, checked)
+	}
+	for _, key := range [][2]string{{loginFailureUser, "alice"}, {loginFailureIP, "127.0.0.1"}} {
+		f, err := srv.db.GetLoginFailure(key[0], key[1])
+		if err != nil || f == nil || f.Failures != checked {
+			t.Errorf("Expected %d failures of %s %s, got %+v (err=%v)", checked, key[0], key[1], f, err)
+		}
+	}
+	if wait := srv.loginLockedFor("alice", "127.0.0.1", time.Now()); wait <= 0 {
+		t.Error("Expected the parallel guesses to lock alice out")
+	}
+}
+
+func TestLoginLockoutAdminMessages(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	adminID, err := db.CreateUser("admin", "hash", uint8(protocol.UserFlagAdmin))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	now := time.Now()
+	srv.config.LoginMaxFailures = 1
+	srv.recordLoginFailure("bob", "10.0.0.1", now)
+
+	admin := testSession(srv)
+	admin.Nickname = "admin"
+	admin.UserID = &adminID
+	admin.UserFlags = uint8(protocol.UserFlagAdmin)
+	user := testSession(srv)
+	user.Nickname = "alice"
+
+	// Only admins see lockouts
+	if err := srv.handleListLoginLockouts(user, dmFrame(t, protocol.TypeListLoginLockouts, &protocol.ListLoginLockoutsMessage{})); err != nil {
+		t.Fatalf("handleListLoginLockouts failed: %v", err)
+	}
+	errMsg := &protocol.ErrorMessage{}
+	decodeFrame(t, readFrames(t, user), protocol.TypeError, errMsg)
+	if errMsg.ErrorCode != protocol.ErrCodePermissionDenied {
+		t.Errorf("Expected permission denied, got %d", errMsg.ErrorCode)
+	}
+
+	if err := srv.handleListLoginLockouts(admin, dmFrame(t, protocol.TypeListLoginLockouts, &protocol.ListLoginLockoutsMessage{})); err != nil {
+		t.Fatalf("handleListLoginLockouts failed: %v", err)
+	}
+	list := &protocol.LoginLockoutListMessage{}
+	decodeFrame(t, readFrames(t, admin), protocol.TypeLoginLockoutList, list)
+	if len(list.Lockouts) != 1 || list.Lockouts[0].Identifier != "bob" || !list.Lockouts[0].LockedUntil.After(now) {
+		t.Fatalf("Expected bob to be locked out, got %+v", list.Lockouts)
+	}
+
+	clearLockout := func(sess *Session, kind, identifier string) *protocol.LoginLockoutClearedMessage {
+		t.Helper()
+		frame := dmFrame(t, protocol.TypeClearLoginLockout, &protocol.ClearLoginLockoutMessage{Kind: kind, Identifier: identifier})
+		if err := srv.handleClearLoginLockout(sess, frame); err != nil {
+			t.Fatalf("handleClearLoginLockout failed: %v", err)
+		}
+		resp := &protocol.LoginLockoutClearedMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeLoginLockoutCleared, resp)
+		return resp
+	}
+
+	if resp := clearLockout(user, loginFailureUser, "bob"); resp.Success {
+		t.Error("Expected non-admin to be refused")
+	}
+	if resp := clearLockout(admin, "host", "bob"); resp.Success {
+		t.Error("Expected unknown kind to be refused")
+	}
+	if resp := clearLockout(admin, loginFailureUser, "bob"); !resp.Success {
+		t.Fatalf("Expected lockout to be cleared, got %q", resp.Message)
+	}
+	if resp := clearLockout(admin, loginFailureUser, "bob"); resp.Success {
+		t.Error("Expected clearing a missing lockout to fail")
+	}
+	if wait := srv.loginLockedFor("bob", "", now); wait != 0 {
+		t.Errorf("Expected bob to be able to log in, locked for %v", wait)
+	}
+
+	actions, err := srv.db.ListAdminActions(database.AdminActionFilter{ActionType: "clear_login_lockout"})
+	if err != nil {
+		t.Fatalf("ListAdminActions failed: %v", err)
+	}
+	if len(actions) != 1 || actions[0].AdminNickname != "admin" || actions[0].TargetIdentifier != "bob" {
+		t.Errorf("Expected one logged clear by admin, got %+v", actions)
+	}
+}
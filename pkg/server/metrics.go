@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 
+	"github.com/aeolun/superchat/pkg/database"
 	"github.com/aeolun/superchat/pkg/protocol"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -113,6 +114,65 @@ func NewMetrics() *Metrics {
 	}
 }
 
+// RegisterCacheMetrics exports the MemDB message cache stats, read on every scrape
+func (m *Metrics) RegisterCacheMetrics(stats func() database.CacheStats) {
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "superchat_memdb_cached_messages",
+			Help: "Number of messages cached in memory",
+		},
+		func() float64 { return float64(stats().Messages) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "superchat_memdb_cached_threads",
+			Help: "Number of threads cached in memory",
+		},
+		func() float64 { return float64(stats().Threads) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "superchat_memdb_cache_bytes",
+			Help: "Approximate memory used by cached messages",
+		},
+		func() float64 { return float64(stats().Bytes) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "superchat_memdb_cache_budget_bytes",
+			Help: "Memory budget for cached messages (0 = unlimited)",
+		},
+		func() float64 { return float64(stats().Budget) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "superchat_memdb_cache_hit_ratio",
+			Help: "Fraction of message lookups served from memory since startup",
+		},
+		func() float64 {
+			s := stats()
+			if s.Hits+s.Misses == 0 {
+				return 1
+			}
+			return float64(s.Hits) / float64(s.Hits+s.Misses)
+		},
+	)
+	promauto.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "superchat_memdb_cache_hits_total",
+			Help: "Total number of message lookups served from memory",
+		},
+		func() float64 { return float64(stats().Hits) },
+	)
+	promauto.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "superchat_memdb_cache_misses_total",
+			Help: "Total number of message lookups that read SQLite",
+		},
+		func() float64 { return float64(stats().Misses) },
+	)
+}
+
 // RecordChannelSubscribers updates the subscriber count for a channel
 func (m *Metrics) RecordChannelSubscribers(channelID uint64, count int) {
 	m.channelSubscribers.WithLabelValues(uint64ToString(channelID)).Set(float64(count))
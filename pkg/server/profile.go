@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+// Profile field limits, in characters
+const (
+	maxDisplayNameLength = 32
+	maxPronounsLength    = 20
+	maxStatusLength      = 64
+	maxBioLength         = 500
+)
+
+// normalizeProfile trims the whitespace around each profile field
+func normalizeProfile(msg *protocol.SetProfileMessage) {
+	msg.DisplayName = strings.TrimSpace(msg.DisplayName)
+	msg.Pronouns = strings.TrimSpace(msg.Pronouns)
+	msg.Bio = strings.TrimSpace(msg.Bio)
+	msg.Status = strings.TrimSpace(msg.Status)
+}
+
+// validateProfile returns why a profile can't be saved, or "" if it can
+func validateProfile(msg *protocol.SetProfileMessage) string {
+	fields := []struct {
+		name      string
+		value     string
+		maxLength int
+		multiline bool
+	}{
+		{"Display name", msg.DisplayName, maxDisplayNameLength, false},
+		{"Pronouns", msg.Pronouns, maxPronounsLength, false},
+		{"Bio", msg.Bio, maxBioLength, true},
+		{"Status", msg.Status, maxStatusLength, false},
+	}
+	for _, f := range fields {
+		if !utf8.ValidString(f.value) {
+			return fmt.Sprintf("%s must be valid UTF-8", f.name)
+		}
+		if utf8.RuneCountInString(f.value) > f.maxLength {
+			return fmt.Sprintf("%s can be at most %d characters", f.name, f.maxLength)
+		}
+		for _, r := range f.value {
+			if unicode.IsControl(r) && !(f.multiline && r == '\n') {
+				return fmt.Sprintf("%s contains invalid characters", f.name)
+			}
+		}
+	}
+	return ""
+}
+
+// loadSessionProfile puts a user's display name and status on a session that just
+// logged in, for SERVER_PRESENCE
+func (s *Server) loadSessionProfile(sess *Session, userID int64) {
+	profile, err := s.db.GetUserProfile(userID)
+	if err != nil {
+		log.Printf("Session %d: failed to load profile: %v", sess.ID, err)
+		return
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if profile == nil {
+		sess.displayName = ""
+		sess.status = ""
+		return
+	}
+	sess.displayName = profile.DisplayName
+	sess.status = profile.Status
+}
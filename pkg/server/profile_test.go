@@ -0,0 +1,185 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidateProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   protocol.SetProfileMessage
+		valid bool
+	}{
+		{"empty", protocol.SetProfileMessage{}, true},
+		{"full", protocol.SetProfileMessage{DisplayName: "Ålice", Pronouns: "she/her", Bio: "Line one\nLine two", Status: "in a meeting"}, true},
+		{"display name at the limit in runes", protocol.SetProfileMessage{DisplayName: strings.Repeat("é", maxDisplayNameLength)}, true},
+		{"display name too long", protocol.SetProfileMessage{DisplayName: strings.Repeat("a", maxDisplayNameLength+1)}, false},
+		{"bio too long", protocol.SetProfileMessage{Bio: strings.Repeat("a", maxBioLength+1)}, false},
+		{"newline in status", protocol.SetProfileMessage{Status: "in a\nmeeting"}, false},
+		{"escape in bio", protocol.SetProfileMessage{Bio: "\x1b[31mred"}, false},
+		{"invalid UTF-8", protocol.SetProfileMessage{Pronouns: "\xff"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := validateProfile(&tt.msg); (reason == "") != tt.valid {
+				t.Errorf("validateProfile = %q, want valid=%v", reason, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword failed: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", string(hash), 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	setProfile := func(sess *Session, msg *protocol.SetProfileMessage) *protocol.ProfileUpdatedMessage {
+		t.Helper()
+		if err := srv.handleSetProfile(sess, dmFrame(t, protocol.TypeSetProfile, msg)); err != nil {
+			t.Fatalf("handleSetProfile failed: %v", err)
+		}
+		resp := &protocol.ProfileUpdatedMessage{}
+		frames := readFrames(t, sess)
+		if findFrame(frames, protocol.TypeProfileUpdated) == nil {
+			return nil
+		}
+		decodeFrame(t, frames, protocol.TypeProfileUpdated, resp)
+		return resp
+	}
+
+	// Anonymous users can't have a profile
+	anon := testSession(srv)
+	anon.Nickname = "guest"
+	if resp := setProfile(anon, &protocol.SetProfileMessage{DisplayName: "Guest"}); resp != nil {
+		t.Fatalf("Expected anonymous session to be refused, got %+v", resp)
+	}
+
+	alice := testSession(srv)
+	alice.Nickname = "alice"
+	alice.UserID = &aliceID
+	if resp := setProfile(alice, &protocol.SetProfileMessage{Status: strings.Repeat("a", maxStatusLength+1)}); resp == nil || resp.Success {
+		t.Fatalf("Expected overlong status to be refused, got %+v", resp)
+	}
+
+	watcher := testSession(srv)
+	watcher.Nickname = "watcher"
+	resp := setProfile(alice, &protocol.SetProfileMessage{DisplayName: " Alice ", Pronouns: "she/her", Bio: "Hi", Status: "in a meeting"})
+	if resp == nil || !resp.Success {
+		t.Fatalf("Expected profile to be saved, got %+v", resp)
+	}
+
+	// Everyone hears about it
+	presence := &protocol.ServerPresenceMessage{}
+	decodeFrame(t, readFrames(t, watcher), protocol.TypeServerPresence, presence)
+	if presence.Nickname != "alice" || presence.DisplayName != "Alice" || presence.Status != "in a meeting" || presence.Away {
+		t.Errorf("Expected presence with the new profile, got %+v", presence)
+	}
+
+	getUserInfo := func(nickname string) *protocol.UserInfoMessage {
+		t.Helper()
+		if err := srv.handleGetUserInfo(watcher, dmFrame(t, protocol.TypeGetUserInfo, &protocol.GetUserInfoMessage{Nickname: nickname})); err != nil {
+			t.Fatalf("handleGetUserInfo failed: %v", err)
+		}
+		info := &protocol.UserInfoMessage{}
+		decodeFrame(t, readFrames(t, watcher), protocol.TypeUserInfo, info)
+		return info
+	}
+	info := getUserInfo("alice")
+	if !info.Online || info.Away || info.DisplayName != "Alice" || info.Pronouns != "she/her" || info.Bio != "Hi" || info.Status != "in a meeting" {
+		t.Errorf("Expected USER_INFO with the profile, got %+v", info)
+	}
+	if info := getUserInfo("guest"); info.IsRegistered || info.DisplayName != "" {
+		t.Errorf("Expected no profile for an anonymous user, got %+v", info)
+	}
+
+	// A new login picks up the profile
+	login := testSession(srv)
+	if err := srv.handleAuthRequest(login, dmFrame(t, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: "alice", Password: "secret"})); err != nil {
+		t.Fatalf("handleAuthRequest failed: %v", err)
+	}
+	if login.displayName != "Alice" || login.status != "in a meeting" {
+		t.Errorf("Expected login to load the profile, got %q/%q", login.displayName, login.status)
+	}
+
+	// Logging out drops it
+	if err := srv.handleLogout(login, dmFrame(t, protocol.TypeLogout, &protocol.LogoutMessage{})); err != nil {
+		t.Fatalf("handleLogout failed: %v", err)
+	}
+	if login.displayName != "" || login.status != "" {
+		t.Errorf("Expected logout to clear the profile, got %q/%q", login.displayName, login.status)
+	}
+}
+
+func TestAwayState(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	alice := testSession(srv)
+	alice.Nickname = "alice"
+	watcher := testSession(srv)
+	watcher.Nickname = "watcher"
+
+	now := time.Now().UnixMilli()
+	srv.sessions.UpdateSessionActivity(watcher, now, true)
+
+	// Pings don't count as input
+	srv.config.AwayAfterSeconds = 60
+	alice.lastInputAt = now - 61*1000
+	if back := srv.sessions.UpdateSessionActivity(alice, now, false); back {
+		t.Error("Expected a ping not to bring anyone back")
+	}
+	srv.markIdleSessionsAway()
+	if !alice.away || watcher.away {
+		t.Fatalf("Expected only alice to be away, got alice=%v watcher=%v", alice.away, watcher.away)
+	}
+	presence := &protocol.ServerPresenceMessage{}
+	decodeFrame(t, readFrames(t, watcher), protocol.TypeServerPresence, presence)
+	if presence.Nickname != "alice" || !presence.Away || !presence.Online {
+		t.Errorf("Expected alice to be announced as away, got %+v", presence)
+	}
+
+	// Sessions that are already away aren't announced again
+	srv.markIdleSessionsAway()
+	if frames := readFrames(t, watcher); len(frames) != 0 {
+		t.Errorf("Expected no presence for a session already away, got %d frames", len(frames))
+	}
+
+	if err := srv.handleGetUserInfo(watcher, dmFrame(t, protocol.TypeGetUserInfo, &protocol.GetUserInfoMessage{Nickname: "alice"})); err != nil {
+		t.Fatalf("handleGetUserInfo failed: %v", err)
+	}
+	info := &protocol.UserInfoMessage{}
+	decodeFrame(t, readFrames(t, watcher), protocol.TypeUserInfo, info)
+	if !info.Online || !info.Away {
+		t.Errorf("Expected USER_INFO to show alice away, got %+v", info)
+	}
+
+	// Input brings her back
+	if back := srv.sessions.UpdateSessionActivity(alice, now, true); !back || alice.away {
+		t.Error("Expected input to bring alice back")
+	}
+	if back := srv.sessions.UpdateSessionActivity(alice, now, true); back {
+		t.Error("Expected only the first input to report coming back")
+	}
+
+	// Disabled
+	srv.config.AwayAfterSeconds = -1
+	alice.lastInputAt = 0
+	srv.markIdleSessionsAway()
+	if alice.away {
+		t.Error("Expected nobody to go away when disabled")
+	}
+}
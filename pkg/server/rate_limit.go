@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket holds the tokens left for one key and when they were last refilled
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// messageRateLimiter is a token bucket limiter for POST_MESSAGE and EDIT_MESSAGE.
+// Buckets start full with one minute's worth of tokens (so short bursts are fine)
+// and refill continuously at the configured messages-per-minute rate.
+type messageRateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+// newMessageRateLimiter creates a limiter for perMinute messages (0 = unlimited)
+func newMessageRateLimiter(perMinute uint16) *messageRateLimiter {
+	return &messageRateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(perMinute),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// sessionRateKey and userRateKey name the buckets for a session and a registered user
+func sessionRateKey(sessionID uint64) string { return fmt.Sprintf("session:%d", sessionID) }
+func userRateKey(userID int64) string        { return fmt.Sprintf("user:%d", userID) }
+
+// allow takes a token from the bucket of every key, or from none of them if any bucket is
+// empty. In that case it returns how long until a token is available in all of them.
+// A nil limiter allows everything.
+func (l *messageRateLimiter) allow(now time.Time, keys ...string) (bool, time.Duration) {
+	if l == nil || l.burst == 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var wait time.Duration
+	buckets := make([]*tokenBucket, 0, len(keys))
+	for _, key := range keys {
+		bucket := l.refill(key, now)
+		if bucket.tokens < 1 {
+			seconds := (1 - bucket.tokens) / l.rate
+			if d := time.Duration(math.Ceil(seconds * float64(time.Second))); d > wait {
+				wait = d
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if wait > 0 {
+		return false, wait
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return true, 0
+}
+
+// refill returns the bucket for a key, topped up for the time since its last use.
+// Caller must hold l.mu.
+func (l *messageRateLimiter) refill(key string, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = bucket
+		return bucket
+	}
+	if elapsed := now.Sub(bucket.updated).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+		bucket.updated = now
+	}
+	return bucket
+}
+
+// forget drops the bucket for a key (e.g. when its session ends)
+func (l *messageRateLimiter) forget(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, key)
+}
+
+// prune drops buckets that have refilled completely, since they behave like new ones
+func (l *messageRateLimiter) prune(now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
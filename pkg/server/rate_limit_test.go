@@ -0,0 +1,88 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageRateLimiterBurstAndRefill(t *testing.T) {
+	limiter := newMessageRateLimiter(6) // one token every 10 seconds
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 6; i++ {
+		if ok, _ := limiter.allow(now, "session:1"); !ok {
+			t.Fatalf("Message %d should be allowed within the burst", i+1)
+		}
+	}
+
+	ok, retryAfter := limiter.allow(now, "session:1")
+	if ok {
+		t.Fatal("Expected the 7th message to be rate limited")
+	}
+	if retryAfter != 10*time.Second {
+		t.Errorf("Expected retry after 10s, got %v", retryAfter)
+	}
+
+	// Other keys have their own bucket
+	if ok, _ := limiter.allow(now, "session:2"); !ok {
+		t.Error("Expected a different session to be allowed")
+	}
+
+	// Partially refilled bucket reports the remaining wait
+	if ok, retryAfter := limiter.allow(now.Add(4*time.Second), "session:1"); ok || retryAfter != 6*time.Second {
+		t.Errorf("Expected retry after 6s, got ok=%v retryAfter=%v", ok, retryAfter)
+	}
+
+	if ok, _ := limiter.allow(now.Add(10*time.Second), "session:1"); !ok {
+		t.Error("Expected a message to be allowed after refill")
+	}
+}
+
+func TestMessageRateLimiterMultipleKeys(t *testing.T) {
+	limiter := newMessageRateLimiter(2)
+	now := time.Unix(1000, 0)
+
+	// Two sessions of the same user share the user bucket
+	if ok, _ := limiter.allow(now, "session:1", "user:7"); !ok {
+		t.Fatal("Expected first message to be allowed")
+	}
+	if ok, _ := limiter.allow(now, "session:2", "user:7"); !ok {
+		t.Fatal("Expected second message to be allowed")
+	}
+	if ok, _ := limiter.allow(now, "session:3", "user:7"); ok {
+		t.Fatal("Expected user bucket to be exhausted")
+	}
+
+	// A rejected message doesn't consume tokens from the other buckets
+	if ok, _ := limiter.allow(now, "session:3"); !ok {
+		t.Error("Expected session:3 to still have its full bucket")
+	}
+}
+
+func TestMessageRateLimiterDisabledAndPrune(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	disabled := newMessageRateLimiter(0)
+	for i := 0; i < 100; i++ {
+		if ok, _ := disabled.allow(now, "session:1"); !ok {
+			t.Fatal("Expected a zero rate to mean unlimited")
+		}
+	}
+
+	var nilLimiter *messageRateLimiter
+	if ok, _ := nilLimiter.allow(now, "session:1"); !ok {
+		t.Error("Expected a nil limiter to allow everything")
+	}
+
+	limiter := newMessageRateLimiter(6)
+	limiter.allow(now, "session:1")
+	limiter.allow(now.Add(30*time.Second), "session:2")
+
+	limiter.prune(now.Add(31 * time.Second))
+	if _, ok := limiter.buckets["session:1"]; ok {
+		t.Error("Expected refilled bucket to be pruned")
+	}
+	if _, ok := limiter.buckets["session:2"]; !ok {
+		t.Error("Expected bucket still refilling to be kept")
+	}
+}
@@ -17,8 +17,9 @@ import (
 // SafeConn solves this by encapsulating both the connection and its write mutex,
 // making it impossible to write without proper synchronization.
 type SafeConn struct {
-	conn net.Conn
-	mu   sync.Mutex // Protects writes to conn
+	conn     net.Conn
+	mu       sync.Mutex // Protects writes to conn and compress
+	compress bool       // Client opted in to compressed frames (SET_COMPRESSION)
 }
 
 // NewSafeConn wraps a net.Conn with write synchronization
@@ -30,12 +31,23 @@ func NewSafeConn(conn net.Conn) *SafeConn {
 
 // EncodeFrame encodes and sends a protocol frame with automatic write synchronization.
 // This is the ONLY way to write frames to the connection - the raw conn is private.
+// Large frames are compressed if the client enabled compression.
 func (sc *SafeConn) EncodeFrame(frame *protocol.Frame) error {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
+	if sc.compress {
+		frame = protocol.CompressFrame(frame)
+	}
 	return protocol.EncodeFrame(sc.conn, frame)
 }
 
+// SetCompression enables or disables compression of large outgoing frames
+func (sc *SafeConn) SetCompression(enabled bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.compress = enabled
+}
+
 // ReadFrame reads a protocol frame from the connection.
 // Reads don't need write synchronization.
 func (sc *SafeConn) ReadFrame() (*protocol.Frame, error) {
@@ -53,7 +65,7 @@ func (sc *SafeConn) RemoteAddr() net.Addr {
 }
 
 // WriteBytes writes raw bytes to the connection with synchronization.
-// Used for pre-encoded frames in broadcast operations.
+// Used for pre-encoded frames in broadcast operations (these are never compressed).
 func (sc *SafeConn) WriteBytes(data []byte) error {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
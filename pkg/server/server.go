@@ -26,6 +26,7 @@ import (
 const (
 	defaultDirectoryPort         = 6465
 	directoryHealthCheckInterval = 5 * time.Minute
+	maxExpiredIDsPerNotice       = 1000 // 8 KB of IDs per MESSAGES_EXPIRED frame
 )
 
 var (
@@ -35,16 +36,19 @@ var (
 
 // Server represents the SuperChat server
 type Server struct {
-	db          *database.MemDB
-	listener    net.Listener
-	sshListener net.Listener
-	sessions    *SessionManager
-	config      ServerConfig
-	configPath  string
-	shutdown    chan struct{}
-	wg          sync.WaitGroup
-	metrics     *Metrics
-	startTime   time.Time // Server start time for uptime calculation
+	db            *database.MemDB
+	dbLock        *DatabaseLock
+	listener      net.Listener
+	sshListener   net.Listener
+	tlsListener   net.Listener
+	adminListener net.Listener
+	sessions      *SessionManager
+	config        ServerConfig
+	configPath    string
+	shutdown      chan struct{}
+	wg            sync.WaitGroup
+	metrics       *Metrics
+	startTime     time.Time // Server start time for uptime calculation
 
 	// Connection deltas for periodic reporting
 	connectionsSinceReport    atomic.Int64
@@ -57,6 +61,24 @@ type Server struct {
 	discoveryRateLimitMu   sync.Mutex
 	autoRegisterMu         sync.Mutex
 	autoRegisterAttempts   map[string][]time.Time
+
+	// Encrypted DMs waiting for participant keys (channelID -> initiator allows unencrypted)
+	pendingDMMu sync.Mutex
+	pendingDMs  map[int64]bool
+
+	// POST_MESSAGE/EDIT_MESSAGE rate limiting (per session and per registered user)
+	messageLimiter *messageRateLimiter
+
+	// Who is typing where (TYPING_START/TYPING_STOP)
+	typing *typingTracker
+
+	// Connection accounting per IP (TCP, SSH and WebSocket)
+	connLimiter    *connectionLimiter
+	trustedProxies []*net.IPNet // Proxies whose X-Forwarded-For is believed for /ws
+
+	// Channel creations per user in the last hour (userID -> creation times)
+	channelCreateMu sync.Mutex
+	channelCreates  map[int64][]time.Time
 }
 
 // ServerConfig holds server configuration
@@ -65,6 +87,10 @@ type ServerConfig struct {
 	SSHPort                 int
 	HTTPPort                int // Public HTTP port for /servers.json (default: 8080, 0 = disabled)
 	SSHHostKeyPath          string
+	TLSPort                 int // TLS port for the binary protocol (default: 6467, <= 0 = disabled)
+	TLSCertPath             string
+	TLSKeyPath              string
+	TLSGenerateCert         bool // Generate a self-signed certificate if none exists
 	MaxConnectionsPerIP     uint8
 	MessageRateLimit        uint16
 	MaxChannelCreates       uint16
@@ -83,7 +109,29 @@ type ServerConfig struct {
 	MaxUsers       uint32 // Max concurrent users (0 = unlimited)
 
 	// Admin configuration
-	AdminUsers []string // List of admin user nicknames
+	AdminUsers  []string // List of admin user nicknames
+	AdminSocket string   // Unix socket for scd admin ("" = disabled)
+
+	// Reverse proxies (IPs or CIDR ranges) trusted to set X-Forwarded-For on /ws
+	TrustedProxies []string
+
+	// Message journal fsync policy: "always", "batch" or "interval"
+	JournalSync           string
+	JournalSyncIntervalMs int // Fsync interval for the "interval" policy
+
+	// Message cache: threads beyond the budget or unused for the hot window are dropped
+	// from memory and read from SQLite again when needed (<= 0 = no limit)
+	MemoryBudgetMB int
+	HotWindowHours int
+
+	// Login brute-force protection: failures before an account or IP address is locked
+	// out (<= 0 = never), and the first lockout, which doubles with further failures
+	LoginMaxFailures      int
+	LoginMaxFailuresPerIP int
+	LoginLockoutSeconds   int
+
+	// Idle time before a user is shown as away (<= 0 = never)
+	AwayAfterSeconds int
 }
 
 // DefaultConfig returns default server configuration
@@ -93,6 +141,10 @@ func DefaultConfig() ServerConfig {
 		SSHPort:                 6466,
 		HTTPPort:                8080, // Public HTTP server for /servers.json
 		SSHHostKeyPath:          "~/.superchat/ssh_host_key",
+		TLSPort:                 6467,
+		TLSCertPath:             "~/.superchat/tls_cert.pem",
+		TLSKeyPath:              "~/.superchat/tls_key.pem",
+		TLSGenerateCert:         true,
 		MaxConnectionsPerIP:     10,
 		MessageRateLimit:        10,   // per minute
 		MaxChannelCreates:       5,    // per hour
@@ -109,25 +161,82 @@ func DefaultConfig() ServerConfig {
 		ServerName:     "SuperChat Server",
 		ServerDesc:     "A SuperChat server",
 		MaxUsers:       0, // unlimited
+
+		TrustedProxies: []string{"127.0.0.1", "::1"},
+
+		JournalSync:           "batch",
+		JournalSyncIntervalMs: 1000,
+
+		MemoryBudgetMB: 512,
+		HotWindowHours: 72,
+
+		LoginMaxFailures:      5,
+		LoginMaxFailuresPerIP: 20,
+		LoginLockoutSeconds:   60,
+
+		AwayAfterSeconds: 600,
 	}
 }
 
 // NewServer creates a new server instance
 func NewServer(dbPath string, config ServerConfig, configPath string) (*Server, error) {
+	// Held until Stop, so scd admin doesn't write to the database behind the in-memory cache
+	dbLock, err := LockDatabase(dbPath)
+	if errors.Is(err, ErrDatabaseInUse) {
+		return nil, fmt.Errorf("database %s is in use by another server", dbPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := openServer(dbPath, config, configPath)
+	if err != nil {
+		dbLock.Close()
+		return nil, err
+	}
+	server.dbLock = dbLock
+	return server, nil
+}
+
+// openServer opens the database and creates the server (NewServer holds the database lock)
+func openServer(dbPath string, config ServerConfig, configPath string) (*Server, error) {
 	// Open underlying SQLite database for snapshots
 	sqliteDB, err := database.Open(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	trustedProxies, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		sqliteDB.Close()
+		return nil, err
+	}
+
 	// Seed default channels if they don't exist
 	if err := sqliteDB.SeedDefaultChannels(); err != nil {
 		sqliteDB.Close()
 		return nil, fmt.Errorf("failed to seed channels: %w", err)
 	}
 
-	// Create in-memory database with 30-second snapshot interval
-	memDB, err := database.NewMemDB(sqliteDB, 30*time.Second)
+	journalSync, err := database.ParseJournalSyncPolicy(config.JournalSync)
+	if err != nil {
+		sqliteDB.Close()
+		return nil, err
+	}
+
+	// Create in-memory database with 30-second snapshot interval. Messages posted between
+	// snapshots are journaled next to the database, so a crash doesn't lose them.
+	memDB, err := database.NewMemDBWithOptions(sqliteDB, 30*time.Second, database.MemDBOptions{
+		Journal: database.JournalConfig{
+			Dir:          dbPath + ".journal",
+			SyncPolicy:   journalSync,
+			SyncInterval: time.Duration(config.JournalSyncIntervalMs) * time.Millisecond,
+		},
+		Cache: database.CacheConfig{
+			MemoryBudget: int64(max(config.MemoryBudgetMB, 0)) << 20,
+			HotWindow:    time.Duration(max(config.HotWindowHours, 0)) * time.Hour,
+		},
+	})
 	if err != nil {
 		sqliteDB.Close()
 		return nil, fmt.Errorf("failed to create in-memory database: %w", err)
@@ -141,6 +250,7 @@ func NewServer(dbPath string, config ServerConfig, configPath string) (*Server,
 	}
 
 	metrics := NewMetrics()
+	metrics.RegisterCacheMetrics(memDB.CacheStats)
 	sessions := NewSessionManager(memDB, config.SessionTimeoutSeconds)
 	sessions.SetMetrics(metrics)
 
@@ -155,6 +265,12 @@ func NewServer(dbPath string, config ServerConfig, configPath string) (*Server,
 		verificationChallenges: make(map[uint64]uint64),
 		discoveryRateLimits:    make(map[string]*discoveryRateLimiter),
 		autoRegisterAttempts:   make(map[string][]time.Time),
+		pendingDMs:             make(map[int64]bool),
+		messageLimiter:         newMessageRateLimiter(config.MessageRateLimit),
+		typing:                 newTypingTracker(),
+		connLimiter:            newConnectionLimiter(config.MaxConnectionsPerIP),
+		trustedProxies:         trustedProxies,
+		channelCreates:         make(map[int64][]time.Time),
 	}
 
 	return server, nil
@@ -240,7 +356,7 @@ func (s *Server) EnableDebugLogging() {
 	debugLog.Println("Debug logging enabled")
 }
 
-// Start starts the TCP and SSH servers
+// Start starts the TCP, TLS and SSH servers
 func (s *Server) Start() error {
 	// Start TCP server
 	addr := fmt.Sprintf(":%d", s.config.TCPPort)
@@ -280,6 +396,27 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to start SSH server: %w", err)
 	}
 
+	// Start TLS server
+	if err := s.startTLSServer(lc); err != nil {
+		s.listener.Close()
+		if s.sshListener != nil {
+			s.sshListener.Close()
+		}
+		return fmt.Errorf("failed to start TLS server: %w", err)
+	}
+
+	// Start admin socket
+	if err := s.startAdminSocket(); err != nil {
+		s.listener.Close()
+		if s.sshListener != nil {
+			s.sshListener.Close()
+		}
+		if s.tlsListener != nil {
+			s.tlsListener.Close()
+		}
+		return fmt.Errorf("failed to start admin socket: %w", err)
+	}
+
 	// Start metrics HTTP server (internal only - never expose publicly!)
 	go func() {
 		metricsMux := http.NewServeMux()
@@ -321,6 +458,10 @@ func (s *Server) Start() error {
 	s.wg.Add(1)
 	go s.sessionCleanupLoop()
 
+	// Start typing indicator expiry goroutine
+	s.wg.Add(1)
+	go s.typingLoop()
+
 	// Start message retention cleanup goroutine
 	s.wg.Add(1)
 	go s.retentionCleanupLoop()
@@ -363,6 +504,18 @@ func (s *Server) Stop() error {
 		log.Println("SSH listener closed")
 	}
 
+	if s.tlsListener != nil {
+		s.tlsListener.Close()
+		s.tlsListener = nil
+		log.Println("TLS listener closed")
+	}
+
+	if s.adminListener != nil {
+		s.adminListener.Close() // Also removes the socket file
+		s.adminListener = nil
+		log.Println("Admin socket closed")
+	}
+
 	// Notify all connected clients before closing connections
 	log.Println("Notifying connected clients of shutdown...")
 	s.notifyClientsOfShutdown()
@@ -377,7 +530,9 @@ func (s *Server) Stop() error {
 
 	// Close in-memory database (triggers final snapshot to SQLite)
 	log.Println("Flushing in-memory database to disk...")
-	if err := s.db.Close(); err != nil {
+	err := s.db.Close()
+	s.dbLock.Close()
+	if err != nil {
 		log.Printf("Error during database close: %v", err)
 		return err
 	}
@@ -443,23 +598,32 @@ func (s *Server) acceptLoop() {
 		}
 
 		// Handle connection directly in goroutine
-		go s.handleConnection(conn)
+		go s.handleConnection(conn, "tcp")
 	}
 }
 
-// handleConnection handles initial connection setup, then spawns message loop goroutine
-func (s *Server) handleConnection(conn net.Conn) {
-	startTime := time.Now()
-
+// handleConnection admits a plain TCP connection, then serves it
+func (s *Server) handleConnection(conn net.Conn, transport string) {
 	// Disable Nagle's algorithm for immediate sends
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
 		tcpConn.SetNoDelay(true)
 	}
 
-	afterTCP := time.Now()
+	conn, ok := s.admitConnection(conn, transport)
+	if !ok {
+		return
+	}
+
+	s.serveConnection(conn, transport)
+}
+
+// serveConnection handles initial setup of an admitted connection, then spawns message loop goroutine.
+// transport is "tcp" for plain connections and "tls" for connections from the TLS listener.
+func (s *Server) serveConnection(conn net.Conn, transport string) {
+	startTime := time.Now()
 
 	// Create session
-	sess, err := s.sessions.CreateSession(nil, "", "tcp", conn)
+	sess, err := s.sessions.CreateSession(nil, "", transport, conn)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
 		conn.Close()
@@ -485,11 +649,10 @@ func (s *Server) handleConnection(conn net.Conn) {
 	// Log timing if it took more than 100ms
 	totalTime := afterServerConfig.Sub(startTime)
 	if totalTime > 100*time.Millisecond {
-		debugLog.Printf("Session %d: SLOW connection setup: total=%v (tcp=%v, createSess=%v, sendConfig=%v)",
+		debugLog.Printf("Session %d: SLOW connection setup: total=%v (createSess=%v, sendConfig=%v)",
 			sess.ID,
 			totalTime,
-			afterTCP.Sub(startTime),
-			afterCreateSession.Sub(afterTCP),
+			afterCreateSession.Sub(startTime),
 			afterServerConfig.Sub(afterCreateSession))
 	}
 
@@ -528,7 +691,9 @@ func (s *Server) messageLoop(sess *Session, conn net.Conn) {
 		debugLog.Printf("Session %d ← RECV: Type=0x%02X Flags=0x%02X PayloadLen=%d", sess.ID, frame.Type, frame.Flags, len(frame.Payload))
 
 		// Update session activity (buffered write, rate-limited to half of session timeout)
-		s.sessions.UpdateSessionActivity(sess, time.Now().UnixMilli())
+		if s.sessions.UpdateSessionActivity(sess, time.Now().UnixMilli(), frame.Type != protocol.TypePing) {
+			s.notifyServerPresence(sess, true)
+		}
 
 		// Track message received
 		if s.metrics != nil {
@@ -569,6 +734,10 @@ func (s *Server) handleMessage(sess *Session, frame *protocol.Frame) error {
 		return s.handleLeaveChannel(sess, frame)
 	case protocol.TypeCreateChannel:
 		return s.handleCreateChannel(sess, frame)
+	case protocol.TypeCreateSubchannel:
+		return s.handleCreateSubchannel(sess, frame)
+	case protocol.TypeGetSubchannels:
+		return s.handleGetSubchannels(sess, frame)
 	case protocol.TypeListMessages:
 		return s.handleListMessages(sess, frame)
 	case protocol.TypePostMessage:
@@ -597,6 +766,32 @@ func (s *Server) handleMessage(sess *Session, frame *protocol.Frame) error {
 		return s.handleGetUnreadCounts(sess, frame)
 	case protocol.TypeUpdateReadState:
 		return s.handleUpdateReadState(sess, frame)
+	case protocol.TypeStartDM:
+		return s.handleStartDM(sess, frame)
+	case protocol.TypeAddDMParticipant:
+		return s.handleAddDMParticipant(sess, frame)
+	case protocol.TypeListDMs:
+		return s.handleListDMs(sess, frame)
+	case protocol.TypeProvidePublicKey:
+		return s.handleProvidePublicKey(sess, frame)
+	case protocol.TypeAllowUnencrypted:
+		return s.handleAllowUnencrypted(sess, frame)
+	case protocol.TypeProvideChannelKeys:
+		return s.handleProvideChannelKeys(sess, frame)
+	case protocol.TypeSetCompression:
+		return s.handleSetCompression(sess, frame)
+	case protocol.TypeSearchMessages:
+		return s.handleSearchMessages(sess, frame)
+	case protocol.TypeAddReaction:
+		return s.handleAddReaction(sess, frame)
+	case protocol.TypeRemoveReaction:
+		return s.handleRemoveReaction(sess, frame)
+	case protocol.TypeListMentions:
+		return s.handleListMentions(sess, frame)
+	case protocol.TypeMarkMentionsRead:
+		return s.handleMarkMentionsRead(sess, frame)
+	case protocol.TypeGetMessageHistory:
+		return s.handleGetMessageHistory(sess, frame)
 	case protocol.TypePing:
 		return s.handlePing(sess, frame)
 	case protocol.TypeDisconnect:
@@ -633,6 +828,28 @@ func (s *Server) handleMessage(sess *Session, frame *protocol.Frame) error {
 		return s.handleDeleteUser(sess, frame)
 	case protocol.TypeDeleteChannel:
 		return s.handleDeleteChannel(sess, frame)
+	case protocol.TypeListAdminActions:
+		return s.handleListAdminActions(sess, frame)
+	case protocol.TypeSetUserFlags:
+		return s.handleSetUserFlags(sess, frame)
+	case protocol.TypeListLoginLockouts:
+		return s.handleListLoginLockouts(sess, frame)
+	case protocol.TypeClearLoginLockout:
+		return s.handleClearLoginLockout(sess, frame)
+	case protocol.TypeAuthTwoFactor:
+		return s.handleAuthTwoFactor(sess, frame)
+	case protocol.TypeEnable2FA:
+		return s.handleEnable2FA(sess, frame)
+	case protocol.TypeConfirm2FA:
+		return s.handleConfirm2FA(sess, frame)
+	case protocol.TypeDisable2FA:
+		return s.handleDisable2FA(sess, frame)
+	case protocol.TypeSetProfile:
+		return s.handleSetProfile(sess, frame)
+	case protocol.TypeTypingStart:
+		return s.handleTypingStart(sess, frame)
+	case protocol.TypeTypingStop:
+		return s.handleTypingStop(sess, frame)
 	default:
 		// Unknown or unimplemented message type
 		return s.sendError(sess, 1001, "Unsupported message type")
@@ -649,6 +866,8 @@ func (s *Server) removeSession(sessionID uint64) {
 	}
 
 	s.sessions.RemoveSession(sessionID)
+	s.messageLimiter.forget(sessionRateKey(sessionID))
+	s.clearTyping(sessionID)
 
 	if ok {
 		if joined != nil {
@@ -670,6 +889,7 @@ func (s *Server) sendServerConfig(sess *Session) error {
 		MaxThreadSubscriptions:  s.config.MaxThreadSubscriptions,
 		MaxChannelSubscriptions: s.config.MaxChannelSubscriptions,
 		DirectoryEnabled:        s.config.DirectoryEnabled,
+		CompressionSupported:    true,
 	}
 
 	payload, err := msg.Encode()
@@ -693,11 +913,24 @@ func (s *Server) sendServerConfig(sess *Session) error {
 
 // sendError sends an ERROR message to a session
 func (s *Server) sendError(sess *Session, code uint16, message string) error {
-	msg := &protocol.ErrorMessage{
+	return s.sendErrorMessage(sess, &protocol.ErrorMessage{
 		ErrorCode: code,
 		Message:   message,
-	}
+	})
+}
 
+// sendRateLimitError sends ERROR 5001 with a hint for when the client may send again
+func (s *Server) sendRateLimitError(sess *Session, retryAfter time.Duration) error {
+	retryAfterMs := uint64(retryAfter.Milliseconds())
+	return s.sendErrorMessage(sess, &protocol.ErrorMessage{
+		ErrorCode:    protocol.ErrCodeMessageRateLimit,
+		Message:      fmt.Sprintf("Slow down! Message rate limit is %d per minute", s.config.MessageRateLimit),
+		RetryAfterMs: &retryAfterMs,
+	})
+}
+
+// sendErrorMessage encodes and sends an ERROR message
+func (s *Server) sendErrorMessage(sess *Session, msg *protocol.ErrorMessage) error {
 	payload, err := msg.Encode()
 	if err != nil {
 		return err
@@ -720,14 +953,25 @@ func (s *Server) sendError(sess *Session, code uint16, message string) error {
 // Returns false for anonymous users (nil UserID)
 // Returns true if session nickname matches any admin user in config
 func (s *Server) isAdmin(sess *Session) bool {
+	sess.mu.RLock()
+	userID := sess.UserID
+	flags := sess.UserFlags
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+
 	// Anonymous users can never be admin
-	if sess.UserID == nil {
+	if userID == nil {
 		return false
 	}
 
+	// Users promoted in the database (scd admin user promote)
+	if protocol.UserFlags(flags).IsAdmin() {
+		return true
+	}
+
 	// Check if nickname is in admin list
 	for _, adminNick := range s.config.AdminUsers {
-		if sess.Nickname == adminNick {
+		if nickname == adminNick {
 			return true
 		}
 	}
@@ -735,6 +979,117 @@ func (s *Server) isAdmin(sess *Session) bool {
 	return false
 }
 
+// moderatorMaxBan is the longest ban a moderator can issue (admins have no limit)
+const moderatorMaxBan = 7 * 24 * time.Hour
+
+// isModerator checks if a session belongs to a user with the moderator flag.
+// Admins aren't moderators unless they have both flags; check isAdmin first.
+func (s *Server) isModerator(sess *Session) bool {
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	return sess.UserID != nil && protocol.UserFlags(sess.UserFlags).IsModerator()
+}
+
+// moderatorChannels returns the channels a moderator session is limited to (none = all)
+func (s *Server) moderatorChannels(sess *Session) ([]int64, error) {
+	sess.mu.RLock()
+	userID := sess.UserID
+	sess.mu.RUnlock()
+
+	if userID == nil {
+		return nil, errors.New("session is not logged in")
+	}
+	return s.db.GetModeratorChannels(*userID)
+}
+
+// canModerateChannel checks if a session may edit and delete other users' messages
+// in a channel: admins anywhere, moderators in public channels within their scope
+func (s *Server) canModerateChannel(sess *Session, channelID int64) bool {
+	if s.isAdmin(sess) {
+		return true
+	}
+	if !s.isModerator(sess) {
+		return false
+	}
+
+	// DMs are private to their participants
+	channel, err := s.db.GetChannel(channelID)
+	if err != nil || channel.IsPrivate {
+		return false
+	}
+
+	scope, err := s.moderatorChannels(sess)
+	if err != nil {
+		log.Printf("Failed to load moderator channels: %v", err)
+		return false
+	}
+	if len(scope) == 0 {
+		return true
+	}
+	for _, id := range scope {
+		if id == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// canBan checks if a session may ban users and IPs from the whole server: admins,
+// and moderators that aren't limited to some channels. Moderator bans must be
+// time-limited; scoped moderators ban from their channels (see canModerateChannel).
+func (s *Server) canBan(sess *Session) bool {
+	if s.isAdmin(sess) {
+		return true
+	}
+	if !s.isModerator(sess) {
+		return false
+	}
+	scope, err := s.moderatorChannels(sess)
+	if err != nil {
+		log.Printf("Failed to load moderator channels: %v", err)
+		return false
+	}
+	return len(scope) == 0
+}
+
+// checkModeratorBanDuration returns why a moderator can't issue a ban of this
+// length, or "" if they can
+func checkModeratorBanDuration(durationSeconds *uint64) string {
+	if durationSeconds == nil || *durationSeconds == 0 {
+		return "Moderators can only issue time-limited bans"
+	}
+	if *durationSeconds > uint64(moderatorMaxBan/time.Second) {
+		return fmt.Sprintf("Moderators can ban for at most %d days", int(moderatorMaxBan.Hours()/24))
+	}
+	return ""
+}
+
+// isStaff checks if a user (by ID, or else by nickname) is an admin or moderator
+func (s *Server) isStaff(userID *uint64, nickname *string) bool {
+	var user *database.User
+	var err error
+	if userID != nil {
+		user, err = s.db.GetUserByID(int64(*userID))
+	} else if nickname != nil {
+		user, err = s.db.GetUserByNickname(*nickname)
+	}
+	if err == nil && user != nil {
+		if protocol.UserFlags(user.UserFlags).IsSystem() {
+			return true
+		}
+		nickname = &user.Nickname
+	}
+
+	if nickname != nil {
+		for _, adminNick := range s.config.AdminUsers {
+			if *nickname == adminNick {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // metricsLoggingLoop periodically logs key metrics
 func (s *Server) metricsLoggingLoop() {
 	defer s.wg.Done()
@@ -774,6 +1129,8 @@ func (s *Server) sessionCleanupLoop() {
 			return
 		case <-ticker.C:
 			s.cleanupStaleSessions()
+			s.markIdleSessionsAway()
+			s.messageLimiter.prune(time.Now())
 		}
 	}
 }
@@ -798,6 +1155,17 @@ func (s *Server) cleanupStaleSessions() {
 	}
 }
 
+// markIdleSessionsAway tells everyone about users who went idle
+func (s *Server) markIdleSessionsAway() {
+	if s.config.AwayAfterSeconds <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(s.config.AwayAfterSeconds) * time.Second).UnixMilli()
+	for _, sess := range s.sessions.MarkIdleSessionsAway(cutoff) {
+		s.notifyServerPresence(sess, true)
+	}
+}
+
 // retentionCleanupLoop periodically cleans up old messages based on channel retention policies
 func (s *Server) retentionCleanupLoop() {
 	defer s.wg.Done()
@@ -820,15 +1188,21 @@ func (s *Server) retentionCleanupLoop() {
 }
 
 // cleanupExpiredMessages deletes messages older than their channel's retention policy
+// and tells the channels' clients to drop them
 func (s *Server) cleanupExpiredMessages() {
-	count, err := s.db.CleanupExpiredMessages()
+	expired, err := s.db.CleanupExpiredMessages()
+	if len(expired) > 0 {
+		log.Printf("Cleaned up %d expired messages", len(expired))
+		s.broadcastMessagesExpired(expired)
+	}
 	if err != nil {
 		log.Printf("Error cleaning up expired messages: %v", err)
 		return
 	}
 
-	if count > 0 {
-		log.Printf("Cleaned up %d expired messages", count)
+	// Forget login failures that no longer count
+	if _, err := s.db.CleanupLoginFailures(time.Now().Add(-loginFailureWindow).UnixMilli()); err != nil {
+		log.Printf("Error cleaning up login failures: %v", err)
 	}
 
 	// Also cleanup idle sessions from the database
@@ -844,6 +1218,53 @@ func (s *Server) cleanupExpiredMessages() {
 	}
 }
 
+// broadcastMessagesExpired sends MESSAGES_EXPIRED for purged messages, one notice per
+// channel/subchannel, split so each frame stays well below the payload limit
+func (s *Server) broadcastMessagesExpired(messages []*database.Message) {
+	type location struct {
+		channelID    int64
+		subchannelID int64 // 0 = channel root
+	}
+
+	byLocation := make(map[location][]uint64)
+	var order []location
+	for _, msg := range messages {
+		loc := location{channelID: msg.ChannelID}
+		if msg.SubchannelID != nil {
+			loc.subchannelID = *msg.SubchannelID
+		}
+		if _, exists := byLocation[loc]; !exists {
+			order = append(order, loc)
+		}
+		byLocation[loc] = append(byLocation[loc], uint64(msg.ID))
+	}
+
+	for _, loc := range order {
+		var subchannelID *uint64
+		if loc.subchannelID != 0 {
+			id := uint64(loc.subchannelID)
+			subchannelID = &id
+		}
+
+		ids := byLocation[loc]
+		for len(ids) > 0 {
+			n := len(ids)
+			if n > maxExpiredIDsPerNotice {
+				n = maxExpiredIDsPerNotice
+			}
+			notice := &protocol.MessagesExpiredMessage{
+				ChannelID:    uint64(loc.channelID),
+				SubchannelID: subchannelID,
+				MessageIDs:   ids[:n],
+			}
+			if err := s.broadcastToChannel(loc.channelID, protocol.TypeMessagesExpired, notice); err != nil {
+				log.Printf("Failed to broadcast expired messages for channel %d: %v", loc.channelID, err)
+			}
+			ids = ids[n:]
+		}
+	}
+}
+
 // directoryHealthCheckLoop periodically verifies registered servers are reachable.
 func (s *Server) directoryHealthCheckLoop() {
 	defer s.wg.Done()
@@ -5,6 +5,7 @@ import (
 	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/aeolun/superchat/pkg/database"
 )
@@ -15,6 +16,22 @@ type ChannelSubscription struct {
 	SubchannelID *uint64
 }
 
+// channelSubKey is the comparable form of ChannelSubscription used as a map key
+// (SubchannelID is a pointer, so the struct itself would compare by address)
+type channelSubKey struct {
+	channelID    uint64
+	subchannelID uint64 // 0 = channel root
+}
+
+// key returns the map key for this subscription
+func (c ChannelSubscription) key() channelSubKey {
+	k := channelSubKey{channelID: c.ChannelID}
+	if c.SubchannelID != nil {
+		k.subchannelID = *c.SubchannelID
+	}
+	return k
+}
+
 // Session represents an active client connection
 type Session struct {
 	ID                     uint64
@@ -26,12 +43,23 @@ type Session struct {
 	Conn                   *SafeConn    // TCP connection with automatic write synchronization
 	RemoteAddr             string       // Remote address (for rate limiting)
 	JoinedChannel          *int64       // Currently joined channel ID
-	mu                     sync.RWMutex // Protects Nickname, UserFlags, Shadowbanned, and JoinedChannel
+	EncryptionKeyID        *int64       // Encryption key provided via PROVIDE_PUBLIC_KEY (nil if none)
+	mu                     sync.RWMutex // Protects Nickname, UserFlags, Shadowbanned, JoinedChannel, and EncryptionKeyID
 	lastActivityUpdateTime int64        // Last time we wrote activity to DB (milliseconds, atomic)
 
+	// Two-factor authentication in progress (protected by mu)
+	pendingTwoFactor  *pendingTwoFactor // Login whose password was right, waiting for AUTH_TWO_FACTOR
+	pendingTOTPSecret string            // Secret from ENABLE_2FA, waiting for CONFIRM_2FA
+
+	// Profile shown in SERVER_PRESENCE (protected by mu)
+	displayName string
+	status      string
+	away        bool  // Idle for longer than AwayAfterSeconds
+	lastInputAt int64 // Last frame that wasn't a keepalive (milliseconds, atomic)
+
 	// Subscriptions for selective message broadcasting
 	subscribedThreads  map[uint64]ChannelSubscription // thread_id -> channel subscription
-	subscribedChannels map[ChannelSubscription]bool   // channel/subchannel -> true
+	subscribedChannels map[channelSubKey]bool         // channel/subchannel -> true
 	subMu              sync.RWMutex                   // Protects subscription maps
 }
 
@@ -45,9 +73,9 @@ type SessionManager struct {
 	activityUpdateIntervalMs int64 // Half of session timeout in milliseconds
 
 	// Reverse subscription indices for fast broadcast lookups
-	threadSubscribers  map[uint64]map[uint64]*Session              // threadID -> sessionID -> session
-	channelSubscribers map[ChannelSubscription]map[uint64]*Session // channelSub -> sessionID -> session
-	subIndexMu         sync.RWMutex                                // Protects subscription indices
+	threadSubscribers  map[uint64]map[uint64]*Session        // threadID -> sessionID -> session
+	channelSubscribers map[channelSubKey]map[uint64]*Session // channelSub -> sessionID -> session
+	subIndexMu         sync.RWMutex                          // Protects subscription indices
 }
 
 // NewSessionManager creates a new session manager
@@ -61,7 +89,7 @@ func NewSessionManager(db *database.MemDB, sessionTimeoutSeconds int) *SessionMa
 		sessions:                 make(map[uint64]*Session),
 		nextID:                   1,
 		threadSubscribers:        make(map[uint64]map[uint64]*Session),
-		channelSubscribers:       make(map[ChannelSubscription]map[uint64]*Session),
+		channelSubscribers:       make(map[channelSubKey]map[uint64]*Session),
 	}
 
 	return sm
@@ -92,8 +120,9 @@ func (sm *SessionManager) CreateSession(userID *int64, nickname, connType string
 		Conn:                   NewSafeConn(conn),
 		RemoteAddr:             conn.RemoteAddr().String(),
 		lastActivityUpdateTime: 0, // Will be set on first activity update
+		lastInputAt:            time.Now().UnixMilli(),
 		subscribedThreads:      make(map[uint64]ChannelSubscription),
-		subscribedChannels:     make(map[ChannelSubscription]bool),
+		subscribedChannels:     make(map[channelSubKey]bool),
 	}
 
 	// Only acquire lock for map insertion (critical section)
@@ -156,7 +185,7 @@ func (sm *SessionManager) RemoveSession(sessionID uint64) {
 	for threadID := range sess.subscribedThreads {
 		threadIDs = append(threadIDs, threadID)
 	}
-	channelSubs := make([]ChannelSubscription, 0, len(sess.subscribedChannels))
+	channelSubs := make([]channelSubKey, 0, len(sess.subscribedChannels))
 	for channelSub := range sess.subscribedChannels {
 		channelSubs = append(channelSubs, channelSub)
 	}
@@ -207,8 +236,19 @@ func (sm *SessionManager) UpdateNickname(sessionID uint64, nickname string) erro
 	return nil
 }
 
-// UpdateSessionActivity updates session activity only if the configured interval has passed
-func (sm *SessionManager) UpdateSessionActivity(sess *Session, now int64) {
+// UpdateSessionActivity updates session activity only if the configured interval has passed.
+// input is false for keepalives, which keep the session alive but don't stop it from
+// going away. Returns true if the session was away and is back.
+func (sm *SessionManager) UpdateSessionActivity(sess *Session, now int64, input bool) bool {
+	back := false
+	if input {
+		atomic.StoreInt64(&sess.lastInputAt, now)
+		sess.mu.Lock()
+		back = sess.away
+		sess.away = false
+		sess.mu.Unlock()
+	}
+
 	lastUpdate := atomic.LoadInt64(&sess.lastActivityUpdateTime)
 
 	// Only update if the configured interval has passed (half of session timeout)
@@ -218,6 +258,25 @@ func (sm *SessionManager) UpdateSessionActivity(sess *Session, now int64) {
 			sm.db.UpdateSessionActivity(sess.DBSessionID)
 		}
 	}
+	return back
+}
+
+// MarkIdleSessionsAway marks sessions without input since before cutoff as away,
+// returning the ones that weren't already
+func (sm *SessionManager) MarkIdleSessionsAway(cutoff int64) []*Session {
+	var idle []*Session
+	for _, sess := range sm.GetAllSessions() {
+		if atomic.LoadInt64(&sess.lastInputAt) >= cutoff {
+			continue
+		}
+		sess.mu.Lock()
+		if !sess.away {
+			sess.away = true
+			idle = append(idle, sess)
+		}
+		sess.mu.Unlock()
+	}
+	return idle
 }
 
 // SetJoinedChannel sets the currently joined channel for a session
@@ -291,33 +350,37 @@ func (sm *SessionManager) UnsubscribeFromThread(sess *Session, threadID uint64)
 
 // SubscribeToChannel subscribes the session to a channel/subchannel and updates reverse index
 func (sm *SessionManager) SubscribeToChannel(sess *Session, channelSub ChannelSubscription) {
+	key := channelSub.key()
+
 	// Update session's subscription map
 	sess.subMu.Lock()
-	sess.subscribedChannels[channelSub] = true
+	sess.subscribedChannels[key] = true
 	sess.subMu.Unlock()
 
 	// Update reverse index
 	sm.subIndexMu.Lock()
-	if sm.channelSubscribers[channelSub] == nil {
-		sm.channelSubscribers[channelSub] = make(map[uint64]*Session)
+	if sm.channelSubscribers[key] == nil {
+		sm.channelSubscribers[key] = make(map[uint64]*Session)
 	}
-	sm.channelSubscribers[channelSub][sess.ID] = sess
+	sm.channelSubscribers[key][sess.ID] = sess
 	sm.subIndexMu.Unlock()
 }
 
 // UnsubscribeFromChannel unsubscribes the session from a channel/subchannel and updates reverse index
 func (sm *SessionManager) UnsubscribeFromChannel(sess *Session, channelSub ChannelSubscription) {
+	key := channelSub.key()
+
 	// Update session's subscription map
 	sess.subMu.Lock()
-	delete(sess.subscribedChannels, channelSub)
+	delete(sess.subscribedChannels, key)
 	sess.subMu.Unlock()
 
 	// Update reverse index
 	sm.subIndexMu.Lock()
-	if subscribers := sm.channelSubscribers[channelSub]; subscribers != nil {
+	if subscribers := sm.channelSubscribers[key]; subscribers != nil {
 		delete(subscribers, sess.ID)
 		if len(subscribers) == 0 {
-			delete(sm.channelSubscribers, channelSub)
+			delete(sm.channelSubscribers, key)
 		}
 	}
 	sm.subIndexMu.Unlock()
@@ -345,7 +408,7 @@ func (sm *SessionManager) GetChannelSubscribers(channelSub ChannelSubscription)
 	sm.subIndexMu.RLock()
 	defer sm.subIndexMu.RUnlock()
 
-	subscribers := sm.channelSubscribers[channelSub]
+	subscribers := sm.channelSubscribers[channelSub.key()]
 	if len(subscribers) == 0 {
 		return nil
 	}
@@ -371,7 +434,7 @@ func (s *Session) IsSubscribedToChannel(channelSub ChannelSubscription) bool {
 	s.subMu.RLock()
 	defer s.subMu.RUnlock()
 
-	return s.subscribedChannels[channelSub]
+	return s.subscribedChannels[channelSub.key()]
 }
 
 // ThreadSubscriptionCount returns the number of thread subscriptions (thread-safe)
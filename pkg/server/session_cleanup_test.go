@@ -73,7 +73,7 @@ func TestRemoveSession_CleansUpChannelSubscriptions(t *testing.T) {
 
 	// Verify the reverse index was cleaned up (map should be empty)
 	sm.subIndexMu.RLock()
-	_, exists := sm.channelSubscribers[channelSub]
+	_, exists := sm.channelSubscribers[channelSub.key()]
 	sm.subIndexMu.RUnlock()
 
 	if exists {
@@ -150,7 +150,7 @@ func TestSessionManagerSubscriptionMethods(t *testing.T) {
 	t.Run("ChannelSubscriptionCount", func(t *testing.T) {
 		// Clear any existing subscriptions
 		sess.subMu.Lock()
-		sess.subscribedChannels = make(map[ChannelSubscription]bool)
+		sess.subscribedChannels = make(map[channelSubKey]bool)
 		sess.subMu.Unlock()
 
 		// Should start at 0
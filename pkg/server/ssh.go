@@ -82,6 +82,11 @@ func (s *Server) acceptSSHLoop(listener net.Listener, config *ssh.ServerConfig)
 			}
 		}
 
+		conn, ok := s.admitConnection(conn, "ssh")
+		if !ok {
+			continue
+		}
+
 		// Handle SSH connection in a goroutine
 		s.wg.Add(1)
 		go s.handleSSHConnection(conn, config)
@@ -201,6 +206,7 @@ func (s *Server) handleSSHSession(channel ssh.Channel, permissions *ssh.Permissi
 		sess.UserFlags = userFlags
 		sess.Shadowbanned = ban != nil && ban.Shadowban
 		sess.mu.Unlock()
+		s.loadSessionProfile(sess, *userID)
 
 		if sess.Shadowbanned {
 			debugLog.Printf("Session %d: SSH user %s (ID: %d) is shadowbanned", sess.ID, nickname, *userID)
@@ -249,7 +255,9 @@ func (s *Server) handleSSHSession(channel ssh.Channel, permissions *ssh.Permissi
 		debugLog.Printf("Session %d ← RECV: Type=0x%02X Flags=0x%02X PayloadLen=%d", sess.ID, frame.Type, frame.Flags, len(frame.Payload))
 
 		// Update session activity (buffered write, rate-limited to half of session timeout)
-		s.sessions.UpdateSessionActivity(sess, time.Now().UnixMilli())
+		if s.sessions.UpdateSessionActivity(sess, time.Now().UnixMilli(), frame.Type != protocol.TypePing) {
+			s.notifyServerPresence(sess, true)
+		}
 
 		// Handle message
 		if err := s.handleMessage(sess, frame); err != nil {
@@ -372,7 +380,8 @@ func (s *Server) loadOrGenerateHostKey() (ssh.Signer, error) {
 	return key, nil
 }
 
-// authenticateSSHKey validates SSH public keys and auto-registers new users (V2 feature)
+// authenticateSSHKey validates SSH public keys and auto-registers new users (V2 feature).
+// Key logins skip two-factor authentication: the key is already a second factor.
 func (s *Server) authenticateSSHKey(conn ssh.ConnMetadata, pubKey ssh.PublicKey) (*ssh.Permissions, error) {
 	// Compute fingerprint (SHA256 format like OpenSSH)
 	fingerprint := ssh.FingerprintSHA256(pubKey)
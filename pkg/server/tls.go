@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// tlsHandshakeTimeout bounds how long a client may take to finish the TLS handshake
+	tlsHandshakeTimeout = 10 * time.Second
+
+	// selfSignedCertValidity is how long a generated certificate is valid. Clients pin
+	// self-signed certificates, so regenerating one means every client has to re-trust it.
+	selfSignedCertValidity = 10 * 365 * 24 * time.Hour
+)
+
+// startTLSServer starts the TLS listener for the binary protocol (scs://)
+func (s *Server) startTLSServer(lc net.ListenConfig) error {
+	if s.config.TLSPort <= 0 {
+		log.Printf("TLS server disabled (tls_port=%d)", s.config.TLSPort)
+		return nil
+	}
+
+	cert, err := s.loadOrGenerateTLSCertificate()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	addr := fmt.Sprintf(":%d", s.config.TLSPort)
+	listener, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.tlsListener = listener
+
+	log.Printf("TLS server listening on %s", addr)
+
+	s.wg.Add(1)
+	go s.acceptTLSLoop(listener, tlsConfig)
+
+	return nil
+}
+
+// acceptTLSLoop accepts incoming TLS connections
+func (s *Server) acceptTLSLoop(listener net.Listener, config *tls.Config) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.shutdown:
+				return
+			default:
+				log.Printf("TLS accept error: %v", err)
+				continue
+			}
+		}
+
+		go s.handleTLSConnection(conn, config)
+	}
+}
+
+// handleTLSConnection completes the TLS handshake, then serves the connection like plain TCP
+func (s *Server) handleTLSConnection(conn net.Conn, config *tls.Config) {
+	// Disable Nagle's algorithm on the underlying socket
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetNoDelay(true)
+	}
+
+	// Count the connection before the handshake, so pending handshakes are limited too
+	conn, ok := s.admitConnection(conn, "tls")
+	if !ok {
+		return
+	}
+
+	// Closing the TLS connection closes conn, which releases its slot
+	tlsConn := tls.Server(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		debugLog.Printf("TLS handshake with %s failed: %v", conn.RemoteAddr(), err)
+		tlsConn.Close()
+		return
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	s.serveConnection(tlsConn, "tls")
+}
+
+// loadOrGenerateTLSCertificate loads the TLS certificate and key, or generates a
+// self-signed pair if neither file exists and generation is enabled
+func (s *Server) loadOrGenerateTLSCertificate() (tls.Certificate, error) {
+	certPath, err := expandHomePath(s.config.TLSCertPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPath, err := expandHomePath(s.config.TLSKeyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if strings.TrimSpace(certPath) == "" || strings.TrimSpace(keyPath) == "" {
+		configTarget := "server config file"
+		if strings.TrimSpace(s.configPath) != "" {
+			configTarget = s.configPath
+		}
+		return tls.Certificate{}, fmt.Errorf("tls certificate or key path is empty; update [server].tls_cert and tls_key in %s or set tls_port = -1 to disable TLS", configTarget)
+	}
+
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+
+	// Try to load the existing pair
+	if certErr == nil && keyErr == nil {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		log.Printf("Loaded TLS certificate from %s", certPath)
+		return cert, nil
+	}
+
+	// Only generate when both files are missing, so a half-configured pair isn't overwritten
+	if !errors.Is(certErr, os.ErrNotExist) || !errors.Is(keyErr, os.ErrNotExist) {
+		if certErr != nil && !errors.Is(certErr, os.ErrNotExist) {
+			return tls.Certificate{}, fmt.Errorf("failed to read certificate: %w", certErr)
+		}
+		if keyErr != nil && !errors.Is(keyErr, os.ErrNotExist) {
+			return tls.Certificate{}, fmt.Errorf("failed to read key: %w", keyErr)
+		}
+		return tls.Certificate{}, fmt.Errorf("found only one of %s and %s; provide both or remove the other to generate a self-signed certificate", certPath, keyPath)
+	}
+
+	if !s.config.TLSGenerateCert {
+		return tls.Certificate{}, fmt.Errorf("no certificate at %s and tls_generate_cert is disabled", certPath)
+	}
+
+	log.Printf("Generating new self-signed TLS certificate at %s...", certPath)
+
+	certPEM, keyPEM, err := generateSelfSignedCertificate(s.config.PublicHostname, time.Now())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	// Ensure directories exist
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to write key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse generated certificate: %w", err)
+	}
+
+	log.Printf("Generated and saved new self-signed TLS certificate")
+	return cert, nil
+}
+
+// generateSelfSignedCertificate creates a PEM-encoded ECDSA P-256 certificate and key.
+// The certificate is valid for localhost and hostname (if set).
+func generateSelfSignedCertificate(hostname string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	commonName := "localhost"
+	if strings.TrimSpace(hostname) != "" {
+		commonName = hostname
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"SuperChat"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if commonName != "localhost" {
+		if ip := net.ParseIP(commonName); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, commonName)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// expandHomePath expands a leading ~/ to the user's home directory
+func expandHomePath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, path[2:]), nil
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestLoadOrGenerateTLSCertificate(t *testing.T) {
+	initTestLoggers(t)
+	tmpDir := t.TempDir()
+
+	cfg := DefaultConfig()
+	cfg.TLSCertPath = filepath.Join(tmpDir, "tls", "cert.pem")
+	cfg.TLSKeyPath = filepath.Join(tmpDir, "tls", "key.pem")
+	cfg.PublicHostname = "chat.example.com"
+	srv := &Server{config: cfg}
+
+	// First start generates a self-signed pair
+	generated, err := srv.loadOrGenerateTLSCertificate()
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(generated.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse generated certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("chat.example.com"); err != nil {
+		t.Errorf("Expected certificate to be valid for the public hostname: %v", err)
+	}
+	if info, err := os.Stat(cfg.TLSKeyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected private key with mode 0600, got %v (err %v)", info.Mode().Perm(), err)
+	}
+
+	// Later starts load the same certificate, so pinned clients keep trusting it
+	loaded, err := srv.loadOrGenerateTLSCertificate()
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	if !bytes.Equal(loaded.Certificate[0], generated.Certificate[0]) {
+		t.Error("Expected the existing certificate to be loaded instead of regenerated")
+	}
+
+	// A lone certificate isn't overwritten
+	if err := os.Remove(cfg.TLSKeyPath); err != nil {
+		t.Fatalf("Failed to remove key: %v", err)
+	}
+	if _, err := srv.loadOrGenerateTLSCertificate(); err == nil {
+		t.Error("Expected error when only the certificate exists")
+	}
+
+	// Generation can be disabled
+	srv.config.TLSCertPath = filepath.Join(tmpDir, "other", "cert.pem")
+	srv.config.TLSKeyPath = filepath.Join(tmpDir, "other", "key.pem")
+	srv.config.TLSGenerateCert = false
+	if _, err := srv.loadOrGenerateTLSCertificate(); err == nil {
+		t.Error("Expected error when the certificate is missing and generation is disabled")
+	}
+}
+
+func TestTLSConnection(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+	tmpDir := t.TempDir()
+	srv.config.TLSCertPath = filepath.Join(tmpDir, "cert.pem")
+	srv.config.TLSKeyPath = filepath.Join(tmpDir, "key.pem")
+	srv.shutdown = make(chan struct{})
+
+	cert, err := srv.loadOrGenerateTLSCertificate()
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv.wg.Add(1)
+	go srv.acceptTLSLoop(listener, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	defer func() {
+		close(srv.shutdown)
+		listener.Close()
+		srv.wg.Wait()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// The server speaks the normal binary protocol inside TLS, starting with SERVER_CONFIG
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	frame, err := protocol.DecodeFrame(conn)
+	if err != nil {
+		t.Fatalf("Failed to read SERVER_CONFIG: %v", err)
+	}
+	if frame.Type != protocol.TypeServerConfig {
+		t.Fatalf("Expected SERVER_CONFIG, got frame type 0x%02X", frame.Type)
+	}
+	if !bytes.Equal(conn.ConnectionState().PeerCertificates[0].Raw, cert.Certificate[0]) {
+		t.Error("Expected the server to present the loaded certificate")
+	}
+
+	// The session is recorded as a TLS connection
+	sessions := srv.sessions.GetAllSessions()
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(sessions))
+	}
+	for _, sess := range sessions {
+		dbSess, err := srv.db.GetSession(sess.DBSessionID)
+		if err != nil {
+			t.Fatalf("Failed to get session: %v", err)
+		}
+		if dbSess.ConnectionType != "tls" {
+			t.Errorf("Expected connection type tls, got %q", dbSess.ConnectionType)
+		}
+	}
+
+	// A plaintext client doesn't get past the handshake
+	plain, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer plain.Close()
+	plain.SetReadDeadline(time.Now().Add(5 * time.Second))
+	protocol.EncodeFrame(plain, &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.TypePing, Payload: make([]byte, 8)})
+	if frame, err := protocol.DecodeFrame(plain); err == nil && frame.Type == protocol.TypeServerConfig {
+		t.Error("Expected plaintext client to be refused")
+	}
+}
+
+func TestTLSConnectionLimit(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+	tmpDir := t.TempDir()
+	srv.config.TLSCertPath = filepath.Join(tmpDir, "cert.pem")
+	srv.config.TLSKeyPath = filepath.Join(tmpDir, "key.pem")
+	srv.shutdown = make(chan struct{})
+	srv.connLimiter = newConnectionLimiter(1)
+
+	cert, err := srv.loadOrGenerateTLSCertificate()
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv.wg.Add(1)
+	go srv.acceptTLSLoop(listener, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	defer func() {
+		close(srv.shutdown)
+		listener.Close()
+		srv.wg.Wait()
+	}()
+
+	dial := func() (*tls.Conn, error) {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := protocol.DecodeFrame(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	waitForSlots := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			srv.connLimiter.mu.Lock()
+			held := srv.connLimiter.counts["127.0.0.1"]
+			srv.connLimiter.mu.Unlock()
+			if held == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d connection slots in use, got %d", want, held)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A client that never finishes the handshake still holds a slot
+	pending, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	waitForSlots(1)
+	if conn, err := dial(); err == nil {
+		conn.Close()
+		t.Fatal("Expected connection over the limit to be refused before the handshake")
+	}
+
+	// A failed handshake gives the slot back
+	pending.Close()
+	waitForSlots(0)
+	conn, err := dial()
+	if err != nil {
+		t.Fatalf("Expected connection after the failed handshake to be admitted: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+)
+
+// TOTP parameters (RFC 6238 defaults, which every authenticator app supports)
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpModulo = 1000000 // 10^totpDigits
+
+	// totpSkew is how many periods a code may be off, for clock drift
+	totpSkew = 1
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes enabling 2FA hands out
+	recoveryCodeCount = 10
+
+	// twoFactorLoginTimeout is how long the code may take after the password
+	twoFactorLoginTimeout = 5 * time.Minute
+)
+
+// pendingTwoFactor is a login whose password was right, waiting for its code
+type pendingTwoFactor struct {
+	userID   int64
+	nickname string
+	expires  time.Time
+}
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret returns a random 160-bit secret, base32 encoded
+func generateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// totpURI returns the otpauth:// URI authenticator apps scan
+func totpURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// totpStep returns the time step a moment falls in
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// totpCode computes the code for a time step (RFC 4226 dynamic truncation)
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulo)
+}
+
+// matchTOTP returns the time step a code is valid for, allowing totpSkew steps
+// of clock drift
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes returns recovery codes ("xxxxx-xxxxx") and their hashes
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz023456789" // 32 characters, no o, 1, l or i
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		var b strings.Builder
+		for j, r := range raw {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(alphabet[r%32])
+		}
+		codes = append(codes, b.String())
+		hashes = append(hashes, hashRecoveryCode(b.String()))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code as typed, ignoring case, spaces and dashes.
+// Codes are random, so a fast hash is enough.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkTwoFactorCode checks an authenticator or recovery code for a user, using it
+// up. recovery tells which kind it was.
+func (s *Server) checkTwoFactorCode(tf *database.TwoFactor, code string, now time.Time) (ok, recovery bool, err error) {
+	code = strings.TrimSpace(code)
+	if step, match := matchTOTP(tf.Secret, strings.ReplaceAll(code, " ", ""), now); match {
+		ok, err := s.db.UseTwoFactorStep(tf.UserID, step)
+		return ok, false, err
+	}
+	if len(code) <= totpDigits {
+		return false, false, nil
+	}
+	ok, err = s.db.UseRecoveryCode(tf.UserID, hashRecoveryCode(code))
+	return ok, ok, err
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/database"
+	"github.com/aeolun/superchat/pkg/protocol"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors (SHA-1), truncated to 6 digits
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, totpStep(time.Unix(tt.unix, 0))); got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	secret := base32NoPadding.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	if step, ok := matchTOTP(secret, "050471", now); !ok || step != totpStep(now) {
+		t.Errorf("Expected current code to match step %d, got %d %v", totpStep(now), step, ok)
+	}
+	if _, ok := matchTOTP(secret, "050471", now.Add(totpPeriod)); !ok {
+		t.Error("Expected previous period's code to match within the skew")
+	}
+	if _, ok := matchTOTP(secret, "050471", now.Add(3*totpPeriod)); ok {
+		t.Error("Expected code from three periods ago not to match")
+	}
+	if _, ok := matchTOTP(secret, "50471", now); ok {
+		t.Error("Expected short code not to match")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("generateRecoveryCodes failed: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("Expected %d codes, got %d", recoveryCodeCount, len(codes))
+	}
+	if len(codes[0]) != 11 || codes[0][5] != '-' {
+		t.Errorf("Expected xxxxx-xxxxx, got %q", codes[0])
+	}
+	if hashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))) != hashes[0] {
+		t.Error("Expected hash to ignore case, spaces and dashes")
+	}
+	if _, err := hex.DecodeString(hashes[0]); err != nil {
+		t.Errorf("Expected hex hash, got %q", hashes[0])
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("right"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword failed: %v", err)
+	}
+	aliceID, err := db.CreateUser("alice", string(hash), 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bobID, err := db.CreateUser("bob", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	adminID, err := db.CreateUser("admin", "hash", uint8(protocol.UserFlagAdmin))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	// Set up 2FA on a logged-in session
+	sess := testSession(srv)
+	sess.Nickname = "alice"
+	sess.UserID = &aliceID
+	if err := srv.handleEnable2FA(sess, dmFrame(t, protocol.TypeEnable2FA, &protocol.Enable2FAMessage{})); err != nil {
+		t.Fatalf("handleEnable2FA failed: %v", err)
+	}
+	setup := &protocol.TwoFactorSetupMessage{}
+	decodeFrame(t, readFrames(t, sess), protocol.TypeTwoFactorSetup, setup)
+	if !setup.Success || !strings.HasPrefix(setup.URI, "otpauth://totp/") || !strings.Contains(setup.URI, setup.Secret) {
+		t.Fatalf("Expected a TOTP secret and URI, got %+v", setup)
+	}
+
+	key, _ := base32NoPadding.DecodeString(setup.Secret)
+	codeAt := func(now time.Time) string { return totpCode(key, totpStep(now)) }
+	confirm := func(code string) *protocol.TwoFactorEnabledMessage {
+		t.Helper()
+		if err := srv.handleConfirm2FA(sess, dmFrame(t, protocol.TypeConfirm2FA, &protocol.Confirm2FAMessage{Code: code})); err != nil {
+			t.Fatalf("handleConfirm2FA failed: %v", err)
+		}
+		resp := &protocol.TwoFactorEnabledMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeTwoFactorEnabled, resp)
+		return resp
+	}
+	if resp := confirm("abcdef"); resp.Success {
+		t.Fatal("Expected wrong confirmation code to be refused")
+	}
+	confirmedAt := time.Now()
+	enabled := confirm(codeAt(confirmedAt))
+	if !enabled.Success || len(enabled.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Expected 2FA to be enabled with recovery codes, got %+v", enabled)
+	}
+
+	login := func(password string) (*Session, *protocol.AuthResponseMessage) {
+		t.Helper()
+		sess := testSession(srv)
+		frame := dmFrame(t, protocol.TypeAuthRequest, &protocol.AuthRequestMessage{Nickname: "alice", Password: password})
+		if err := srv.handleAuthRequest(sess, frame); err != nil {
+			t.Fatalf("handleAuthRequest failed: %v", err)
+		}
+		resp := &protocol.AuthResponseMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeAuthResponse, resp)
+		return sess, resp
+	}
+	sendCode := func(sess *Session, code string) *protocol.AuthResponseMessage {
+		t.Helper()
+		if err := srv.handleAuthTwoFactor(sess, dmFrame(t, protocol.TypeAuthTwoFactor, &protocol.AuthTwoFactorMessage{Code: code})); err != nil {
+			t.Fatalf("handleAuthTwoFactor failed: %v", err)
+		}
+		resp := &protocol.AuthResponseMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeAuthResponse, resp)
+		return resp
+	}
+
+	// The password alone doesn't log in
+	pending, resp := login("right")
+	if resp.Success || !resp.TwoFactorRequired || pending.UserID != nil {
+		t.Fatalf("Expected a two-factor prompt, got success=%v required=%v", resp.Success, resp.TwoFactorRequired)
+	}
+
+	// The confirmation code was used up, so the next period's code is needed
+	if resp := sendCode(pending, codeAt(confirmedAt)); resp.Success || !resp.TwoFactorRequired {
+		t.Fatalf("Expected replayed code to be refused with a retry, got success=%v %q", resp.Success, resp.Message)
+	}
+	if resp := sendCode(pending, codeAt(confirmedAt.Add(totpPeriod))); !resp.Success || pending.UserID == nil || *pending.UserID != aliceID {
+		t.Fatalf("Expected login with the code to succeed, got %q", resp.Message)
+	}
+
+	// A recovery code works once
+	pending, _ = login("right")
+	if resp := sendCode(pending, strings.ToUpper(enabled.RecoveryCodes[0])); !resp.Success || !strings.Contains(resp.Message, "9 left") {
+		t.Fatalf("Expected login with a recovery code to succeed, got %q", resp.Message)
+	}
+	pending, _ = login("right")
+	if resp := sendCode(pending, enabled.RecoveryCodes[0]); resp.Success {
+		t.Fatal("Expected used recovery code to be refused")
+	}
+
+	// Codes need a password first
+	if resp := sendCode(testSession(srv), codeAt(time.Now())); resp.Success || resp.TwoFactorRequired {
+		t.Fatalf("Expected code without a password to be refused, got %q", resp.Message)
+	}
+
+	// Users can't turn off 2FA for others, admins can
+	disable := func(sess *Session, msg *protocol.Disable2FAMessage) *protocol.TwoFactorDisabledMessage {
+		t.Helper()
+		if err := srv.handleDisable2FA(sess, dmFrame(t, protocol.TypeDisable2FA, msg)); err != nil {
+			t.Fatalf("handleDisable2FA failed: %v", err)
+		}
+		resp := &protocol.TwoFactorDisabledMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeTwoFactorDisabled, resp)
+		return resp
+	}
+	other := testSession(srv)
+	other.Nickname = "bob"
+	other.UserID = &bobID
+	nickname := "alice"
+	if resp := disable(other, &protocol.Disable2FAMessage{Nickname: &nickname}); resp.Success {
+		t.Fatal("Expected non-admin to be refused")
+	}
+	if resp := disable(sess, &protocol.Disable2FAMessage{Code: "abcdef"}); resp.Success {
+		t.Fatal("Expected disabling with a wrong code to be refused")
+	}
+
+	admin := testSession(srv)
+	admin.Nickname = "admin"
+	admin.UserID = &adminID
+	admin.UserFlags = uint8(protocol.UserFlagAdmin)
+	if resp := disable(admin, &protocol.Disable2FAMessage{Nickname: &nickname}); !resp.Success {
+		t.Fatalf("Expected admin to disable 2FA, got %q", resp.Message)
+	}
+	if _, resp := login("right"); !resp.Success {
+		t.Errorf("Expected password login without 2FA, got %q", resp.Message)
+	}
+
+	actions, err := srv.db.ListAdminActions(database.AdminActionFilter{ActionType: "disable_2fa"})
+	if err != nil {
+		t.Fatalf("ListAdminActions failed: %v", err)
+	}
+	if len(actions) != 1 || actions[0].AdminNickname != "admin" || actions[0].TargetIdentifier != "alice" {
+		t.Errorf("Expected one logged 2FA override by admin, got %+v", actions)
+	}
+}
+
+func TestDisable2FALockout(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	aliceID, err := db.CreateUser("alice", "hash", 0)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	key := []byte("12345678901234567890")
+	if err := db.EnableTwoFactor(aliceID, base32NoPadding.EncodeToString(key), nil); err != nil {
+		t.Fatalf("EnableTwoFactor failed: %v", err)
+	}
+	reloadMemDB(t, srv, db)
+
+	sess := testSession(srv)
+	sess.Nickname = "alice"
+	sess.UserID = &aliceID
+	disable := func(code string) *protocol.TwoFactorDisabledMessage {
+		t.Helper()
+		if err := srv.handleDisable2FA(sess, dmFrame(t, protocol.TypeDisable2FA, &protocol.Disable2FAMessage{Code: code})); err != nil {
+			t.Fatalf("handleDisable2FA failed: %v", err)
+		}
+		resp := &protocol.TwoFactorDisabledMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeTwoFactorDisabled, resp)
+		return resp
+	}
+
+	// Guessing codes with a logged in session locks the account like guessing at login
+	wrong := totpCode(key, totpStep(time.Now())+100)
+	for i := 0; i < srv.config.LoginMaxFailures; i++ {
+		if resp := disable(wrong); resp.Success || resp.Message != "Invalid two-factor code" {
+			t.Fatalf("Attempt %d: expected an invalid code, got %q", i+1, resp.Message)
+		}
+	}
+	resp := disable(totpCode(key, totpStep(time.Now())))
+	if resp.Success || !strings.HasPrefix(resp.Message, "Too many failed attempts") {
+		t.Fatalf("Expected a locked out account, got success=%v %q", resp.Success, resp.Message)
+	}
+	if tf, err := srv.db.GetTwoFactor(aliceID); err != nil || tf == nil {
+		t.Errorf("Expected 2FA to stay enabled, got %+v (err=%v)", tf, err)
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+const (
+	// typingTimeout is how long a TYPING_START lasts unless the client repeats it
+	typingTimeout = 6 * time.Second
+
+	// typingThrottle is how often one session's typing is announced again while it
+	// keeps sending TYPING_START (receivers forget it after typingTimeout)
+	typingThrottle = 3 * time.Second
+)
+
+// typingTarget is where a session is typing. Zero IDs mean "none".
+type typingTarget struct {
+	channelID    uint64
+	subchannelID uint64
+	threadID     uint64 // Thread root being replied to (0 = chat or a new thread)
+}
+
+// typingState is one session's typing indicator
+type typingState struct {
+	sess      *Session
+	target    typingTarget
+	expiresAt time.Time
+	sentAt    time.Time // Last USER_TYPING announcing it
+}
+
+// typingTracker remembers who is typing where, so TYPING_START can be throttled
+// and forgotten when clients stop sending it
+type typingTracker struct {
+	mu     sync.Mutex
+	states map[uint64]*typingState // sessionID -> state
+}
+
+func newTypingTracker() *typingTracker {
+	return &typingTracker{states: make(map[uint64]*typingState)}
+}
+
+// start records that a session is typing at target. It reports whether that should be
+// announced, and where the session was typing before if it moved elsewhere.
+// A nil tracker announces nothing.
+func (t *typingTracker) start(sess *Session, target typingTarget, now time.Time) (bool, *typingTarget) {
+	if t == nil {
+		return false, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.states[sess.ID]
+	if ok && state.target == target {
+		state.expiresAt = now.Add(typingTimeout)
+		if now.Sub(state.sentAt) < typingThrottle {
+			return false, nil
+		}
+		state.sentAt = now
+		return true, nil
+	}
+
+	var previous *typingTarget
+	if ok {
+		previous = &state.target
+	}
+	t.states[sess.ID] = &typingState{sess: sess, target: target, expiresAt: now.Add(typingTimeout), sentAt: now}
+	return true, previous
+}
+
+// stop forgets a session's typing indicator (only if it's at target, when given),
+// returning it if there was one
+func (t *typingTracker) stop(sessionID uint64, target *typingTarget) *typingState {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.states[sessionID]
+	if !ok || (target != nil && state.target != *target) {
+		return nil
+	}
+	delete(t.states, sessionID)
+	return state
+}
+
+// expire forgets and returns the typing indicators that weren't repeated in time
+func (t *typingTracker) expire(now time.Time) []*typingState {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expired []*typingState
+	for id, state := range t.states {
+		if !now.Before(state.expiresAt) {
+			expired = append(expired, state)
+			delete(t.states, id)
+		}
+	}
+	return expired
+}
+
+// handleTypingStart handles TYPING_START message
+func (s *Server) handleTypingStart(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.TypingStartMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	sess.mu.RUnlock()
+	if nickname == "" {
+		return nil
+	}
+
+	channel, err := s.db.GetChannel(int64(msg.ChannelID))
+	if err != nil {
+		return s.sendError(sess, protocol.ErrCodeChannelNotFound, "Channel not found")
+	}
+	if !s.canAccessChannel(sess, channel.ID) {
+		return s.sendError(sess, protocol.ErrCodeChannelPrivate, "Channel is private")
+	}
+
+	target := typingTarget{channelID: msg.ChannelID}
+	if msg.SubchannelID != nil {
+		sub, err := s.db.GetSubchannel(int64(*msg.SubchannelID))
+		if err != nil || sub.ChannelID != channel.ID {
+			return s.sendError(sess, protocol.ErrCodeSubchannelNotFound, "Subchannel does not exist")
+		}
+		target.subchannelID = *msg.SubchannelID
+	}
+	if msg.ThreadID != nil {
+		root, err := s.db.GetMessage(int64(*msg.ThreadID))
+		if err != nil || root.ChannelID != channel.ID || root.ParentID != nil {
+			return s.sendError(sess, protocol.ErrCodeThreadNotFound, "Thread not found")
+		}
+		target.threadID = *msg.ThreadID
+	}
+
+	announce, previous := s.typing.start(sess, target, time.Now())
+	if previous != nil {
+		s.broadcastTyping(sess, *previous, false)
+	}
+	if announce {
+		s.broadcastTyping(sess, target, true)
+	}
+	return nil
+}
+
+// handleTypingStop handles TYPING_STOP message. A stop for somewhere the session isn't
+// typing (any more) is ignored.
+func (s *Server) handleTypingStop(sess *Session, frame *protocol.Frame) error {
+	msg := &protocol.TypingStopMessage{}
+	if err := msg.Decode(frame.Payload); err != nil {
+		return s.sendError(sess, protocol.ErrCodeInvalidFormat, "Invalid message format")
+	}
+
+	target := typingTarget{
+		channelID:    msg.ChannelID,
+		subchannelID: safeDeref(msg.SubchannelID, 0),
+		threadID:     safeDeref(msg.ThreadID, 0),
+	}
+	if state := s.typing.stop(sess.ID, &target); state != nil {
+		s.broadcastTyping(sess, state.target, false)
+	}
+	return nil
+}
+
+// clearTyping forgets a session's typing indicator and tells the others it stopped
+func (s *Server) clearTyping(sessionID uint64) {
+	if state := s.typing.stop(sessionID, nil); state != nil {
+		s.broadcastTyping(state.sess, state.target, false)
+	}
+}
+
+// broadcastTyping sends USER_TYPING to the other sessions subscribed where sess is typing:
+// the channel (or subchannel) for chat and new threads, the thread for replies
+func (s *Server) broadcastTyping(sess *Session, target typingTarget, typing bool) {
+	sess.mu.RLock()
+	nickname := sess.Nickname
+	shadowbanned := sess.Shadowbanned
+	sess.mu.RUnlock()
+
+	msg := &protocol.UserTypingMessage{
+		ChannelID: target.channelID,
+		SessionID: sess.ID,
+		Nickname:  nickname,
+		Typing:    typing,
+	}
+	if target.subchannelID != 0 {
+		msg.SubchannelID = &target.subchannelID
+	}
+
+	var targets []*Session
+	if target.threadID != 0 {
+		msg.ThreadID = &target.threadID
+		targets = s.sessions.GetThreadSubscribers(target.threadID)
+	} else {
+		targets = s.sessions.GetChannelSubscribers(ChannelSubscription{
+			ChannelID:    target.channelID,
+			SubchannelID: msg.SubchannelID,
+		})
+	}
+	targets = s.filterChannelAccess(int64(target.channelID), targets)
+
+	for _, other := range targets {
+		if other.ID == sess.ID {
+			continue
+		}
+		// Shadowbanned users' messages only reach admins, so should their typing
+		if shadowbanned {
+			other.mu.RLock()
+			isAdmin := other.UserID != nil && (other.UserFlags&1) != 0
+			other.mu.RUnlock()
+			if !isAdmin {
+				continue
+			}
+		}
+		if err := s.sendMessage(other, protocol.TypeUserTyping, msg); err != nil {
+			log.Printf("Failed to send USER_TYPING to session %d: %v", other.ID, err)
+		}
+	}
+}
+
+// typingLoop stops typing indicators whose clients went quiet
+func (s *Server) typingLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case now := <-ticker.C:
+			for _, state := range s.typing.expire(now) {
+				s.broadcastTyping(state.sess, state.target, false)
+			}
+		}
+	}
+}
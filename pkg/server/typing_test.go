@@ -0,0 +1,195 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aeolun/superchat/pkg/protocol"
+)
+
+func TestTypingTracker(t *testing.T) {
+	tracker := newTypingTracker()
+	sess := &Session{ID: 1}
+	chat := typingTarget{channelID: 1}
+	thread := typingTarget{channelID: 1, threadID: 5}
+	now := time.Now()
+
+	if announce, previous := tracker.start(sess, chat, now); !announce || previous != nil {
+		t.Fatalf("Expected the first start to be announced, got %v/%v", announce, previous)
+	}
+
+	// Repeats within the throttle only keep it alive
+	if announce, _ := tracker.start(sess, chat, now.Add(typingThrottle/2)); announce {
+		t.Error("Expected a repeat within the throttle not to be announced")
+	}
+	if expired := tracker.expire(now.Add(typingTimeout)); len(expired) != 0 {
+		t.Errorf("Expected the repeat to extend the timeout, got %d expired", len(expired))
+	}
+	if announce, _ := tracker.start(sess, chat, now.Add(typingThrottle)); !announce {
+		t.Error("Expected a repeat after the throttle to be announced")
+	}
+
+	// Moving elsewhere stops the old one
+	announce, previous := tracker.start(sess, thread, now.Add(typingThrottle))
+	if !announce || previous == nil || *previous != chat {
+		t.Errorf("Expected a move to be announced and stop %+v, got %v/%+v", chat, announce, previous)
+	}
+
+	if state := tracker.stop(sess.ID, &chat); state != nil {
+		t.Error("Expected a stop for the wrong target to be ignored")
+	}
+	if state := tracker.stop(sess.ID, &thread); state == nil || state.target != thread {
+		t.Errorf("Expected the stop to return the thread, got %+v", state)
+	}
+
+	tracker.start(sess, chat, now)
+	if expired := tracker.expire(now.Add(typingTimeout)); len(expired) != 1 || expired[0].sess != sess {
+		t.Errorf("Expected the start to expire, got %d expired", len(expired))
+	}
+	if state := tracker.stop(sess.ID, nil); state != nil {
+		t.Error("Expected nothing left after expiry")
+	}
+
+	var nilTracker *typingTracker
+	if announce, _ := nilTracker.start(sess, chat, now); announce {
+		t.Error("Expected a nil tracker to announce nothing")
+	}
+	nilTracker.stop(sess.ID, nil)
+	nilTracker.expire(now)
+}
+
+func TestTypingIndicators(t *testing.T) {
+	srv, db := testServer(t)
+	defer db.Close()
+
+	channelID := createTestChannel(t, db, "general", "General")
+	otherChannelID := createTestChannel(t, db, "random", "Random")
+	rootID := postTestMessage(t, db, channelID, nil, "carol", "A thread")
+	replyID := postTestMessage(t, db, channelID, &rootID, "carol", "A reply")
+	reloadMemDB(t, srv, db)
+
+	newSession := func(nickname string) *Session {
+		sess := testSession(srv)
+		sess.Nickname = nickname
+		return sess
+	}
+	alice := newSession("alice")
+	bob := newSession("bob")
+	carol := newSession("carol")
+	elsewhere := newSession("dave")
+
+	// Bob watches the channel, carol the thread, dave another channel
+	channelSub := ChannelSubscription{ChannelID: uint64(channelID)}
+	srv.sessions.SubscribeToChannel(alice, channelSub)
+	srv.sessions.SubscribeToChannel(bob, channelSub)
+	srv.sessions.SubscribeToThread(carol, uint64(rootID), channelSub)
+	srv.sessions.SubscribeToChannel(elsewhere, ChannelSubscription{ChannelID: uint64(otherChannelID)})
+
+	send := func(sess *Session, msgType uint8, msg protocol.ProtocolMessage) {
+		t.Helper()
+		var err error
+		if msgType == protocol.TypeTypingStart {
+			err = srv.handleTypingStart(sess, dmFrame(t, msgType, msg))
+		} else {
+			err = srv.handleTypingStop(sess, dmFrame(t, msgType, msg))
+		}
+		if err != nil {
+			t.Fatalf("Typing handler failed: %v", err)
+		}
+	}
+	expectTyping := func(sess *Session, nickname string, typing bool) *protocol.UserTypingMessage {
+		t.Helper()
+		msg := &protocol.UserTypingMessage{}
+		decodeFrame(t, readFrames(t, sess), protocol.TypeUserTyping, msg)
+		if msg.Nickname != nickname || msg.Typing != typing {
+			t.Errorf("Expected %s typing=%v, got %+v", nickname, typing, msg)
+		}
+		return msg
+	}
+	expectNothing := func(sessions ...*Session) {
+		t.Helper()
+		for _, sess := range sessions {
+			if frames := readFrames(t, sess); len(frames) != 0 {
+				t.Errorf("Expected no frames for %s, got %d", sess.Nickname, len(frames))
+			}
+		}
+	}
+
+	// Chat typing reaches the channel's other subscribers only
+	send(alice, protocol.TypeTypingStart, &protocol.TypingStartMessage{ChannelID: uint64(channelID)})
+	if msg := expectTyping(bob, "alice", true); msg.SessionID != alice.ID || msg.ThreadID != nil {
+		t.Errorf("Expected chat typing from alice's session, got %+v", msg)
+	}
+	expectNothing(alice, carol, elsewhere)
+
+	// Repeats are throttled
+	send(alice, protocol.TypeTypingStart, &protocol.TypingStartMessage{ChannelID: uint64(channelID)})
+	expectNothing(bob)
+
+	// Replying in the thread moves alice there
+	threadID := uint64(rootID)
+	send(alice, protocol.TypeTypingStart, &protocol.TypingStartMessage{ChannelID: uint64(channelID), ThreadID: &threadID})
+	expectTyping(bob, "alice", false)
+	if msg := expectTyping(carol, "alice", true); msg.ThreadID == nil || *msg.ThreadID != threadID {
+		t.Errorf("Expected thread typing, got %+v", msg)
+	}
+
+	// A stop for where she no longer types is ignored
+	send(alice, protocol.TypeTypingStop, &protocol.TypingStopMessage{ChannelID: uint64(channelID)})
+	expectNothing(bob, carol)
+	send(alice, protocol.TypeTypingStop, &protocol.TypingStopMessage{ChannelID: uint64(channelID), ThreadID: &threadID})
+	expectTyping(carol, "alice", false)
+
+	// Posting stops it
+	send(bob, protocol.TypeTypingStart, &protocol.TypingStartMessage{ChannelID: uint64(channelID)})
+	expectTyping(alice, "bob", true)
+	if err := srv.handlePostMessage(bob, dmFrame(t, protocol.TypePostMessage, &protocol.PostMessageMessage{ChannelID: uint64(channelID), Content: "hi"})); err != nil {
+		t.Fatalf("handlePostMessage failed: %v", err)
+	}
+	frames := readFrames(t, alice)
+	stopped := &protocol.UserTypingMessage{}
+	decodeFrame(t, frames, protocol.TypeUserTyping, stopped)
+	if stopped.Nickname != "bob" || stopped.Typing {
+		t.Errorf("Expected bob to stop typing when posting, got %+v", stopped)
+	}
+	readFrames(t, bob)
+
+	// Quiet clients expire
+	send(bob, protocol.TypeTypingStart, &protocol.TypingStartMessage{ChannelID: uint64(channelID)})
+	expectTyping(alice, "bob", true)
+	for _, state := range srv.typing.expire(time.Now().Add(typingTimeout)) {
+		srv.broadcastTyping(state.sess, state.target, false)
+	}
+	expectTyping(alice, "bob", false)
+
+	// Disconnecting stops it
+	send(bob, protocol.TypeTypingStart, &protocol.TypingStartMessage{ChannelID: uint64(channelID)})
+	expectTyping(alice, "bob", true)
+	srv.removeSession(bob.ID)
+	frames = readFrames(t, alice)
+	stopped = &protocol.UserTypingMessage{}
+	decodeFrame(t, frames, protocol.TypeUserTyping, stopped)
+	if stopped.Nickname != "bob" || stopped.Typing {
+		t.Errorf("Expected bob to stop typing when leaving, got %+v", stopped)
+	}
+	readFrames(t, carol)
+	readFrames(t, elsewhere)
+
+	// Bad targets
+	expectError := func(msg *protocol.TypingStartMessage, code uint16) {
+		t.Helper()
+		send(alice, protocol.TypeTypingStart, msg)
+		errMsg := &protocol.ErrorMessage{}
+		decodeFrame(t, readFrames(t, alice), protocol.TypeError, errMsg)
+		if errMsg.ErrorCode != code {
+			t.Errorf("Expected error %d, got %d (%s)", code, errMsg.ErrorCode, errMsg.Message)
+		}
+	}
+	expectError(&protocol.TypingStartMessage{ChannelID: 9999}, protocol.ErrCodeChannelNotFound)
+	subchannelID := uint64(9999)
+	expectError(&protocol.TypingStartMessage{ChannelID: uint64(channelID), SubchannelID: &subchannelID}, protocol.ErrCodeSubchannelNotFound)
+	notRoot := uint64(replyID)
+	expectError(&protocol.TypingStartMessage{ChannelID: uint64(channelID), ThreadID: &notRoot}, protocol.ErrCodeThreadNotFound)
+	expectError(&protocol.TypingStartMessage{ChannelID: uint64(otherChannelID), ThreadID: &threadID}, protocol.ErrCodeThreadNotFound)
+	expectNothing(carol, elsewhere)
+}
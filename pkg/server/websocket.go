@@ -21,6 +21,8 @@ type WebSocketConn struct {
 	writeMu sync.Mutex
 	closed  bool
 	closeMu sync.Mutex
+
+	remoteAddr net.Addr // Client address behind a trusted proxy (nil = the peer itself)
 }
 
 var upgrader = websocket.Upgrader{
@@ -42,8 +44,16 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Wrap WebSocket as net.Conn
-	conn := NewWebSocketConn(ws)
+	// Wrap WebSocket as net.Conn, reporting the client's address rather than the proxy's
+	wsConn := NewWebSocketConn(ws)
+	if ip := clientIP(r, s.trustedProxies); ip != remoteIP(ws.RemoteAddr()) {
+		wsConn.remoteAddr = &net.TCPAddr{IP: net.ParseIP(ip)}
+	}
+
+	conn, ok := s.admitConnection(wsConn, "websocket")
+	if !ok {
+		return
+	}
 
 	// Create session (exactly like TCP handler does)
 	sess, err := s.sessions.CreateSession(nil, "", "websocket", conn)
@@ -140,6 +150,9 @@ func (c *WebSocketConn) LocalAddr() net.Addr {
 
 // RemoteAddr implements net.Conn.RemoteAddr
 func (c *WebSocketConn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
 	return c.ws.RemoteAddr()
 }
 
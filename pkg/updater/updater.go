@@ -1,7 +1,13 @@
 package updater
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,146 +17,414 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 )
 
 const (
-	GitHubRepo   = "aeolun/superchat"
-	InstallScript = "https://raw.githubusercontent.com/aeolun/superchat/main/install.sh"
+	GitHubRepo        = "aeolun/superchat"
+	DefaultAPIBaseURL = "https://api.github.com"
+
+	// ChecksumsAsset lists the SHA-256 of every release asset, in `sha256sum` format,
+	// after a "# version <tag>" comment line. SignatureAsset is the raw ed25519
+	// signature of that file, so the signature also covers the release it's for.
+	ChecksumsAsset = "SHA256SUMS"
+	SignatureAsset = "SHA256SUMS.sig"
+
+	manifestVersionPrefix = "# version "
+
+	maxManifestSize = 1 << 20   // SHA256SUMS
+	maxBinarySize   = 512 << 20 // Release binaries
+)
+
+// PublicKey is the base64-encoded ed25519 key releases are signed with. It is set at
+// build time (-ldflags "-X github.com/aeolun/superchat/pkg/updater.PublicKey=...");
+// builds without one can check for updates but not install them.
+var PublicKey = ""
+
+// Channel selects which releases to update to
+type Channel string
+
+const (
+	ChannelStable     Channel = "stable"     // Releases only
+	ChannelPrerelease Channel = "prerelease" // Releases and pre-releases
 )
 
+// ParseChannel parses a channel name
+func ParseChannel(s string) (Channel, error) {
+	switch Channel(s) {
+	case ChannelStable, ChannelPrerelease:
+		return Channel(s), nil
+	}
+	return "", fmt.Errorf("unknown update channel %q (use %q or %q)", s, ChannelStable, ChannelPrerelease)
+}
+
+// DefaultChannel returns the channel for a build: pre-releases keep following pre-releases
+func DefaultChannel(currentVersion string) Channel {
+	if v, err := ParseVersion(currentVersion); err == nil && v.IsPrerelease() {
+		return ChannelPrerelease
+	}
+	return ChannelStable
+}
+
 // Release represents a GitHub release
 type Release struct {
-	TagName string `json:"tag_name"`
-	Name    string `json:"name"`
+	TagName    string  `json:"tag_name"`
+	Name       string  `json:"name"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
 }
 
-// CheckLatestVersion fetches the latest version from GitHub
-func CheckLatestVersion() (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", GitHubRepo)
+// asset finds a release asset by name
+func (r *Release) asset(name string) (*Asset, bool) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// Updater finds and installs releases from GitHub
+type Updater struct {
+	APIBaseURL string // GitHub API, without a trailing slash
+	Repo       string // owner/name
+	Channel    Channel
+	PublicKey  ed25519.PublicKey // nil = can't install updates
+	HTTPClient *http.Client
+
+	// CurrentVersion is the installed version; Install refuses releases that aren't
+	// newer ("" = install any release)
+	CurrentVersion string
+
+	// GOOS and GOARCH pick the release binary to install
+	GOOS, GOARCH string
+
+	// CheckBinary runs a freshly installed binary; if it fails, the old one is restored.
+	// Defaults to running it with --version.
+	CheckBinary func(path string) error
+}
+
+// New creates an updater for the SuperChat client on this platform, using the
+// PublicKey it was built with
+func New(channel Channel) *Updater {
+	u := &Updater{
+		APIBaseURL: DefaultAPIBaseURL,
+		Repo:       GitHubRepo,
+		Channel:    channel,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+	}
+	if key, err := base64.StdEncoding.DecodeString(PublicKey); err == nil && len(key) == ed25519.PublicKeySize {
+		u.PublicKey = key
+	}
+	return u
+}
+
+// AssetName returns the name of the client binary for a platform
+func AssetName(goos, goarch string) string {
+	name := fmt.Sprintf("superchat-%s-%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
 
-	resp, err := http.Get(url)
+// LatestRelease returns the newest release on the updater's channel
+func (u *Updater) LatestRelease() (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=50", u.APIBaseURL, u.Repo)
+
+	resp, err := u.HTTPClient.Get(url)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch release info: %w", err)
+		return nil, fmt.Errorf("failed to fetch release info: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
 	}
 
-	var release Release
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return "", fmt.Errorf("failed to parse release info: %w", err)
+	var releases []Release
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to parse release info: %w", err)
 	}
 
+	var latest *Release
+	var latestVersion Version
+	for i := range releases {
+		release := &releases[i]
+		version, err := ParseVersion(release.TagName)
+		if err != nil || release.Draft {
+			continue
+		}
+		if u.Channel != ChannelPrerelease && (release.Prerelease || version.IsPrerelease()) {
+			continue
+		}
+		if latest == nil || version.Compare(latestVersion) > 0 {
+			latest = release
+			latestVersion = version
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no %s releases found", u.Channel)
+	}
+	return latest, nil
+}
+
+// CheckLatestVersion returns the tag of the newest release on the channel
+// currentVersion follows
+func CheckLatestVersion(currentVersion string) (string, error) {
+	release, err := New(DefaultChannel(currentVersion)).LatestRelease()
+	if err != nil {
+		return "", err
+	}
 	return release.TagName, nil
 }
 
-// CompareVersions returns true if newVersion is newer than currentVersion
-func CompareVersions(currentVersion, newVersion string) bool {
-	// Simple string comparison for now (works for semantic versions like v1.2.3)
-	// Strip "v" prefix if present
-	current := strings.TrimPrefix(currentVersion, "v")
-	new := strings.TrimPrefix(newVersion, "v")
+// Install replaces the binary at exePath with the release's. The download must match
+// the checksum manifest signed for the release, and the old binary comes back if
+// anything fails.
+func (u *Updater) Install(release *Release, exePath string) error {
+	if len(u.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("this build has no update signing key; reinstall with install.sh instead")
+	}
 
-	if current == "dev" {
-		return true // Always update dev versions
+	name := AssetName(u.GOOS, u.GOARCH)
+	binary, ok := release.asset(name)
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", release.TagName, u.GOOS, u.GOARCH)
+	}
+	want, err := u.verifiedChecksum(release, name)
+	if err != nil {
+		return err
 	}
 
-	return new > current
-}
+	// The tag is bound to the signature now, so an old release can't pose as a new one
+	if u.CurrentVersion != "" && !CompareVersions(u.CurrentVersion, release.TagName) {
+		return fmt.Errorf("release %s is not newer than the installed %s", release.TagName, u.CurrentVersion)
+	}
 
-// Update checks for updates and installs if available
-func Update(currentVersion, exePath string, args []string) error {
-	// Check latest version
-	latestVersion, err := CheckLatestVersion()
+	// Download next to the binary, so the swap is a rename on the same filesystem
+	dir := filepath.Dir(exePath)
+	tmpFile, err := os.CreateTemp(dir, ".superchat-update-*")
 	if err != nil {
-		return err
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("no permission to write to %s; run the update as a user who can (e.g. with sudo)", dir)
+		}
+		return fmt.Errorf("failed to create temp file: %w", err)
 	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
 
-	fmt.Printf("Latest version: %s\n", latestVersion)
+	hash := sha256.New()
+	err = u.download(binary.URL, maxBinarySize, io.MultiWriter(tmpFile, hash))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if got := hash.Sum(nil); hex.EncodeToString(got) != want {
+		return fmt.Errorf("checksum mismatch for %s: got %x, want %s", name, got, want)
+	}
 
-	// Compare versions
-	if !CompareVersions(currentVersion, latestVersion) {
-		fmt.Println("You're already on the latest version!")
-		return nil
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(exePath); err == nil {
+		mode = info.Mode().Perm() | 0111
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to make update executable: %w", err)
 	}
 
-	fmt.Printf("New version available: %s\n", latestVersion)
-	fmt.Println("Downloading and installing update...")
+	return u.swap(tmpPath, exePath)
+}
+
+// verifiedChecksum checks the signature of the release's checksum manifest and that it
+// was signed for this release, and returns the hex SHA-256 it lists for name
+func (u *Updater) verifiedChecksum(release *Release, name string) (string, error) {
+	manifestAsset, ok := release.asset(ChecksumsAsset)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", release.TagName, ChecksumsAsset)
+	}
+	signatureAsset, ok := release.asset(SignatureAsset)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", release.TagName, SignatureAsset)
+	}
 
-	// Determine install directory from executable path
-	installDir := filepath.Dir(exePath)
+	var manifest, signature strings.Builder
+	if err := u.download(manifestAsset.URL, maxManifestSize, &manifest); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", ChecksumsAsset, err)
+	}
+	if err := u.download(signatureAsset.URL, ed25519.SignatureSize, &signature); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", SignatureAsset, err)
+	}
+	if !ed25519.Verify(u.PublicKey, []byte(manifest.String()), []byte(signature.String())) {
+		return "", fmt.Errorf("%s of release %s has an invalid signature", ChecksumsAsset, release.TagName)
+	}
+	if version := manifestVersion(manifest.String()); version != release.TagName {
+		if version == "" {
+			return "", fmt.Errorf("%s of release %s isn't signed for a version", ChecksumsAsset, release.TagName)
+		}
+		return "", fmt.Errorf("%s of release %s is signed for %s", ChecksumsAsset, release.TagName, version)
+	}
 
-	// Download and run install script
-	if err := runInstallScript(installDir); err != nil {
-		return fmt.Errorf("failed to run install script: %w", err)
+	checksums, err := ParseChecksums(manifest.String())
+	if err != nil {
+		return "", err
+	}
+	sum, ok := checksums[name]
+	if !ok {
+		return "", fmt.Errorf("%s has no checksum for %s", ChecksumsAsset, name)
 	}
+	return sum, nil
+}
 
-	fmt.Println("Update installed successfully!")
-	fmt.Println("Restarting with new version...")
+// ParseChecksums parses `sha256sum` output into file name -> lowercase hex SHA-256.
+// Comment lines (like the version) are skipped, as `sha256sum -c` does.
+func ParseChecksums(manifest string) (map[string]string, error) {
+	checksums := make(map[string]string)
+	for i, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		// "<sum>  <name>" in text mode, "<sum> *<name>" in binary mode
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("invalid %s line %d", ChecksumsAsset, i+1)
+		}
+		checksums[name] = strings.ToLower(sum)
+	}
+	return checksums, nil
+}
 
-	// Exec the new binary
-	return execNewBinary(exePath, args)
+// manifestVersion returns the release tag a checksum manifest was made for ("" if none)
+func manifestVersion(manifest string) string {
+	first, _, _ := strings.Cut(manifest, "\n")
+	version, ok := strings.CutPrefix(strings.TrimRight(first, "\r"), manifestVersionPrefix)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(version)
 }
 
-// runInstallScript downloads and executes the install script
-func runInstallScript(installDir string) error {
-	// Download install script
-	resp, err := http.Get(InstallScript)
+// download writes the body of url to w, refusing bodies over limit bytes
+func (u *Updater) download(url string, limit int64, w io.Writer) error {
+	resp, err := u.HTTPClient.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to download install script: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download install script: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
-	// Write to temporary file
-	tmpFile, err := os.CreateTemp("", "superchat-install-*.sh")
+	n, err := io.Copy(w, io.LimitReader(resp.Body, limit+1))
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return err
 	}
-	defer os.Remove(tmpFile.Name())
+	if n > limit {
+		return fmt.Errorf("larger than %d bytes", limit)
+	}
+	return nil
+}
 
-	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
-		tmpFile.Close()
-		return fmt.Errorf("failed to write install script: %w", err)
+// swap moves newPath over exePath, keeping the old binary until the new one runs
+func (u *Updater) swap(newPath, exePath string) error {
+	backupPath := exePath + ".old"
+	os.Remove(backupPath) // Left over from an earlier update on Windows
+
+	// A hard link keeps exePath in place until the rename replaces it atomically.
+	// Windows can't replace a running binary, but it can move it out of the way.
+	linked := runtime.GOOS != "windows" && os.Link(exePath, backupPath) == nil
+	if !linked {
+		if err := os.Rename(exePath, backupPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", exePath, err)
+		}
 	}
-	tmpFile.Close()
 
-	// Make executable
-	if err := os.Chmod(tmpFile.Name(), 0755); err != nil {
-		return fmt.Errorf("failed to make install script executable: %w", err)
+	if err := os.Rename(newPath, exePath); err != nil {
+		if !linked {
+			os.Rename(backupPath, exePath)
+		} else {
+			os.Remove(backupPath)
+		}
+		return fmt.Errorf("failed to replace %s: %w", exePath, err)
 	}
 
-	// Determine if we need sudo (if installing to system directory)
-	needsSudo := false
-	if strings.HasPrefix(installDir, "/usr/") {
-		needsSudo = true
+	check := u.CheckBinary
+	if check == nil {
+		check = runVersion
+	}
+	if err := check(exePath); err != nil {
+		if restoreErr := os.Rename(backupPath, exePath); restoreErr != nil {
+			return fmt.Errorf("new binary doesn't work (%v) and restoring the old one failed: %w", err, restoreErr)
+		}
+		return fmt.Errorf("new binary doesn't work, restored the old one: %w", err)
 	}
 
-	// Run install script
-	var cmd *exec.Cmd
-	if needsSudo {
-		fmt.Println("System-wide installation detected. You may be prompted for sudo password.")
-		cmd = exec.Command("sudo", "sh", tmpFile.Name(), "--global")
-	} else {
-		// Set INSTALL_DIR environment variable to preserve install location
-		cmd = exec.Command("sh", tmpFile.Name())
-		cmd.Env = append(os.Environ(), fmt.Sprintf("INSTALL_DIR=%s", installDir))
+	// Windows keeps the running binary locked; it's removed by the next update
+	os.Remove(backupPath)
+	return nil
+}
+
+// runVersion checks that a binary starts by running it with --version
+func runVersion(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput(); err != nil {
+		return fmt.Errorf("%s --version: %w (%s)", filepath.Base(path), err, strings.TrimSpace(string(out)))
 	}
+	return nil
+}
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+// Update installs the newest release on channel if it's newer than currentVersion,
+// then restarts with args (program name first)
+func Update(currentVersion, exePath string, args []string, channel Channel) error {
+	u := New(channel)
+	u.CurrentVersion = currentVersion
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("install script failed: %w", err)
+	release, err := u.LatestRelease()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	fmt.Printf("Latest version: %s\n", release.TagName)
+
+	// Compare versions
+	if !CompareVersions(currentVersion, release.TagName) {
+		fmt.Println("You're already on the latest version!")
+		return nil
+	}
+
+	fmt.Printf("New version available: %s\n", release.TagName)
+	fmt.Println("Downloading and verifying update...")
+
+	// Replace the file the binary really is, not a symlink to it
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	if err := u.Install(release, exePath); err != nil {
+		return err
+	}
+
+	fmt.Println("Update installed successfully!")
+	fmt.Println("Restarting with new version...")
+
+	// Exec the new binary
+	return execNewBinary(exePath, args)
 }
 
 // execNewBinary replaces the current process with the new binary
@@ -162,23 +436,9 @@ func execNewBinary(exePath string, args []string) error {
 	}
 
 	// Get the binary name (sc or superchat)
-	binName := filepath.Base(exePath)
-
-	// Build new args (remove "update" subcommand if present)
-	newArgs := []string{binName}
-	skipNext := false
-	for i, arg := range args {
-		if i == 0 {
-			continue // Skip program name
-		}
-		if skipNext {
-			skipNext = false
-			continue
-		}
-		if arg == "update" {
-			continue // Skip "update" subcommand
-		}
-		newArgs = append(newArgs, arg)
+	newArgs := []string{filepath.Base(exePath)}
+	if len(args) > 1 {
+		newArgs = append(newArgs, args[1:]...)
 	}
 
 	// Exec the new binary (Unix only)
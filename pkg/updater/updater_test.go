@@ -0,0 +1,246 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testReleases serves releases and their assets like the GitHub API would
+type testReleases struct {
+	releases []Release
+	files    map[string][]byte // "tag/name" -> content
+}
+
+func (r *testReleases) add(tag string, prerelease, draft bool, files map[string][]byte) {
+	release := Release{TagName: tag, Prerelease: prerelease, Draft: draft}
+	for name, content := range files {
+		release.Assets = append(release.Assets, Asset{Name: name, Size: int64(len(content))})
+		r.files[tag+"/"+name] = content
+	}
+	r.releases = append(r.releases, release)
+}
+
+// serve starts the server and points the release assets at it
+func (r *testReleases) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/"+GitHubRepo+"/releases", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(r.releases)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, req *http.Request) {
+		content, ok := r.files[strings.TrimPrefix(req.URL.Path, "/download/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(content)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	for i := range r.releases {
+		for j := range r.releases[i].Assets {
+			asset := &r.releases[i].Assets[j]
+			asset.URL = fmt.Sprintf("%s/download/%s/%s", srv.URL, r.releases[i].TagName, asset.Name)
+		}
+	}
+	return srv
+}
+
+// release finds a served release by tag
+func (r *testReleases) release(t *testing.T, tag string) *Release {
+	t.Helper()
+	for i := range r.releases {
+		if r.releases[i].TagName == tag {
+			return &r.releases[i]
+		}
+	}
+	t.Fatalf("No release %s", tag)
+	return nil
+}
+
+// signedFiles returns a release's assets: the binary and a manifest for version (none
+// if "") signed with key
+func signedFiles(key ed25519.PrivateKey, version, binaryName string, binary []byte) map[string][]byte {
+	manifest := fmt.Sprintf("%x  %s\n%x  superchat-server-linux-amd64\n", sha256.Sum256(binary), binaryName, sha256.Sum256([]byte("server")))
+	if version != "" {
+		manifest = "# version " + version + "\n" + manifest
+	}
+	return map[string][]byte{
+		binaryName:     binary,
+		ChecksumsAsset: []byte(manifest),
+		SignatureAsset: ed25519.Sign(key, []byte(manifest)),
+	}
+}
+
+func testUpdater(srv *httptest.Server, channel Channel, key ed25519.PublicKey) *Updater {
+	return &Updater{
+		APIBaseURL:  srv.URL,
+		Repo:        GitHubRepo,
+		Channel:     channel,
+		PublicKey:   key,
+		HTTPClient:  srv.Client(),
+		GOOS:        "linux",
+		GOARCH:      "amd64",
+		CheckBinary: func(string) error { return nil },
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	releases := &testReleases{files: make(map[string][]byte)}
+	releases.add("v1.9.0", false, false, nil)
+	releases.add("v1.10.0", false, false, nil)
+	releases.add("v1.11.0-rc.1", true, false, nil)
+	releases.add("v1.11.0-beta.1", false, false, nil) // Not flagged, but still a pre-release
+	releases.add("v2.0.0", false, true, nil)          // Draft
+	releases.add("nightly", false, false, nil)
+	srv := releases.serve(t)
+
+	tests := []struct {
+		channel Channel
+		want    string
+	}{
+		{ChannelStable, "v1.10.0"},
+		{ChannelPrerelease, "v1.11.0-rc.1"},
+	}
+	for _, tt := range tests {
+		release, err := testUpdater(srv, tt.channel, nil).LatestRelease()
+		if err != nil {
+			t.Fatalf("LatestRelease(%s) failed: %v", tt.channel, err)
+		}
+		if release.TagName != tt.want {
+			t.Errorf("LatestRelease(%s) = %s, want %s", tt.channel, release.TagName, tt.want)
+		}
+	}
+
+	empty := (&testReleases{files: make(map[string][]byte)}).serve(t)
+	if _, err := testUpdater(empty, ChannelStable, nil).LatestRelease(); err == nil {
+		t.Error("Expected an error without releases")
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	checksums, err := ParseChecksums("# version v1.2.3\n" + sum + "  superchat-linux-amd64\r\n\n" + strings.ToUpper(sum) + " *superchat-windows-amd64.exe\n")
+	if err != nil {
+		t.Fatalf("ParseChecksums failed: %v", err)
+	}
+	if checksums["superchat-linux-amd64"] != sum || checksums["superchat-windows-amd64.exe"] != sum {
+		t.Errorf("Unexpected checksums %v", checksums)
+	}
+
+	for _, manifest := range []string{"abc  superchat", sum, "zz" + sum[2:] + "  superchat"} {
+		if _, err := ParseChecksums(manifest); err == nil {
+			t.Errorf("Expected ParseChecksums(%q) to fail", manifest)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test binaries are shell scripts")
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	newBinary := []byte("#!/bin/sh\necho SuperChat v1.10.0\n")
+	brokenBinary := []byte("#!/bin/sh\nexit 1\n")
+	name := AssetName("linux", "amd64")
+
+	releases := &testReleases{files: make(map[string][]byte)}
+	releases.add("v1.10.0", false, false, signedFiles(privateKey, "v1.10.0", name, newBinary))
+	releases.add("v1.11.0", false, false, signedFiles(otherKey, "v1.11.0", name, newBinary))
+	releases.add("v1.12.0", false, false, signedFiles(privateKey, "v1.12.0", name, brokenBinary))
+	tampered := signedFiles(privateKey, "v1.13.0", name, newBinary)
+	tampered[name] = []byte("#!/bin/sh\necho pwned\n")
+	releases.add("v1.13.0", false, false, tampered)
+	releases.add("v1.14.0", false, false, map[string][]byte{name: newBinary})
+	releases.add("v1.15.0", false, false, signedFiles(privateKey, "v1.10.0", name, newBinary)) // Older release replayed
+	releases.add("v1.16.0", false, false, signedFiles(privateKey, "", name, newBinary))
+	srv := releases.serve(t)
+
+	oldBinary := []byte("#!/bin/sh\necho SuperChat v1.9.0\n")
+	dir := t.TempDir()
+	exePath := filepath.Join(dir, "sc")
+	reset := func() {
+		t.Helper()
+		if err := os.WriteFile(exePath, oldBinary, 0700); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	expectBinary := func(want []byte) {
+		t.Helper()
+		got, err := os.ReadFile(exePath)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("Expected binary %q, got %q", want, got)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("Expected only the binary to be left, got %d files", len(entries))
+		}
+	}
+
+	// Refused: no key, wrong key, tampered binary, no manifest, manifest of another
+	// release, manifest without a version
+	failures := []struct {
+		tag string
+		key ed25519.PublicKey
+	}{
+		{"v1.10.0", nil},
+		{"v1.11.0", publicKey},
+		{"v1.13.0", publicKey},
+		{"v1.14.0", publicKey},
+		{"v1.15.0", publicKey},
+		{"v1.16.0", publicKey},
+	}
+	for _, tt := range failures {
+		reset()
+		if err := testUpdater(srv, ChannelStable, tt.key).Install(releases.release(t, tt.tag), exePath); err == nil {
+			t.Errorf("Expected installing %s to fail", tt.tag)
+		}
+		expectBinary(oldBinary)
+	}
+
+	// A binary that doesn't run is rolled back
+	reset()
+	u := testUpdater(srv, ChannelStable, publicKey)
+	u.CheckBinary = nil
+	if err := u.Install(releases.release(t, "v1.12.0"), exePath); err == nil || !strings.Contains(err.Error(), "restored") {
+		t.Errorf("Expected the broken binary to be rolled back, got %v", err)
+	}
+	expectBinary(oldBinary)
+
+	// Releases that aren't newer than the installed one are refused
+	reset()
+	u.CurrentVersion = "v1.10.0"
+	if err := u.Install(releases.release(t, "v1.10.0"), exePath); err == nil || !strings.Contains(err.Error(), "not newer") {
+		t.Errorf("Expected reinstalling the same version to be refused, got %v", err)
+	}
+	expectBinary(oldBinary)
+
+	reset()
+	u.CurrentVersion = "v1.9.0"
+	if err := u.Install(releases.release(t, "v1.10.0"), exePath); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	expectBinary(newBinary)
+	if info, err := os.Stat(exePath); err != nil || info.Mode().Perm() != 0711 {
+		t.Errorf("Expected the binary to keep its mode, got %v", info.Mode())
+	}
+}
@@ -0,0 +1,146 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version (https://semver.org). Build metadata is ignored,
+// as it doesn't take part in precedence.
+type Version struct {
+	Major, Minor, Patch uint64
+	Prerelease          []string // Dot-separated identifiers after "-" (nil for releases)
+}
+
+// ParseVersion parses a version like "v1.2.3" or "1.2.3-rc.1+build.5"
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v Version
+	core, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+	for i, field := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		n, err := parseNumeric(parts[i])
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		*field = n
+	}
+
+	if hasPre {
+		v.Prerelease = strings.Split(pre, ".")
+		for _, id := range v.Prerelease {
+			if id == "" || strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+				return Version{}, fmt.Errorf("invalid version %q: bad pre-release identifier %q", s, id)
+			}
+			if isNumeric(id) && len(id) > 1 && id[0] == '0' {
+				return Version{}, fmt.Errorf("invalid version %q: leading zero in %q", s, id)
+			}
+		}
+	}
+	return v, nil
+}
+
+// parseNumeric parses a MAJOR, MINOR or PATCH number (no leading zeros)
+func parseNumeric(s string) (uint64, error) {
+	if !isNumeric(s) || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func isNumeric(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// IsPrerelease reports whether v is a pre-release like 1.2.0-rc.1
+func (v Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// String formats v without a "v" prefix
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.IsPrerelease() {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than other
+func (v Version) Compare(other Version) int {
+	for _, pair := range [][2]uint64{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A pre-release comes before its release
+	switch {
+	case !v.IsPrerelease() && !other.IsPrerelease():
+		return 0
+	case !v.IsPrerelease():
+		return 1
+	case !other.IsPrerelease():
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := comparePrerelease(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.Prerelease) < len(other.Prerelease):
+		return -1
+	case len(v.Prerelease) > len(other.Prerelease):
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares pre-release identifiers: numbers numerically, and below
+// alphanumeric identifiers, which compare in ASCII order
+func comparePrerelease(a, b string) int {
+	aNum, bNum := isNumeric(a), isNumeric(b)
+	switch {
+	case aNum && bNum:
+		if len(a) != len(b) {
+			// No leading zeros, so the longer number is bigger
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// CompareVersions returns true if newVersion is newer than currentVersion. Builds that
+// aren't a release (like "dev") are older than every release.
+func CompareVersions(currentVersion, newVersion string) bool {
+	next, err := ParseVersion(newVersion)
+	if err != nil {
+		return false
+	}
+	current, err := ParseVersion(currentVersion)
+	if err != nil {
+		return true
+	}
+	return next.Compare(current) > 0
+}
@@ -0,0 +1,93 @@
+package updater
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // "" = invalid
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.10.0", "1.10.0"},
+		{"v2.0.0-rc.1", "2.0.0-rc.1"},
+		{"1.0.0-alpha-2.beta", "1.0.0-alpha-2.beta"},
+		{"1.0.0+build.5", "1.0.0"},
+		{"1.0.0-rc.1+build.5", "1.0.0-rc.1"},
+		{"dev", ""},
+		{"1.2", ""},
+		{"1.2.3.4", ""},
+		{"01.2.3", ""},
+		{"1.2.3-", ""},
+		{"1.2.3-rc..1", ""},
+		{"1.2.3-01", ""},
+		{"1.2.3-rc_1", ""},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.input)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("ParseVersion(%q) = %s, want error", tt.input, v)
+		case tt.want != "" && err != nil:
+			t.Errorf("ParseVersion(%q) failed: %v", tt.input, err)
+		case tt.want != "" && v.String() != tt.want:
+			t.Errorf("ParseVersion(%q) = %s, want %s", tt.input, v, tt.want)
+		}
+	}
+}
+
+func TestVersionOrder(t *testing.T) {
+	// semver.org, section 11, oldest first
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.9.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			va, _ := ParseVersion(a)
+			vb, _ := ParseVersion(b)
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := va.Compare(vb); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		current, next string
+		want          bool
+	}{
+		{"v1.9.0", "v1.10.0", true},
+		{"v1.10.0", "v1.9.0", false},
+		{"v1.10.0", "v1.10.0", false},
+		{"v1.10.0+local", "v1.10.0", false},
+		{"v2.0.0-rc.1", "v2.0.0", true},
+		{"v2.0.0", "v2.0.0-rc.1", false},
+		{"dev", "v0.1.0", true},
+		{"v1.0.0", "nightly", false},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.current, tt.next); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %v, want %v", tt.current, tt.next, got, tt.want)
+		}
+	}
+
+	if DefaultChannel("v2.0.0-rc.1") != ChannelPrerelease || DefaultChannel("v1.0.0") != ChannelStable || DefaultChannel("dev") != ChannelStable {
+		t.Error("Expected only pre-release builds to follow pre-releases")
+	}
+}
@@ -447,6 +447,8 @@ export interface NewMessage {
   created_at: bigint;
   edited_at: { present: number, value?: bigint };
   reply_count: number;
+  reaction_count: number;
+  reactions: ReactionSummary[];
 }
 
 export class NewMessageEncoder extends BitStreamEncoder {
@@ -490,6 +492,18 @@ export class NewMessageEncoder extends BitStreamEncoder {
       this.writeInt64(value.edited_at.value, "big_endian");
     }
     this.writeUint32(value.reply_count, "big_endian");
+    this.writeUint16(value.reaction_count, "big_endian");
+    for (const value_reactions_item of value.reactions) {
+      const value_reactions_item_emoji_bytes = new TextEncoder().encode(value_reactions_item.emoji);
+      this.writeUint16(value_reactions_item_emoji_bytes.length, "big_endian");
+      for (const byte of value_reactions_item_emoji_bytes) {
+        this.writeUint8(byte);
+      }
+      this.writeUint32(value_reactions_item.user_count, "big_endian");
+      for (const value_reactions_item_user_ids_item of value_reactions_item.user_ids) {
+        this.writeUint64(value_reactions_item_user_ids_item, "big_endian");
+      }
+    }
     return this.finish();
   }
 }
@@ -538,6 +552,28 @@ export class NewMessageDecoder extends BitStreamDecoder {
       value.edited_at.value = this.readInt64("big_endian");
     }
     value.reply_count = this.readUint32("big_endian");
+    value.reaction_count = this.readUint16("big_endian");
+    value.reactions = [];
+    const reactions_length = value.reaction_count ?? this.context?.reaction_count;
+    if (reactions_length === undefined) {
+      throw new Error('Field-referenced array length field "reaction_count" not found in value or context');
+    }
+    for (let i = 0; i < reactions_length; i++) {
+      let reactions_item: any;
+      reactions_item = {};
+      const reactions_item_emoji_length = this.readUint16("big_endian");
+      const reactions_item_emoji_bytes: number[] = [];
+      for (let i = 0; i < reactions_item_emoji_length; i++) {
+        reactions_item_emoji_bytes.push(this.readUint8());
+      }
+      reactions_item.emoji = new TextDecoder().decode(new Uint8Array(reactions_item_emoji_bytes));
+      reactions_item.user_count = this.readUint32("big_endian");
+      reactions_item.user_ids = [];
+      for (let i = 0; i < reactions_item.user_count; i++) {
+        reactions_item.user_ids.push(this.readUint64("big_endian"));
+      }
+      value.reactions.push(reactions_item);
+    }
     return value;
   }
 }
@@ -1025,6 +1061,8 @@ export interface Message {
   created_at: bigint;
   edited_at: { present: number, value?: bigint };
   reply_count: number;
+  reaction_count: number;
+  reactions: ReactionSummary[];
 }
 
 export class MessageEncoder extends BitStreamEncoder {
@@ -1068,6 +1106,18 @@ export class MessageEncoder extends BitStreamEncoder {
       this.writeInt64(value.edited_at.value, "big_endian");
     }
     this.writeUint32(value.reply_count, "big_endian");
+    this.writeUint16(value.reaction_count, "big_endian");
+    for (const value_reactions_item of value.reactions) {
+      const value_reactions_item_emoji_bytes = new TextEncoder().encode(value_reactions_item.emoji);
+      this.writeUint16(value_reactions_item_emoji_bytes.length, "big_endian");
+      for (const byte of value_reactions_item_emoji_bytes) {
+        this.writeUint8(byte);
+      }
+      this.writeUint32(value_reactions_item.user_count, "big_endian");
+      for (const value_reactions_item_user_ids_item of value_reactions_item.user_ids) {
+        this.writeUint64(value_reactions_item_user_ids_item, "big_endian");
+      }
+    }
     return this.finish();
   }
 }
@@ -1116,6 +1166,88 @@ export class MessageDecoder extends BitStreamDecoder {
       value.edited_at.value = this.readInt64("big_endian");
     }
     value.reply_count = this.readUint32("big_endian");
+    value.reaction_count = this.readUint16("big_endian");
+    value.reactions = [];
+    const reactions_length = value.reaction_count ?? this.context?.reaction_count;
+    if (reactions_length === undefined) {
+      throw new Error('Field-referenced array length field "reaction_count" not found in value or context');
+    }
+    for (let i = 0; i < reactions_length; i++) {
+      let reactions_item: any;
+      reactions_item = {};
+      const reactions_item_emoji_length = this.readUint16("big_endian");
+      const reactions_item_emoji_bytes: number[] = [];
+      for (let i = 0; i < reactions_item_emoji_length; i++) {
+        reactions_item_emoji_bytes.push(this.readUint8());
+      }
+      reactions_item.emoji = new TextDecoder().decode(new Uint8Array(reactions_item_emoji_bytes));
+      reactions_item.user_count = this.readUint32("big_endian");
+      reactions_item.user_ids = [];
+      for (let i = 0; i < reactions_item.user_count; i++) {
+        reactions_item.user_ids.push(this.readUint64("big_endian"));
+      }
+      value.reactions.push(reactions_item);
+    }
+    return value;
+  }
+}
+
+/**
+ * Users who reacted to a message with one emoji
+ */
+export interface ReactionSummary {
+  emoji: String;
+  user_count: number;
+  user_ids: bigint[];
+}
+
+export class ReactionSummaryEncoder extends BitStreamEncoder {
+  private compressionDict: Map<string, number> = new Map();
+
+  constructor() {
+    super("msb_first");
+  }
+
+  encode(value: ReactionSummary): Uint8Array {
+    // Reset compression dictionary for each encode
+    this.compressionDict.clear();
+
+    const value_emoji_bytes = new TextEncoder().encode(value.emoji);
+    this.writeUint16(value_emoji_bytes.length, "big_endian");
+    for (const byte of value_emoji_bytes) {
+      this.writeUint8(byte);
+    }
+    this.writeUint32(value.user_count, "big_endian");
+    for (const value_user_ids_item of value.user_ids) {
+      this.writeUint64(value_user_ids_item, "big_endian");
+    }
+    return this.finish();
+  }
+}
+
+export class ReactionSummaryDecoder extends BitStreamDecoder {
+  constructor(bytes: Uint8Array | number[], private context?: any) {
+    super(bytes, "msb_first");
+  }
+
+  decode(): ReactionSummary {
+    const value: any = {};
+
+    const emoji_length = this.readUint16("big_endian");
+    const emoji_bytes: number[] = [];
+    for (let i = 0; i < emoji_length; i++) {
+      emoji_bytes.push(this.readUint8());
+    }
+    value.emoji = new TextDecoder().decode(new Uint8Array(emoji_bytes));
+    value.user_count = this.readUint32("big_endian");
+    value.user_ids = [];
+    const user_ids_length = value.user_count ?? this.context?.user_count;
+    if (user_ids_length === undefined) {
+      throw new Error('Field-referenced array length field "user_count" not found in value or context');
+    }
+    for (let i = 0; i < user_ids_length; i++) {
+      value.user_ids.push(this.readUint64("big_endian"));
+    }
     return value;
   }
 }
@@ -1183,6 +1315,18 @@ export class MessageListEncoder extends BitStreamEncoder {
         this.writeInt64(value_messages_item.edited_at.value, "big_endian");
       }
       this.writeUint32(value_messages_item.reply_count, "big_endian");
+      this.writeUint16(value_messages_item.reaction_count, "big_endian");
+      for (const value_messages_item_reactions_item of value_messages_item.reactions) {
+        const value_messages_item_reactions_item_emoji_bytes = new TextEncoder().encode(value_messages_item_reactions_item.emoji);
+        this.writeUint16(value_messages_item_reactions_item_emoji_bytes.length, "big_endian");
+        for (const byte of value_messages_item_reactions_item_emoji_bytes) {
+          this.writeUint8(byte);
+        }
+        this.writeUint32(value_messages_item_reactions_item.user_count, "big_endian");
+        for (const value_messages_item_reactions_item_user_ids_item of value_messages_item_reactions_item.user_ids) {
+          this.writeUint64(value_messages_item_reactions_item_user_ids_item, "big_endian");
+        }
+      }
     }
     return this.finish();
   }
@@ -1252,6 +1396,28 @@ export class MessageListDecoder extends BitStreamDecoder {
         messages_item.edited_at.value = this.readInt64("big_endian");
       }
       messages_item.reply_count = this.readUint32("big_endian");
+      messages_item.reaction_count = this.readUint16("big_endian");
+      messages_item.reactions = [];
+      const messages_item_reactions_length = messages_item.reaction_count ?? this.context?.reaction_count;
+      if (messages_item_reactions_length === undefined) {
+        throw new Error('Field-referenced array length field "reaction_count" not found in value or context');
+      }
+      for (let i = 0; i < messages_item_reactions_length; i++) {
+        let messages_item_reactions_item: any;
+        messages_item_reactions_item = {};
+        const messages_item_reactions_item_emoji_length = this.readUint16("big_endian");
+        const messages_item_reactions_item_emoji_bytes: number[] = [];
+        for (let i = 0; i < messages_item_reactions_item_emoji_length; i++) {
+          messages_item_reactions_item_emoji_bytes.push(this.readUint8());
+        }
+        messages_item_reactions_item.emoji = new TextDecoder().decode(new Uint8Array(messages_item_reactions_item_emoji_bytes));
+        messages_item_reactions_item.user_count = this.readUint32("big_endian");
+        messages_item_reactions_item.user_ids = [];
+        for (let i = 0; i < messages_item_reactions_item.user_count; i++) {
+          messages_item_reactions_item.user_ids.push(this.readUint64("big_endian"));
+        }
+        messages_item.reactions.push(messages_item_reactions_item);
+      }
       value.messages.push(messages_item);
     }
     return value;
@@ -1179,7 +1179,9 @@ class SuperChatClient {
       content: newMsg.content,
       created_at: newMsg.created_at,
       edited_at: newMsg.edited_at,
-      reply_count: newMsg.reply_count
+      reply_count: newMsg.reply_count,
+      reaction_count: newMsg.reaction_count,
+      reactions: newMsg.reactions
     };
 
     // Check if this is our own message by comparing nicknames